@@ -0,0 +1,127 @@
+package attest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/rego"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/named_files_reader"
+)
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s deployment attest --result result.json --out att.json [--max-age 1h]\n" +
+		"    [--metadata key=value]... [--expiry 24h] [--nonce value] orgPath projectsPath\n" +
+		"\n" +
+		"Example:\n" +
+		"%s deployment attest --result result.json --out att.json --max-age 10m --metadata run=1234 --expiry 24h ./path/to/policy/org ./path/to/policy/projects\n" +
+		"\n" +
+		"--metadata records a property in the attestation and may be repeated. Keys must not start with 'slsa.dev/'.\n" +
+		"--expiry sets the time after which the attestation must no longer be trusted, relative to its creation time.\n" +
+		"--nonce records a value chosen by the caller, e.g. to bind the attestation to a deployment request.\n" +
+		"\n" +
+		"NOTE: the policy paths must be the same, relative to the working directory, as the ones used during evaluation.\n" +
+		"The SOURCE_DATE_EPOCH environment variable, a number of seconds since the Unix epoch,\n" +
+		"sets the creation time of the attestation, so that the same inputs create the same attestation.\n" +
+		"\n"
+	utils.Log(msg, cli, cli)
+	os.Exit(1)
+}
+
+func Run(cli string, args []string) error {
+	fs := flag.NewFlagSet("attest", flag.ContinueOnError)
+	fs.Usage = func() { usage(cli) }
+	resultPath := fs.String("result", "", "path to the exported evaluation result")
+	outPath := fs.String("out", "", "path to write the attestation to")
+	maxAge := fs.Duration("max-age", 0, "reject results evaluated more than max-age ago")
+	var metadata [][2]string
+	fs.Func("metadata", "property to record, as key=value", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid metadata (%q): must be key=value", s)
+		}
+		metadata = append(metadata, [2]string{key, value})
+		return nil
+	})
+	expiry := fs.Duration("expiry", 0, "duration after the creation time at which the attestation expires")
+	nonce := fs.String("nonce", "", "nonce to record in the attestation")
+	if err := fs.Parse(args); err != nil {
+		usage(cli)
+	}
+	if *resultPath == "" || *outPath == "" || fs.NArg() != 2 || *expiry < 0 {
+		usage(cli)
+	}
+	// Create the local policy.
+	orgPath := fs.Arg(0)
+	projectsPath, err := utils.ReadFiles(fs.Arg(1), orgPath)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	projectsReader := named_files_reader.FromPaths(cwd, projectsPath)
+	organizationReader, err := os.Open(orgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read org path: %w", err)
+	}
+	pol, err := deployment.PolicyNew(organizationReader, projectsReader, deployment.SetValidator(&validate.PolicyValidator{}),
+		deployment.SetCustomRulesEngine(rego.EngineNew(cwd)))
+	if err != nil {
+		return fmt.Errorf("failed to create policy: %w", err)
+	}
+
+	// Import the result.
+	resultReader, err := os.Open(*resultPath)
+	if err != nil {
+		return fmt.Errorf("failed to read result path: %w", err)
+	}
+	defer resultReader.Close()
+	var opts []deployment.ImportOption
+	if *maxAge != 0 {
+		opts = append(opts, deployment.WithMaxAge(*maxAge))
+	}
+	result, err := pol.ImportResult(resultReader, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to import result: %w", err)
+	}
+
+	// Create the attestation.
+	creationOpts := utils.PolicyCreationOptions(filepath.Dir(orgPath))
+	creationTime, exists, err := utils.SourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		creationTime = time.Now()
+	}
+	// NOTE: the creation time is set explicitly so that
+	// the expiry is relative to the recorded time.
+	creationOpts = append(creationOpts, deployment.WithCreationTime(creationTime))
+	for _, m := range metadata {
+		creationOpts = append(creationOpts, deployment.WithMetadata(m[0], m[1]))
+	}
+	if *expiry != 0 {
+		creationOpts = append(creationOpts, deployment.WithExpiry(creationTime.Add(*expiry)))
+	}
+	if *nonce != "" {
+		creationOpts = append(creationOpts, deployment.WithNonce(*nonce))
+	}
+	att, err := result.AttestationNew(creationOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create attestation: %w", err)
+	}
+	attBytes, err := att.ToBytes()
+	if err != nil {
+		return fmt.Errorf("failed to get attestation bytes: %w", err)
+	}
+	return utils.WriteFileAtomic(*outPath, attBytes)
+}
@@ -3,7 +3,11 @@ package deployment
 import (
 	"os"
 
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/attest"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/diff"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/evaluate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/simulate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/stats"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
 )
@@ -15,6 +19,10 @@ func usage(cli string) {
 		"Available options:\n" +
 		"validate \t\tValidate the policy files\n" +
 		"evaluate \t\tEvaluate the policy\n" +
+		"stats \t\t\tPrint statistics about the policy\n" +
+		"attest \t\t\tCreate an attestation from an exported evaluation result\n" +
+		"simulate \t\tEvaluate requests interactively with a stub verifier\n" +
+		"diff \t\t\tReport the decisions that differ between two policies\n" +
 		"\n"
 	utils.Log(msg, cli)
 	os.Exit(1)
@@ -32,6 +40,14 @@ func Run(cli string, args []string) error {
 		err = validate.Run(cli, args[1:])
 	case "evaluate":
 		err = evaluate.Run(cli, args[1:])
+	case "stats":
+		err = stats.Run(cli, args[1:])
+	case "attest":
+		err = attest.Run(cli, args[1:])
+	case "simulate":
+		err = simulate.Run(cli, args[1:])
+	case "diff":
+		err = diff.Run(cli, args[1:])
 	}
 	return err
 }
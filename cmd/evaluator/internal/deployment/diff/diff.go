@@ -0,0 +1,219 @@
+package diff
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/evaluate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s deployment diff [--format text|json] [--workers n] [--old-org path] [--new-org path] oldPolicyDir newPolicyDir requestsPath\n" +
+		"\n" +
+		"Evaluates the requests against both policies and prints the requests whose decision differs.\n" +
+		"The org policy defaults to org.json, org.yaml or org.yml under the policy directory.\n" +
+		"The requests file contains a JSON array of requests, e.g. the decisions printed by\n" +
+		"'deployment evaluate --format json':\n" +
+		"[{\"package\": \"docker.io/org/server\", \"digests\": {\"sha256\": \"...\"}, \"policy_id\": \"servers/prod.json\",\n" +
+		"  \"scopes\": {\"kubernetes.io/pod/service_account/v1\": \"...\"}}]\n" +
+		"\n" +
+		"Options:\n" +
+		"--format \t\tOutput format, text (default) or json.\n" +
+		"--workers\t\tNumber of requests evaluated concurrently. Defaults to the number of CPUs.\n" +
+		"\n" +
+		"Example:\n" +
+		"%s deployment diff ./main/policy ./branch/policy ./requests.json\n" +
+		"\n"
+	utils.Log(msg, cli, cli)
+	os.Exit(1)
+}
+
+func Run(cli string, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Usage = func() { usage(cli) }
+	format := fs.String("format", utils.FormatText, "output format: text or json")
+	workers := fs.Int("workers", 0, "number of requests evaluated concurrently")
+	oldOrg := fs.String("old-org", "", "path to the org policy of the old policy")
+	newOrg := fs.String("new-org", "", "path to the org policy of the new policy")
+	if err := fs.Parse(args); err != nil {
+		usage(cli)
+	}
+	if fs.NArg() != 3 {
+		usage(cli)
+	}
+	if err := utils.ValidateFormat(*format); err != nil {
+		return err
+	}
+	oldPolicy, err := validate.LoadPolicyDir(fs.Arg(0), *oldOrg)
+	if err != nil {
+		return fmt.Errorf("old policy: %w", err)
+	}
+	newPolicy, err := validate.LoadPolicyDir(fs.Arg(1), *newOrg)
+	if err != nil {
+		return fmt.Errorf("new policy: %w", err)
+	}
+	requests, err := readRequests(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	diffs, err := deployment.DiffPolicies(oldPolicy, newPolicy, requests, deployment.DiffOptions{
+		Verifier: newCachedVerifier(evaluate.NewPublishVerifier()),
+		Workers:  *workers,
+	})
+	if err != nil {
+		return err
+	}
+	return write(os.Stdout, *format, diffs)
+}
+
+func readRequests(path string) ([]deployment.EvaluationRequest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read requests: %w", err)
+	}
+	var list []utils.DeploymentRequest
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse requests: %w", err)
+	}
+	requests := make([]deployment.EvaluationRequest, 0, len(list))
+	for _, r := range list {
+		requests = append(requests, r.EvaluationRequest())
+	}
+	return requests, nil
+}
+
+// output is the JSON output of a request whose decision differs.
+// NOTE: The fields are part of the CLI interface, do not rename them.
+type output struct {
+	Index    int               `json:"index"`
+	Package  string            `json:"package"`
+	Digests  intoto.DigestSet  `json:"digests"`
+	PolicyID string            `json:"policy_id"`
+	Scopes   map[string]string `json:"scopes,omitempty"`
+	Old      decision          `json:"old"`
+	New      decision          `json:"new"`
+}
+
+type decision struct {
+	// Decision is either "allow" or "deny".
+	Decision string            `json:"decision"`
+	Scopes   map[string]string `json:"scopes,omitempty"`
+	// Category is the category of the error, see errs.Category.
+	Category string `json:"category,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+func decisionNew(d deployment.Decision) decision {
+	if !d.Allow {
+		return decision{
+			Decision: "deny",
+			Category: d.Category(),
+			Message:  d.Err.Error(),
+		}
+	}
+	return decision{
+		Decision: "allow",
+		Scopes:   d.Scopes,
+	}
+}
+
+func (d decision) String() string {
+	if d.Decision == "deny" {
+		return fmt.Sprintf("deny(%s)", d.Category)
+	}
+	return d.Decision
+}
+
+// write writes the differences in the order of the requests.
+func write(w io.Writer, format string, diffs []deployment.DecisionDiff) error {
+	outputs := make([]output, 0, len(diffs))
+	for _, d := range diffs {
+		outputs = append(outputs, output{
+			Index:    d.Index,
+			Package:  d.Request.PackageName,
+			Digests:  d.Request.Digests,
+			PolicyID: d.Request.PolicyID,
+			Scopes:   d.Request.Scopes,
+			Old:      decisionNew(d.Old),
+			New:      decisionNew(d.New),
+		})
+	}
+	if format == utils.FormatJSON {
+		content, err := json.MarshalIndent(outputs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(content))
+		return err
+	}
+	for _, o := range outputs {
+		if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s -> %s\n", o.Index, o.Package, o.PolicyID, o.Old, o.New); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d decision(s) changed\n", len(outputs))
+	return err
+}
+
+// cachedVerifier verifies each publish attestation once, so that
+// both policies get the same answer for the same verification.
+// It is safe for concurrent use if its verifier is.
+type cachedVerifier struct {
+	verifier deployment.AttestationVerifier
+	mu       sync.Mutex
+	results  map[string]cachedResult
+}
+
+type cachedResult struct {
+	env *string
+	err error
+}
+
+func newCachedVerifier(verifier deployment.AttestationVerifier) *cachedVerifier {
+	return &cachedVerifier{
+		verifier: verifier,
+		results:  make(map[string]cachedResult),
+	}
+}
+
+func (v *cachedVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	key := cacheKey(digests, packageName, environment, opts)
+	v.mu.Lock()
+	result, exists := v.results[key]
+	v.mu.Unlock()
+	if exists {
+		return result.env, result.err
+	}
+	env, err := v.verifier.VerifyPublishAttestation(digests, packageName, environment, opts)
+	v.mu.Lock()
+	v.results[key] = cachedResult{env: env, err: err}
+	v.mu.Unlock()
+	return env, err
+}
+
+func cacheKey(digests intoto.DigestSet, packageName string, environment []string,
+	opts deployment.AttestationVerifierPublishOptions) string {
+	keys := make([]string, 0, len(digests))
+	for k, v := range digests {
+		keys = append(keys, k+":"+v)
+	}
+	slices.Sort(keys)
+	identity := ""
+	if opts.Identity != nil {
+		identity = opts.Identity.Issuer + " " + opts.Identity.SubjectRegex.String()
+	}
+	// NOTE: %q escapes the separators.
+	return fmt.Sprintf("%q %q %q %q %q %q %d %q", keys, packageName, environment, opts.PublishrID,
+		opts.PublishrIDRegex, identity, opts.MinBuildLevel, opts.MinAuthorVersion)
+}
@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func newPolicyDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return dir
+}
+
+// levelVerifier accepts every publish attestation
+// at its level, without environment.
+type levelVerifier struct {
+	level int
+	calls atomic.Int32
+}
+
+func (v *levelVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	v.calls.Add(1)
+	if v.level < opts.MinBuildLevel {
+		return nil, os.ErrNotExist
+	}
+	return nil, nil
+}
+
+func Test_Diff(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := func(principal string, level string) string {
+		return `{"format": 1, "principal": {"uri": "` + principal + `"}, "build": {"require_slsa_level": ` + level + `},
+			"packages": [{"name": "docker.io/org/server"}]}`
+	}
+	oldPolicy, err := validate.LoadPolicyDir(newPolicyDir(t, map[string]string{
+		"org.json":    org,
+		"level2.json": project("principal_uri1", "2"),
+		"level3.json": project("principal_uri2", "3"),
+		"other.json":  project("principal_uri3", "2"),
+	}), "")
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	newPolicy, err := validate.LoadPolicyDir(newPolicyDir(t, map[string]string{
+		"org.json":    org,
+		"level2.json": project("principal_uri1", "3"),
+		"level3.json": project("principal_uri2", "2"),
+		"other.json":  project("other_principal_uri3", "2"),
+	}), "")
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	digests := intoto.DigestSet{"sha256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}
+	requests := []utils.DeploymentRequest{
+		{Package: "docker.io/org/server", Digests: digests, PolicyID: "level2.json"},
+		{Package: "docker.io/org/server", Digests: digests, PolicyID: "level3.json"},
+		{Package: "docker.io/org/server", Digests: digests, PolicyID: "other.json",
+			Scopes: map[string]string{deployment.ScopeKeyKubernetesServiceAccount: "principal_uri3"}},
+		{Package: "docker.io/org/server", Digests: digests, PolicyID: "unknown.json"},
+	}
+	content, err := json.Marshal(requests)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	requestsPath := filepath.Join(t.TempDir(), "requests.json")
+	if err := os.WriteFile(requestsPath, content, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	workload, err := readRequests(requestsPath)
+	if err != nil {
+		t.Fatalf("failed to read requests: %v", err)
+	}
+	verifier := &levelVerifier{level: 2}
+	diffs, err := deployment.DiffPolicies(oldPolicy, newPolicy, workload, deployment.DiffOptions{
+		Verifier: newCachedVerifier(verifier),
+		// NOTE: concurrent requests may verify the same attestation.
+		Workers: 1,
+	})
+	if err != nil {
+		t.Fatalf("failed to diff policies: %v", err)
+	}
+	var out bytes.Buffer
+	if err := write(&out, utils.FormatText, diffs); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	expected := []string{
+		"0\tdocker.io/org/server\tlevel2.json\tallow -> deny(verification)",
+		"1\tdocker.io/org/server\tlevel3.json\tdeny(verification) -> allow",
+		"2\tdocker.io/org/server\tother.json\tallow -> deny(mismatch)",
+		"3 decision(s) changed",
+	}
+	if diff := cmp.Diff(expected, strings.Split(strings.TrimSpace(out.String()), "\n")); diff != "" {
+		t.Fatalf("unexpected output (-want +got): \n%s", diff)
+	}
+	// Each verification is shared by both policies.
+	if diff := cmp.Diff(int32(2), verifier.calls.Load()); diff != "" {
+		t.Fatalf("unexpected verifier calls (-want +got): \n%s", diff)
+	}
+
+	out.Reset()
+	if err := write(&out, utils.FormatJSON, diffs); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	var outputs []output
+	if err := json.Unmarshal(out.Bytes(), &outputs); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if diff := cmp.Diff([]string{"allow", "deny", "allow"}, []string{outputs[0].Old.Decision, outputs[1].Old.Decision,
+		outputs[2].Old.Decision}); diff != "" {
+		t.Fatalf("unexpected decisions (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("verification", outputs[0].New.Category); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+}
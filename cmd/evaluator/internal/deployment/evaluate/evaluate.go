@@ -1,61 +1,209 @@
 package evaluate
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/crypto"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/image"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/rego"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/source"
 	"github.com/slsa-framework/slsa-policy/pkg/deployment"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/named_files_reader"
 )
 
+// Media types of the attestation pushed as a referrer of the image.
+const (
+	attestationArtifactType = "application/vnd.slsa.deployment.v0.1+json"
+	attestationMediaType    = "application/vnd.in-toto+json"
+	envelopeMediaType       = "application/vnd.dsse.envelope.v1+json"
+)
+
 func usage(cli string) {
 	msg := "" +
-		"Usage: %s deployment evaluate orgPath projectsPath packageURI policyID\n" +
+		"Usage: %s deployment evaluate [--format text|json [--trace]] [--sign keyless|kms [--kms-key reference]] [--output-ref repository | --no-push --out path] [--revocation-list path|url] orgPath projectsPath packageURI policyID\n" +
+		"       %s deployment evaluate [--format text|json [--trace]] [--sign keyless|kms [--kms-key reference]] [--output-ref repository | --no-push --out path] [--revocation-list path|url] --image reference [--platform os/arch] orgPath projectsPath policyID\n" +
+		"       %s deployment evaluate [--revocation-list path|url] [--workers n] --batch-file path orgPath projectsPath\n" +
+		"\n" +
+		"Options:\n" +
+		"--format \t\tOutput format, text (default) or json. The json format prints\n" +
+		"         \t\ta single decision object to stdout.\n" +
+		"--trace  \t\tInclude in the json decision the roots considered by the evaluation,\n" +
+		"         \t\tin order, and why each was rejected. Requires --format json.\n" +
+		"--output-ref\t\tRepository to push the attestation to, as a referrer of the image.\n" +
+		"         \t\tDefaults to the repository of the image.\n" +
+		"--no-push\t\tWrite the attestation to the --out path instead of pushing it.\n" +
+		"--sign   \t\tSign the attestation, keyless with Fulcio and Rekor, or with a KMS key.\n" +
+		"         \t\tThe attestation is a DSSE envelope if set, a plain statement otherwise.\n" +
+		"         \t\tWith keyless, the attestation is also attached to the image, unless --no-push is set.\n" +
+		"--kms-key\t\tReference of the KMS key, e.g. gcpkms://projects/[...]/cryptoKeys/key.\n" +
+		"         \t\tRequires --sign kms.\n" +
+		"--image  \t\tImage reference with a tag or a digest. The digest is resolved\n" +
+		"         \t\tfrom the registry, using the docker credentials.\n" +
+		"--platform\t\tPlatform of the image to select from a multi-platform index,\n" +
+		"         \t\te.g. linux/amd64. Requires --image.\n" +
+		"--revocation-list\tPath or https URL of a revocation list. Revoked image digests\n" +
+		"         \t\tare denied before any publish attestation is verified.\n" +
+		"--batch-file\t\tEvaluate the requests of the file, one JSON object per line, e.g.\n" +
+		"         \t\t{\"package\": \"docker.io/org/server\", \"digests\": {\"sha256\": \"...\"}, \"policy_id\": \"servers-prod.json\"}.\n" +
+		"         \t\tPrints one JSON decision per line, in the order of the requests, without\n" +
+		"         \t\tcreating attestations. Exits with an error if any request is denied.\n" +
+		"--workers\t\tNumber of requests of the batch file evaluated concurrently. Defaults to 1.\n" +
 		"\n" +
 		"Example:\n" +
 		"%s deployment evaluate ./path/to/policy/org ./path/to/policy/projects slsa-framework/echo-server@sha256:xxxx servers-prod.json\n" +
+		"%s deployment evaluate --image gcr.io/proj/echo-server:v1.2.3 --platform linux/amd64 ./path/to/policy/org ./path/to/policy/projects servers-prod.json\n" +
+		"%s deployment evaluate org.json https://example.com/policies.tar.gz#sha256=xxxx slsa-framework/echo-server@sha256:xxxx servers-prod.json\n" +
+		"\n" +
+		"The projectsPath is a directory, or a remote policy source pinned by digest:\n" +
+		"an OCI artifact, e.g. oci://ghcr.io/org/policies@sha256:xxxx, or a tarball,\n" +
+		"e.g. https://example.com/policies.tar.gz#sha256=xxxx. For a remote source, orgPath\n" +
+		"is the path of the org policy in the source, empty for org.json, org.yaml or org.yml.\n" +
+		"The command exits with distinct codes if the source cannot be fetched or\n" +
+		"does not match its digest.\n" +
+		"The attestation records the URI and digest of a remote source.\n" +
+		"The SOURCE_DATE_EPOCH environment variable, a number of seconds since the Unix epoch,\n" +
+		"sets the creation time of the attestation, so that the same inputs create the same attestation.\n" +
+		"\n" +
+		"NOTE: the command exits with a distinct code if the attestation is created but cannot be stored.\n" +
 		"\n"
-	fmt.Fprintf(os.Stderr, msg, cli, cli)
+	fmt.Fprintf(os.Stderr, msg, cli, cli, cli, cli, cli, cli)
 	os.Exit(1)
 }
 
 func Run(cli string, args []string) error {
-	if len(args) != 4 {
+	fs := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	fs.Usage = func() { usage(cli) }
+	format := fs.String("format", utils.FormatText, "output format: text or json")
+	imageRef := fs.String("image", "", "image reference with a tag or a digest")
+	platform := fs.String("platform", "", "platform to select from a multi-platform index")
+	outputRef := fs.String("output-ref", "", "repository to push the attestation to")
+	noPush := fs.Bool("no-push", false, "write the attestation to a file instead of pushing it")
+	outPath := fs.String("out", "", "path to write the attestation to, with --no-push")
+	sign := fs.String("sign", "", "signing mode: keyless or kms")
+	kmsKey := fs.String("kms-key", "", "reference of the KMS key, with --sign kms")
+	batchFile := fs.String("batch-file", "", "path to a file of requests, one JSON object per line")
+	workers := fs.Int("workers", 1, "number of requests of the batch file evaluated concurrently")
+	revocationList := fs.String("revocation-list", "", "path or https URL of a revocation list")
+	withTrace := fs.Bool("trace", false, "include the evaluation trace in the json decision")
+	if err := fs.Parse(args); err != nil {
 		usage(cli)
 	}
-	// Extract inputs.
-	orgPath := args[0]
-	projectsPath, err := utils.ReadFiles(args[1], orgPath)
-	if err != nil {
+	var policyOpts []deployment.PolicyOption
+	if *revocationList != "" {
+		reader, err := utils.OpenRevocationList(*revocationList)
+		if err != nil {
+			return err
+		}
+		policyOpts = append(policyOpts, deployment.SetRevocationList(reader))
+	}
+	if *batchFile != "" {
+		if *imageRef != "" || *sign != "" || *noPush || *outputRef != "" {
+			return fmt.Errorf("--batch-file does not create attestations. Remove --image, --sign, --output-ref and --no-push")
+		}
+		if *withTrace {
+			return fmt.Errorf("--trace is not supported with --batch-file")
+		}
+		if fs.NArg() != 2 {
+			usage(cli)
+		}
+		return evaluateBatch(fs.Arg(0), fs.Arg(1), *batchFile, newPublishVerifier(), *workers, policyOpts, os.Stdout)
+	}
+	if err := utils.ValidateFormat(*format); err != nil {
+		return err
+	}
+	if err := utils.ValidateSigning(*sign, *kmsKey); err != nil {
 		return err
 	}
+	if *withTrace && *format != utils.FormatJSON {
+		return fmt.Errorf("--trace requires --format json")
+	}
+	if *platform != "" && *imageRef == "" {
+		return fmt.Errorf("--platform requires --image")
+	}
+	if *noPush != (*outPath != "") {
+		return fmt.Errorf("--no-push and --out must be set together")
+	}
+	if *noPush && *outputRef != "" {
+		return fmt.Errorf("--no-push and --output-ref are mutually exclusive")
+	}
+	args = fs.Args()
+	if *imageRef != "" {
+		if len(args) != 3 {
+			usage(cli)
+		}
+		// NOTE: the resolved reference replaces the packageURI argument.
+		args = []string{args[0], args[1], *imageRef, args[2]}
+	} else if len(args) != 4 {
+		usage(cli)
+	}
+	decision := utils.Decision{
+		Decision: "deny",
+		Package:  args[2],
+		PolicyID: args[3],
+	}
+	signer, err := crypto.SignerNew(context.Background(), *sign, *kmsKey)
+	if err == nil && *imageRef != "" {
+		args[2], err = image.Resolve(*imageRef, *platform)
+	}
+	var evalOpts []deployment.EvaluationOption
+	if *withTrace {
+		evalOpts = append(evalOpts, deployment.WithTrace())
+	}
+	if err == nil {
+		err = evaluate(args, *format, signer, storage{outputRef: *outputRef, outPath: *outPath}, policyOpts, evalOpts, &decision)
+	}
+	if *format == utils.FormatJSON {
+		if err != nil {
+			decision.SetError(err)
+		}
+		if err := decision.Write(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// storage defines where the attestation is stored.
+type storage struct {
+	// outputRef is the repository to push the attestation to.
+	outputRef string
+	// outPath, if set, is the path to write the attestation
+	// to instead of pushing it.
+	outPath string
+}
+
+// evaluate evaluates the policy and creates and stores a deployment
+// attestation, signed by signer if set and also attached to the image
+// if pushed and signed keyless. The decision is filled as the
+// evaluation progresses. Storage failures wrap utils.ErrorStorage.
+func evaluate(args []string, format string, signer intoto.AttestationSigner, store storage,
+	policyOpts []deployment.PolicyOption, evalOpts []deployment.EvaluationOption, decision *utils.Decision) error {
+	// Extract inputs.
+	orgPath := args[0]
 	imageURI, digest, err := utils.ParseImageReference(args[2])
 	if err != nil {
 		return err
 	}
+	decision.Package = imageURI
 	policyID := args[3]
 	digestsArr := strings.Split(digest, ":")
 	if len(digestsArr) != 2 {
 		return fmt.Errorf("invalid digest (%q)", digest)
 	}
-	wd, err := os.Getwd()
-	if err != nil {
-		return err
-	}
 	// Create a policy.
-	projectsReader := named_files_reader.FromPaths(wd, projectsPath)
-	organizationReader, err := os.Open(orgPath)
-	if err != nil {
-		return fmt.Errorf("failed to read org path: %w", err)
-	}
-	pol, err := deployment.PolicyNew(organizationReader, projectsReader, deployment.SetValidator(&validate.PolicyValidator{}))
+	pol, src, err := loadPolicy(orgPath, args[1], policyOpts...)
 	if err != nil {
-		return fmt.Errorf("failed to create policy: %w", err)
+		return err
 	}
 
 	// Evaluate the policy.
@@ -65,24 +213,133 @@ func Run(cli string, args []string) error {
 	digests := intoto.DigestSet{
 		digestsArr[0]: digestsArr[1],
 	}
+	decision.Digests = digests
 	// NOTE: imageURI must be the same as set in the policy's package name.
-	result := pol.Evaluate(digests, imageURI, policyID, opts)
+	result := pol.Evaluate(digests, imageURI, policyID, opts, evalOpts...)
+	decision.Trace = result.Trace()
 	if result.Error() != nil {
 		return result.Error()
 	}
+	decision.Decision = "allow"
+	decision.PrincipalURI = result.PrincipalURI()
+	decision.Scopes = result.Scopes()
 
-	// Create a publish attestation and sign it.
-	// TODO(#3): do not attach the attestation, so that caller can do it however they want.
-	// TODO(#2): add policy.
-	att, err := result.AttestationNew()
+	// Create a deployment attestation and store it.
+	// NOTE: a remote policy source is recorded with its pinned digest,
+	// a local policy with the commit of its git repository.
+	var creationOpts []deployment.AttestationCreationOption
+	if src != nil {
+		creationOpts = src.CreationOptions()
+	} else {
+		creationOpts = utils.PolicyCreationOptions(filepath.Dir(orgPath))
+	}
+	creationTime, exists, err := utils.SourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	if exists {
+		creationOpts = append(creationOpts, deployment.WithCreationTime(creationTime))
+	}
+	att, err := result.AttestationNew(creationOpts...)
 	if err != nil {
 		return fmt.Errorf("failed to create attestation: %w", err)
 	}
-	attBytes, err := att.ToBytes()
+	immutableImage := utils.ImmutableImage(imageURI, digests)
+	var attBytes []byte
+	if crypto.IsKeyless(signer) && store.outPath == "" {
+		// The keyless signature is also attached to the image,
+		// so the attached envelope is the one pushed.
+		envelope, entryID, err := crypto.Sign(att, immutableImage)
+		if err != nil {
+			return fmt.Errorf("%w: %w", utils.ErrorStorage, err)
+		}
+		attBytes = envelope
+		decision.LogEntryID = entryID
+	} else {
+		attBytes, err = utils.AttestationContent(context.Background(), att, signer)
+		if err != nil {
+			return err
+		}
+	}
+	mediaType := attestationMediaType
+	if signer != nil {
+		mediaType = envelopeMediaType
+	}
+	// NOTE: the JSON format prints the decision only.
+	if format == utils.FormatText {
+		fmt.Println(string(attBytes))
+	}
+	if store.outPath != "" {
+		if err := os.WriteFile(store.outPath, attBytes, 0o600); err != nil {
+			return fmt.Errorf("%w: %w", utils.ErrorStorage, err)
+		}
+		decision.Attestation = store.outPath
+		return nil
+	}
+	ref, err := image.PushReferrer(immutableImage, store.outputRef, image.Artifact{
+		ArtifactType: attestationArtifactType,
+		MediaType:    mediaType,
+		Content:      attBytes,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", utils.ErrorStorage, err)
+	}
+	decision.Attestation = ref
+	return nil
+}
+
+// loadPolicy creates a policy from the org policy and the project policies
+// at projects, either a directory or a remote policy source. For a remote
+// source, orgPath is the path of the org policy inside the source, and the
+// fetched source is returned.
+func loadPolicy(orgPath, projects string, opts ...deployment.PolicyOption) (*deployment.Policy, *source.Policy, error) {
+	if !source.IsRemote(projects) {
+		projectsPath, err := utils.ReadFiles(projects, orgPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		pol, err := newPolicy(orgPath, projectsPath, opts...)
+		return pol, nil, err
+	}
+	src, err := source.Fetch(projects)
+	if err != nil {
+		return nil, nil, err
+	}
+	organizationReader, projectsReader, err := src.Open(orgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pol, err := createPolicy(organizationReader, projectsReader, rego.EngineWithReadFile(src.ReadFile), opts...)
 	if err != nil {
-		return fmt.Errorf("failed to get attestation bytes: %v", err)
+		return nil, nil, err
 	}
-	fmt.Println(string(attBytes))
+	return pol, src, nil
+}
 
-	return crypto.Sign(att, utils.ImmutableImage(imageURI, digests))
+// newPolicy creates a policy from the org policy and the project policy files.
+// The policy IDs are the paths of the project files, relative to the working directory.
+func newPolicy(orgPath string, projectsPath []string, opts ...deployment.PolicyOption) (*deployment.Policy, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	projectsReader := named_files_reader.FromPaths(wd, projectsPath)
+	organizationReader, err := os.Open(orgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read org path: %w", err)
+	}
+	return createPolicy(organizationReader, projectsReader, rego.EngineNew(wd), opts...)
+}
+
+func createPolicy(organizationReader io.ReadCloser, projectsReader iterator.NamedReadCloserIterator,
+	engine deployment.CustomRulesEngine, opts ...deployment.PolicyOption) (*deployment.Policy, error) {
+	opts = append([]deployment.PolicyOption{
+		deployment.SetValidator(&validate.PolicyValidator{}),
+		deployment.SetCustomRulesEngine(engine),
+	}, opts...)
+	pol, err := deployment.PolicyNew(organizationReader, projectsReader, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy: %w", err)
+	}
+	return pol, nil
 }
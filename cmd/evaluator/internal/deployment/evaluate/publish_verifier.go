@@ -2,8 +2,10 @@ package evaluate
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"path"
 	"strings"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
@@ -15,21 +17,47 @@ import (
 
 type publishVerifier struct {
 	deployment.AttestationVerifierPublishOptions
+	// quiet disables printing the verified attestations.
+	quiet bool
 }
 
 func newPublishVerifier() *publishVerifier {
 	return &publishVerifier{}
 }
 
+// NewPublishVerifier returns the verifier of publish attestations used
+// by 'evaluate'. It does not print the attestations and is safe for
+// concurrent use.
+func NewPublishVerifier() deployment.AttestationVerifier {
+	return &sharedPublishVerifier{}
+}
+
+// sharedPublishVerifier creates a verifier per call, since
+// publishVerifier records the options of the call.
+type sharedPublishVerifier struct{}
+
+func (v *sharedPublishVerifier) VerifyPublishAttestation(digests intoto.DigestSet, imageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	verifier := newPublishVerifier()
+	verifier.quiet = true
+	return verifier.VerifyPublishAttestation(digests, imageName, environment, opts)
+}
+
 func (v *publishVerifier) validate() error {
 	// Validate the identities.
 	if err := crypto.ValidateIdentity(v.AttestationVerifierPublishOptions.PublishrID,
 		v.AttestationVerifierPublishOptions.PublishrIDRegex); err != nil {
 		return err
 	}
+	// Validate the issuer.
+	if identity := v.AttestationVerifierPublishOptions.Identity; identity != nil {
+		if err := crypto.ValidateIssuer(identity.Issuer); err != nil {
+			return err
+		}
+	}
 	// Validate the build level.
-	if v.AttestationVerifierPublishOptions.BuildLevel <= 0 || v.AttestationVerifierPublishOptions.BuildLevel > 4 {
-		return fmt.Errorf("build level (%d) must be between 1 and 4", v.AttestationVerifierPublishOptions.BuildLevel)
+	if v.AttestationVerifierPublishOptions.MinBuildLevel <= 0 || v.AttestationVerifierPublishOptions.MinBuildLevel > 4 {
+		return fmt.Errorf("build level (%d) must be between 1 and 4", v.AttestationVerifierPublishOptions.MinBuildLevel)
 	}
 	return nil
 }
@@ -74,15 +102,29 @@ func (v *publishVerifier) verifyAttestationContent(attBytes []byte, imageName st
 		return nil, fmt.Errorf("failed to create verifier for image (%q) and env (%q): %w", imageName, environment, err)
 	}
 
-	// Build level verification.
+	// Build level and author version verification.
 	levelOpts := []publish.VerificationOption{
-		publish.IsSlsaBuildLevelOrAbove(v.AttestationVerifierPublishOptions.BuildLevel),
+		publish.IsSlsaBuildLevelOrAbove(v.AttestationVerifierPublishOptions.MinBuildLevel),
+	}
+	if version := v.AttestationVerifierPublishOptions.MinAuthorVersion; version != "" {
+		levelOpts = append(levelOpts, publish.IsAuthorVersionAtLeast(version))
 	}
 	// If environment is present, we must verify it.
 	var errList []error
 	if len(environment) > 0 {
 		for i := range environment {
 			penv := &environment[i]
+			// A wildcard matches the environment recorded in the attestation.
+			if strings.Contains(*penv, "*") {
+				env, err := verifyEnvironmentPattern(verification, digests, imageName, *penv, levelOpts)
+				if err != nil {
+					errList = append(errList, err)
+					continue
+				}
+				utils.Log("Image (%q) verified with publishr ID (%q) and publishr ID regex (%q) and env (%q) matching (%q)\n",
+					imageName, v.AttestationVerifierPublishOptions.PublishrID, v.AttestationVerifierPublishOptions.PublishrIDRegex, *env, *penv)
+				return env, nil
+			}
 			opts := append(levelOpts, publish.IsPackageEnvironment(*penv))
 			// WARNING: We must ensure that the imageName follows the format defined in the policy.
 			// This is the case, since our policy expect an image as registry/image.
@@ -109,6 +151,23 @@ func (v *publishVerifier) verifyAttestationContent(attBytes []byte, imageName st
 	return nil, nil
 }
 
+func verifyEnvironmentPattern(verification *publish.Verification, digests intoto.DigestSet, imageName, pattern string,
+	opts []publish.VerificationOption) (*string, error) {
+	att, err := verification.VerifyAttestation(context.Background(), digests, imageName, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify image (%q) and env (%q): %w", imageName, pattern, err)
+	}
+	env := att.Package.Environment
+	matched, err := path.Match(pattern, env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env (%q): %w", pattern, err)
+	}
+	if env == "" || !matched {
+		return nil, fmt.Errorf("failed to verify image (%q): env (%q) does not match (%q)", imageName, env, pattern)
+	}
+	return &env, nil
+}
+
 func (v *publishVerifier) VerifyPublishAttestation(digests intoto.DigestSet, imageName string, environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
 	if err := v.setOptions(opts); err != nil {
 		return nil, err
@@ -120,7 +179,9 @@ func (v *publishVerifier) VerifyPublishAttestation(digests intoto.DigestSet, ima
 		return nil, err
 	}
 
-	fmt.Println(string(attBytes))
+	if !v.quiet {
+		fmt.Println(string(attBytes))
+	}
 
 	// Verify the attestation content.
 	return v.verifyAttestationContent(attBytes, imageName, digests, environment)
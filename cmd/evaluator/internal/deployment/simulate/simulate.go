@@ -0,0 +1,355 @@
+package simulate
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s deployment simulate --policy-dir dir [--org orgPath]\n" +
+		"\n" +
+		"The org policy defaults to org.json, org.yaml or org.yml under the policy directory.\n" +
+		"Commands are read from the standard input, see 'help'.\n" +
+		"\n" +
+		"Example:\n" +
+		"%s deployment simulate --policy-dir ./path/to/policy\n" +
+		"\n"
+	utils.Log(msg, cli, cli)
+	os.Exit(1)
+}
+
+const commandsUsage = "" +
+	"Available commands:\n" +
+	"eval <package> <alg:digest> <env|-> [publisher=<id>] [level=<n>] [policy=<id>]\n" +
+	"\tEvaluate a request. The stub verifier accepts attestations from the publisher\n" +
+	"\t(default: any) at the level (default: 3). The policy defaults to the only\n" +
+	"\tpolicy defining the package. Use '-' for no environment.\n" +
+	"explain\t\tExplain the last evaluation\n" +
+	"packages\tList the packages of the project policies\n" +
+	"roots\t\tList the publish roots of the org policy\n" +
+	"help\t\tPrint this message\n" +
+	"quit\t\tExit\n"
+
+const evalUsage = "eval <package> <alg:digest> <env|-> [publisher=<id>] [level=<n>] [policy=<id>]"
+
+func Run(cli string, args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.Usage = func() { usage(cli) }
+	policyDir := fs.String("policy-dir", "", "directory containing the policy files")
+	orgPath := fs.String("org", "", "path to the org policy")
+	if err := fs.Parse(args); err != nil {
+		usage(cli)
+	}
+	if *policyDir == "" || fs.NArg() != 0 {
+		usage(cli)
+	}
+	pol, err := validate.LoadPolicyDir(*policyDir, *orgPath)
+	if err != nil {
+		return err
+	}
+	return NewSession(pol, os.Stdin, os.Stdout).Run()
+}
+
+// Session is an interactive simulation session. It reads commands
+// from its input and writes the answers to its output.
+type Session struct {
+	policy *deployment.Policy
+	in     io.Reader
+	out    io.Writer
+	last   *evaluation
+}
+
+// evaluation is the record of an evaluation, used by 'explain'.
+type evaluation struct {
+	request  request
+	verifier *stubVerifier
+	result   deployment.PolicyEvaluationResult
+}
+
+type request struct {
+	packageName string
+	digests     intoto.DigestSet
+	environment string
+	policyID    string
+}
+
+// NewSession creates a new session for the policy.
+func NewSession(policy *deployment.Policy, in io.Reader, out io.Writer) *Session {
+	return &Session{
+		policy: policy,
+		in:     in,
+		out:    out,
+	}
+}
+
+// Run reads and executes commands until 'quit' or the end of the input.
+// Malformed commands print a usage hint and do not end the session.
+func (s *Session) Run() error {
+	scanner := bufio.NewScanner(s.in)
+	s.printf("> ")
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			if fields[0] == "quit" || fields[0] == "exit" {
+				return nil
+			}
+			if err := s.execute(fields[0], fields[1:]); err != nil {
+				s.printf("error: %v\n", err)
+			}
+		}
+		s.printf("> ")
+	}
+	return scanner.Err()
+}
+
+func (s *Session) printf(format string, a ...any) {
+	fmt.Fprintf(s.out, format, a...)
+}
+
+func (s *Session) execute(command string, args []string) error {
+	switch command {
+	default:
+		return fmt.Errorf("unknown command (%q). Type 'help' for the list of commands", command)
+	case "help":
+		s.printf("%s", commandsUsage)
+	case "eval":
+		return s.eval(args)
+	case "explain":
+		return s.explain(args)
+	case "packages":
+		if len(args) != 0 {
+			return fmt.Errorf("packages takes no argument\nusage: packages")
+		}
+		for _, pkg := range s.policy.Packages() {
+			s.printf("%s\t%s\tenvironments=%s\tlevel=%d\tprincipal=%s\n", pkg.PolicyID, pkg.Name,
+				list(pkg.Environments), pkg.RequiredLevel, pkg.PrincipalURI)
+		}
+	case "roots":
+		if len(args) != 0 {
+			return fmt.Errorf("roots takes no argument\nusage: roots")
+		}
+		for _, root := range s.policy.Roots() {
+			s.printf("%s\tmax_level=%d\n", root.ID, root.MaxSlsaLevel)
+		}
+	}
+	return nil
+}
+
+func (s *Session) eval(args []string) error {
+	req, verifier, err := s.parseEval(args)
+	if err != nil {
+		return fmt.Errorf("%w\nusage: %s", err, evalUsage)
+	}
+	result := s.policy.Evaluate(req.digests, req.packageName, req.policyID, deployment.AttestationVerificationOption{
+		Verifier: verifier,
+	})
+	s.last = &evaluation{
+		request:  req,
+		verifier: verifier,
+		result:   result,
+	}
+	if result.Error() != nil {
+		s.printf("DENY %s (policy %q): %v\n", req.packageName, req.policyID, result.Error())
+		return nil
+	}
+	s.printf("ALLOW %s (policy %q): authorized by publish root %q\n", req.packageName, req.policyID, result.PublishRoot())
+	return nil
+}
+
+func (s *Session) parseEval(args []string) (request, *stubVerifier, error) {
+	var req request
+	if len(args) < 3 {
+		return req, nil, fmt.Errorf("eval takes at least 3 arguments, got %d", len(args))
+	}
+	req.packageName = args[0]
+	alg, digest, ok := strings.Cut(args[1], ":")
+	if !ok || alg == "" || digest == "" {
+		return req, nil, fmt.Errorf("invalid digest (%q). Must be of the form alg:digest", args[1])
+	}
+	req.digests = intoto.DigestSet{alg: digest}
+	if args[2] != "-" {
+		req.environment = args[2]
+	}
+	verifier := &stubVerifier{
+		environment: req.environment,
+		level:       3,
+	}
+	for _, arg := range args[3:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return req, nil, fmt.Errorf("invalid argument (%q). Must be of the form key=value", arg)
+		}
+		switch key {
+		default:
+			return req, nil, fmt.Errorf("unknown argument (%q)", key)
+		case "publisher":
+			verifier.publisher = value
+		case "level":
+			level, err := strconv.Atoi(value)
+			if err != nil || level < 0 || level > 4 {
+				return req, nil, fmt.Errorf("invalid level (%q). Must be between 0 and 4", value)
+			}
+			verifier.level = level
+		case "policy":
+			req.policyID = value
+		}
+	}
+	if req.policyID == "" {
+		policyID, err := s.findPolicy(req.packageName)
+		if err != nil {
+			return req, nil, err
+		}
+		req.policyID = policyID
+	}
+	return req, verifier, nil
+}
+
+// findPolicy returns the ID of the only policy defining the package.
+func (s *Session) findPolicy(packageName string) (string, error) {
+	var ids []string
+	for _, pkg := range s.policy.Packages() {
+		// NOTE: package names may be patterns, see the policy documentation.
+		matched, _ := path.Match(pkg.Name, packageName)
+		if (pkg.Name == packageName || matched) && !slices.Contains(ids, pkg.PolicyID) {
+			ids = append(ids, pkg.PolicyID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("package (%q) is not defined by any policy. Type 'packages' for the list of packages", packageName)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("package (%q) is defined by several policies %q. Use policy=<id>", packageName, ids)
+}
+
+func (s *Session) explain(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("explain takes no argument\nusage: explain")
+	}
+	if s.last == nil {
+		return errors.New("no evaluation to explain. Use 'eval' first")
+	}
+	req := s.last.request
+	s.printf("request: package=%s digests=%v environment=%s policy=%s\n",
+		req.packageName, req.digests, orNone(req.environment), req.policyID)
+	s.printf("verifier: publisher=%s level=%d\n", orAny(s.last.verifier.publisher), s.last.verifier.level)
+	if len(s.last.verifier.calls) == 0 {
+		s.printf("no publish attestation was verified\n")
+	}
+	for i, call := range s.last.verifier.calls {
+		s.printf("attempt %d: %s\n", i+1, call)
+	}
+	if err := s.last.result.Error(); err != nil {
+		s.printf("decision: DENY\n")
+		for _, violation := range errs.Violations(err) {
+			s.printf("reason: %v\n", violation)
+		}
+		return nil
+	}
+	s.printf("decision: ALLOW\n")
+	s.printf("reason: publish root %q attested the package\n", s.last.result.PublishRoot())
+	return nil
+}
+
+// stubVerifier answers publish attestation verifications
+// from the arguments of the eval command.
+type stubVerifier struct {
+	// publisher is the publish root that signed the attestation.
+	// If empty, any publish root is accepted.
+	publisher   string
+	level       int
+	environment string
+	calls       []string
+}
+
+func (v *stubVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageURI string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	env, err := v.verify(environment, opts)
+	root := opts.PublishrID
+	if opts.Identity != nil {
+		root = opts.Identity.SubjectRegex.String()
+	}
+	call := fmt.Sprintf("publish root %q, required level %d, environments %s: ", root, opts.MinBuildLevel, list(environment))
+	if err != nil {
+		v.calls = append(v.calls, call+"rejected: "+err.Error())
+		return nil, err
+	}
+	v.calls = append(v.calls, call+"accepted")
+	return env, nil
+}
+
+// matchPublisher returns true if the publish root that signed the
+// attestation matches the root's ID or certificate identity.
+func (v *stubVerifier) matchPublisher(opts deployment.AttestationVerifierPublishOptions) bool {
+	if opts.Identity != nil {
+		return opts.Identity.SubjectRegex.MatchString(v.publisher)
+	}
+	return v.publisher == opts.PublishrID
+}
+
+// matchEnvironment returns true if env, which may be a wildcard,
+// matches the environment of the attestation.
+func (v *stubVerifier) matchEnvironment(env string) bool {
+	if !strings.Contains(env, "*") {
+		return env == v.environment
+	}
+	matched, _ := path.Match(env, v.environment)
+	return matched
+}
+
+func (v *stubVerifier) verify(environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	if v.publisher != "" && !v.matchPublisher(opts) {
+		return nil, fmt.Errorf("attestation is signed by publish root (%q)", v.publisher)
+	}
+	if v.level < opts.MinBuildLevel {
+		return nil, fmt.Errorf("attestation has level %d", v.level)
+	}
+	if len(environment) == 0 {
+		return nil, nil
+	}
+	if v.environment == "" {
+		return nil, errors.New("attestation has no environment")
+	}
+	if !slices.ContainsFunc(environment, v.matchEnvironment) {
+		return nil, fmt.Errorf("attestation has environment (%q)", v.environment)
+	}
+	env := v.environment
+	return &env, nil
+}
+
+func list(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ",")
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+func orAny(value string) string {
+	if value == "" {
+		return "any"
+	}
+	return value
+}
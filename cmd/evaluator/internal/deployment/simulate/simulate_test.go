@@ -0,0 +1,154 @@
+package simulate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+)
+
+// digest is the digest of the evaluated packages.
+const digest = "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+
+func newPolicyDir(t *testing.T) string {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"org.yaml": `format: 1
+roots:
+  publish:
+    - id: publishr_id1
+      build:
+        max_slsa_level: 2
+    - id: publishr_id2
+      build:
+        max_slsa_level: 3
+`,
+		"servers/prod.json": `{"format": 1, "principal": {"uri": "principal_uri1"}, "build": {"require_slsa_level": 3},
+			"packages": [{"name": "docker.io/org/server", "environment": {"any_of": ["dev", "prod"]}}]}`,
+		"tools/tools.yml": `format: 1
+principal:
+  uri: principal_uri2
+build:
+  require_slsa_level: 2
+packages:
+  - name: docker.io/org/tool
+`,
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return dir
+}
+
+func Test_Session(t *testing.T) {
+	t.Parallel()
+	pol, err := validate.LoadPolicyDir(newPolicyDir(t), "")
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	tests := []struct {
+		name     string
+		input    []string
+		expected []string
+	}{
+		{
+			name:  "packages and roots",
+			input: []string{"packages", "roots", "quit", "roots"},
+			expected: []string{
+				"servers/prod.json\tdocker.io/org/server\tenvironments=dev,prod\tlevel=3\tprincipal=principal_uri1",
+				"tools/tools.yml\tdocker.io/org/tool\tenvironments=none\tlevel=2\tprincipal=principal_uri2",
+				"publishr_id1\tmax_level=2",
+				"publishr_id2\tmax_level=3",
+			},
+		},
+		{
+			name:  "allow",
+			input: []string{"eval docker.io/org/server " + digest + " prod"},
+			expected: []string{
+				`ALLOW docker.io/org/server (policy "servers/prod.json"): authorized by publish root "publishr_id2"`,
+			},
+		},
+		{
+			name:  "allow without environment",
+			input: []string{"eval docker.io/org/tool " + digest + " - level=2"},
+			expected: []string{
+				`ALLOW docker.io/org/tool (policy "tools/tools.yml"): authorized by publish root "publishr_id1"`,
+			},
+		},
+		{
+			name: "deny and explain",
+			input: []string{
+				"eval docker.io/org/server " + digest + " staging publisher=publishr_id2 policy=servers/prod.json",
+				"explain",
+			},
+			expected: []string{
+				`DENY docker.io/org/server (policy "servers/prod.json"): [project] verification error: ` +
+					`cannot verify: [attestation has environment ("staging")]`,
+				"request: package=docker.io/org/server digests=map[" + digest + "] environment=staging policy=servers/prod.json",
+				"verifier: publisher=publishr_id2 level=3",
+				`attempt 1: publish root "publishr_id2", required level 3, environments dev,prod: ` +
+					`rejected: attestation has environment ("staging")`,
+				"decision: DENY",
+				`reason: [project] verification error: cannot verify: [attestation has environment ("staging")]`,
+			},
+		},
+		{
+			name: "malformed commands",
+			input: []string{
+				"",
+				"evaluate",
+				"eval docker.io/org/server",
+				"eval docker.io/org/server abc prod",
+				"eval docker.io/org/server " + digest + " prod level=five",
+				"eval docker.io/org/unknown " + digest + " prod",
+				"explain",
+				"roots extra",
+			},
+			expected: []string{
+				`error: unknown command ("evaluate"). Type 'help' for the list of commands`,
+				"error: eval takes at least 3 arguments, got 1",
+				"usage: " + evalUsage,
+				`error: invalid digest ("abc"). Must be of the form alg:digest`,
+				"usage: " + evalUsage,
+				`error: invalid level ("five"). Must be between 0 and 4`,
+				"usage: " + evalUsage,
+				`error: package ("docker.io/org/unknown") is not defined by any policy. Type 'packages' for the list of packages`,
+				"usage: " + evalUsage,
+				"error: no evaluation to explain. Use 'eval' first",
+				"error: roots takes no argument",
+				"usage: roots",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			in := strings.NewReader(strings.Join(tt.input, "\n") + "\n")
+			if err := NewSession(pol, in, &out).Run(); err != nil {
+				t.Fatalf("failed to run session: %v", err)
+			}
+			// Remove the prompts.
+			var lines []string
+			for _, line := range strings.Split(out.String(), "\n") {
+				line = strings.TrimLeft(line, "> ")
+				if line != "" {
+					lines = append(lines, line)
+				}
+			}
+			if diff := cmp.Diff(tt.expected, lines); diff != "" {
+				t.Fatalf("unexpected output (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
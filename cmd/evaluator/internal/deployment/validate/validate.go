@@ -1,10 +1,13 @@
 package validate
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/rego"
 	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/named_files_reader"
 )
 
@@ -44,9 +47,35 @@ func Run(cli string, args []string) error {
 	}
 	projectsReader := named_files_reader.FromPaths(cwd, projectsPath)
 	organizationReader, err := os.Open(orgPath)
-	_, err = deployment.PolicyNew(organizationReader, projectsReader, deployment.SetValidator(&PolicyValidator{}))
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to read org path: %w", err)
+	}
+	_, err = deployment.PolicyNew(organizationReader, projectsReader, deployment.SetValidator(&PolicyValidator{}),
+		deployment.SetCustomRulesEngine(rego.EngineNew(cwd)))
+	if err != nil {
+		// Print every violation, one per line.
+		violations := errs.Violations(err)
+		for _, violation := range violations {
+			utils.Log("%v\n", violation)
+		}
+		return fmt.Errorf("%d policy violation(s)", len(violations))
 	}
 	return nil
 }
+
+// LoadPolicyDir creates a policy from the files under policyDir. The org
+// policy is excluded from the project policies if it is under policyDir.
+// The options are applied in addition to the validator.
+func LoadPolicyDir(policyDir, orgPath string, opts ...deployment.PolicyOption) (*deployment.Policy, error) {
+	organizationReader, projectsReader, err := utils.OpenPolicyDir(policyDir, orgPath)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]deployment.PolicyOption{deployment.SetValidator(&PolicyValidator{}),
+		deployment.SetCustomRulesEngine(rego.EngineNew(policyDir))}, opts...)
+	pol, err := deployment.PolicyNew(organizationReader, projectsReader, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy: %w", err)
+	}
+	return pol, nil
+}
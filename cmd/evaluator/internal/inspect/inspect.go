@@ -0,0 +1,283 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/image"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+var (
+	errorPredicateType = errors.New("unknown predicate type")
+	errorMalformed     = errors.New("malformed attestation")
+)
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s inspect path|-|artifact\n" +
+		"\n" +
+		"Print the content of a publish or deployment attestation, a statement or a DSSE envelope,\n" +
+		"and validate its structure. The attestation is read from a file, from stdin with '-',\n" +
+		"or from an artifact in a registry, e.g. pushed by 'deployment evaluate'.\n" +
+		"The signatures of a DSSE envelope are not verified.\n" +
+		"\n" +
+		"Example:\n" +
+		"%s inspect ./attestation.json\n" +
+		"%s inspect ghcr.io/org/image@sha256:...\n" +
+		"\n"
+	utils.Log(msg, cli, cli, cli)
+	os.Exit(1)
+}
+
+func Run(cli string, args []string) error {
+	if len(args) != 1 {
+		usage(cli)
+	}
+	content, err := read(args[0])
+	if err != nil {
+		return err
+	}
+	return inspect(content, os.Stdout)
+}
+
+// read reads the attestation from stdin, a file or, if no
+// such file exists, an artifact in a registry.
+func read(arg string) ([]byte, error) {
+	if arg == "-" {
+		return limit.ReadAll(os.Stdin, limit.DefaultMaxSize)
+	}
+	file, fileErr := os.Open(arg)
+	if fileErr == nil {
+		defer file.Close()
+		return limit.ReadAll(file, limit.DefaultMaxSize)
+	}
+	if !errors.Is(fileErr, fs.ErrNotExist) {
+		return nil, fileErr
+	}
+	content, err := image.PullArtifact(arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read (%q) as a file (%v) or an artifact: %w", arg, fileErr, err)
+	}
+	return content, nil
+}
+
+// inspect prints the attestation to w. It returns errorMalformed
+// if the attestation cannot be parsed or its structure is invalid.
+func inspect(content []byte, w io.Writer) error {
+	predicateType, err := statementPredicateType(content)
+	if err != nil {
+		return err
+	}
+	p := printer{w: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+	var validateErr error
+	switch {
+	case slices.Contains(publish.PredicateTypes(), predicateType):
+		verification, err := publish.VerificationNew(io.NopCloser(bytes.NewReader(content)), &utils.PackageHelper{})
+		if err != nil {
+			return fmt.Errorf("%w: %w", errorMalformed, err)
+		}
+		p.publish(verification.Content())
+		validateErr = verification.Validate()
+	case slices.Contains(deployment.PredicateTypes(), predicateType):
+		verification, err := deployment.VerificationNew(io.NopCloser(bytes.NewReader(content)))
+		if err != nil {
+			return fmt.Errorf("%w: %w", errorMalformed, err)
+		}
+		p.deployment(verification.Content())
+		validateErr = verification.Validate()
+	default:
+		return fmt.Errorf("%w: (%q) is not a publish or deployment predicate type", errorPredicateType, predicateType)
+	}
+	violations := errs.Violations(validateErr)
+	p.section("VALIDATION")
+	if len(violations) == 0 {
+		p.row("valid")
+	}
+	for _, violation := range violations {
+		p.row(violation.Error())
+	}
+	if err := p.w.Flush(); err != nil {
+		return err
+	}
+	if len(violations) != 0 {
+		return fmt.Errorf("%w: %d violation(s)", errorMalformed, len(violations))
+	}
+	return nil
+}
+
+// statementPredicateType returns the predicate type
+// of the statement, or of the payload of the envelope.
+func statementPredicateType(content []byte) (string, error) {
+	statement := content
+	dsse, err := intoto.ParseEnvelope(content)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errorMalformed, err)
+	}
+	if dsse != nil {
+		if statement, err = dsse.Statement(); err != nil {
+			return "", fmt.Errorf("%w: %w", errorMalformed, err)
+		}
+	}
+	var header intoto.Header
+	if err := json.Unmarshal(statement, &header); err != nil {
+		return "", fmt.Errorf("%w: failed to unmarshal: %w", errorMalformed, err)
+	}
+	return header.PredicateType, nil
+}
+
+// printer prints the attestation as tables, one per section.
+type printer struct {
+	w          *tabwriter.Writer
+	hasSection bool
+}
+
+func (p *printer) publish(content *publish.Content) {
+	p.section("ATTESTATION")
+	p.header("publish", content.PredicateType, content.PredicateVersion, content.DSSE, content.Signatures)
+	p.row("created", orNone(content.CreationTime))
+	author := "<none>"
+	if content.AuthorID != "" || content.AuthorVersion != "" {
+		author = strings.TrimSpace(content.AuthorID + " " + content.AuthorVersion)
+	}
+	p.row("author", author)
+	p.subjects(content.Subjects)
+	p.section("PACKAGE")
+	pkg := content.Package
+	for _, field := range [][2]string{
+		{"name", pkg.Name},
+		{"registry", pkg.Registry},
+		{"version", pkg.Version},
+		{"environment", pkg.Environment},
+		{"arch", pkg.Arch},
+		{"os", pkg.OS},
+		{"distro", pkg.Distro},
+		{"mediaType", pkg.MediaType},
+	} {
+		if field[1] != "" {
+			p.row(field[0], field[1])
+		}
+	}
+	p.descriptors("POLICIES", content.Policy)
+	p.descriptors("EVIDENCE", content.Evidence)
+	p.properties(content.Properties)
+}
+
+func (p *printer) deployment(content *deployment.Content) {
+	p.section("ATTESTATION")
+	p.header("deployment", content.PredicateType, content.PredicateVersion, content.DSSE, content.Signatures)
+	p.row("created", orNone(content.CreationTime))
+	p.subjects(content.Subjects)
+	p.section("SCOPES")
+	if len(content.Scopes) == 0 {
+		p.row("<none>")
+	}
+	for _, key := range sortedKeys(content.Scopes) {
+		p.row(key, content.Scopes[key])
+	}
+	p.descriptors("POLICIES", content.Policy)
+	p.descriptors("EVIDENCE", content.Evidence)
+	p.properties(content.Properties)
+}
+
+func (p *printer) header(kind, predicateType, version string, dsse bool, signatures int) {
+	p.row("kind", kind)
+	predicate := predicateType
+	if version != "" && !strings.HasSuffix(predicateType, "/"+version) {
+		predicate = fmt.Sprintf("%s (created as %s)", predicateType, version)
+	}
+	p.row("predicate", predicate)
+	envelope := "<none>"
+	if dsse {
+		envelope = fmt.Sprintf("DSSE, %d signature(s), not verified", signatures)
+	}
+	p.row("envelope", envelope)
+}
+
+func (p *printer) subjects(subjects []intoto.Subject) {
+	p.section("SUBJECTS")
+	p.row("NAME", "DIGEST")
+	for _, subject := range subjects {
+		p.row(orNone(subject.Name), digests(subject.Digests))
+	}
+}
+
+func (p *printer) descriptors(title string, descs []intoto.ResourceDescriptor) {
+	if len(descs) == 0 {
+		return
+	}
+	p.section(title)
+	p.row("NAME", "URI", "DIGEST")
+	for _, desc := range descs {
+		p.row(orNone(desc.Name), orNone(desc.URI), digests(desc.Digest))
+	}
+}
+
+func (p *printer) properties(properties map[string]interface{}) {
+	p.section("PROPERTIES")
+	if len(properties) == 0 {
+		p.row("<none>")
+	}
+	for _, name := range sortedKeys(properties) {
+		value, err := json.Marshal(properties[name])
+		if err != nil {
+			value = []byte(fmt.Sprintf("%v", properties[name]))
+		}
+		p.row(name, string(value))
+	}
+}
+
+// section starts a table, separated from the previous one by an empty line.
+func (p *printer) section(title string) {
+	if p.hasSection {
+		fmt.Fprintln(p.w)
+	}
+	p.hasSection = true
+	fmt.Fprintln(p.w, title)
+}
+
+func (p *printer) row(columns ...string) {
+	fmt.Fprintln(p.w, strings.Join(columns, "\t"))
+}
+
+// digests returns the digests as alg:value, sorted by algorithm.
+func digests(ds intoto.DigestSet) string {
+	if len(ds) == 0 {
+		return "<none>"
+	}
+	values := make([]string, 0, len(ds))
+	for _, alg := range sortedKeys(ds) {
+		values = append(values, alg+":"+ds[alg])
+	}
+	return strings.Join(values, ", ")
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
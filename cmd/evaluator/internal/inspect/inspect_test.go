@@ -0,0 +1,161 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_inspect(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	creationTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	publishAtt, err := publish.CreationNew(intoto.Subject{Digests: digests},
+		intoto.PackageDescriptor{Name: "org/server", Registry: "docker.io", Environment: "prod"},
+		publish.SetSlsaBuildLevel(3), publish.WithAuthor("evaluator", "1.0.0"), publish.WithCreationTime(creationTime))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	publishStatement, err := publishAtt.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	deploymentAtt, err := deployment.CreationNew(intoto.Subject{Digests: digests},
+		map[string]string{deployment.ScopeKeyKubernetesServiceAccount: "principal_uri"},
+		deployment.SetPublishRoot("publishr_id"), deployment.WithPolicy("org.json", "policy_uri", digests),
+		deployment.WithCreationTime(creationTime))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	deploymentStatement, err := deploymentAtt.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	deploymentEnvelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(deploymentStatement),
+		Signatures:  []intoto.Signature{{Sig: "c2ln"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	v01Statement := bytes.Replace(publishStatement, []byte("https://slsa.dev/publish/v0.2"),
+		[]byte("https://slsa.dev/publish/v0.1"), 1)
+	invalidStatement := bytes.Replace(publishStatement, []byte(`"slsa.dev/build/level":3`),
+		[]byte(`"slsa.dev/build/level":7`), 1)
+	tests := []struct {
+		name     string
+		content  []byte
+		output   string
+		contains []string
+		expected error
+	}{
+		{
+			name:    "publish statement",
+			content: publishStatement,
+			output: `ATTESTATION
+kind       publish
+predicate  https://slsa.dev/publish/v0.2
+envelope   <none>
+created    2024-01-02T03:04:05Z
+author     evaluator 1.0.0
+
+SUBJECTS
+NAME    DIGEST
+<none>  sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe
+
+PACKAGE
+name         org/server
+registry     docker.io
+environment  prod
+
+PROPERTIES
+slsa.dev/build/level  3
+
+VALIDATION
+valid
+`,
+		},
+		{
+			name:     "publish v0.1 statement",
+			content:  v01Statement,
+			contains: []string{"https://slsa.dev/publish/v0.2 (created as v0.1)"},
+		},
+		{
+			name:    "deployment envelope",
+			content: deploymentEnvelope,
+			output: `ATTESTATION
+kind       deployment
+predicate  https://slsa.dev/deployment/v0.2
+envelope   DSSE, 1 signature(s), not verified
+created    2024-01-02T03:04:05Z
+
+SUBJECTS
+NAME    DIGEST
+<none>  sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe
+
+SCOPES
+kubernetes.io/pod/service_account/v1  principal_uri
+
+POLICIES
+NAME      URI         DIGEST
+org.json  policy_uri  sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe
+
+PROPERTIES
+slsa.dev/publish/root  "publishr_id"
+
+VALIDATION
+valid
+`,
+		},
+		{
+			name:    "invalid structure",
+			content: invalidStatement,
+			// The content is printed before the violations.
+			contains: []string{"slsa.dev/build/level  7", "VALIDATION\ninvalid field"},
+			expected: errorMalformed,
+		},
+		{
+			name:     "unknown predicate type",
+			content:  []byte(`{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/provenance/v1"}`),
+			expected: errorPredicateType,
+		},
+		{
+			name:     "not json",
+			content:  []byte(`not json`),
+			expected: errorMalformed,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			err := inspect(tt.content, &out)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.output != "" {
+				if diff := cmp.Diff(tt.output, out.String()); diff != "" {
+					t.Fatalf("unexpected output (-want +got): \n%s", diff)
+				}
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(out.String(), s) {
+					t.Fatalf("output does not contain (%q): \n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package evaluate
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+)
+
+// evaluateBatch evaluates the requests of the batch file against
+// the policy and writes one decision per line to w, in the order of
+// the requests. A malformed request is denied without being evaluated.
+func evaluateBatch(orgPath, projects, batchPath string, verifier publish.AttestationVerifier,
+	workers int, w io.Writer) error {
+	pol, err := loadPolicy(orgPath, projects)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(batchPath)
+	if err != nil {
+		return fmt.Errorf("failed to read batch file: %w", err)
+	}
+	defer file.Close()
+	reqs, lineErrs, err := utils.ReadJSONLines[utils.PublishRequest](file)
+	if err != nil {
+		return err
+	}
+	var requests []publish.EvaluationRequest
+	var indices []int
+	for i := range reqs {
+		if lineErrs[i] == nil {
+			requests = append(requests, reqs[i].EvaluationRequest())
+			indices = append(indices, i)
+		}
+	}
+	results, err := pol.EvaluateBatch(requests, publish.BatchOptions{
+		Verifier: verifier,
+		Workers:  workers,
+	})
+	if err != nil {
+		return err
+	}
+	decisions := make([]utils.Decision, len(reqs))
+	for i := range reqs {
+		if lineErrs[i] != nil {
+			decisions[i] = utils.Decision{Package: reqs[i].Package}
+			decisions[i].SetError(lineErrs[i])
+		}
+	}
+	for j, i := range indices {
+		decisions[i] = reqs[i].Decision(results[j])
+	}
+	return utils.WriteDecisions(w, decisions)
+}
@@ -2,36 +2,148 @@ package evaluate
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/gha"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/verifiers/slsaprovenance"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-verifier/v2/options"
 	"github.com/slsa-framework/slsa-verifier/v2/verifiers"
 )
 
 type buildVerifier struct {
+	provenances verifiedProvenances
 }
 
-func newBuildVerifier() *buildVerifier {
+// NewBuildVerifier returns the verifier of build attestations used
+// by 'publish evaluate' without --github-attestations. The verifier
+// keeps the provenances it verifies, so a long-running process must
+// create one per evaluation.
+func NewBuildVerifier() publish.AttestationVerifier {
 	return &buildVerifier{}
 }
 
-func (v *buildVerifier) VerifyBuildAttestation(digests intoto.DigestSet, imageName, builderID, sourceURI string) error {
+func (v *buildVerifier) VerifyBuildAttestation(digests intoto.DigestSet, imageName, builderID, sourceURI string,
+	opts publish.AttestationVerifierBuildOptions) error {
+	// NOTE: the provenance of trusted builders is signed by
+	// their reusable workflow, whose URI is the builder ID.
+	if opts.Identity != nil && opts.Identity.Issuer != gha.GitHubIssuer {
+		return fmt.Errorf("VerifyBuildAttestation: builder issuer (%q) is not (%q)",
+			opts.Identity.Issuer, gha.GitHubIssuer)
+	}
 	provenanceOpts := &options.ProvenanceOpts{
 		ExpectedSourceURI: sourceURI,
 		ExpectedDigest:    digests["sha256"],
 	}
 
-	builderOpts := &options.BuilderOpts{
-		ExpectedID: &builderID,
+	builderOpts := &options.BuilderOpts{}
+	if opts.Identity == nil {
+		builderOpts.ExpectedID = &builderID
 	}
 	// NOTE: the API expects an immutable image.
 	immutableImage := utils.ImmutableImage(imageName, digests)
-	_, fullBuilderID, err := verifiers.VerifyImage(context.Background(), immutableImage, nil, provenanceOpts, builderOpts)
+	provenance, fullBuilderID, err := verifiers.VerifyImage(context.Background(), immutableImage, nil, provenanceOpts, builderOpts)
 	if err != nil {
 		return fmt.Errorf("VerifyBuildAttestation: %w", err)
 	}
+	if opts.Identity != nil {
+		// NOTE: the policy anchors the regex.
+		if !opts.Identity.SubjectRegex.MatchString(fullBuilderID.String()) {
+			return fmt.Errorf("VerifyBuildAttestation: builder ID (%q) does not match (%q)",
+				fullBuilderID.String(), opts.Identity.SubjectRegex)
+		}
+	}
+	if opts.MaxAge > 0 {
+		if err := verifyBuildTime(provenance, opts.MaxAge); err != nil {
+			return fmt.Errorf("VerifyBuildAttestation: %w", err)
+		}
+	}
+	v.provenances.set(immutableImage, provenance)
 	utils.Log("Image (%q) verified with builder ID (%q) and sourceURI (%q)\n", imageName, fullBuilderID.String(), sourceURI)
 	return nil
 }
+
+// provenanceTimes contains the build times of
+// the SLSA v0.2 and v1 provenance formats.
+type provenanceTimes struct {
+	Predicate struct {
+		Metadata struct {
+			BuildStartedOn  *time.Time `json:"buildStartedOn"`
+			BuildFinishedOn *time.Time `json:"buildFinishedOn"`
+		} `json:"metadata"`
+		RunDetails struct {
+			Metadata struct {
+				StartedOn  *time.Time `json:"startedOn"`
+				FinishedOn *time.Time `json:"finishedOn"`
+			} `json:"metadata"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+}
+
+// verifyBuildTime verifies that the provenance was built within maxAge.
+// The start of the build is used if present.
+func verifyBuildTime(provenance []byte, maxAge time.Duration) error {
+	var times provenanceTimes
+	if err := json.Unmarshal(provenance, &times); err != nil {
+		return fmt.Errorf("failed to unmarshal provenance: %w", err)
+	}
+	var buildTime *time.Time
+	for _, t := range []*time.Time{
+		times.Predicate.RunDetails.Metadata.StartedOn, times.Predicate.Metadata.BuildStartedOn,
+		times.Predicate.RunDetails.Metadata.FinishedOn, times.Predicate.Metadata.BuildFinishedOn,
+	} {
+		if t != nil {
+			buildTime = t
+			break
+		}
+	}
+	if buildTime == nil {
+		return fmt.Errorf("provenance has no build time")
+	}
+	if age := time.Since(*buildTime); age > maxAge {
+		return fmt.Errorf("provenance built at (%v) is older than (%v)", buildTime.UTC(), maxAge)
+	}
+	return nil
+}
+
+func (v *buildVerifier) BaseImages(digests intoto.DigestSet, imageName string) ([]string, error) {
+	return v.provenances.baseImages(utils.ImmutableImage(imageName, digests))
+}
+
+// verifiedProvenances contains the provenance statements verified by
+// VerifyBuildAttestation, by immutable image. The policy only asks for
+// the base images of a package once its build attestation is verified.
+type verifiedProvenances struct {
+	mu         sync.Mutex
+	statements map[string][]byte
+}
+
+func (p *verifiedProvenances) set(image string, statement []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.statements == nil {
+		p.statements = make(map[string][]byte)
+	}
+	p.statements[image] = statement
+}
+
+// baseImages returns the images among the materials of the verified
+// provenance of image, see slsaprovenance.Provenance.BaseImages.
+func (p *verifiedProvenances) baseImages(image string) ([]string, error) {
+	p.mu.Lock()
+	statement, exists := p.statements[image]
+	p.mu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("BaseImages: no verified provenance for image (%q)", image)
+	}
+	provenance, err := slsaprovenance.ParseStatement(statement)
+	if err != nil {
+		return nil, fmt.Errorf("BaseImages: %w", err)
+	}
+	return provenance.BaseImages, nil
+}
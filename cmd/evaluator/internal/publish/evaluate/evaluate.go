@@ -1,44 +1,155 @@
 package evaluate
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/validate"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/crypto"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/source"
 	"github.com/slsa-framework/slsa-policy/pkg/publish"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files_reader"
 )
 
 func usage(cli string) {
 	msg := "" +
-		"Usage: %s publish evaluate orgPath projectsPath packageName [optional:environment]\n" +
+		"Usage: %s publish evaluate [--dry-run] [--format text|json [--trace]] [--sign keyless|kms [--kms-key reference]] [--github-attestations] orgPath projectsPath packageName [optional:environment]\n" +
+		"       %s publish evaluate [--github-attestations] [--workers n] --batch-file path orgPath projectsPath\n" +
+		"\n" +
+		"Options:\n" +
+		"--dry-run \t\tPrint the decision without creating or signing an attestation.\n" +
+		"          \t\tExits with 0 if the package is allowed, 1 otherwise.\n" +
+		"--format \t\tOutput format, text (default) or json. The json format prints\n" +
+		"         \t\ta single decision object to stdout.\n" +
+		"--trace  \t\tInclude in the json decision the builders considered by the evaluation,\n" +
+		"         \t\tin order, and why each was rejected. Requires --format json.\n" +
+		"--sign   \t\tSign the printed attestation, keyless with Fulcio and Rekor, or with a KMS key.\n" +
+		"         \t\tThe attestation is a DSSE envelope if set, a plain statement otherwise.\n" +
+		"         \t\tWith keyless, the attestation is also attached to the image, see cosign verify-attestation.\n" +
+		"--kms-key\t\tReference of the KMS key, e.g. gcpkms://projects/[...]/cryptoKeys/key.\n" +
+		"         \t\tRequires --sign kms.\n" +
+		"--github-attestations\tVerify the GitHub artifact attestations of the image, fetched from\n" +
+		"         \t\tthe GitHub attestations API using the GITHUB_TOKEN environment variable.\n" +
+		"--batch-file\t\tEvaluate the requests of the file, one JSON object per line, e.g.\n" +
+		"         \t\t{\"package\": \"docker.io/org/server\", \"digests\": {\"sha256\": \"...\"}, \"environment\": \"prod\"}.\n" +
+		"         \t\tPrints one JSON decision per line, in the order of the requests, without\n" +
+		"         \t\tcreating attestations. Exits with an error if any request is denied.\n" +
+		"--workers\t\tNumber of requests of the batch file evaluated concurrently. Defaults to 1.\n" +
 		"\n" +
 		"Example:\n" +
 		"%s publish evaluate ./path/to/policy/org ./path/to/policy/projects slsa-framework/echo-server@sha256:xxxx prod\n" +
+		"%s publish evaluate org.json oci://ghcr.io/org/policies@sha256:xxxx slsa-framework/echo-server@sha256:xxxx prod\n" +
+		"\n" +
+		"The projectsPath is a directory, or a remote policy source pinned by digest:\n" +
+		"an OCI artifact, e.g. oci://ghcr.io/org/policies@sha256:xxxx, or a tarball,\n" +
+		"e.g. https://example.com/policies.tar.gz#sha256=xxxx. For a remote source, orgPath\n" +
+		"is the path of the org policy in the source, empty for org.json, org.yaml or org.yml.\n" +
+		"The command exits with distinct codes if the source cannot be fetched or\n" +
+		"does not match its digest.\n" +
+		"The SOURCE_DATE_EPOCH environment variable, a number of seconds since the Unix epoch,\n" +
+		"sets the creation time of the attestation, so that the same inputs create the same attestation.\n" +
 		"\n"
-	fmt.Fprintf(os.Stderr, msg, cli, cli)
+	fmt.Fprintf(os.Stderr, msg, cli, cli, cli, cli)
 	os.Exit(1)
 }
 
 func Run(cli string, args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	fs.Usage = func() { usage(cli) }
+	dryRun := fs.Bool("dry-run", false, "print the decision without creating or signing an attestation")
+	format := fs.String("format", utils.FormatText, "output format: text or json")
+	sign := fs.String("sign", "", "signing mode: keyless or kms")
+	kmsKey := fs.String("kms-key", "", "reference of the KMS key, with --sign kms")
+	githubAttestations := fs.Bool("github-attestations", false, "verify GitHub artifact attestations")
+	batchFile := fs.String("batch-file", "", "path to a file of requests, one JSON object per line")
+	workers := fs.Int("workers", 1, "number of requests of the batch file evaluated concurrently")
+	withTrace := fs.Bool("trace", false, "include the evaluation trace in the json decision")
+	if err := fs.Parse(args); err != nil {
+		usage(cli)
+	}
+	var verifier publish.AttestationVerifier = NewBuildVerifier()
+	if *githubAttestations {
+		verifier = newGHAVerifier()
+	}
+	if *batchFile != "" {
+		if *dryRun || *sign != "" {
+			return fmt.Errorf("--batch-file does not create attestations. Remove --dry-run and --sign")
+		}
+		if *withTrace {
+			return fmt.Errorf("--trace is not supported with --batch-file")
+		}
+		if fs.NArg() != 2 {
+			usage(cli)
+		}
+		return evaluateBatch(fs.Arg(0), fs.Arg(1), *batchFile, verifier, *workers, os.Stdout)
+	}
+	if err := utils.ValidateFormat(*format); err != nil {
+		return err
+	}
+	if err := utils.ValidateSigning(*sign, *kmsKey); err != nil {
+		return err
+	}
+	if *withTrace && *format != utils.FormatJSON {
+		return fmt.Errorf("--trace requires --format json")
+	}
+	args = fs.Args()
 	// Argument count is 3 or 4.
 	if len(args) < 3 || len(args) > 4 {
 		usage(cli)
 	}
-	// Extract inputs.
-	orgPath := args[0]
-	projectsPath, err := utils.ReadFiles(args[1], orgPath)
+	decision := utils.Decision{
+		Decision: "deny",
+		Package:  args[2],
+	}
+	signer, err := crypto.SignerNew(context.Background(), *sign, *kmsKey)
 	if err != nil {
 		return err
 	}
+	var evalOpts []publish.EvaluationOption
+	if *withTrace {
+		evalOpts = append(evalOpts, publish.WithTrace())
+	}
+	result, err := evaluate(args, *dryRun, *format, verifier, signer, evalOpts, &decision)
+	if *format == utils.FormatJSON {
+		if err != nil {
+			decision.SetError(err)
+		}
+		if err := decision.Write(os.Stdout); err != nil {
+			return err
+		}
+	}
+	if !*dryRun || result == nil {
+		return err
+	}
+	if *format == utils.FormatText {
+		printDecision(decision.Package, *result)
+	}
+	if result.Error() != nil {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// evaluate evaluates the policy and, unless dryRun is set, creates
+// a publish attestation. The printed attestation is signed by signer
+// if set, and attached to the image if signed keyless. The decision
+// is filled as the evaluation progresses.
+func evaluate(args []string, dryRun bool, format string, verifier publish.AttestationVerifier,
+	signer intoto.AttestationSigner, evalOpts []publish.EvaluationOption, decision *utils.Decision) (*publish.PolicyEvaluationResult, error) {
+	// Extract inputs.
+	orgPath := args[0]
 	imageURI, digest, err := utils.ParseImageReference(args[2])
 	if err != nil {
-		return err
+		return nil, err
 	}
+	decision.Package = imageURI
 	var env *string
 	if len(args) == 4 && args[3] != "" {
 		// Only set the env if it's not empty.
@@ -47,19 +158,17 @@ func Run(cli string, args []string) error {
 	}
 	digestsArr := strings.Split(digest, ":")
 	if len(digestsArr) != 2 {
-		return fmt.Errorf("invalid digest (%q)", digest)
+		return nil, fmt.Errorf("invalid digest (%q)", digest)
 	}
 	// Create a policy.
-	projectsReader := files_reader.FromPaths(projectsPath)
-	organizationReader, err := os.Open(orgPath)
-	pol, err := publish.PolicyNew(organizationReader, projectsReader, &utils.PackageHelper{}, publish.SetValidator(&validate.PolicyValidator{}))
+	pol, err := loadPolicy(orgPath, args[1])
 	if err != nil {
-		return fmt.Errorf("failed to create policy: %w", err)
+		return nil, err
 	}
 
 	// Evaluate the policy.
 	opts := publish.AttestationVerificationOption{
-		Verifier: newBuildVerifier(),
+		Verifier: verifier,
 	}
 	reqOpts := publish.RequestOption{
 		Environment: env,
@@ -67,24 +176,109 @@ func Run(cli string, args []string) error {
 	digests := intoto.DigestSet{
 		digestsArr[0]: digestsArr[1],
 	}
+	decision.Digests = digests
 	// NOTE: imageURI must be the same as set in the policy's package name.
-	result := pol.Evaluate(digests, imageURI, reqOpts, opts)
+	result := pol.Evaluate(digests, imageURI, reqOpts, opts, evalOpts...)
+	decision.Trace = result.Trace()
 	if result.Error() != nil {
-		return result.Error()
+		return &result, result.Error()
+	}
+	level := result.Level()
+	decision.Decision = "allow"
+	decision.SlsaLevel = &level
+	if dryRun {
+		return &result, nil
 	}
 
 	// Create a publish attestation and sign it.
 	// TODO(#3): do not attach the attestation, so that caller can do it however they want.
 	// TODO(#2): add policy.
-	att, err := result.AttestationNew()
+	var creationOpts []publish.AttestationCreationOption
+	creationTime, exists, err := utils.SourceDateEpoch()
+	if err != nil {
+		return &result, err
+	}
+	if exists {
+		creationOpts = append(creationOpts, publish.WithCreationTime(creationTime))
+	}
+	att, err := result.AttestationNew(creationOpts...)
+	if err != nil {
+		return &result, fmt.Errorf("failed to create attestation: %w", err)
+	}
+	if crypto.IsKeyless(signer) {
+		// The keyless signature is also attached to the image.
+		immutableImage := utils.ImmutableImage(imageURI, digests)
+		envelope, entryID, err := crypto.Sign(att, immutableImage)
+		if err != nil {
+			return &result, err
+		}
+		decision.Attestation = immutableImage
+		decision.LogEntryID = entryID
+		printAttestation(format, envelope)
+		return &result, nil
+	}
+	attBytes, err := utils.AttestationContent(context.Background(), att, signer)
+	if err != nil {
+		return &result, err
+	}
+	printAttestation(format, attBytes)
+	return &result, nil
+}
+
+// printAttestation prints the attestation to stdout.
+// NOTE: the JSON format prints the decision only.
+func printAttestation(format string, content []byte) {
+	if format == utils.FormatText {
+		fmt.Println(string(content))
+	}
+}
+
+// printDecision prints the result of the evaluation to stdout.
+// NOTE: the package name identifies the project policy that matched.
+func printDecision(packageName string, result publish.PolicyEvaluationResult) {
+	if err := result.Error(); err != nil {
+		fmt.Printf("decision: deny\npackage: %s\nreason: %v\n", packageName, err)
+		return
+	}
+	fmt.Printf("decision: allow\npackage: %s\nlevel: %d\n", packageName, result.Level())
+}
+
+// loadPolicy creates a policy from the org policy and the project policies
+// at projects, either a directory or a remote policy source. For a remote
+// source, orgPath is the path of the org policy inside the source.
+func loadPolicy(orgPath, projects string) (*publish.Policy, error) {
+	if !source.IsRemote(projects) {
+		projectsPath, err := utils.ReadFiles(projects, orgPath)
+		if err != nil {
+			return nil, err
+		}
+		return newPolicy(orgPath, projectsPath)
+	}
+	src, err := source.Fetch(projects)
 	if err != nil {
-		return fmt.Errorf("failed to create attestation: %w", err)
+		return nil, err
 	}
-	attBytes, err := att.ToBytes()
+	organizationReader, projectsReader, err := src.Open(orgPath)
+	if err != nil {
+		return nil, err
+	}
+	return createPolicy(organizationReader, iterator.Unnamed(projectsReader))
+}
+
+// newPolicy creates a policy from the org policy and the project policy files.
+func newPolicy(orgPath string, projectsPath []string) (*publish.Policy, error) {
+	projectsReader := files_reader.FromPaths(projectsPath)
+	organizationReader, err := os.Open(orgPath)
 	if err != nil {
-		return fmt.Errorf("failed to get attestation bytes: %w\n", err)
+		return nil, fmt.Errorf("failed to read org path: %w", err)
 	}
-	fmt.Println(string(attBytes))
+	return createPolicy(organizationReader, projectsReader)
+}
 
-	return crypto.Sign(att, utils.ImmutableImage(imageURI, digests))
+func createPolicy(organizationReader io.ReadCloser, projectsReader iterator.ReadCloserIterator) (*publish.Policy, error) {
+	pol, err := publish.PolicyNew(organizationReader, projectsReader, &utils.PackageHelper{}, publish.SetValidator(&validate.PolicyValidator{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy: %w", err)
+	}
+	return pol, nil
 }
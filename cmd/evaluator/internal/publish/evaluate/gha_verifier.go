@@ -0,0 +1,60 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/gha"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// ghaVerifier verifies the GitHub artifact attestations of images,
+// fetched from the GitHub attestations API.
+type ghaVerifier struct {
+	verifier    *gha.Verifier
+	provenances verifiedProvenances
+}
+
+func newGHAVerifier() *ghaVerifier {
+	client := gha.ClientNew(gha.DefaultBaseURL, os.Getenv("GITHUB_TOKEN"))
+	return &ghaVerifier{
+		verifier: gha.VerifierNew(client, gha.SigstoreVerifierNew()),
+	}
+}
+
+func (v *ghaVerifier) VerifyBuildAttestation(digests intoto.DigestSet, imageName, builderID, sourceURI string,
+	opts publish.AttestationVerifierBuildOptions) error {
+	criteria := gha.Criteria{
+		Digest:    digests["sha256"],
+		BuilderID: builderID,
+		SourceURI: sourceURI,
+	}
+	if opts.Identity != nil {
+		// NOTE: GitHub artifact attestations are signed by workflows.
+		if opts.Identity.Issuer != gha.GitHubIssuer {
+			return fmt.Errorf("VerifyBuildAttestation: builder issuer (%q) is not (%q)",
+				opts.Identity.Issuer, gha.GitHubIssuer)
+		}
+		criteria.SubjectRegex = opts.Identity.SubjectRegex
+	}
+	provenance, err := v.verifier.Verify(context.Background(), criteria)
+	if err != nil {
+		return fmt.Errorf("VerifyBuildAttestation: %w", err)
+	}
+	if opts.MaxAge > 0 {
+		if err := verifyBuildTime(provenance.Statement, opts.MaxAge); err != nil {
+			return fmt.Errorf("VerifyBuildAttestation: %w", err)
+		}
+	}
+	v.provenances.set(utils.ImmutableImage(imageName, digests), provenance.Statement)
+	utils.Log("Image (%q) verified with GitHub attestation signed by (%q) and sourceURI (%q)\n",
+		imageName, provenance.Identity.SubjectURI, sourceURI)
+	return nil
+}
+
+func (v *ghaVerifier) BaseImages(digests intoto.DigestSet, imageName string) ([]string, error) {
+	return v.provenances.baseImages(utils.ImmutableImage(imageName, digests))
+}
@@ -0,0 +1,347 @@
+package gha
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore/pkg/fulcioroots"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/tlog"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+var errorBundle = errors.New("invalid bundle")
+
+// Fulcio certificate extensions, see
+// https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md.
+var (
+	oidIssuerV1            = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuer              = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidSourceRepositoryURI = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+)
+
+// Identity is the identity of the signer of an attestation,
+// extracted from its Fulcio certificate.
+type Identity struct {
+	// SubjectURI is the URI of the signer workflow,
+	// e.g. https://github.com/org/repo/.github/workflows/build.yml@refs/heads/main.
+	SubjectURI string
+	// Issuer is the OIDC issuer.
+	Issuer string
+	// SourceRepositoryURI is the repository the workflow ran in.
+	SourceRepositoryURI string
+}
+
+// Provenance is a verified attestation.
+type Provenance struct {
+	// Statement is the in-toto statement of the attestation.
+	Statement []byte
+	Identity  Identity
+}
+
+// BundleVerifier verifies Sigstore bundles.
+type BundleVerifier interface {
+	// Verify verifies the signature and the transparency log entries
+	// of a bundle and returns the attestation it contains.
+	Verify(ctx context.Context, bundle []byte) (*Provenance, error)
+}
+
+type rawBytes struct {
+	RawBytes string `json:"rawBytes"`
+}
+
+type tlogEntry struct {
+	LogIndex string `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   string `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	InclusionProof *struct {
+		LogIndex   string   `json:"logIndex"`
+		RootHash   string   `json:"rootHash"`
+		TreeSize   string   `json:"treeSize"`
+		Hashes     []string `json:"hashes"`
+		Checkpoint struct {
+			Envelope string `json:"envelope"`
+		} `json:"checkpoint"`
+	} `json:"inclusionProof"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// bundle is a Sigstore bundle, in the JSON encoding of
+// https://github.com/sigstore/protobuf-specs/blob/main/protos/sigstore_bundle.proto.
+type bundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		Certificate          *rawBytes `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []rawBytes `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []tlogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope *intoto.Envelope `json:"dsseEnvelope"`
+}
+
+// sigstoreVerifier verifies bundles signed with the public
+// Sigstore instance, i.e., Fulcio and Rekor.
+type sigstoreVerifier struct{}
+
+// SigstoreVerifierNew returns a verifier for bundles
+// signed with the public Sigstore instance.
+func SigstoreVerifierNew() BundleVerifier {
+	return &sigstoreVerifier{}
+}
+
+func (v *sigstoreVerifier) Verify(ctx context.Context, content []byte) (*Provenance, error) {
+	var b bundle
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal: %w", errorBundle, err)
+	}
+	if b.DSSEEnvelope == nil {
+		return nil, fmt.Errorf("%w: no DSSE envelope", errorBundle)
+	}
+	cert, intermediates, err := b.certificates()
+	if err != nil {
+		return nil, err
+	}
+	// Certificate chain.
+	roots, err := fulcioroots.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fulcio roots: %w", err)
+	}
+	if err := fulcioroots.GetIntermediatesWithCertPool(intermediates); err != nil {
+		return nil, fmt.Errorf("failed to get fulcio intermediates: %w", err)
+	}
+	if _, err := cosign.TrustedCert(cert, roots, intermediates); err != nil {
+		return nil, err
+	}
+	// Signature.
+	verifier, err := intoto.PublicKeysVerifierNew(cert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.DSSEEnvelope.Verify(verifier); err != nil {
+		return nil, err
+	}
+	// Transparency log.
+	if err := b.verifyTlogEntries(ctx, cert); err != nil {
+		return nil, err
+	}
+	statement, err := b.DSSEEnvelope.Statement()
+	if err != nil {
+		return nil, err
+	}
+	identity, err := certIdentity(cert)
+	if err != nil {
+		return nil, err
+	}
+	return &Provenance{
+		Statement: statement,
+		Identity:  *identity,
+	}, nil
+}
+
+// certificates returns the leaf certificate and the
+// pool of intermediate certificates of the bundle.
+func (b *bundle) certificates() (*x509.Certificate, *x509.CertPool, error) {
+	var raws []rawBytes
+	switch {
+	case b.VerificationMaterial.Certificate != nil:
+		raws = []rawBytes{*b.VerificationMaterial.Certificate}
+	case b.VerificationMaterial.X509CertificateChain != nil:
+		raws = b.VerificationMaterial.X509CertificateChain.Certificates
+	}
+	if len(raws) == 0 {
+		return nil, nil, fmt.Errorf("%w: no certificate", errorBundle)
+	}
+	intermediates := x509.NewCertPool()
+	var leaf *x509.Certificate
+	for i, raw := range raws {
+		der, err := base64.StdEncoding.DecodeString(raw.RawBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: certificate %d: %w", errorBundle, i, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: certificate %d: %w", errorBundle, i, err)
+		}
+		if i == 0 {
+			leaf = cert
+			continue
+		}
+		intermediates.AddCert(cert)
+	}
+	return leaf, intermediates, nil
+}
+
+// verifyTlogEntries verifies that at least one entry is signed by Rekor,
+// records the envelope and certificate of the bundle, and was integrated
+// while the certificate was valid.
+func (b *bundle) verifyTlogEntries(ctx context.Context, cert *x509.Certificate) error {
+	if len(b.VerificationMaterial.TlogEntries) == 0 {
+		return fmt.Errorf("%w: no transparency log entry", errorBundle)
+	}
+	pubKeys, err := cosign.GetRekorPubs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get rekor public keys: %w", err)
+	}
+	var errs []error
+	for i, e := range b.VerificationMaterial.TlogEntries {
+		entry, err := e.logEntry()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %w", i, err))
+			continue
+		}
+		if err := cosign.VerifyTLogEntryOffline(ctx, entry, pubKeys); err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %w", i, err))
+			continue
+		}
+		// NOTE: the inclusion proof only proves that the body is in
+		// the log, not that it is the body of this bundle.
+		if err := verifyEntryBody(e.CanonicalizedBody, b.DSSEEnvelope, cert); err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %w", i, err))
+			continue
+		}
+		integrated := time.Unix(*entry.IntegratedTime, 0)
+		if integrated.Before(cert.NotBefore) || integrated.After(cert.NotAfter) {
+			errs = append(errs, fmt.Errorf("entry %d: integrated at (%v) outside of certificate validity", i, integrated.UTC()))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no valid transparency log entry: %w", errorBundle, errors.Join(errs...))
+}
+
+// verifyEntryBody verifies that the body of a Rekor entry records
+// the payload of the envelope and one of its signatures, by cert.
+func verifyEntryBody(body string, envelope *intoto.Envelope, cert *x509.Certificate) error {
+	verifiers, err := tlog.VerifyEntryBody(body, envelope)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errorBundle, err)
+	}
+	for _, verifier := range verifiers {
+		block, _ := pem.Decode(verifier)
+		if block != nil && bytes.Equal(block.Bytes, cert.Raw) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: entry does not record a signature of the envelope by the certificate", errorBundle)
+}
+
+// logEntry converts the entry to the format of the Rekor API.
+func (e *tlogEntry) logEntry() (*models.LogEntryAnon, error) {
+	if e.InclusionPromise == nil || e.InclusionProof == nil {
+		return nil, fmt.Errorf("no inclusion promise or proof")
+	}
+	logIndex, err := strconv.ParseInt(e.LogIndex, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log index: %w", err)
+	}
+	integratedTime, err := strconv.ParseInt(e.IntegratedTime, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integrated time: %w", err)
+	}
+	proofIndex, err := strconv.ParseInt(e.InclusionProof.LogIndex, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof log index: %w", err)
+	}
+	treeSize, err := strconv.ParseInt(e.InclusionProof.TreeSize, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tree size: %w", err)
+	}
+	logID, err := base64ToHex(e.LogID.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log ID: %w", err)
+	}
+	rootHash, err := base64ToHex(e.InclusionProof.RootHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root hash: %w", err)
+	}
+	hashes := make([]string, len(e.InclusionProof.Hashes))
+	for i, h := range e.InclusionProof.Hashes {
+		if hashes[i], err = base64ToHex(h); err != nil {
+			return nil, fmt.Errorf("invalid hash: %w", err)
+		}
+	}
+	set, err := base64.StdEncoding.DecodeString(e.InclusionPromise.SignedEntryTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signed entry timestamp: %w", err)
+	}
+	checkpoint := e.InclusionProof.Checkpoint.Envelope
+	return &models.LogEntryAnon{
+		Body:           e.CanonicalizedBody,
+		IntegratedTime: &integratedTime,
+		LogID:          &logID,
+		LogIndex:       &logIndex,
+		Verification: &models.LogEntryAnonVerification{
+			InclusionProof: &models.InclusionProof{
+				Checkpoint: &checkpoint,
+				Hashes:     hashes,
+				LogIndex:   &proofIndex,
+				RootHash:   &rootHash,
+				TreeSize:   &treeSize,
+			},
+			SignedEntryTimestamp: set,
+		},
+	}, nil
+}
+
+func base64ToHex(value string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(decoded), nil
+}
+
+// certIdentity extracts the identity of the signer from the certificate.
+func certIdentity(cert *x509.Certificate) (*Identity, error) {
+	if len(cert.URIs) != 1 {
+		return nil, fmt.Errorf("%w: certificate has %d URIs", errorBundle, len(cert.URIs))
+	}
+	identity := Identity{
+		SubjectURI: cert.URIs[0].String(),
+	}
+	for _, ext := range cert.Extensions {
+		var err error
+		switch {
+		case ext.Id.Equal(oidIssuer):
+			identity.Issuer, err = derString(ext.Value)
+		case ext.Id.Equal(oidIssuerV1) && identity.Issuer == "":
+			// NOTE: the deprecated extension is not DER-encoded.
+			identity.Issuer = string(ext.Value)
+		case ext.Id.Equal(oidSourceRepositoryURI):
+			identity.SourceRepositoryURI, err = derString(ext.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: extension %v: %w", errorBundle, ext.Id, err)
+		}
+	}
+	return &identity, nil
+}
+
+func derString(value []byte) (string, error) {
+	var s string
+	rest, err := asn1.Unmarshal(value, &s)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) != 0 {
+		return "", fmt.Errorf("trailing data")
+	}
+	return s, nil
+}
@@ -0,0 +1,310 @@
+package gha
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func newCertificate(t *testing.T, uris []string, extensions []pkix.Extension) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		NotBefore:       time.Now(),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		ExtraExtensions: extensions,
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			t.Fatalf("failed to parse URI: %v", err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func derExtension(t *testing.T, id asn1.ObjectIdentifier, value string) pkix.Extension {
+	der, err := asn1.MarshalWithParams(value, "utf8")
+	if err != nil {
+		t.Fatalf("failed to marshal extension: %v", err)
+	}
+	return pkix.Extension{Id: id, Value: der}
+}
+
+func Test_certIdentity(t *testing.T) {
+	t.Parallel()
+	subjectURI := workflowURI + "@refs/heads/main"
+	tests := []struct {
+		name       string
+		uris       []string
+		extensions func(t *testing.T) []pkix.Extension
+		identity   *Identity
+		expected   error
+	}{
+		{
+			name: "extensions",
+			uris: []string{subjectURI},
+			extensions: func(t *testing.T) []pkix.Extension {
+				return []pkix.Extension{
+					{Id: oidIssuerV1, Value: []byte("https://deprecated.issuer")},
+					derExtension(t, oidIssuer, GitHubIssuer),
+					derExtension(t, oidSourceRepositoryURI, "https://github.com/org/repo"),
+				}
+			},
+			identity: &Identity{
+				SubjectURI:          subjectURI,
+				Issuer:              GitHubIssuer,
+				SourceRepositoryURI: "https://github.com/org/repo",
+			},
+		},
+		{
+			name: "deprecated issuer",
+			uris: []string{subjectURI},
+			extensions: func(t *testing.T) []pkix.Extension {
+				return []pkix.Extension{{Id: oidIssuerV1, Value: []byte(GitHubIssuer)}}
+			},
+			identity: &Identity{
+				SubjectURI: subjectURI,
+				Issuer:     GitHubIssuer,
+			},
+		},
+		{
+			name:     "no URI",
+			expected: errorBundle,
+		},
+		{
+			name:     "several URIs",
+			uris:     []string{subjectURI, subjectURI},
+			expected: errorBundle,
+		},
+		{
+			name: "invalid extension",
+			uris: []string{subjectURI},
+			extensions: func(t *testing.T) []pkix.Extension {
+				return []pkix.Extension{{Id: oidIssuer, Value: []byte(GitHubIssuer)}}
+			},
+			expected: errorBundle,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var extensions []pkix.Extension
+			if tt.extensions != nil {
+				extensions = tt.extensions(t)
+			}
+			identity, err := certIdentity(newCertificate(t, tt.uris, extensions))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.identity, identity); diff != "" {
+				t.Fatalf("unexpected identity (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_SigstoreVerifier(t *testing.T) {
+	t.Parallel()
+	envelope := `"dsseEnvelope":{"payloadType":"application/vnd.in-toto+json","payload":"e30=","signatures":[{"sig":"c2ln"}]}`
+	tests := []struct {
+		name   string
+		bundle string
+	}{
+		{
+			name:   "not json",
+			bundle: "not json",
+		},
+		{
+			name:   "no envelope",
+			bundle: `{"verificationMaterial":{"certificate":{"rawBytes":"c2ln"}}}`,
+		},
+		{
+			name:   "no certificate",
+			bundle: `{"verificationMaterial":{},` + envelope + `}`,
+		},
+		{
+			name:   "invalid certificate encoding",
+			bundle: `{"verificationMaterial":{"certificate":{"rawBytes":"not base64!"}},` + envelope + `}`,
+		},
+		{
+			name:   "invalid certificate",
+			bundle: `{"verificationMaterial":{"x509CertificateChain":{"certificates":[{"rawBytes":"c2ln"}]}},` + envelope + `}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := SigstoreVerifierNew().Verify(context.Background(), []byte(tt.bundle))
+			if diff := cmp.Diff(errorBundle, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_logEntry(t *testing.T) {
+	t.Parallel()
+	entry := `{"logIndex":"10","logId":{"keyId":"AQI="},"integratedTime":"1700000000",` +
+		`"inclusionPromise":{"signedEntryTimestamp":"c2V0"},` +
+		`"canonicalizedBody":"Ym9keQ=="` +
+		`INCLUSION_PROOF}`
+	var noProof tlogEntry
+	if err := json.Unmarshal([]byte(strings.Replace(entry, "INCLUSION_PROOF", "", 1)), &noProof); err != nil {
+		t.Fatalf("failed to unmarshal entry: %v", err)
+	}
+	if _, err := noProof.logEntry(); err == nil {
+		t.Fatalf("expected an error for an entry without inclusion proof")
+	}
+	var withProof tlogEntry
+	if err := json.Unmarshal([]byte(strings.Replace(entry, "INCLUSION_PROOF",
+		`,"inclusionProof":{"logIndex":"5","rootHash":"AwQ=","treeSize":"20","hashes":["BQY="],"checkpoint":{"envelope":"checkpoint"}}`, 1)),
+		&withProof); err != nil {
+		t.Fatalf("failed to unmarshal entry: %v", err)
+	}
+	result, err := withProof.logEntry()
+	if err != nil {
+		t.Fatalf("failed to convert entry: %v", err)
+	}
+	if diff := cmp.Diff("0102", *result.LogID); diff != "" {
+		t.Fatalf("unexpected log ID (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff(int64(10), *result.LogIndex); diff != "" {
+		t.Fatalf("unexpected log index (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"0506"}, result.Verification.InclusionProof.Hashes); diff != "" {
+		t.Fatalf("unexpected hashes (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("0304", *result.Verification.InclusionProof.RootHash); diff != "" {
+		t.Fatalf("unexpected root hash (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff(int64(20), *result.Verification.InclusionProof.TreeSize); diff != "" {
+		t.Fatalf("unexpected tree size (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("set", string(result.Verification.SignedEntryTimestamp)); diff != "" {
+		t.Fatalf("unexpected signed entry timestamp (-want +got): \n%s", diff)
+	}
+}
+
+func Test_verifyEntryBody(t *testing.T) {
+	t.Parallel()
+	cert := newCertificate(t, nil, nil)
+	otherCert := newCertificate(t, nil, nil)
+	envelope := &intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString([]byte(`{"predicateType":"a"}`)),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString([]byte("sig"))}},
+	}
+	otherEnvelope := &intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString([]byte(`{"predicateType":"b"}`)),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString([]byte("other sig"))}},
+	}
+	dsseBody := func(envelope *intoto.Envelope, cert *x509.Certificate) string {
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		digest := sha256.Sum256(payload)
+		pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"dsse","spec":{"payloadHash":{"algorithm":"sha256","value":%q},`+
+			`"signatures":[{"signature":%q,"verifier":%q}]}}`,
+			hex.EncodeToString(digest[:]), envelope.Signatures[0].Sig, base64.StdEncoding.EncodeToString(pemCert))
+		return base64.StdEncoding.EncodeToString([]byte(body))
+	}
+	intotoBody := func(envelope *intoto.Envelope, cert *x509.Certificate) string {
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		digest := sha256.Sum256(payload)
+		pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		body := fmt.Sprintf(`{"apiVersion":"0.0.2","kind":"intoto","spec":{"content":{"payloadHash":{"algorithm":"sha256","value":%q},`+
+			`"envelope":{"signatures":[{"sig":%q,"publicKey":%q}]}}}}`,
+			hex.EncodeToString(digest[:]), base64.StdEncoding.EncodeToString([]byte(envelope.Signatures[0].Sig)),
+			base64.StdEncoding.EncodeToString(pemCert))
+		return base64.StdEncoding.EncodeToString([]byte(body))
+	}
+	tests := []struct {
+		name     string
+		body     string
+		expected error
+	}{
+		{
+			name: "dsse entry",
+			body: dsseBody(envelope, cert),
+		},
+		{
+			name: "intoto entry",
+			body: intotoBody(envelope, cert),
+		},
+		{
+			name:     "swapped dsse entry",
+			body:     dsseBody(otherEnvelope, cert),
+			expected: errorBundle,
+		},
+		{
+			name:     "swapped intoto entry",
+			body:     intotoBody(otherEnvelope, cert),
+			expected: errorBundle,
+		},
+		{
+			name:     "other certificate",
+			body:     dsseBody(envelope, otherCert),
+			expected: errorBundle,
+		},
+		{
+			name:     "unsupported kind",
+			body:     base64.StdEncoding.EncodeToString([]byte(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{}}`)),
+			expected: errorBundle,
+		},
+		{
+			name:     "invalid body",
+			body:     "not base64!",
+			expected: errorBundle,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := verifyEntryBody(tt.body, envelope, cert)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
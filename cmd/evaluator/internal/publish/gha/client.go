@@ -0,0 +1,83 @@
+package gha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultBaseURL is the URL of the GitHub API.
+const DefaultBaseURL = "https://api.github.com"
+
+var errorNotFound = errors.New("no attestation found")
+
+// Client fetches artifact attestations from the GitHub attestations API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// ClientNew creates a client for the GitHub API at baseURL.
+// The token is optional for public repositories.
+func ClientNew(baseURL, token string) *Client {
+	return &Client{
+		httpClient: http.DefaultClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+	}
+}
+
+type attestationsResponse struct {
+	Attestations []struct {
+		Bundle json.RawMessage `json:"bundle"`
+	} `json:"attestations"`
+}
+
+// Bundles returns the Sigstore bundles of the attestations of the
+// repository, of the form owner/name, for a sha256 digest.
+func (c *Client) Bundles(ctx context.Context, repository, digest string) ([][]byte, error) {
+	url := fmt.Sprintf("%s/repos/%s/attestations/sha256:%s", c.baseURL, repository, digest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attestations: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: repository (%q), digest (%q)", errorNotFound, repository, digest)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch attestations: status %d", resp.StatusCode)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var attestations attestationsResponse
+	if err := json.Unmarshal(content, &attestations); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	var bundles [][]byte
+	for _, att := range attestations.Attestations {
+		if len(att.Bundle) > 0 {
+			bundles = append(bundles, att.Bundle)
+		}
+	}
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("%w: repository (%q), digest (%q)", errorNotFound, repository, digest)
+	}
+	return bundles, nil
+}
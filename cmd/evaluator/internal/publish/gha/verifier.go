@@ -0,0 +1,132 @@
+package gha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// GitHubIssuer is the OIDC issuer of GitHub Actions workflows.
+const GitHubIssuer = "https://token.actions.githubusercontent.com"
+
+const provenancePredicateType = "https://slsa.dev/provenance/v1"
+
+var errorMismatch = errors.New("provenance mismatch")
+
+// Criteria are the expected properties of a provenance.
+type Criteria struct {
+	// Digest is the sha256 digest of the artifact.
+	Digest string
+	// BuilderID is the ID of the policy root. It matches the URI
+	// of the signer workflow in the certificate, without its ref, e.g.
+	// https://github.com/org/repo/.github/workflows/build.yml.
+	// NOTE: the builder ID of the predicate is not used, since
+	// any workflow may claim any builder ID.
+	BuilderID string
+	// SubjectRegex, if set, is the subject regex of a policy root
+	// defined by a certificate identity. It is used instead of
+	// BuilderID. The policy anchors it, so it matches the whole URI
+	// of the signer workflow, including its ref.
+	SubjectRegex *regexp.Regexp
+	// SourceURI is the source repository, e.g. github.com/org/repo.
+	SourceURI string
+}
+
+type statement struct {
+	PredicateType string           `json:"predicateType"`
+	Subjects      []intoto.Subject `json:"subject"`
+}
+
+// Match verifies that a verified provenance satisfies the criteria.
+func Match(prov *Provenance, c Criteria) error {
+	if prov.Identity.Issuer != GitHubIssuer {
+		return fmt.Errorf("%w: issuer (%q) != (%q)", errorMismatch, prov.Identity.Issuer, GitHubIssuer)
+	}
+	var s statement
+	if err := json.Unmarshal(prov.Statement, &s); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal statement: %w", errorMismatch, err)
+	}
+	if s.PredicateType != provenancePredicateType {
+		return fmt.Errorf("%w: predicate type (%q) != (%q)", errorMismatch, s.PredicateType, provenancePredicateType)
+	}
+	if !hasDigest(s.Subjects, c.Digest) {
+		return fmt.Errorf("%w: no subject with digest (%q)", errorMismatch, c.Digest)
+	}
+	if source := normalizeURI(prov.Identity.SourceRepositoryURI); source != normalizeURI(c.SourceURI) {
+		return fmt.Errorf("%w: source (%q) != (%q)", errorMismatch, source, c.SourceURI)
+	}
+	if c.SubjectRegex != nil {
+		if !c.SubjectRegex.MatchString(prov.Identity.SubjectURI) {
+			return fmt.Errorf("%w: signer workflow (%q) does not match (%q)", errorMismatch,
+				prov.Identity.SubjectURI, c.SubjectRegex)
+		}
+		return nil
+	}
+	workflow, _, _ := strings.Cut(prov.Identity.SubjectURI, "@")
+	if c.BuilderID != workflow {
+		return fmt.Errorf("%w: builder (%q) != signer workflow (%q)", errorMismatch, c.BuilderID, workflow)
+	}
+	return nil
+}
+
+func hasDigest(subjects []intoto.Subject, digest string) bool {
+	for _, subject := range subjects {
+		if subject.Digests["sha256"] == digest {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURI removes the scheme and the .git suffix of a repository URI.
+func normalizeURI(uri string) string {
+	uri = strings.TrimPrefix(uri, "git+")
+	uri = strings.TrimPrefix(uri, "https://")
+	return strings.TrimSuffix(uri, ".git")
+}
+
+// Verifier fetches and verifies GitHub artifact attestations.
+type Verifier struct {
+	client  *Client
+	bundles BundleVerifier
+}
+
+// VerifierNew creates a verifier that fetches bundles with client
+// and verifies them with bundles.
+func VerifierNew(client *Client, bundles BundleVerifier) *Verifier {
+	return &Verifier{
+		client:  client,
+		bundles: bundles,
+	}
+}
+
+// Verify returns the first verified provenance that satisfies the criteria.
+func (v *Verifier) Verify(ctx context.Context, c Criteria) (*Provenance, error) {
+	repository, found := strings.CutPrefix(normalizeURI(c.SourceURI), "github.com/")
+	if !found || strings.Count(repository, "/") != 1 {
+		return nil, fmt.Errorf("%w: source (%q) is not a GitHub repository", errorMismatch, c.SourceURI)
+	}
+	bundles, err := v.client.Bundles(ctx, repository, c.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for i, bundle := range bundles {
+		prov, err := v.bundles.Verify(ctx, bundle)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bundle %d: %w", i, err))
+			continue
+		}
+		if err := Match(prov, c); err != nil {
+			errs = append(errs, fmt.Errorf("bundle %d: %w", i, err))
+			continue
+		}
+		return prov, nil
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,295 @@
+package gha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const (
+	digest      = "6a2ae7e9d0a3e8a4b0b1f0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1"
+	workflowURI = "https://github.com/org/repo/.github/workflows/build.yml"
+)
+
+func readProvenance(t *testing.T) []byte {
+	content, err := os.ReadFile("testdata/provenance.json")
+	if err != nil {
+		t.Fatalf("failed to read provenance: %v", err)
+	}
+	return content
+}
+
+func Test_Match(t *testing.T) {
+	t.Parallel()
+	statement := readProvenance(t)
+	identity := Identity{
+		SubjectURI:          workflowURI + "@refs/heads/main",
+		Issuer:              GitHubIssuer,
+		SourceRepositoryURI: "https://github.com/org/repo",
+	}
+	criteria := Criteria{
+		Digest:    digest,
+		BuilderID: workflowURI,
+		SourceURI: "github.com/org/repo",
+	}
+	tests := []struct {
+		name      string
+		statement []byte
+		identity  func(Identity) Identity
+		criteria  func(Criteria) Criteria
+		expected  error
+	}{
+		{
+			name: "workflow builder",
+		},
+		{
+			// NOTE: the builder ID of the predicate is self-asserted.
+			name: "mismatch provenance builder",
+			criteria: func(c Criteria) Criteria {
+				c.BuilderID = "https://github.com/actions/runner/github-hosted"
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "source with scheme",
+			criteria: func(c Criteria) Criteria {
+				c.SourceURI = "git+https://github.com/org/repo.git"
+				return c
+			},
+		},
+		{
+			name: "mismatch builder",
+			criteria: func(c Criteria) Criteria {
+				c.BuilderID = "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml"
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "mismatch workflow ref",
+			criteria: func(c Criteria) Criteria {
+				c.BuilderID = workflowURI + "@refs/heads/main"
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "subject regex",
+			criteria: func(c Criteria) Criteria {
+				c.BuilderID = ""
+				c.SubjectRegex = regexp.MustCompile("^(?:https://github.com/org/repo/.*@refs/heads/main)$")
+				return c
+			},
+		},
+		{
+			name: "mismatch subject regex",
+			criteria: func(c Criteria) Criteria {
+				c.BuilderID = ""
+				c.SubjectRegex = regexp.MustCompile("^(?:https://github.com/org/repo/.*@refs/tags/.*)$")
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "subject regex prefix",
+			criteria: func(c Criteria) Criteria {
+				c.BuilderID = ""
+				// NOTE: the policy anchors the regex.
+				c.SubjectRegex = regexp.MustCompile("^(?:" + regexp.QuoteMeta(workflowURI) + ")$")
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "mismatch source",
+			criteria: func(c Criteria) Criteria {
+				c.SourceURI = "github.com/org/other"
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "mismatch digest",
+			criteria: func(c Criteria) Criteria {
+				c.Digest = "other"
+				return c
+			},
+			expected: errorMismatch,
+		},
+		{
+			name: "mismatch issuer",
+			identity: func(i Identity) Identity {
+				i.Issuer = "https://accounts.google.com"
+				return i
+			},
+			expected: errorMismatch,
+		},
+		{
+			name:      "mismatch predicate type",
+			statement: []byte(strings.Replace(string(statement), "https://slsa.dev/provenance/v1", "https://slsa.dev/provenance/v0.2", 1)),
+			expected:  errorMismatch,
+		},
+		{
+			name:      "invalid statement",
+			statement: []byte("not json"),
+			expected:  errorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			prov := Provenance{
+				Statement: statement,
+				Identity:  identity,
+			}
+			if tt.statement != nil {
+				prov.Statement = tt.statement
+			}
+			if tt.identity != nil {
+				prov.Identity = tt.identity(identity)
+			}
+			c := criteria
+			if tt.criteria != nil {
+				c = tt.criteria(criteria)
+			}
+			err := Match(&prov, c)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeBundleVerifier returns the provenance of the
+// bundles it knows and fails for the others.
+type fakeBundleVerifier struct {
+	provenances map[string]Provenance
+}
+
+func (v *fakeBundleVerifier) Verify(ctx context.Context, bundle []byte) (*Provenance, error) {
+	prov, exists := v.provenances[string(bundle)]
+	if !exists {
+		return nil, errorBundle
+	}
+	return &prov, nil
+}
+
+func Test_Verifier(t *testing.T) {
+	t.Parallel()
+	prov := Provenance{
+		Statement: readProvenance(t),
+		Identity: Identity{
+			SubjectURI:          workflowURI + "@refs/heads/main",
+			Issuer:              GitHubIssuer,
+			SourceRepositoryURI: "https://github.com/org/repo",
+		},
+	}
+	other := prov
+	other.Identity.SourceRepositoryURI = "https://github.com/org/other"
+	bundles := &fakeBundleVerifier{
+		provenances: map[string]Provenance{
+			`{"id":"valid"}`: prov,
+			`{"id":"other"}`: other,
+		},
+	}
+	responses := map[string]string{
+		"/repos/org/repo/attestations/sha256:" + digest:      `{"attestations":[{"bundle":{"id":"invalid"}},{"bundle":{"id":"other"}},{"bundle":{"id":"valid"}}]}`,
+		"/repos/org/invalid/attestations/sha256:" + digest:   `{"attestations":[{"bundle":{"id":"invalid"}},{"bundle":{"id":"other"}}]}`,
+		"/repos/org/empty/attestations/sha256:" + digest:     `{"attestations":[]}`,
+		"/repos/org/malformed/attestations/sha256:" + digest: `not json`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		response, exists := responses[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+	tests := []struct {
+		name      string
+		sourceURI string
+		token     string
+		found     bool
+		expected  error
+	}{
+		{
+			name:      "matching bundle",
+			sourceURI: "github.com/org/repo",
+			token:     "token",
+			found:     true,
+		},
+		{
+			name:      "no matching bundle",
+			sourceURI: "github.com/org/invalid",
+			token:     "token",
+			expected:  errorBundle,
+		},
+		{
+			name:      "no attestations",
+			sourceURI: "github.com/org/empty",
+			token:     "token",
+			expected:  errorNotFound,
+		},
+		{
+			name:      "unknown repository",
+			sourceURI: "github.com/org/unknown",
+			token:     "token",
+			expected:  errorNotFound,
+		},
+		{
+			name:      "not a github repository",
+			sourceURI: "gitlab.com/org/repo",
+			token:     "token",
+			expected:  errorMismatch,
+		},
+		{
+			name:      "malformed response",
+			sourceURI: "github.com/org/malformed",
+			token:     "token",
+			expected:  cmpopts.AnyError,
+		},
+		{
+			name:      "unauthorized",
+			sourceURI: "github.com/org/repo",
+			expected:  cmpopts.AnyError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifier := VerifierNew(ClientNew(server.URL, tt.token), bundles)
+			result, err := verifier.Verify(context.Background(), Criteria{
+				Digest:    digest,
+				BuilderID: workflowURI,
+				SourceURI: tt.sourceURI,
+			})
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if (result != nil) != tt.found {
+				t.Fatalf("unexpected result: %v", result)
+			}
+			if result != nil && !json.Valid(result.Statement) {
+				t.Fatalf("invalid statement: %s", result.Statement)
+			}
+		})
+	}
+}
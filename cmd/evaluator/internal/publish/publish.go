@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/evaluate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/stats"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/validate"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
 )
@@ -15,6 +16,7 @@ func usage(cli string) {
 		"Available options:\n" +
 		"validate \t\tValidate the policy files\n" +
 		"evaluate \t\tEvaluate the policy\n" +
+		"stats \t\t\tPrint statistics about the policy\n" +
 		"\n"
 	utils.Log(msg, cli)
 	os.Exit(1)
@@ -32,6 +34,8 @@ func Run(cli string, args []string) error {
 		err = validate.Run(cli, args[1:])
 	case "evaluate":
 		err = evaluate.Run(cli, args[1:])
+	case "stats":
+		err = stats.Run(cli, args[1:])
 	}
 	return err
 }
@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files_reader"
+)
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s publish stats orgPath projectsPath\n" +
+		"\n" +
+		"Example:\n" +
+		"%s publish stats ./path/to/policy/org ./path/to/policy/projects\n" +
+		"\n"
+	utils.Log(msg, cli, cli)
+	os.Exit(1)
+}
+
+func Run(cli string, args []string) error {
+	// We need 2 paths:
+	// 1. Path to org policy
+	// 2. Path to project policy.
+	if len(args) != 2 {
+		usage(cli)
+	}
+	orgPath := args[0]
+	projectsPath, err := utils.ReadFiles(args[1], orgPath)
+	if err != nil {
+		return err
+	}
+	// Create a policy.
+	projectsReader := files_reader.FromPaths(projectsPath)
+	organizationReader, err := os.Open(orgPath)
+	if err != nil {
+		return err
+	}
+	pol, err := publish.PolicyNew(organizationReader, projectsReader, &utils.PackageHelper{}, publish.SetValidator(&validate.PolicyValidator{}))
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(pol.Stats(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	fmt.Println(string(content))
+	return nil
+}
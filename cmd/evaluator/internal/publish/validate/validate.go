@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/publish"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files_reader"
 )
@@ -41,9 +42,33 @@ func Run(cli string, args []string) error {
 	// Create a policy. This will validate the files.
 	projectsReader := files_reader.FromPaths(projectsPath)
 	organizationReader, err := os.Open(orgPath)
+	if err != nil {
+		return fmt.Errorf("failed to read org path: %w", err)
+	}
 	_, err = publish.PolicyNew(organizationReader, projectsReader, &utils.PackageHelper{}, publish.SetValidator(&PolicyValidator{}))
 	if err != nil {
-		return err
+		// Print every violation, one per line.
+		violations := errs.Violations(err)
+		for _, violation := range violations {
+			utils.Log("%v\n", violation)
+		}
+		return fmt.Errorf("%d policy violation(s)", len(violations))
 	}
 	return nil
 }
+
+// LoadPolicyDir creates a policy from the files under policyDir. The org
+// policy is excluded from the project policies if it is under policyDir.
+// The options are applied in addition to the validator.
+func LoadPolicyDir(policyDir, orgPath string, opts ...publish.PolicyOption) (*publish.Policy, error) {
+	organizationReader, projectsReader, err := utils.OpenPolicyDir(policyDir, orgPath)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]publish.PolicyOption{publish.SetValidator(&PolicyValidator{})}, opts...)
+	pol, err := publish.PolicyNewFromIterator(organizationReader, projectsReader, &utils.PackageHelper{}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy: %w", err)
+	}
+	return pol, nil
+}
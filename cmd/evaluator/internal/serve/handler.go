@@ -0,0 +1,143 @@
+package serve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+)
+
+// maxRequestSize is the maximum size of a request body.
+const maxRequestSize = 1 << 20
+
+// policies are the policies the server evaluates requests against.
+// A nil policy is not configured.
+type policies struct {
+	publish    *publish.Policy
+	deployment *deployment.Policy
+}
+
+// server evaluates requests against the last loaded policies.
+// It is safe for concurrent use if its verifiers are.
+type server struct {
+	load     func() (*policies, error)
+	policies atomic.Pointer[policies]
+	// newPublishVerifier creates the verifier of each request, since
+	// the verifier keeps the provenances it verified for BaseImages.
+	// Sharing it would grow its cache for the lifetime of the server.
+	newPublishVerifier func() publish.AttestationVerifier
+	deploymentVerifier deployment.AttestationVerifier
+	// metrics, if set, serves the metrics of the evaluations.
+	metrics http.Handler
+}
+
+// reload loads the policies. The previous policies
+// are kept if they cannot be loaded.
+func (s *server) reload() error {
+	pols, err := s.load()
+	if err != nil {
+		return err
+	}
+	s.policies.Store(pols)
+	return nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/publish/evaluate", s.publishEvaluate)
+	mux.HandleFunc("POST /v1/deployment/evaluate", s.deploymentEvaluate)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if s.policies.Load() == nil {
+			http.Error(w, "policies not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	if s.metrics != nil {
+		mux.Handle("GET /metrics", s.metrics)
+	}
+	return mux
+}
+
+func (s *server) publishEvaluate(w http.ResponseWriter, r *http.Request) {
+	var req utils.PublishRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, utils.Decision{Package: req.Package}, err)
+		return
+	}
+	// denied is the decision if the request cannot be evaluated.
+	denied := utils.Decision{
+		Package: req.Package,
+		Digests: req.Digests,
+	}
+	pols := s.policies.Load()
+	if pols == nil || pols.publish == nil {
+		writeError(w, http.StatusNotFound, denied, fmt.Errorf("%w: publish policy not configured", errs.ErrorNotFound))
+		return
+	}
+	results, err := pols.publish.EvaluateBatch([]publish.EvaluationRequest{req.EvaluationRequest()},
+		publish.BatchOptions{Verifier: s.newPublishVerifier()})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, denied, err)
+		return
+	}
+	writeDecision(w, http.StatusOK, req.Decision(results[0]))
+}
+
+func (s *server) deploymentEvaluate(w http.ResponseWriter, r *http.Request) {
+	var req utils.DeploymentRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, utils.Decision{Package: req.Package}, err)
+		return
+	}
+	// denied is the decision if the request cannot be evaluated.
+	denied := utils.Decision{
+		Package:  req.Package,
+		Digests:  req.Digests,
+		PolicyID: req.PolicyID,
+	}
+	pols := s.policies.Load()
+	if pols == nil || pols.deployment == nil {
+		writeError(w, http.StatusNotFound, denied, fmt.Errorf("%w: deployment policy not configured", errs.ErrorNotFound))
+		return
+	}
+	// NOTE: the batch verifies the scopes of the request.
+	results, err := pols.deployment.EvaluateBatch([]deployment.EvaluationRequest{req.EvaluationRequest()},
+		deployment.BatchOptions{Verifier: s.deploymentVerifier})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, denied, err)
+		return
+	}
+	writeDecision(w, http.StatusOK, req.Decision(results[0]))
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) error {
+	err := utils.DecodeRequest(http.MaxBytesReader(w, r.Body, maxRequestSize), req)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return fmt.Errorf("%w: request larger than (%d) bytes", errs.ErrorInvalidInput, maxErr.Limit)
+	}
+	return err
+}
+
+// writeError writes a deny decision for err.
+func writeError(w http.ResponseWriter, status int, decision utils.Decision, err error) {
+	decision.SetError(err)
+	writeDecision(w, status, decision)
+}
+
+func writeDecision(w http.ResponseWriter, status int, decision utils.Decision) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := decision.Write(w); err != nil {
+		utils.Log("failed to write decision: %v\n", err)
+	}
+}
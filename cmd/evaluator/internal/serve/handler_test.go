@@ -0,0 +1,378 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	deploymentvalidate "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	publishvalidate "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func newPolicyDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	writePolicyDir(t, dir, files)
+	return dir
+}
+
+func writePolicyDir(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+// Digests of the packages the verifiers accept and reject.
+const (
+	goodDigest = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	badDigest  = "cb8379ac2098aa165029e3938a51da0bcecfc008fd6795f401178647f96c5b34"
+)
+
+// buildVerifier accepts the build attestations of
+// the packages whose sha256 digest is goodDigest.
+type buildVerifier struct{}
+
+func (v *buildVerifier) VerifyBuildAttestation(digests intoto.DigestSet, packageName, builderID, sourceURI string,
+	opts publish.AttestationVerifierBuildOptions) error {
+	if digests["sha256"] != goodDigest {
+		return fmt.Errorf("%w: no build attestation", errs.ErrorVerification)
+	}
+	return nil
+}
+
+func (v *buildVerifier) BaseImages(digests intoto.DigestSet, packageName string) ([]string, error) {
+	return nil, nil
+}
+
+// publishVerifier accepts the publish attestations of
+// the packages whose sha256 digest is goodDigest.
+type publishVerifier struct{}
+
+func (v *publishVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	if digests["sha256"] != goodDigest {
+		return nil, fmt.Errorf("%w: no publish attestation", errs.ErrorVerification)
+	}
+	return nil, nil
+}
+
+const (
+	publishOrg = `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3}]}}`
+	publishProject = `{"format": 1, "package": {"name": "docker.io/org/server", "environment": {"any_of": ["prod"]}},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "github.com/org/server"}}}`
+	deploymentOrg = `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	// NOTE: the principal is a format argument.
+	deploymentProject = `{"format": 1, "principal": {"uri": %q}, "build": {"require_slsa_level": 3},
+		"packages": [{"name": "docker.io/org/server"}]}`
+)
+
+func newServer(t *testing.T, publishDir, deploymentDir string) *server {
+	s := &server{
+		load: func() (*policies, error) {
+			var pols policies
+			var err error
+			if publishDir != "" {
+				if pols.publish, err = publishvalidate.LoadPolicyDir(publishDir, ""); err != nil {
+					return nil, err
+				}
+			}
+			if deploymentDir != "" {
+				if pols.deployment, err = deploymentvalidate.LoadPolicyDir(deploymentDir, ""); err != nil {
+					return nil, err
+				}
+			}
+			return &pols, nil
+		},
+		newPublishVerifier: func() publish.AttestationVerifier { return &buildVerifier{} },
+		deploymentVerifier: &publishVerifier{},
+	}
+	if err := s.reload(); err != nil {
+		t.Fatalf("failed to load policies: %v", err)
+	}
+	return s
+}
+
+func post(t *testing.T, handler http.Handler, path, body string) (int, utils.Decision) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var decision utils.Decision
+	if rec.Code != http.StatusMethodNotAllowed {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decision); err != nil {
+			t.Fatalf("failed to unmarshal (%q): %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code, decision
+}
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+	s := newServer(t, newPolicyDir(t, map[string]string{
+		"org.json":         publishOrg,
+		"servers/api.json": publishProject,
+	}), newPolicyDir(t, map[string]string{
+		"org.json":          deploymentOrg,
+		"servers/prod.json": fmt.Sprintf(deploymentProject, "principal_uri"),
+	}))
+	handler := s.handler()
+	scope := deployment.ScopeKeyKubernetesServiceAccount
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		status   int
+		decision string
+		// category is the category of the error, if any.
+		category string
+		scopes   map[string]string
+	}{
+		{
+			name:     "publish allow",
+			path:     "/v1/publish/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "` + goodDigest + `"}, "environment": "prod"}`,
+			status:   http.StatusOK,
+			decision: "allow",
+		},
+		{
+			name:     "publish deny verification",
+			path:     "/v1/publish/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "` + badDigest + `"}, "environment": "prod"}`,
+			status:   http.StatusOK,
+			decision: "deny",
+			category: "verification",
+		},
+		{
+			name:     "publish deny invalid digest",
+			path:     "/v1/publish/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "abc"}, "environment": "prod"}`,
+			status:   http.StatusOK,
+			decision: "deny",
+			category: "invalid_input",
+		},
+		{
+			name:     "publish deny environment",
+			path:     "/v1/publish/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "` + goodDigest + `"}, "environment": "dev"}`,
+			status:   http.StatusOK,
+			decision: "deny",
+			category: "not_found",
+		},
+		{
+			name:     "deployment allow",
+			path:     "/v1/deployment/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "` + goodDigest + `"}, "policy_id": "servers/prod.json"}`,
+			status:   http.StatusOK,
+			decision: "allow",
+			scopes:   map[string]string{scope: "principal_uri"},
+		},
+		{
+			name: "deployment allow scopes",
+			path: "/v1/deployment/evaluate",
+			body: `{"package": "docker.io/org/server", "digests": {"sha256": "` + goodDigest + `"}, "policy_id": "servers/prod.json",
+				"scopes": {"kubernetes.io/pod/service_account/v1": "principal_uri"}}`,
+			status:   http.StatusOK,
+			decision: "allow",
+			scopes:   map[string]string{scope: "principal_uri"},
+		},
+		{
+			name: "deployment deny scopes",
+			path: "/v1/deployment/evaluate",
+			body: `{"package": "docker.io/org/server", "digests": {"sha256": "` + goodDigest + `"}, "policy_id": "servers/prod.json",
+				"scopes": {"kubernetes.io/pod/service_account/v1": "other_principal_uri"}}`,
+			status:   http.StatusOK,
+			decision: "deny",
+			category: "mismatch",
+		},
+		{
+			name:     "deployment deny verification",
+			path:     "/v1/deployment/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "` + badDigest + `"}, "policy_id": "servers/prod.json"}`,
+			status:   http.StatusOK,
+			decision: "deny",
+			category: "verification",
+		},
+		{
+			name:     "deployment unknown policy",
+			path:     "/v1/deployment/evaluate",
+			body:     `{"package": "docker.io/org/server", "digests": {"sha256": "` + goodDigest + `"}, "policy_id": "servers/dev.json"}`,
+			status:   http.StatusOK,
+			decision: "deny",
+			category: "not_found",
+		},
+		{
+			name:     "malformed request",
+			path:     "/v1/deployment/evaluate",
+			body:     `{"package": "docker.io/org/server"`,
+			status:   http.StatusBadRequest,
+			decision: "deny",
+			category: "invalid_input",
+		},
+		{
+			name:     "unknown request field",
+			path:     "/v1/publish/evaluate",
+			body:     `{"package": "docker.io/org/server", "digest": {"sha256": "` + goodDigest + `"}}`,
+			status:   http.StatusBadRequest,
+			decision: "deny",
+			category: "invalid_input",
+		},
+		{
+			name:     "request too large",
+			path:     "/v1/publish/evaluate",
+			body:     `{"package": "` + strings.Repeat("a", maxRequestSize) + `"}`,
+			status:   http.StatusBadRequest,
+			decision: "deny",
+			category: "invalid_input",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			status, decision := post(t, handler, tt.path, tt.body)
+			if diff := cmp.Diff(tt.status, status); diff != "" {
+				t.Fatalf("unexpected status (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.decision, decision.Decision); diff != "" {
+				t.Fatalf("unexpected decision (-want +got): \n%s", diff)
+			}
+			category := ""
+			if decision.Error != nil {
+				category = decision.Error.Category
+			}
+			if diff := cmp.Diff(tt.category, category); diff != "" {
+				t.Fatalf("unexpected category (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.scopes, decision.Scopes); diff != "" {
+				t.Fatalf("unexpected scopes (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_NotConfigured(t *testing.T) {
+	t.Parallel()
+	s := newServer(t, "", newPolicyDir(t, map[string]string{
+		"org.json":          deploymentOrg,
+		"servers/prod.json": fmt.Sprintf(deploymentProject, "principal_uri"),
+	}))
+	status, decision := post(t, s.handler(), "/v1/publish/evaluate",
+		`{"package": "docker.io/org/server", "digests": {"sha256": "`+goodDigest+`"}}`)
+	if diff := cmp.Diff(http.StatusNotFound, status); diff != "" {
+		t.Fatalf("unexpected status (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("not_found", decision.Error.Category); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+	// Only POST evaluates.
+	req := httptest.NewRequest(http.MethodGet, "/v1/deployment/evaluate", nil)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	if diff := cmp.Diff(http.StatusMethodNotAllowed, rec.Code); diff != "" {
+		t.Fatalf("unexpected status (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Probes(t *testing.T) {
+	t.Parallel()
+	s := &server{}
+	handler := s.handler()
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	if diff := cmp.Diff(http.StatusOK, get("/healthz")); diff != "" {
+		t.Fatalf("unexpected liveness (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff(http.StatusServiceUnavailable, get("/readyz")); diff != "" {
+		t.Fatalf("unexpected readiness (-want +got): \n%s", diff)
+	}
+	s.policies.Store(&policies{})
+	if diff := cmp.Diff(http.StatusOK, get("/readyz")); diff != "" {
+		t.Fatalf("unexpected readiness (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Reload(t *testing.T) {
+	t.Parallel()
+	dir := newPolicyDir(t, map[string]string{
+		"org.json":          deploymentOrg,
+		"servers/prod.json": fmt.Sprintf(deploymentProject, "principal_uri"),
+	})
+	s := newServer(t, "", dir)
+	handler := s.handler()
+	principal := func() string {
+		_, decision := post(t, handler, "/v1/deployment/evaluate",
+			`{"package": "docker.io/org/server", "digests": {"sha256": "`+goodDigest+`"}, "policy_id": "servers/prod.json"}`)
+		return decision.PrincipalURI
+	}
+	if diff := cmp.Diff("principal_uri", principal()); diff != "" {
+		t.Fatalf("unexpected principal (-want +got): \n%s", diff)
+	}
+
+	// A valid policy replaces the previous one.
+	writePolicyDir(t, dir, map[string]string{
+		"servers/prod.json": fmt.Sprintf(deploymentProject, "new_principal_uri"),
+	})
+	if err := s.reload(); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if diff := cmp.Diff("new_principal_uri", principal()); diff != "" {
+		t.Fatalf("unexpected principal (-want +got): \n%s", diff)
+	}
+
+	// An invalid policy keeps the previous one.
+	writePolicyDir(t, dir, map[string]string{
+		"servers/prod.json": `{"format": 1, "principal": {}}`,
+	})
+	if err := s.reload(); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if diff := cmp.Diff("new_principal_uri", principal()); diff != "" {
+		t.Fatalf("unexpected principal (-want +got): \n%s", diff)
+	}
+}
+
+func Test_PublishVerifierPerRequest(t *testing.T) {
+	t.Parallel()
+	s := newServer(t, newPolicyDir(t, map[string]string{
+		"org.json":         publishOrg,
+		"servers/api.json": publishProject,
+	}), "")
+	var verifiers []publish.AttestationVerifier
+	s.newPublishVerifier = func() publish.AttestationVerifier {
+		v := &buildVerifier{}
+		verifiers = append(verifiers, v)
+		return v
+	}
+	handler := s.handler()
+	for i := 0; i < 2; i++ {
+		_, decision := post(t, handler, "/v1/publish/evaluate",
+			`{"package": "docker.io/org/server", "digests": {"sha256": "`+goodDigest+`"}, "environment": "prod"}`)
+		if diff := cmp.Diff("allow", decision.Decision); diff != "" {
+			t.Fatalf("unexpected decision (-want +got): \n%s", diff)
+		}
+	}
+	// NOTE: the verifier keeps the provenances it verifies,
+	// so no verifier is shared between requests.
+	if diff := cmp.Diff(2, len(verifiers)); diff != "" {
+		t.Fatalf("unexpected verifiers (-want +got): \n%s", diff)
+	}
+}
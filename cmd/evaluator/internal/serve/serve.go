@@ -0,0 +1,155 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	deploymentevaluate "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/evaluate"
+	deploymentvalidate "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	publishevaluate "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/evaluate"
+	publishvalidate "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	utilsprometheus "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/prometheus"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+)
+
+// shutdownTimeout is the time in-flight requests
+// have to complete when the server stops.
+const shutdownTimeout = 30 * time.Second
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s serve [--listen address] [--publish-policy-dir path [--publish-org path]] [--deployment-policy-dir path [--deployment-org path]]\n" +
+		"\n" +
+		"Serves the evaluation of the policies over HTTP. At least one policy directory is required.\n" +
+		"The org policy defaults to org.json, org.yaml or org.yml under the policy directory.\n" +
+		"The policies are reloaded on SIGHUP. The previous policies are kept if they are invalid.\n" +
+		"The server stops gracefully on SIGINT or SIGTERM.\n" +
+		"\n" +
+		"Endpoints:\n" +
+		"POST /v1/publish/evaluate\t{\"package\": \"docker.io/org/server\", \"digests\": {\"sha256\": \"...\"}, \"environment\": \"prod\"}\n" +
+		"POST /v1/deployment/evaluate\t{\"package\": \"docker.io/org/server\", \"digests\": {\"sha256\": \"...\"}, \"policy_id\": \"servers/prod.json\",\n" +
+		"                            \t \"scopes\": {\"kubernetes.io/pod/service_account/v1\": \"...\"}}\n" +
+		"GET /healthz             \tLiveness of the server.\n" +
+		"GET /readyz              \tReadiness of the server, once the policies are loaded.\n" +
+		"GET /metrics             \tPrometheus metrics of the evaluations, by decision and error code.\n" +
+		"\n" +
+		"The evaluation endpoints respond with the decision printed by 'evaluate --format json'.\n" +
+		"\n" +
+		"Options:\n" +
+		"--listen \t\tAddress to listen on. Defaults to :8080.\n" +
+		"\n" +
+		"Example:\n" +
+		"%s serve --listen :8080 --publish-policy-dir ./publish/policy --deployment-policy-dir ./deployment/policy\n" +
+		"\n"
+	utils.Log(msg, cli, cli)
+	os.Exit(1)
+}
+
+func Run(cli string, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() { usage(cli) }
+	listen := fs.String("listen", ":8080", "address to listen on")
+	publishDir := fs.String("publish-policy-dir", "", "path to the publish policy directory")
+	publishOrg := fs.String("publish-org", "", "path to the publish org policy")
+	deploymentDir := fs.String("deployment-policy-dir", "", "path to the deployment policy directory")
+	deploymentOrg := fs.String("deployment-org", "", "path to the deployment org policy")
+	if err := fs.Parse(args); err != nil {
+		usage(cli)
+	}
+	if fs.NArg() != 0 {
+		usage(cli)
+	}
+	if *publishDir == "" && *deploymentDir == "" {
+		return fmt.Errorf("--publish-policy-dir or --deployment-policy-dir is required")
+	}
+	if *publishOrg != "" && *publishDir == "" {
+		return fmt.Errorf("--publish-org requires --publish-policy-dir")
+	}
+	if *deploymentOrg != "" && *deploymentDir == "" {
+		return fmt.Errorf("--deployment-org requires --deployment-policy-dir")
+	}
+	registry := prometheus.NewRegistry()
+	metrics, err := utilsprometheus.MetricsNew(registry)
+	if err != nil {
+		return err
+	}
+	s := &server{
+		load: func() (*policies, error) {
+			var pols policies
+			var err error
+			if *publishDir != "" {
+				if pols.publish, err = publishvalidate.LoadPolicyDir(*publishDir, *publishOrg,
+					publish.SetMetrics(metrics)); err != nil {
+					return nil, fmt.Errorf("publish policy: %w", err)
+				}
+			}
+			if *deploymentDir != "" {
+				if pols.deployment, err = deploymentvalidate.LoadPolicyDir(*deploymentDir, *deploymentOrg,
+					deployment.SetMetrics(metrics)); err != nil {
+					return nil, fmt.Errorf("deployment policy: %w", err)
+				}
+			}
+			return &pols, nil
+		},
+		newPublishVerifier: publishevaluate.NewBuildVerifier,
+		deploymentVerifier: deploymentevaluate.NewPublishVerifier(),
+		metrics:            promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	}
+	if err := s.reload(); err != nil {
+		return err
+	}
+	return serve(s, *listen)
+}
+
+// serve serves the requests until SIGINT or SIGTERM,
+// and reloads the policies on SIGHUP.
+func serve(s *server, listen string) error {
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	serveErr := make(chan error, 1)
+	go func() {
+		utils.Log("listening on %s\n", listen)
+		serveErr <- srv.ListenAndServe()
+	}()
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				if err := s.reload(); err != nil {
+					utils.Log("failed to reload policies: %v\n", err)
+					continue
+				}
+				utils.Log("policies reloaded\n")
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := srv.Shutdown(ctx); err != nil {
+				return fmt.Errorf("failed to shut down: %w", err)
+			}
+			if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package crypto
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"time"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils/tlog"
 	"github.com/slsa-framework/slsa-policy/pkg/publish"
 	"github.com/sigstore/cosign/v2/cmd/cosign/cli/options"
 	"github.com/sigstore/cosign/v2/cmd/cosign/cli/rekor"
@@ -22,37 +22,35 @@ import (
 	"github.com/sigstore/cosign/v2/pkg/oci/static"
 	cpolicy "github.com/sigstore/cosign/v2/pkg/policy"
 	"github.com/sigstore/cosign/v2/pkg/types"
-	"github.com/sigstore/sigstore/pkg/signature/dsse"
-	signatureoptions "github.com/sigstore/sigstore/pkg/signature/options"
 )
 
 // This file is copied from https://github.com/sigstore/cosign/blob/main/cmd/cosign/cli/attest/attest.go and
 // https://github.com/sigstore/cosign/blob/main/cmd/cosign/cli/verify/verify_attestation.go
 var ko = options.KeyOpts{
 	FulcioURL: "https://fulcio.sigstore.dev",
-	RekorURL:  "https://rekor.sigstore.dev",
+	RekorURL:  tlog.DefaultRekorURL,
 	// Don't ask for confirmation to create a certificate.
 	SkipConfirmation: true,
 }
 
 var githubIssuer = "https://token.actions.githubusercontent.com"
 
-func uploadToTlog(ctx context.Context, sv *clisign.SignerVerifier, signature []byte, rekorURL string) (*cbundle.RekorBundle, error) {
+func uploadToTlog(ctx context.Context, sv *clisign.SignerVerifier, signature []byte, rekorURL string) (*cbundle.RekorBundle, string, error) {
 	rekorBytes, err := sv.Bytes(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	rekorClient, err := rekor.NewClient(rekorURL)
+	rekorClient, err := tlog.RekorNew(rekorURL, rekorBytes, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	entry, err := cosign.TLogUploadDSSEEnvelope(ctx, rekorClient, signature, rekorBytes)
+	entry, entryID, err := rekorClient.UploadEntry(ctx, signature)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	utils.Log("tlog entry created with index: %v\n", *entry.LogIndex)
-	return cbundle.EntryToBundle(entry), nil
+	return cbundle.EntryToBundle(entry), entryID, nil
 }
 
 type Attestation interface {
@@ -60,11 +58,14 @@ type Attestation interface {
 	PredicateType() string
 }
 
-func Sign(att Attestation, immutableImage string) error {
+// Sign signs the attestation keyless, records it in the transparency log
+// and attaches it to the image. It returns the signed DSSE envelope and
+// the ID of the log entry.
+func Sign(att Attestation, immutableImage string) ([]byte, string, error) {
 	// Retrieve the attestation bytes.
 	attBytes, err := att.ToBytes()
 	if err != nil {
-		return fmt.Errorf("failed to get attestation bytes: %w", err)
+		return nil, "", fmt.Errorf("failed to get attestation bytes: %w", err)
 	}
 
 	// Set up the context.
@@ -88,23 +89,25 @@ func Sign(att Attestation, immutableImage string) error {
 	// Create the signer.
 	sv, err := clisign.SignerFromKeyOpts(ctx, "", "", ko)
 	if err != nil {
-		return fmt.Errorf("failed to get signer: %w", err)
+		return nil, "", fmt.Errorf("failed to get signer: %w", err)
 	}
 	defer sv.Close()
 
-	// Create the DSSE signer wrapper.
-	wrapped := dsse.WrapSigner(sv, types.IntotoPayloadType)
-	signedPayload, err := wrapped.SignMessage(bytes.NewReader(attBytes), signatureoptions.WithContext(ctx))
+	// Create the DSSE envelope.
+	signedPayload, err := signEnvelope(ctx, sv, attBytes)
 	if err != nil {
-		return fmt.Errorf("failed to sign: %w", err)
+		return nil, "", err
 	}
 	// Upload to TLog.
-	bundle, err := uploadToTlog(ctx, sv, signedPayload, ko.RekorURL)
+	bundle, entryID, err := uploadToTlog(ctx, sv, signedPayload, ko.RekorURL)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	return attach(immutableImage, att, bundle, signedPayload, sv)
+	if err := attach(immutableImage, att, bundle, signedPayload, sv); err != nil {
+		return nil, "", err
+	}
+	return signedPayload, entryID, nil
 }
 
 func attach(immutableImage string, att Attestation, bundle *cbundle.RekorBundle, signedPayload []byte, sv *clisign.SignerVerifier) error {
@@ -162,6 +165,15 @@ func ValidateIdentity(publishrID, publishrIDRegex string) error {
 	return nil
 }
 
+// ValidateIssuer validates the OIDC issuer of a certificate identity.
+// Only GitHub Actions is supported.
+func ValidateIssuer(issuer string) error {
+	if issuer != githubIssuer {
+		return fmt.Errorf("issuer (%q) is not supported. Must be (%q)", issuer, githubIssuer)
+	}
+	return nil
+}
+
 func getIdentity(publishrID, publishrIDRegex string) (*cosign.Identity, error) {
 	if err := ValidateIdentity(publishrID, publishrIDRegex); err != nil {
 		return nil, err
@@ -237,22 +249,26 @@ func VerifySignature(immutableImage string, publishrID, publishrIDRegex string)
 		return "", nil, fmt.Errorf("failed to verify bundle")
 	}
 	var errList []error
-	for _, vp := range verified {
-		payload, predicateType, err := cpolicy.AttestationToPayloadJSON(ctx, publish.PredicateType(), vp)
-		if err != nil {
-			errList = append(errList, fmt.Errorf("failed to convert to consumable policy validation: %w", err))
-			continue
-		}
-		if len(payload) == 0 {
-			// This is not the predicate type we're looking for.
-			continue
-		}
-		if publish.PredicateType() != predicateType {
-			errList = append(errList, fmt.Errorf("internal error. predicate ype (%q) != attestation type (%q)",
-				predicateType, publish.PredicateType()))
-			continue
+	// NOTE: attestations of a previous predicate version are accepted,
+	// and translated by the publish package.
+	for _, wantType := range publish.PredicateTypes() {
+		for _, vp := range verified {
+			payload, predicateType, err := cpolicy.AttestationToPayloadJSON(ctx, wantType, vp)
+			if err != nil {
+				errList = append(errList, fmt.Errorf("failed to convert to consumable policy validation: %w", err))
+				continue
+			}
+			if len(payload) == 0 {
+				// This is not the predicate type we're looking for.
+				continue
+			}
+			if wantType != predicateType {
+				errList = append(errList, fmt.Errorf("internal error. predicate ype (%q) != attestation type (%q)",
+					predicateType, wantType))
+				continue
+			}
+			return publishrID, payload, nil
 		}
-		return publishrID, payload, nil
 	}
 	return "", nil, fmt.Errorf("failed to verify: %v", errList)
 }
@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	clisign "github.com/sigstore/cosign/v2/cmd/cosign/cli/sign"
+	sigs "github.com/sigstore/cosign/v2/pkg/signature"
+	"github.com/sigstore/cosign/v2/pkg/types"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+	signatureoptions "github.com/sigstore/sigstore/pkg/signature/options"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// SignerNew returns the attestation signer of the signing mode,
+// or nil if mode is empty. kmsKey is the reference of the key
+// used by the kms mode, e.g., gcpkms://projects/[...]/cryptoKeys/key.
+func SignerNew(ctx context.Context, mode, kmsKey string) (intoto.AttestationSigner, error) {
+	if err := utils.ValidateSigning(mode, kmsKey); err != nil {
+		return nil, err
+	}
+	switch mode {
+	case utils.SignKeyless:
+		return &keylessSigner{}, nil
+	case utils.SignKMS:
+		sv, err := sigs.SignerVerifierFromKeyRef(ctx, kmsKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kms key (%q): %w", kmsKey, err)
+		}
+		return &kmsSigner{sv: sv}, nil
+	}
+	return nil, nil
+}
+
+// keylessSigner signs with an ephemeral key certified by Fulcio
+// and records the signature in Rekor.
+//
+// NOTE: it obtains the key and certificate from cosign's CLI package,
+// like the signing of attestations attached to images does, see
+// crypto.go. cosign is already a dependency of the evaluator and
+// its flow handles the OIDC token of the CI. Moving to sigstore-go
+// would add a second Sigstore client, so both signers must move
+// together once the evaluator depends on it.
+type keylessSigner struct{}
+
+func (s *keylessSigner) Sign(ctx context.Context, statement []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	sv, err := clisign.SignerFromKeyOpts(ctx, "", "", ko)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signer: %w", err)
+	}
+	defer sv.Close()
+	envelope, err := signEnvelope(ctx, sv, statement)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := uploadToTlog(ctx, sv, envelope, ko.RekorURL); err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// IsKeyless returns whether the signer signs keyless, see Sign().
+func IsKeyless(signer intoto.AttestationSigner) bool {
+	_, ok := signer.(*keylessSigner)
+	return ok
+}
+
+// kmsSigner signs with a key managed by a KMS.
+type kmsSigner struct {
+	sv signature.SignerVerifier
+}
+
+func (s *kmsSigner) Sign(ctx context.Context, statement []byte) ([]byte, error) {
+	return signEnvelope(ctx, s.sv, statement)
+}
+
+func signEnvelope(ctx context.Context, signer signature.Signer, statement []byte) ([]byte, error) {
+	wrapped := dsse.WrapSigner(signer, types.IntotoPayloadType)
+	envelope, err := wrapped.SignMessage(bytes.NewReader(statement), signatureoptions.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	return envelope, nil
+}
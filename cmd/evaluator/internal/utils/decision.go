@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
+)
+
+// Output formats of the commands.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ValidateFormat returns an error if format is not a supported output format.
+func ValidateFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("invalid format (%q). Must be %q or %q", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// Decision is the machine-readable output of an evaluation.
+// NOTE: The fields are part of the CLI interface, do not rename them.
+type Decision struct {
+	// Decision is either "allow" or "deny".
+	Decision string           `json:"decision"`
+	Package  string           `json:"package"`
+	Digests  intoto.DigestSet `json:"digests,omitempty"`
+	// PolicyID is the ID of the matched project policy.
+	PolicyID string `json:"policy_id,omitempty"`
+	// SlsaLevel is the SLSA level of a publish decision.
+	SlsaLevel *int `json:"slsa_level,omitempty"`
+	// PrincipalURI is the principal of a deployment decision.
+	PrincipalURI string `json:"principal_uri,omitempty"`
+	// Scopes are the scopes of a deployment decision.
+	Scopes map[string]string `json:"scopes,omitempty"`
+	// Attestation is the path or reference of the created attestation.
+	Attestation string `json:"attestation,omitempty"`
+	// LogEntryID is the ID of the transparency log entry
+	// of the signed attestation.
+	LogEntryID string         `json:"log_entry_id,omitempty"`
+	Error      *DecisionError `json:"error,omitempty"`
+	// Trace is the evaluation trace, if requested.
+	Trace *trace.Trace `json:"trace,omitempty"`
+}
+
+// DecisionError describes the failure of an evaluation.
+type DecisionError struct {
+	// Category is the category of the error, see errs.Category.
+	Category string `json:"category"`
+	// Code is the code of the error, see errs.Code.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SetError records err in the decision. A decision
+// with an error is always a deny decision.
+func (d *Decision) SetError(err error) {
+	d.Decision = "deny"
+	d.Error = &DecisionError{
+		Category: errs.Category(err),
+		Code:     errs.Code(err),
+		Message:  err.Error(),
+	}
+}
+
+// Write writes the decision as a single JSON object.
+func (d *Decision) Write(w io.Writer) error {
+	content, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to marshal decision: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func Test_DecisionWrite(t *testing.T) {
+	t.Parallel()
+	level := 3
+	digests := intoto.DigestSet{
+		"sha256": "some_value",
+	}
+	tests := []struct {
+		name     string
+		decision Decision
+		err      error
+	}{
+		{
+			name: "publish allow",
+			decision: Decision{
+				Decision:    "allow",
+				Package:     "docker.io/org/server",
+				Digests:     digests,
+				SlsaLevel:   &level,
+				Attestation: "docker.io/org/server@sha256:some_value",
+				LogEntryID:  "24296fb24b8ad77a",
+			},
+		},
+		{
+			name: "deployment allow",
+			decision: Decision{
+				Decision:     "allow",
+				Package:      "docker.io/org/server",
+				Digests:      digests,
+				PolicyID:     "servers-prod.json",
+				PrincipalURI: "k8s://ns/sa",
+				Attestation:  "docker.io/org/server@sha256:some_value",
+			},
+		},
+		{
+			name: "deployment deny",
+			decision: Decision{
+				Decision: "allow",
+				Package:  "docker.io/org/server",
+				Digests:  digests,
+				PolicyID: "servers-prod.json",
+			},
+			err: fmt.Errorf("[project] %w: no publish attestation", errs.ErrorVerification),
+		},
+		{
+			name: "deployment deny with trace",
+			decision: Decision{
+				Decision: "allow",
+				Package:  "docker.io/org/server",
+				Digests:  digests,
+				PolicyID: "servers-prod.json",
+				Trace: &trace.Trace{
+					Steps: []trace.Step{
+						{
+							PolicyID: "servers-prod.json", Package: "docker.io/org/server", RootID: "publishr_id1",
+							Outcome: trace.OutcomeRejected, Reason: trace.ReasonLevelTooLow,
+							Error: "root level (2) below required level (3)",
+						},
+						{
+							PolicyID: "servers-prod.json", Package: "docker.io/org/server", RootID: "publishr_id2",
+							Environments: []string{"prod"}, Outcome: trace.OutcomeRejected,
+							Reason: trace.ReasonVerifierFailure, Error: "verification error: no publish attestation",
+						},
+					},
+				},
+			},
+			err: fmt.Errorf("[project] %w: no publish attestation", errs.ErrorVerification),
+		},
+		{
+			name: "invalid input",
+			decision: Decision{
+				Package: "docker.io/org/server",
+			},
+			err: fmt.Errorf("failed to parse image reference"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			decision := tt.decision
+			if tt.err != nil {
+				decision.SetError(tt.err)
+			}
+			var out bytes.Buffer
+			if err := decision.Write(&out); err != nil {
+				t.Fatalf("failed to write decision: %v", err)
+			}
+			golden := filepath.Join("testdata", "decision", strings.ReplaceAll(tt.name, " ", "_")+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o600); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if diff := cmp.Diff(string(expected), out.String()); diff != "" {
+				t.Fatalf("unexpected output (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_ValidateFormat(t *testing.T) {
+	t.Parallel()
+	for _, format := range []string{FormatText, FormatJSON} {
+		if err := ValidateFormat(format); err != nil {
+			t.Fatalf("unexpected err for format (%q): %v", format, err)
+		}
+	}
+	if err := ValidateFormat("yaml"); err == nil {
+		t.Fatalf("expected an error for format (%q)", "yaml")
+	}
+}
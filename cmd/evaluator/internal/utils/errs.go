@@ -5,4 +5,13 @@ import "errors"
 var (
 	errorImageParsing = errors.New("failed to parse image reference")
 	errorPackageName  = errors.New("invalid package name")
+	// ErrorStorage is returned when an attestation is created
+	// but cannot be stored.
+	ErrorStorage = errors.New("failed to store attestation")
+	// ErrorPolicyFetch is returned when a remote policy
+	// source cannot be fetched or unpacked.
+	ErrorPolicyFetch = errors.New("failed to fetch policy source")
+	// ErrorPolicyDigest is returned when the content of a remote
+	// policy source does not match its pinned digest.
+	ErrorPolicyDigest = errors.New("policy source digest mismatch")
 )
@@ -0,0 +1,110 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+var (
+	errorImageParsing  = errors.New("failed to parse image reference")
+	errorImageNotFound = errors.New("image not found")
+	errorRegistryAuth  = errors.New("registry requires authentication")
+	errorMultiPlatform = errors.New("image is a multi-platform index")
+	errorPlatform      = errors.New("invalid platform")
+)
+
+// Resolve resolves a tag or digest reference to an immutable
+// reference of the form registry/name@sha256:xxx, using the
+// credentials of the default keychain.
+// A multi-platform index is refused unless platform is set, in
+// which case the digest of the platform's image is returned.
+func Resolve(image, platform string) (string, error) {
+	// NOTE: disable "latest" default tag.
+	ref, err := name.ParseReference(image, name.WithDefaultTag(""))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to parse image (%q): %w", errorImageParsing, image, err)
+	}
+	if ref.Identifier() == "" {
+		return "", fmt.Errorf("%w: no tag or digest in image (%q)", errorImageParsing, image)
+	}
+	return resolve(ref, platform, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+}
+
+func resolve(ref name.Reference, platform string, options ...remote.Option) (string, error) {
+	var spec *v1.Platform
+	if platform != "" {
+		var err error
+		spec, err = v1.ParsePlatform(platform)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q: %w", errorPlatform, platform, err)
+		}
+	}
+	desc, err := remote.Head(ref, options...)
+	if err != nil {
+		return "", registryError(ref, err)
+	}
+	digest := desc.Digest
+	if desc.MediaType.IsIndex() {
+		if spec == nil {
+			return "", fmt.Errorf("%w: image (%q) has multiple platforms, use --platform to select one",
+				errorMultiPlatform, ref.String())
+		}
+		digest, err = platformDigest(ref, *spec, options...)
+		if err != nil {
+			return "", err
+		}
+	}
+	if digest.Algorithm != "sha256" {
+		return "", fmt.Errorf("%w: unsupported digest (%q) for image (%q)", errorImageParsing,
+			digest.String(), ref.String())
+	}
+	return ref.Context().Name() + "@" + digest.String(), nil
+}
+
+func platformDigest(ref name.Reference, spec v1.Platform, options ...remote.Option) (v1.Hash, error) {
+	index, err := remote.Index(ref, options...)
+	if err != nil {
+		return v1.Hash{}, registryError(ref, err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("failed to read index (%q): %w", ref.String(), err)
+	}
+	var matches []v1.Descriptor
+	for _, desc := range manifest.Manifests {
+		if desc.Platform != nil && desc.Platform.Satisfies(spec) {
+			matches = append(matches, desc)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return v1.Hash{}, fmt.Errorf("%w: no image for platform (%q) in image (%q)", errorImageNotFound,
+			spec.String(), ref.String())
+	case 1:
+		return matches[0].Digest, nil
+	default:
+		return v1.Hash{}, fmt.Errorf("%w: multiple images for platform (%q) in image (%q)", errorMultiPlatform,
+			spec.String(), ref.String())
+	}
+}
+
+func registryError(ref name.Reference, err error) error {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: image (%q) does not exist: %w", errorImageNotFound, ref.String(), err)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: access to image (%q) was denied, configure credentials with docker login: %w",
+				errorRegistryAuth, ref.String(), err)
+		}
+	}
+	return fmt.Errorf("failed to get image (%q): %w", ref.String(), err)
+}
@@ -0,0 +1,191 @@
+package image
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func Test_resolve(t *testing.T) {
+	t.Parallel()
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/private/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	newImage := func() (v1.Image, string) {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatalf("failed to create image: %v", err)
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			t.Fatalf("failed to get digest: %v", err)
+		}
+		return img, digest.String()
+	}
+	parse := func(image string) name.Reference {
+		ref, err := name.ParseReference(image, name.Insecure)
+		if err != nil {
+			t.Fatalf("failed to parse reference: %v", err)
+		}
+		return ref
+	}
+	// Single-platform image.
+	img, imgDigest := newImage()
+	if err := remote.Write(parse(host+"/single:v1"), img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	// Multi-platform index.
+	amd64, amd64Digest := newImage()
+	arm64, arm64Digest := newImage()
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{
+			Add: amd64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+			},
+		},
+		mutate.IndexAddendum{
+			Add: arm64,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: "arm64"},
+			},
+		},
+	)
+	if err := remote.WriteIndex(parse(host+"/multi:v1"), index); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		image    string
+		platform string
+		result   string
+		expected error
+	}{
+		{
+			name:   "tag",
+			image:  host + "/single:v1",
+			result: host + "/single@" + imgDigest,
+		},
+		{
+			name:   "digest",
+			image:  host + "/single@" + imgDigest,
+			result: host + "/single@" + imgDigest,
+		},
+		{
+			name:     "single platform with platform",
+			image:    host + "/single:v1",
+			platform: "linux/amd64",
+			result:   host + "/single@" + imgDigest,
+		},
+		{
+			name:     "index without platform",
+			image:    host + "/multi:v1",
+			expected: errorMultiPlatform,
+		},
+		{
+			name:     "index with platform",
+			image:    host + "/multi:v1",
+			platform: "linux/arm64",
+			result:   host + "/multi@" + arm64Digest,
+		},
+		{
+			name:     "index with other platform",
+			image:    host + "/multi:v1",
+			platform: "linux/amd64",
+			result:   host + "/multi@" + amd64Digest,
+		},
+		{
+			name:     "index with ambiguous platform",
+			image:    host + "/multi:v1",
+			platform: "linux",
+			expected: errorMultiPlatform,
+		},
+		{
+			name:     "index with unknown platform",
+			image:    host + "/multi:v1",
+			platform: "windows/amd64",
+			expected: errorImageNotFound,
+		},
+		{
+			name:     "invalid platform",
+			image:    host + "/multi:v1",
+			platform: "linux/arm/v7/extra",
+			expected: errorPlatform,
+		},
+		{
+			name:     "unknown tag",
+			image:    host + "/single:v2",
+			expected: errorImageNotFound,
+		},
+		{
+			name:     "authentication required",
+			image:    host + "/private/image:v1",
+			expected: errorRegistryAuth,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := resolve(parse(tt.image), tt.platform)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.result, result); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Resolve(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		image    string
+		expected error
+	}{
+		{
+			name:     "no identifier",
+			image:    "gcr.io/project/image",
+			expected: errorImageParsing,
+		},
+		{
+			name:     "invalid reference",
+			image:    "gcr.io/project/image:",
+			expected: errorImageParsing,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Resolve(tt.image, "")
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
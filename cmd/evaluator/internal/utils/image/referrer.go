@@ -0,0 +1,121 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+var (
+	errorPush = errors.New("failed to push artifact")
+	errorPull = errors.New("failed to pull artifact")
+)
+
+// Artifact defines an artifact to push as a referrer of an image.
+type Artifact struct {
+	// ArtifactType identifies the artifact in the referrers list.
+	ArtifactType string
+	// MediaType is the media type of the content.
+	MediaType string
+	Content   []byte
+}
+
+// PushReferrer pushes the artifact as an OCI 1.1 referrer of the
+// subject, an immutable reference of the form registry/name@sha256:xxx,
+// using the credentials of the default keychain.
+// The artifact is pushed to the repository of the subject, unless
+// repository is set. It returns the immutable reference of the artifact.
+func PushReferrer(subject, repository string, artifact Artifact) (string, error) {
+	subjectRef, err := name.NewDigest(subject)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to parse subject (%q): %w", errorImageParsing, subject, err)
+	}
+	repo := subjectRef.Context()
+	if repository != "" {
+		repo, err = name.NewRepository(repository)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to parse repository (%q): %w", errorImageParsing, repository, err)
+		}
+	}
+	return pushReferrer(subjectRef, repo, artifact, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+}
+
+func pushReferrer(subject name.Digest, repo name.Repository, artifact Artifact, options ...remote.Option) (string, error) {
+	if artifact.ArtifactType == "" || artifact.MediaType == "" {
+		return "", fmt.Errorf("%w: artifact type or media type is empty", errorPush)
+	}
+	desc, err := remote.Head(subject, options...)
+	if err != nil {
+		return "", registryError(subject, err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer(artifact.Content, types.MediaType(artifact.MediaType)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errorPush, err)
+	}
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	// NOTE: the config media type is the artifact type
+	// of manifests that do not set one.
+	img = mutate.ConfigMediaType(img, types.MediaType(artifact.ArtifactType))
+	img = mutate.Subject(img, v1.Descriptor{
+		MediaType: desc.MediaType,
+		Size:      desc.Size,
+		Digest:    desc.Digest,
+	}).(v1.Image)
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errorPush, err)
+	}
+	ref := repo.Digest(digest.String())
+	if err := remote.Write(ref, img, options...); err != nil {
+		return "", fmt.Errorf("%w: %w", errorPush, registryError(ref, err))
+	}
+	return ref.String(), nil
+}
+
+// PullArtifact returns the content of the artifact at image, a tag or
+// digest reference to an artifact pushed by PushReferrer, using the
+// credentials of the default keychain.
+func PullArtifact(image string) ([]byte, error) {
+	// NOTE: disable "latest" default tag.
+	ref, err := name.ParseReference(image, name.WithDefaultTag(""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse artifact (%q): %w", errorImageParsing, image, err)
+	}
+	if ref.Identifier() == "" {
+		return nil, fmt.Errorf("%w: no tag or digest in artifact (%q)", errorImageParsing, image)
+	}
+	return pullArtifact(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+}
+
+func pullArtifact(ref name.Reference, options ...remote.Option) ([]byte, error) {
+	img, err := remote.Image(ref, options...)
+	if err != nil {
+		return nil, registryError(ref, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errorPull, registryError(ref, err))
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("%w: artifact (%q) has %d layers, expected 1", errorPull, ref.String(), len(layers))
+	}
+	reader, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errorPull, registryError(ref, err))
+	}
+	defer reader.Close()
+	content, err := limit.ReadAll(reader, limit.DefaultMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: artifact (%q): %w", errorPull, ref.String(), err)
+	}
+	return content, nil
+}
@@ -0,0 +1,220 @@
+package image
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func Test_pushReferrer(t *testing.T) {
+	t.Parallel()
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/private/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	tag, err := name.NewTag(host+"/image:v1", name.Insecure)
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	artifact := Artifact{
+		ArtifactType: "application/vnd.test.artifact",
+		MediaType:    "application/vnd.in-toto+json",
+		Content:      []byte(`{"_type": "https://in-toto.io/Statement/v1"}`),
+	}
+	tests := []struct {
+		name       string
+		subject    string
+		repository string
+		artifact   Artifact
+		expected   error
+	}{
+		{
+			name:     "subject repository",
+			subject:  host + "/image@" + digest.String(),
+			artifact: artifact,
+		},
+		{
+			name:       "other repository",
+			subject:    host + "/image@" + digest.String(),
+			repository: host + "/attestations",
+			artifact:   artifact,
+		},
+		{
+			name:     "unknown subject",
+			subject:  host + "/other@" + digest.String(),
+			artifact: artifact,
+			expected: errorImageNotFound,
+		},
+		{
+			name:       "authentication required",
+			subject:    host + "/image@" + digest.String(),
+			repository: host + "/private/attestations",
+			artifact:   artifact,
+			expected:   errorPush,
+		},
+		{
+			name:    "empty artifact type",
+			subject: host + "/image@" + digest.String(),
+			artifact: Artifact{
+				MediaType: artifact.MediaType,
+				Content:   artifact.Content,
+			},
+			expected: errorPush,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			subject, err := name.NewDigest(tt.subject, name.Insecure)
+			if err != nil {
+				t.Fatalf("failed to parse subject: %v", err)
+			}
+			repo := subject.Context()
+			if tt.repository != "" {
+				repo, err = name.NewRepository(tt.repository, name.Insecure)
+				if err != nil {
+					t.Fatalf("failed to parse repository: %v", err)
+				}
+			}
+			result, err := pushReferrer(subject, repo, tt.artifact)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			// The artifact must be listed as a referrer of the subject.
+			index, err := remote.Referrers(repo.Digest(subject.DigestStr()))
+			if err != nil {
+				t.Fatalf("failed to get referrers: %v", err)
+			}
+			manifest, err := index.IndexManifest()
+			if err != nil {
+				t.Fatalf("failed to get index manifest: %v", err)
+			}
+			if len(manifest.Manifests) != 1 {
+				t.Fatalf("unexpected referrers: %v", manifest.Manifests)
+			}
+			if diff := cmp.Diff(tt.artifact.ArtifactType, manifest.Manifests[0].ArtifactType); diff != "" {
+				t.Fatalf("unexpected artifact type (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(repo.Digest(manifest.Manifests[0].Digest.String()).String(), result); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_pullArtifact(t *testing.T) {
+	t.Parallel()
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/private/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	tag, err := name.NewTag(host+"/image:v1", name.Insecure)
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	subject := tag.Context().Digest(digest.String())
+	content := []byte(`{"_type": "https://in-toto.io/Statement/v1"}`)
+	artifact, err := pushReferrer(subject, subject.Context(), Artifact{
+		ArtifactType: "application/vnd.test.artifact",
+		MediaType:    "application/vnd.in-toto+json",
+		Content:      content,
+	})
+	if err != nil {
+		t.Fatalf("failed to push artifact: %v", err)
+	}
+	tests := []struct {
+		name     string
+		ref      string
+		expected error
+	}{
+		{
+			name: "artifact",
+			ref:  artifact,
+		},
+		{
+			name:     "multiple layers",
+			ref:      host + "/image@" + digest.String(),
+			expected: errorPull,
+		},
+		{
+			name:     "unknown artifact",
+			ref:      host + "/other@" + digest.String(),
+			expected: errorImageNotFound,
+		},
+		{
+			name:     "authentication required",
+			ref:      host + "/private/image@" + digest.String(),
+			expected: errorRegistryAuth,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ref, err := name.ParseReference(tt.ref, name.Insecure)
+			if err != nil {
+				t.Fatalf("failed to parse reference: %v", err)
+			}
+			result, err := pullArtifact(ref)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(string(content), string(result)); diff != "" {
+				t.Fatalf("unexpected content (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+)
+
+// namespace prefixes the names of the metrics.
+const namespace = "slsa_policy"
+
+// Metrics records the policy evaluations and attestation
+// verifications as Prometheus metrics:
+//
+//	slsa_policy_evaluations_total{component, decision, code}
+//	slsa_policy_evaluation_duration_seconds{component, decision}
+//	slsa_policy_verifications_total{component, decision, code}
+//	slsa_policy_verification_duration_seconds{component, decision}
+//
+// It implements metrics.Metrics and is safe for concurrent use.
+type Metrics struct {
+	evaluations          *prometheus.CounterVec
+	evaluationDuration   *prometheus.HistogramVec
+	verifications        *prometheus.CounterVec
+	verificationDuration *prometheus.HistogramVec
+}
+
+var _ metrics.Metrics = (*Metrics)(nil)
+
+// MetricsNew creates the metrics and registers them with registerer.
+func MetricsNew(registerer prometheus.Registerer) (*Metrics, error) {
+	if registerer == nil {
+		return nil, fmt.Errorf("registerer is nil")
+	}
+	m := &Metrics{
+		evaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "evaluations_total",
+			Help:      "Number of policy evaluations, by decision and error code.",
+		}, []string{"component", "decision", "code"}),
+		evaluationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "evaluation_duration_seconds",
+			Help:      "Duration of the policy evaluations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"component", "decision"}),
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verifications_total",
+			Help:      "Number of attestation verifications, by decision and error code.",
+		}, []string{"component", "decision", "code"}),
+		verificationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "verification_duration_seconds",
+			Help:      "Duration of the attestation verifications.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"component", "decision"}),
+	}
+	for _, c := range []prometheus.Collector{m.evaluations, m.evaluationDuration, m.verifications, m.verificationDuration} {
+		if err := registerer.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// ObserveEvaluation implements metrics.Metrics.
+func (m *Metrics) ObserveEvaluation(component, decision, code string, duration time.Duration) {
+	m.evaluations.WithLabelValues(component, decision, code).Inc()
+	m.evaluationDuration.WithLabelValues(component, decision).Observe(duration.Seconds())
+}
+
+// ObserveVerification implements metrics.Metrics.
+func (m *Metrics) ObserveVerification(component, decision, code string, duration time.Duration) {
+	m.verifications.WithLabelValues(component, decision, code).Inc()
+	m.verificationDuration.WithLabelValues(component, decision).Observe(duration.Seconds())
+}
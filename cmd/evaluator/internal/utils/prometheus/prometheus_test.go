@@ -0,0 +1,45 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+)
+
+func Test_Metrics(t *testing.T) {
+	t.Parallel()
+	registry := prometheus.NewRegistry()
+	m, err := MetricsNew(registry)
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+	m.ObserveEvaluation(metrics.ComponentDeployment, metrics.DecisionAllow, "", time.Millisecond)
+	m.ObserveEvaluation(metrics.ComponentDeployment, metrics.DecisionAllow, "", time.Millisecond)
+	m.ObserveEvaluation(metrics.ComponentDeployment, metrics.DecisionDeny, "revoked", time.Millisecond)
+	m.ObserveVerification(metrics.ComponentPublish, metrics.DecisionDeny, "mismatch", time.Millisecond)
+	expected := `
+# HELP slsa_policy_evaluations_total Number of policy evaluations, by decision and error code.
+# TYPE slsa_policy_evaluations_total counter
+slsa_policy_evaluations_total{code="",component="deployment",decision="allow"} 2
+slsa_policy_evaluations_total{code="revoked",component="deployment",decision="deny"} 1
+# HELP slsa_policy_verifications_total Number of attestation verifications, by decision and error code.
+# TYPE slsa_policy_verifications_total counter
+slsa_policy_verifications_total{code="mismatch",component="publish",decision="deny"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"slsa_policy_evaluations_total", "slsa_policy_verifications_total"); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+	if count := testutil.CollectAndCount(m.evaluationDuration); count != 2 {
+		t.Fatalf("unexpected duration series: want 2, got %d", count)
+	}
+
+	// Registering twice fails.
+	if _, err := MetricsNew(registry); err == nil {
+		t.Fatalf("expected registration error")
+	}
+}
@@ -0,0 +1,120 @@
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+)
+
+// denyRule is the rule of a module that lists the deny messages.
+const denyRule = "deny"
+
+// Engine compiles the Rego modules of the custom rules of project
+// policies. A module denies a request if its deny rule, a set of
+// messages, is not empty, e.g.
+//
+//	package custom
+//
+//	deny[msg] {
+//	  input.request.environment == "prod"
+//	  input.attestation.build_level < 3
+//	  msg := "prod requires build level 3"
+//	}
+type Engine struct {
+	// root is the directory the policy IDs are relative to.
+	root string
+	// readFile reads the module files.
+	readFile func(name string) ([]byte, error)
+}
+
+// EngineNew creates an engine for project policies whose IDs are
+// paths relative to root. The path of a module is relative to the
+// directory of the project policy that references it.
+func EngineNew(root string) *Engine {
+	return &Engine{root: root, readFile: os.ReadFile}
+}
+
+// EngineWithReadFile creates an engine that reads the module files
+// with readFile instead of from the file system, e.g. from a fetched
+// policy source. The paths passed to readFile are relative to the
+// root the policy IDs are relative to.
+func EngineWithReadFile(readFile func(name string) ([]byte, error)) *Engine {
+	return &Engine{readFile: readFile}
+}
+
+// Compile compiles the module of the custom rules.
+func (e *Engine) Compile(policyID string, rules deployment.CustomRules) (deployment.CustomRulesEvaluator, error) {
+	name, module := policyID, rules.Module
+	if rules.Path != "" {
+		name = rules.Path
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(e.root, filepath.Dir(filepath.FromSlash(policyID)), name)
+		}
+		content, err := e.readFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read module: %w", err)
+		}
+		module = string(content)
+	}
+	parsed, err := ast.ParseModule(name, module)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse module (%q): %w", name, err)
+	}
+	query := parsed.Package.Path.String() + "." + denyRule
+	prepared, err := rego.New(rego.Query(query), rego.ParsedModule(parsed)).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile module (%q): %w", name, err)
+	}
+	return &evaluator{query: prepared}, nil
+}
+
+// evaluator evaluates a compiled module.
+// NOTE: a prepared query is safe for concurrent use.
+type evaluator struct {
+	query rego.PreparedEvalQuery
+}
+
+func (e *evaluator) Evaluate(input deployment.CustomRulesInput) (deployment.CustomRulesDecision, error) {
+	// NOTE: the input is converted to its JSON form, so that
+	// the rules use the names of the JSON fields.
+	content, err := json.Marshal(input)
+	if err != nil {
+		return deployment.CustomRulesDecision{}, fmt.Errorf("failed to marshal input: %w", err)
+	}
+	var doc any
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return deployment.CustomRulesDecision{}, fmt.Errorf("failed to unmarshal input: %w", err)
+	}
+	results, err := e.query.Eval(context.Background(), rego.EvalInput(doc))
+	if err != nil {
+		return deployment.CustomRulesDecision{}, err
+	}
+	var messages []string
+	for _, result := range results {
+		for _, expression := range result.Expressions {
+			values, ok := expression.Value.([]any)
+			if !ok {
+				return deployment.CustomRulesDecision{}, fmt.Errorf("%s rule is not a set", denyRule)
+			}
+			for _, value := range values {
+				messages = append(messages, fmt.Sprint(value))
+			}
+		}
+	}
+	if len(messages) == 0 {
+		return deployment.CustomRulesDecision{}, nil
+	}
+	sort.Strings(messages)
+	return deployment.CustomRulesDecision{
+		Deny:    true,
+		Message: strings.Join(messages, "; "),
+	}, nil
+}
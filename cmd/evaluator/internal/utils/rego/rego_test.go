@@ -0,0 +1,153 @@
+package rego
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_Engine(t *testing.T) {
+	t.Parallel()
+	module := `package custom
+
+deny[msg] {
+	input.request.environment == "prod"
+	input.attestation.build_level < 3
+	msg := "prod requires build level 3"
+}
+
+deny[msg] {
+	not startswith(input.request.package_name, "docker.io/org/")
+	msg := sprintf("package %s is not allowed", [input.request.package_name])
+}
+`
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "projects"), 0o700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "projects", "rules.rego"), []byte(module), 0o600); err != nil {
+		t.Fatalf("failed to write module: %v", err)
+	}
+	env := func(e string) *string { return &e }
+	input := func(pkg string, environment *string, level int) deployment.CustomRulesInput {
+		return deployment.CustomRulesInput{
+			Request: deployment.CustomRulesRequest{
+				Digests: intoto.DigestSet{
+					"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+				},
+				Package:     pkg,
+				PolicyID:    "projects/servers.json",
+				Environment: environment,
+			},
+			Attestation: deployment.CustomRulesAttestation{
+				PublishRootID: "publishr_id",
+				BuildLevel:    level,
+			},
+		}
+	}
+	tests := []struct {
+		name       string
+		rules      deployment.CustomRules
+		input      deployment.CustomRulesInput
+		compileErr bool
+		expected   deployment.CustomRulesDecision
+	}{
+		{
+			name:  "allow",
+			rules: deployment.CustomRules{Module: module},
+			input: input("docker.io/org/server", env("prod"), 3),
+		},
+		{
+			name:  "allow without environment",
+			rules: deployment.CustomRules{Module: module},
+			input: input("docker.io/org/server", nil, 1),
+		},
+		{
+			name:     "deny",
+			rules:    deployment.CustomRules{Module: module},
+			input:    input("docker.io/org/server", env("prod"), 2),
+			expected: deployment.CustomRulesDecision{Deny: true, Message: "prod requires build level 3"},
+		},
+		{
+			name:  "deny with several messages",
+			rules: deployment.CustomRules{Module: module},
+			input: input("docker.io/other/server", env("prod"), 2),
+			expected: deployment.CustomRulesDecision{Deny: true,
+				Message: "package docker.io/other/server is not allowed; prod requires build level 3"},
+		},
+		{
+			name:     "module path",
+			rules:    deployment.CustomRules{Path: "rules.rego"},
+			input:    input("docker.io/org/server", env("prod"), 2),
+			expected: deployment.CustomRulesDecision{Deny: true, Message: "prod requires build level 3"},
+		},
+		{
+			name:       "missing module path",
+			rules:      deployment.CustomRules{Path: "other.rego"},
+			compileErr: true,
+		},
+		{
+			name:       "invalid module",
+			rules:      deployment.CustomRules{Module: "package custom\n\ndeny[msg] {"},
+			compileErr: true,
+		},
+		{
+			name:       "unsafe variable",
+			rules:      deployment.CustomRules{Module: "package custom\n\ndeny[msg] { x == 1 }"},
+			compileErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			evaluator, err := EngineNew(root).Compile("projects/servers.json", tt.rules)
+			if (err != nil) != tt.compileErr {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			decision, err := evaluator.Evaluate(tt.input)
+			if err != nil {
+				t.Fatalf("failed to evaluate: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, decision); diff != "" {
+				t.Fatalf("unexpected decision (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_EngineWithReadFile(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"projects/rules.rego": "package custom\n\ndeny[msg] {\n\tmsg := \"denied\"\n}\n",
+	}
+	engine := EngineWithReadFile(func(name string) ([]byte, error) {
+		content, exists := files[filepath.ToSlash(name)]
+		if !exists {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	})
+	evaluator, err := engine.Compile("projects/servers.json", deployment.CustomRules{Path: "rules.rego"})
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	decision, err := evaluator.Evaluate(deployment.CustomRulesInput{})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if diff := cmp.Diff(deployment.CustomRulesDecision{Deny: true, Message: "denied"}, decision); diff != "" {
+		t.Fatalf("unexpected decision (-want +got): \n%s", diff)
+	}
+	if _, err := engine.Compile("projects/servers.json", deployment.CustomRules{Path: "other.rego"}); err == nil {
+		t.Fatalf("expected an error for a missing module")
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// maxLineSize is the maximum size of a line of a JSON lines file.
+const maxLineSize = 1 << 20
+
+// PublishRequest is a publish evaluation request.
+// NOTE: The fields are part of the CLI interface, do not rename them.
+type PublishRequest struct {
+	Package     string           `json:"package"`
+	Digests     intoto.DigestSet `json:"digests"`
+	Environment *string          `json:"environment,omitempty"`
+}
+
+// EvaluationRequest returns the request to evaluate.
+func (r PublishRequest) EvaluationRequest() publish.EvaluationRequest {
+	return publish.EvaluationRequest{
+		Digests:           r.Digests,
+		PolicyPackageName: r.Package,
+		Environment:       r.Environment,
+	}
+}
+
+// DeploymentRequest is a deployment evaluation request.
+// Its fields are those of the decision of 'deployment evaluate'.
+// NOTE: The fields are part of the CLI interface, do not rename them.
+type DeploymentRequest struct {
+	Package  string           `json:"package"`
+	Digests  intoto.DigestSet `json:"digests"`
+	PolicyID string           `json:"policy_id"`
+	// Scopes, if set, must be the scopes of the decision.
+	Scopes map[string]string `json:"scopes,omitempty"`
+}
+
+// EvaluationRequest returns the request to evaluate.
+func (r DeploymentRequest) EvaluationRequest() deployment.EvaluationRequest {
+	return deployment.EvaluationRequest{
+		Digests:     r.Digests,
+		PackageName: r.Package,
+		PolicyID:    r.PolicyID,
+		Scopes:      r.Scopes,
+	}
+}
+
+// DecodeRequest decodes a single JSON request. Unknown fields are rejected.
+func DecodeRequest(r io.Reader, req any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		return fmt.Errorf("%w: failed to parse request: %w", errs.ErrorInvalidInput, err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("%w: trailing data after request", errs.ErrorInvalidInput)
+	}
+	return nil
+}
+
+// ReadJSONLines decodes each non-empty line of r as a request. A line
+// that cannot be decoded is reported at its index in lineErrs, so that
+// one malformed line does not fail the batch. The returned error is set
+// only if r cannot be read.
+func ReadJSONLines[T any](r io.Reader) (reqs []T, lineErrs []error, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+		var req T
+		err := DecodeRequest(bytes.NewReader(content), &req)
+		if err != nil {
+			err = fmt.Errorf("line %d: %w", line, err)
+		}
+		reqs = append(reqs, req)
+		lineErrs = append(lineErrs, err)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read requests: %w", err)
+	}
+	return reqs, lineErrs, nil
+}
+
+// Decision returns the decision for the result of the evaluation of the request.
+func (r PublishRequest) Decision(result publish.PolicyEvaluationResult) Decision {
+	decision := Decision{
+		Decision: "deny",
+		Package:  r.Package,
+		Digests:  r.Digests,
+	}
+	if err := result.Error(); err != nil {
+		decision.SetError(err)
+		return decision
+	}
+	level := result.Level()
+	decision.Decision = "allow"
+	decision.SlsaLevel = &level
+	return decision
+}
+
+// Decision returns the decision for the result of the evaluation of the request.
+// NOTE: the scopes of the request must be verified by the evaluation,
+// see deployment.Policy.EvaluateBatch.
+func (r DeploymentRequest) Decision(result deployment.PolicyEvaluationResult) Decision {
+	decision := Decision{
+		Decision: "deny",
+		Package:  r.Package,
+		Digests:  r.Digests,
+		PolicyID: r.PolicyID,
+	}
+	if err := result.Error(); err != nil {
+		decision.SetError(err)
+		return decision
+	}
+	decision.Decision = "allow"
+	decision.PrincipalURI = result.PrincipalURI()
+	decision.Scopes = result.Scopes()
+	return decision
+}
+
+// WriteDecisions writes one decision per line, in the order of the
+// requests. It returns an error if any decision is a deny decision.
+func WriteDecisions(w io.Writer, decisions []Decision) error {
+	denied := 0
+	for i := range decisions {
+		if err := decisions[i].Write(w); err != nil {
+			return err
+		}
+		if decisions[i].Decision != "allow" {
+			denied++
+		}
+	}
+	if denied > 0 {
+		return fmt.Errorf("%d of %d request(s) denied", denied, len(decisions))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_ReadJSONLines(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	line := `{"package": "docker.io/org/server", "digests": {"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"}, "policy_id": "servers-prod.json"}`
+	request := DeploymentRequest{
+		Package:  "docker.io/org/server",
+		Digests:  digests,
+		PolicyID: "servers-prod.json",
+	}
+	tests := []struct {
+		name     string
+		content  string
+		requests []DeploymentRequest
+		lineErrs []error
+	}{
+		{
+			name:     "single request",
+			content:  line,
+			requests: []DeploymentRequest{request},
+			lineErrs: []error{nil},
+		},
+		{
+			name:     "blank lines",
+			content:  "\n" + line + "\n   \n" + line + "\n",
+			requests: []DeploymentRequest{request, request},
+			lineErrs: []error{nil, nil},
+		},
+		{
+			name:     "empty file",
+			content:  "",
+			requests: nil,
+			lineErrs: nil,
+		},
+		{
+			name:     "malformed line",
+			content:  line + "\n{\"package\": \n" + line,
+			requests: []DeploymentRequest{request, {}, request},
+			lineErrs: []error{nil, errs.ErrorInvalidInput, nil},
+		},
+		{
+			name:     "unknown field",
+			content:  `{"package": "docker.io/org/server", "other": "value"}`,
+			requests: []DeploymentRequest{{Package: "docker.io/org/server"}},
+			lineErrs: []error{errs.ErrorInvalidInput},
+		},
+		{
+			name:     "trailing data",
+			content:  line + " {}",
+			requests: []DeploymentRequest{request},
+			lineErrs: []error{errs.ErrorInvalidInput},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			requests, lineErrs, err := ReadJSONLines[DeploymentRequest](strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("failed to read requests: %v", err)
+			}
+			if diff := cmp.Diff(tt.requests, requests); diff != "" {
+				t.Fatalf("unexpected requests (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.lineErrs, lineErrs, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
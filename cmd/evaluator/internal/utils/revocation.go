@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+// revocationListTimeout is the maximum time to fetch a revocation list.
+const revocationListTimeout = 30 * time.Second
+
+// maxRevocationListSize is the maximum size of a fetched revocation list.
+// Reading a larger list fails with errs.ErrorInvalidInput.
+const maxRevocationListSize = limit.DefaultMaxSize
+
+// OpenRevocationList opens the revocation list at location,
+// either a local path or an https URL.
+func OpenRevocationList(location string) (io.ReadCloser, error) {
+	return openRevocationList(location, nil)
+}
+
+// openRevocationList opens the revocation list at location. The
+// transport defaults to http.DefaultTransport. It is set by tests.
+func openRevocationList(location string, transport http.RoundTripper) (io.ReadCloser, error) {
+	if strings.HasPrefix(location, "http://") {
+		return nil, fmt.Errorf("revocation list URL (%q) must use https", location)
+	}
+	if !strings.HasPrefix(location, "https://") {
+		reader, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read revocation list: %w", err)
+		}
+		return reader, nil
+	}
+	client := &http.Client{
+		Timeout:   revocationListTimeout,
+		Transport: transport,
+		// NOTE: a redirect must not downgrade to http.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to (%q) does not use https", req.URL)
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revocation list: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch revocation list: status %d", resp.StatusCode)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{limit.Reader(resp.Body, maxRevocationListSize), resp.Body}, nil
+}
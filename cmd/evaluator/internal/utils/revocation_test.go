@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_OpenRevocationList(t *testing.T) {
+	t.Parallel()
+	content := `{"format": 1, "revocations": []}`
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write revocation list: %v", err)
+	}
+	large := strings.Repeat(" ", int(maxRevocationListSize)) + content
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/revocations.json":
+			io.WriteString(w, content)
+		case "/large.json":
+			io.WriteString(w, large)
+		case "/redirect.json":
+			http.Redirect(w, r, "http://"+r.Host+"/revocations.json", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	tests := []struct {
+		name     string
+		location string
+		content  string
+		err      bool
+		// readErr is the error of reading the list, if any.
+		readErr error
+	}{
+		{
+			name:     "path",
+			location: path,
+			content:  content,
+		},
+		{
+			name:     "url",
+			location: server.URL + "/revocations.json",
+			content:  content,
+		},
+		{
+			name:     "large url",
+			location: server.URL + "/large.json",
+			readErr:  errs.ErrorInvalidInput,
+		},
+		{
+			name:     "http url",
+			location: "http://" + strings.TrimPrefix(server.URL, "https://") + "/revocations.json",
+			err:      true,
+		},
+		{
+			name:     "redirect to http",
+			location: server.URL + "/redirect.json",
+			err:      true,
+		},
+		{
+			name:     "path not found",
+			location: filepath.Join(t.TempDir(), "other.json"),
+			err:      true,
+		},
+		{
+			name:     "url not found",
+			location: server.URL + "/other.json",
+			err:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reader, err := openRevocationList(tt.location, server.Client().Transport)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			defer reader.Close()
+			got, err := io.ReadAll(reader)
+			if diff := cmp.Diff(tt.readErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.content, string(got)); diff != "" {
+				t.Fatalf("unexpected content (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Signing modes of the --sign flag.
+const (
+	SignKeyless = "keyless"
+	SignKMS     = "kms"
+)
+
+// ValidateSigning returns an error if the signing mode is not supported
+// or inconsistent with kmsKey. An empty mode disables signing.
+func ValidateSigning(mode, kmsKey string) error {
+	switch mode {
+	case "", SignKeyless:
+		if kmsKey != "" {
+			return fmt.Errorf("--kms-key requires --sign=%s", SignKMS)
+		}
+	case SignKMS:
+		if kmsKey == "" {
+			return fmt.Errorf("--sign=%s requires --kms-key", SignKMS)
+		}
+	default:
+		return fmt.Errorf("invalid signing mode (%q). Must be %q or %q", mode, SignKeyless, SignKMS)
+	}
+	return nil
+}
+
+// SignableAttestation is an attestation that can be signed.
+type SignableAttestation interface {
+	ToBytes() ([]byte, error)
+	Sign(ctx context.Context, signer intoto.AttestationSigner) ([]byte, error)
+}
+
+// AttestationContent returns the content of the attestation to output:
+// a signed DSSE envelope if signer is set, the plain statement otherwise.
+func AttestationContent(ctx context.Context, att SignableAttestation, signer intoto.AttestationSigner) ([]byte, error) {
+	if signer == nil {
+		content, err := att.ToBytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attestation bytes: %w", err)
+		}
+		return content, nil
+	}
+	content, err := att.Sign(ctx, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign attestation: %w", err)
+	}
+	return content, nil
+}
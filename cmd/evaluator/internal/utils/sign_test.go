@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_ValidateSigning(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		mode     string
+		kmsKey   string
+		expected bool
+	}{
+		{
+			name: "no signing",
+		},
+		{
+			name: "keyless",
+			mode: SignKeyless,
+		},
+		{
+			name:   "kms",
+			mode:   SignKMS,
+			kmsKey: "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k",
+		},
+		{
+			name:     "kms without key",
+			mode:     SignKMS,
+			expected: true,
+		},
+		{
+			name:     "keyless with key",
+			mode:     SignKeyless,
+			kmsKey:   "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k",
+			expected: true,
+		},
+		{
+			name:     "key without signing",
+			kmsKey:   "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k",
+			expected: true,
+		},
+		{
+			name:     "unknown mode",
+			mode:     "key",
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateSigning(tt.mode, tt.kmsKey)
+			if (err != nil) != tt.expected {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
+
+// fakeSigner records the statement it is asked to sign.
+type fakeSigner struct {
+	statement []byte
+}
+
+func (s *fakeSigner) Sign(ctx context.Context, statement []byte) ([]byte, error) {
+	s.statement = statement
+	return json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString([]byte("signature"))}},
+	})
+}
+
+func Test_AttestationContent(t *testing.T) {
+	t.Parallel()
+	att, err := deployment.CreationNew(intoto.Subject{Digests: intoto.DigestSet{"sha256": "some_value"}},
+		map[string]string{"environment": "prod"})
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	tests := []struct {
+		name     string
+		signer   *fakeSigner
+		envelope bool
+	}{
+		{
+			name: "plain statement",
+		},
+		{
+			name:     "signed envelope",
+			signer:   &fakeSigner{},
+			envelope: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var signer intoto.AttestationSigner
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+			content, err := AttestationContent(context.Background(), att, signer)
+			if err != nil {
+				t.Fatalf("failed to get content: %v", err)
+			}
+			envelope, err := intoto.ParseEnvelope(content)
+			if err != nil {
+				t.Fatalf("failed to parse content: %v", err)
+			}
+			if (envelope != nil) != tt.envelope {
+				t.Fatalf("unexpected content: %s", content)
+			}
+			if !tt.envelope {
+				if diff := cmp.Diff(string(statement), string(content)); diff != "" {
+					t.Fatalf("unexpected content (-want +got): \n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(string(statement), string(tt.signer.statement)); diff != "" {
+				t.Fatalf("unexpected signed statement (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
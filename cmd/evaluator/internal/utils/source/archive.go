@@ -0,0 +1,81 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+// gzipMagic are the first bytes of gzip content.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// fileSet contains the unpacked files of a policy source,
+// by their path, and their total size.
+type fileSet struct {
+	files map[string][]byte
+	size  int
+}
+
+func newFileSet() *fileSet {
+	return &fileSet{files: make(map[string][]byte)}
+}
+
+// unpack unpacks the regular files of a tarball, optionally
+// gzip-compressed, into files. Files outside the root of the
+// archive and duplicate files are rejected.
+func unpack(content []byte, files *fileSet) error {
+	var r io.Reader = bytes.NewReader(content)
+	if bytes.HasPrefix(content, gzipMagic) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := limit.ReadAll(tr, maxSize)
+		if err != nil {
+			return fmt.Errorf("failed to read (%q): %w", header.Name, err)
+		}
+		if err := files.add(header.Name, content); err != nil {
+			return err
+		}
+	}
+}
+
+// add adds a file to the set. The total size of
+// the files is limited to maxSize.
+func (f *fileSet) add(name string, content []byte) error {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("invalid file path (%q)", name)
+	}
+	if _, exists := f.files[clean]; exists {
+		return fmt.Errorf("duplicate file (%q)", clean)
+	}
+	if f.size+len(content) > maxSize {
+		return fmt.Errorf("files exceed %d bytes", maxSize)
+	}
+	f.files[clean] = content
+	f.size += len(content)
+	return nil
+}
@@ -0,0 +1,70 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+// titleAnnotation is the annotation of a layer that names its file,
+// e.g. set by oras for the files it pushes.
+const titleAnnotation = "org.opencontainers.image.title"
+
+// fetchOCI fetches the artifact pinned by ref. Its layers are either
+// tarballs, unpacked at the root of the source, or single files named
+// by their title annotation.
+func fetchOCI(ref name.Digest, options ...remote.Option) (*Policy, error) {
+	uri := prefixOCI + ref.Context().String()
+	img, err := remote.Image(ref, options...)
+	if err != nil {
+		// NOTE: the registry client does not expose a typed
+		// error for a manifest that does not match the digest.
+		if strings.Contains(err.Error(), "does not match requested digest") {
+			return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyDigest, ref, err)
+		}
+		return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyFetch, ref, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyFetch, ref, err)
+	}
+	files := newFileSet()
+	for _, desc := range manifest.Layers {
+		if desc.Size > maxSize {
+			return nil, fmt.Errorf("%w: (%q): layer (%q) exceeds %d bytes", utils.ErrorPolicyFetch, ref, desc.Digest, maxSize)
+		}
+		layer, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyFetch, ref, err)
+		}
+		reader, err := layer.Compressed()
+		if err != nil {
+			return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyFetch, ref, err)
+		}
+		// NOTE: the reader verifies the digest of the layer once read.
+		content, err := limit.ReadAll(reader, maxSize)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: (%q): layer (%q): %w", utils.ErrorPolicyFetch, ref, desc.Digest, err)
+		}
+		if title, exists := desc.Annotations[titleAnnotation]; exists && !strings.Contains(string(desc.MediaType), "tar") {
+			err = files.add(title, content)
+		} else {
+			err = unpack(content, files)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: (%q): layer (%q): %w", utils.ErrorPolicyFetch, ref, desc.Digest, err)
+		}
+	}
+	algorithm, digest, _ := strings.Cut(ref.DigestStr(), ":")
+	return &Policy{
+		URI:    uri,
+		Digest: intoto.DigestSet{algorithm: digest},
+		files:  files.files,
+	}, nil
+}
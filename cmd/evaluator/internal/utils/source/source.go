@@ -0,0 +1,191 @@
+package source
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+// Prefixes of the remote policy sources.
+const (
+	prefixOCI   = "oci://"
+	prefixHTTPS = "https://"
+)
+
+// fetchTimeout is the maximum time to fetch an HTTPS policy source.
+const fetchTimeout = 60 * time.Second
+
+// maxSize is the maximum size of a policy source, both
+// of its fetched content and of its unpacked files.
+const maxSize = 64 << 20
+
+var (
+	sha256Pattern   = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	orgPolicies     = []string{"org.json", "org.yaml", "org.yml"}
+	policyExtension = []string{".json", ".yaml", ".yml"}
+)
+
+// Policy is the content of a remote policy source, fetched
+// and verified against its pinned digest.
+type Policy struct {
+	// URI is the location of the source, without its digest.
+	URI string
+	// Digest is the pinned digest of the source.
+	Digest intoto.DigestSet
+	// files are the unpacked files, by their path
+	// relative to the root of the source.
+	files map[string][]byte
+}
+
+// IsRemote returns true if location is a remote policy source,
+// i.e. an oci:// reference or an https:// URL.
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, prefixOCI) || strings.HasPrefix(location, prefixHTTPS)
+}
+
+// Fetch fetches the policy source at location, verifies its pinned
+// digest and unpacks it in memory. The location is either an OCI
+// artifact pinned by digest, e.g. oci://registry/repo@sha256:xxxx, or
+// the HTTPS URL of a tarball with the digest as fragment, e.g.
+// https://host/policies.tar.gz#sha256=xxxx. Fetch failures wrap
+// utils.ErrorPolicyFetch and digest mismatches utils.ErrorPolicyDigest.
+func Fetch(location string) (*Policy, error) {
+	switch {
+	case strings.HasPrefix(location, prefixOCI):
+		ref, err := name.NewDigest(strings.TrimPrefix(location, prefixOCI))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid policy source (%q). Must be pinned by digest: %w", errs.ErrorInvalidInput, location, err)
+		}
+		return fetchOCI(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	case strings.HasPrefix(location, prefixHTTPS):
+		return fetchHTTPS(&http.Client{Timeout: fetchTimeout}, location)
+	default:
+		return nil, fmt.Errorf("%w: invalid policy source (%q). Must start with %q or %q", errs.ErrorInvalidInput, location, prefixOCI, prefixHTTPS)
+	}
+}
+
+func fetchHTTPS(client *http.Client, location string) (*Policy, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid policy source (%q): %w", errs.ErrorInvalidInput, location, err)
+	}
+	digest, ok := strings.CutPrefix(u.Fragment, "sha256=")
+	if !ok || !sha256Pattern.MatchString(digest) {
+		return nil, fmt.Errorf("%w: invalid policy source (%q). Must be pinned by a #sha256=<hex> fragment", errs.ErrorInvalidInput, location)
+	}
+	u.Fragment = ""
+	uri := u.String()
+	resp, err := client.Get(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", utils.ErrorPolicyFetch, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: (%q): status %d", utils.ErrorPolicyFetch, uri, resp.StatusCode)
+	}
+	content, err := limit.ReadAll(resp.Body, maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyFetch, uri, err)
+	}
+	sum := sha256.Sum256(content)
+	if actual := hex.EncodeToString(sum[:]); actual != digest {
+		return nil, fmt.Errorf("%w: (%q): got sha256 (%q), expected (%q)", utils.ErrorPolicyDigest, uri, actual, digest)
+	}
+	files := newFileSet()
+	if err := unpack(content, files); err != nil {
+		return nil, fmt.Errorf("%w: (%q): %w", utils.ErrorPolicyFetch, uri, err)
+	}
+	return &Policy{
+		URI:    uri,
+		Digest: intoto.DigestSet{"sha256": digest},
+		files:  files.files,
+	}, nil
+}
+
+// Open returns the org policy at orgPath, relative to the root of the
+// source, and an iterator over the other policy files of the source,
+// in lexical order of their path. The ID of a project policy is its
+// path relative to the root. The org policy defaults to org.json,
+// org.yaml or org.yml at the root if orgPath is empty.
+func (p *Policy) Open(orgPath string) (io.ReadCloser, iterator.NamedReadCloserIterator, error) {
+	orgPath = path.Clean("/" + orgPath)[1:]
+	if orgPath == "" {
+		for _, name := range orgPolicies {
+			if _, exists := p.files[name]; exists {
+				orgPath = name
+				break
+			}
+		}
+	}
+	org, exists := p.files[orgPath]
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: no org policy found in (%q)", errs.ErrorNotFound, p.URI)
+	}
+	var names []string
+	for name := range p.files {
+		if name != orgPath && slices.Contains(policyExtension, path.Ext(name)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return io.NopCloser(bytes.NewReader(org)), &filesIterator{files: p.files, names: names, index: -1}, nil
+}
+
+// ReadFile returns the content of a file of the source,
+// e.g. a module of custom rules.
+func (p *Policy) ReadFile(name string) ([]byte, error) {
+	content, exists := p.files[path.Clean("/" + filepath.ToSlash(name))[1:]]
+	if !exists {
+		return nil, fmt.Errorf("%w: file (%q) not found in (%q)", errs.ErrorNotFound, name, p.URI)
+	}
+	return bytes.Clone(content), nil
+}
+
+// CreationOptions returns the options recording the source
+// and its digest in a deployment attestation.
+func (p *Policy) CreationOptions() []deployment.AttestationCreationOption {
+	return []deployment.AttestationCreationOption{
+		deployment.WithPolicy("policy", p.URI, p.Digest),
+	}
+}
+
+type filesIterator struct {
+	files map[string][]byte
+	names []string
+	index int
+}
+
+func (iter *filesIterator) Next() (string, io.ReadCloser) {
+	iter.index++
+	name := iter.names[iter.index]
+	return name, io.NopCloser(bytes.NewReader(iter.files[name]))
+}
+
+func (iter *filesIterator) HasNext() bool {
+	return iter.index+1 < len(iter.names)
+}
+
+func (iter *filesIterator) Error() error {
+	return nil
+}
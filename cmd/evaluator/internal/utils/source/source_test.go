@@ -0,0 +1,430 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+type file struct {
+	name    string
+	content string
+}
+
+func archive(t *testing.T, compress bool, files ...file) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(f.content))}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatalf("failed to write content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatalf("failed to close archive: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readAllFiles returns the org policy and the project policies of p.
+func readAllFiles(t *testing.T, p *Policy, orgPath string) (string, map[string]string, error) {
+	org, projects, err := p.Open(orgPath)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := io.ReadAll(org)
+	if err != nil {
+		t.Fatalf("failed to read org policy: %v", err)
+	}
+	files := make(map[string]string)
+	for projects.HasNext() {
+		id, reader := projects.Next()
+		c, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read project policy: %v", err)
+		}
+		files[id] = string(c)
+	}
+	return string(content), files, projects.Error()
+}
+
+func Test_fetchHTTPS(t *testing.T) {
+	t.Parallel()
+	content := archive(t, true,
+		file{name: "./org.json", content: "org"},
+		file{name: "projects/servers.json", content: "servers"},
+		file{name: "projects/rules.rego", content: "rules"},
+	)
+	duplicate := archive(t, false, file{name: "org.json"}, file{name: "./org.json"})
+	escape := archive(t, false, file{name: "../org.json"})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/policies.tar.gz":
+			w.Write(content)
+		case "/duplicate.tar":
+			w.Write(duplicate)
+		case "/escape.tar":
+			w.Write(escape)
+		case "/invalid.tar":
+			io.WriteString(w, "not an archive")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	tests := []struct {
+		name     string
+		location string
+		uri      string
+		digest   intoto.DigestSet
+		projects map[string]string
+		expected error
+	}{
+		{
+			name:     "valid source",
+			location: server.URL + "/policies.tar.gz#sha256=" + sha256Hex(content),
+			uri:      server.URL + "/policies.tar.gz",
+			digest:   intoto.DigestSet{"sha256": sha256Hex(content)},
+			projects: map[string]string{"projects/servers.json": "servers"},
+		},
+		{
+			name:     "digest mismatch",
+			location: server.URL + "/policies.tar.gz#sha256=" + sha256Hex([]byte("other")),
+			expected: utils.ErrorPolicyDigest,
+		},
+		{
+			name:     "not found",
+			location: server.URL + "/other.tar.gz#sha256=" + sha256Hex(content),
+			expected: utils.ErrorPolicyFetch,
+		},
+		{
+			name:     "invalid archive",
+			location: server.URL + "/invalid.tar#sha256=" + sha256Hex([]byte("not an archive")),
+			expected: utils.ErrorPolicyFetch,
+		},
+		{
+			name:     "duplicate file",
+			location: server.URL + "/duplicate.tar#sha256=" + sha256Hex(duplicate),
+			expected: utils.ErrorPolicyFetch,
+		},
+		{
+			name:     "file outside root",
+			location: server.URL + "/escape.tar#sha256=" + sha256Hex(escape),
+			expected: utils.ErrorPolicyFetch,
+		},
+		{
+			name:     "no digest",
+			location: server.URL + "/policies.tar.gz",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid digest",
+			location: server.URL + "/policies.tar.gz#sha256=abc",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := fetchHTTPS(server.Client(), tt.location)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.uri, p.URI); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.digest, p.Digest); diff != "" {
+				t.Fatalf("unexpected digest (-want +got): \n%s", diff)
+			}
+			org, projects, err := readAllFiles(t, p, "")
+			if err != nil {
+				t.Fatalf("failed to open policy: %v", err)
+			}
+			if diff := cmp.Diff("org", org); diff != "" {
+				t.Fatalf("unexpected org policy (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.projects, projects); diff != "" {
+				t.Fatalf("unexpected projects (-want +got): \n%s", diff)
+			}
+			rules, err := p.ReadFile("projects/../projects/rules.rego")
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if diff := cmp.Diff("rules", string(rules)); diff != "" {
+				t.Fatalf("unexpected file (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_fetchOCI(t *testing.T) {
+	t.Parallel()
+	tarball := archive(t, true,
+		file{name: "org.yaml", content: "org"},
+		file{name: "servers.json", content: "servers"},
+	)
+	img, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: static.NewLayer(tarball, types.OCILayer)},
+		mutate.Addendum{
+			Layer:       static.NewLayer([]byte("echo"), "application/json"),
+			Annotations: map[string]string{titleAnnotation: "projects/echo.json"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to create artifact: %v", err)
+	}
+	hash, err := img.Digest()
+	if err != nil {
+		t.Fatalf("failed to get digest: %v", err)
+	}
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	// NOTE: the manifest of the artifact is served for the
+	// other digest, so that it does not match the requested digest.
+	digest := hash.String()
+	otherDigest := "sha256:" + sha256Hex([]byte("other"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/manifests/"+otherDigest) {
+			r.URL.Path = strings.TrimSuffix(r.URL.Path, otherDigest) + digest
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tag, err := name.NewTag(host+"/policies:v1", name.Insecure)
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("failed to write artifact: %v", err)
+	}
+	tests := []struct {
+		name     string
+		ref      string
+		uri      string
+		projects map[string]string
+		expected error
+	}{
+		{
+			name: "valid artifact",
+			ref:  host + "/policies@" + digest,
+			uri:  "oci://" + host + "/policies",
+			projects: map[string]string{
+				"projects/echo.json": "echo",
+				"servers.json":       "servers",
+			},
+		},
+		{
+			name:     "digest mismatch",
+			ref:      host + "/policies@" + otherDigest,
+			expected: utils.ErrorPolicyDigest,
+		},
+		{
+			name:     "not found",
+			ref:      host + "/other@" + digest,
+			expected: utils.ErrorPolicyFetch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ref, err := name.NewDigest(tt.ref, name.Insecure)
+			if err != nil {
+				t.Fatalf("failed to parse reference: %v", err)
+			}
+			p, err := fetchOCI(ref)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.uri, p.URI); diff != "" {
+				t.Fatalf("unexpected uri (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(intoto.DigestSet{"sha256": hash.Hex}, p.Digest); diff != "" {
+				t.Fatalf("unexpected digest (-want +got): \n%s", diff)
+			}
+			org, projects, err := readAllFiles(t, p, "org.yaml")
+			if err != nil {
+				t.Fatalf("failed to open policy: %v", err)
+			}
+			if diff := cmp.Diff("org", org); diff != "" {
+				t.Fatalf("unexpected org policy (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.projects, projects); diff != "" {
+				t.Fatalf("unexpected projects (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Open(t *testing.T) {
+	t.Parallel()
+	p := &Policy{
+		URI: "https://example.com/policies.tar.gz",
+		files: map[string][]byte{
+			"org/org.json":   []byte("org"),
+			"servers.json":   []byte("servers"),
+			"README.md":      []byte("readme"),
+			"echo/echo.yaml": []byte("echo"),
+		},
+	}
+	tests := []struct {
+		name     string
+		orgPath  string
+		projects map[string]string
+		expected error
+	}{
+		{
+			name:    "org path",
+			orgPath: "./org/org.json",
+			projects: map[string]string{
+				"echo/echo.yaml": "echo",
+				"servers.json":   "servers",
+			},
+		},
+		{
+			name:     "no default org policy",
+			expected: errs.ErrorNotFound,
+		},
+		{
+			name:     "org path not found",
+			orgPath:  "org.json",
+			expected: errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, projects, err := readAllFiles(t, p, tt.orgPath)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.projects, projects); diff != "" {
+				t.Fatalf("unexpected projects (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_IsRemote(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		location string
+		expected bool
+	}{
+		{location: "oci://ghcr.io/org/policies@sha256:abc", expected: true},
+		{location: "https://example.com/policies.tar.gz#sha256=abc", expected: true},
+		{location: "http://example.com/policies.tar.gz"},
+		{location: "./policies"},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.location, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, IsRemote(tt.location)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_fileSetAdd(t *testing.T) {
+	t.Parallel()
+	half := make([]byte, maxSize/2)
+	tests := []struct {
+		name  string
+		files []file
+		err   bool
+	}{
+		{
+			name:  "files",
+			files: []file{{name: "org.json"}, {name: "./servers/api.json"}},
+		},
+		{
+			name:  "max size",
+			files: []file{{name: "a.json", content: string(half)}, {name: "b.json", content: string(half)}},
+		},
+		{
+			name: "exceeds max size",
+			files: []file{{name: "a.json", content: string(half)}, {name: "b.json", content: string(half)},
+				{name: "c.json", content: "x"}},
+			err: true,
+		},
+		{
+			name:  "duplicate",
+			files: []file{{name: "org.json"}, {name: "./org.json"}},
+			err:   true,
+		},
+		{
+			name:  "outside root",
+			files: []file{{name: "../org.json"}},
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			files := newFileSet()
+			var err error
+			for _, f := range tt.files {
+				if err = files.add(f.name, []byte(f.content)); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sourceDateEpochEnv is the environment variable that sets the creation
+// time of attestations, see https://reproducible-builds.org/specs/source-date-epoch/.
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// SourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH
+// environment variable, or false if it is not set.
+func SourceDateEpoch() (time.Time, bool, error) {
+	value, exists := os.LookupEnv(sourceDateEpochEnv)
+	if !exists || value == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := ParseSourceDateEpoch(value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// ParseSourceDateEpoch parses a number of seconds since
+// the Unix epoch, e.g. 1714557600, as a UTC time.
+func ParseSourceDateEpoch(value string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, fmt.Errorf("invalid %s (%q): must be a positive number of seconds", sourceDateEpochEnv, value)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
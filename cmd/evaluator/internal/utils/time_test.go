@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ParseSourceDateEpoch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Time
+		err      bool
+	}{
+		{
+			name:     "seconds",
+			value:    "1714557600",
+			expected: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "zero",
+			value: "0",
+			err:   true,
+		},
+		{
+			name:  "negative",
+			value: "-1",
+			err:   true,
+		},
+		{
+			name:  "fractional seconds",
+			value: "1714557600.5",
+			err:   true,
+		},
+		{
+			name:  "date",
+			value: "2024-05-01T10:00:00Z",
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			value, err := ParseSourceDateEpoch(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, value); diff != "" {
+				t.Fatalf("unexpected time (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
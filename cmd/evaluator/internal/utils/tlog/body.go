@@ -0,0 +1,132 @@
+package tlog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+var errorEntryBody = errors.New("entry does not record the envelope")
+
+// hash is a hash of a Rekor entry body.
+type hash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// entryBody is the canonicalized body of a Rekor entry of kind
+// dsse v0.0.1 or intoto v0.0.2, the kinds of DSSE envelopes.
+type entryBody struct {
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	Spec       struct {
+		// dsse v0.0.1.
+		PayloadHash *hash `json:"payloadHash"`
+		Signatures  []struct {
+			Signature string `json:"signature"`
+			Verifier  string `json:"verifier"`
+		} `json:"signatures"`
+		// intoto v0.0.2.
+		Content *struct {
+			PayloadHash *hash `json:"payloadHash"`
+			Envelope    struct {
+				Signatures []struct {
+					Sig       string `json:"sig"`
+					PublicKey string `json:"publicKey"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+		} `json:"content"`
+	} `json:"spec"`
+}
+
+// entrySignature is a signature recorded in an entry body and the
+// PEM-encoded key or certificate it verifies with, both base64-encoded.
+type entrySignature struct {
+	sig, verifier string
+}
+
+// VerifyEntryBody verifies that the body of a Rekor entry, base64-encoded
+// as returned by the API and stored in bundles, records the envelope: its
+// payload and at least one of its signatures. It returns the PEM-encoded
+// keys or certificates of the recorded signatures of the envelope.
+// NOTE: the inclusion proof of an entry only proves that its body
+// is in the log, not that it records a given envelope.
+func VerifyEntryBody(body string, envelope *intoto.Envelope) ([][]byte, error) {
+	if envelope == nil {
+		return nil, fmt.Errorf("%w: envelope is nil", errorEntryBody)
+	}
+	content, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry body: %w", err)
+	}
+	var entry entryBody
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("invalid entry body: %w", err)
+	}
+	var payloadHash *hash
+	var sigs []entrySignature
+	switch {
+	case entry.Kind == "dsse" && entry.APIVersion == "0.0.1":
+		payloadHash = entry.Spec.PayloadHash
+		for _, s := range entry.Spec.Signatures {
+			sigs = append(sigs, entrySignature{sig: s.Signature, verifier: s.Verifier})
+		}
+	case entry.Kind == "intoto" && entry.APIVersion == "0.0.2" && entry.Spec.Content != nil:
+		payloadHash = entry.Spec.Content.PayloadHash
+		for _, s := range entry.Spec.Content.Envelope.Signatures {
+			// NOTE: intoto v0.0.2 encodes the signatures twice.
+			sig, err := base64.StdEncoding.DecodeString(s.Sig)
+			if err != nil {
+				return nil, fmt.Errorf("invalid entry signature: %w", err)
+			}
+			sigs = append(sigs, entrySignature{sig: string(sig), verifier: s.PublicKey})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported entry kind (%q) version (%q)", entry.Kind, entry.APIVersion)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	digest := sha256.Sum256(payload)
+	if payloadHash == nil || payloadHash.Algorithm != "sha256" || payloadHash.Value != hex.EncodeToString(digest[:]) {
+		return nil, fmt.Errorf("%w: payload hash mismatch", errorEntryBody)
+	}
+	var verifiers [][]byte
+	for _, s := range sigs {
+		if !hasSignature(envelope, s.sig) {
+			continue
+		}
+		verifier, err := base64.StdEncoding.DecodeString(s.verifier)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry verifier: %w", err)
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	if len(verifiers) == 0 {
+		return nil, fmt.Errorf("%w: no signature of the envelope", errorEntryBody)
+	}
+	return verifiers, nil
+}
+
+// hasSignature returns whether sig, base64-encoded,
+// is one of the signatures of the envelope.
+func hasSignature(envelope *intoto.Envelope, sig string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil || len(decoded) == 0 {
+		return false
+	}
+	for _, s := range envelope.Signatures {
+		envSig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err == nil && bytes.Equal(envSig, decoded) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package tlog
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_VerifyEntryBody(t *testing.T) {
+	t.Parallel()
+	verifier := []byte("-----BEGIN PUBLIC KEY-----\nkey\n-----END PUBLIC KEY-----\n")
+	newEnvelope := func(payload, sig string) *intoto.Envelope {
+		return &intoto.Envelope{
+			PayloadType: intoto.PayloadType,
+			Payload:     base64.StdEncoding.EncodeToString([]byte(payload)),
+			Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString([]byte(sig))}},
+		}
+	}
+	envelope := newEnvelope(`{"predicateType":"a"}`, "sig")
+	otherEnvelope := newEnvelope(`{"predicateType":"b"}`, "other sig")
+	payloadHash := func(envelope *intoto.Envelope) string {
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		digest := sha256.Sum256(payload)
+		return hex.EncodeToString(digest[:])
+	}
+	dsseBody := func(envelope *intoto.Envelope) string {
+		body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"dsse","spec":{"payloadHash":{"algorithm":"sha256","value":%q},`+
+			`"signatures":[{"signature":%q,"verifier":%q}]}}`,
+			payloadHash(envelope), envelope.Signatures[0].Sig, base64.StdEncoding.EncodeToString(verifier))
+		return base64.StdEncoding.EncodeToString([]byte(body))
+	}
+	intotoBody := func(envelope *intoto.Envelope) string {
+		body := fmt.Sprintf(`{"apiVersion":"0.0.2","kind":"intoto","spec":{"content":{"payloadHash":{"algorithm":"sha256","value":%q},`+
+			`"envelope":{"signatures":[{"sig":%q,"publicKey":%q}]}}}}`,
+			payloadHash(envelope), base64.StdEncoding.EncodeToString([]byte(envelope.Signatures[0].Sig)),
+			base64.StdEncoding.EncodeToString(verifier))
+		return base64.StdEncoding.EncodeToString([]byte(body))
+	}
+	tests := []struct {
+		name      string
+		body      string
+		verifiers [][]byte
+		expected  error
+	}{
+		{
+			name:      "dsse entry",
+			body:      dsseBody(envelope),
+			verifiers: [][]byte{verifier},
+		},
+		{
+			name:      "intoto entry",
+			body:      intotoBody(envelope),
+			verifiers: [][]byte{verifier},
+		},
+		{
+			name:     "dsse entry of another envelope",
+			body:     dsseBody(otherEnvelope),
+			expected: errorEntryBody,
+		},
+		{
+			name:     "intoto entry of another envelope",
+			body:     intotoBody(otherEnvelope),
+			expected: errorEntryBody,
+		},
+		{
+			name: "other signature",
+			body: dsseBody(&intoto.Envelope{
+				Payload:    envelope.Payload,
+				Signatures: otherEnvelope.Signatures,
+			}),
+			expected: errorEntryBody,
+		},
+		{
+			name:     "unsupported kind",
+			body:     base64.StdEncoding.EncodeToString([]byte(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{}}`)),
+			expected: cmpopts.AnyError,
+		},
+		{
+			name:     "invalid body",
+			body:     "not base64!",
+			expected: cmpopts.AnyError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifiers, err := VerifyEntryBody(tt.body, envelope)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.verifiers, verifiers); diff != "" {
+				t.Fatalf("unexpected verifiers (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package tlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/rekor"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/generated/client/index"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore/pkg/tuf"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// DefaultRekorURL is the URL of the public Rekor instance.
+const DefaultRekorURL = "https://rekor.sigstore.dev"
+
+var (
+	errorEntryNotFound = errors.New("entry not found")
+	errorLogPublicKey  = errors.New("no public key for the log")
+)
+
+// Rekor is a transparency log backed by a Rekor instance.
+// It implements deployment.TransparencyLog and publish.TransparencyLog.
+// Envelopes are recorded as DSSE entries.
+type Rekor struct {
+	client *client.Rekor
+	// publicKey is the PEM-encoded key or certificate
+	// that verifies the signatures of the envelopes.
+	publicKey []byte
+	// logPublicKeys verify the entries of the log. If nil,
+	// the keys of the public instance are used.
+	logPublicKeys *cosign.TrustedTransparencyLogPubKeys
+}
+
+// RekorNew creates a client for the Rekor instance at url.
+// publicKey is only required to upload envelopes. logPublicKey is the
+// PEM-encoded public key of the log, which verifies its entries. It is
+// required for any instance other than the public one at DefaultRekorURL,
+// whose keys are retrieved from the Sigstore TUF root.
+func RekorNew(url string, publicKey, logPublicKey []byte) (*Rekor, error) {
+	r := Rekor{
+		publicKey: publicKey,
+	}
+	switch {
+	case len(logPublicKey) != 0:
+		keys := cosign.NewTrustedTransparencyLogPubKeys()
+		if err := keys.AddTransparencyLogPubKey(logPublicKey, tuf.Active); err != nil {
+			return nil, fmt.Errorf("invalid log public key: %w", err)
+		}
+		r.logPublicKeys = &keys
+	case url != DefaultRekorURL:
+		return nil, fmt.Errorf("%w: (%q) is not the public instance", errorLogPublicKey, url)
+	}
+	c, err := rekor.NewClient(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rekor client: %w", err)
+	}
+	r.client = c
+	return &r, nil
+}
+
+// Upload records the signed envelope and returns the UUID of the entry.
+func (r *Rekor) Upload(ctx context.Context, envelope []byte) (string, error) {
+	_, entryID, err := r.UploadEntry(ctx, envelope)
+	return entryID, err
+}
+
+// UploadEntry records the signed envelope and returns the entry
+// and its UUID. The entry is needed to create a cosign bundle.
+func (r *Rekor) UploadEntry(ctx context.Context, envelope []byte) (*models.LogEntryAnon, string, error) {
+	if len(r.publicKey) == 0 {
+		return nil, "", fmt.Errorf("no public key to upload the envelope")
+	}
+	entry, err := cosign.TLogUploadDSSEEnvelope(ctx, r.client, envelope, r.publicKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to upload envelope: %w", err)
+	}
+	entryID, err := EntryID(entry)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry, entryID, nil
+}
+
+// VerifyInclusion looks up the entries of the envelope by its
+// hash and verifies the inclusion proof and the signed entry timestamp
+// of the entries against the keys of the log. An entry must record
+// the payload and a signature of the envelope, see VerifyEntryBody.
+func (r *Rekor) VerifyInclusion(ctx context.Context, envelope []byte) error {
+	dsse, err := intoto.ParseEnvelope(envelope)
+	if err != nil {
+		return err
+	}
+	if dsse == nil {
+		return fmt.Errorf("%w: not a DSSE envelope", errorEntryBody)
+	}
+	sum := sha256.Sum256(envelope)
+	params := index.NewSearchIndexParamsWithContext(ctx)
+	params.SetQuery(&models.SearchIndex{
+		Hash: "sha256:" + hex.EncodeToString(sum[:]),
+	})
+	resp, err := r.client.Index.SearchIndex(params)
+	if err != nil {
+		return fmt.Errorf("failed to search index: %w", err)
+	}
+	if len(resp.Payload) == 0 {
+		return errorEntryNotFound
+	}
+	pubKeys, err := r.pubKeys(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, uuid := range resp.Payload {
+		entry, err := cosign.GetTlogEntry(ctx, r.client, uuid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+			continue
+		}
+		if err := cosign.VerifyTLogEntryOffline(ctx, entry, pubKeys); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+			continue
+		}
+		body, ok := entry.Body.(string)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: invalid entry body type: %T", uuid, entry.Body))
+			continue
+		}
+		if _, err := VerifyEntryBody(body, dsse); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %w", errorEntryNotFound, errors.Join(errs...))
+}
+
+// pubKeys returns the keys that verify the entries of the log.
+func (r *Rekor) pubKeys(ctx context.Context) (*cosign.TrustedTransparencyLogPubKeys, error) {
+	if r.logPublicKeys != nil {
+		return r.logPublicKeys, nil
+	}
+	pubKeys, err := cosign.GetRekorPubs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rekor public keys: %w", err)
+	}
+	return pubKeys, nil
+}
+
+// EntryID returns the UUID of an entry, i.e., the hex-encoded
+// leaf hash of its body in the log.
+func EntryID(entry *models.LogEntryAnon) (string, error) {
+	if entry == nil {
+		return "", fmt.Errorf("entry is nil")
+	}
+	encoded, ok := entry.Body.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid entry body type: %T", entry.Body)
+	}
+	body, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode entry body: %w", err)
+	}
+	return hex.EncodeToString(rfc6962.DefaultHasher.HashLeaf(body)), nil
+}
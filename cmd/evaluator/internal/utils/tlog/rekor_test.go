@@ -0,0 +1,112 @@
+package tlog
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/sigstore/rekor/pkg/generated/models"
+)
+
+func Test_EntryID(t *testing.T) {
+	t.Parallel()
+	body := []byte(`{"kind":"dsse"}`)
+	leaf := sha256.Sum256(append([]byte{0}, body...))
+	tests := []struct {
+		name     string
+		entry    *models.LogEntryAnon
+		entryID  string
+		expected bool
+	}{
+		{
+			name:    "valid entry",
+			entry:   &models.LogEntryAnon{Body: base64.StdEncoding.EncodeToString(body)},
+			entryID: hex.EncodeToString(leaf[:]),
+		},
+		{
+			name:     "nil entry",
+			expected: true,
+		},
+		{
+			name:     "invalid body type",
+			entry:    &models.LogEntryAnon{Body: body},
+			expected: true,
+		},
+		{
+			name:     "invalid body encoding",
+			entry:    &models.LogEntryAnon{Body: "not base64!"},
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			entryID, err := EntryID(tt.entry)
+			if (err != nil) != tt.expected {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(tt.entryID, entryID); diff != "" {
+				t.Fatalf("unexpected entry ID (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_RekorNew(t *testing.T) {
+	t.Parallel()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	logPublicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	tests := []struct {
+		name         string
+		url          string
+		logPublicKey []byte
+		expected     error
+	}{
+		{
+			name: "public instance",
+			url:  DefaultRekorURL,
+		},
+		{
+			name:         "custom instance with key",
+			url:          "https://rekor.example.com",
+			logPublicKey: logPublicKey,
+		},
+		{
+			name:     "custom instance without key",
+			url:      "https://rekor.example.com",
+			expected: errorLogPublicKey,
+		},
+		{
+			name:         "invalid key",
+			url:          "https://rekor.example.com",
+			logPublicKey: []byte("not a key"),
+			expected:     cmpopts.AnyError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := RekorNew(tt.url, nil, tt.logPublicKey)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
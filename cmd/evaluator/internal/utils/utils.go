@@ -2,8 +2,12 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files"
 )
 
 func ReadFiles(dir string, ignore string) ([]string, error) {
@@ -38,3 +42,58 @@ func ReadFiles(dir string, ignore string) ([]string, error) {
 func Log(format string, a ...any) {
 	fmt.Fprintf(os.Stderr, format, a...)
 }
+
+// WriteFileAtomic writes the content to a temporary file in the same
+// directory and renames it, so that readers never see a partial file.
+func WriteFileAtomic(path string, content []byte) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	// NOTE: Remove is a no-op once the file is renamed.
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// OpenPolicyDir opens the org policy and returns an iterator over the
+// project policies under policyDir. The org policy defaults to org.json,
+// org.yaml or org.yml under policyDir, and is excluded from the project
+// policies if it is under policyDir.
+func OpenPolicyDir(policyDir, orgPath string) (io.ReadCloser, *files.PolicyIterator, error) {
+	if orgPath == "" {
+		for _, name := range []string{"org.json", "org.yaml", "org.yml"} {
+			p := filepath.Join(policyDir, name)
+			if _, err := os.Stat(p); err == nil {
+				orgPath = p
+				break
+			}
+		}
+		if orgPath == "" {
+			return nil, nil, fmt.Errorf("no org policy found in (%q)", policyDir)
+		}
+	}
+	var opts []files.PolicyIteratorOption
+	if rel, err := filepath.Rel(policyDir, orgPath); err == nil && !strings.HasPrefix(rel, "..") {
+		opts = append(opts, files.WithExclude(filepath.ToSlash(rel)))
+	}
+	projectsReader, err := files.NewPolicyIterator(policyDir, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	organizationReader, err := os.Open(orgPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read org path: %w", err)
+	}
+	return organizationReader, projectsReader, nil
+}
@@ -0,0 +1,39 @@
+package validate
+
+import (
+	"os"
+
+	deployment "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment/validate"
+	publish "github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish/validate"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+)
+
+func usage(cli string) {
+	msg := "" +
+		"Usage: %s validate [options] orgPath projectsPath\n" +
+		"\n" +
+		"Available options:\n" +
+		"publish \t\tValidate the publish policy files\n" +
+		"deployment \t\tValidate the deployment policy files\n" +
+		"\n" +
+		"Every violation is reported, one per line.\n" +
+		"\n"
+	utils.Log(msg, cli)
+	os.Exit(1)
+}
+
+func Run(cli string, args []string) error {
+	if len(args) < 1 {
+		usage(cli)
+	}
+	var err error
+	switch args[0] {
+	default:
+		usage(cli)
+	case "publish":
+		err = publish.Run(cli, args[1:])
+	case "deployment":
+		err = deployment.Run(cli, args[1:])
+	}
+	return err
+}
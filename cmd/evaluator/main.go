@@ -1,11 +1,15 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/deployment"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/inspect"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/publish"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/serve"
 	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/utils"
+	"github.com/slsa-framework/slsa-policy/cli/evaluator/internal/validate"
 )
 
 func usage(prog string) {
@@ -15,11 +19,25 @@ func usage(prog string) {
 		"Available commands:\n" +
 		"publish \t\tOperation on publish policy\n" +
 		"deployment \t\tOperation on deployment policy\n" +
+		"validate \t\tValidate policy files without evaluating them\n" +
+		"serve \t\t\tServe the evaluation of the policies over HTTP\n" +
+		"inspect \t\tPrint and validate a publish or deployment attestation\n" +
 		"\n"
 	utils.Log(msg, prog)
 	os.Exit(1)
 }
 
+// exitPolicySource exits with a distinct code if err is
+// a failure to fetch or verify a remote policy source.
+func exitPolicySource(err error) {
+	switch {
+	case errors.Is(err, utils.ErrorPolicyFetch):
+		os.Exit(7)
+	case errors.Is(err, utils.ErrorPolicyDigest):
+		os.Exit(8)
+	}
+}
+
 func fatal(e error) {
 	utils.Log("error: %v", e)
 	os.Exit(2)
@@ -36,13 +54,33 @@ func main() {
 	case "publish":
 		if err := publish.Run(os.Args[0], arguments[1:]); err != nil {
 			utils.Log(err.Error() + "\n")
+			exitPolicySource(err)
 			os.Exit(2)
 		}
 	case "deployment":
 		if err := deployment.Run(os.Args[0], arguments[1:]); err != nil {
 			utils.Log(err.Error() + "\n")
+			exitPolicySource(err)
+			if errors.Is(err, utils.ErrorStorage) {
+				os.Exit(5)
+			}
 			os.Exit(3)
 		}
+	case "validate":
+		if err := validate.Run(os.Args[0], arguments[1:]); err != nil {
+			utils.Log(err.Error() + "\n")
+			os.Exit(4)
+		}
+	case "serve":
+		if err := serve.Run(os.Args[0], arguments[1:]); err != nil {
+			utils.Log(err.Error() + "\n")
+			os.Exit(6)
+		}
+	case "inspect":
+		if err := inspect.Run(os.Args[0], arguments[1:]); err != nil {
+			utils.Log(err.Error() + "\n")
+			os.Exit(9)
+		}
 	}
 	os.Exit(0)
 }
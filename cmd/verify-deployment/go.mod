@@ -0,0 +1,7 @@
+module github.com/slsa-framework/slsa-policy/cli/verify-deployment
+
+go 1.22
+
+require github.com/slsa-framework/slsa-policy/pkg v0.0.0
+
+replace github.com/slsa-framework/slsa-policy/pkg v0.0.0 => ../../pkg
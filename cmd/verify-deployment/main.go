@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Exit codes.
+const (
+	exitAllow = 0
+	exitDeny  = 1
+	exitUsage = 2
+)
+
+func usage(prog string) {
+	msg := "" +
+		"Usage: %s --attestation path --digest sha256:xxxx --service-account uri [--revocation-list path]\n" +
+		"\n" +
+		"Verifies a deployment attestation offline. Attestations and images\n" +
+		"listed in the revocation list, if set, are denied.\n" +
+		"\n" +
+		"Exit codes:\n" +
+		"0 \t\tThe attestation allows the image to run under the service account\n" +
+		"1 \t\tThe attestation does not allow it\n" +
+		"2 \t\tThe arguments or the attestation file are invalid\n" +
+		"\n"
+	fmt.Fprintf(os.Stderr, msg, prog)
+	os.Exit(exitUsage)
+}
+
+func main() {
+	prog := os.Args[0]
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	fs.Usage = func() { usage(prog) }
+	attestationPath := fs.String("attestation", "", "path to the deployment attestation")
+	digest := fs.String("digest", "", "digest of the image, e.g. sha256:xxxx")
+	serviceAccount := fs.String("service-account", "", "URI of the service account")
+	revocationPath := fs.String("revocation-list", "", "path to a revocation list")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		usage(prog)
+	}
+	if *attestationPath == "" || *digest == "" || *serviceAccount == "" || fs.NArg() != 0 {
+		usage(prog)
+	}
+	os.Exit(run(*attestationPath, *digest, *serviceAccount, *revocationPath))
+}
+
+func run(attestationPath, digest, serviceAccount, revocationPath string) int {
+	alg, value, found := strings.Cut(digest, ":")
+	if !found || alg == "" || value == "" {
+		fmt.Fprintf(os.Stderr, "invalid digest (%q)\n", digest)
+		return exitUsage
+	}
+	var opts []deployment.VerificationOption
+	if revocationPath != "" {
+		reader, err := os.Open(revocationPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read revocation list: %v\n", err)
+			return exitUsage
+		}
+		list, err := deployment.RevocationListNew(reader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to parse revocation list: %v\n", err)
+			return exitUsage
+		}
+		opts = append(opts, deployment.WithRevocations(list))
+	}
+	reader, err := os.Open(attestationPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read attestation: %v\n", err)
+		return exitUsage
+	}
+	verification, err := deployment.VerificationNew(reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse attestation: %v\n", err)
+		return exitUsage
+	}
+	digests := intoto.DigestSet{
+		alg: value,
+	}
+	scopes := map[string]string{
+		deployment.ScopeKeyKubernetesServiceAccount: serviceAccount,
+	}
+	if err := verification.Verify(digests, scopes, opts...); err != nil {
+		// Malformed inputs are not a decision.
+		if errors.Is(err, errs.ErrorInvalidInput) {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitUsage
+		}
+		fmt.Printf("deny: %v\n", err)
+		return exitDeny
+	}
+	fmt.Println("allow")
+	return exitAllow
+}
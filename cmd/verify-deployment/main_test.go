@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_run(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	att, err := deployment.CreationNew(intoto.Subject{Digests: digests}, map[string]string{
+		deployment.ScopeKeyKubernetesServiceAccount: "principal_uri",
+	})
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	dir := t.TempDir()
+	attestationPath := filepath.Join(dir, "att.json")
+	if err := os.WriteFile(attestationPath, content, 0o600); err != nil {
+		t.Fatalf("failed to write attestation: %v", err)
+	}
+	invalidPath := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalidPath, []byte("{"), 0o600); err != nil {
+		t.Fatalf("failed to write attestation: %v", err)
+	}
+	revocationPath := filepath.Join(dir, "revocations.json")
+	if err := os.WriteFile(revocationPath, []byte(`{"format": 1, "revocations": [{"subject": {"sha256":
+		"bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"}, "reason": "compromised builder",
+		"revoked_at": "2024-05-01T00:00:00Z"}]}`), 0o600); err != nil {
+		t.Fatalf("failed to write revocation list: %v", err)
+	}
+	tests := []struct {
+		name            string
+		attestationPath string
+		digest          string
+		serviceAccount  string
+		revocationPath  string
+		expected        int
+	}{
+		{
+			name:            "allow",
+			attestationPath: attestationPath,
+			digest:          "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			serviceAccount:  "principal_uri",
+			expected:        exitAllow,
+		},
+		{
+			name:            "mixed case digest",
+			attestationPath: attestationPath,
+			digest:          "SHA256:BF8260204A85F123E8C486C01057463AE681906DE652202E82C7AA25D9E06BFE",
+			serviceAccount:  "principal_uri",
+			expected:        exitAllow,
+		},
+		{
+			name:            "short digest",
+			attestationPath: attestationPath,
+			digest:          "sha256:bf8260204a85f123",
+			serviceAccount:  "principal_uri",
+			expected:        exitUsage,
+		},
+		{
+			name:            "digest mismatch",
+			attestationPath: attestationPath,
+			digest:          "sha256:77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c",
+			serviceAccount:  "principal_uri",
+			expected:        exitDeny,
+		},
+		{
+			name:            "service account mismatch",
+			attestationPath: attestationPath,
+			digest:          "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			serviceAccount:  "other_uri",
+			expected:        exitDeny,
+		},
+		{
+			name:            "invalid digest",
+			attestationPath: attestationPath,
+			digest:          "some_value",
+			serviceAccount:  "principal_uri",
+			expected:        exitUsage,
+		},
+		{
+			name:            "missing attestation",
+			attestationPath: filepath.Join(dir, "missing.json"),
+			digest:          "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			serviceAccount:  "principal_uri",
+			expected:        exitUsage,
+		},
+		{
+			name:            "invalid attestation",
+			attestationPath: invalidPath,
+			digest:          "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			serviceAccount:  "principal_uri",
+			expected:        exitUsage,
+		},
+		{
+			name:            "revoked digest",
+			attestationPath: attestationPath,
+			digest:          "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			serviceAccount:  "principal_uri",
+			revocationPath:  revocationPath,
+			expected:        exitDeny,
+		},
+		{
+			name:            "invalid revocation list",
+			attestationPath: attestationPath,
+			digest:          "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			serviceAccount:  "principal_uri",
+			revocationPath:  invalidPath,
+			expected:        exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := run(tt.attestationPath, tt.digest, tt.serviceAccount, tt.revocationPath); got != tt.expected {
+				t.Fatalf("unexpected exit code: want %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// AllowUnknownDigestAlgorithms allows project policies to accept
+// digest algorithms that are not defined by the in-toto specification.
+func AllowUnknownDigestAlgorithms() PolicyOption {
+	return func(p *Policy) error {
+		return p.allowUnknownDigestAlgorithms()
+	}
+}
+
+func (p *Policy) allowUnknownDigestAlgorithms() error {
+	p.unknownDigestAlgorithms = true
+	return nil
+}
+
+func (p *Policy) checkDigestAlgorithms() error {
+	if p.unknownDigestAlgorithms {
+		return nil
+	}
+	for _, alg := range p.policy.AcceptedDigestAlgorithms() {
+		if !intoto.IsKnownDigestAlgorithm(alg) {
+			return fmt.Errorf("%w: accepted digest algorithm (%q) is unknown", errs.ErrorInvalidField, alg)
+		}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ type predicate struct {
 	CreationTime    string            `json:"creationTime"`
 	DecisionDetails *decisionDetails  `json:"decisionDetails,omitempty"`
 	Scopes          map[string]string `json:"scopes,omitempty"`
+	Properties      properties        `json:"properties,omitempty"`
 	// TODO: add inputs as a list of intoto.PackageDescriptor, so that we can
 	// indicate which attestations were used.
 }
@@ -20,10 +21,23 @@ type predicate struct {
 type attestation struct {
 	intoto.Header
 	Predicate predicate `json:"predicate"`
+	// version is the predicate version the attestation
+	// was created with, set by translate().
+	version string
 }
 
+type properties map[string]interface{}
+
 const (
-	statementType             = "https://in-toto.io/Statement/v1"
-	predicateType             = "https://slsa.dev/deployment/v0.1"
-	scopeGoogleServiceAccount = "cloud.google.com/service_account/v1"
+	statementType              = intoto.StatementType
+	predicateType              = "https://slsa.dev/deployment/v0.2"
+	predicateTypeV01           = "https://slsa.dev/deployment/v0.1"
+	publishRootProperty        = "slsa.dev/publish/root"
+	buildLevelProperty         = "slsa.dev/build/level"
+	digestAlgorithmsProperty   = "slsa.dev/deployment/digestAlgorithms"
+	exceptionProperty          = "slsa.dev/deployment/exception"
+	evaluationDurationProperty = "slsa.dev/telemetry/evaluationDurationMs"
+	verifierCallsProperty      = "slsa.dev/telemetry/verifierCalls"
+	expiresAtProperty          = "slsa.dev/deployment/expiresAt"
+	nonceProperty              = "slsa.dev/deployment/nonce"
 )
@@ -0,0 +1,78 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// AuditRequest defines a policy evaluation to audit.
+type AuditRequest struct {
+	Digests     intoto.DigestSet
+	PackageName string
+	PolicyID    string
+}
+
+// AuditIterator defines an iterator over audit requests.
+type AuditIterator interface {
+	Next() AuditRequest
+	HasNext() bool
+	Error() error
+}
+
+// AuditItem contains the outcome of a single audit request.
+type AuditItem struct {
+	// Index is the position of the request in the iterator.
+	Index   int
+	Request AuditRequest
+	Result  PolicyEvaluationResult
+}
+
+// AuditStream evaluates the policy for each request and invokes the
+// callback with the outcome as soon as it is known. A failed evaluation
+// is reported in the item's result and does not stop the stream. An error
+// returned by the callback aborts the stream and is returned wrapped.
+// Items are not retained, so memory use does not depend on the number
+// of requests.
+func AuditStream(policy *Policy, requests AuditIterator, opts AttestationVerificationOption,
+	callback func(item AuditItem) error) error {
+	if policy == nil {
+		return fmt.Errorf("%w: policy is nil", errs.ErrorInvalidInput)
+	}
+	if requests == nil {
+		return fmt.Errorf("%w: requests iterator is nil", errs.ErrorInvalidInput)
+	}
+	if callback == nil {
+		return fmt.Errorf("%w: callback is nil", errs.ErrorInvalidInput)
+	}
+	for i := 0; requests.HasNext(); i++ {
+		request := requests.Next()
+		item := AuditItem{
+			Index:   i,
+			Request: request,
+			Result:  policy.Evaluate(request.Digests, request.PackageName, request.PolicyID, opts),
+		}
+		if err := callback(item); err != nil {
+			return fmt.Errorf("callback failed for request %d: %w", i, err)
+		}
+	}
+	if err := requests.Error(); err != nil {
+		return fmt.Errorf("%w: failed to read requests: %w", errs.ErrorInvalidInput, err)
+	}
+	return nil
+}
+
+// Audit evaluates the policy for each request and returns all the items,
+// in order. See AuditStream for large numbers of requests.
+func Audit(policy *Policy, requests AuditIterator, opts AttestationVerificationOption) ([]AuditItem, error) {
+	var items []AuditItem
+	err := AuditStream(policy, requests, opts, func(item AuditItem) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
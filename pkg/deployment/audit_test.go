@@ -0,0 +1,228 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// generatorIterator generates count requests without storing them.
+type generatorIterator struct {
+	count, index int
+	request      func(i int) AuditRequest
+	err          error
+}
+
+func (iter *generatorIterator) Next() AuditRequest {
+	iter.index++
+	return iter.request(iter.index - 1)
+}
+
+func (iter *generatorIterator) HasNext() bool {
+	return iter.index < iter.count
+}
+
+func (iter *generatorIterator) Error() error {
+	return iter.err
+}
+
+func newAuditPolicy(t *testing.T) (*Policy, AttestationVerificationOption, intoto.DigestSet) {
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "publishr_id",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(3),
+			},
+			Principal: project.Principal{
+				URI: "principal_uri",
+			},
+			Packages: []project.Package{
+				{
+					Name: "package_uri",
+				},
+			},
+		},
+	}
+	opts := AttestationVerificationOption{
+		Verifier: NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id", 3),
+	}
+	return newTestPolicy(t, org, projects, time.Now), opts, digests
+}
+
+func Test_AuditStream(t *testing.T) {
+	t.Parallel()
+	pol, opts, digests := newAuditPolicy(t)
+	errCallback := errors.New("callback error")
+	errIterator := errors.New("iterator error")
+	// Even requests are allowed, odd ones are denied.
+	request := func(i int) AuditRequest {
+		if i%2 == 0 {
+			return AuditRequest{Digests: digests, PackageName: "package_uri", PolicyID: "policy_id0"}
+		}
+		return AuditRequest{Digests: digests, PackageName: "other_package_uri", PolicyID: "policy_id0"}
+	}
+	tests := []struct {
+		name        string
+		count       int
+		failAt      int
+		iteratorErr error
+		calls       int
+		expected    error
+	}{
+		{
+			name:   "all requests",
+			count:  5,
+			failAt: -1,
+			calls:  5,
+		},
+		{
+			name:   "callback error aborts",
+			count:  5,
+			failAt: 2,
+			calls:  3,
+			// NOTE: expected is set to errCallback below.
+		},
+		{
+			name:        "iterator error",
+			count:       2,
+			failAt:      -1,
+			iteratorErr: errIterator,
+			calls:       2,
+			expected:    errIterator,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			expected := tt.expected
+			if tt.failAt >= 0 {
+				expected = errCallback
+			}
+			iter := &generatorIterator{count: tt.count, request: request, err: tt.iteratorErr}
+			calls := 0
+			err := AuditStream(pol, iter, opts, func(item AuditItem) error {
+				if diff := cmp.Diff(calls, item.Index); diff != "" {
+					t.Fatalf("unexpected index (-want +got): \n%s", diff)
+				}
+				calls++
+				// Failed evaluations do not stop the stream.
+				if allow := item.Result.Error() == nil; allow != (item.Index%2 == 0) {
+					t.Fatalf("item %d: unexpected result: %v", item.Index, item.Result.Error())
+				}
+				if item.Index == tt.failAt {
+					return errCallback
+				}
+				return nil
+			})
+			if diff := cmp.Diff(expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.calls, calls); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Audit(t *testing.T) {
+	t.Parallel()
+	pol, opts, digests := newAuditPolicy(t)
+	iter := &generatorIterator{
+		count: 3,
+		request: func(i int) AuditRequest {
+			return AuditRequest{Digests: digests, PackageName: "package_uri", PolicyID: fmt.Sprintf("policy_id%d", i)}
+		},
+	}
+	items, err := Audit(pol, iter, opts)
+	if err != nil {
+		t.Fatalf("failed to audit: %v", err)
+	}
+	if diff := cmp.Diff(3, len(items)); diff != "" {
+		t.Fatalf("unexpected items (-want +got): \n%s", diff)
+	}
+	for i := range items {
+		// Only policy_id0 exists.
+		var expected error
+		if i > 0 {
+			expected = errs.ErrorNotFound
+		}
+		if diff := cmp.Diff(expected, items[i].Result.Error(), cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("unexpected err (-want +got): \n%s", diff)
+		}
+	}
+	// Invalid inputs.
+	if _, err := Audit(nil, iter, opts); !errors.Is(err, errs.ErrorInvalidInput) {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := AuditStream(pol, iter, opts, nil); !errors.Is(err, errs.ErrorInvalidInput) {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+// NOTE: this test is not run in parallel so that other
+// tests do not affect the heap measurements.
+func Test_AuditStreamMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	const (
+		count = 100000
+		// Retaining every item would use tens of MB.
+		ceiling = 4 << 20
+	)
+	pol, opts, digests := newAuditPolicy(t)
+	iter := &generatorIterator{
+		count: count,
+		request: func(i int) AuditRequest {
+			return AuditRequest{Digests: digests, PackageName: "package_uri", PolicyID: "policy_id0"}
+		},
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	calls := 0
+	err := AuditStream(pol, iter, opts, func(item AuditItem) error {
+		calls++
+		if item.Index == count-1 {
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to audit: %v", err)
+	}
+	if diff := cmp.Diff(count, calls); diff != "" {
+		t.Fatalf("unexpected calls (-want +got): \n%s", diff)
+	}
+	if after.HeapAlloc > before.HeapAlloc && after.HeapAlloc-before.HeapAlloc > ceiling {
+		t.Fatalf("heap grew by %d bytes, exceeds ceiling %d", after.HeapAlloc-before.HeapAlloc, ceiling)
+	}
+}
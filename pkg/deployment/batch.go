@@ -0,0 +1,48 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/parallel"
+)
+
+// BatchOptions defines options for EvaluateBatch().
+type BatchOptions struct {
+	// Verifier verifies the publish attestations
+	// of every request.
+	Verifier AttestationVerifier
+	// Workers is the maximum number of requests evaluated
+	// concurrently. It defaults to 1. The verifier must be
+	// safe for concurrent use if Workers is greater than 1.
+	Workers int
+}
+
+// EvaluateBatch evaluates each request against the policy, with the same
+// verifier, and returns the results in the order of the requests. A request
+// that cannot be evaluated, e.g. with invalid digests, is reported in its
+// result and does not fail the batch. A request with scopes is denied if
+// the evaluation allows other scopes, see PolicyEvaluationResult.VerifyScopes.
+// It returns an error only if the options are invalid.
+func (p *Policy) EvaluateBatch(requests []EvaluationRequest, opts BatchOptions) ([]PolicyEvaluationResult, error) {
+	if opts.Verifier == nil {
+		return nil, fmt.Errorf("%w: verifier is nil", errs.ErrorInvalidInput)
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = 1
+	}
+	if workers < 1 {
+		return nil, fmt.Errorf("%w: workers (%d) must be positive", errs.ErrorInvalidInput, workers)
+	}
+	verification := AttestationVerificationOption{
+		Verifier: opts.Verifier,
+	}
+	// NOTE: each request writes its own entry, so the
+	// results do not depend on scheduling.
+	results := make([]PolicyEvaluationResult, len(requests))
+	parallel.ForEach(len(requests), workers, func(index int) {
+		results[index] = requests[index].result(p, verification)
+	})
+	return results, nil
+}
@@ -0,0 +1,128 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_EvaluateBatch(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	newProject := func(principalURI string, level int) project.Policy {
+		return project.Policy{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(level),
+			},
+			Principal: project.Principal{
+				URI: principalURI,
+			},
+			Packages: []project.Package{
+				{
+					Name: packageName,
+				},
+			},
+		}
+	}
+	// NOTE: policy IDs are policy_id0, policy_id1, etc.
+	pol := newTestPolicy(t, org, []project.Policy{
+		newProject("principal_uri0", 2),
+		newProject("principal_uri1", 3),
+	}, time.Now)
+	verifier := NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2)
+	requests := []EvaluationRequest{
+		{Digests: digests, PackageName: packageName, PolicyID: "policy_id0"},
+		{Digests: digests, PackageName: packageName, PolicyID: "policy_id1"},
+		{Digests: digests, PackageName: packageName, PolicyID: "unknown_policy_id"},
+		{Digests: intoto.DigestSet{}, PackageName: packageName, PolicyID: "policy_id0"},
+		{Digests: digests, PackageName: packageName, PolicyID: "policy_id0",
+			Scopes: map[string]string{ScopeKubernetesServiceAccount(): "principal_uri0"}},
+		{Digests: digests, PackageName: packageName, PolicyID: "policy_id0",
+			Scopes: map[string]string{ScopeKubernetesServiceAccount(): "principal_uri1"}},
+		{Digests: intoto.DigestSet{"SHA256": strings.ToUpper(digests["sha256"])}, PackageName: packageName,
+			PolicyID: "policy_id0"},
+	}
+	// categories are the categories of the errors of the requests.
+	categories := []string{"", "verification", "not_found", "invalid_field", "", "mismatch", ""}
+	tests := []struct {
+		name     string
+		verifier AttestationVerifier
+		workers  int
+		expected error
+	}{
+		{
+			name:     "default workers",
+			verifier: verifier,
+		},
+		{
+			name:     "concurrent workers",
+			verifier: verifier,
+			workers:  3,
+		},
+		{
+			name:     "nil verifier",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "negative workers",
+			verifier: verifier,
+			workers:  -1,
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			results, err := pol.EvaluateBatch(requests, BatchOptions{
+				Verifier: tt.verifier,
+				Workers:  tt.workers,
+			})
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]string, len(results))
+			for i := range results {
+				if err := results[i].Error(); err != nil {
+					got[i] = errs.Category(err)
+					continue
+				}
+				if diff := cmp.Diff("principal_uri0", results[i].PrincipalURI()); diff != "" {
+					t.Fatalf("unexpected principal (-want +got): \n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(categories, got); diff != "" {
+				t.Fatalf("unexpected categories (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+)
+
+// BundleVerification is a verification for a bundle of attestations,
+// e.g., all the attestations a registry stores for an artifact.
+type BundleVerification struct {
+	verifications []*Verification
+	lines         []int
+	skipped       []error
+}
+
+// VerificationNewBundle creates a verification for a bundle of attestations.
+// A bundle is a stream of newline-delimited in-toto statements or DSSE
+// envelopes, see intoto.BundleStatements. The statements with a predicate
+// type other than the deployment predicate types are ignored. Malformed entries
+// are skipped and reported by Skipped().
+func VerificationNewBundle(reader io.ReadCloser, opts ...ParseOption) (*BundleVerification, error) {
+	defer reader.Close()
+	p, err := parserNew(opts)
+	if err != nil {
+		return nil, err
+	}
+	entries, skipped, err := intoto.BundleStatements(limit.Reader(reader, p.maxSize))
+	if err != nil {
+		return nil, err
+	}
+	bundle := BundleVerification{
+		skipped: skipped,
+	}
+	for _, entry := range entries {
+		var att attestation
+		if err := json.Unmarshal(entry.Statement, &att); err != nil {
+			bundle.skipped = append(bundle.skipped, fmt.Errorf("line %d: %w: failed to unmarshal: %v",
+				entry.Line, errs.ErrorInvalidField, err))
+			continue
+		}
+		if !att.translate() {
+			continue
+		}
+		// The envelope was parsed by BundleStatements already,
+		// so it is either a valid envelope or a statement.
+		dsse, err := intoto.ParseEnvelope(entry.Envelope)
+		if err != nil {
+			return nil, err
+		}
+		verification := verificationNew(att, entry.Envelope)
+		verification.dsse = dsse
+		bundle.verifications = append(bundle.verifications, verification)
+		bundle.lines = append(bundle.lines, entry.Line)
+	}
+	if len(bundle.verifications) == 0 {
+		return nil, errors.Join(append([]error{fmt.Errorf("%w: no attestation with predicate type (%q) in bundle",
+			errs.ErrorNotFound, predicateType)}, bundle.skipped...)...)
+	}
+	return &bundle, nil
+}
+
+// Skipped returns the errors of the malformed entries of the bundle.
+func (b *BundleVerification) Skipped() []error {
+	return b.skipped
+}
+
+// Verify verifies the attestations of the bundle in order and succeeds
+// as soon as one of them passes verification. If none does, the errors
+// of all the attestations are returned.
+func (b *BundleVerification) Verify(digests intoto.DigestSet, scopes map[string]string, options ...VerificationOption) error {
+	return b.VerifyContext(context.Background(), digests, scopes, options...)
+}
+
+// VerifyContext is Verify with a context, see Verification.VerifyContext().
+func (b *BundleVerification) VerifyContext(ctx context.Context, digests intoto.DigestSet, scopes map[string]string, options ...VerificationOption) error {
+	var errList []error
+	for i, verification := range b.verifications {
+		err := verification.VerifyContext(ctx, digests, scopes, options...)
+		if err == nil {
+			return nil
+		}
+		errList = append(errList, fmt.Errorf("attestation at line %d: %w", b.lines[i], err))
+	}
+	return errors.Join(errList...)
+}
@@ -0,0 +1,114 @@
+package deployment
+
+import (
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+)
+
+// PolicyCache reuses a policy as long as the digests of its
+// content do not change. It is safe for concurrent use: a rebuild
+// swaps the cached policy atomically, and callers evaluating the
+// previous policy are not affected.
+type PolicyCache struct {
+	opts   []PolicyOption
+	now    func() time.Time
+	mu     sync.Mutex
+	entry  atomic.Pointer[cacheEntry]
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry struct {
+	policy  *Policy
+	digests intoto.DigestSet
+	builtAt time.Time
+}
+
+// PolicyCacheStats contains statistics about a policy cache.
+type PolicyCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// LastBuild is the time the cached policy was created.
+	// It is zero if no policy was created.
+	LastBuild time.Time `json:"last_build"`
+}
+
+// PolicyCacheNew creates a policy cache. The options are
+// used every time the policy is created.
+func PolicyCacheNew(opts ...PolicyOption) *PolicyCache {
+	return &PolicyCache{
+		opts: append([]PolicyOption{}, opts...),
+		now:  time.Now,
+	}
+}
+
+// Get returns the cached policy if the digests are equal to the
+// digests of the cached policy. Otherwise, it creates the policy
+// from the org and projects and caches it. If the creation fails,
+// the previous policy stays cached.
+// The digests identify the content of the org and projects,
+// e.g. the resource version of a ConfigMap.
+func (c *PolicyCache) Get(digests intoto.DigestSet, org io.ReadCloser, projects iterator.NamedReadCloserIterator) (*Policy, error) {
+	if err := digests.Validate(); err != nil {
+		return nil, err
+	}
+	if policy := c.lookup(digests); policy != nil {
+		c.hits.Add(1)
+		closeReader(org)
+		return policy, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Another caller may have created the policy while we were waiting.
+	if policy := c.lookup(digests); policy != nil {
+		c.hits.Add(1)
+		closeReader(org)
+		return policy, nil
+	}
+	c.misses.Add(1)
+	policy, err := PolicyNew(org, projects, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.entry.Store(&cacheEntry{
+		policy:  policy,
+		digests: maps.Clone(digests),
+		builtAt: c.now(),
+	})
+	return policy, nil
+}
+
+// Stats returns statistics about the cache.
+func (c *PolicyCache) Stats() PolicyCacheStats {
+	stats := PolicyCacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+	if entry := c.entry.Load(); entry != nil {
+		stats.LastBuild = entry.builtAt
+	}
+	return stats
+}
+
+func (c *PolicyCache) lookup(digests intoto.DigestSet) *Policy {
+	entry := c.entry.Load()
+	if entry == nil || !maps.Equal(entry.digests, digests) {
+		return nil
+	}
+	return entry.policy
+}
+
+// closeReader closes a reader that is not used because
+// the policy is cached.
+func closeReader(reader io.ReadCloser) {
+	if reader != nil {
+		reader.Close()
+	}
+}
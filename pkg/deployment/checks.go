@@ -0,0 +1,12 @@
+package deployment
+
+import "github.com/slsa-framework/slsa-policy/pkg/utils/checks"
+
+// addCheck registers a check when called from Verify(),
+// and runs it immediately otherwise.
+func (v *Verification) addCheck(n checks.Check) error {
+	if v.checks == nil {
+		return n.Run()
+	}
+	return v.checks.Add(n)
+}
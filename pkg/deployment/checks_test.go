@@ -0,0 +1,122 @@
+package deployment
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_OptionsOrder(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{
+		"key1": "val1",
+		"key2": "val2",
+	}
+	att := attestation{
+		Header: intoto.Header{
+			Type:          statementType,
+			PredicateType: predicateType,
+			Subjects: []intoto.Subject{
+				{Digests: digests},
+				{Digests: intoto.DigestSet{"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c"}},
+			},
+		},
+		Predicate: predicate{
+			CreationTime: intoto.Now(),
+			Scopes:       scopes,
+		},
+	}
+	tests := []struct {
+		name     string
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name: "all options pass",
+			options: []VerificationOption{
+				ScopeValueMatches("key1", `^val`),
+				ScopeValueMatches("key1", `1$`),
+				ScopeValueMatches("key2", `^val2$`),
+			},
+		},
+		{
+			name: "identical duplicates collapse",
+			options: []VerificationOption{
+				ScopeValueMatches("key1", `^val`),
+				ScopeValueMatches("key1", `^val`),
+			},
+		},
+		{
+			name: "multiple failing options",
+			options: []VerificationOption{
+				ScopeValueMatches("key1", `^other`),
+				ScopeValueMatches("key2", `^other`),
+				RejectForeignSubjects(digests),
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "multiple failing scopes",
+			options: []VerificationOption{
+				ScopeValueMatches("key2", `^other`),
+				ScopeValueMatches("key1", `^other`),
+				ScopeValueMatches("key1", `^val`),
+			},
+			expected: errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			// Verify with the options in different orders.
+			var errStr string
+			for i, options := range permutations(tt.options) {
+				err := verification.Verify(digests, scopes, withUnknownScopes(options...)...)
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if err == nil {
+					continue
+				}
+				if i == 0 {
+					errStr = err.Error()
+				}
+				if diff := cmp.Diff(errStr, err.Error()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+// permutations returns the rotations and reversed rotations of the options.
+func permutations(options []VerificationOption) [][]VerificationOption {
+	var res [][]VerificationOption
+	for i := range options {
+		rotated := append(append([]VerificationOption{}, options[i:]...), options[:i]...)
+		reversed := make([]VerificationOption, len(rotated))
+		for j := range rotated {
+			reversed[len(rotated)-1-j] = rotated[j]
+		}
+		res = append(res, rotated, reversed)
+	}
+	return res
+}
@@ -0,0 +1,55 @@
+package deployment
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Content is a read-only view of an attestation, e.g. to display it.
+// Unlike VerifiedAttestation, it is available before Verify succeeds,
+// so none of its fields are verified, see Validate().
+type Content struct {
+	// PredicateType is the current predicate type, even for
+	// an attestation created with a previous version.
+	PredicateType    string
+	PredicateVersion string
+	Subjects         []intoto.Subject
+	Scopes           map[string]string
+	// CreationTime is the creation time as is, since it may be invalid.
+	CreationTime string
+	Policy       []intoto.ResourceDescriptor
+	Evidence     []intoto.ResourceDescriptor
+	// Properties contains the properties of the attestation.
+	// NOTE: nested values are shared with the verification
+	// and must not be modified.
+	Properties map[string]interface{}
+	// DSSE is set if the attestation was decoded from a DSSE
+	// envelope, and Signatures is the number of signatures of
+	// the envelope. The signatures are not verified.
+	DSSE       bool
+	Signatures int
+}
+
+// Content returns the content of the attestation, verified or not.
+func (v *Verification) Content() *Content {
+	att := &v.attestation
+	content := Content{
+		PredicateType:    att.Header.PredicateType,
+		PredicateVersion: att.version,
+		Subjects:         copySubjects(att.Header.Subjects),
+		Scopes:           maps.Clone(att.Predicate.Scopes),
+		CreationTime:     att.Predicate.CreationTime,
+		Properties:       maps.Clone(att.Predicate.Properties),
+	}
+	if details := att.Predicate.DecisionDetails; details != nil {
+		content.Policy = slices.Clone(details.Policy)
+		content.Evidence = slices.Clone(details.Evidence)
+	}
+	if v.dsse != nil {
+		content.DSSE = true
+		content.Signatures = len(v.dsse.Signatures)
+	}
+	return &content
+}
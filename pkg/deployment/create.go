@@ -1,32 +1,52 @@
 package deployment
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 )
 
 type Creation struct {
 	attestation
-	safeMode bool
+	safeMode         bool
+	telemetry        *telemetry
+	telemetryEnabled bool
+	tlog             TransparencyLog
+}
+
+// telemetry contains data recorded during the policy evaluation.
+type telemetry struct {
+	duration      time.Duration
+	verifierCalls int
 }
 
 type AttestationCreationOption func(*Creation) error
 
 func CreationNew(subject intoto.Subject, scopes map[string]string, options ...AttestationCreationOption) (*Creation, error) {
-	if err := subject.Validate(); err != nil {
+	header, err := intoto.NewStatement(statementType, predicateType, []intoto.Subject{subject})
+	if err != nil {
+		return nil, err
+	}
+	if err := validateScopes(scopes); err != nil {
 		return nil, err
 	}
+	// NOTE: a subject recording an algorithm twice with
+	// conflicting values could never be verified.
+	if alg, conflicts := subject.Digests.ConflictingAlgorithm(); conflicts {
+		return nil, fmt.Errorf("%w: digest (%q) has conflicting values", errs.ErrorInvalidInput, alg)
+	}
 
 	// Validate the digests.
 	att := Creation{
 		attestation: attestation{
-			Header: intoto.Header{
-				Type:          statementType,
-				PredicateType: predicateType,
-				Subjects:      []intoto.Subject{subject},
-			},
+			Header: header,
 			Predicate: predicate{
 				CreationTime: intoto.Now(),
 				Scopes:       scopes,
@@ -39,17 +59,52 @@ func CreationNew(subject intoto.Subject, scopes map[string]string, options ...At
 			return nil, err
 		}
 	}
+	if att.telemetryEnabled {
+		if err := att.writeTelemetry(); err != nil {
+			return nil, err
+		}
+	}
+	if err := att.validateProperties(); err != nil {
+		return nil, err
+	}
 	return &att, nil
 }
 
+// ToBytes returns the JSON encoding of the attestation. The encoding
+// is deterministic: the same attestation is always encoded to the
+// same bytes.
 func (a *Creation) ToBytes() ([]byte, error) {
+	// NOTE: encoding/json sorts map keys, e.g. of the properties,
+	// and serializes struct fields in declaration order.
 	content, err := json.Marshal(a.attestation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal: %v", err)
+		return nil, fmt.Errorf("%w: failed to marshal: %v", errs.ErrorInternal, err)
 	}
 	return content, nil
 }
 
+// Digest returns the sha256 of the bytes returned by ToBytes(),
+// e.g. to name the attestation when it is stored.
+func (a *Creation) Digest() (intoto.DigestSet, error) {
+	content, err := a.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return intoto.DigestSet{
+		"sha256": hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Sign returns a DSSE envelope of the attestation signed by signer.
+func (a *Creation) Sign(ctx context.Context, signer intoto.AttestationSigner) ([]byte, error) {
+	content, err := a.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return intoto.SignStatement(ctx, signer, content)
+}
+
 // TODO: Add support for decision details.
 
 func EnterSafeMode() AttestationCreationOption {
@@ -67,6 +122,244 @@ func (a *Creation) isSafeMode() bool {
 	return a.safeMode
 }
 
+// WithCreationTime sets the creation time of the attestation, which
+// defaults to the current time. It is recorded in UTC with a precision
+// of one second, so that attestations created with the same inputs
+// and creation time have the same content, see ToBytes().
+func WithCreationTime(t time.Time) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withCreationTime(t)
+	}
+}
+
+func (a *Creation) withCreationTime(t time.Time) error {
+	if t.IsZero() {
+		return fmt.Errorf("%w: creation time is zero", errs.ErrorInvalidInput)
+	}
+	a.attestation.Predicate.CreationTime = intoto.FormatTime(t)
+	return nil
+}
+
+func SetPublishRoot(id string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setPublishRoot(id)
+	}
+}
+
+func (a *Creation) setPublishRoot(id string) error {
+	if a.isSafeMode() {
+		return fmt.Errorf("%w: safe mode enabled, cannot edit publish root", errs.ErrorInternal)
+	}
+	if id == "" {
+		return fmt.Errorf("%w: publish root is empty", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[publishRootProperty] = id
+	return nil
+}
+
+func setBuildLevel(level int) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setBuildLevel(level)
+	}
+}
+
+func (a *Creation) setBuildLevel(level int) error {
+	if a.isSafeMode() {
+		return fmt.Errorf("%w: safe mode enabled, cannot edit build level", errs.ErrorInternal)
+	}
+	if err := validateLevel(level); err != nil {
+		return err
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[buildLevelProperty] = level
+	return nil
+}
+
+func setException(exception PolicyException) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setException(exception)
+	}
+}
+
+func (a *Creation) setException(exception PolicyException) error {
+	if a.isSafeMode() {
+		return fmt.Errorf("%w: safe mode enabled, cannot edit exception", errs.ErrorInternal)
+	}
+	if exception.Digest == "" || exception.Reason == "" {
+		return fmt.Errorf("%w: exception digest or reason is empty", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[exceptionProperty] = map[string]string{
+		"digest":  exception.Digest,
+		"reason":  exception.Reason,
+		"expires": exception.Expires.UTC().Format(time.RFC3339),
+	}
+	return nil
+}
+
+func setDigestAlgorithms(algorithms []string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setDigestAlgorithms(algorithms)
+	}
+}
+
+func (a *Creation) setDigestAlgorithms(algorithms []string) error {
+	if a.isSafeMode() {
+		return fmt.Errorf("%w: safe mode enabled, cannot edit digest algorithms", errs.ErrorInternal)
+	}
+	if len(algorithms) == 0 {
+		return fmt.Errorf("%w: digest algorithms are empty", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[digestAlgorithmsProperty] = append([]string{}, algorithms...)
+	return nil
+}
+
+// WithPolicy records the policy that produced the decision, e.g.
+// the URI and commit of the repository containing the policy files.
+// Each policy is identified by a unique name.
+func WithPolicy(name, uri string, digests intoto.DigestSet) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withPolicy(name, uri, digests)
+	}
+}
+
+func (a *Creation) withPolicy(name, uri string, digests intoto.DigestSet) error {
+	if name == "" {
+		return fmt.Errorf("%w: policy name is empty", errs.ErrorInvalidInput)
+	}
+	if err := digests.Validate(); err != nil {
+		return err
+	}
+	if a.attestation.Predicate.DecisionDetails == nil {
+		a.attestation.Predicate.DecisionDetails = &decisionDetails{}
+	}
+	for i := range a.attestation.Predicate.DecisionDetails.Policy {
+		if a.attestation.Predicate.DecisionDetails.Policy[i].Name == name {
+			return fmt.Errorf("%w: policy (%q) is set more than once", errs.ErrorInvalidInput, name)
+		}
+	}
+	ds := make(intoto.DigestSet, len(digests))
+	for k, v := range digests {
+		ds[k] = v
+	}
+	a.attestation.Predicate.DecisionDetails.Policy = append(a.attestation.Predicate.DecisionDetails.Policy,
+		intoto.ResourceDescriptor{
+			Name:   name,
+			URI:    uri,
+			Digest: ds,
+		})
+	return nil
+}
+
+// WithMetadata records a property chosen by the caller, e.g. the ID of
+// the pipeline run that created the attestation. The name must not use
+// the reserved prefix, see properties.go, and is set at most once.
+func WithMetadata(name, value string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withMetadata(name, value)
+	}
+}
+
+func (a *Creation) withMetadata(name, value string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: metadata name is empty", errs.ErrorInvalidInput)
+	}
+	if isReservedProperty(name) {
+		return fmt.Errorf("%w: metadata (%q) uses the reserved prefix (%q)", errs.ErrorInvalidInput,
+			name, reservedPropertyPrefix)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	if _, exists := a.attestation.Predicate.Properties[name]; exists {
+		return fmt.Errorf("%w: metadata (%q) is set more than once", errs.ErrorInvalidInput, name)
+	}
+	a.attestation.Predicate.Properties[name] = value
+	return nil
+}
+
+// WithExpiry sets the time after which the attestation must no longer
+// be trusted, see NotExpired(). It must be after the creation time,
+// and is recorded with the same precision, see WithCreationTime().
+func WithExpiry(t time.Time) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withExpiry(t)
+	}
+}
+
+func (a *Creation) withExpiry(t time.Time) error {
+	if t.IsZero() {
+		return fmt.Errorf("%w: expiry is zero", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[expiresAtProperty] = intoto.FormatTime(t)
+	return nil
+}
+
+// WithNonce records a nonce chosen by the caller, e.g. by the admission
+// controller that requested the deployment, so that the attestation
+// cannot be replayed for another request, see HasNonce().
+func WithNonce(nonce string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withNonce(nonce)
+	}
+}
+
+func (a *Creation) withNonce(nonce string) error {
+	if nonce == "" {
+		return fmt.Errorf("%w: nonce is empty", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[nonceProperty] = nonce
+	return nil
+}
+
+// WithTelemetryProperties records the policy evaluation's duration
+// and number of verifier calls in the attestation properties.
+func WithTelemetryProperties() AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withTelemetryProperties()
+	}
+}
+
+func (a *Creation) withTelemetryProperties() error {
+	a.telemetryEnabled = true
+	return nil
+}
+
+func setTelemetry(t telemetry) AttestationCreationOption {
+	return func(a *Creation) error {
+		a.telemetry = &t
+		return nil
+	}
+}
+
+func (a *Creation) writeTelemetry() error {
+	if a.telemetry == nil {
+		return fmt.Errorf("%w: no telemetry available", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[evaluationDurationProperty] = a.telemetry.duration.Milliseconds()
+	a.attestation.Predicate.Properties[verifierCallsProperty] = a.telemetry.verifierCalls
+	return nil
+}
+
 // Utility functions needed by cosign APIs.
 func (a *Creation) PredicateType() string {
 	return predicateType
@@ -1,7 +1,16 @@
 package deployment
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -14,8 +23,8 @@ func Test_CreationNew(t *testing.T) {
 	t.Parallel()
 	subject := intoto.Subject{
 		Digests: intoto.DigestSet{
-			"sha256":    "some_value",
-			"gitCommit": "another_value",
+			"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
 		},
 	}
 	scopes := map[string]string{
@@ -26,7 +35,6 @@ func Test_CreationNew(t *testing.T) {
 		name     string
 		subject  intoto.Subject
 		scopes   map[string]string
-		policy   map[string]intoto.Policy
 		expected error
 	}{
 		{
@@ -48,7 +56,7 @@ func Test_CreationNew(t *testing.T) {
 			name: "result with empty digest value",
 			subject: intoto.Subject{
 				Digests: intoto.DigestSet{
-					"sha256":    "some_value",
+					"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
 					"gitCommit": "",
 				},
 			},
@@ -59,7 +67,7 @@ func Test_CreationNew(t *testing.T) {
 			name: "result with empty digest key",
 			subject: intoto.Subject{
 				Digests: intoto.DigestSet{
-					"sha256": "some_value",
+					"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
 					"":       "another_value",
 				},
 			},
@@ -103,3 +111,387 @@ func Test_CreationNew(t *testing.T) {
 		})
 	}
 }
+
+func Test_WithPolicy(t *testing.T) {
+	t.Parallel()
+	subject := intoto.Subject{
+		Digests: intoto.DigestSet{
+			"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		},
+	}
+	digests := intoto.DigestSet{
+		"gitCommit": "d58ce22cd8e763354b58513d4de925e045c332d9",
+	}
+	tests := []struct {
+		name     string
+		options  []AttestationCreationOption
+		policy   []intoto.ResourceDescriptor
+		expected error
+	}{
+		{
+			name: "no policy",
+		},
+		{
+			name: "policies set",
+			options: []AttestationCreationOption{
+				WithPolicy("org", "git+https://github.com/org/policies", digests),
+				WithPolicy("projects", "", digests),
+			},
+			policy: []intoto.ResourceDescriptor{
+				{Name: "org", URI: "git+https://github.com/org/policies", Digest: digests},
+				{Name: "projects", Digest: digests},
+			},
+		},
+		{
+			name: "policy set in safe mode",
+			options: []AttestationCreationOption{
+				EnterSafeMode(),
+				WithPolicy("org", "uri", digests),
+			},
+			policy: []intoto.ResourceDescriptor{
+				{Name: "org", URI: "uri", Digest: digests},
+			},
+		},
+		{
+			name: "duplicate policy",
+			options: []AttestationCreationOption{
+				WithPolicy("org", "uri1", digests),
+				WithPolicy("org", "uri2", digests),
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "empty name",
+			options: []AttestationCreationOption{
+				WithPolicy("", "uri", digests),
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "empty digests",
+			options: []AttestationCreationOption{
+				WithPolicy("org", "uri", intoto.DigestSet{}),
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(subject, nil, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			var policy []intoto.ResourceDescriptor
+			if att.Predicate.DecisionDetails != nil {
+				policy = att.Predicate.DecisionDetails.Policy
+			}
+			if diff := cmp.Diff(tt.policy, policy); diff != "" {
+				t.Fatalf("unexpected policy (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+// recordingSigner is a fake signer that records
+// the statement it is asked to sign.
+type recordingSigner struct {
+	statement []byte
+}
+
+func (s *recordingSigner) Sign(ctx context.Context, statement []byte) ([]byte, error) {
+	s.statement = statement
+	return json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString([]byte("signature"))}},
+	})
+}
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes)
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	var signer recordingSigner
+	envelope, err := att.Sign(context.Background(), &signer)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if diff := cmp.Diff(string(statement), string(signer.statement)); diff != "" {
+		t.Fatalf("unexpected signed statement (-want +got): \n%s", diff)
+	}
+	// The envelope is accepted by verification.
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(envelope)))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	if err := verification.Verify(digests, scopes, AllowUnknownScopes(), AllowUnsignedDSSE()); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	_, err = att.Sign(context.Background(), nil)
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Digest(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
+	}
+	scopes := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		scopes[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes,
+		SetPublishRoot("publish_root"),
+		WithPolicy("org", "https://example.com/org.json", intoto.DigestSet{"sha256": "abc", "gitCommit": "def"}),
+		WithPolicy("project", "https://example.com/project.json", intoto.DigestSet{"sha256": "ghi"}))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	sum := sha256.Sum256(statement)
+	expected := intoto.DigestSet{"sha256": hex.EncodeToString(sum[:])}
+	// NOTE: map iteration order is randomized, so repeated
+	// encodings would differ if the encoding depended on it.
+	for i := 0; i < 100; i++ {
+		content, err := att.ToBytes()
+		if err != nil {
+			t.Fatalf("failed to get attestation bytes: %v", err)
+		}
+		if !bytes.Equal(statement, content) {
+			t.Fatalf("unexpected content (-want +got): \n%s", cmp.Diff(string(statement), string(content)))
+		}
+		digest, err := att.Digest()
+		if err != nil {
+			t.Fatalf("failed to get attestation digest: %v", err)
+		}
+		if diff := cmp.Diff(expected, digest); diff != "" {
+			t.Fatalf("unexpected digest (-want +got): \n%s", diff)
+		}
+	}
+}
+
+func Test_WithCreationTime(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name         string
+		creationTime time.Time
+		value        string
+		expected     error
+	}{
+		{
+			name:         "utc time",
+			creationTime: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			value:        "2024-05-01T10:00:00Z",
+		},
+		{
+			name:         "time zone offset",
+			creationTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			value:        "2024-05-01T10:00:00Z",
+		},
+		{
+			name:         "fractional seconds",
+			creationTime: time.Date(2024, 5, 1, 10, 0, 0, 999999999, time.UTC),
+			value:        "2024-05-01T10:00:00Z",
+		},
+		{
+			name:     "zero time",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var contents [][]byte
+			// NOTE: attestations created with the same inputs must have the same content.
+			for i := 0; i < 2; i++ {
+				att, err := CreationNew(intoto.Subject{Digests: digests}, map[string]string{"key": "value"},
+					WithCreationTime(tt.creationTime))
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if err != nil {
+					return
+				}
+				if diff := cmp.Diff(tt.value, att.attestation.Predicate.CreationTime); diff != "" {
+					t.Fatalf("unexpected creation time (-want +got): \n%s", diff)
+				}
+				content, err := att.ToBytes()
+				if err != nil {
+					t.Fatalf("failed to get attestation bytes: %v", err)
+				}
+				contents = append(contents, content)
+			}
+			if !bytes.Equal(contents[0], contents[1]) {
+				t.Fatalf("unexpected content (-want +got): \n%s", cmp.Diff(string(contents[0]), string(contents[1])))
+			}
+		})
+	}
+}
+
+func Test_WithMetadata(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		options    []AttestationCreationOption
+		properties properties
+		expected   error
+	}{
+		{
+			name:       "single metadata",
+			options:    []AttestationCreationOption{WithMetadata("run", "1234")},
+			properties: properties{"run": "1234"},
+		},
+		{
+			name: "multiple metadata",
+			options: []AttestationCreationOption{
+				WithMetadata("run", "1234"),
+				WithMetadata("example.com/team", "platform"),
+			},
+			properties: properties{"run": "1234", "example.com/team": "platform"},
+		},
+		{
+			name: "metadata with nonce and expiry",
+			options: []AttestationCreationOption{
+				WithMetadata("run", "1234"),
+				WithNonce("nonce"),
+				WithExpiry(time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)),
+				WithCreationTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+			},
+			properties: properties{
+				"run":             "1234",
+				nonceProperty:     "nonce",
+				expiresAtProperty: "2024-05-01T11:00:00Z",
+			},
+		},
+		{
+			name:     "empty name",
+			options:  []AttestationCreationOption{WithMetadata(" ", "1234")},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "reserved name",
+			options:  []AttestationCreationOption{WithMetadata("SLSA.dev/run", "1234")},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "duplicate name",
+			options: []AttestationCreationOption{
+				WithMetadata("run", "1234"),
+				WithMetadata("run", "5678"),
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty nonce",
+			options:  []AttestationCreationOption{WithNonce("")},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.properties, properties(att.attestation.Predicate.Properties)); diff != "" {
+				t.Fatalf("unexpected properties (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_WithExpiry(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	creationTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		expiry   time.Time
+		value    string
+		expected error
+	}{
+		{
+			name:   "after creation",
+			expiry: creationTime.Add(time.Hour),
+			value:  "2024-05-01T11:00:00Z",
+		},
+		{
+			name:   "time zone offset",
+			expiry: time.Date(2024, 5, 1, 13, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			value:  "2024-05-01T11:00:00Z",
+		},
+		{
+			name:     "at creation",
+			expiry:   creationTime,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "within the same second",
+			expiry:   creationTime.Add(time.Millisecond),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "before creation",
+			expiry:   creationTime.Add(-time.Hour),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "zero time",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// NOTE: the expiry is set before the creation time
+			// to verify that the order of the options does not matter.
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil,
+				WithExpiry(tt.expiry), WithCreationTime(creationTime))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.value, att.attestation.Predicate.Properties[expiresAtProperty]); diff != "" {
+				t.Fatalf("unexpected expiry (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
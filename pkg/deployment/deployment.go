@@ -1,14 +1,25 @@
 package deployment
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal"
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/logging"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/retry"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/semver"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 // AttestationVerifierPublishOptions defines options for
@@ -16,7 +27,44 @@ import (
 type AttestationVerifierPublishOptions struct {
 	// One of PublishrID or PublishrIDRegex must be set.
 	PublishrID, PublishrIDRegex string
-	BuildLevel                  int
+	// MinBuildLevel is the minimum SLSA build level required by the policy.
+	// Attestations at this level or above must be accepted.
+	MinBuildLevel int
+	// Deprecated: use MinBuildLevel. It is set to the same value.
+	BuildLevel int
+	// Identity, if set, is the certificate identity of the publish root
+	// defined by the organization policy. PublishrIDRegex is then set
+	// to its subject regex, and the verifier must also verify the issuer.
+	Identity *RootIdentity
+	// MinAuthorVersion, if set, is the minimum semantic version of the tool
+	// that created the attestation, as required by the organization policy.
+	// The verifier must reject attestations created by older versions,
+	// see VerifyAuthorVersion().
+	MinAuthorVersion string
+}
+
+// VerifyAuthorVersion verifies the version of the author recorded in a
+// publish attestation against MinAuthorVersion, so that verifiers compare
+// versions uniformly. It returns errs.ErrorMismatch if the version is lower,
+// and errs.ErrorInvalidField if it is not a semantic version.
+// Any version is accepted if MinAuthorVersion is not set.
+func (o AttestationVerifierPublishOptions) VerifyAuthorVersion(version string) error {
+	if o.MinAuthorVersion == "" {
+		return nil
+	}
+	if err := semver.AtLeast(version, o.MinAuthorVersion); err != nil {
+		return fmt.Errorf("author: %w", err)
+	}
+	return nil
+}
+
+// RootIdentity defines the certificate identity of a trusted root.
+type RootIdentity struct {
+	// Issuer is the OIDC issuer of the certificate.
+	Issuer string
+	// SubjectRegex matches the subject alternative name of the certificate.
+	// It is anchored, so it matches the whole name.
+	SubjectRegex *regexp.Regexp
 }
 
 // AttestationVerifier defines an interface to verify attestations.
@@ -29,31 +77,84 @@ type AttestationVerifier interface {
 // publish attestations.
 type AttestationVerificationOption struct {
 	Verifier AttestationVerifier
+	// Retry, if set, retries the calls to the verifier that fail
+	// with a transient error, see errs.TransientError. Failed
+	// verifications are never retried.
+	Retry *retry.Policy
+	// Context, if set, interrupts the waits between retries
+	// once it is done. It defaults to context.Background().
+	Context context.Context
 }
 
 // Policy defines the deployment policy.
 type Policy struct {
 	policy    *internal.Policy
 	validator options.PolicyValidator
+	now       func() time.Time
+	digests   intoto.DigestSet
+	rootUsage RootUsageMode
+	warnings  []string
+	parseOpts []options.ParseOption
+	// unknownDigestAlgorithms allows accepted digest
+	// algorithms not defined by in-toto.
+	unknownDigestAlgorithms bool
+	// expiredExceptionsErr fails the policy creation
+	// on expired exceptions instead of warning.
+	expiredExceptionsErr bool
+	logger               *slog.Logger
+	revocations          *RevocationList
+	rulesEngine          CustomRulesEngine
+	metrics              metrics.Metrics
+	// rules maps a policy ID to its compiled custom rules.
+	rules map[string]CustomRulesEvaluator
 }
 
 // PolicyOption defines a policy option.
 type PolicyOption func(*Policy) error
 
+// EvaluationOption defines an option of a single evaluation.
+type EvaluationOption func(*evaluation) error
+
+type evaluation struct {
+	trace *trace.Trace
+}
+
+// WithTrace records the project policy, package and roots considered by
+// the evaluation, and why each was rejected, see PolicyEvaluationResult.Trace().
+func WithTrace() EvaluationOption {
+	return func(e *evaluation) error {
+		e.trace = trace.New()
+		return nil
+	}
+}
+
 // This is a helpder class to forward calls between the internal
 // classes and the caller.
 type internal_verifier struct {
-	opts AttestationVerificationOption
+	opts  AttestationVerificationOption
+	calls int
 }
 
 func (i *internal_verifier) VerifyPublishAttestation(digests intoto.DigestSet, packageURI string,
-	environment []string, publishrID string, buildLevel int) (*string, error) {
+	environment []string, publishrID string, identity *options.Identity, minBuildLevel int,
+	minAuthorVersion string) (*string, error) {
 	if i.opts.Verifier == nil {
 		return nil, fmt.Errorf("%w: verifier is nil", errs.ErrorInvalidInput)
 	}
+	i.calls++
 	opts := AttestationVerifierPublishOptions{
-		PublishrID: publishrID,
-		BuildLevel: buildLevel,
+		PublishrID:       publishrID,
+		MinBuildLevel:    minBuildLevel,
+		BuildLevel:       minBuildLevel,
+		MinAuthorVersion: minAuthorVersion,
+	}
+	if identity != nil {
+		opts.PublishrID = ""
+		opts.PublishrIDRegex = identity.SubjectRegex.String()
+		opts.Identity = &RootIdentity{
+			Issuer:       identity.Issuer,
+			SubjectRegex: identity.SubjectRegex,
+		}
 	}
 	return i.opts.Verifier.VerifyPublishAttestation(digests, packageURI, environment, opts)
 }
@@ -77,25 +178,67 @@ func (i *internal_validator) ValidatePackage(pkg options.ValidationPackage) erro
 	})
 }
 
+// PolicyNewFromFS creates a deployment policy from the files of fsys, e.g.
+// an embed.FS. orgPath is the path of the org policy, and projectGlob
+// matches the paths of the project policies, see fs.Glob. The ID of a
+// project policy is its path in fsys.
+func PolicyNewFromFS(fsys fs.FS, orgPath, projectGlob string, opts ...PolicyOption) (*Policy, error) {
+	projects, err := files.NewFSPolicyIterator(fsys, projectGlob, orgPath)
+	if err != nil {
+		return nil, err
+	}
+	org, err := files.OpenFS(fsys, orgPath)
+	if err != nil {
+		return nil, err
+	}
+	return PolicyNew(org, projects, opts...)
+}
+
 // New creates a deployment policy.
 func PolicyNew(org io.ReadCloser, projects iterator.NamedReadCloserIterator, opts ...PolicyOption) (*Policy, error) {
 	// Initialize a policy with caller options.
 	p := new(Policy)
+	p.now = time.Now
 	for _, option := range opts {
 		err := option(p)
 		if err != nil {
 			return nil, err
 		}
 	}
-	policy, err := internal.PolicyNew(org, projects, p.validator)
+	digester := policyDigesterNew()
+	policy, err := internal.PolicyNew(digester.wrapOrg(org), digester.wrapProjects(projects), p.validator, p.parseOpts...)
 	if err != nil {
 		return nil, err
 	}
 	p.policy = policy
+	p.digests = digester.digests()
+	if err := p.checkDigestAlgorithms(); err != nil {
+		return nil, err
+	}
+	if err := p.checkRootUsage(); err != nil {
+		return nil, err
+	}
+	if err := p.checkExpiredExceptions(); err != nil {
+		return nil, err
+	}
+	if err := p.compileCustomRules(); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
-// SetValidator sets a custom validator.
+// Digests returns the digest of the policy files the policy was created from.
+func (p *Policy) Digests() intoto.DigestSet {
+	digests := make(intoto.DigestSet, len(p.digests))
+	for k, v := range p.digests {
+		digests[k] = v
+	}
+	return digests
+}
+
+// SetValidator sets a custom validator. The validator must be safe
+// for concurrent use if more than one parse worker is set, see
+// SetParseWorkers().
 func SetValidator(validator PolicyValidator) PolicyOption {
 	return func(p *Policy) error {
 		return p.setValidator(validator)
@@ -111,23 +254,270 @@ func (p *Policy) setValidator(validator PolicyValidator) error {
 	return nil
 }
 
+// AllowUnknownFields accepts policy files with fields that are not defined
+// by the policy format, e.g. annotations. By default, such files are rejected
+// so that a misspelled field does not silently weaken the policy.
+func AllowUnknownFields() PolicyOption {
+	return func(p *Policy) error {
+		return p.allowUnknownFields()
+	}
+}
+
+func (p *Policy) allowUnknownFields() error {
+	p.parseOpts = append(p.parseOpts, options.AllowUnknownFields())
+	return nil
+}
+
+// SetParseWorkers sets the maximum number of project policy files
+// parsed concurrently. It defaults to 1. The validator set
+// by SetValidator() may be called concurrently unless workers is 1.
+func SetParseWorkers(workers int) PolicyOption {
+	return func(p *Policy) error {
+		return p.setParseWorkers(workers)
+	}
+}
+
+func (p *Policy) setParseWorkers(workers int) error {
+	if workers < 1 {
+		return fmt.Errorf("%w: parse workers (%d) must be positive", errs.ErrorInvalidInput, workers)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithWorkers(workers))
+	return nil
+}
+
+// SetMaxPolicySize sets the maximum size of a policy file, in bytes.
+// Larger files are rejected with errs.ErrorInvalidInput. It defaults
+// to 4 MiB.
+func SetMaxPolicySize(size int64) PolicyOption {
+	return func(p *Policy) error {
+		return p.setMaxPolicySize(size)
+	}
+}
+
+func (p *Policy) setMaxPolicySize(size int64) error {
+	if size < 1 {
+		return fmt.Errorf("%w: max policy size (%d) must be positive", errs.ErrorInvalidInput, size)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithMaxSize(size))
+	return nil
+}
+
+// SetMaxPolicies sets the maximum number of project policy files,
+// including the files they extend. Once more files are read, the
+// project policies are rejected with errs.ErrorInvalidInput, without
+// reading the remaining files. It defaults to 10000.
+func SetMaxPolicies(count int) PolicyOption {
+	return func(p *Policy) error {
+		return p.setMaxPolicies(count)
+	}
+}
+
+func (p *Policy) setMaxPolicies(count int) error {
+	if count < 1 {
+		return fmt.Errorf("%w: max policies (%d) must be positive", errs.ErrorInvalidInput, count)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithMaxPolicies(count))
+	return nil
+}
+
+// SetMaxPackagesPerPolicy sets the maximum number of packages
+// a project policy file defines. Files with more packages are
+// rejected with errs.ErrorInvalidInput. It defaults to 1000.
+func SetMaxPackagesPerPolicy(count int) PolicyOption {
+	return func(p *Policy) error {
+		return p.setMaxPackagesPerPolicy(count)
+	}
+}
+
+func (p *Policy) setMaxPackagesPerPolicy(count int) error {
+	if count < 1 {
+		return fmt.Errorf("%w: max packages per policy (%d) must be positive", errs.ErrorInvalidInput, count)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithMaxPackages(count))
+	return nil
+}
+
+// SetClock sets the clock used to measure the evaluation.
+func SetClock(now func() time.Time) PolicyOption {
+	return func(p *Policy) error {
+		return p.setClock(now)
+	}
+}
+
+func (p *Policy) setClock(now func() time.Time) error {
+	if now == nil {
+		return fmt.Errorf("%w: clock is nil", errs.ErrorInvalidInput)
+	}
+	p.now = now
+	return nil
+}
+
+// SetLogger sets the logger that receives the records of the policy
+// parsing and evaluation, e.g. the project policy selected, the publish
+// roots considered and the verifier results. Nothing is logged by default.
+func SetLogger(logger *slog.Logger) PolicyOption {
+	return func(p *Policy) error {
+		return p.setLogger(logger)
+	}
+}
+
+func (p *Policy) setLogger(logger *slog.Logger) error {
+	if logger == nil {
+		return fmt.Errorf("%w: logger is nil", errs.ErrorInvalidInput)
+	}
+	p.logger = logger
+	p.parseOpts = append(p.parseOpts, options.WithLogger(logger))
+	return nil
+}
+
+// EffectiveHash returns a hash of the parsed policy. It does not
+// depend on formatting, key order or the order of the project files,
+// and changes whenever a field used during evaluation changes.
+func (p *Policy) EffectiveHash() (string, error) {
+	return p.policy.EffectiveHash()
+}
+
 // Evaluate evalues the deployment policy.
-func (p *Policy) Evaluate(digests intoto.DigestSet, policyPackageName string, policyID string, opts AttestationVerificationOption) PolicyEvaluationResult {
-	protection, err := p.policy.Evaluate(digests, policyPackageName, policyID,
+func (p *Policy) Evaluate(digests intoto.DigestSet, policyPackageName string, policyID string, opts AttestationVerificationOption,
+	evalOpts ...EvaluationOption) PolicyEvaluationResult {
+	var eval evaluation
+	for _, option := range evalOpts {
+		if err := option(&eval); err != nil {
+			return PolicyEvaluationResult{
+				err:         err,
+				digests:     digests,
+				packageName: policyPackageName,
+			}
+		}
+	}
+	if p.metrics == nil {
+		return p.evaluate(digests, policyPackageName, policyID, opts, eval)
+	}
+	start := time.Now()
+	result := p.evaluate(digests, policyPackageName, policyID, opts, eval)
+	decision, code := metrics.Decision(result.err)
+	p.metrics.ObserveEvaluation(metrics.ComponentDeployment, decision, code, time.Since(start))
+	return result
+}
+
+func (p *Policy) evaluate(digests intoto.DigestSet, policyPackageName string, policyID string, opts AttestationVerificationOption,
+	eval evaluation) PolicyEvaluationResult {
+	start := p.now()
+	logger := logging.OrDiscard(p.logger)
+	verifier := &internal_verifier{
+		opts: opts,
+	}
+	normalized, err := digests.Normalize()
+	if err == nil {
+		err = opts.Retry.Validate()
+	}
+	if err != nil {
+		logger.Info("policy decision", "package", policyPackageName, "policy_id", policyID,
+			"allow", false, "error", err)
+		eval.trace.Record(trace.Step{PolicyID: policyID, Package: policyPackageName,
+			Outcome: trace.OutcomeRejected, Reason: trace.ReasonInvalidInput}, err)
+		return PolicyEvaluationResult{
+			err:         err,
+			digests:     digests,
+			packageName: policyPackageName,
+			trace:       eval.trace,
+		}
+	}
+	digests = normalized
+	if err := p.verifyRevocations(digests, start); err != nil {
+		logger.Info("policy decision", "package", policyPackageName, "policy_id", policyID,
+			"allow", false, "error", err)
+		eval.trace.Record(trace.Step{PolicyID: policyID, Package: policyPackageName,
+			Outcome: trace.OutcomeRejected, Reason: trace.ReasonRevoked}, err)
+		return PolicyEvaluationResult{
+			err:         err,
+			digests:     digests,
+			packageName: policyPackageName,
+			trace:       eval.trace,
+		}
+	}
+	result, err := p.policy.Evaluate(digests, policyPackageName, policyID,
 		options.PublishVerification{
-			Verifier: &internal_verifier{
-				opts: opts,
-			},
+			Verifier: verifier,
+			Logger:   p.logger,
+			Trace:    eval.trace,
+			Retry:    opts.Retry,
+			Context:  opts.Context,
 		},
+		start,
 	)
-	return PolicyEvaluationResult{
-		err:        err,
-		digests:    digests,
-		protection: protection,
+	// NOTE: no publish attestation is verified for exceptions.
+	if err == nil && result.Exception == nil {
+		err = p.evaluateCustomRules(policyID, CustomRulesInput{
+			Request: CustomRulesRequest{
+				Digests:     digests,
+				Package:     policyPackageName,
+				PolicyID:    policyID,
+				Environment: result.Environment,
+				Scopes:      result.Principal.AllScopes(),
+			},
+			Attestation: CustomRulesAttestation{
+				PublishRootID: result.PublishRootID,
+				BuildLevel:    result.BuildLevel,
+			},
+		})
+		if err != nil {
+			eval.trace.Record(trace.Step{PolicyID: policyID, Package: policyPackageName, RootID: result.PublishRootID,
+				Outcome: trace.OutcomeRejected, Reason: trace.ReasonCustomRules}, err)
+		}
 	}
+	if err != nil {
+		logger.Info("policy decision", "package", policyPackageName, "policy_id", policyID,
+			"allow", false, "error", err)
+		return PolicyEvaluationResult{
+			err:         err,
+			digests:     digests,
+			packageName: policyPackageName,
+			trace:       eval.trace,
+		}
+	}
+	res := PolicyEvaluationResult{
+		digests:       result.Digests,
+		packageName:   policyPackageName,
+		principal:     &result.Principal,
+		publishRootID: result.PublishRootID,
+		policyDigests: p.Digests(),
+		evaluatedAt:   start,
+		telemetry: &telemetry{
+			duration:      p.now().Sub(start),
+			verifierCalls: verifier.calls,
+		},
+		digestAlgorithms:    result.DigestAlgorithms,
+		allowedEnvironments: result.AllowedEnvironments,
+		trace:               eval.trace,
+	}
+	logger.Info("policy decision", "package", policyPackageName, "policy_id", policyID,
+		"allow", true, "principal", result.Principal.URI, "publish_root", result.PublishRootID)
+	// NOTE: no publish attestation is verified for exceptions.
+	if result.Exception != nil {
+		res.exception = exceptionNew(policyID, result.Exception)
+	} else {
+		res.buildLevel = &result.BuildLevel
+	}
+	return res
 }
 
 // Utility function for cosign integration.
 func PredicateType() string {
 	return predicateType
 }
+
+// PredicateTypes returns the predicate types accepted
+// by verification, the current one first.
+// Utility function for cosign integration.
+func PredicateTypes() []string {
+	return []string{predicateType, predicateTypeV01}
+}
+
+// ScopeKubernetesServiceAccount returns the scope key
+// of the Kubernetes service account.
+//
+// Deprecated: use ScopeKeyKubernetesServiceAccount.
+func ScopeKubernetesServiceAccount() string {
+	return ScopeKeyKubernetesServiceAccount
+}
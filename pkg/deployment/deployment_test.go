@@ -2,11 +2,21 @@ package deployment
 
 import (
 	"bytes"
+	"context"
+	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"os"
 	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -15,13 +25,16 @@ import (
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/retry"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 func Test_AttestationNew(t *testing.T) {
 	t.Parallel()
 	digests := intoto.DigestSet{
-		"sha256":    "some_value",
-		"gitCommit": "another_value",
+		"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
 	}
 	subject := intoto.Subject{
 		Digests: digests,
@@ -30,8 +43,9 @@ func Test_AttestationNew(t *testing.T) {
 		URI: "principal_uri",
 	}
 	result := PolicyEvaluationResult{
-		digests:   digests,
-		principal: &principal,
+		digests:       digests,
+		principal:     &principal,
+		publishRootID: "publish_root_id",
 	}
 	opts := []AttestationCreationOption{}
 	tests := []struct {
@@ -82,11 +96,17 @@ func Test_AttestationNew(t *testing.T) {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 			c := map[string]string{
-				scopeKubernetesServiceAccount: tt.result.principal.URI,
+				ScopeKeyKubernetesServiceAccount: tt.result.principal.URI,
 			}
 			if diff := cmp.Diff(c, att.attestation.Predicate.Scopes); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			p := properties{
+				publishRootProperty: tt.result.publishRootID,
+			}
+			if diff := cmp.Diff(p, att.attestation.Predicate.Properties); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
@@ -105,7 +125,8 @@ type attestationVerifier struct {
 }
 
 func (v *attestationVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string, env []string, opts AttestationVerifierPublishOptions) (*string, error) {
-	if opts.BuildLevel == v.buildLevel && packageName == v.packageName && opts.PublishrID == v.publishrID &&
+	// NOTE: the required level is a minimum.
+	if opts.MinBuildLevel <= v.buildLevel && packageName == v.packageName && opts.PublishrID == v.publishrID &&
 		common.MapEq(digests, v.digests) &&
 		((v.env != "" && len(env) > 0 && slices.Contains(env, v.env)) ||
 			(v.env == "" && len(env) == 0)) {
@@ -114,7 +135,7 @@ func (v *attestationVerifier) VerifyPublishAttestation(digests intoto.DigestSet,
 		}
 		return &v.env, nil
 	}
-	return nil, fmt.Errorf("%w: cannot verify package Name (%q) publishr ID (%q) env (%q) buildLevel (%d)", errs.ErrorVerification, packageName, opts.PublishrID, env, opts.BuildLevel)
+	return nil, fmt.Errorf("%w: cannot verify package Name (%q) publishr ID (%q) env (%q) buildLevel (%d)", errs.ErrorVerification, packageName, opts.PublishrID, env, opts.MinBuildLevel)
 }
 
 func newPolicyValidator(pass bool) PolicyValidator {
@@ -135,7 +156,7 @@ func (v *policyValidator) ValidatePackage(pkg ValidationPackage) error {
 func Test_e2e(t *testing.T) {
 	t.Parallel()
 	digests := intoto.DigestSet{
-		"sha256": "val256",
+		"sha256": "728349abb3d6fe69386e0755b956978e62e81c56759c4e21801554a5fdc7e7f5",
 		"sha512": "val512",
 	}
 	publishrID1 := "publishr_id1"
@@ -469,7 +490,7 @@ func Test_e2e(t *testing.T) {
 			// No validator.
 			orgReader = io.NopCloser(bytes.NewReader(orgContent))
 			projectsReader = common.NewNamedBytesIterator(policies, true)
-			pol, err := PolicyNew(orgReader, projectsReader)
+			pol, err := PolicyNew(orgReader, projectsReader, SetClock(newFakeClock(10*time.Millisecond)))
 			if err != nil {
 				t.Fatalf("failed to create policy: %v", err)
 			}
@@ -481,6 +502,15 @@ func Test_e2e(t *testing.T) {
 			if diff := cmp.Diff(tt.errorEvaluate, result.Error(), cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if result.Error() == nil {
+				// The fake clock advances by 10ms on each call.
+				if diff := cmp.Diff(10*time.Millisecond, result.telemetry.duration); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if result.telemetry.verifierCalls < 1 {
+					t.Fatalf("unexpected verifier calls: %d\n", result.telemetry.verifierCalls)
+				}
+			}
 			if err != nil {
 				return
 			}
@@ -502,10 +532,13 @@ func Test_e2e(t *testing.T) {
 			}
 
 			// Create verification options.
-			options := []VerificationOption{}
+			options := []VerificationOption{
+				IsSlsaBuildLevelOrAbove(tt.buildLevel),
+				HasPublishRoot(tt.publishrID),
+			}
 			// Verify.
 			scopes := map[string]string{
-				scopeKubernetesServiceAccount: tt.principalURI,
+				ScopeKeyKubernetesServiceAccount: tt.principalURI,
 			}
 			err = verification.Verify(tt.digests, scopes, options...)
 			if diff := cmp.Diff(tt.errorVerify, err, cmpopts.EquateErrors()); diff != "" {
@@ -514,3 +547,2538 @@ func Test_e2e(t *testing.T) {
 		})
 	}
 }
+
+func Test_Telemetry(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	principal := project.Principal{
+		URI: "principal_uri",
+	}
+	tests := []struct {
+		name             string
+		telemetry        *telemetry
+		options          []AttestationCreationOption
+		expected         error
+		expectedErr      error
+		expectedDuration time.Duration
+		expectedCalls    int
+	}{
+		{
+			name: "telemetry enabled",
+			telemetry: &telemetry{
+				duration:      1500 * time.Millisecond,
+				verifierCalls: 3,
+			},
+			options:          []AttestationCreationOption{WithTelemetryProperties()},
+			expectedDuration: 1500 * time.Millisecond,
+			expectedCalls:    3,
+		},
+		{
+			name: "telemetry not enabled",
+			telemetry: &telemetry{
+				duration:      1500 * time.Millisecond,
+				verifierCalls: 3,
+			},
+			expectedErr: errs.ErrorNotFound,
+		},
+		{
+			name:     "telemetry enabled without data",
+			options:  []AttestationCreationOption{WithTelemetryProperties()},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := PolicyEvaluationResult{
+				digests:   digests,
+				principal: &principal,
+				telemetry: tt.telemetry,
+			}
+			att, err := result.AttestationNew(tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v\n", err)
+			}
+			duration, err := verification.EvaluationDuration()
+			if diff := cmp.Diff(tt.expectedErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedDuration, duration); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			calls, err := verification.VerifierCalls()
+			if diff := cmp.Diff(tt.expectedErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedCalls, calls); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func newFakeClock(step time.Duration) func() time.Time {
+	now := time.Unix(0, 0)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func Test_ResultJSON(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
+	}
+	principal := project.Principal{
+		URI: "principal_uri",
+	}
+	tests := []struct {
+		name          string
+		result        PolicyEvaluationResult
+		content       []byte
+		expected      error
+		expectedParse error
+		expectedErr   error
+	}{
+		{
+			name: "allow result",
+			result: PolicyEvaluationResult{
+				digests:       digests,
+				packageName:   "package_name",
+				principal:     &principal,
+				publishRootID: "publish_root_id",
+			},
+		},
+		{
+			name: "allow result with scopes",
+			result: PolicyEvaluationResult{
+				digests:     digests,
+				packageName: "package_name",
+				principal: &project.Principal{
+					URI: "principal_uri",
+					Scopes: map[string]string{
+						"region": "us-east1",
+					},
+				},
+			},
+		},
+		{
+			name: "deny result",
+			result: PolicyEvaluationResult{
+				digests:     digests,
+				packageName: "package_name",
+				err:         fmt.Errorf("%w: some reason", errs.ErrorVerification),
+			},
+			expectedErr: errs.ErrorVerification,
+		},
+		{
+			name: "deny result unknown error",
+			result: PolicyEvaluationResult{
+				digests:     digests,
+				packageName: "package_name",
+				err:         fmt.Errorf("some reason"),
+			},
+		},
+		{
+			name:    "deny result with category",
+			content: []byte(`{"allow":false,"error":{"category":"mismatch","reason":"reason"}}`),
+			result: PolicyEvaluationResult{
+				err: fmt.Errorf("reason"),
+			},
+			expectedErr: errs.ErrorMismatch,
+		},
+		{
+			name: "allow result no principal",
+			result: PolicyEvaluationResult{
+				digests: digests,
+			},
+			expected: errs.ErrorInternal,
+		},
+		{
+			name:          "invalid json",
+			content:       []byte(`{"allow":`),
+			expectedParse: errs.ErrorInvalidInput,
+		},
+		{
+			name:          "deny without error",
+			content:       []byte(`{"allow":false}`),
+			expectedParse: errs.ErrorInvalidField,
+		},
+		{
+			name:          "allow with error",
+			content:       []byte(`{"allow":true,"principal_uri":"principal_uri","digests":{"sha256":"bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"},"error":{"category":"internal","reason":"reason"}}`),
+			expectedParse: errs.ErrorInvalidField,
+		},
+		{
+			name:          "allow without principal",
+			content:       []byte(`{"allow":true,"digests":{"sha256":"bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"}}`),
+			expectedParse: errs.ErrorInvalidField,
+		},
+		{
+			name:          "allow with kubernetes scope",
+			content:       []byte(`{"allow":true,"principal_uri":"principal_uri","scopes":{"kubernetes.io/pod/service_account/v1":"other_uri"},"digests":{"sha256":"bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"}}`),
+			expectedParse: errs.ErrorInvalidField,
+		},
+		{
+			name:          "allow with empty scope",
+			content:       []byte(`{"allow":true,"principal_uri":"principal_uri","scopes":{"region":""},"digests":{"sha256":"bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"}}`),
+			expectedParse: errs.ErrorInvalidField,
+		},
+		{
+			name:          "allow without digests",
+			content:       []byte(`{"allow":true,"principal_uri":"principal_uri"}`),
+			expectedParse: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content := tt.content
+			if content == nil {
+				var err error
+				content, err = tt.result.ToJSON()
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if err != nil {
+					return
+				}
+			}
+			result, err := ResultFromJSON(content)
+			if diff := cmp.Diff(tt.expectedParse, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.result.digests, result.digests); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.result.packageName, result.packageName); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.result.principal, result.principal); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.result.publishRootID, result.PublishRoot()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.result.err == nil {
+				if result.Error() != nil {
+					t.Fatalf("unexpected error: %v", result.Error())
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.result.err.Error(), result.Error().Error()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.expectedErr != nil {
+				if diff := cmp.Diff(tt.expectedErr, result.Error(), cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+// Test_DigestSetContract checks that nil and empty digest sets
+// are rejected with ErrorInvalidField by every entry point,
+// before the attestation verifier is called.
+func Test_DigestSetContract(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	principalURI := "principal_uri"
+	policyID := "policy_id0"
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(3),
+			},
+			Principal: project.Principal{
+				URI: principalURI,
+			},
+			Packages: []project.Package{
+				{
+					Name: packageName,
+				},
+			},
+		},
+	}
+	pol := newTestPolicy(t, org, projects, time.Now)
+	scopes := map[string]string{
+		ScopeKeyKubernetesServiceAccount: principalURI,
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes)
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	attBytes, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	entryPoints := []struct {
+		name string
+		call func(digests intoto.DigestSet) error
+	}{
+		{
+			name: "Evaluate",
+			call: func(ds intoto.DigestSet) error {
+				// NOTE: the verifier fails with ErrorVerification on any other digests.
+				verifier := NewE2eAttestationVerifier(digests, packageName, "", publishrID, 3)
+				result := pol.Evaluate(ds, packageName, policyID, AttestationVerificationOption{
+					Verifier: verifier,
+				})
+				return result.Error()
+			},
+		},
+		{
+			name: "CreationNew",
+			call: func(ds intoto.DigestSet) error {
+				_, err := CreationNew(intoto.Subject{Digests: ds}, scopes)
+				return err
+			},
+		},
+		{
+			name: "Verify",
+			call: func(ds intoto.DigestSet) error {
+				verification, err := VerificationNew(io.NopCloser(bytes.NewReader(attBytes)))
+				if err != nil {
+					return err
+				}
+				return verification.Verify(ds, scopes)
+			},
+		},
+		{
+			name: "RejectForeignSubjects",
+			call: func(ds intoto.DigestSet) error {
+				verification, err := VerificationNew(io.NopCloser(bytes.NewReader(attBytes)))
+				if err != nil {
+					return err
+				}
+				return verification.Verify(digests, scopes, RejectForeignSubjects(ds))
+			},
+		},
+		{
+			name: "ResultFromJSON",
+			call: func(ds intoto.DigestSet) error {
+				content, err := json.Marshal(resultJSON{
+					Allow:        true,
+					PrincipalURI: principalURI,
+					Digests:      ds,
+				})
+				if err != nil {
+					return err
+				}
+				_, err = ResultFromJSON(content)
+				return err
+			},
+		},
+	}
+	inputs := []struct {
+		name     string
+		digests  intoto.DigestSet
+		expected error
+	}{
+		{
+			name:     "nil digests",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty digests",
+			digests:  intoto.DigestSet{},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:    "single digest",
+			digests: digests,
+		},
+		{
+			name: "mixed case digest",
+			digests: intoto.DigestSet{
+				"SHA256": strings.ToUpper(digests["sha256"]),
+			},
+		},
+		{
+			name: "conflicting duplicate digest",
+			digests: intoto.DigestSet{
+				"sha256": digests["sha256"],
+				"SHA256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, ep := range entryPoints {
+		for _, in := range inputs {
+			ep, in := ep, in // Re-initializing variables so they are not changed while executing the closure below
+			t.Run(ep.name+" "+in.name, func(t *testing.T) {
+				t.Parallel()
+				err := ep.call(in.digests)
+				if diff := cmp.Diff(in.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func Test_RootUsageCheck(t *testing.T) {
+	t.Parallel()
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "publishr_id1",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					ID: "publishr_id2",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(2),
+					},
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(3),
+			},
+			Principal: project.Principal{
+				URI: "principal_uri",
+			},
+			Packages: []project.Package{
+				{
+					Name: "package_name",
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		warnings []string
+		expected error
+	}{
+		{
+			name:     "no check",
+			warnings: []string{},
+		},
+		{
+			name:     "ignore mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageIgnore)},
+			warnings: []string{},
+		},
+		{
+			name:     "warn mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageWarn)},
+			warnings: []string{`publish root ("publishr_id2") is not referenced by any project`},
+		},
+		{
+			name:     "error mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageError)},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageMode(-1))},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			orgContent, err := json.Marshal(org)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			policies := make([][]byte, len(projects))
+			for i := range projects {
+				content, err := json.Marshal(projects[i])
+				if err != nil {
+					t.Fatalf("failed to marshal: %v", err)
+				}
+				policies[i] = content
+			}
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+				common.NewNamedBytesIterator(policies, true), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.warnings, pol.Warnings()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_AcceptedDigestAlgorithms(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	policyID := "policy_id0"
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name         string
+		accepted     []string
+		allowUnknown bool
+		digests      intoto.DigestSet
+		// subject contains the verified and attested digests, if
+		// different from digests.
+		subject    intoto.DigestSet
+		policyErr  error
+		expected   error
+		algorithms []string
+	}{
+		{
+			name: "any algorithm",
+			digests: intoto.DigestSet{
+				"gitCommit": "8d00dff510292c3db45ce0c21982253ce7e94931",
+			},
+		},
+		{
+			name:     "sha256 only",
+			accepted: []string{"sha256"},
+			digests: intoto.DigestSet{
+				"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			},
+			algorithms: []string{"sha256"},
+		},
+		{
+			name:     "git commit only",
+			accepted: []string{"sha256"},
+			digests: intoto.DigestSet{
+				"gitCommit": "8d00dff510292c3db45ce0c21982253ce7e94931",
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "mixed",
+			accepted: []string{"sha256", "sha512"},
+			digests: intoto.DigestSet{
+				"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+				"gitCommit": "8d00dff510292c3db45ce0c21982253ce7e94931",
+			},
+			subject: intoto.DigestSet{
+				"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			},
+			algorithms: []string{"sha256"},
+		},
+		{
+			name:     "unknown algorithm",
+			accepted: []string{"sha256", "custom_hash"},
+			digests: intoto.DigestSet{
+				"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			},
+			policyErr: errs.ErrorInvalidField,
+		},
+		{
+			name:         "allowed unknown algorithm",
+			accepted:     []string{"sha256", "custom_hash"},
+			allowUnknown: true,
+			digests: intoto.DigestSet{
+				"custom_hash": "some_value",
+			},
+			algorithms: []string{"custom_hash"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			projects := []project.Policy{
+				{
+					Format: 1,
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+					Principal: project.Principal{
+						URI: "principal_uri",
+					},
+					Packages: []project.Package{
+						{
+							Name:                     packageName,
+							AcceptedDigestAlgorithms: tt.accepted,
+						},
+					},
+				},
+			}
+			orgContent, err := json.Marshal(org)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			projectContent, err := json.Marshal(projects[0])
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			var opts []PolicyOption
+			if tt.allowUnknown {
+				opts = append(opts, AllowUnknownDigestAlgorithms())
+			}
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+				common.NewNamedBytesIterator([][]byte{projectContent}, true), opts...)
+			if diff := cmp.Diff(tt.policyErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			subject := tt.digests
+			if tt.subject != nil {
+				subject = tt.subject
+			}
+			// NOTE: the verifier fails unless it receives exactly subject.
+			result := pol.Evaluate(tt.digests, packageName, policyID, AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(subject, packageName, "", publishrID, 3),
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if result.Error() != nil {
+				return
+			}
+			att, err := result.AttestationNew()
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			var algorithms []string
+			if value, exists := att.attestation.Predicate.Properties[digestAlgorithmsProperty]; exists {
+				algorithms = value.([]string)
+			}
+			if diff := cmp.Diff(tt.algorithms, algorithms); diff != "" {
+				t.Fatalf("unexpected algorithms (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff([]intoto.Subject{{Digests: subject}}, att.attestation.Header.Subjects); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Exceptions(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	policyID := "policy_id0"
+	digests := intoto.DigestSet{
+		"sha256": strings.Repeat("a", 64),
+	}
+	otherDigests := intoto.DigestSet{
+		"sha256": strings.Repeat("b", 64),
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	exception := func(decision, expires string) project.Exception {
+		return project.Exception{
+			Digest:   "sha256:" + digests["sha256"],
+			Decision: decision,
+			Reason:   "incident",
+			Expires:  expires,
+		}
+	}
+	tests := []struct {
+		name            string
+		exception       project.Exception
+		options         []PolicyOption
+		verifierDigests intoto.DigestSet
+		policyErr       error
+		expected        error
+		warnings        []string
+		allowed         *PolicyException
+	}{
+		{
+			name:            "deny",
+			exception:       exception("deny", "2024-01-02T00:00:00Z"),
+			verifierDigests: digests,
+			warnings:        []string{},
+			expected:        errs.ErrorVerification,
+		},
+		{
+			name:            "allow",
+			exception:       exception("allow", "2024-01-02T00:00:00Z"),
+			verifierDigests: otherDigests,
+			warnings:        []string{},
+			allowed: &PolicyException{
+				PolicyID: policyID,
+				Digest:   "sha256:" + digests["sha256"],
+				Decision: "allow",
+				Reason:   "incident",
+				Expires:  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:            "expired deny",
+			exception:       exception("deny", "2023-12-31T00:00:00Z"),
+			verifierDigests: digests,
+			warnings: []string{
+				`policy_id0: exception for digest ("sha256:` + digests["sha256"] + `") expired at 2023-12-31T00:00:00Z`,
+			},
+		},
+		{
+			name:            "expired allow",
+			exception:       exception("allow", "2023-12-31T00:00:00Z"),
+			verifierDigests: otherDigests,
+			warnings: []string{
+				`policy_id0: exception for digest ("sha256:` + digests["sha256"] + `") expired at 2023-12-31T00:00:00Z`,
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:      "rejected expired exception",
+			exception: exception("deny", "2023-12-31T00:00:00Z"),
+			options:   []PolicyOption{RejectExpiredExceptions()},
+			policyErr: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			projects := []project.Policy{
+				{
+					Format: 1,
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+					Principal: project.Principal{
+						URI: "principal_uri",
+					},
+					Packages: []project.Package{
+						{
+							Name: packageName,
+						},
+					},
+					Exceptions: []project.Exception{tt.exception},
+				},
+			}
+			orgContent, err := json.Marshal(org)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			projectContent, err := json.Marshal(projects[0])
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			opts := append([]PolicyOption{SetClock(func() time.Time { return now })}, tt.options...)
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+				common.NewNamedBytesIterator([][]byte{projectContent}, true), opts...)
+			if diff := cmp.Diff(tt.policyErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.warnings, pol.Warnings()); diff != "" {
+				t.Fatalf("unexpected warnings (-want +got): \n%s", diff)
+			}
+			result := pol.Evaluate(digests, packageName, policyID, AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(tt.verifierDigests, packageName, "", publishrID, 3),
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if result.Error() != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.allowed, result.Exception()); diff != "" {
+				t.Fatalf("unexpected exception (-want +got): \n%s", diff)
+			}
+			att, err := result.AttestationNew()
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			_, recorded := att.attestation.Predicate.Properties[exceptionProperty]
+			if recorded != (tt.allowed != nil) {
+				t.Fatalf("unexpected exception property: %v", att.attestation.Predicate.Properties)
+			}
+			_, hasLevel := att.attestation.Predicate.Properties[buildLevelProperty]
+			if hasLevel != (tt.allowed == nil) {
+				t.Fatalf("unexpected build level property: %v", att.attestation.Predicate.Properties)
+			}
+		})
+	}
+}
+
+func Test_EvaluateAll(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	newProject := func(principalURI string, level int, name string) project.Policy {
+		return project.Policy{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(level),
+			},
+			Principal: project.Principal{
+				URI: principalURI,
+			},
+			Packages: []project.Package{
+				{
+					Name: name,
+				},
+			},
+		}
+	}
+	// NOTE: policy IDs are policy_id0, policy_id1, etc.
+	projects := []project.Policy{
+		newProject("principal_uri0", 2, packageName),
+		newProject("principal_uri1", 3, packageName),
+		newProject("principal_uri2", 2, "other_package_uri"),
+		newProject("principal_uri3", 1, packageName),
+		newProject("principal_uri4", 2, "package_*"),
+	}
+	tests := []struct {
+		name        string
+		packageName string
+		digests     intoto.DigestSet
+		results     []PrincipalEvaluationResult
+		allowed     []bool
+		expected    error
+	}{
+		{
+			name:        "all principals",
+			packageName: packageName,
+			digests:     digests,
+			results: []PrincipalEvaluationResult{
+				{PolicyID: "policy_id0", PrincipalURI: "principal_uri0"},
+				{PolicyID: "policy_id1", PrincipalURI: "principal_uri1"},
+				{PolicyID: "policy_id3", PrincipalURI: "principal_uri3"},
+				{PolicyID: "policy_id4", PrincipalURI: "principal_uri4"},
+			},
+			allowed: []bool{true, false, true, true},
+		},
+		{
+			name:        "glob package",
+			packageName: "package_other",
+			digests:     digests,
+			results: []PrincipalEvaluationResult{
+				{PolicyID: "policy_id4", PrincipalURI: "principal_uri4"},
+			},
+			// NOTE: the verifier only verifies packageName.
+			allowed: []bool{false},
+		},
+		{
+			name:        "unknown package",
+			packageName: "unknown_package_uri",
+			digests:     digests,
+			results:     []PrincipalEvaluationResult{},
+			allowed:     []bool{},
+		},
+		{
+			name:     "empty package name",
+			digests:  digests,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:        "empty digests",
+			packageName: packageName,
+			expected:    errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol := newTestPolicy(t, org, projects, time.Now)
+			results, err := pol.EvaluateAll(tt.digests, tt.packageName, AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			})
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			allowed := []bool{}
+			for i := range results {
+				allowed = append(allowed, results[i].Allow())
+			}
+			if diff := cmp.Diff(tt.allowed, allowed); diff != "" {
+				t.Fatalf("unexpected decisions (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.results, results, cmpopts.IgnoreFields(PrincipalEvaluationResult{}, "PolicyEvaluationResult")); diff != "" {
+				t.Fatalf("unexpected results (-want +got): \n%s", diff)
+			}
+			// The single policy evaluation is unchanged.
+			for i := range results {
+				result := pol.Evaluate(tt.digests, tt.packageName, results[i].PolicyID, AttestationVerificationOption{
+					Verifier: NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+				})
+				if diff := cmp.Diff(fmt.Sprint(result.Error()), fmt.Sprint(results[i].Error())); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_YAMLPolicy(t *testing.T) {
+	t.Parallel()
+	policyID := "policy_id0"
+	orgJSON := `{"format": 1, "roots": {"publish": [
+		{"id": "publishr_id1", "build": {"max_slsa_level": 2}},
+		{"id": "publishr_id2", "build": {"max_slsa_level": 3}}]}}`
+	orgYAML := `# Organization policy.
+format: 1
+roots:
+  publish:
+    - id: publishr_id1
+      build:
+        max_slsa_level: 2
+    - id: publishr_id2 # Level 3 publisher.
+      build:
+        max_slsa_level: 3
+`
+	projectJSON := `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+		"packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}}]}`
+	projectYAML := `format: 1
+principal:
+  uri: principal_uri
+build:
+  require_slsa_level: 3
+packages:
+- name: package_uri
+  environment:
+    any_of:
+      - dev
+      - prod
+`
+	digests := intoto.DigestSet{
+		"sha256": "728349abb3d6fe69386e0755b956978e62e81c56759c4e21801554a5fdc7e7f5",
+	}
+	tests := []struct {
+		name        string
+		publishrID  string
+		environment string
+		expected    error
+	}{
+		{
+			name:        "passing",
+			publishrID:  "publishr_id2",
+			environment: "prod",
+		},
+		{
+			name:        "mismatch environment",
+			publishrID:  "publishr_id2",
+			environment: "staging",
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "mismatch level",
+			publishrID:  "publishr_id1",
+			environment: "prod",
+			expected:    errs.ErrorVerification,
+		},
+	}
+	newPolicy := func(org, project string) *Policy {
+		pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+			common.NewNamedBytesIterator([][]byte{[]byte(project)}, true))
+		if err != nil {
+			t.Fatalf("failed to create policy: %v", err)
+		}
+		return pol
+	}
+	jsonPolicy := newPolicy(orgJSON, projectJSON)
+	yamlPolicy := newPolicy(orgYAML, projectYAML)
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(digests, "package_uri", tt.environment, tt.publishrID, 3),
+			}
+			want := jsonPolicy.Evaluate(digests, "package_uri", policyID, opts)
+			got := yamlPolicy.Evaluate(digests, "package_uri", policyID, opts)
+			if diff := cmp.Diff(tt.expected, want.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, got.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+
+	// YAML documents with unknown fields are rejected.
+	_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(orgYAML))),
+		common.NewNamedBytesIterator([][]byte{[]byte(projectYAML + "unknown: value\n")}, true))
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_PackagesAndRoots(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [
+		{"id": "publishr_id2", "build": {"max_slsa_level": 3}},
+		{"id": "publishr_id1", "build": {"max_slsa_level": 2}}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri1"}, "build": {"require_slsa_level": 2},
+			"packages": [{"name": "package_uri2", "publish_roots": ["publishr_id1"]}, {"name": "package_uri1"}]}`),
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri0"}, "build": {"require_slsa_level": 3},
+			"packages": [{"name": "package_uri0", "environment": {"any_of": ["dev", "prod"]}}]}`),
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewNamedBytesIterator(projects, true))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	packages := []PolicyPackage{
+		{
+			PolicyID:      "policy_id0",
+			Name:          "package_uri2",
+			RequiredLevel: 2,
+			PrincipalURI:  "principal_uri1",
+			PublishRoots:  []string{"publishr_id1"},
+		},
+		{
+			PolicyID:      "policy_id0",
+			Name:          "package_uri1",
+			RequiredLevel: 2,
+			PrincipalURI:  "principal_uri1",
+		},
+		{
+			PolicyID:      "policy_id1",
+			Name:          "package_uri0",
+			Environments:  []string{"dev", "prod"},
+			RequiredLevel: 3,
+			PrincipalURI:  "principal_uri0",
+		},
+	}
+	if diff := cmp.Diff(packages, pol.Packages(), cmpopts.EquateEmpty()); diff != "" {
+		t.Fatalf("unexpected packages (-want +got): \n%s", diff)
+	}
+	roots := []PolicyRoot{
+		{ID: "publishr_id2", MaxSlsaLevel: 3},
+		{ID: "publishr_id1", MaxSlsaLevel: 2},
+	}
+	if diff := cmp.Diff(roots, pol.Roots()); diff != "" {
+		t.Fatalf("unexpected roots (-want +got): \n%s", diff)
+	}
+}
+
+func Test_BuildLevelOverrides(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}}`
+	tests := []struct {
+		name     string
+		project  string
+		expected error
+	}{
+		{
+			name: "environment and package levels",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"},
+				"build": {"require_slsa_level": 2, "environments": {"prod": 3}},
+				"packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}},
+				{"name": "package_uri1", "build": {"require_slsa_level": 3}}]}`,
+		},
+		{
+			name: "environment level lower than project level",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"},
+				"build": {"require_slsa_level": 2, "environments": {"dev": 1}},
+				"packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "environment level out of range",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"},
+				"build": {"require_slsa_level": 2, "environments": {"prod": 4}},
+				"packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "package level lower than project level",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"},
+				"build": {"require_slsa_level": 2},
+				"packages": [{"name": "package_uri", "build": {"require_slsa_level": 1}}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+				common.NewNamedBytesIterator([][]byte{[]byte(tt.project)}, true))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PackageRequirements(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri0"}, "build": {"require_slsa_level": 2},
+			"packages": [{"name": "package_uri1", "environment": {"any_of": ["dev"]}}, {"name": "package_uri0"}]}`),
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri1"}, "build": {"require_slsa_level": 3},
+			"packages": [{"name": "package_uri1", "environment": {"any_of": ["prod"]}}]}`),
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewNamedBytesIterator(projects, true))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	if diff := cmp.Diff([]string{"package_uri0", "package_uri1"}, pol.PackageNames()); diff != "" {
+		t.Fatalf("unexpected names (-want +got): \n%s", diff)
+	}
+	tests := []struct {
+		name         string
+		packageName  string
+		requirements []PolicyPackage
+		expected     error
+	}{
+		{
+			name:        "single policy",
+			packageName: "package_uri0",
+			requirements: []PolicyPackage{
+				{PolicyID: "policy_id0", Name: "package_uri0", RequiredLevel: 2, PrincipalURI: "principal_uri0"},
+			},
+		},
+		{
+			name:        "multiple policies",
+			packageName: "package_uri1",
+			requirements: []PolicyPackage{
+				{PolicyID: "policy_id0", Name: "package_uri1", Environments: []string{"dev"}, RequiredLevel: 2, PrincipalURI: "principal_uri0"},
+				{PolicyID: "policy_id1", Name: "package_uri1", Environments: []string{"prod"}, RequiredLevel: 3, PrincipalURI: "principal_uri1"},
+			},
+		},
+		{
+			name:        "unknown package",
+			packageName: "package_uri2",
+			expected:    errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			requirements, err := pol.PackageRequirements(tt.packageName)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.requirements, requirements, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("unexpected requirements (-want +got): \n%s", diff)
+			}
+			// Modifying the requirements must not change the policy.
+			for i := range requirements {
+				requirements[i].Name = "modified"
+				if len(requirements[i].Environments) > 0 {
+					requirements[i].Environments[0] = "modified"
+				}
+			}
+			requirements, _ = pol.PackageRequirements(tt.packageName)
+			if diff := cmp.Diff(tt.requirements, requirements, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("unexpected requirements (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PackageEnvironments(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri0"}, "build": {"require_slsa_level": 2},
+			"packages": [{"name": "package_uri1", "environment": {"any_of": ["dev", "prod/*"]}}, {"name": "package_uri0"},
+			{"name": "docker.io/org/*", "environment": {"any_of": ["staging"]}}]}`),
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewNamedBytesIterator(projects, true))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	tests := []struct {
+		name         string
+		packageName  string
+		policyID     string
+		environments []string
+		expected     error
+	}{
+		{
+			name:         "environments",
+			packageName:  "package_uri1",
+			policyID:     "policy_id0",
+			environments: []string{"dev", "prod/*"},
+		},
+		{
+			name:        "no environment",
+			packageName: "package_uri0",
+			policyID:    "policy_id0",
+		},
+		{
+			name:         "pattern",
+			packageName:  "docker.io/org/server",
+			policyID:     "policy_id0",
+			environments: []string{"staging"},
+		},
+		{
+			name:        "unknown package",
+			packageName: "package_uri2",
+			policyID:    "policy_id0",
+			expected:    errs.ErrorNotFound,
+		},
+		{
+			name:        "unknown policy",
+			packageName: "package_uri1",
+			policyID:    "policy_id1",
+			expected:    errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			environments, err := pol.PackageEnvironments(tt.packageName, tt.policyID)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.environments, environments); diff != "" {
+				t.Fatalf("unexpected environments (-want +got): \n%s", diff)
+			}
+			// Modifying the environments must not change the policy.
+			if len(environments) > 0 {
+				environments[0] = "modified"
+				environments, _ = pol.PackageEnvironments(tt.packageName, tt.policyID)
+				if diff := cmp.Diff(tt.environments, environments); diff != "" {
+					t.Fatalf("unexpected environments (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_DefaultBuildRequirements(t *testing.T) {
+	t.Parallel()
+	roots := `"roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}`
+	tests := []struct {
+		name         string
+		org          string
+		project      string
+		requirements []PolicyPackage
+		expected     error
+	}{
+		{
+			name:    "default level",
+			org:     `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_level": 2}}}`,
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "packages": [{"name": "package_uri"}]}`,
+			requirements: []PolicyPackage{
+				{PolicyID: "policy_id0", Name: "package_uri", RequiredLevel: 2, PrincipalURI: "principal_uri"},
+			},
+		},
+		{
+			name: "project level",
+			org:  `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_level": 2}}}`,
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+				"packages": [{"name": "package_uri"}]}`,
+			requirements: []PolicyPackage{
+				{PolicyID: "policy_id0", Name: "package_uri", RequiredLevel: 3, PrincipalURI: "principal_uri"},
+			},
+		},
+		{
+			name:     "no defaults",
+			org:      `{"format": 1, ` + roots + `}`,
+			project:  `{"format": 1, "principal": {"uri": "principal_uri"}, "packages": [{"name": "package_uri"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "default level above roots",
+			org:      `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_level": 4}}}`,
+			project:  `{"format": 1, "principal": {"uri": "principal_uri"}, "packages": [{"name": "package_uri"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(tt.org))),
+				common.NewNamedBytesIterator([][]byte{[]byte(tt.project)}, true))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			requirements, err := pol.PackageRequirements("package_uri")
+			if err != nil {
+				t.Fatalf("failed to get requirements: %v", err)
+			}
+			if diff := cmp.Diff(tt.requirements, requirements, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("unexpected requirements (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+// authorVerifier accepts attestations created
+// by an author at the version.
+type authorVerifier struct {
+	version string
+}
+
+func (v *authorVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string, env []string,
+	opts AttestationVerifierPublishOptions) (*string, error) {
+	return nil, opts.VerifyAuthorVersion(v.version)
+}
+
+func Test_MinAuthorVersion(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	roots := `"roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}`
+	project := []byte(`{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 2},
+		"packages": [{"name": "package_uri"}]}`)
+	tests := []struct {
+		name      string
+		org       string
+		version   string
+		policyErr error
+		expected  error
+	}{
+		{
+			name:    "version above minimum",
+			org:     `{"format": 1, ` + roots + `, "publish_requirements": {"min_author_version": "1.2.0"}}`,
+			version: "1.3.0",
+		},
+		{
+			name:    "version at minimum",
+			org:     `{"format": 1, ` + roots + `, "publish_requirements": {"min_author_version": "1.2.0"}}`,
+			version: "v1.2.0",
+		},
+		{
+			name:     "version below minimum",
+			org:      `{"format": 1, ` + roots + `, "publish_requirements": {"min_author_version": "1.2.0"}}`,
+			version:  "1.1.9",
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "invalid version",
+			org:      `{"format": 1, ` + roots + `, "publish_requirements": {"min_author_version": "1.2.0"}}`,
+			version:  "latest",
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:    "no requirements",
+			org:     `{"format": 1, ` + roots + `}`,
+			version: "0.0.1",
+		},
+		{
+			name:      "invalid min version",
+			org:       `{"format": 1, ` + roots + `, "publish_requirements": {"min_author_version": "latest"}}`,
+			policyErr: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(tt.org))),
+				common.NewNamedBytesIterator([][]byte{project}, true))
+			if diff := cmp.Diff(tt.policyErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			result := pol.Evaluate(digests, "package_uri", "policy_id0",
+				AttestationVerificationOption{Verifier: &authorVerifier{version: tt.version}})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Trace(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 1}},
+		{"id": "publishr_id2", "build": {"max_slsa_level": 3}}]}}`)
+	project := []byte(`{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 2},
+		"packages": [{"name": "package_uri"}]}`)
+	tests := []struct {
+		name        string
+		packageName string
+		policyID    string
+		verifier    AttestationVerifier
+		options     []EvaluationOption
+		expected    error
+		steps       []trace.Step
+	}{
+		{
+			name:        "selected root",
+			packageName: "package_uri",
+			policyID:    "policy_id0",
+			verifier:    NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id2", 3),
+			options:     []EvaluationOption{WithTrace()},
+			steps: []trace.Step{
+				{
+					PolicyID: "policy_id0", Package: "package_uri", RootID: "publishr_id1",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonLevelTooLow,
+				},
+				{
+					PolicyID: "policy_id0", Package: "package_uri", RootID: "publishr_id2",
+					Outcome: trace.OutcomeSelected, Reason: trace.ReasonVerified,
+				},
+			},
+		},
+		{
+			name:        "verifier failure",
+			packageName: "package_uri",
+			policyID:    "policy_id0",
+			verifier:    NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id1", 3),
+			options:     []EvaluationOption{WithTrace()},
+			expected:    errs.ErrorVerification,
+			steps: []trace.Step{
+				{
+					PolicyID: "policy_id0", Package: "package_uri", RootID: "publishr_id1",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonLevelTooLow,
+				},
+				{
+					PolicyID: "policy_id0", Package: "package_uri", RootID: "publishr_id2",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonVerifierFailure,
+				},
+			},
+		},
+		{
+			name:        "package not found",
+			packageName: "other_uri",
+			policyID:    "policy_id0",
+			verifier:    NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id2", 3),
+			options:     []EvaluationOption{WithTrace()},
+			expected:    errs.ErrorNotFound,
+			steps: []trace.Step{
+				{
+					PolicyID: "policy_id0", Package: "other_uri",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonPackageNotFound,
+				},
+			},
+		},
+		{
+			name:        "policy not found",
+			packageName: "package_uri",
+			policyID:    "policy_id1",
+			verifier:    NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id2", 3),
+			options:     []EvaluationOption{WithTrace()},
+			expected:    errs.ErrorNotFound,
+			steps: []trace.Step{
+				{
+					PolicyID: "policy_id1", Package: "package_uri",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonPolicyNotFound,
+				},
+			},
+		},
+		{
+			name:        "no trace",
+			packageName: "package_uri",
+			policyID:    "policy_id0",
+			verifier:    NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id2", 3),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(org)),
+				common.NewNamedBytesIterator([][]byte{project}, true))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := pol.Evaluate(digests, tt.packageName, tt.policyID,
+				AttestationVerificationOption{Verifier: tt.verifier}, tt.options...)
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			tr := result.Trace()
+			if tt.steps == nil {
+				if tr != nil {
+					t.Fatalf("unexpected trace: %v", tr)
+				}
+				return
+			}
+			if tr == nil {
+				t.Fatalf("trace is nil")
+			}
+			// NOTE: the error details are not part of the contract.
+			if diff := cmp.Diff(tt.steps, tr.Steps, cmpopts.IgnoreFields(trace.Step{}, "Error")); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			for i := range tr.Steps {
+				if tr.Steps[i].Outcome == trace.OutcomeRejected && tr.Steps[i].Error == "" {
+					t.Fatalf("step %d has no error", i)
+				}
+			}
+		})
+	}
+}
+
+// flakyVerifier fails the first calls with err,
+// then forwards the calls to verifier.
+type flakyVerifier struct {
+	verifier AttestationVerifier
+	failures int
+	err      error
+	calls    int
+}
+
+func (v *flakyVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string, env []string,
+	opts AttestationVerifierPublishOptions) (*string, error) {
+	v.calls++
+	if v.calls <= v.failures {
+		return nil, v.err
+	}
+	return v.verifier.VerifyPublishAttestation(digests, packageName, env, opts)
+}
+
+func Test_Retry(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}}`)
+	project := []byte(`{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 2},
+		"packages": [{"name": "package_uri"}]}`)
+	transient := errs.TransientErrorNew(errors.New("429 too many requests"), 0)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name     string
+		retry    *retry.Policy
+		ctx      context.Context
+		failures int
+		err      error
+		calls    int
+		expected error
+	}{
+		{
+			name:     "transient error retried",
+			retry:    &retry.Policy{MaxAttempts: 3},
+			failures: 2,
+			err:      transient,
+			calls:    3,
+		},
+		{
+			name:     "transient error without retry",
+			failures: 1,
+			err:      transient,
+			calls:    1,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "attempts exhausted",
+			retry:    &retry.Policy{MaxAttempts: 3},
+			failures: 3,
+			err:      transient,
+			calls:    3,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "denial not retried",
+			retry:    &retry.Policy{MaxAttempts: 3},
+			failures: 1,
+			err:      fmt.Errorf("%w: no attestation", errs.ErrorVerification),
+			calls:    1,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "transient denial not retried",
+			retry:    &retry.Policy{MaxAttempts: 3},
+			failures: 1,
+			err:      errs.TransientErrorNew(fmt.Errorf("%w: digest", errs.ErrorMismatch), 0),
+			calls:    1,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "canceled context",
+			// NOTE: the test times out if the wait does not end with the context.
+			retry:    &retry.Policy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+			ctx:      canceled,
+			failures: 3,
+			err:      transient,
+			calls:    1,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "invalid retry policy",
+			retry:    &retry.Policy{},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(org)),
+				common.NewNamedBytesIterator([][]byte{project}, true))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			verifier := &flakyVerifier{
+				verifier: NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id1", 3),
+				failures: tt.failures,
+				err:      tt.err,
+			}
+			result := pol.Evaluate(digests, "package_uri", "policy_id0",
+				AttestationVerificationOption{Verifier: verifier, Retry: tt.retry, Context: tt.ctx})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.calls, verifier.calls); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Scopes(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri", "scopes": {"iam.gserviceaccount.com/v1": "gcp_sa", "region": "us-east1"}},
+			"build": {"require_slsa_level": 3}, "packages": [{"name": "package_uri"}]}`),
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewNamedBytesIterator(projects, true))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	result := pol.Evaluate(digests, "package_uri", "policy_id0", AttestationVerificationOption{
+		Verifier: NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id", 3),
+	})
+	if err := result.Error(); err != nil {
+		t.Fatalf("failed to evaluate policy: %v", err)
+	}
+	scopes := map[string]string{
+		ScopeKeyKubernetesServiceAccount: "principal_uri",
+		"iam.gserviceaccount.com/v1":     "gcp_sa",
+		"region":                         "us-east1",
+	}
+	if diff := cmp.Diff(scopes, result.Scopes()); diff != "" {
+		t.Fatalf("unexpected scopes (-want +got): \n%s", diff)
+	}
+	att, err := result.AttestationNew()
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	tests := []struct {
+		name     string
+		scopes   map[string]string
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:   "all scopes",
+			scopes: scopes,
+		},
+		{
+			name: "missing scope",
+			scopes: map[string]string{
+				ScopeKeyKubernetesServiceAccount: "principal_uri",
+				"region":                         "us-east1",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "mismatch scope",
+			scopes: map[string]string{
+				ScopeKeyKubernetesServiceAccount: "principal_uri",
+				"iam.gserviceaccount.com/v1":     "gcp_sa",
+				"region":                         "europe-west1",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "extra scope",
+			scopes: map[string]string{
+				ScopeKeyKubernetesServiceAccount: "principal_uri",
+				"iam.gserviceaccount.com/v1":     "gcp_sa",
+				"region":                         "us-east1",
+				"zone":                           "us-east1-b",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "extra scope allowed",
+			scopes: map[string]string{
+				ScopeKeyKubernetesServiceAccount: "principal_uri",
+				"iam.gserviceaccount.com/v1":     "gcp_sa",
+				"region":                         "us-east1",
+				"zone":                           "us-east1-b",
+			},
+			options: []VerificationOption{AllowExtraScopes()},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, tt.scopes, withUnknownScopes(tt.options...)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if len(tt.options) > 0 {
+				return
+			}
+			// The result verifies the scopes like the attestation does.
+			err = result.VerifyScopes(tt.scopes)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_EnvironmentPrincipals(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := `{"format": 1, "principal": {"environments": {"dev": "sa-dev", "prod": "sa-prod"}},
+		"build": {"require_slsa_level": 3}, "packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}}]}`
+	tests := []struct {
+		name        string
+		project     string
+		env         string
+		principal   string
+		creationErr error
+		expected    error
+	}{
+		{
+			name:      "prod principal",
+			project:   project,
+			env:       "prod",
+			principal: "sa-prod",
+		},
+		{
+			name:      "dev principal",
+			project:   project,
+			env:       "dev",
+			principal: "sa-dev",
+		},
+		{
+			name:     "unlisted environment",
+			project:  project,
+			env:      "staging",
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "duplicate environment",
+			project: `{"format": 1, "principal": {"environments": {"prod": "sa-prod", "prod": "sa-other"}},
+				"build": {"require_slsa_level": 3}, "packages": [{"name": "package_uri", "environment": {"any_of": ["prod"]}}]}`,
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name: "environment without principal",
+			project: `{"format": 1, "principal": {"environments": {"prod": "sa-prod"}},
+				"build": {"require_slsa_level": 3}, "packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}}]}`,
+			creationErr: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(strings.NewReader(org)),
+				common.NewNamedBytesIterator([][]byte{[]byte(tt.project)}, true))
+			if diff := cmp.Diff(tt.creationErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			result := pol.Evaluate(digests, "package_uri", "policy_id0", AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(digests, "package_uri", tt.env, "publishr_id", 3),
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if result.Error() != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.principal, result.PrincipalURI()); diff != "" {
+				t.Fatalf("unexpected principal (-want +got): \n%s", diff)
+			}
+			// The attestation records the selected principal.
+			att, err := result.AttestationNew()
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			if err := verification.Verify(digests, map[string]string{
+				ScopeKeyKubernetesServiceAccount: tt.principal,
+			}); err != nil {
+				t.Fatalf("failed to verify attestation: %v", err)
+			}
+		})
+	}
+}
+
+func Test_AllowedEnvironments(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	tests := []struct {
+		name         string
+		project      string
+		env          string
+		environments []string
+		expected     error
+	}{
+		{
+			name: "environments",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+				"packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod/*"]}}]}`,
+			env:          "dev",
+			environments: []string{"dev", "prod/*"},
+		},
+		{
+			name: "no environment",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+				"packages": [{"name": "package_uri"}]}`,
+		},
+		{
+			name: "exception",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+				"packages": [{"name": "package_uri", "environment": {"any_of": ["dev"]}}],
+				"exceptions": [{"digest": "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+				"decision": "allow", "reason": "incident", "expires": "2100-01-02T15:04:05Z"}]}`,
+			env:          "staging",
+			environments: []string{"dev"},
+		},
+		{
+			name: "evaluation failure",
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+				"packages": [{"name": "package_uri", "environment": {"any_of": ["dev"]}}]}`,
+			env:      "prod",
+			expected: errs.ErrorVerification,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(strings.NewReader(org)),
+				common.NewNamedBytesIterator([][]byte{[]byte(tt.project)}, true))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := pol.Evaluate(digests, "package_uri", "policy_id0", AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(digests, "package_uri", tt.env, "publishr_id", 3),
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			environments := result.AllowedEnvironments()
+			if diff := cmp.Diff(tt.environments, environments); diff != "" {
+				t.Fatalf("unexpected environments (-want +got): \n%s", diff)
+			}
+			// Modifying the environments must not change the result.
+			if len(environments) > 0 {
+				environments[0] = "modified"
+				if diff := cmp.Diff(tt.environments, result.AllowedEnvironments()); diff != "" {
+					t.Fatalf("unexpected environments (-want +got): \n%s", diff)
+				}
+			}
+			// The environments are serialized.
+			content, err := result.ToJSON()
+			if err != nil {
+				t.Fatalf("failed to serialize result: %v", err)
+			}
+			decoded, err := ResultFromJSON(content)
+			if err != nil {
+				t.Fatalf("failed to parse result: %v", err)
+			}
+			if diff := cmp.Diff(tt.environments, decoded.AllowedEnvironments()); diff != "" {
+				t.Fatalf("unexpected environments (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_UnknownFields(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+		"packages": [{"name": "package_uri"}]}`
+	tests := []struct {
+		name     string
+		org      string
+		project  string
+		options  []PolicyOption
+		contains []string
+		expected error
+	}{
+		{
+			name:    "no unknown fields",
+			org:     org,
+			project: project,
+		},
+		{
+			name:     "unknown org field",
+			org:      `{"format": 1, "annotations": {}, "roots": {"publish": []}}`,
+			project:  project,
+			contains: []string{`"annotations"`, "organization policy"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "misspelled project field",
+			org:  org,
+			project: `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_lever": 3},
+				"packages": [{"name": "package_uri"}]}`,
+			contains: []string{`"require_slsa_lever"`, "policy_id0"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "allowed unknown fields",
+			org:  `{"format": 1, "annotations": {}, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`,
+			project: `{"format": 1, "owner": "team", "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+				"packages": [{"name": "package_uri"}]}`,
+			options: []PolicyOption{AllowUnknownFields()},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(tt.org))),
+				common.NewNamedBytesIterator([][]byte{[]byte(tt.project)}, true), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(err.Error(), s) {
+					t.Fatalf("error (%v) does not contain (%q)", err, s)
+				}
+			}
+		})
+	}
+}
+
+//go:embed testdata/policies
+var embeddedPolicies embed.FS
+
+func Test_PolicyNewFromFS(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}}`)
+	project := []byte(`{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 2},
+		"packages": [{"name": "package_uri"}]}`)
+	tests := []struct {
+		name        string
+		fsys        fs.FS
+		orgPath     string
+		projectGlob string
+		policyID    string
+		errPath     string
+		expected    error
+	}{
+		{
+			name:        "embed fs",
+			fsys:        embeddedPolicies,
+			orgPath:     "testdata/policies/org.json",
+			projectGlob: "testdata/policies/*.json",
+			policyID:    "testdata/policies/servers-prod.json",
+		},
+		{
+			name:        "os dir fs",
+			fsys:        os.DirFS("testdata/policies"),
+			orgPath:     "org.json",
+			projectGlob: "*.json",
+			policyID:    "servers-prod.json",
+		},
+		{
+			name: "map fs",
+			fsys: fstest.MapFS{
+				"org.json":                        {Data: org},
+				"projects/servers-prod.json":      {Data: project},
+				"projects/notes/servers-dev.json": {Data: project},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/*.json",
+			policyID:    "projects/servers-prod.json",
+		},
+		{
+			name: "invalid project policy",
+			fsys: fstest.MapFS{
+				"org.json":                   {Data: org},
+				"projects/servers-prod.json": {Data: project},
+				"projects/servers-dev.json":  {Data: []byte(`{"format": 1, "principal": {"uri": "principal_uri"}}`)},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/*.json",
+			errPath:     "projects/servers-dev.json",
+			expected:    errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid org policy",
+			fsys: fstest.MapFS{
+				"policies/org.json":          {Data: []byte(`{"format": 1, "roots": {"publish": [{"unknown": "value"}]}}`)},
+				"projects/servers-prod.json": {Data: project},
+			},
+			orgPath:     "policies/org.json",
+			projectGlob: "projects/*.json",
+			errPath:     "policies/org.json",
+			expected:    errs.ErrorInvalidField,
+		},
+		{
+			name: "org policy not found",
+			fsys: fstest.MapFS{
+				"projects/servers-prod.json": {Data: project},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/*.json",
+			errPath:     "org.json",
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name: "invalid glob",
+			fsys: fstest.MapFS{
+				"org.json":                   {Data: org},
+				"projects/servers-prod.json": {Data: project},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/[.json",
+			expected:    errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNewFromFS(tt.fsys, tt.orgPath, tt.projectGlob)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.errPath) {
+					t.Fatalf("error (%q) does not contain (%q)", err, tt.errPath)
+				}
+				return
+			}
+			verifier := NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id1", 3)
+			result := pol.Evaluate(digests, "package_uri", tt.policyID, AttestationVerificationOption{Verifier: verifier})
+			if diff := cmp.Diff(nil, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_MaxPolicySize(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+		"packages": [{"name": "package_uri", "environment": {"any_of": ["dev", "prod"]}}]}`
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		contains string
+		expected error
+	}{
+		{
+			name: "default size",
+		},
+		{
+			name:    "files within size",
+			options: []PolicyOption{SetMaxPolicySize(int64(len(project)))},
+		},
+		{
+			name:     "org larger than size",
+			options:  []PolicyOption{SetMaxPolicySize(int64(len(org) - 1))},
+			contains: "[organization]",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "project larger than size",
+			options:  []PolicyOption{SetMaxPolicySize(int64(len(org)))},
+			contains: "[project]",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid size",
+			options:  []PolicyOption{SetMaxPolicySize(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+				common.NewNamedBytesIterator([][]byte{[]byte(project)}, true), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.contains != "" && !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("error (%v) does not contain (%q)", err, tt.contains)
+			}
+		})
+	}
+}
+
+// countingIterator counts the policy files read from an iterator.
+type countingIterator struct {
+	iterator.NamedReadCloserIterator
+	count int
+}
+
+func (iter *countingIterator) Next() (string, io.ReadCloser) {
+	iter.count++
+	return iter.NamedReadCloserIterator.Next()
+}
+
+func Test_MaxPolicies(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"format": 1, "principal": {"uri": "principal_uri%d"}, "build": {"require_slsa_level": 3},
+			"packages": [{"name": "package_uri%d"}]}`, i, i))
+	}
+	projects := [][]byte{project(1), project(2), project(3)}
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		read     int
+		expected error
+	}{
+		{
+			name: "default count",
+			read: 3,
+		},
+		{
+			name:    "files within count",
+			options: []PolicyOption{SetMaxPolicies(3)},
+			read:    3,
+		},
+		{
+			name:     "more files than count",
+			options:  []PolicyOption{SetMaxPolicies(1)},
+			read:     1,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid count",
+			options:  []PolicyOption{SetMaxPolicies(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			iter := &countingIterator{NamedReadCloserIterator: common.NewNamedBytesIterator(projects, true)}
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), iter, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil && tt.read != 0 && !strings.Contains(err.Error(), "maximum number of policy files") {
+				t.Fatalf("error (%v) does not name the limit", err)
+			}
+			// The files beyond the limit are not read.
+			if diff := cmp.Diff(tt.read, iter.count); diff != "" {
+				t.Fatalf("unexpected files read (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_MaxPackagesPerPolicy(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+		"packages": [{"name": "package_uri1"}, {"name": "package_uri2"}]}`
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		contains string
+		expected error
+	}{
+		{
+			name: "default count",
+		},
+		{
+			name:    "packages within count",
+			options: []PolicyOption{SetMaxPackagesPerPolicy(2)},
+		},
+		{
+			name:     "more packages than count",
+			options:  []PolicyOption{SetMaxPackagesPerPolicy(1)},
+			contains: "policy_id0: [project]",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid count",
+			options:  []PolicyOption{SetMaxPackagesPerPolicy(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+				common.NewNamedBytesIterator([][]byte{[]byte(project)}, true), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.contains != "" && !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("error (%v) does not contain (%q)", err, tt.contains)
+			}
+		})
+	}
+}
+
+// recordingHandler records the messages of the log records.
+type recordingHandler struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, r.Message)
+	return nil
+}
+
+func Test_SetLogger(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}},
+		{"id": "publishr_id2", "build": {"max_slsa_level": 1}}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "principal": {"uri": "principal_uri0"}, "build": {"require_slsa_level": 2},
+			"packages": [{"name": "package_uri0"}]}`),
+	}
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		publishrID string
+		messages   []string
+		expected   error
+	}{
+		{
+			name:       "allow",
+			publishrID: "publishr_id1",
+			messages: []string{
+				"policy file parsed",
+				"policy file parsed",
+				"project policy selected",
+				"package matched",
+				"root considered",
+				"verifier invoked",
+				"verifier result",
+				"policy decision",
+			},
+		},
+		{
+			name:       "deny",
+			publishrID: "other_publishr_id",
+			messages: []string{
+				"policy file parsed",
+				"policy file parsed",
+				"project policy selected",
+				"package matched",
+				"root considered",
+				"verifier invoked",
+				"verifier result",
+				"root considered",
+				"root skipped",
+				"policy decision",
+			},
+			expected: errs.ErrorVerification,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := &recordingHandler{}
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+				common.NewNamedBytesIterator(projects, true), SetLogger(slog.New(handler)))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := pol.Evaluate(digests, "package_uri0", "policy_id0", AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(digests, "package_uri0", "", tt.publishrID, 3),
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.messages, handler.messages); diff != "" {
+				t.Fatalf("unexpected messages (-want +got): \n%s", diff)
+			}
+		})
+	}
+	// A nil logger is rejected.
+	_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+		common.NewNamedBytesIterator(projects, true), SetLogger(nil))
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// Parsing without workers is rejected.
+	_, err = PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+		common.NewNamedBytesIterator(projects, true), SetParseWorkers(0))
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_PolicyCache(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	projects := map[string][]byte{
+		"value1": []byte(`{"format": 1, "principal": {"uri": "principal_uri1"}, "build": {"require_slsa_level": 3},
+			"packages": [{"name": "package_uri"}]}`),
+		"value2": []byte(`{"format": 1, "principal": {"uri": "principal_uri2"}, "build": {"require_slsa_level": 3},
+			"packages": [{"name": "package_uri"}]}`),
+	}
+	cache := PolicyCacheNew()
+	cache.now = newFakeClock(time.Second)
+	if diff := cmp.Diff(PolicyCacheStats{}, cache.Stats()); diff != "" {
+		t.Fatalf("unexpected stats (-want +got): \n%s", diff)
+	}
+	// Policies are rebuilt and evaluated concurrently.
+	const calls = 20
+	var wg sync.WaitGroup
+	errors := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(value string) {
+			defer wg.Done()
+			pol, err := cache.Get(intoto.DigestSet{"sha256": value}, io.NopCloser(bytes.NewReader([]byte(org))),
+				common.NewNamedBytesIterator([][]byte{projects[value]}, true))
+			if err != nil {
+				errors <- err
+				return
+			}
+			result := pol.Evaluate(digests, "package_uri", "policy_id0", AttestationVerificationOption{
+				Verifier: NewE2eAttestationVerifier(digests, "package_uri", "", "publishr_id", 3),
+			})
+			if err := result.Error(); err != nil {
+				errors <- err
+				return
+			}
+			if result.PrincipalURI() != "principal_uri"+value[len(value)-1:] {
+				errors <- fmt.Errorf("unexpected principal (%q) for digest (%q)", result.PrincipalURI(), value)
+			}
+		}(fmt.Sprintf("value%d", i%2+1))
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	stats := cache.Stats()
+	if stats.Hits+stats.Misses != calls || stats.Misses < 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.LastBuild != time.Unix(int64(stats.Misses), 0) {
+		t.Fatalf("unexpected last build: %v", stats.LastBuild)
+	}
+
+	// The digests must be valid.
+	_, err := cache.Get(intoto.DigestSet{}, io.NopCloser(bytes.NewReader([]byte(org))),
+		common.NewNamedBytesIterator([][]byte{projects["value1"]}, true))
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// A policy that fails to build does not replace the cached policy.
+	_, err = cache.Get(intoto.DigestSet{"sha256": "89dc6ae7f06a9f46b565af03eab0ece0bf6024d3659b7e3a1d03573cfeb0b59d"}, io.NopCloser(bytes.NewReader([]byte(org))),
+		common.NewNamedBytesIterator([][]byte{[]byte(`{"format": 1`)}, true))
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff(stats.LastBuild, cache.Stats().LastBuild); diff != "" {
+		t.Fatalf("unexpected last build (-want +got): \n%s", diff)
+	}
+}
+
+// newLargePolicy returns a policy with projects*packages packages.
+// Each project also defines a package name pattern.
+func newLargePolicy(b *testing.B, projects, packages int) *Policy {
+	org := `{"format": 1, "roots": {"publish": [{"id": "publishr_id1", "build": {"max_slsa_level": 3}}]}}`
+	policies := make([][]byte, projects)
+	for i := range policies {
+		names := []string{fmt.Sprintf(`{"name": "pattern%d/*"}`, i)}
+		for j := 0; j < packages; j++ {
+			names = append(names, fmt.Sprintf(`{"name": "package_uri%d_%d"}`, i, j))
+		}
+		policies[i] = []byte(fmt.Sprintf(`{"format": 1, "principal": {"uri": "principal_uri%d"},
+			"build": {"require_slsa_level": 3}, "packages": [%s]}`, i, strings.Join(names, ", ")))
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewNamedBytesIterator(policies, true))
+	if err != nil {
+		b.Fatalf("failed to create policy: %v", err)
+	}
+	return pol
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	digests := intoto.DigestSet{
+		"sha256": "728349abb3d6fe69386e0755b956978e62e81c56759c4e21801554a5fdc7e7f5",
+	}
+	pol := newLargePolicy(b, 1000, 10)
+	b.ResetTimer()
+	benchmarks := []struct {
+		name        string
+		packageName string
+	}{
+		{
+			name:        "exact name",
+			packageName: "package_uri999_9",
+		},
+		{
+			name:        "pattern",
+			packageName: "pattern999/package",
+		},
+	}
+	for _, bb := range benchmarks {
+		bb := bb // Re-initializing variable so it is not changed while executing the closure below
+		opts := AttestationVerificationOption{
+			Verifier: NewE2eAttestationVerifier(digests, bb.packageName, "", "publishr_id1", 3),
+		}
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if result := pol.Evaluate(digests, bb.packageName, "policy_id999", opts); result.Error() != nil {
+					b.Fatalf("failed to evaluate: %v", result.Error())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluateAll(b *testing.B) {
+	digests := intoto.DigestSet{
+		"sha256": "728349abb3d6fe69386e0755b956978e62e81c56759c4e21801554a5fdc7e7f5",
+	}
+	pol := newLargePolicy(b, 1000, 10)
+	b.ResetTimer()
+	opts := AttestationVerificationOption{
+		Verifier: NewE2eAttestationVerifier(digests, "package_uri999_9", "", "publishr_id1", 3),
+	}
+	for i := 0; i < b.N; i++ {
+		results, err := pol.EvaluateAll(digests, "package_uri999_9", opts)
+		if err != nil {
+			b.Fatalf("failed to evaluate: %v", err)
+		}
+		if len(results) != 1 || !results[0].Allow() {
+			b.Fatalf("unexpected results: %v", results)
+		}
+	}
+}
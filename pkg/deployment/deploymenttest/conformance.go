@@ -0,0 +1,229 @@
+package deploymenttest
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+)
+
+// VerifierFactory creates the verifier under test. The verifier
+// must verify the publish attestation described by att, e.g.
+// by signing att.Statement() with a test key.
+type VerifierFactory func(t *testing.T, att PublishAttestation) deployment.AttestationVerifier
+
+// GoldenAttestation returns the publish attestation the conformance
+// suite starts from.
+func GoldenAttestation() PublishAttestation {
+	return PublishAttestation{
+		Digests: map[string]string{
+			"sha256": strings.Repeat("a", 64),
+		},
+		PackageName:   "package_name",
+		Registry:      "registry",
+		Environment:   "prod",
+		BuildLevel:    3,
+		PublisherID:   "publishr_id",
+		AuthorID:      "author_id",
+		AuthorVersion: "1.2.0",
+	}
+}
+
+// RunVerifierConformance exercises the contract the deployment policy
+// evaluation relies on:
+//   - An attestation is accepted at or above the required build level.
+//   - If environments are requested, the environment of the attestation
+//     is returned. It must match one of the environments, which may be
+//     wildcards such as "prod/*".
+//   - If no environment is requested, a nil environment is returned.
+//   - The attestation is rejected if the digests, the package name, the
+//     publish root or the environment do not match.
+//   - If a minimum author version is requested, the attestation is rejected
+//     if its author is missing or older.
+//
+// Verifiers that do not support publish roots defined by certificate
+// identity must reject them.
+func RunVerifierConformance(t *testing.T, newVerifier VerifierFactory) {
+	t.Helper()
+	golden := GoldenAttestation()
+	tests := []struct {
+		name        string
+		att         func(*PublishAttestation)
+		digests     map[string]string
+		packageName string
+		environment []string
+		opts        deployment.AttestationVerifierPublishOptions
+		expected    *string
+		fails       bool
+	}{
+		{
+			name:        "environment",
+			environment: []string{"dev", "prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+			expected:    asPointer("prod"),
+		},
+		{
+			name: "environment wildcard",
+			att: func(a *PublishAttestation) {
+				a.Environment = "prod/us-east1"
+			},
+			environment: []string{"dev", "prod/*"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+			expected:    asPointer("prod/us-east1"),
+		},
+		{
+			name: "no environment",
+			att: func(a *PublishAttestation) {
+				a.Environment = ""
+			},
+			opts: deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+		},
+		{
+			name:        "environment mismatch",
+			environment: []string{"dev", "staging"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+			fails:       true,
+		},
+		{
+			name: "environment missing",
+			att: func(a *PublishAttestation) {
+				a.Environment = ""
+			},
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+			fails:       true,
+		},
+		{
+			name:        "level above minimum",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 2},
+			expected:    asPointer("prod"),
+		},
+		{
+			name:        "level below minimum",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 4},
+			fails:       true,
+		},
+		{
+			name:        "publisher mismatch",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "other_publishr_id", MinBuildLevel: 3},
+			fails:       true,
+		},
+		{
+			name:        "publisher regex",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrIDRegex: "^publishr_.*$", MinBuildLevel: 3},
+			expected:    asPointer("prod"),
+		},
+		{
+			name:        "publisher regex mismatch",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrIDRegex: "^other_.*$", MinBuildLevel: 3},
+			fails:       true,
+		},
+		{
+			name:        "identity mismatch",
+			environment: []string{"prod"},
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "^https://github.com/org/other/.*$",
+				MinBuildLevel:   3,
+				Identity: &deployment.RootIdentity{
+					Issuer:       "https://token.actions.githubusercontent.com",
+					SubjectRegex: regexp.MustCompile("^https://github.com/org/other/.*$"),
+				},
+			},
+			fails: true,
+		},
+		{
+			name:        "author version above minimum",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3, MinAuthorVersion: "1.1"},
+			expected:    asPointer("prod"),
+		},
+		{
+			name:        "author version below minimum",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3, MinAuthorVersion: "1.3.0"},
+			fails:       true,
+		},
+		{
+			name: "author missing",
+			att: func(a *PublishAttestation) {
+				a.AuthorID = ""
+				a.AuthorVersion = ""
+			},
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3, MinAuthorVersion: "1.0.0"},
+			fails:       true,
+		},
+		{
+			name:        "digests mismatch",
+			digests:     map[string]string{"sha256": strings.Repeat("b", 64)},
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+			fails:       true,
+		},
+		{
+			name:        "package name mismatch",
+			packageName: "other_package_name",
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3},
+			fails:       true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			att := golden
+			if tt.att != nil {
+				tt.att(&att)
+			}
+			digests := att.Digests
+			if tt.digests != nil {
+				digests = tt.digests
+			}
+			packageName := att.PackageName
+			if tt.packageName != "" {
+				packageName = tt.packageName
+			}
+			opts := tt.opts
+			opts.BuildLevel = opts.MinBuildLevel
+			env, err := newVerifier(t, att).VerifyPublishAttestation(digests, packageName, tt.environment, opts)
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got environment (%v)", pointerString(env))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if tt.expected == nil {
+				if env != nil {
+					t.Fatalf("expected nil environment, got (%q)", *env)
+				}
+				return
+			}
+			if env == nil {
+				t.Fatalf("expected environment (%q), got nil", *tt.expected)
+			}
+			if *env != *tt.expected {
+				t.Fatalf("expected environment (%q), got (%q)", *tt.expected, *env)
+			}
+		})
+	}
+}
+
+func pointerString(s *string) string {
+	if s == nil {
+		return "nil"
+	}
+	return *s
+}
+
+func asPointer[K interface{}](o K) *K {
+	return &o
+}
@@ -0,0 +1,167 @@
+// Package deploymenttest provides a fake verifier, golden publish
+// attestations and a conformance suite for implementations of
+// deployment.AttestationVerifier.
+package deploymenttest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// PublishAttestation describes a publish attestation.
+type PublishAttestation struct {
+	Digests intoto.DigestSet
+	// PackageName is the name of the package in the deployment policy.
+	PackageName string
+	// Registry is the registry of the package.
+	Registry string
+	// Environment is empty if the package was published
+	// without environment.
+	Environment string
+	BuildLevel  int
+	// PublisherID is the ID of the publish root that signed
+	// the attestation.
+	PublisherID string
+	// Issuer and Subject, if set, are the certificate identity
+	// of the publish root that signed the attestation.
+	Issuer, Subject string
+	// AuthorID and AuthorVersion, if set, are the tool
+	// that created the attestation.
+	AuthorID, AuthorVersion string
+}
+
+// Statement returns the unsigned in-toto statement of the attestation.
+func (a PublishAttestation) Statement() ([]byte, error) {
+	opts := []publish.AttestationCreationOption{
+		publish.SetSlsaBuildLevel(a.BuildLevel),
+	}
+	if a.AuthorVersion != "" {
+		opts = append(opts, publish.WithAuthor(a.AuthorID, a.AuthorVersion))
+	}
+	creation, err := publish.CreationNew(
+		intoto.Subject{
+			Digests: a.Digests,
+		},
+		intoto.PackageDescriptor{
+			Name:        a.PackageName,
+			Registry:    a.Registry,
+			Environment: a.Environment,
+		},
+		opts...)
+	if err != nil {
+		return nil, err
+	}
+	return creation.ToBytes()
+}
+
+// NewAttestationVerifier returns a verifier that accepts the given
+// attestations. It implements the contract of deployment.AttestationVerifier
+// and passes RunVerifierConformance.
+func NewAttestationVerifier(atts ...PublishAttestation) deployment.AttestationVerifier {
+	return &attestationVerifier{atts: atts}
+}
+
+type attestationVerifier struct {
+	atts []PublishAttestation
+}
+
+func (v *attestationVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	var errList []error
+	for i := range v.atts {
+		env, err := verify(&v.atts[i], digests, packageName, environment, opts)
+		if err != nil {
+			errList = append(errList, err)
+			continue
+		}
+		return env, nil
+	}
+	if len(errList) == 0 {
+		return nil, fmt.Errorf("%w: no attestation for package (%q)", errs.ErrorVerification, packageName)
+	}
+	return nil, errors.Join(errList...)
+}
+
+func verify(att *PublishAttestation, digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	if !maps.Equal(att.Digests, digests) {
+		return nil, fmt.Errorf("%w: digests (%q) != (%q)", errs.ErrorVerification, digests, att.Digests)
+	}
+	if packageName != att.PackageName {
+		return nil, fmt.Errorf("%w: package name (%q) != (%q)", errs.ErrorVerification, packageName, att.PackageName)
+	}
+	if err := verifyPublisher(att, opts); err != nil {
+		return nil, err
+	}
+	if att.BuildLevel < opts.MinBuildLevel {
+		return nil, fmt.Errorf("%w: build level (%d) is below (%d)", errs.ErrorVerification,
+			att.BuildLevel, opts.MinBuildLevel)
+	}
+	if opts.MinAuthorVersion != "" && att.AuthorVersion == "" {
+		return nil, fmt.Errorf("%w: no author, expected version (%q) or above", errs.ErrorVerification,
+			opts.MinAuthorVersion)
+	}
+	if err := opts.VerifyAuthorVersion(att.AuthorVersion); err != nil {
+		return nil, err
+	}
+	return verifyEnvironment(att, environment)
+}
+
+func verifyPublisher(att *PublishAttestation, opts deployment.AttestationVerifierPublishOptions) error {
+	if opts.Identity != nil {
+		if att.Issuer != opts.Identity.Issuer || !opts.Identity.SubjectRegex.MatchString(att.Subject) {
+			return fmt.Errorf("%w: identity (%q, %q) does not match (%q, %q)", errs.ErrorVerification,
+				att.Issuer, att.Subject, opts.Identity.Issuer, opts.Identity.SubjectRegex)
+		}
+		return nil
+	}
+	if opts.PublishrID != "" {
+		if att.PublisherID != opts.PublishrID {
+			return fmt.Errorf("%w: publisher ID (%q) != (%q)", errs.ErrorVerification, att.PublisherID, opts.PublishrID)
+		}
+		return nil
+	}
+	matched, err := regexp.MatchString(opts.PublishrIDRegex, att.PublisherID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid publisher ID regex (%q): %v", errs.ErrorInvalidInput, opts.PublishrIDRegex, err)
+	}
+	if !matched {
+		return fmt.Errorf("%w: publisher ID (%q) does not match (%q)", errs.ErrorVerification,
+			att.PublisherID, opts.PublishrIDRegex)
+	}
+	return nil
+}
+
+func verifyEnvironment(att *PublishAttestation, environment []string) (*string, error) {
+	if len(environment) == 0 {
+		if att.Environment != "" {
+			return nil, fmt.Errorf("%w: unexpected environment (%q)", errs.ErrorVerification, att.Environment)
+		}
+		return nil, nil
+	}
+	if att.Environment == "" {
+		return nil, fmt.Errorf("%w: no environment, expected one of (%q)", errs.ErrorVerification, environment)
+	}
+	for _, env := range environment {
+		if env == att.Environment {
+			return &env, nil
+		}
+		if !strings.Contains(env, "*") {
+			continue
+		}
+		if matched, _ := path.Match(env, att.Environment); matched {
+			attEnv := att.Environment
+			return &attEnv, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: environment (%q) not in (%q)", errs.ErrorVerification, att.Environment, environment)
+}
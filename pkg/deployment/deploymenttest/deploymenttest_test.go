@@ -0,0 +1,102 @@
+package deploymenttest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+type packageHelper struct{}
+
+func (p *packageHelper) PolicyPackageName(desc intoto.PackageDescriptor) (string, error) {
+	return desc.Name, nil
+}
+
+func (p *packageHelper) PackageDescriptor(name string) (intoto.PackageDescriptor, error) {
+	return intoto.PackageDescriptor{
+		Name:     name,
+		Registry: "registry",
+	}, nil
+}
+
+func Test_RunVerifierConformance(t *testing.T) {
+	t.Parallel()
+	RunVerifierConformance(t, func(t *testing.T, att PublishAttestation) deployment.AttestationVerifier {
+		return NewAttestationVerifier(att)
+	})
+}
+
+func Test_Statement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		att      func(*PublishAttestation)
+		opts     []publish.VerificationOption
+		expected error
+	}{
+		{
+			name: "golden attestation",
+			opts: []publish.VerificationOption{
+				publish.IsSlsaBuildLevel(3),
+				publish.IsPackageEnvironment("prod"),
+			},
+		},
+		{
+			name: "no environment",
+			att: func(a *PublishAttestation) {
+				a.Environment = ""
+			},
+			opts: []publish.VerificationOption{
+				publish.IsPackageEnvironment("prod"),
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "level mismatch",
+			opts: []publish.VerificationOption{
+				publish.IsSlsaBuildLevel(2),
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "empty registry",
+			att: func(a *PublishAttestation) {
+				a.Registry = ""
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att := GoldenAttestation()
+			if tt.att != nil {
+				tt.att(&att)
+			}
+			err := verifyStatement(att, tt.opts...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func verifyStatement(att PublishAttestation, opts ...publish.VerificationOption) error {
+	content, err := att.Statement()
+	if err != nil {
+		return err
+	}
+	verification, err := publish.VerificationNew(io.NopCloser(bytes.NewReader(content)), &packageHelper{})
+	if err != nil {
+		return err
+	}
+	return verification.Verify(att.Digests, att.PackageName, opts...)
+}
@@ -0,0 +1,32 @@
+package deployment
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Test_Dependencies guards the dependency budget of the verification
+// path: it must only depend on the standard library and this module,
+// so that small offline verifiers can embed it.
+func Test_Dependencies(t *testing.T) {
+	t.Parallel()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("go binary not found: %v", err)
+	}
+	packages := []string{
+		".",
+		"../utils/intoto",
+		"../errs",
+	}
+	out, err := exec.Command(goBin, append([]string{"list", "-deps", "-f", "{{if not .Standard}}{{.ImportPath}}{{end}}"}, packages...)...).Output()
+	if err != nil {
+		t.Fatalf("failed to list dependencies: %v", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if !strings.HasPrefix(dep, "github.com/slsa-framework/slsa-policy/pkg/") {
+			t.Errorf("unexpected dependency: %q", dep)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// PolicyPackage describes a package defined by a project policy.
+type PolicyPackage struct {
+	PolicyID     string   `json:"policy_id"`
+	Name         string   `json:"name"`
+	Environments []string `json:"environments,omitempty"`
+	// RequiredLevel is the SLSA build level required by the project.
+	RequiredLevel int    `json:"required_slsa_level"`
+	PrincipalURI  string `json:"principal_uri"`
+	// PublishRoots, if set, contains the IDs of the only
+	// publish roots allowed to attest to the package.
+	PublishRoots []string `json:"publish_roots,omitempty"`
+}
+
+// PolicyRoot describes a publish root of the organization policy.
+type PolicyRoot struct {
+	// ID is the ID of the root, or its name if the
+	// root is identified by its certificate identity.
+	ID           string `json:"id"`
+	MaxSlsaLevel int    `json:"max_slsa_level"`
+}
+
+// Packages returns the packages of the project policies,
+// sorted by policy ID and in the order they are defined.
+func (p *Policy) Packages() []PolicyPackage {
+	packages := []PolicyPackage{}
+	for _, pkg := range p.policy.Packages() {
+		packages = append(packages, policyPackage(pkg))
+	}
+	return packages
+}
+
+func policyPackage(pkg internal.PackageDescription) PolicyPackage {
+	return PolicyPackage{
+		PolicyID:      pkg.PolicyID,
+		Name:          pkg.Name,
+		Environments:  pkg.Environments,
+		RequiredLevel: pkg.RequiredLevel,
+		PrincipalURI:  pkg.PrincipalURI,
+		PublishRoots:  pkg.PublishRoots,
+	}
+}
+
+// Roots returns the publish roots of the organization policy,
+// in the order they are defined.
+func (p *Policy) Roots() []PolicyRoot {
+	roots := []PolicyRoot{}
+	for _, root := range p.policy.Roots() {
+		roots = append(roots, PolicyRoot{
+			ID:           root.ID,
+			MaxSlsaLevel: root.MaxSlsaLevel,
+		})
+	}
+	return roots
+}
+
+// PackageNames returns the sorted names of the packages
+// the project policies define.
+func (p *Policy) PackageNames() []string {
+	return p.policy.PackageNames()
+}
+
+// PackageRequirements returns the requirements of a package, one per
+// project policy that defines it, sorted by policy ID. It returns
+// errs.ErrorNotFound if no project policy defines the package.
+// The returned values are copies and may be modified by the caller.
+func (p *Policy) PackageRequirements(name string) ([]PolicyPackage, error) {
+	var packages []PolicyPackage
+	for _, pkg := range p.policy.PackagesNamed(name) {
+		packages = append(packages, policyPackage(pkg))
+	}
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("%w: package's name (%q) not present in project policies", errs.ErrorNotFound, name)
+	}
+	return packages, nil
+}
+
+// PackageEnvironments returns the environments the project policy
+// policyID allows for a package, as configured in its any_of field.
+// Entries may be wildcards, e.g. "prod/*". It is nil if the package
+// configures no environment, and it returns errs.ErrorNotFound if
+// the policy does not exist or does not define the package.
+// The returned value is a copy and may be modified by the caller.
+func (p *Policy) PackageEnvironments(packageName, policyID string) ([]string, error) {
+	return p.policy.PackageEnvironments(packageName, policyID)
+}
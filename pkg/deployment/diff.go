@@ -0,0 +1,162 @@
+package deployment
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/parallel"
+)
+
+// EvaluationRequest defines a request to evaluate, e.g.
+// recorded from a previous evaluation.
+type EvaluationRequest struct {
+	Digests     intoto.DigestSet
+	PackageName string
+	PolicyID    string
+	// Scopes, if set, are the scopes the package runs with. The
+	// request is denied if the evaluation allows other scopes.
+	Scopes map[string]string
+}
+
+// DiffOptions defines options for DiffPolicies().
+type DiffOptions struct {
+	// Verifier verifies the publish attestations for
+	// both policies.
+	Verifier AttestationVerifier
+	// Workers is the maximum number of requests evaluated
+	// concurrently. It defaults to GOMAXPROCS. The verifier
+	// must be safe for concurrent use unless Workers is 1.
+	Workers int
+}
+
+// Decision is the decision of a policy for a request.
+type Decision struct {
+	Allow bool
+	// Scopes are the scopes the package is allowed to run with.
+	// They are nil if the request is denied.
+	Scopes map[string]string
+	// Err is the error of the evaluation. It is nil
+	// if the request is allowed.
+	Err error
+}
+
+// Category returns the category of the error, see errs.Category.
+// It is empty if the request is allowed.
+func (d Decision) Category() string {
+	if d.Err == nil {
+		return ""
+	}
+	return errs.Category(d.Err)
+}
+
+func (d Decision) equal(other Decision) bool {
+	return d.Allow == other.Allow && maps.Equal(d.Scopes, other.Scopes)
+}
+
+// DecisionDiff describes a request whose decision differs
+// between two policies.
+type DecisionDiff struct {
+	// Index is the index of the request in the workload.
+	Index   int
+	Request EvaluationRequest
+	Old     Decision
+	New     Decision
+}
+
+// DiffPolicies evaluates each request of the workload against both
+// policies, with the same verifier, and returns the requests whose
+// decision differs, sorted by their index in the workload. Decisions
+// differ if one allows the request and the other denies it, or if
+// they allow different scopes. Two denials with different errors
+// do not differ.
+func DiffPolicies(oldPolicy, newPolicy *Policy, workload []EvaluationRequest, opts DiffOptions) ([]DecisionDiff, error) {
+	if oldPolicy == nil || newPolicy == nil {
+		return nil, fmt.Errorf("%w: policy is nil", errs.ErrorInvalidInput)
+	}
+	if opts.Verifier == nil {
+		return nil, fmt.Errorf("%w: verifier is nil", errs.ErrorInvalidInput)
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers < 1 {
+		return nil, fmt.Errorf("%w: workers (%d) must be positive", errs.ErrorInvalidInput, workers)
+	}
+	for i := range workload {
+		if err := workload[i].validate(); err != nil {
+			return nil, fmt.Errorf("request %d: %w", i, err)
+		}
+	}
+	verification := AttestationVerificationOption{
+		Verifier: opts.Verifier,
+	}
+	// NOTE: each request writes its own entry, so the
+	// results do not depend on scheduling.
+	results := make([]*DecisionDiff, len(workload))
+	parallel.ForEach(len(workload), workers, func(index int) {
+		req := &workload[index]
+		oldDecision := req.evaluate(oldPolicy, verification)
+		newDecision := req.evaluate(newPolicy, verification)
+		if oldDecision.equal(newDecision) {
+			return
+		}
+		results[index] = &DecisionDiff{
+			Index:   index,
+			Request: *req,
+			Old:     oldDecision,
+			New:     newDecision,
+		}
+	})
+	diffs := []DecisionDiff{}
+	for _, result := range results {
+		if result != nil {
+			diffs = append(diffs, *result)
+		}
+	}
+	return diffs, nil
+}
+
+func (r *EvaluationRequest) validate() error {
+	if err := r.Digests.Validate(); err != nil {
+		return err
+	}
+	if r.PackageName == "" {
+		return fmt.Errorf("%w: package name is empty", errs.ErrorInvalidInput)
+	}
+	if r.PolicyID == "" {
+		return fmt.Errorf("%w: policy ID is empty", errs.ErrorInvalidInput)
+	}
+	return validateScopes(r.Scopes)
+}
+
+func (r *EvaluationRequest) evaluate(policy *Policy, opts AttestationVerificationOption) Decision {
+	result := r.result(policy, opts)
+	if err := result.Error(); err != nil {
+		return Decision{Err: err}
+	}
+	return Decision{
+		Allow:  true,
+		Scopes: result.Scopes(),
+	}
+}
+
+// result evaluates the request. The result fails if the
+// request has scopes and the evaluation allows other scopes.
+func (r *EvaluationRequest) result(policy *Policy, opts AttestationVerificationOption) PolicyEvaluationResult {
+	result := policy.Evaluate(r.Digests, r.PackageName, r.PolicyID, opts)
+	if result.Error() != nil || len(r.Scopes) == 0 {
+		return result
+	}
+	if err := result.VerifyScopes(r.Scopes); err != nil {
+		return PolicyEvaluationResult{
+			err:         err,
+			digests:     r.Digests,
+			packageName: r.PackageName,
+		}
+	}
+	return result
+}
@@ -0,0 +1,205 @@
+package deployment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_DiffPolicies(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	newProject := func(principalURI string, level int) project.Policy {
+		return project.Policy{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(level),
+			},
+			Principal: project.Principal{
+				URI: principalURI,
+			},
+			Packages: []project.Package{
+				{
+					Name: packageName,
+				},
+			},
+		}
+	}
+	// NOTE: policy IDs are policy_id0, policy_id1, etc.
+	oldPolicy := newTestPolicy(t, org, []project.Policy{
+		newProject("principal_uri0", 2),
+		newProject("principal_uri1", 2),
+		newProject("principal_uri2", 3),
+		newProject("principal_uri3", 2),
+	}, time.Now)
+	newPolicy := newTestPolicy(t, org, []project.Policy{
+		newProject("principal_uri0", 3),
+		newProject("other_principal_uri1", 2),
+		newProject("principal_uri2", 2),
+		newProject("principal_uri3", 2),
+	}, time.Now)
+	request := func(policyID string, scopes map[string]string) EvaluationRequest {
+		return EvaluationRequest{
+			Digests:     digests,
+			PackageName: packageName,
+			PolicyID:    policyID,
+			Scopes:      scopes,
+		}
+	}
+	workload := []EvaluationRequest{
+		request("policy_id0", nil),
+		request("policy_id1", nil),
+		request("policy_id1", map[string]string{ScopeKubernetesServiceAccount(): "principal_uri1"}),
+		request("policy_id2", nil),
+		request("policy_id3", nil),
+		request("unknown_policy_id", nil),
+	}
+	type decision struct {
+		Index       int
+		Old, New    bool
+		OldCategory string
+		NewCategory string
+	}
+	tests := []struct {
+		name      string
+		oldPolicy *Policy
+		newPolicy *Policy
+		workload  []EvaluationRequest
+		verifier  AttestationVerifier
+		workers   int
+		decisions []decision
+		expected  error
+	}{
+		{
+			name:      "one worker",
+			oldPolicy: oldPolicy,
+			newPolicy: newPolicy,
+			workload:  workload,
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			workers:   1,
+			decisions: []decision{
+				{Index: 0, Old: true, New: false, NewCategory: "verification"},
+				{Index: 1, Old: true, New: true},
+				{Index: 2, Old: true, New: false, NewCategory: "mismatch"},
+				{Index: 3, Old: false, New: true, OldCategory: "verification"},
+			},
+		},
+		{
+			name:      "default workers",
+			oldPolicy: oldPolicy,
+			newPolicy: newPolicy,
+			workload:  workload,
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			decisions: []decision{
+				{Index: 0, Old: true, New: false, NewCategory: "verification"},
+				{Index: 1, Old: true, New: true},
+				{Index: 2, Old: true, New: false, NewCategory: "mismatch"},
+				{Index: 3, Old: false, New: true, OldCategory: "verification"},
+			},
+		},
+		{
+			name:      "same policy",
+			oldPolicy: oldPolicy,
+			newPolicy: oldPolicy,
+			workload:  workload,
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			decisions: []decision{},
+		},
+		{
+			name:      "nil policy",
+			oldPolicy: oldPolicy,
+			workload:  workload,
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "nil verifier",
+			oldPolicy: oldPolicy,
+			newPolicy: newPolicy,
+			workload:  workload,
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "negative workers",
+			oldPolicy: oldPolicy,
+			newPolicy: newPolicy,
+			workload:  workload,
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			workers:   -1,
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "empty policy ID",
+			oldPolicy: oldPolicy,
+			newPolicy: newPolicy,
+			workload:  []EvaluationRequest{request("", nil)},
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "empty scope value",
+			oldPolicy: oldPolicy,
+			newPolicy: newPolicy,
+			workload:  []EvaluationRequest{request("policy_id0", map[string]string{"key": ""})},
+			verifier:  NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2),
+			expected:  errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			diffs, err := DiffPolicies(tt.oldPolicy, tt.newPolicy, tt.workload, DiffOptions{
+				Verifier: tt.verifier,
+				Workers:  tt.workers,
+			})
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			decisions := []decision{}
+			for i := range diffs {
+				d := &diffs[i]
+				if diff := cmp.Diff(tt.workload[d.Index], d.Request); diff != "" {
+					t.Fatalf("unexpected request (-want +got): \n%s", diff)
+				}
+				decisions = append(decisions, decision{
+					Index:       d.Index,
+					Old:         d.Old.Allow,
+					New:         d.New.Allow,
+					OldCategory: d.Old.Category(),
+					NewCategory: d.New.Category(),
+				})
+			}
+			if diff := cmp.Diff(tt.decisions, decisions); diff != "" {
+				t.Fatalf("unexpected decisions (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
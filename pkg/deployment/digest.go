@@ -0,0 +1,109 @@
+package deployment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+)
+
+// policyDigester records the digest of the policy files
+// as they are read by the policy parser.
+type policyDigester struct {
+	org      hash.Hash
+	projects map[string]hash.Hash
+}
+
+func policyDigesterNew() *policyDigester {
+	return &policyDigester{
+		org:      sha256.New(),
+		projects: make(map[string]hash.Hash),
+	}
+}
+
+// hashingReadCloser hashes the content as it is read.
+type hashingReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (h *hashingReadCloser) Close() error {
+	return h.closer.Close()
+}
+
+// namedHashingReadCloser forwards the name of the file
+// that is hashed, so that errors identify the file.
+type namedHashingReadCloser struct {
+	*hashingReadCloser
+	name string
+}
+
+func (h *namedHashingReadCloser) Name() string {
+	return h.name
+}
+
+func (d *policyDigester) wrapOrg(org io.ReadCloser) io.ReadCloser {
+	if org == nil {
+		return nil
+	}
+	reader := &hashingReadCloser{
+		Reader: io.TeeReader(org, d.org),
+		closer: org,
+	}
+	if named, ok := org.(interface{ Name() string }); ok {
+		return &namedHashingReadCloser{hashingReadCloser: reader, name: named.Name()}
+	}
+	return reader
+}
+
+func (d *policyDigester) wrapProjects(projects iterator.NamedReadCloserIterator) iterator.NamedReadCloserIterator {
+	if projects == nil {
+		return nil
+	}
+	return &hashingIterator{
+		NamedReadCloserIterator: projects,
+		digester:                d,
+	}
+}
+
+// digests returns the digest of the policy. The projects are
+// sorted by ID so that the digest does not depend on the
+// order in which the files are read.
+func (d *policyDigester) digests() intoto.DigestSet {
+	ids := make([]string, 0, len(d.projects))
+	for id := range d.projects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	h := sha256.New()
+	fmt.Fprintf(h, "org:%x\n", d.org.Sum(nil))
+	for _, id := range ids {
+		fmt.Fprintf(h, "project:%q:%x\n", id, d.projects[id].Sum(nil))
+	}
+	return intoto.DigestSet{
+		"sha256": hex.EncodeToString(h.Sum(nil)),
+	}
+}
+
+type hashingIterator struct {
+	iterator.NamedReadCloserIterator
+	digester *policyDigester
+}
+
+func (iter *hashingIterator) Next() (string, io.ReadCloser) {
+	id, reader := iter.NamedReadCloserIterator.Next()
+	if reader == nil {
+		return id, reader
+	}
+	h := sha256.New()
+	iter.digester.projects[id] = h
+	return id, &hashingReadCloser{
+		Reader: io.TeeReader(reader, h),
+		closer: reader,
+	}
+}
@@ -0,0 +1,53 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// WithDSSEVerifier requires the attestation to be a DSSE envelope
+// with at least one signature verified by verifier.
+func WithDSSEVerifier(verifier intoto.SignatureVerifier) VerificationOption {
+	return func(v *Verification) error {
+		if verifier == nil {
+			return fmt.Errorf("%w: signature verifier is nil", errs.ErrorInvalidInput)
+		}
+		if v.dsseVerifier != nil || v.allowUnsignedDSSE {
+			return fmt.Errorf("%w: DSSE verification is set more than once", errs.ErrorInvalidInput)
+		}
+		v.dsseVerifier = verifier
+		return nil
+	}
+}
+
+// AllowUnsignedDSSE accepts a DSSE envelope without
+// verifying its signatures.
+func AllowUnsignedDSSE() VerificationOption {
+	return func(v *Verification) error {
+		if v.dsseVerifier != nil || v.allowUnsignedDSSE {
+			return fmt.Errorf("%w: DSSE verification is set more than once", errs.ErrorInvalidInput)
+		}
+		v.allowUnsignedDSSE = true
+		return nil
+	}
+}
+
+func (v *Verification) verifyEnvelope() error {
+	switch {
+	case v.dsse == nil && v.dsseVerifier != nil:
+		return errs.VerificationErrorNew(errs.CheckSignature, "", "",
+			fmt.Errorf("%w: attestation is not a DSSE envelope", errs.ErrorVerification))
+	case v.dsse == nil, v.allowUnsignedDSSE:
+		return nil
+	case v.dsseVerifier == nil:
+		return errs.VerificationErrorNew(errs.CheckSignature, "", "",
+			fmt.Errorf("%w: no signature verifier for the DSSE envelope, see AllowUnsignedDSSE()",
+				errs.ErrorVerification))
+	}
+	if err := v.dsse.Verify(v.dsseVerifier); err != nil {
+		return errs.VerificationErrorNew(errs.CheckSignature, "", "", err)
+	}
+	return nil
+}
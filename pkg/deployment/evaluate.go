@@ -0,0 +1,55 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// PrincipalEvaluationResult defines the result of the
+// evaluation of a project policy by EvaluateAll().
+type PrincipalEvaluationResult struct {
+	PolicyID string
+	// PrincipalURI is the URI of the principal the package is allowed
+	// to run under. If the evaluation failed, it is the default URI of
+	// the project's principal, which may be empty.
+	PrincipalURI string
+	PolicyEvaluationResult
+}
+
+// Allow returns true if the package is allowed to run under the principal.
+func (r PrincipalEvaluationResult) Allow() bool {
+	return r.Error() == nil
+}
+
+// EvaluateAll evaluates every project policy that defines the package,
+// sorted by policy ID. Package names that are patterns, e.g.
+// "docker.io/org/*", are matched as by Evaluate(). A failed evaluation is reported in its result,
+// so that callers can compare the principals allowed to run the digests.
+// It returns an error only if the inputs are invalid, and no result if
+// no project policy defines the package.
+func (p *Policy) EvaluateAll(digests intoto.DigestSet, policyPackageName string, opts AttestationVerificationOption,
+	evalOpts ...EvaluationOption) ([]PrincipalEvaluationResult, error) {
+	if policyPackageName == "" {
+		return nil, fmt.Errorf("%w: package name is empty", errs.ErrorInvalidInput)
+	}
+	digests, err := digests.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	results := []PrincipalEvaluationResult{}
+	for _, pkg := range p.policy.PackagesMatching(policyPackageName) {
+		result := p.Evaluate(digests, policyPackageName, pkg.PolicyID, opts, evalOpts...)
+		principalURI := pkg.PrincipalURI
+		if result.Error() == nil {
+			principalURI = result.PrincipalURI()
+		}
+		results = append(results, PrincipalEvaluationResult{
+			PolicyID:               pkg.PolicyID,
+			PrincipalURI:           principalURI,
+			PolicyEvaluationResult: result,
+		})
+	}
+	return results, nil
+}
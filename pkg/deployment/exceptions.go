@@ -0,0 +1,75 @@
+package deployment
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// PolicyException describes an exception of a project policy,
+// i.e. a decision for a digest that overrides the publish
+// attestations until it expires.
+type PolicyException struct {
+	PolicyID string `json:"policy_id,omitempty"`
+	// Digest is of the form sha256:<hex>.
+	Digest string `json:"digest"`
+	// Decision is either "allow" or "deny".
+	Decision string    `json:"decision"`
+	Reason   string    `json:"reason"`
+	Expires  time.Time `json:"expires"`
+}
+
+func exceptionNew(policyID string, exception *project.Exception) *PolicyException {
+	return &PolicyException{
+		PolicyID: policyID,
+		Digest:   exception.Digest,
+		Decision: exception.Decision,
+		Reason:   exception.Reason,
+		Expires:  exception.ExpiresAt(),
+	}
+}
+
+// RejectExpiredExceptions fails the policy creation if a project
+// policy contains expired exceptions. By default, expired exceptions
+// are ignored during evaluation and reported in Warnings().
+func RejectExpiredExceptions() PolicyOption {
+	return func(p *Policy) error {
+		return p.rejectExpiredExceptions()
+	}
+}
+
+func (p *Policy) rejectExpiredExceptions() error {
+	p.expiredExceptionsErr = true
+	return nil
+}
+
+func (p *Policy) checkExpiredExceptions() error {
+	now := p.now()
+	var expired []string
+	for _, exception := range p.policy.Exceptions() {
+		if exception.IsExpired(now) {
+			expired = append(expired, fmt.Sprintf("%s: exception for digest (%q) expired at %s",
+				exception.PolicyID, exception.Digest, exception.Expires))
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	if p.expiredExceptionsErr {
+		return fmt.Errorf("%w: expired exceptions (%q)", errs.ErrorInvalidField, expired)
+	}
+	p.warnings = append(p.warnings, expired...)
+	return nil
+}
+
+// Exception returns the exception that allowed the package, if any.
+// No publish attestation was verified in this case.
+func (r PolicyEvaluationResult) Exception() *PolicyException {
+	if r.exception == nil {
+		return nil
+	}
+	e := *r.exception
+	return &e
+}
@@ -55,16 +55,33 @@ func NewAttestationVerifier(digests intoto.DigestSet, packageName, env, publishr
 	return &attestationVerifier{digests: digests, packageName: packageName, publishrID: publishrID, env: env, buildLevel: buildLevel}
 }
 
+// Attestation verifier for an attestation signed by the given
+// certificate identity.
+func NewAttestationVerifierWithIdentity(digests intoto.DigestSet, packageName, env, issuer, subject string, buildLevel int) options.AttestationVerifier {
+	return &attestationVerifier{digests: digests, packageName: packageName, issuer: issuer, subject: subject, env: env, buildLevel: buildLevel}
+}
+
 type attestationVerifier struct {
 	packageName string
 	publishrID  string
+	issuer      string
+	subject     string
 	buildLevel  int
 	env         string
 	digests     intoto.DigestSet
 }
 
-func (v *attestationVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string, env []string, publishrID string, buildLevel int) (*string, error) {
-	if buildLevel <= v.buildLevel && packageName == v.packageName && publishrID == v.publishrID &&
+func (v *attestationVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string, env []string, publishrID string,
+	identity *options.Identity, minBuildLevel int, minAuthorVersion string) (*string, error) {
+	// NOTE: the author version is not recorded, so any version is accepted.
+	if identity != nil {
+		if identity.Issuer != v.issuer || !identity.SubjectRegex.MatchString(v.subject) {
+			return nil, fmt.Errorf("%w: cannot verify package Name (%q) identity (%q, %q)", errs.ErrorVerification,
+				packageName, identity.Issuer, identity.SubjectRegex)
+		}
+		publishrID = v.publishrID
+	}
+	if minBuildLevel <= v.buildLevel && packageName == v.packageName && publishrID == v.publishrID &&
 		MapEq(digests, v.digests) &&
 		((v.env != "" && len(env) > 0 && slices.Contains(env, v.env)) ||
 			(v.env == "" && len(env) == 0)) {
@@ -73,7 +90,7 @@ func (v *attestationVerifier) VerifyPublishAttestation(digests intoto.DigestSet,
 		}
 		return &v.env, nil
 	}
-	return nil, fmt.Errorf("%w: cannot verify package Name (%q) publishr ID (%q) env (%q) buildLevel (%d)", errs.ErrorVerification, packageName, publishrID, env, buildLevel)
+	return nil, fmt.Errorf("%w: cannot verify package Name (%q) publishr ID (%q) env (%q) buildLevel (%d)", errs.ErrorVerification, packageName, publishrID, env, minBuildLevel)
 }
 
 func MapEq(m1, m2 map[string]string) bool {
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// PackageDescription describes a package defined by a project policy.
+type PackageDescription struct {
+	PolicyID      string
+	Name          string
+	Environments  []string
+	RequiredLevel int
+	PrincipalURI  string
+	PublishRoots  []string
+}
+
+// RootDescription describes a publish root of the organization policy.
+type RootDescription struct {
+	ID           string
+	MaxSlsaLevel int
+}
+
+// Packages returns the packages of the project policies,
+// sorted by policy ID and in the order they are defined.
+func (p *Policy) Packages() []PackageDescription {
+	ids := make([]string, 0, len(p.projectPolicies))
+	for id := range p.projectPolicies {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	packages := []PackageDescription{}
+	for _, id := range ids {
+		projectPolicy := p.projectPolicies[id]
+		for i := range projectPolicy.Packages {
+			packages = append(packages, describePackage(id, &projectPolicy, i))
+		}
+	}
+	return packages
+}
+
+// PackageNames returns the sorted names of the
+// packages the project policies define.
+func (p *Policy) PackageNames() []string {
+	var names []string
+	for name := range p.packages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PackagesNamed returns the packages of the project policies
+// named name, sorted by policy ID. Package names that are
+// patterns are not matched against name.
+func (p *Policy) PackagesNamed(name string) []PackageDescription {
+	packages := []PackageDescription{}
+	for _, ref := range p.packages[name] {
+		projectPolicy := p.projectPolicies[ref.policyID]
+		packages = append(packages, describePackage(ref.policyID, &projectPolicy, ref.index))
+	}
+	return packages
+}
+
+// PackagesMatching returns the package each project policy evaluates
+// for name, sorted by policy ID. Unlike PackagesNamed, package names
+// that are patterns are matched against name, as in evaluation.
+func (p *Policy) PackagesMatching(name string) []PackageDescription {
+	ids := make([]string, 0, len(p.projectPolicies))
+	for id := range p.projectPolicies {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	packages := []PackageDescription{}
+	for _, id := range ids {
+		projectPolicy := p.projectPolicies[id]
+		i, err := projectPolicy.PackageIndex(name)
+		if err != nil {
+			continue
+		}
+		packages = append(packages, describePackage(id, &projectPolicy, i))
+	}
+	return packages
+}
+
+// PackageEnvironments returns the environments the project policy
+// policyID configures for the package. It returns errs.ErrorNotFound
+// if the policy does not exist or does not define the package.
+func (p *Policy) PackageEnvironments(packageName, policyID string) ([]string, error) {
+	projectPolicy, exists := p.projectPolicies[policyID]
+	if !exists {
+		return nil, fmt.Errorf("%w: policy id (%q) not present in project policies", errs.ErrorNotFound, policyID)
+	}
+	return projectPolicy.PackageEnvironments(packageName)
+}
+
+func describePackage(id string, projectPolicy *project.Policy, index int) PackageDescription {
+	pkg := &projectPolicy.Packages[index]
+	return PackageDescription{
+		PolicyID:      id,
+		Name:          pkg.Name,
+		Environments:  slices.Clone(pkg.Environment.AnyOf),
+		RequiredLevel: projectPolicy.RequiredLevel(pkg, nil),
+		PrincipalURI:  projectPolicy.Principal.URI,
+		PublishRoots:  slices.Clone(pkg.PublishRoots),
+	}
+}
+
+// Roots returns the publish roots of the organization policy,
+// in the order they are defined.
+func (p *Policy) Roots() []RootDescription {
+	roots := []RootDescription{}
+	for i := range p.orgPolicy.Roots.Publish {
+		root := &p.orgPolicy.Roots.Publish[i]
+		roots = append(roots, RootDescription{
+			ID:           root.Key(),
+			MaxSlsaLevel: *root.Build.MaxSlsaLevel,
+		})
+	}
+	return roots
+}
+
+// ExceptionDescription describes an exception of a project policy.
+type ExceptionDescription struct {
+	PolicyID string
+	project.Exception
+}
+
+// Exceptions returns the exceptions of the project policies,
+// sorted by policy ID and in the order they are defined.
+func (p *Policy) Exceptions() []ExceptionDescription {
+	ids := make([]string, 0, len(p.projectPolicies))
+	for id := range p.projectPolicies {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var exceptions []ExceptionDescription
+	for _, id := range ids {
+		for _, exception := range p.projectPolicies[id].Exceptions {
+			exceptions = append(exceptions, ExceptionDescription{
+				PolicyID:  id,
+				Exception: exception,
+			})
+		}
+	}
+	return exceptions
+}
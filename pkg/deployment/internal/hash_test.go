@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_EffectiveHash(t *testing.T) {
+	t.Parallel()
+	org := `{"format":1,"roots":{"publish":[{"id":"publishr_id","build":{"max_slsa_level":3}}]}}`
+	project1 := `{"format":1,"principal":{"uri":"principal_uri1"},"packages":[{"name":"package_name1"}],"build":{"require_slsa_level":3}}`
+	project2 := `{"format":1,"principal":{"uri":"principal_uri2"},"packages":[{"name":"package_name2"}],"build":{"require_slsa_level":2}}`
+	files := []namedContent{{"id1", project1}, {"id2", project2}}
+	tests := []struct {
+		name  string
+		org   string
+		files []namedContent
+		same  bool
+	}{
+		{
+			name:  "identical policy",
+			org:   org,
+			files: files,
+			same:  true,
+		},
+		{
+			name: "whitespace and key order",
+			org: `{
+				"roots": {"publish": [{"build": {"max_slsa_level": 3}, "id": "publishr_id"}]},
+				"format": 1
+			}`,
+			files: []namedContent{
+				{"id1", `{"build":{"require_slsa_level":3},"packages":[{"name":"package_name1"}],"principal":{"uri":"principal_uri1"},"format":1}`},
+				{"id2", project2},
+			},
+			same: true,
+		},
+		{
+			name:  "file order",
+			org:   org,
+			files: []namedContent{{"id2", project2}, {"id1", project1}},
+			same:  true,
+		},
+		{
+			name: "level change",
+			org:  org,
+			files: []namedContent{
+				{"id1", project1},
+				{"id2", `{"format":1,"principal":{"uri":"principal_uri2"},"packages":[{"name":"package_name2"}],"build":{"require_slsa_level":3}}`},
+			},
+		},
+		{
+			name:  "policy id change",
+			org:   org,
+			files: []namedContent{{"id1", project1}, {"id3", project2}},
+		},
+		{
+			name:  "root level change",
+			org:   `{"format":1,"roots":{"publish":[{"id":"publishr_id","build":{"max_slsa_level":4}}]}}`,
+			files: files,
+		},
+	}
+	expected := effectiveHash(t, org, files)
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hash := effectiveHash(t, tt.org, tt.files)
+			if diff := cmp.Diff(tt.same, hash == expected); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+type namedContent struct {
+	id      string
+	content string
+}
+
+type namedContentIterator struct {
+	files []namedContent
+	index int
+}
+
+func (iter *namedContentIterator) Next() (string, io.ReadCloser) {
+	iter.index++
+	file := iter.files[iter.index]
+	return file.id, io.NopCloser(bytes.NewReader([]byte(file.content)))
+}
+
+func (iter *namedContentIterator) HasNext() bool {
+	return iter.index+1 < len(iter.files)
+}
+
+func (iter *namedContentIterator) Error() error {
+	return nil
+}
+
+func effectiveHash(t *testing.T, org string, files []namedContent) string {
+	orgReader := io.NopCloser(bytes.NewReader([]byte(org)))
+	policy, err := PolicyNew(orgReader, &namedContentIterator{files: files, index: -1}, nil)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	hash, err := policy.EffectiveHash()
+	if err != nil {
+		t.Fatalf("failed to compute hash: %v", err)
+	}
+	return hash
+}
@@ -1,17 +1,77 @@
 package options
 
-import "github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+import (
+	"context"
+	"log/slog"
+	"regexp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/logging"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/retry"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
+)
+
+// Identity is the certificate identity of a trusted root.
+type Identity struct {
+	Issuer       string
+	SubjectRegex *regexp.Regexp
+}
 
 // AttestationVerifier defines an interface to verify attestations.
 type AttestationVerifier interface {
 	// Publish attestations. The string returned contains the value of the environment, if present.
-	VerifyPublishAttestation(digests intoto.DigestSet, packageName string, environment []string, publishrID string, buildLevel int) (*string, error)
+	// Attestations at minBuildLevel or above must be accepted. The identity is set if the root
+	// is identified by its certificate identity, in which case publishrID is the root's name.
+	// If minAuthorVersion is set, the attestations must be created by an author at this version
+	// or above.
+	VerifyPublishAttestation(digests intoto.DigestSet, packageName string, environment []string, publishrID string,
+		identity *Identity, minBuildLevel int, minAuthorVersion string) (*string, error)
 }
 
 // PublishVerification defines the configuration to verify
 // publish attestations.
 type PublishVerification struct {
 	Verifier AttestationVerifier
+	// MinAuthorVersion, if set, is the minimum version of the
+	// author of the publish attestations.
+	MinAuthorVersion string
+	// Logger, if set, receives the records of the evaluation.
+	Logger *slog.Logger
+	// Trace, if set, records the candidates considered
+	// by the evaluation.
+	Trace *trace.Trace
+	// PolicyID is the ID of the evaluated project
+	// policy, recorded in the trace.
+	PolicyID string
+	// Retry, if set, retries the verifier calls that
+	// fail with a transient error.
+	Retry *retry.Policy
+	// Context, if set, interrupts the waits between retries.
+	Context context.Context
+}
+
+// Log returns the logger of the evaluation.
+func (v PublishVerification) Log() *slog.Logger {
+	return logging.OrDiscard(v.Logger)
+}
+
+// Ctx returns the context of the evaluation.
+func (v PublishVerification) Ctx() context.Context {
+	if v.Context == nil {
+		return context.Background()
+	}
+	return v.Context
+}
+
+// Record records a step of the evaluation of the
+// project policy in the trace, if set.
+func (v PublishVerification) Record(step trace.Step, err error) {
+	if v.Trace == nil {
+		return
+	}
+	step.PolicyID = v.PolicyID
+	v.Trace.Record(step, err)
 }
 
 // ValidationPackage defines the structure holding
@@ -34,3 +94,114 @@ type ValidationEnvironment struct {
 type PolicyValidator interface {
 	ValidatePackage(pkg ValidationPackage) error
 }
+
+// ParseOption defines an option to parse policy files.
+type ParseOption func(*Parse)
+
+// Parse defines how policy files are parsed.
+type Parse struct {
+	// AllowUnknownFields accepts fields that are not
+	// defined by the policy format.
+	AllowUnknownFields bool
+	// Logger, if set, receives the records of the parsing.
+	Logger *slog.Logger
+	// Workers, if set, is the maximum number of
+	// policy files parsed concurrently.
+	Workers int
+	// MaxSize, if set, is the maximum size of
+	// a policy file, see limit.DefaultMaxSize.
+	MaxSize int64
+	// MaxPolicies, if set, is the maximum number of
+	// policy files, see limit.DefaultMaxPolicies.
+	MaxPolicies int
+	// MaxPackages, if set, is the maximum number of packages
+	// of a policy file, see limit.DefaultMaxPackages.
+	MaxPackages int
+}
+
+// Log returns the logger of the parsing.
+func (p Parse) Log() *slog.Logger {
+	return logging.OrDiscard(p.Logger)
+}
+
+// Concurrency returns the maximum number of policy files parsed
+// concurrently. It defaults to 1, so that the validator is not
+// called concurrently unless the caller opts in, see WithWorkers().
+func (p Parse) Concurrency() int {
+	if p.Workers > 0 {
+		return p.Workers
+	}
+	return 1
+}
+
+// PolicyLimit returns the maximum number of policy
+// files. It defaults to limit.DefaultMaxPolicies.
+func (p Parse) PolicyLimit() int {
+	if p.MaxPolicies > 0 {
+		return p.MaxPolicies
+	}
+	return limit.DefaultMaxPolicies
+}
+
+// PackageLimit returns the maximum number of packages of
+// a policy file. It defaults to limit.DefaultMaxPackages.
+func (p Parse) PackageLimit() int {
+	if p.MaxPackages > 0 {
+		return p.MaxPackages
+	}
+	return limit.DefaultMaxPackages
+}
+
+// AllowUnknownFields accepts fields that are not
+// defined by the policy format.
+func AllowUnknownFields() ParseOption {
+	return func(p *Parse) {
+		p.AllowUnknownFields = true
+	}
+}
+
+// WithLogger sets the logger of the parsing.
+func WithLogger(logger *slog.Logger) ParseOption {
+	return func(p *Parse) {
+		p.Logger = logger
+	}
+}
+
+// WithWorkers sets the maximum number of
+// policy files parsed concurrently.
+func WithWorkers(workers int) ParseOption {
+	return func(p *Parse) {
+		p.Workers = workers
+	}
+}
+
+// WithMaxSize sets the maximum size of a policy file.
+func WithMaxSize(size int64) ParseOption {
+	return func(p *Parse) {
+		p.MaxSize = size
+	}
+}
+
+// WithMaxPolicies sets the maximum number of policy files.
+func WithMaxPolicies(count int) ParseOption {
+	return func(p *Parse) {
+		p.MaxPolicies = count
+	}
+}
+
+// WithMaxPackages sets the maximum number
+// of packages of a policy file.
+func WithMaxPackages(count int) ParseOption {
+	return func(p *Parse) {
+		p.MaxPackages = count
+	}
+}
+
+// ParseNew creates the parse configuration from the options.
+func ParseNew(opts ...ParseOption) Parse {
+	var p Parse
+	for _, option := range opts {
+		option(&p)
+	}
+	return p
+}
@@ -1,66 +1,179 @@
 package organization
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/semver"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/yaml"
 )
 
-// Root defines a trusted root.
+// Root defines a trusted root. It is identified either by
+// its ID or by its certificate identity, but not both.
+// A root identified by its certificate identity is
+// referenced by its name.
 type Root struct {
-	ID    string `json:"id"`
-	Build Build  `json:"build"`
+	ID                    string                 `json:"id"`
+	Name                  string                 `json:"name,omitempty"`
+	Identity              *Identity              `json:"identity,omitempty"`
+	Build                 Build                  `json:"build"`
+	PrincipalRestrictions *PrincipalRestrictions `json:"principal_restrictions,omitempty"`
+	// Environments, if set, contains the only environments the root
+	// is trusted to attest to. Wildcards are not supported.
+	Environments []string `json:"environments,omitempty"`
 	// TODO: Have a field to indicate which package Names the publishr is allowed to
 	// attest to. This assumes every organization has a central registry to make their
 	// publishs accessible.
 }
 
+// Identity defines the certificate identity of a root
+// for keyless signing, e.g. a reusable workflow.
+type Identity struct {
+	// Issuer is the OIDC issuer of the certificate.
+	Issuer string `json:"issuer"`
+	// SubjectRegex matches the subject alternative name of the certificate.
+	SubjectRegex string `json:"subject_regex"`
+	// subject is SubjectRegex compiled by validate(),
+	// anchored to match the whole subject.
+	subject *regexp.Regexp
+}
+
+// Subject returns the compiled subject regex. It is anchored,
+// so the verifiers may match it against the subject as is.
+func (i *Identity) Subject() *regexp.Regexp {
+	return i.subject
+}
+
 // Build defines the build metadata.
 type Build struct {
 	MaxSlsaLevel *int `json:"max_slsa_level"`
 }
 
+// PrincipalRestrictions defines the principals a root is allowed
+// to authorize. Only one of Allow or Deny may be set.
+type PrincipalRestrictions struct {
+	// Allow contains the URI prefixes of the principals the root may authorize.
+	// Principals not matching any prefix are denied.
+	Allow []string `json:"allow,omitempty"`
+	// Deny contains the URI prefixes of the principals the root must not authorize.
+	// Principals not matching any prefix are allowed.
+	Deny []string `json:"deny,omitempty"`
+}
+
 // Roots defines a set of truted roots.
 type Roots struct {
 	Publish []Root `json:"publish"`
 }
 
+// Defaults defines the default requirements of the project
+// policies. The value of a project policy, if set, takes precedence.
+type Defaults struct {
+	Build DefaultBuild `json:"build"`
+}
+
+// DefaultBuild defines the default build requirements.
+type DefaultBuild struct {
+	// RequireSlsaLevel is the SLSA build level required by
+	// project policies that do not define any level.
+	RequireSlsaLevel *int `json:"require_slsa_level"`
+}
+
+// PublishRequirements defines the requirements on
+// the publish attestations of all packages.
+type PublishRequirements struct {
+	// MinAuthorVersion is the minimum semantic version of the
+	// tool that created the publish attestations.
+	MinAuthorVersion string `json:"min_author_version"`
+}
+
 // Policy defines the policy.
 type Policy struct {
-	Format int   `json:"format"`
-	Roots  Roots `json:"roots"`
+	Format              int                  `json:"format"`
+	Roots               Roots                `json:"roots"`
+	Defaults            *Defaults            `json:"defaults,omitempty"`
+	PublishRequirements *PublishRequirements `json:"publish_requirements,omitempty"`
 }
 
 // FromReader creates a new instance of a Policy from an IO reader.
-func FromReader(reader io.ReadCloser) (*Policy, error) {
+func FromReader(reader io.ReadCloser, parseOpts ...options.ParseOption) (*Policy, error) {
+	parse := options.ParseNew(parseOpts...)
 	// NOTE: see https://yourbasic.org/golang/io-reader-interface-explained.
-	content, err := ioutil.ReadAll(reader)
+	content, err := limit.ReadAll(reader, parse.MaxSize)
 	if err != nil {
-		return nil, fmt.Errorf("[organization] failed to read: %w", err)
+		return nil, fmt.Errorf("[organization] %w", err)
 	}
 	defer reader.Close()
 	var org Policy
-	if err := json.Unmarshal(content, &org); err != nil {
-		return nil, fmt.Errorf("[organization] failed to unmarshal: %w", err)
+	if err := policySchema.Validate(content, parse.AllowUnknownFields); err != nil {
+		return nil, schema.WrapViolations(fmt.Sprintf("[organization] failed to validate (%s)", readerName(reader)), err)
+	}
+	if err := yaml.Unmarshal(content, &org, parse.AllowUnknownFields); err != nil {
+		return nil, fmt.Errorf("[organization] failed to unmarshal (%s): %w", readerName(reader), err)
 	}
 	if err := org.validate(); err != nil {
 		return nil, err
 	}
+	parse.Log().Debug("policy file parsed", "policy_id", readerName(reader), "roots", len(org.Roots.Publish))
 	return &org, nil
 }
 
+// readerName returns the name of the file the policy is read from, if available.
+func readerName(reader io.ReadCloser) string {
+	if named, ok := reader.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return "organization policy"
+}
+
 // validate validates the format of the policy.
 func (p *Policy) validate() error {
-	if err := p.validateFormat(); err != nil {
+	if err := errors.Join(p.validateFormat(), p.validatePublishRoots(), p.validatePublishRequirements()); err != nil {
 		return err
 	}
-	if err := p.validatePublishRoots(); err != nil {
-		return err
+	// NOTE: defaults are validated against valid roots only.
+	return p.validateDefaults()
+}
+
+func (p *Policy) validateDefaults() error {
+	if p.Defaults == nil {
+		return nil
+	}
+	// The default level must be set and must be
+	// satisfiable by the publish roots.
+	pointer := schema.Pointer("defaults", "build", "require_slsa_level")
+	level := p.Defaults.Build.RequireSlsaLevel
+	if level == nil || *level < 0 || *level > 4 {
+		return fmt.Errorf("[organization] %w: %q: defaults' require_slsa_level is invalid. Must satisfy 0 <= slsa_level <= 4",
+			errs.ErrorInvalidField, pointer)
+	}
+	if max := p.MaxBuildSlsaLevel(); *level > max {
+		return fmt.Errorf("[organization] %w: %q: defaults' require_slsa_level (%d) cannot be satisfied by publish roots' max level (%d)",
+			errs.ErrorInvalidField, pointer, *level, max)
+	}
+	return nil
+}
+
+func (p *Policy) validatePublishRequirements() error {
+	if p.PublishRequirements == nil {
+		return nil
+	}
+	pointer := schema.Pointer("publish_requirements", "min_author_version")
+	version := p.PublishRequirements.MinAuthorVersion
+	if version == "" {
+		return fmt.Errorf("[organization] %w: %q: publish_requirements' min_author_version is not defined",
+			errs.ErrorInvalidField, pointer)
+	}
+	if _, err := semver.Parse(version); err != nil {
+		return fmt.Errorf("[organization] %q: publish_requirements' min_author_version: %w", pointer, err)
 	}
 	return nil
 }
@@ -68,7 +181,8 @@ func (p *Policy) validate() error {
 func (p *Policy) validateFormat() error {
 	// Format must be 1.
 	if p.Format != 1 {
-		return fmt.Errorf("[organization] %w: invalid format (%q). Must be 1", errs.ErrorInvalidField, p.Format)
+		return fmt.Errorf("[organization] %w: %q: invalid format (%q). Must be 1", errs.ErrorInvalidField,
+			schema.Pointer("format"), p.Format)
 	}
 	return nil
 }
@@ -76,36 +190,230 @@ func (p *Policy) validateFormat() error {
 func (p *Policy) validatePublishRoots() error {
 	// There must be at least one publish root.
 	if len(p.Roots.Publish) == 0 {
-		return fmt.Errorf("[organization] %w: publish's roots are not defined", errs.ErrorInvalidField)
+		return fmt.Errorf("[organization] %w: %q: publish's roots are not defined", errs.ErrorInvalidField,
+			schema.Pointer("roots", "publish"))
 	}
 	// Each root must have all its fields defined.
 	// Also validate that
-	//  2) the ids do not repeat
+	//  2) the ids and names do not repeat
+	//  3) the identities do not repeat
+	// NOTE: errors are accumulated so that all invalid roots are reported.
 	ids := make(map[string]bool)
+	identities := make(map[Identity]bool)
+	var allErrs []error
 	for i := range p.Roots.Publish {
-		publish := &p.Roots.Publish[i]
+		if err := p.Roots.Publish[i].validate(schema.Pointer("roots", "publish", i), ids, identities); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return errors.Join(allErrs...)
+}
+
+// validate validates the root at pointer, see schema.Pointer().
+func (r *Root) validate(pointer string, ids map[string]bool, identities map[Identity]bool) error {
+	// Exactly one of ID and identity must be defined.
+	if r.ID != "" && r.Identity != nil {
+		return fmt.Errorf("[organization] %w: %q: publish's id (%q) and identity are mutually exclusive",
+			errs.ErrorInvalidField, pointer, r.ID)
+	}
+	if r.Identity != nil {
+		// Name must be defined and non-empty.
+		if r.Name == "" {
+			return fmt.Errorf("[organization] %w: %q: publish's name is empty", errs.ErrorInvalidField,
+				pointer+"/name")
+		}
+		if err := r.Identity.validate(pointer+"/identity", identities); err != nil {
+			return err
+		}
+	} else {
 		// ID must be defined and non-empty.
-		if publish.ID == "" {
-			return fmt.Errorf("[organization] %w: publish's id is empty", errs.ErrorInvalidField)
+		if r.ID == "" {
+			return fmt.Errorf("[organization] %w: %q: publish's id is empty", errs.ErrorInvalidField,
+				pointer+"/id")
+		}
+		// Name is only used by identity-based roots.
+		if r.Name != "" {
+			return fmt.Errorf("[organization] %w: %q: publish's (%q) name (%q) is set without an identity",
+				errs.ErrorInvalidField, pointer+"/name", r.ID, r.Name)
 		}
-		// ID must be unique.
-		if _, exists := ids[publish.ID]; exists {
-			return fmt.Errorf("[organization] %w: publish's name (%q) is defined more than once", errs.ErrorInvalidField, publish.ID)
+	}
+	// ID or name must be unique.
+	key := r.Key()
+	if _, exists := ids[key]; exists {
+		return fmt.Errorf("[organization] %w: %q: publish's name (%q) is defined more than once", errs.ErrorInvalidField,
+			pointer, key)
+	}
+	ids[key] = true
+	// Build Level must be defined.
+	if r.Build.MaxSlsaLevel == nil {
+		return fmt.Errorf("[organization] %w: %q: publish's max_slsa_level is not defined", errs.ErrorInvalidField,
+			pointer+"/build/max_slsa_level")
+	}
+	// Level must be in the corre range.
+	if *r.Build.MaxSlsaLevel < 0 || *r.Build.MaxSlsaLevel > 4 {
+		return fmt.Errorf("[organization] %w: %q: publish's max_slsa_level is invalid (%d). Must satisfy 0 <= slsa_level <= 4",
+			errs.ErrorInvalidField, pointer+"/build/max_slsa_level", *r.Build.MaxSlsaLevel)
+	}
+	// Principal restrictions, if set, must be valid.
+	if err := r.validatePrincipalRestrictions(pointer + "/principal_restrictions"); err != nil {
+		return err
+	}
+	// Environments, if set, must be valid.
+	return r.validateEnvironments(pointer + "/environments")
+}
+
+func (r *Root) validateEnvironments(pointer string) error {
+	if r.Environments != nil && len(r.Environments) == 0 {
+		return fmt.Errorf("[organization] %w: %q: publish's (%q) environments is empty", errs.ErrorInvalidField,
+			pointer, r.Key())
+	}
+	for i, env := range r.Environments {
+		envPointer := fmt.Sprintf("%s/%d", pointer, i)
+		if env == "" {
+			return fmt.Errorf("[organization] %w: %q: publish's (%q) environments has an empty field",
+				errs.ErrorInvalidField, envPointer, r.Key())
 		}
-		ids[publish.ID] = true
-		// Build Level must be defined.
-		if publish.Build.MaxSlsaLevel == nil {
-			return fmt.Errorf("[organization] %w: publish's max_slsa_level is not defined", errs.ErrorInvalidField)
+		if strings.Contains(env, "*") {
+			return fmt.Errorf("[organization] %w: %q: publish's (%q) environment (%q) must not be a wildcard",
+				errs.ErrorInvalidField, envPointer, r.Key(), env)
 		}
-		// Level must be in the corre range.
-		if *publish.Build.MaxSlsaLevel < 0 || *publish.Build.MaxSlsaLevel > 4 {
-			return fmt.Errorf("[organization] %w: publish's max_slsa_level is invalid (%d). Must satisfy 0 <= slsa_level <= 4",
-				errs.ErrorInvalidField, *publish.Build.MaxSlsaLevel)
+		if slices.Contains(r.Environments[:i], env) {
+			return fmt.Errorf("[organization] %w: %q: publish's (%q) environment (%q) is defined more than once",
+				errs.ErrorInvalidField, envPointer, r.Key(), env)
 		}
 	}
 	return nil
 }
 
+func (r *Root) validatePrincipalRestrictions(pointer string) error {
+	if r.PrincipalRestrictions == nil {
+		return nil
+	}
+	allow, deny := r.PrincipalRestrictions.Allow, r.PrincipalRestrictions.Deny
+	// Allow and deny lists must not be mixed.
+	if len(allow) > 0 && len(deny) > 0 {
+		return fmt.Errorf("[organization] %w: %q: publish's (%q) principal_restrictions has both allow and deny set",
+			errs.ErrorInvalidField, pointer, r.Key())
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return fmt.Errorf("[organization] %w: %q: publish's (%q) principal_restrictions is empty",
+			errs.ErrorInvalidField, pointer, r.Key())
+	}
+	// Prefixes must be non-empty.
+	name, prefixes := "allow", allow
+	if len(deny) > 0 {
+		name, prefixes = "deny", deny
+	}
+	for i, prefix := range prefixes {
+		if prefix == "" {
+			return fmt.Errorf("[organization] %w: %q: publish's (%q) principal_restrictions has an empty prefix",
+				errs.ErrorInvalidField, fmt.Sprintf("%s/%s/%d", pointer, name, i), r.Key())
+		}
+	}
+	return nil
+}
+
+// validate validates the identity at pointer, see schema.Pointer().
+func (i *Identity) validate(pointer string, identities map[Identity]bool) error {
+	// Issuer and subject must be defined and non-empty.
+	if i.Issuer == "" {
+		return fmt.Errorf("[organization] %w: %q: publish's identity issuer is empty", errs.ErrorInvalidField,
+			pointer+"/issuer")
+	}
+	if i.SubjectRegex == "" {
+		return fmt.Errorf("[organization] %w: %q: publish's identity subject_regex is empty", errs.ErrorInvalidField,
+			pointer+"/subject_regex")
+	}
+	// Identity must be unique.
+	key := Identity{Issuer: i.Issuer, SubjectRegex: i.SubjectRegex}
+	if _, exists := identities[key]; exists {
+		return fmt.Errorf("[organization] %w: %q: publish's identity (%q, %q) is defined more than once",
+			errs.ErrorInvalidField, pointer, i.Issuer, i.SubjectRegex)
+	}
+	identities[key] = true
+	// Subject must be a valid regex. It must match the whole subject,
+	// even if the policy omits the anchors.
+	subject, err := regexp.Compile("^(?:" + i.SubjectRegex + ")$")
+	if err != nil {
+		return fmt.Errorf("[organization] %w: %q: publish's identity subject_regex (%q) is invalid: %v",
+			errs.ErrorInvalidField, pointer+"/subject_regex", i.SubjectRegex, err)
+	}
+	i.subject = subject
+	return nil
+}
+
+// Key returns the identifier projects reference the root by:
+// its ID, or its name if it is identified by its certificate identity.
+func (r *Root) Key() string {
+	if r.Identity != nil {
+		return r.Name
+	}
+	return r.ID
+}
+
+// CanAuthorize returns true if the root is allowed to authorize the principal.
+func (r *Root) CanAuthorize(principalURI string) bool {
+	if r.PrincipalRestrictions == nil {
+		return true
+	}
+	if len(r.PrincipalRestrictions.Allow) > 0 {
+		return hasPrefix(principalURI, r.PrincipalRestrictions.Allow)
+	}
+	return !hasPrefix(principalURI, r.PrincipalRestrictions.Deny)
+}
+
+// HasEnvironmentRestrictions returns true if the root is only
+// trusted to attest to some environments.
+func (r *Root) HasEnvironmentRestrictions() bool {
+	return len(r.Environments) > 0
+}
+
+// AllowsEnvironment returns true if the root is trusted to attest to
+// the environment. A nil environment is only allowed by roots without
+// environment restrictions.
+func (r *Root) AllowsEnvironment(env *string) bool {
+	if !r.HasEnvironmentRestrictions() {
+		return true
+	}
+	return env != nil && slices.Contains(r.Environments, *env)
+}
+
+func hasPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishRootIDs returns the keys of the publish roots, see Root.Key().
+func (p *Policy) PublishRootIDs() []string {
+	ids := make([]string, 0, len(p.Roots.Publish))
+	for i := range p.Roots.Publish {
+		ids = append(ids, p.Roots.Publish[i].Key())
+	}
+	return ids
+}
+
+// DefaultSlsaLevel returns the SLSA build level required by project
+// policies that do not define any level, or nil.
+func (p *Policy) DefaultSlsaLevel() *int {
+	if p.Defaults == nil {
+		return nil
+	}
+	return p.Defaults.Build.RequireSlsaLevel
+}
+
+// MinAuthorVersion returns the minimum version of the tool that
+// created the publish attestations, or an empty string.
+func (p *Policy) MinAuthorVersion() string {
+	if p.PublishRequirements == nil {
+		return ""
+	}
+	return p.PublishRequirements.MinAuthorVersion
+}
+
 func (p *Policy) MaxBuildSlsaLevel() int {
 	max := -1
 	for i := range p.Roots.Publish {
@@ -1,9 +1,10 @@
 package organization
 
 import (
-	// "bytes"
-	// "encoding/json"
-	// "io"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -139,9 +140,10 @@ func Test_validatePublishRoots(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name     string
-		policy   *Policy
-		expected error
+		name       string
+		policy     *Policy
+		violations int
+		expected   error
 	}{
 		{
 			name:     "empty roots",
@@ -244,6 +246,201 @@ func Test_validatePublishRoots(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "root with allowed principals",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &PrincipalRestrictions{
+								Allow: []string{"principal1", "principal2"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "root with denied principals",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &PrincipalRestrictions{
+								Deny: []string{"principal1"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "root with allowed and denied principals",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &PrincipalRestrictions{
+								Allow: []string{"principal1"},
+								Deny:  []string{"principal2"},
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with empty principal restrictions",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &PrincipalRestrictions{},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with empty allowed prefix",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &PrincipalRestrictions{
+								Allow: []string{"principal1", ""},
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with empty denied prefix",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &PrincipalRestrictions{
+								Deny: []string{""},
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with environments",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							Environments: []string{"dev", "staging"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "root with empty environments",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							Environments: []string{},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with empty environment",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							Environments: []string{"dev", ""},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with wildcard environment",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							Environments: []string{"prod/*"},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "root with duplicate environment",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "publishr id",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							Environments: []string{"dev", "dev"},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
 		{
 			name: "two roots with same id",
 			policy: &Policy{
@@ -266,6 +463,203 @@ func Test_validatePublishRoots(t *testing.T) {
 			},
 			expected: errs.ErrorInvalidField,
 		},
+		{
+			name: "one root with valid identity",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "one root with id and identity",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID:   "publishr id",
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with identity and empty name",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with id and name",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID:   "publishr id",
+							Name: "the name",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with identity empty issuer",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with identity invalid subject regex",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^(invalid$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "two roots with same identity",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+						{
+							Name: "the name2",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "two roots with name same as id",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							ID: "the name",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$",
+							},
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "multiple invalid roots",
+			policy: &Policy{
+				Roots: Roots{
+					Publish: []Root{
+						{
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+						{
+							ID: "publishr id",
+						},
+						{
+							ID: "publishr id2",
+							Build: Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+					},
+				},
+			},
+			violations: 2,
+			expected:   errs.ErrorInvalidField,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -276,6 +670,268 @@ func Test_validatePublishRoots(t *testing.T) {
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if tt.violations == 0 {
+				return
+			}
+			if diff := cmp.Diff(tt.violations, len(errs.Violations(err))); diff != "" {
+				t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_validateDefaults(t *testing.T) {
+	t.Parallel()
+
+	roots := Roots{
+		Publish: []Root{
+			{
+				ID: "publishr id",
+				Build: Build{
+					MaxSlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		policy   *Policy
+		expected error
+	}{
+		{
+			name: "no defaults",
+			policy: &Policy{
+				Roots: roots,
+			},
+		},
+		{
+			name: "default level",
+			policy: &Policy{
+				Roots: roots,
+				Defaults: &Defaults{
+					Build: DefaultBuild{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name: "no default level",
+			policy: &Policy{
+				Roots:    roots,
+				Defaults: &Defaults{},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "negative default level",
+			policy: &Policy{
+				Roots: roots,
+				Defaults: &Defaults{
+					Build: DefaultBuild{
+						RequireSlsaLevel: common.AsPointer(-1),
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "default level above roots",
+			policy: &Policy{
+				Roots: roots,
+				Defaults: &Defaults{
+					Build: DefaultBuild{
+						RequireSlsaLevel: common.AsPointer(4),
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.policy.validateDefaults()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_validatePublishRequirements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		version  string
+		expected error
+	}{
+		{
+			name:   "no requirements",
+			policy: &Policy{},
+		},
+		{
+			name: "min author version",
+			policy: &Policy{
+				PublishRequirements: &PublishRequirements{
+					MinAuthorVersion: "v1.2.3",
+				},
+			},
+			version: "v1.2.3",
+		},
+		{
+			name: "empty min author version",
+			policy: &Policy{
+				PublishRequirements: &PublishRequirements{},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid min author version",
+			policy: &Policy{
+				PublishRequirements: &PublishRequirements{
+					MinAuthorVersion: "latest",
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.policy.validatePublishRequirements()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.version, tt.policy.MinAuthorVersion()); diff != "" {
+				t.Fatalf("unexpected version (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CanAuthorize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		root         Root
+		principalURI string
+		expected     bool
+	}{
+		{
+			name:         "unrestricted",
+			principalURI: "principal_uri",
+			expected:     true,
+		},
+		{
+			name: "allowed prefix",
+			root: Root{
+				PrincipalRestrictions: &PrincipalRestrictions{
+					Allow: []string{"other", "principal_"},
+				},
+			},
+			principalURI: "principal_uri",
+			expected:     true,
+		},
+		{
+			name: "not allowed prefix",
+			root: Root{
+				PrincipalRestrictions: &PrincipalRestrictions{
+					Allow: []string{"other", "principal_uri2"},
+				},
+			},
+			principalURI: "principal_uri",
+		},
+		{
+			name: "denied prefix",
+			root: Root{
+				PrincipalRestrictions: &PrincipalRestrictions{
+					Deny: []string{"other", "principal_"},
+				},
+			},
+			principalURI: "principal_uri",
+		},
+		{
+			name: "not denied prefix",
+			root: Root{
+				PrincipalRestrictions: &PrincipalRestrictions{
+					Deny: []string{"other", "principal_uri2"},
+				},
+			},
+			principalURI: "principal_uri",
+			expected:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			allowed := tt.root.CanAuthorize(tt.principalURI)
+			if diff := cmp.Diff(tt.expected, allowed); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_AllowsEnvironment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		root     Root
+		env      *string
+		expected bool
+	}{
+		{
+			name:     "unrestricted",
+			env:      common.AsPointer("prod"),
+			expected: true,
+		},
+		{
+			name:     "unrestricted nil environment",
+			expected: true,
+		},
+		{
+			name: "allowed environment",
+			root: Root{
+				Environments: []string{"dev", "staging"},
+			},
+			env:      common.AsPointer("staging"),
+			expected: true,
+		},
+		{
+			name: "not allowed environment",
+			root: Root{
+				Environments: []string{"dev", "staging"},
+			},
+			env: common.AsPointer("prod"),
+		},
+		{
+			name: "restricted nil environment",
+			root: Root{
+				Environments: []string{"dev"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			allowed := tt.root.AllowsEnvironment(tt.env)
+			if diff := cmp.Diff(tt.expected, allowed); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
@@ -304,3 +960,78 @@ func Test_Evaluate(t *testing.T) {
 		})
 	}
 }
+
+func FuzzFromReader(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		content, err := json.Marshal(randomPolicy(r))
+		if err != nil {
+			f.Fatalf("failed to marshal: %v", err)
+		}
+		f.Add(content)
+	}
+	f.Add([]byte("format: 1\nroots:\n  publish:\n    - id: root_id\n      build:\n        max_slsa_level: 3\n"))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		policy, err := FromReader(io.NopCloser(bytes.NewReader(content)))
+		if err != nil {
+			return
+		}
+		_ = policy.PublishRootIDs()
+		_ = policy.DefaultSlsaLevel()
+		_ = policy.MinAuthorVersion()
+		_ = policy.MaxBuildSlsaLevel()
+	})
+}
+
+func Test_IdentitySubject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		regex    string
+		subject  string
+		expected bool
+	}{
+		{
+			name:     "match",
+			regex:    "https://github.com/org/repo/.*@refs/heads/main",
+			subject:  "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+			expected: true,
+		},
+		{
+			name:     "anchored match",
+			regex:    "^https://github.com/org/repo/.*@refs/heads/main$",
+			subject:  "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+			expected: true,
+		},
+		{
+			name:    "prefix",
+			regex:   "https://github.com/org/repo/.github/workflows/release.yml",
+			subject: "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+		},
+		{
+			name:    "suffix",
+			regex:   "https://github.com/org/repo/.*",
+			subject: "https://evil.com/https://github.com/org/repo/release.yml",
+		},
+		{
+			name:    "alternation",
+			regex:   "https://github.com/org/repo/.*|https://github.com/org/other/.*",
+			subject: "https://evil.com/https://github.com/org/other/release.yml",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			identity := Identity{Issuer: "https://token.actions.githubusercontent.com", SubjectRegex: tt.regex}
+			if err := identity.validate("/identity", make(map[Identity]bool)); err != nil {
+				t.Fatalf("failed to validate: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, identity.Subject().MatchString(tt.subject)); diff != "" {
+				t.Fatalf("unexpected match (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
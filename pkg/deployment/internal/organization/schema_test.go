@@ -0,0 +1,81 @@
+package organization
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+)
+
+// randomPolicy returns a random valid policy.
+func randomPolicy(r *rand.Rand) Policy {
+	policy := Policy{Format: 1}
+	n := 1 + r.Intn(4)
+	maxLevel := 0
+	for i := 0; i < n; i++ {
+		root := Root{
+			Build: Build{
+				MaxSlsaLevel: common.AsPointer(r.Intn(5)),
+			},
+		}
+		maxLevel = max(maxLevel, *root.Build.MaxSlsaLevel)
+		if r.Intn(2) == 0 {
+			root.ID = fmt.Sprintf("publish_id%d", i)
+		} else {
+			root.Name = fmt.Sprintf("publish_name%d", i)
+			root.Identity = &Identity{
+				Issuer:       "https://token.actions.githubusercontent.com",
+				SubjectRegex: fmt.Sprintf("^https://github.com/org/repo%d/", i),
+			}
+		}
+		switch r.Intn(3) {
+		case 0:
+			root.PrincipalRestrictions = &PrincipalRestrictions{
+				Allow: []string{"k8s://org/"},
+			}
+		case 1:
+			root.PrincipalRestrictions = &PrincipalRestrictions{
+				Deny: []string{"k8s://org/test/", "k8s://org/dev/"},
+			}
+		}
+		policy.Roots.Publish = append(policy.Roots.Publish, root)
+	}
+	if r.Intn(2) == 0 {
+		policy.Defaults = &Defaults{
+			Build: DefaultBuild{
+				RequireSlsaLevel: common.AsPointer(r.Intn(maxLevel + 1)),
+			},
+		}
+	}
+	if r.Intn(2) == 0 {
+		policy.PublishRequirements = &PublishRequirements{
+			MinAuthorVersion: fmt.Sprintf("v1.%d.0", r.Intn(10)),
+		}
+	}
+	return policy
+}
+
+func Test_SchemaAgreement(t *testing.T) {
+	t.Parallel()
+	if _, err := schema.Compile(Schema()); err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		content, err := json.Marshal(randomPolicy(r))
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if err := policySchema.Validate(content, false); err != nil {
+			t.Fatalf("schema rejects policy (%s): %v", content, err)
+		}
+		if _, err := FromReader(io.NopCloser(bytes.NewReader(content))); err != nil {
+			t.Fatalf("failed to create policy (%s): %v", content, err)
+		}
+	}
+}
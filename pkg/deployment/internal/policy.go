@@ -3,6 +3,8 @@ package internal
 import (
 	"fmt"
 	"io"
+	"slices"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
@@ -10,29 +12,60 @@ import (
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 type Policy struct {
 	orgPolicy       organization.Policy
 	projectPolicies map[string]project.Policy
+	// packages maps a package name to the project
+	// policies that define it, sorted by policy ID.
+	packages map[string][]packageRef
 }
 
-func PolicyNew(org io.ReadCloser, projects iterator.NamedReadCloserIterator, validator options.PolicyValidator) (*Policy, error) {
-	orgPolicy, err := organization.FromReader(org)
+// packageRef references a package of a project policy.
+type packageRef struct {
+	policyID string
+	// index is the index of the package in the policy's Packages.
+	index int
+}
+
+// indexPackages indexes the packages of the project policies by name.
+func indexPackages(projectPolicies map[string]project.Policy) map[string][]packageRef {
+	ids := make([]string, 0, len(projectPolicies))
+	for id := range projectPolicies {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	packages := make(map[string][]packageRef)
+	for _, id := range ids {
+		projectPolicy := projectPolicies[id]
+		for i := range projectPolicy.Packages {
+			name := projectPolicy.Packages[i].Name
+			packages[name] = append(packages[name], packageRef{policyID: id, index: i})
+		}
+	}
+	return packages
+}
+
+func PolicyNew(org io.ReadCloser, projects iterator.NamedReadCloserIterator, validator options.PolicyValidator, parseOpts ...options.ParseOption) (*Policy, error) {
+	orgPolicy, err := organization.FromReader(org, parseOpts...)
 	if err != nil {
 		return nil, err
 	}
-	projectPolicies, err := project.FromReaders(projects, *orgPolicy, validator)
+	projectPolicies, err := project.FromReaders(projects, *orgPolicy, validator, parseOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return &Policy{
 		orgPolicy:       *orgPolicy,
 		projectPolicies: projectPolicies,
+		packages:        indexPackages(projectPolicies),
 	}, nil
 }
 
-func (p *Policy) Evaluate(digests intoto.DigestSet, packageName, policyID string, publishOpts options.PublishVerification) (*project.Protection, error) {
+func (p *Policy) Evaluate(digests intoto.DigestSet, packageName, policyID string, publishOpts options.PublishVerification,
+	now time.Time) (*project.Result, error) {
 	if packageName == "" {
 		return nil, fmt.Errorf("%w: package name is empty", errs.ErrorInvalidInput)
 	}
@@ -45,19 +78,54 @@ func (p *Policy) Evaluate(digests intoto.DigestSet, packageName, policyID string
 	// Get the project policy for the artifact.
 	projectPolicy, exists := p.projectPolicies[policyID]
 	if !exists {
-		return nil, fmt.Errorf("%w: policy id (%q) not present in project policies", errs.ErrorNotFound, policyID)
+		err := fmt.Errorf("%w: policy id (%q) not present in project policies", errs.ErrorNotFound, policyID)
+		publishOpts.Trace.Record(trace.Step{PolicyID: policyID, Package: packageName,
+			Outcome: trace.OutcomeRejected, Reason: trace.ReasonPolicyNotFound}, err)
+		return nil, err
 	}
+	publishOpts.Log().Debug("project policy selected", "policy_id", policyID)
+	publishOpts.PolicyID = policyID
 
 	// Evaluate the org policy.
+	publishOpts.MinAuthorVersion = p.orgPolicy.MinAuthorVersion()
 	err := p.orgPolicy.Evaluate(digests, packageName, publishOpts)
 	if err != nil {
 		return nil, err
 	}
 
 	// Evaluate the project policy.
-	protection, err := projectPolicy.Evaluate(digests, packageName, p.orgPolicy, publishOpts)
+	result, err := projectPolicy.Evaluate(digests, packageName, p.orgPolicy, publishOpts, now)
 	if err != nil {
 		return nil, err
 	}
-	return protection, nil
+	return result, nil
+}
+
+// CustomRules returns the custom rules of the
+// project policies that define them, by policy ID.
+func (p *Policy) CustomRules() map[string]project.CustomRules {
+	rules := make(map[string]project.CustomRules)
+	for id, projectPolicy := range p.projectPolicies {
+		if projectPolicy.CustomRules != nil {
+			rules[id] = *projectPolicy.CustomRules
+		}
+	}
+	return rules
+}
+
+// AcceptedDigestAlgorithms returns the sorted digest algorithms
+// accepted by at least one package of the project policies.
+func (p *Policy) AcceptedDigestAlgorithms() []string {
+	var algorithms []string
+	for _, projectPolicy := range p.projectPolicies {
+		for i := range projectPolicy.Packages {
+			for _, alg := range projectPolicy.Packages[i].AcceptedDigestAlgorithms {
+				if !slices.Contains(algorithms, alg) {
+					algorithms = append(algorithms, alg)
+				}
+			}
+		}
+	}
+	slices.Sort(algorithms)
+	return algorithms
 }
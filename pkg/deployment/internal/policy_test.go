@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -51,8 +52,8 @@ func Test_PolicyNew(t *testing.T) {
 			BuildRequirements: project.BuildRequirements{
 				RequireSlsaLevel: common.AsPointer(2),
 			},
-			Protection: project.Protection{
-				ServiceAccount: serviceAccount1,
+			Principal: project.Principal{
+				URI: serviceAccount1,
 			},
 			Packages: []project.Package{
 				{
@@ -71,8 +72,8 @@ func Test_PolicyNew(t *testing.T) {
 		},
 		{
 			Format: 1,
-			Protection: project.Protection{
-				ServiceAccount: serviceAccount2,
+			Principal: project.Principal{
+				URI: serviceAccount2,
 			},
 			BuildRequirements: project.BuildRequirements{
 				RequireSlsaLevel: common.AsPointer(3),
@@ -140,8 +141,8 @@ func Test_PolicyNew(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -160,8 +161,8 @@ func Test_PolicyNew(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -193,8 +194,8 @@ func Test_PolicyNew(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -213,8 +214,8 @@ func Test_PolicyNew(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -232,8 +233,8 @@ func Test_PolicyNew(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -252,8 +253,8 @@ func Test_PolicyNew(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -271,8 +272,8 @@ func Test_PolicyNew(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -291,8 +292,8 @@ func Test_PolicyNew(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -317,8 +318,8 @@ func Test_PolicyNew(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -337,8 +338,8 @@ func Test_PolicyNew(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -355,7 +356,7 @@ func Test_PolicyNew(t *testing.T) {
 			},
 		},
 		{
-			name:     "project empty Protection",
+			name:     "project empty principal",
 			expected: errs.ErrorInvalidField,
 			org:      org,
 			projects: []project.Policy{
@@ -364,8 +365,8 @@ func Test_PolicyNew(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -586,8 +587,8 @@ func Test_Evaluate(t *testing.T) {
 			BuildRequirements: project.BuildRequirements{
 				RequireSlsaLevel: common.AsPointer(2),
 			},
-			Protection: project.Protection{
-				ServiceAccount: serviceAccount1,
+			Principal: project.Principal{
+				URI: serviceAccount1,
 			},
 			Packages: []project.Package{
 				{
@@ -606,8 +607,8 @@ func Test_Evaluate(t *testing.T) {
 		},
 		{
 			Format: 1,
-			Protection: project.Protection{
-				ServiceAccount: serviceAccount2,
+			Principal: project.Principal{
+				URI: serviceAccount2,
 			},
 			BuildRequirements: project.BuildRequirements{
 				RequireSlsaLevel: common.AsPointer(3),
@@ -733,8 +734,8 @@ func Test_Evaluate(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -753,8 +754,8 @@ func Test_Evaluate(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -802,8 +803,8 @@ func Test_Evaluate(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -822,8 +823,8 @@ func Test_Evaluate(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -876,8 +877,8 @@ func Test_Evaluate(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -896,8 +897,8 @@ func Test_Evaluate(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(1),
@@ -951,8 +952,8 @@ func Test_Evaluate(t *testing.T) {
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(2),
 					},
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount1,
+					Principal: project.Principal{
+						URI: serviceAccount1,
 					},
 					Packages: []project.Package{
 						{
@@ -971,8 +972,8 @@ func Test_Evaluate(t *testing.T) {
 				},
 				{
 					Format: 1,
-					Protection: project.Protection{
-						ServiceAccount: serviceAccount2,
+					Principal: project.Principal{
+						URI: serviceAccount2,
 					},
 					BuildRequirements: project.BuildRequirements{
 						RequireSlsaLevel: common.AsPointer(3),
@@ -1030,7 +1031,7 @@ func Test_Evaluate(t *testing.T) {
 			opts := options.PublishVerification{
 				Verifier: verifier,
 			}
-			Protection, err := policy.Evaluate(tt.digests, tt.packageName, tt.policyID, opts)
+			result, err := policy.Evaluate(tt.digests, tt.packageName, tt.policyID, opts, time.Now())
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
@@ -1040,7 +1041,7 @@ func Test_Evaluate(t *testing.T) {
 			if len(tt.projects) < 2 {
 				t.Fatalf("internal error. number of projects: %d", len(tt.projects))
 			}
-			if diff := cmp.Diff(tt.projects[1].Protection, *Protection, cmpopts.EquateErrors()); diff != "" {
+			if diff := cmp.Diff(tt.projects[1].Principal, result.Principal, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 		})
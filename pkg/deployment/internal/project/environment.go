@@ -0,0 +1,96 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Environments are hierarchical, with segments separated by '/',
+// e.g. "prod/us-east1". An environment entry may be a wildcard,
+// where a segment ends with '*', e.g. "prod/*" or "prod/us-*". A
+// wildcard matches environments with the same number of segments.
+// An exact entry takes precedence over a wildcard.
+
+func isEnvironmentPattern(env string) bool {
+	return strings.Contains(env, "*")
+}
+
+// validateEnvironment validates the environment
+// of the field at pointer, see schema.Pointer().
+func validateEnvironment(pointer, env string) error {
+	if env == "" {
+		return fmt.Errorf("[project] %w: %q: environment is empty", errs.ErrorInvalidField, pointer)
+	}
+	for _, segment := range strings.Split(env, "/") {
+		if segment == "" {
+			return fmt.Errorf("[project] %w: %q: environment (%q) has an empty segment", errs.ErrorInvalidField,
+				pointer, env)
+		}
+		if i := strings.Index(segment, "*"); i >= 0 && i != len(segment)-1 {
+			return fmt.Errorf("[project] %w: %q: environment (%q) may only contain '*' at the end of a segment",
+				errs.ErrorInvalidField, pointer, env)
+		}
+	}
+	return nil
+}
+
+func matchEnvironment(pattern, env string) bool {
+	if !isEnvironmentPattern(pattern) {
+		return pattern == env
+	}
+	// NOTE: validateEnvironment() ensures the pattern is valid.
+	matched, _ := path.Match(pattern, env)
+	return matched
+}
+
+// matchEnvironments returns the entry of envs that matches env.
+// Exact entries take precedence over wildcards.
+func matchEnvironments(envs []string, env string) (string, bool) {
+	if isEnvironmentPattern(env) {
+		return "", false
+	}
+	if slices.Contains(envs, env) {
+		return env, true
+	}
+	for _, pattern := range envs {
+		if matchEnvironment(pattern, env) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// candidates expands the wildcards of envs with the environments
+// the principal defines. The result contains the exact entries
+// first, then each wildcard follows the environments it expands to.
+func (p *Principal) candidates(envs []string) []string {
+	var exact, expanded []string
+	for _, env := range envs {
+		if !isEnvironmentPattern(env) {
+			exact = append(exact, env)
+		}
+	}
+	known := make([]string, 0, len(p.Environments))
+	for env := range p.Environments {
+		known = append(known, env)
+	}
+	slices.Sort(known)
+	for _, pattern := range envs {
+		if !isEnvironmentPattern(pattern) {
+			continue
+		}
+		for _, env := range known {
+			if matchEnvironment(pattern, env) && !slices.Contains(exact, env) && !slices.Contains(expanded, env) {
+				expanded = append(expanded, env)
+			}
+		}
+		// NOTE: the wildcard itself lets the verifier match
+		// environments the principal does not define.
+		expanded = append(expanded, pattern)
+	}
+	return append(exact, expanded...)
+}
@@ -0,0 +1,201 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_validateEnvironment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		env      string
+		expected error
+	}{
+		{
+			name: "exact",
+			env:  "prod/us-east1",
+		},
+		{
+			name: "wildcard segment",
+			env:  "prod/*",
+		},
+		{
+			name: "wildcard prefix",
+			env:  "prod/us-*",
+		},
+		{
+			name: "wildcard in each segment",
+			env:  "*/us-*",
+		},
+		{
+			name:     "empty",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty segment",
+			env:      "prod//us-east1",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "trailing separator",
+			env:      "prod/",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "leading wildcard",
+			env:      "prod/*-east1",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "multiple wildcards",
+			env:      "prod/**",
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateEnvironment("/env", tt.env)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_matchEnvironments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		envs   []string
+		env    string
+		entry  string
+		result bool
+	}{
+		{
+			name:   "exact",
+			envs:   []string{"dev", "prod/us-east1"},
+			env:    "prod/us-east1",
+			entry:  "prod/us-east1",
+			result: true,
+		},
+		{
+			name:   "wildcard",
+			envs:   []string{"dev", "prod/*"},
+			env:    "prod/eu-west4",
+			entry:  "prod/*",
+			result: true,
+		},
+		{
+			name:   "wildcard prefix",
+			envs:   []string{"prod/us-*"},
+			env:    "prod/us-east1",
+			entry:  "prod/us-*",
+			result: true,
+		},
+		{
+			name:   "exact before wildcard",
+			envs:   []string{"prod/*", "prod/us-east1"},
+			env:    "prod/us-east1",
+			entry:  "prod/us-east1",
+			result: true,
+		},
+		{
+			name: "wildcard other prefix",
+			envs: []string{"prod/us-*"},
+			env:  "prod/eu-west4",
+		},
+		{
+			name: "wildcard more segments",
+			envs: []string{"prod/*"},
+			env:  "prod/us-east1/zone-a",
+		},
+		{
+			name: "wildcard fewer segments",
+			envs: []string{"prod/*"},
+			env:  "prod",
+		},
+		{
+			name: "wildcard env",
+			envs: []string{"prod/*"},
+			env:  "prod/*",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			entry, result := matchEnvironments(tt.envs, tt.env)
+			if diff := cmp.Diff(tt.result, result); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.entry, entry); diff != "" {
+				t.Fatalf("unexpected entry (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_candidates(t *testing.T) {
+	t.Parallel()
+
+	principal := Principal{
+		URI: "the_sa",
+		Environments: map[string]string{
+			"prod/us-east1":    "the_us_sa",
+			"prod/eu-west4":    "the_eu_sa",
+			"staging/us-east1": "the_staging_sa",
+		},
+	}
+	tests := []struct {
+		name     string
+		envs     []string
+		expected []string
+	}{
+		{
+			name: "no environment",
+		},
+		{
+			name:     "exact",
+			envs:     []string{"dev", "prod/us-east1"},
+			expected: []string{"dev", "prod/us-east1"},
+		},
+		{
+			name:     "wildcard",
+			envs:     []string{"prod/*"},
+			expected: []string{"prod/eu-west4", "prod/us-east1", "prod/*"},
+		},
+		{
+			name:     "exact first",
+			envs:     []string{"prod/*", "prod/us-east1"},
+			expected: []string{"prod/us-east1", "prod/eu-west4", "prod/*"},
+		},
+		{
+			name:     "overlapping wildcards",
+			envs:     []string{"*/us-*", "prod/*"},
+			expected: []string{"prod/us-east1", "staging/us-east1", "*/us-*", "prod/eu-west4", "prod/*"},
+		},
+		{
+			name:     "wildcard without principal environment",
+			envs:     []string{"dev/*"},
+			expected: []string{"dev/*"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			candidates := principal.candidates(tt.envs)
+			if diff := cmp.Diff(tt.expected, candidates); diff != "" {
+				t.Fatalf("unexpected candidates (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+)
+
+// Decisions of an exception.
+const (
+	ExceptionAllow = "allow"
+	ExceptionDeny  = "deny"
+)
+
+var sha256Regex = regexp.MustCompile("^[a-f0-9]{64}$")
+
+// Exception overrides the decision for a digest until it expires,
+// e.g. during incident response.
+type Exception struct {
+	// Digest is of the form sha256:<hex>.
+	Digest   string `json:"digest"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	// Expires is an RFC3339 time.
+	Expires string `json:"expires"`
+	// expires is the parsed value of Expires.
+	expires time.Time
+}
+
+// ExpiresAt returns the time the exception expires at.
+func (e *Exception) ExpiresAt() time.Time {
+	return e.expires
+}
+
+// IsExpired returns true if the exception has expired at now.
+func (e *Exception) IsExpired(now time.Time) bool {
+	return !now.Before(e.expires)
+}
+
+// sha256 returns the hex value of the digest.
+func (e *Exception) sha256() string {
+	return strings.TrimPrefix(e.Digest, "sha256:")
+}
+
+func (p *Policy) validateExceptions() error {
+	digests := make(map[string]bool, len(p.Exceptions))
+	for i := range p.Exceptions {
+		exception := &p.Exceptions[i]
+		alg, value, _ := strings.Cut(exception.Digest, ":")
+		if alg != "sha256" || !sha256Regex.MatchString(value) {
+			return fmt.Errorf("[project] %w: %q: exception's digest (%q) must be of the form sha256:<hex>",
+				errs.ErrorInvalidField, schema.Pointer("exceptions", i, "digest"), exception.Digest)
+		}
+		if _, exists := digests[value]; exists {
+			return fmt.Errorf("[project] %w: %q: exception's digest (%q) is present multiple times",
+				errs.ErrorInvalidField, schema.Pointer("exceptions", i, "digest"), exception.Digest)
+		}
+		digests[value] = true
+		switch exception.Decision {
+		default:
+			return fmt.Errorf("[project] %w: %q: exception's decision (%q) must be (%q) or (%q)",
+				errs.ErrorInvalidField, schema.Pointer("exceptions", i, "decision"), exception.Decision,
+				ExceptionAllow, ExceptionDeny)
+		case ExceptionDeny, ExceptionAllow:
+			// NOTE: an allowed package resolves the principal of its
+			// environments, which validatePrincipalEnvironments checks.
+		}
+		if exception.Reason == "" {
+			return fmt.Errorf("[project] %w: %q: exception's reason for digest (%q) is empty",
+				errs.ErrorInvalidField, schema.Pointer("exceptions", i, "reason"), exception.Digest)
+		}
+		expires, err := time.Parse(time.RFC3339, exception.Expires)
+		if err != nil {
+			return fmt.Errorf("[project] %w: %q: exception's expiry (%q) for digest (%q) is not an RFC3339 time",
+				errs.ErrorInvalidField, schema.Pointer("exceptions", i, "expires"), exception.Expires, exception.Digest)
+		}
+		exception.expires = expires
+	}
+	return nil
+}
+
+// exception returns the non-expired exception for the digests, if any.
+func (p *Policy) exception(digests intoto.DigestSet, now time.Time) *Exception {
+	value, exists := digests["sha256"]
+	if !exists {
+		return nil
+	}
+	for i := range p.Exceptions {
+		exception := &p.Exceptions[i]
+		if exception.sha256() == value && !exception.IsExpired(now) {
+			return exception
+		}
+	}
+	return nil
+}
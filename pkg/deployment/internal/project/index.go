@@ -0,0 +1,29 @@
+package project
+
+// packageIndex indexes the packages of a policy by name, so that
+// a package is found without scanning all of them. It stores
+// the indices of the packages in Policy.Packages.
+type packageIndex struct {
+	// names maps a package name to its index.
+	names map[string]int
+	// patterns contains the indices of the packages whose name
+	// is a pattern, in the order they are defined.
+	patterns []int
+}
+
+func newPackageIndex(packages []Package) *packageIndex {
+	index := &packageIndex{
+		names: make(map[string]int, len(packages)),
+	}
+	for i := range packages {
+		name := packages[i].Name
+		// NOTE: validatePackages() rejects duplicate names.
+		if _, exists := index.names[name]; !exists {
+			index.names[name] = i
+		}
+		if isPattern(name) {
+			index.patterns = append(index.patterns, i)
+		}
+	}
+	return index
+}
@@ -0,0 +1,94 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Package names may be glob patterns: '*' matches any sequence
+// of characters and '?' matches a single character, neither of
+// them matching the path separator '/'.
+
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*?")
+}
+
+// validatePattern validates the package name
+// of the field at pointer, see schema.Pointer().
+func validatePattern(pointer, name string) error {
+	if strings.ContainsAny(name, `[]\`) {
+		return fmt.Errorf("[project] %w: %q: package's name (%q) contains unsupported characters. Only '*' and '?' are supported",
+			errs.ErrorInvalidField, pointer, name)
+	}
+	if _, err := path.Match(name, ""); err != nil {
+		return fmt.Errorf("[project] %w: %q: package's name (%q) is not a valid pattern: %v", errs.ErrorInvalidField,
+			pointer, name, err)
+	}
+	return nil
+}
+
+func matchName(pattern, name string) bool {
+	if !isPattern(pattern) {
+		return pattern == name
+	}
+	// NOTE: validatePattern() ensures the pattern is valid.
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// patternsOverlap returns true if at least one name matches both patterns.
+func patternsOverlap(p, q string) bool {
+	type state struct{ i, j int }
+	visited := make(map[state]bool)
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		s := state{i, j}
+		if visited[s] {
+			return false
+		}
+		visited[s] = true
+		if i == len(p) && j == len(q) {
+			return true
+		}
+		// A '*' may match the empty string.
+		if i < len(p) && p[i] == '*' && overlap(i+1, j) {
+			return true
+		}
+		if j < len(q) && q[j] == '*' && overlap(i, j+1) {
+			return true
+		}
+		if i == len(p) || j == len(q) {
+			return false
+		}
+		// Both patterns consume the same character.
+		if !charsOverlap(p[i], q[j]) {
+			return false
+		}
+		ni, nj := i+1, j+1
+		if p[i] == '*' {
+			ni = i
+		}
+		if q[j] == '*' {
+			nj = j
+		}
+		return overlap(ni, nj)
+	}
+	return overlap(0, 0)
+}
+
+func charsOverlap(a, b byte) bool {
+	aWild := a == '*' || a == '?'
+	bWild := b == '*' || b == '?'
+	switch {
+	case aWild && bWild:
+		return true
+	case aWild:
+		return b != '/'
+	case bWild:
+		return a != '/'
+	}
+	return a == b
+}
@@ -0,0 +1,75 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_patternsOverlap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		p, q     string
+		expected bool
+	}{
+		{
+			name:     "same pattern",
+			p:        "registry/repo/*",
+			q:        "registry/repo/*",
+			expected: true,
+		},
+		{
+			name: "different prefixes",
+			p:    "registry/repo1/*",
+			q:    "registry/repo2/*",
+		},
+		{
+			name:     "different segments",
+			p:        "registry/repo/*",
+			q:        "registry/*/service",
+			expected: true,
+		},
+		{
+			name: "different number of segments",
+			p:    "registry/*",
+			q:    "registry/repo/*",
+		},
+		{
+			name:     "prefix and suffix",
+			p:        "registry/repo/front*",
+			q:        "registry/repo/*end",
+			expected: true,
+		},
+		{
+			name:     "single character",
+			p:        "registry/repo/service?",
+			q:        "registry/repo/*1",
+			expected: true,
+		},
+		{
+			name: "single character too short",
+			p:    "registry/repo/a?",
+			q:    "registry/repo/b*",
+		},
+		{
+			name: "star does not match separator",
+			p:    "registry/*",
+			q:    "registry/repo/service",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, patternsOverlap(tt.p, tt.q)); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// The relation is symmetric.
+			if diff := cmp.Diff(tt.expected, patternsOverlap(tt.q, tt.p)); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
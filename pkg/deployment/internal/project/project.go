@@ -1,26 +1,47 @@
 package project
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"maps"
 	"slices"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/assertions"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/extends"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/parallel"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/yaml"
 )
 
 // BuildRequirements defines the build requirements.
 type BuildRequirements struct {
 	RequireSlsaLevel *int `json:"require_slsa_level"`
+	// Environments, if set, maps an environment to the SLSA build
+	// level required to deploy to it. It may only raise RequireSlsaLevel.
+	Environments map[string]int `json:"environments,omitempty"`
+}
+
+// PackageBuildRequirements defines the build requirements
+// of a package, which may only raise the project's.
+type PackageBuildRequirements struct {
+	RequireSlsaLevel *int `json:"require_slsa_level"`
 }
 
 // Environment defines the target environment.
 type Environment struct {
+	// AnyOf contains the accepted environments, which
+	// may be wildcards such as "prod/*".
 	AnyOf []string `json:"any_of"`
 }
 
@@ -29,55 +50,197 @@ type Environment struct {
 type Package struct {
 	Name        string      `json:"name"`
 	Environment Environment `json:"environment"`
+	// PublishRoots, if set, contains the IDs of the only
+	// publish roots allowed to attest to the package.
+	PublishRoots []string `json:"publish_roots,omitempty"`
+	// AcceptedDigestAlgorithms, if set, contains the only digest
+	// algorithms allowed to identify the package.
+	AcceptedDigestAlgorithms []string `json:"accepted_digest_algorithms,omitempty"`
+	// BuildRequirements, if set, overrides the project's build requirements.
+	BuildRequirements *PackageBuildRequirements `json:"build,omitempty"`
 }
 
-type Protection struct {
-	GoogleServiceAccount string `json:"google_service_account"`
+// ScopeKubernetesServiceAccount is the scope key
+// the URI of the principal is recorded under.
+const ScopeKubernetesServiceAccount = "kubernetes.io/pod/service_account/v1"
+
+// Principal defines the principal the packages are
+// allowed to run under.
+type Principal struct {
+	// URI is the default principal, used for environments
+	// not present in Environments.
+	URI string `json:"uri"`
+	// Environments maps an environment to the URI of the
+	// principal allowed to run in it.
+	Environments PrincipalEnvironments `json:"environments,omitempty"`
+	// Scopes contains additional scopes of the principal,
+	// e.g. the GCP service account and region of a Cloud Run
+	// service. They apply to all the environments.
+	Scopes map[string]string `json:"scopes,omitempty"`
+}
+
+// PrincipalEnvironments maps an environment to the URI of a principal.
+type PrincipalEnvironments map[string]string
+
+// UnmarshalJSON rejects environments defined more than once,
+// which would otherwise silently select the last principal.
+func (e *PrincipalEnvironments) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		*e = nil
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("%w: principal's environments must be an object", errs.ErrorInvalidField)
+	}
+	envs := make(PrincipalEnvironments)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		// NOTE: object keys are always strings.
+		env := token.(string)
+		var uri string
+		if err := decoder.Decode(&uri); err != nil {
+			return fmt.Errorf("%w: principal's URI for environment (%q): %w", errs.ErrorInvalidField, env, err)
+		}
+		if _, exists := envs[env]; exists {
+			return fmt.Errorf("%w: principal's environment (%q) is defined more than once", errs.ErrorInvalidField, env)
+		}
+		envs[env] = uri
+	}
+	*e = envs
+	return nil
+}
+
+// CustomRules references a module of custom conditions, e.g. a Rego
+// module, either embedded in the policy or stored in a file.
+type CustomRules struct {
+	Module string `json:"module,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// Result defines the result of a successful evaluation.
+type Result struct {
+	Principal Principal
+	// Environment is the environment verified
+	// by the publish attestation, if any.
+	Environment *string
+	// PublishRootID is the ID of the publish root
+	// that authorized the decision.
+	PublishRootID string
+	// BuildLevel is the minimum SLSA build level
+	// verified by the publish root.
+	BuildLevel int
+	// DigestAlgorithms contains the digest algorithms that
+	// satisfied the package's accepted digest algorithms, if set.
+	DigestAlgorithms []string
+	// Digests contains the digests of the accepted algorithms,
+	// i.e. those the verifier verified.
+	Digests intoto.DigestSet
+	// Exception is set if an exception allowed the package.
+	// No publish attestation is verified in this case.
+	Exception *Exception
+	// AllowedEnvironments contains the environments the package's
+	// policy allows, as configured. It is nil if none is configured.
+	AllowedEnvironments []string
 }
 
 // Policy defines the policy.
 type Policy struct {
 	Format            int                     `json:"format"`
-	Protection        Protection              `json:"protection"`
+	Principal         Principal               `json:"principal"`
 	Packages          []Package               `json:"packages"`
 	BuildRequirements BuildRequirements       `json:"build"`
+	Assertions        []assertions.Assertion  `json:"assertions,omitempty"`
+	Exceptions        []Exception             `json:"exceptions,omitempty"`
+	CustomRules       *CustomRules            `json:"custom_rules,omitempty"`
 	validator         options.PolicyValidator `json:"-"`
+	index             *packageIndex           `json:"-"`
 }
 
 // PolicyOption defines a policy option.
 type PolicyOption func(*Policy) error
 
-func fromReader(reader io.ReadCloser, maxBuildLevel int, validator options.PolicyValidator) (*Policy, error) {
+// readPolicy reads the content of a policy file.
+func readPolicy(reader io.ReadCloser, parse options.Parse) ([]byte, error) {
 	// NOTE: see https://yourbasic.org/golang/io-reader-interface-explained.
-	content, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("[project] failed to read: %w", err)
-	}
 	defer reader.Close()
+	return limit.ReadAll(reader, parse.MaxSize)
+}
+
+// fromContent creates a policy from the content of a policy
+// file, with the files it extends merged in, see extends.Resolve.
+func fromContent(content []byte, maxBuildLevel int, defaultLevel *int, validator options.PolicyValidator,
+	parse options.Parse) (*Policy, error) {
 	var project Policy
-	if err := json.Unmarshal(content, &project); err != nil {
+	if err := policySchema.Validate(content, parse.AllowUnknownFields); err != nil {
+		return nil, schema.WrapViolations("[project] failed to validate", err)
+	}
+	if err := yaml.Unmarshal(content, &project, parse.AllowUnknownFields); err != nil {
 		return nil, fmt.Errorf("[project] failed to unmarshal: %w", err)
 	}
+	// NOTE: the packages are validated pairwise, so their number is bounded.
+	if len(project.Packages) > parse.PackageLimit() {
+		return nil, fmt.Errorf("[project] %w: (%d) packages, more than the maximum number of packages per policy (%d)",
+			errs.ErrorInvalidInput, len(project.Packages), parse.PackageLimit())
+	}
 	project.validator = validator
+	project.applyDefaults(defaultLevel)
 	if err := project.validate(maxBuildLevel); err != nil {
 		return nil, err
 	}
+	project.index = newPackageIndex(project.Packages)
 	return &project, nil
 }
 
+// applyDefaults sets the level to the default level of the
+// organization policy, if the policy does not define any level.
+func (p *Policy) applyDefaults(defaultLevel *int) {
+	if p.BuildRequirements.RequireSlsaLevel == nil && defaultLevel != nil {
+		level := *defaultLevel
+		p.BuildRequirements.RequireSlsaLevel = &level
+	}
+}
+
 // validate validates the format of the policy.
+// All independent checks are run so that every violation is reported.
 func (p *Policy) validate(maxBuildLevel int) error {
-	if err := p.validateFormat(); err != nil {
+	if err := errors.Join(p.validateFormat(), p.validatePrincipal(), p.validatePackages(),
+		p.validateBuildRequirements(maxBuildLevel), p.validateExceptions(), p.validateCustomRules()); err != nil {
 		return err
 	}
-	if err := p.validateProtection(); err != nil {
+	// NOTE: environments are cross-checked on a valid principal and valid packages.
+	if err := p.validatePrincipalEnvironments(); err != nil {
 		return err
 	}
-	if err := p.validatePackages(); err != nil {
+	// NOTE: assertions must be evaluated last, on the validated policy.
+	if err := p.validateAssertions(); err != nil {
 		return err
 	}
-	if err := p.validateBuildRequirements(maxBuildLevel); err != nil {
-		return err
+	return nil
+}
+
+func (p *Policy) validateAssertions() error {
+	if err := assertions.Evaluate(p.Assertions, p); err != nil {
+		return fmt.Errorf("[project] %w", err)
+	}
+	return nil
+}
+
+func (p *Policy) validateCustomRules() error {
+	if p.CustomRules == nil {
+		return nil
+	}
+	// Exactly one of the module and the path must be set.
+	if (p.CustomRules.Module == "") == (p.CustomRules.Path == "") {
+		return fmt.Errorf("[project] %w: %q: custom_rules must set exactly one of module and path", errs.ErrorInvalidField,
+			schema.Pointer("custom_rules"))
 	}
 	return nil
 }
@@ -85,38 +248,219 @@ func (p *Policy) validate(maxBuildLevel int) error {
 func (p *Policy) validateFormat() error {
 	// Format must be 1.
 	if p.Format != 1 {
-		return fmt.Errorf("[project] %w: invalid format (%q). Must be 1", errs.ErrorInvalidField, p.Format)
+		return fmt.Errorf("[project] %w: %q: invalid format (%q). Must be 1", errs.ErrorInvalidField,
+			schema.Pointer("format"), p.Format)
 	}
 	return nil
 }
 
-func (p *Policy) validateProtection() error {
-	if p.Protection.GoogleServiceAccount == "" {
-		return fmt.Errorf("[project] %w: empty protection service_account", errs.ErrorInvalidField)
+func (p *Policy) validatePrincipal() error {
+	if p.Principal.URI == "" && len(p.Principal.Environments) == 0 {
+		return fmt.Errorf("[project] %w: %q: empty principal URI", errs.ErrorInvalidField,
+			schema.Pointer("principal", "uri"))
+	}
+	for env, uri := range p.Principal.Environments {
+		pointer := schema.Pointer("principal", "environments", env)
+		if env == "" {
+			return fmt.Errorf("[project] %w: %q: principal's environment is empty", errs.ErrorInvalidField, pointer)
+		}
+		if uri == "" {
+			return fmt.Errorf("[project] %w: %q: principal's URI for environment (%q) is empty", errs.ErrorInvalidField,
+				pointer, env)
+		}
+		if isEnvironmentPattern(env) {
+			return fmt.Errorf("[project] %w: %q: principal's environment (%q) must not be a wildcard", errs.ErrorInvalidField,
+				pointer, env)
+		}
+		if err := validateEnvironment(pointer, env); err != nil {
+			return err
+		}
+	}
+	for key, value := range p.Principal.Scopes {
+		pointer := schema.Pointer("principal", "scopes", key)
+		if key == "" {
+			return fmt.Errorf("[project] %w: %q: principal's scope key is empty", errs.ErrorInvalidField, pointer)
+		}
+		if value == "" {
+			return fmt.Errorf("[project] %w: %q: principal's value for scope (%q) is empty", errs.ErrorInvalidField,
+				pointer, key)
+		}
+		// NOTE: the URI is recorded under this scope.
+		if key == ScopeKubernetesServiceAccount {
+			return fmt.Errorf("[project] %w: %q: principal's scope (%q) must be set with the uri field",
+				errs.ErrorInvalidField, pointer, key)
+		}
+	}
+	return nil
+}
+
+// validatePrincipalEnvironments validates that every package
+// environment resolves to a principal.
+func (p *Policy) validatePrincipalEnvironments() error {
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		if len(pkg.Environment.AnyOf) == 0 {
+			if p.Principal.URI == "" {
+				return fmt.Errorf("[project] %w: %q: package (%q) has no environment and principal has no default URI",
+					errs.ErrorInvalidField, schema.Pointer("packages", i, "environment"), pkg.Name)
+			}
+			continue
+		}
+		for j, env := range pkg.Environment.AnyOf {
+			// A wildcard resolves to the default principal or
+			// to the environments it expands to.
+			if isEnvironmentPattern(env) && len(p.Principal.uris([]string{env})) > 0 {
+				continue
+			}
+			if _, err := p.Principal.resolve(&env); err != nil {
+				return fmt.Errorf("[project] %w: %q: package (%q) environment (%q) has no principal",
+					errs.ErrorInvalidField, schema.Pointer("packages", i, "environment", "any_of", j), pkg.Name, env)
+			}
+		}
 	}
 	return nil
 }
 
+// resolve returns the principal for an environment: the principal
+// of the environment, else the default one. It returns
+// errs.ErrorNotFound if there is none.
+func (p *Principal) resolve(env *string) (*Principal, error) {
+	if env != nil {
+		if uri, exists := p.Environments[*env]; exists {
+			return &Principal{URI: uri, Scopes: p.Scopes}, nil
+		}
+	}
+	if p.URI == "" {
+		var e string
+		if env != nil {
+			e = *env
+		}
+		return nil, fmt.Errorf("[project] %w: no principal for environment (%q)", errs.ErrorNotFound, e)
+	}
+	return &Principal{URI: p.URI, Scopes: p.Scopes}, nil
+}
+
+// resolveAny returns the principal for the first of the environments
+// that has one, in the order of candidates. With no environment, it
+// returns the default principal. No publish attestation verifies an
+// environment when an exception applies, so this is the best match.
+func (p *Principal) resolveAny(envs []string) (*Principal, error) {
+	if len(envs) == 0 {
+		return p.resolve(nil)
+	}
+	candidates := p.candidates(envs)
+	for i := range candidates {
+		if principal, err := p.resolve(&candidates[i]); err == nil {
+			return principal, nil
+		}
+	}
+	return nil, fmt.Errorf("[project] %w: no principal for environments (%q)", errs.ErrorNotFound, envs)
+}
+
+// AllScopes returns the scopes of a resolved principal,
+// including the URI.
+func (p *Principal) AllScopes() map[string]string {
+	scopes := maps.Clone(p.Scopes)
+	if scopes == nil {
+		scopes = make(map[string]string, 1)
+	}
+	scopes[ScopeKubernetesServiceAccount] = p.URI
+	return scopes
+}
+
+// uris returns the unique URIs the principal may resolve to
+// for the environments.
+func (p *Principal) uris(envs []string) []string {
+	if len(envs) == 0 {
+		return []string{p.URI}
+	}
+	var uris []string
+	candidates := p.candidates(envs)
+	for i := range candidates {
+		principal, err := p.resolve(&candidates[i])
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(uris, principal.URI) {
+			uris = append(uris, principal.URI)
+		}
+	}
+	return uris
+}
+
+// allURIs returns all the unique URIs defined by the principal.
+func (p *Principal) allURIs() []string {
+	var uris []string
+	if p.URI != "" {
+		uris = append(uris, p.URI)
+	}
+	for _, uri := range p.Environments {
+		if !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
 func (p *Policy) validatePackages() error {
 	if len(p.Packages) == 0 {
-		return fmt.Errorf("[project] %w: no packages", errs.ErrorInvalidField)
+		return fmt.Errorf("[project] %w: %q: no packages", errs.ErrorInvalidField, schema.Pointer("packages"))
 	}
 	packages := make(map[string]bool, len(p.Packages))
 	for i := range p.Packages {
 		pkg := &p.Packages[i]
+		namePointer := schema.Pointer("packages", i, "name")
 		// Package must have a non-empty Name.
 		if pkg.Name == "" {
-			return fmt.Errorf("[project] %w: package's name is empty", errs.ErrorInvalidField)
+			return fmt.Errorf("[project] %w: %q: package's name is empty", errs.ErrorInvalidField, namePointer)
 		}
 		if _, exists := packages[pkg.Name]; exists {
-			return fmt.Errorf("[project] %w: package's name (%q) is present multiple times", errs.ErrorInvalidField, pkg.Name)
+			return fmt.Errorf("[project] %w: %q: package's name (%q) is present multiple times", errs.ErrorInvalidField,
+				namePointer, pkg.Name)
 		}
 		packages[pkg.Name] = true
-		// Environment field, if set, must contain non-empty values.
-		for i := range pkg.Environment.AnyOf {
-			val := &pkg.Environment.AnyOf[i]
-			if *val == "" {
-				return fmt.Errorf("[project] %w: package's any_of value has an empty field", errs.ErrorInvalidField)
+		// Patterns must not overlap, so that a name matches at most one of them.
+		// NOTE: exact names take precedence over patterns.
+		if isPattern(pkg.Name) {
+			if err := validatePattern(namePointer, pkg.Name); err != nil {
+				return err
+			}
+			for j := 0; j < i; j++ {
+				other := &p.Packages[j]
+				if isPattern(other.Name) && patternsOverlap(pkg.Name, other.Name) {
+					return fmt.Errorf("[project] %w: %q: package's names (%q) and (%q) overlap", errs.ErrorInvalidField,
+						namePointer, other.Name, pkg.Name)
+				}
+			}
+		}
+		// Environment field, if set, must contain valid values.
+		for j, env := range pkg.Environment.AnyOf {
+			if err := validateEnvironment(schema.Pointer("packages", i, "environment", "any_of", j), env); err != nil {
+				return err
+			}
+		}
+		// Publish roots field, if set, must contain non-empty values.
+		for j, id := range pkg.PublishRoots {
+			if id == "" {
+				return fmt.Errorf("[project] %w: %q: package's publish_roots has an empty field", errs.ErrorInvalidField,
+					schema.Pointer("packages", i, "publish_roots", j))
+			}
+		}
+		// Accepted digest algorithms, if set, must contain unique non-empty values.
+		// NOTE: unknown algorithm names are checked by the caller.
+		if pkg.AcceptedDigestAlgorithms != nil && len(pkg.AcceptedDigestAlgorithms) == 0 {
+			return fmt.Errorf("[project] %w: %q: package's accepted_digest_algorithms is empty", errs.ErrorInvalidField,
+				schema.Pointer("packages", i, "accepted_digest_algorithms"))
+		}
+		for j, alg := range pkg.AcceptedDigestAlgorithms {
+			pointer := schema.Pointer("packages", i, "accepted_digest_algorithms", j)
+			if alg == "" {
+				return fmt.Errorf("[project] %w: %q: package's accepted_digest_algorithms has an empty field",
+					errs.ErrorInvalidField, pointer)
+			}
+			if slices.Contains(pkg.AcceptedDigestAlgorithms[:j], alg) {
+				return fmt.Errorf("[project] %w: %q: package's accepted_digest_algorithms contains (%q) more than once",
+					errs.ErrorInvalidField, pointer, alg)
 			}
 		}
 		// TODO: validate the packages are defined in a non-overlapping way.
@@ -130,7 +474,8 @@ func (p *Policy) validatePackages() error {
 				},
 			}
 			if err := p.validator.ValidatePackage(pkg); err != nil {
-				return fmt.Errorf("%w: failed to validate package: %w", errs.ErrorInvalidField, err)
+				return fmt.Errorf("%w: %q: failed to validate package: %w", errs.ErrorInvalidField,
+					schema.Pointer("packages", i), err)
 			}
 		}
 	}
@@ -138,6 +483,78 @@ func (p *Policy) validatePackages() error {
 	return nil
 }
 
+// validatePublishRoots validates that the packages only
+// reference publish roots defined in the org policy.
+func (p *Policy) validatePublishRoots(rootIDs []string) error {
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		for j, id := range pkg.PublishRoots {
+			if !slices.Contains(rootIDs, id) {
+				return fmt.Errorf("[project] %w: %q: package (%q) has unknown publish root (%q). Must be one of %q",
+					errs.ErrorInvalidField, schema.Pointer("packages", i, "publish_roots", j), pkg.Name, id, rootIDs)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRootEnvironments validates that each environment of the
+// packages may be attested to by at least one of the publish roots
+// the package allows. Packages without environments require a root
+// without environment restrictions.
+func (p *Policy) validateRootEnvironments(roots []organization.Root) error {
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		var allowed []*organization.Root
+		for j := range roots {
+			if pkg.allowsRoot(roots[j].Key()) {
+				allowed = append(allowed, &roots[j])
+			}
+		}
+		if len(pkg.Environment.AnyOf) == 0 {
+			if !slices.ContainsFunc(allowed, func(root *organization.Root) bool { return !root.HasEnvironmentRestrictions() }) {
+				return fmt.Errorf("[project] %w: %q: package (%q) has no environment and no publish root is trusted for it",
+					errs.ErrorInvalidField, schema.Pointer("packages", i, "environment"), pkg.Name)
+			}
+			continue
+		}
+		for j, env := range pkg.Environment.AnyOf {
+			if !slices.ContainsFunc(allowed, func(root *organization.Root) bool {
+				return len(rootEnvironments(root, []string{env})) > 0
+			}) {
+				return fmt.Errorf("[project] %w: %q: package (%q) environment (%q) has no trusted publish root",
+					errs.ErrorInvalidField, schema.Pointer("packages", i, "environment", "any_of", j), pkg.Name, env)
+			}
+		}
+	}
+	return nil
+}
+
+// rootEnvironments returns the environments of envs the root is
+// trusted to attest to. Wildcards are replaced by the environments
+// of the root they match. Roots without environment restrictions
+// are trusted for all of envs.
+func rootEnvironments(root *organization.Root, envs []string) []string {
+	if !root.HasEnvironmentRestrictions() {
+		return envs
+	}
+	var trusted []string
+	for _, env := range envs {
+		for _, rootEnv := range root.Environments {
+			if matchEnvironment(env, rootEnv) && !slices.Contains(trusted, rootEnv) {
+				trusted = append(trusted, rootEnv)
+			}
+		}
+	}
+	return trusted
+}
+
+// allowsRoot returns true if the package may be attested to by the root.
+// Packages without publish roots allow any root.
+func (pkg *Package) allowsRoot(rootID string) bool {
+	return len(pkg.PublishRoots) == 0 || slices.Contains(pkg.PublishRoots, rootID)
+}
+
 func (p *Policy) validateBuildRequirements(maxBuildLevel int) error {
 	// SLSA publishr
 	//	1) must be set
@@ -149,49 +566,271 @@ func (p *Policy) validateBuildRequirements(maxBuildLevel int) error {
 	if p.BuildRequirements.RequireSlsaLevel == nil ||
 		*p.BuildRequirements.RequireSlsaLevel < 0 ||
 		*p.BuildRequirements.RequireSlsaLevel > 4 {
-		return fmt.Errorf("[project] %w: build's require_slsa_level is invalid. Must satisfy 0 <= slsa_level <= 4", errs.ErrorInvalidField)
+		return fmt.Errorf("[project] %w: %q: build's require_slsa_level is invalid. Must satisfy 0 <= slsa_level <= 4",
+			errs.ErrorInvalidField, schema.Pointer("build", "require_slsa_level"))
 	}
 	if *p.BuildRequirements.RequireSlsaLevel > maxBuildLevel {
-		return fmt.Errorf("[project] %w: build's level (%d) cannot be satisfied by org policy's max level (%d)",
-			errs.ErrorInvalidField, *p.BuildRequirements.RequireSlsaLevel, maxBuildLevel)
+		return fmt.Errorf("[project] %w: %q: build's level (%d) cannot be satisfied by org policy's max level (%d)",
+			errs.ErrorInvalidField, schema.Pointer("build", "require_slsa_level"), *p.BuildRequirements.RequireSlsaLevel,
+			maxBuildLevel)
+	}
+	// Overrides must be satisfiable and must not lower the project's level.
+	level := *p.BuildRequirements.RequireSlsaLevel
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		if pkg.BuildRequirements == nil {
+			continue
+		}
+		pointer := schema.Pointer("packages", i, "build", "require_slsa_level")
+		if pkg.BuildRequirements.RequireSlsaLevel == nil {
+			return fmt.Errorf("[project] %w: %q: package (%q) build's require_slsa_level is not set",
+				errs.ErrorInvalidField, pointer, pkg.Name)
+		}
+		if err := validateLevelOverride(pointer, fmt.Sprintf("package (%q)", pkg.Name),
+			*pkg.BuildRequirements.RequireSlsaLevel, level, maxBuildLevel); err != nil {
+			return err
+		}
+	}
+	envs := make([]string, 0, len(p.BuildRequirements.Environments))
+	for env := range p.BuildRequirements.Environments {
+		envs = append(envs, env)
+	}
+	slices.Sort(envs)
+	for _, env := range envs {
+		pointer := schema.Pointer("build", "environments", env)
+		if err := validateEnvironment(pointer, env); err != nil {
+			return err
+		}
+		if isEnvironmentPattern(env) {
+			return fmt.Errorf("[project] %w: %q: build's environment (%q) must not be a wildcard", errs.ErrorInvalidField,
+				pointer, env)
+		}
+		if !slices.ContainsFunc(p.Packages, func(pkg Package) bool {
+			_, ok := matchEnvironments(pkg.Environment.AnyOf, env)
+			return ok
+		}) {
+			return fmt.Errorf("[project] %w: %q: build's environment (%q) is not defined by any package",
+				errs.ErrorInvalidField, pointer, env)
+		}
+		if err := validateLevelOverride(pointer, fmt.Sprintf("environment (%q)", env),
+			p.BuildRequirements.Environments[env], level, maxBuildLevel); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// FromReaders creates a set of policies indexed by their unique id.
-func FromReaders(readers iterator.NamedReadCloserIterator, orgPolicy organization.Policy, validator options.PolicyValidator) (map[string]Policy, error) {
-	policies := make(map[string]Policy)
-	protections := make(map[string]bool)
+// validateLevelOverride validates the level of the field at pointer,
+// which overrides the project's level for the package or environment name.
+func validateLevelOverride(pointer, name string, level, projectLevel, maxBuildLevel int) error {
+	if level < 0 || level > 4 {
+		return fmt.Errorf("[project] %w: %q: %s build's level (%d) is invalid. Must satisfy 0 <= slsa_level <= 4",
+			errs.ErrorInvalidField, pointer, name, level)
+	}
+	if level > maxBuildLevel {
+		return fmt.Errorf("[project] %w: %q: %s build's level (%d) cannot be satisfied by org policy's max level (%d)",
+			errs.ErrorInvalidField, pointer, name, level, maxBuildLevel)
+	}
+	if level < projectLevel {
+		return fmt.Errorf("[project] %w: %q: %s build's level (%d) conflicts with project's level (%d)",
+			errs.ErrorInvalidField, pointer, name, level, projectLevel)
+	}
+	return nil
+}
+
+// RequiredLevel returns the strictest SLSA build level required
+// to deploy the package to the environment, if set.
+func (p *Policy) RequiredLevel(pkg *Package, env *string) int {
+	level := *p.BuildRequirements.RequireSlsaLevel
+	if pkg.BuildRequirements != nil && pkg.BuildRequirements.RequireSlsaLevel != nil {
+		level = max(level, *pkg.BuildRequirements.RequireSlsaLevel)
+	}
+	if env != nil {
+		if l, exists := p.BuildRequirements.Environments[*env]; exists {
+			level = max(level, l)
+		}
+	}
+	return level
+}
+
+// levelGroup contains the candidate environments
+// that require the same SLSA build level.
+type levelGroup struct {
+	level int
+	envs  []string
+}
+
+// levelGroups groups the candidate environments of the package by
+// required SLSA build level, from the strictest to the least strict.
+// The environments with a level that a wildcard candidate matches are
+// added to the group of their level, so that they are verified at it.
+func (p *Policy) levelGroups(pkg *Package, candidates []string) []levelGroup {
+	if len(candidates) == 0 {
+		return []levelGroup{{level: p.RequiredLevel(pkg, nil)}}
+	}
+	overrides := make([]string, 0, len(p.BuildRequirements.Environments))
+	for env := range p.BuildRequirements.Environments {
+		overrides = append(overrides, env)
+	}
+	slices.Sort(overrides)
+	var groups []levelGroup
+	add := func(env string) {
+		level := p.RequiredLevel(pkg, &env)
+		i := slices.IndexFunc(groups, func(g levelGroup) bool { return g.level == level })
+		if i < 0 {
+			groups = append(groups, levelGroup{level: level})
+			i = len(groups) - 1
+		}
+		if !slices.Contains(groups[i].envs, env) {
+			groups[i].envs = append(groups[i].envs, env)
+		}
+	}
+	for _, env := range candidates {
+		if isEnvironmentPattern(env) {
+			for _, override := range overrides {
+				if matchEnvironment(env, override) {
+					add(override)
+				}
+			}
+		}
+		add(env)
+	}
+	slices.SortStableFunc(groups, func(a, b levelGroup) int { return b.level - a.level })
+	return groups
+}
+
+// parsedPolicy is the result of parsing a policy file.
+type parsedPolicy struct {
+	id string
+	// label identifies the policy file and the files
+	// it extends, used to annotate errors.
+	label  string
+	policy *Policy
+	err    error
+}
+
+// readAll reads the policy files of readers. The iterator is consumed
+// by the calling goroutine. It stops reading once there are more policy
+// files than the limit, see options.WithMaxPolicies().
+func readAll(readers iterator.NamedReadCloserIterator, parse options.Parse) ([]extends.Document, error) {
+	var docs []extends.Document
 	for readers.HasNext() {
+		if len(docs) == parse.PolicyLimit() {
+			return nil, fmt.Errorf("[project] %w: more policy files than the maximum number of policy files (%d)",
+				errs.ErrorInvalidInput, parse.PolicyLimit())
+		}
 		id, reader := readers.Next()
-		// NOTE: fromReader()validates that the required levels is achievable.
-		policy, err := fromReader(reader, orgPolicy.MaxBuildSlsaLevel(), validator)
+		// NOTE: the iterator returns a nil reader on error,
+		// which is reported by readers.Error().
+		if reader == nil {
+			break
+		}
+		doc := extends.Document{ID: id}
+		doc.Content, doc.Err = readPolicy(reader, parse)
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// parseAll parses the resolved policy files, with up to workers files
+// parsed concurrently. The results are in the order of the files, so
+// that they do not depend on scheduling.
+func parseAll(files []extends.Resolved, workers int,
+	parse func(content []byte) (*Policy, error)) []parsedPolicy {
+	results := make([]parsedPolicy, len(files))
+	// NOTE: each call writes distinct results.
+	parallel.ForEach(len(files), workers, func(i int) {
+		results[i].id, results[i].label = files[i].ID, files[i].Label()
+		if files[i].Err != nil {
+			results[i].err = fmt.Errorf("[project] %w", files[i].Err)
+			return
+		}
+		results[i].policy, results[i].err = parse(files[i].Content)
+	})
+	return results
+}
+
+// FromReaders creates a set of policies indexed by their unique id.
+// A policy file may extend a base file, see extends.Resolve. Base files
+// are not policies by themselves. The policy files may be parsed
+// concurrently, see options.WithWorkers(), in which case the
+// validator must be safe for concurrent use.
+func FromReaders(readers iterator.NamedReadCloserIterator, orgPolicy organization.Policy, validator options.PolicyValidator, parseOpts ...options.ParseOption) (map[string]Policy, error) {
+	parse := options.ParseNew(parseOpts...)
+	policies := make(map[string]Policy)
+	principals := make(map[string]bool)
+	maxBuildLevel := orgPolicy.MaxBuildSlsaLevel()
+	defaultLevel := orgPolicy.DefaultSlsaLevel()
+	rootIDs := orgPolicy.PublishRootIDs()
+	roots := orgPolicy.Roots.Publish
+	docs, err := readAll(readers, parse)
+	if err != nil {
+		return nil, err
+	}
+	files := extends.Resolve(docs)
+	results := parseAll(files, parse.Concurrency(), func(content []byte) (*Policy, error) {
+		// NOTE: fromContent() validates that the required levels is achievable.
+		policy, err := fromContent(content, maxBuildLevel, defaultLevel, validator, parse)
 		if err != nil {
 			return nil, err
 		}
+		if err := policy.validatePublishRoots(rootIDs); err != nil {
+			return nil, err
+		}
+		if err := policy.validateRootEnvironments(roots); err != nil {
+			return nil, err
+		}
+		return policy, nil
+	})
+	// NOTE: errors are accumulated so that all invalid policies are reported.
+	var allErrs []error
+	for _, result := range results {
+		id, policy := result.id, result.policy
+		if result.err != nil {
+			allErrs = append(allErrs, annotate(result.label, result.err)...)
+			continue
+		}
 		// The policy ID must be unique across all projects.
 		if _, exists := policies[id]; exists {
-			return nil, fmt.Errorf("[project] %w: policy id (%q) is defined more than once", errs.ErrorInvalidField, id)
+			allErrs = append(allErrs, fmt.Errorf("[project] %w: policy id (%q) is defined more than once", errs.ErrorInvalidField, id))
+			continue
 		}
 		policies[id] = *policy
+		parse.Log().Debug("policy file parsed", "policy_id", id, "packages", len(policy.Packages))
 
-		// The protection must be unique across all projects.
-		name := policy.Protection.GoogleServiceAccount
-		if _, exists := protections[name]; exists {
-			return nil, fmt.Errorf("[project] %w: protection's serivce_account (%q) is defined more than once", errs.ErrorInvalidField, name)
+		// The principals must be unique across all projects.
+		for _, uri := range policy.Principal.allURIs() {
+			if _, exists := principals[uri]; exists {
+				allErrs = append(allErrs, fmt.Errorf("%s: [project] %w: principal's URI (%q) is defined more than once",
+					id, errs.ErrorInvalidField, uri))
+				continue
+			}
+			principals[uri] = true
 		}
-		protections[name] = true
 	}
 	//TODO: add test for this.
 	if readers.Error() != nil {
-		return nil, fmt.Errorf("[project] failed to read policy: %w", readers.Error())
+		return nil, fmt.Errorf("[project] %w: failed to read policy: %w", errs.ErrorInvalidInput, readers.Error())
+	}
+	if len(allErrs) > 0 {
+		return nil, errors.Join(allErrs...)
 	}
 	return policies, nil
 }
 
+// annotate prefixes each violation in err with the policy identifier.
+func annotate(id string, err error) []error {
+	var list []error
+	for _, e := range errs.Violations(err) {
+		list = append(list, fmt.Errorf("%s: %w", id, e))
+	}
+	return list
+}
+
 // Evaluate evaluates a policy.
+// Non-expired exceptions at now take precedence over the publish attestations.
 func (p *Policy) Evaluate(digests intoto.DigestSet, packageName string,
-	orgPolicy organization.Policy, publishOpts options.PublishVerification) (*Protection, error) {
+	orgPolicy organization.Policy, publishOpts options.PublishVerification, now time.Time) (*Result, error) {
 	if publishOpts.Verifier == nil {
 		return nil, fmt.Errorf("[project] %w: verifier is empty", errs.ErrorInvalidInput)
 	}
@@ -200,13 +839,63 @@ func (p *Policy) Evaluate(digests intoto.DigestSet, packageName string,
 	if err := digests.Validate(); err != nil {
 		return nil, err
 	}
-	// Get the package for protection Name.
+	// Get the package for the name.
 	pkg, err := p.getPackage(packageName)
 	if err != nil {
+		publishOpts.Record(trace.Step{Package: packageName, Outcome: trace.OutcomeRejected,
+			Reason: trace.ReasonPackageNotFound}, err)
+		return nil, err
+	}
+	logger := publishOpts.Log()
+	logger.Debug("package matched", "package", packageName, "environments", pkg.Environment.AnyOf)
+	// Verify the digest algorithms before calling the verifier.
+	digestAlgorithms, err := pkg.acceptedDigests(digests)
+	if err != nil {
+		publishOpts.Record(trace.Step{Package: packageName, Outcome: trace.OutcomeRejected,
+			Reason: trace.ReasonDigestNotAccepted}, err)
 		return nil, err
 	}
+	// NOTE: digests of other algorithms are neither verified nor attested.
+	acceptedDigests := digests
+	if digestAlgorithms != nil {
+		acceptedDigests = make(intoto.DigestSet, len(digestAlgorithms))
+		for _, alg := range digestAlgorithms {
+			acceptedDigests[alg] = digests[alg]
+		}
+	}
+	// Exceptions.
+	if exception := p.exception(digests, now); exception != nil {
+		logger.Info("exception applied", "digest", exception.Digest, "decision", exception.Decision,
+			"reason", exception.Reason, "expires", exception.Expires)
+		if exception.Decision == ExceptionDeny {
+			err := fmt.Errorf("[project] %w: digest (%q) is denied by an exception until %s: %s",
+				errs.ErrorVerification, exception.Digest, exception.Expires, exception.Reason)
+			publishOpts.Record(trace.Step{Package: packageName, Outcome: trace.OutcomeRejected,
+				Reason: trace.ReasonException}, err)
+			return nil, err
+		}
+		principal, err := p.Principal.resolveAny(pkg.Environment.AnyOf)
+		if err != nil {
+			return nil, err
+		}
+		publishOpts.Record(trace.Step{Package: packageName, Outcome: trace.OutcomeSelected,
+			Reason: trace.ReasonException}, nil)
+		e := *exception
+		return &Result{
+			Principal:           *principal,
+			DigestAlgorithms:    digestAlgorithms,
+			Digests:             acceptedDigests,
+			Exception:           &e,
+			AllowedEnvironments: slices.Clone(pkg.Environment.AnyOf),
+		}, nil
+	}
 
 	env := pkg.Environment.AnyOf
+	// NOTE: the verifier receives the wildcards expanded.
+	candidates := p.Principal.candidates(env)
+	// NOTE: groups are sorted from the strictest level.
+	groups := p.levelGroups(pkg, candidates)
+	minLevel := groups[len(groups)-1].level
 
 	// Verify with each publishr.
 	// WARNING: the hidden assumption is that the verifier is aware of which
@@ -216,32 +905,202 @@ func (p *Policy) Evaluate(digests intoto.DigestSet, packageName string,
 	var allErrs []error
 	for i := range orgPolicy.Roots.Publish {
 		publishr := &orgPolicy.Roots.Publish[i]
+		rootID := publishr.Key()
+		identity := rootIdentity(publishr)
+		logger.Debug("root considered", "root", rootID, "max_level", *publishr.Build.MaxSlsaLevel,
+			"required_level", minLevel)
 		// Filter out the publishrs that don't match the SLSA build level requirement
 		// in the policy.
-		if *publishr.Build.MaxSlsaLevel < *p.BuildRequirements.RequireSlsaLevel {
+		if *publishr.Build.MaxSlsaLevel < minLevel {
+			logger.Debug("root skipped", "root", rootID, "reason", "max level below required level")
+			if publishOpts.Trace.Enabled() {
+				publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Outcome: trace.OutcomeRejected,
+					Reason: trace.ReasonLevelTooLow}, fmt.Errorf("publish root's max level (%d) is below required level (%d)",
+					*publishr.Build.MaxSlsaLevel, minLevel))
+			}
 			continue
 		}
-		// We have a candidate.
-		verifiedEnv, err := publishOpts.Verifier.VerifyPublishAttestation(digests, packageName, env, publishr.ID, *p.BuildRequirements.RequireSlsaLevel)
-		if err != nil {
-			// Verification failed, continue.
+		// Filter out the publishrs the package is not pinned to.
+		if !pkg.allowsRoot(rootID) {
+			logger.Debug("root skipped", "root", rootID, "reason", "not in package's publish roots")
+			err := fmt.Errorf("%w: publish root (%q) is not in package's publish roots (%q)",
+				errs.ErrorVerification, rootID, pkg.PublishRoots)
+			publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Outcome: trace.OutcomeRejected,
+				Reason: trace.ReasonRootNotAllowed}, err)
+			allErrs = append(allErrs, err)
+			continue
+		}
+		// Filter out the publishrs that are not trusted to attest
+		// to any of the package's environments.
+		rootEnvs := rootEnvironments(publishr, env)
+		if publishr.HasEnvironmentRestrictions() && len(rootEnvs) == 0 {
+			logger.Debug("root skipped", "root", rootID, "reason", "not trusted for package's environments")
+			err := fmt.Errorf("%w: publish root (%q) is not trusted for environments (%q)",
+				errs.ErrorVerification, rootID, env)
+			publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Environments: env,
+				Outcome: trace.OutcomeRejected, Reason: trace.ReasonEnvironmentMismatch}, err)
+			allErrs = append(allErrs, err)
+			continue
+		}
+		// Filter out the publishrs that are not allowed to authorize
+		// any of the principals for the package's environments.
+		uris := p.Principal.uris(rootEnvs)
+		if !slices.ContainsFunc(uris, publishr.CanAuthorize) {
+			logger.Debug("root skipped", "root", rootID, "reason", "cannot authorize principals")
+			err := fmt.Errorf("%w: publish root (%q) is not allowed to authorize principals (%q)",
+				errs.ErrorVerification, rootID, uris)
+			publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Outcome: trace.OutcomeRejected,
+				Reason: trace.ReasonRootNotAllowed}, err)
 			allErrs = append(allErrs, err)
 			continue
 		}
+		// We have a candidate. Verify each group of environments
+		// whose required level the publishr can satisfy.
+		for i := range groups {
+			group := &groups[i]
+			if *publishr.Build.MaxSlsaLevel < group.level {
+				logger.Debug("environments skipped", "root", rootID, "environments", group.envs,
+					"reason", "max level below required level")
+				if publishOpts.Trace.Enabled() {
+					publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Environments: group.envs,
+						Outcome: trace.OutcomeRejected, Reason: trace.ReasonLevelTooLow},
+						fmt.Errorf("publish root's max level (%d) is below required level (%d)",
+							*publishr.Build.MaxSlsaLevel, group.level))
+				}
+				continue
+			}
+			// The verifier only receives the environments the publishr is trusted for.
+			groupEnvs := rootEnvironments(publishr, group.envs)
+			if publishr.HasEnvironmentRestrictions() && len(groupEnvs) == 0 {
+				logger.Debug("environments skipped", "root", rootID, "environments", group.envs,
+					"reason", "not trusted for environments")
+				if publishOpts.Trace.Enabled() {
+					publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Environments: group.envs,
+						Outcome: trace.OutcomeRejected, Reason: trace.ReasonEnvironmentMismatch},
+						fmt.Errorf("publish root is only trusted for environments (%q)", publishr.Environments))
+				}
+				continue
+			}
+			// The required level is a minimum: the verifier accepts
+			// attestations at this level or above.
+			logger.Debug("verifier invoked", "package", packageName, "environments", groupEnvs, "root", rootID,
+				"min_level", group.level)
+			var verifiedEnv *string
+			err := publishOpts.Retry.Do(publishOpts.Ctx(), func() error {
+				var err error
+				verifiedEnv, err = publishOpts.Verifier.VerifyPublishAttestation(acceptedDigests, packageName, groupEnvs, rootID, identity,
+					group.level, publishOpts.MinAuthorVersion)
+				return err
+			})
+			if err != nil {
+				// Verification failed, continue.
+				logger.Debug("verifier result", "root", rootID, "error", err)
+				publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Environments: groupEnvs,
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonVerifierFailure}, err)
+				allErrs = append(allErrs, err)
+				continue
+			}
+			if verifiedEnv != nil {
+				logger.Debug("verifier result", "root", rootID, "environment", *verifiedEnv)
+			} else {
+				logger.Debug("verifier result", "root", rootID)
+			}
 
-		// Verification of publish attestation succeeded.
+			// Verification of publish attestation succeeded.
 
-		// Sanity check.
-		if err := validateEnv(env, verifiedEnv); err != nil {
-			return nil, err
+			// Sanity check.
+			if err := validateEnv(env, verifiedEnv); err != nil {
+				return nil, err
+			}
+			// The verifier may return an environment the publishr is not trusted for.
+			if !publishr.AllowsEnvironment(verifiedEnv) {
+				err := fmt.Errorf("%w: publish root (%q) is not trusted for environment (%q). Must be one of %q",
+					errs.ErrorVerification, rootID, *verifiedEnv, publishr.Environments)
+				publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Environments: []string{*verifiedEnv},
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonEnvironmentMismatch}, err)
+				allErrs = append(allErrs, err)
+				continue
+			}
+			// A wildcard may match an environment that requires a stricter level.
+			if level := p.RequiredLevel(pkg, verifiedEnv); level > group.level {
+				err := fmt.Errorf("%w: environment (%q) requires build level (%d) but was verified at (%d)",
+					errs.ErrorVerification, *verifiedEnv, level, group.level)
+				publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Environments: []string{*verifiedEnv},
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonLevelTooLow}, err)
+				allErrs = append(allErrs, err)
+				continue
+			}
+			// Select the principal for the verified environment.
+			principal, err := p.Principal.resolve(verifiedEnv)
+			if err != nil {
+				return nil, err
+			}
+			if !publishr.CanAuthorize(principal.URI) {
+				err := fmt.Errorf("%w: publish root (%q) is not allowed to authorize principal (%q)",
+					errs.ErrorVerification, rootID, principal.URI)
+				publishOpts.Record(trace.Step{Package: packageName, RootID: rootID, Outcome: trace.OutcomeRejected,
+					Reason: trace.ReasonRootNotAllowed}, err)
+				allErrs = append(allErrs, err)
+				continue
+			}
+			if publishOpts.Trace.Enabled() {
+				step := trace.Step{Package: packageName, RootID: rootID, Outcome: trace.OutcomeSelected,
+					Reason: trace.ReasonVerified}
+				if verifiedEnv != nil {
+					step.Environments = []string{*verifiedEnv}
+				}
+				publishOpts.Record(step, nil)
+			}
+			return &Result{
+				Principal:           *principal,
+				Environment:         verifiedEnv,
+				PublishRootID:       rootID,
+				BuildLevel:          group.level,
+				DigestAlgorithms:    digestAlgorithms,
+				Digests:             acceptedDigests,
+				AllowedEnvironments: slices.Clone(pkg.Environment.AnyOf),
+			}, nil
 		}
-		// The target Name of the policy.
-		cpy := p.Protection
-		return &cpy, nil
 	}
 	return nil, fmt.Errorf("[project] %w: cannot verify: %v", errs.ErrorVerification, allErrs)
 }
 
+// CanUseRoot returns true if the root may authorize
+// at least one of the packages in the policy.
+func (p *Policy) CanUseRoot(root *organization.Root) bool {
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		if !pkg.allowsRoot(root.Key()) {
+			continue
+		}
+		for _, group := range p.levelGroups(pkg, p.Principal.candidates(pkg.Environment.AnyOf)) {
+			if *root.Build.MaxSlsaLevel < group.level {
+				continue
+			}
+			envs := rootEnvironments(root, group.envs)
+			if root.HasEnvironmentRestrictions() && len(envs) == 0 {
+				continue
+			}
+			if slices.ContainsFunc(p.Principal.uris(envs), root.CanAuthorize) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rootIdentity returns the certificate identity of the root,
+// or nil if the root is identified by its ID.
+func rootIdentity(root *organization.Root) *options.Identity {
+	if root.Identity == nil {
+		return nil
+	}
+	return &options.Identity{
+		Issuer:       root.Identity.Issuer,
+		SubjectRegex: root.Identity.Subject(),
+	}
+}
+
 func validateEnv(env []string, verifiedEnv *string) error {
 	if len(env) > 0 {
 		if verifiedEnv == nil {
@@ -250,7 +1109,7 @@ func validateEnv(env []string, verifiedEnv *string) error {
 		if *verifiedEnv == "" {
 			return fmt.Errorf("[project] %w: mismatch environment (%q) and verified environment (%q)", errs.ErrorInternal, env, *verifiedEnv)
 		}
-		if !slices.Contains(env, *verifiedEnv) {
+		if _, ok := matchEnvironments(env, *verifiedEnv); !ok {
 			return fmt.Errorf("[project] %w: mismatch value environment (%q) and verified environment (%q)", errs.ErrorInternal, env, *verifiedEnv)
 		}
 		return nil
@@ -261,12 +1120,66 @@ func validateEnv(env []string, verifiedEnv *string) error {
 	return nil
 }
 
+// acceptedDigests returns the sorted digest algorithms of the
+// digests that are accepted for the package, or nil if the package
+// accepts any algorithm.
+func (pkg *Package) acceptedDigests(digests intoto.DigestSet) ([]string, error) {
+	if len(pkg.AcceptedDigestAlgorithms) == 0 {
+		return nil, nil
+	}
+	var accepted, provided []string
+	for alg := range digests {
+		provided = append(provided, alg)
+		if slices.Contains(pkg.AcceptedDigestAlgorithms, alg) {
+			accepted = append(accepted, alg)
+		}
+	}
+	slices.Sort(provided)
+	if len(accepted) == 0 {
+		return nil, fmt.Errorf("[project] %w: digest algorithms (%q) are not accepted for package (%q). Must contain one of %q",
+			errs.ErrorInvalidField, provided, pkg.Name, pkg.AcceptedDigestAlgorithms)
+	}
+	slices.Sort(accepted)
+	return accepted, nil
+}
+
+// PackageEnvironments returns the environments configured for the
+// package, which may be wildcards. It returns errs.ErrorNotFound if
+// no package matches the name. The returned value is a copy.
+func (p *Policy) PackageEnvironments(packageName string) ([]string, error) {
+	pkg, err := p.getPackage(packageName)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(pkg.Environment.AnyOf), nil
+}
+
+// getPackage returns the package for the name. An exact match
+// takes precedence over a pattern match.
 func (p *Policy) getPackage(packageName string) (*Package, error) {
-	for i := range p.Packages {
-		pkg := &p.Packages[i]
-		if pkg.Name == packageName {
-			return pkg, nil
+	i, err := p.PackageIndex(packageName)
+	if err != nil {
+		return nil, err
+	}
+	return &p.Packages[i], nil
+}
+
+// PackageIndex returns the index in Packages of the package for
+// the name, see getPackage(). It returns errs.ErrorNotFound if
+// no package matches the name.
+func (p *Policy) PackageIndex(packageName string) (int, error) {
+	index := p.index
+	if index == nil {
+		// NOTE: only policies created by fromReader() are indexed.
+		index = newPackageIndex(p.Packages)
+	}
+	if i, exists := index.names[packageName]; exists {
+		return i, nil
+	}
+	for _, i := range index.patterns {
+		if matchName(p.Packages[i].Name, packageName) {
+			return i, nil
 		}
 	}
-	return nil, fmt.Errorf("[project] %w: package name(%q)", errs.ErrorNotFound, packageName)
+	return 0, fmt.Errorf("[project] %w: package name(%q)", errs.ErrorNotFound, packageName)
 }
@@ -2,7 +2,15 @@ package project
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -10,7 +18,9 @@ import (
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/assertions"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files"
 )
 
 func Test_validateFormat(t *testing.T) {
@@ -53,7 +63,7 @@ func Test_validateFormat(t *testing.T) {
 	}
 }
 
-func Test_validateProtection(t *testing.T) {
+func Test_validatePrincipal(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
@@ -62,152 +72,130 @@ func Test_validateProtection(t *testing.T) {
 		expected error
 	}{
 		{
-			name: "service_account present",
+			name: "principal present",
 			policy: Policy{
-				Protection: Protection{
-					GoogleServiceAccount: "the_sa",
+				Principal: Principal{
+					URI: "the_sa",
 				},
 			},
 		},
 		{
-			name:     "service_account not present",
+			name:     "principal not present",
 			policy:   Policy{},
 			expected: errs.ErrorInvalidField,
 		},
-	}
-	for _, tt := range tests {
-		tt := tt // Re-initializing variable so it is not changed while executing the closure below
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			err := tt.policy.validateProtection()
-			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
-			}
-		})
-	}
-}
-
-func Test_getPackage(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name        string
-		policy      Policy
-		packageName string
-		expected    error
-	}{
 		{
-			name:        "name2 present",
-			packageName: "name2",
+			name: "environment principals only",
 			policy: Policy{
-				Packages: []Package{
-					{
-						Name: "name1",
-					},
-					{
-						Name: "name2",
+				Principal: Principal{
+					Environments: map[string]string{
+						"prod": "the_prod_sa",
 					},
 				},
 			},
 		},
 		{
-			name:        "name3 not present",
-			expected:    errs.ErrorNotFound,
-			packageName: "name3",
+			name: "empty environment",
 			policy: Policy{
-				Packages: []Package{
-					{
-						Name: "name1",
-					},
-					{
-						Name: "name2",
+				Principal: Principal{
+					URI: "the_sa",
+					Environments: map[string]string{
+						"": "the_prod_sa",
 					},
 				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
-	}
-	for _, tt := range tests {
-		tt := tt // Re-initializing variable so it is not changed while executing the closure below
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			pkg, err := tt.policy.getPackage(tt.packageName)
-			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
-			}
-			if err != nil {
-				return
-			}
-			if diff := cmp.Diff(tt.packageName, pkg.Name); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
-			}
-		})
-	}
-}
-
-func Test_validateEnv(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name     string
-		env      *string
-		envs     []string
-		expected error
-	}{
 		{
-			name: "env match",
-			env:  common.AsPointer("dev"),
-			envs: []string{
-				"prod", "dev",
+			name: "empty environment URI",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Environments: map[string]string{
+						"prod": "",
+					},
+				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
 		{
-			name:     "env mismatch",
-			expected: errs.ErrorInternal,
-			env:      common.AsPointer("not dev"),
-			envs: []string{
-				"prod", "dev",
+			name: "wildcard environment",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Environments: map[string]string{
+						"prod/*": "the_prod_sa",
+					},
+				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
 		{
-			name:     "nil env",
-			expected: errs.ErrorInternal,
-			envs: []string{
-				"prod", "dev",
+			name: "environment with empty segment",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Environments: map[string]string{
+						"prod//us-east1": "the_prod_sa",
+					},
+				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
 		{
-			name:     "nil envs",
-			expected: errs.ErrorInternal,
-			env:      common.AsPointer("dev"),
+			name: "scopes",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Scopes: map[string]string{
+						"iam.gserviceaccount.com/v1": "the_gcp_sa",
+						"region":                     "us-east1",
+					},
+				},
+			},
 		},
 		{
-			name:     "empty envs",
-			expected: errs.ErrorInternal,
-			env:      common.AsPointer("dev"),
-			envs:     []string{},
+			name: "empty scope key",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Scopes: map[string]string{
+						"": "the_gcp_sa",
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
 		},
 		{
-			name:     "empty env",
-			expected: errs.ErrorInternal,
-			env:      common.AsPointer(""),
-			envs: []string{
-				"prod", "dev",
+			name: "empty scope value",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Scopes: map[string]string{
+						"region": "",
+					},
+				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
 		{
-			name:     "empty envs entry",
-			expected: errs.ErrorInternal,
-			env:      common.AsPointer(""),
-			envs: []string{
-				"prod", "",
+			name: "kubernetes scope",
+			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Scopes: map[string]string{
+						ScopeKubernetesServiceAccount: "other_sa",
+					},
+				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			err := validateEnv(tt.envs, tt.env)
+			err := tt.policy.validatePrincipal()
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
@@ -215,7 +203,7 @@ func Test_validateEnv(t *testing.T) {
 	}
 }
 
-func Test_validatePackages(t *testing.T) {
+func Test_validatePrincipalEnvironments(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
@@ -224,17 +212,17 @@ func Test_validatePackages(t *testing.T) {
 		expected error
 	}{
 		{
-			name: "two valid packages",
+			name: "all environments have a principal",
 			policy: Policy{
-				Packages: []Package{
-					{
-						Name: "the_name",
-						Environment: Environment{
-							AnyOf: []string{"dev", "prod"},
-						},
+				Principal: Principal{
+					Environments: map[string]string{
+						"dev":  "the_dev_sa",
+						"prod": "the_prod_sa",
 					},
+				},
+				Packages: []Package{
 					{
-						Name: "the_name2",
+						Name: "package_name",
 						Environment: Environment{
 							AnyOf: []string{"dev", "prod"},
 						},
@@ -243,628 +231,3236 @@ func Test_validatePackages(t *testing.T) {
 			},
 		},
 		{
-			name:     "duplicate name",
-			expected: errs.ErrorInvalidField,
+			name: "default principal",
 			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+					Environments: map[string]string{
+						"prod": "the_prod_sa",
+					},
+				},
 				Packages: []Package{
 					{
-						Name: "the_name",
+						Name: "package_name",
 						Environment: Environment{
 							AnyOf: []string{"dev", "prod"},
 						},
 					},
 					{
-						Name: "the_name",
-						Environment: Environment{
-							AnyOf: []string{"staging"},
-						},
+						Name: "package_name2",
 					},
 				},
 			},
 		},
 		{
-			name:     "empty env",
-			expected: errs.ErrorInvalidField,
+			name: "missing environment principal",
 			policy: Policy{
+				Principal: Principal{
+					Environments: map[string]string{
+						"prod": "the_prod_sa",
+					},
+				},
 				Packages: []Package{
 					{
-						Name: "the_name",
+						Name: "package_name",
 						Environment: Environment{
-							AnyOf: []string{"", "prod"},
+							AnyOf: []string{"dev", "prod"},
 						},
 					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "wildcard expands to environment principals",
+			policy: Policy{
+				Principal: Principal{
+					Environments: map[string]string{
+						"prod/us-east1": "the_us_sa",
+						"prod/eu-west4": "the_eu_sa",
+					},
+				},
+				Packages: []Package{
 					{
-						Name: "the_name2",
+						Name: "package_name",
 						Environment: Environment{
-							AnyOf: []string{"dev", "prod"},
+							AnyOf: []string{"prod/*"},
 						},
 					},
 				},
 			},
 		},
 		{
-			name:     "missing name",
-			expected: errs.ErrorInvalidField,
+			name: "wildcard default principal",
 			policy: Policy{
+				Principal: Principal{
+					URI: "the_sa",
+				},
 				Packages: []Package{
 					{
-						Name: "the_name",
+						Name: "package_name",
 						Environment: Environment{
-							AnyOf: []string{"dev", "prod"},
+							AnyOf: []string{"prod/*"},
 						},
 					},
+				},
+			},
+		},
+		{
+			name: "wildcard without principal",
+			policy: Policy{
+				Principal: Principal{
+					Environments: map[string]string{
+						"staging/us-east1": "the_staging_sa",
+					},
+				},
+				Packages: []Package{
 					{
+						Name: "package_name",
 						Environment: Environment{
-							AnyOf: []string{"dev", "prod"},
+							AnyOf: []string{"prod/*"},
 						},
 					},
 				},
 			},
+			expected: errs.ErrorInvalidField,
 		},
 		{
-			name:     "no packages",
+			name: "no environment no default principal",
+			policy: Policy{
+				Principal: Principal{
+					Environments: map[string]string{
+						"prod": "the_prod_sa",
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+					},
+				},
+			},
 			expected: errs.ErrorInvalidField,
-			policy:   Policy{},
 		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			err := tt.policy.validatePackages()
+			err := tt.policy.validatePrincipalEnvironments()
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
-			// Same policy with a passing validator.
-			tt.policy.validator = common.NewPolicyValidator(true)
-			err = tt.policy.validatePackages()
+		})
+	}
+}
+
+func Test_PrincipalEnvironmentsUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		content   string
+		principal Principal
+		expected  error
+	}{
+		{
+			name:    "environments",
+			content: `{"uri": "the_sa", "environments": {"dev": "the_dev_sa", "prod": "the_prod_sa"}}`,
+			principal: Principal{
+				URI: "the_sa",
+				Environments: map[string]string{
+					"dev":  "the_dev_sa",
+					"prod": "the_prod_sa",
+				},
+			},
+		},
+		{
+			name:    "null environments",
+			content: `{"uri": "the_sa", "environments": null}`,
+			principal: Principal{
+				URI: "the_sa",
+			},
+		},
+		{
+			name:     "duplicate environment",
+			content:  `{"uri": "the_sa", "environments": {"prod": "the_prod_sa", "prod": "the_other_sa"}}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "environments not an object",
+			content:  `{"uri": "the_sa", "environments": ["prod"]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "URI not a string",
+			content:  `{"uri": "the_sa", "environments": {"prod": 1}}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var principal Principal
+			err := json.Unmarshal([]byte(tt.content), &principal)
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 			if err != nil {
 				return
 			}
-			// Same policy with a failing validator.
-			tt.policy.validator = common.NewPolicyValidator(false)
-			err = tt.policy.validatePackages()
-			if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			if diff := cmp.Diff(tt.principal, principal); diff != "" {
+				t.Fatalf("unexpected principal (-want +got): \n%s", diff)
 			}
-
 		})
 	}
 }
 
-func Test_validateBuildRequirements(t *testing.T) {
+func Test_resolve(t *testing.T) {
 	t.Parallel()
+	principal := Principal{
+		Environments: map[string]string{
+			"prod": "the_prod_sa",
+		},
+		Scopes: map[string]string{
+			"region": "us-east1",
+		},
+	}
+	principalWithDefault := principal
+	principalWithDefault.URI = "the_sa"
 
 	tests := []struct {
-		name          string
-		policy        Policy
-		maxBuildLevel int
-		expected      error
+		name      string
+		principal Principal
+		env       *string
+		expected  *Principal
+		err       error
 	}{
 		{
-			name:          "same levels",
-			maxBuildLevel: 3,
-			policy: Policy{
-				BuildRequirements: BuildRequirements{
-					RequireSlsaLevel: common.AsPointer(3),
-				},
+			name:      "environment principal",
+			principal: principal,
+			env:       common.AsPointer("prod"),
+			expected: &Principal{
+				URI:    "the_prod_sa",
+				Scopes: principal.Scopes,
 			},
 		},
 		{
-			name:          "lower reqnameed level",
-			maxBuildLevel: 3,
-			policy: Policy{
-				BuildRequirements: BuildRequirements{
-					RequireSlsaLevel: common.AsPointer(2),
-				},
+			name:      "default principal",
+			principal: principalWithDefault,
+			env:       common.AsPointer("dev"),
+			expected: &Principal{
+				URI:    "the_sa",
+				Scopes: principal.Scopes,
 			},
 		},
 		{
-			name:          "higher reqnameed level",
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 3,
-			policy: Policy{
-				BuildRequirements: BuildRequirements{
-					RequireSlsaLevel: common.AsPointer(4),
-				},
+			name:      "default principal without environment",
+			principal: principalWithDefault,
+			expected: &Principal{
+				URI:    "the_sa",
+				Scopes: principal.Scopes,
 			},
 		},
 		{
-			name:          "negative level",
+			name:      "no principal for environment",
+			principal: principal,
+			env:       common.AsPointer("dev"),
+			err:       errs.ErrorNotFound,
+		},
+		{
+			name:      "no principal without environment",
+			principal: principal,
+			err:       errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			resolved, err := tt.principal.resolve(tt.env)
+			if diff := cmp.Diff(tt.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, resolved); diff != "" {
+				t.Fatalf("unexpected principal (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_getPackage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		policy      Policy
+		packageName string
+		matched     string
+		expected    error
+	}{
+		{
+			name:        "name2 present",
+			packageName: "name2",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "name1",
+					},
+					{
+						Name: "name2",
+					},
+				},
+			},
+		},
+		{
+			name:        "name3 not present",
+			expected:    errs.ErrorNotFound,
+			packageName: "name3",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "name1",
+					},
+					{
+						Name: "name2",
+					},
+				},
+			},
+		},
+		{
+			name:        "pattern match",
+			packageName: "registry/repo/service1",
+			matched:     "registry/repo/*",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/other/*",
+					},
+					{
+						Name: "registry/repo/*",
+					},
+				},
+			},
+		},
+		{
+			name:        "exact match over pattern match",
+			packageName: "registry/repo/service1",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo/*",
+					},
+					{
+						Name: "registry/repo/service1",
+					},
+				},
+			},
+		},
+		{
+			name:        "pattern match over other exact name",
+			packageName: "registry/repo/service2",
+			matched:     "registry/repo/*",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo/service1",
+					},
+					{
+						Name: "registry/repo/*",
+					},
+				},
+			},
+		},
+		{
+			name:        "pattern does not match across segments",
+			expected:    errs.ErrorNotFound,
+			packageName: "registry/repo/team/service1",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo/*",
+					},
+				},
+			},
+		},
+		{
+			name:        "pattern in middle segment",
+			packageName: "registry/team1/service",
+			matched:     "registry/*/service",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/*/service",
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pkg, err := tt.policy.getPackage(tt.packageName)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			matched := tt.packageName
+			if tt.matched != "" {
+				matched = tt.matched
+			}
+			if diff := cmp.Diff(matched, pkg.Name); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_validateEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		env      *string
+		envs     []string
+		expected error
+	}{
+		{
+			name: "env match",
+			env:  common.AsPointer("dev"),
+			envs: []string{
+				"prod", "dev",
+			},
+		},
+		{
+			name:     "env mismatch",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer("not dev"),
+			envs: []string{
+				"prod", "dev",
+			},
+		},
+		{
+			name: "env wildcard match",
+			env:  common.AsPointer("prod/us-east1"),
+			envs: []string{
+				"dev", "prod/*",
+			},
+		},
+		{
+			name:     "env wildcard mismatch",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer("prod/us-east1/zone-a"),
+			envs: []string{
+				"dev", "prod/*",
+			},
+		},
+		{
+			name:     "env is wildcard",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer("prod/*"),
+			envs: []string{
+				"dev", "prod/*",
+			},
+		},
+		{
+			name:     "nil env",
+			expected: errs.ErrorInternal,
+			envs: []string{
+				"prod", "dev",
+			},
+		},
+		{
+			name:     "nil envs",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer("dev"),
+		},
+		{
+			name:     "empty envs",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer("dev"),
+			envs:     []string{},
+		},
+		{
+			name:     "empty env",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer(""),
+			envs: []string{
+				"prod", "dev",
+			},
+		},
+		{
+			name:     "empty envs entry",
+			expected: errs.ErrorInternal,
+			env:      common.AsPointer(""),
+			envs: []string{
+				"prod", "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateEnv(tt.envs, tt.env)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_validatePackages(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		expected error
+	}{
+		{
+			name: "two valid packages",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+					{
+						Name: "the_name2",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "duplicate name",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"staging"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "wildcard env",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"prod/*", "staging/us-*"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "invalid wildcard env",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"prod/*-east1"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "empty segment env",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"prod//us-east1"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "empty env",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"", "prod"},
+						},
+					},
+					{
+						Name: "the_name2",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "missing name",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "the_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+					{
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "exact name and pattern",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo/service1",
+					},
+					{
+						Name: "registry/repo/*",
+					},
+				},
+			},
+		},
+		{
+			name: "non-overlapping patterns",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo1/*",
+					},
+					{
+						Name: "registry/repo2/*",
+					},
+				},
+			},
+		},
+		{
+			name:     "overlapping patterns",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo/*",
+					},
+					{
+						Name: "registry/*/service",
+					},
+				},
+			},
+		},
+		{
+			name:     "unsupported pattern",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name: "registry/repo/[ab]*",
+					},
+				},
+			},
+		},
+		{
+			name:     "empty publish root",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name:         "the_name",
+						PublishRoots: []string{"root_id", ""},
+					},
+				},
+			},
+		},
+		{
+			name: "accepted digest algorithms",
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name:                     "the_name",
+						AcceptedDigestAlgorithms: []string{"sha256", "sha512"},
+					},
+				},
+			},
+		},
+		{
+			name:     "empty accepted digest algorithms",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name:                     "the_name",
+						AcceptedDigestAlgorithms: []string{},
+					},
+				},
+			},
+		},
+		{
+			name:     "empty accepted digest algorithm",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name:                     "the_name",
+						AcceptedDigestAlgorithms: []string{"sha256", ""},
+					},
+				},
+			},
+		},
+		{
+			name:     "duplicate accepted digest algorithm",
+			expected: errs.ErrorInvalidField,
+			policy: Policy{
+				Packages: []Package{
+					{
+						Name:                     "the_name",
+						AcceptedDigestAlgorithms: []string{"sha256", "sha256"},
+					},
+				},
+			},
+		},
+		{
+			name:     "no packages",
+			expected: errs.ErrorInvalidField,
+			policy:   Policy{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.policy.validatePackages()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Same policy with a passing validator.
+			tt.policy.validator = common.NewPolicyValidator(true)
+			err = tt.policy.validatePackages()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			// Same policy with a failing validator.
+			tt.policy.validator = common.NewPolicyValidator(false)
+			err = tt.policy.validatePackages()
+			if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+
+		})
+	}
+}
+
+func Test_validateBuildRequirements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		policy        Policy
+		maxBuildLevel int
+		expected      error
+	}{
+		{
+			name:          "same levels",
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+		{
+			name:          "lower reqnameed level",
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+			},
+		},
+		{
+			name:          "higher reqnameed level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(4),
+				},
+			},
+		},
+		{
+			name:          "negative level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: -1,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(4),
+				},
+			},
+		},
+		{
+			name:          "large level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 5,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(4),
+				},
+			},
+		},
+		{
+			name:          "negative policy level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(-1),
+				},
+			},
+		},
+		{
+			name:          "large policy level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 5,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(5),
+				},
+			},
+		},
+		{
+			name:          "package level",
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						BuildRequirements: &PackageBuildRequirements{
+							RequireSlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "package level not set",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Packages: []Package{
+					{
+						Name:              "package_name",
+						BuildRequirements: &PackageBuildRequirements{},
+					},
+				},
+			},
+		},
+		{
+			name:          "package level lower than project level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						BuildRequirements: &PackageBuildRequirements{
+							RequireSlsaLevel: common.AsPointer(1),
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "package level higher than max level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						BuildRequirements: &PackageBuildRequirements{
+							RequireSlsaLevel: common.AsPointer(4),
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "environment level",
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+					Environments: map[string]int{
+						"prod/us-east1": 3,
+						"dev":           2,
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod/*"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "environment level lower than project level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+					Environments: map[string]int{
+						"dev": 1,
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "environment level higher than max level",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+					Environments: map[string]int{
+						"prod": 4,
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "environment level not defined by packages",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+					Environments: map[string]int{
+						"staging": 3,
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "environment level wildcard",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+					Environments: map[string]int{
+						"prod/*": 3,
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: []string{"prod/*"},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.policy.validateBuildRequirements(tt.maxBuildLevel)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+	type dummyVerifierOpts struct {
+		digests     intoto.DigestSet
+		buildLevel  int
+		packageName string
+		publishrID  string
+		env         string
+	}
+	publishrID1 := "publishr_id1"
+	publishrID2 := "publishr_id2"
+	packageName1 := "package_name1"
+	packageName2 := "package_name2"
+	digests := intoto.DigestSet{
+		"sha256": "val256",
+		"sha512": "val512",
+	}
+	org := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID1,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					ID: publishrID2,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(2),
+					},
+				},
+			},
+		},
+	}
+	project := Policy{
+		Principal: Principal{
+			URI: "protection_name",
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(2),
+		},
+		Packages: []Package{
+			{
+				Name: packageName1,
+				Environment: Environment{
+					AnyOf: []string{"dev", "prod"},
+				},
+			},
+			{
+				Name: packageName2,
+				Environment: Environment{
+					AnyOf: []string{"dev", "prod"},
+				},
+			},
+		},
+	}
+	envProject := Policy{
+		Principal: Principal{
+			URI: "deployer-default",
+			Environments: map[string]string{
+				"dev":  "deployer-dev",
+				"prod": "deployer-prod",
+			},
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(2),
+		},
+		Packages: []Package{
+			{
+				Name: packageName1,
+				Environment: Environment{
+					AnyOf: []string{"dev", "prod", "staging"},
+				},
+			},
+		},
+	}
+	wildcardProject := Policy{
+		Principal: Principal{
+			URI: "deployer-default",
+			Environments: map[string]string{
+				"prod/us-east1": "deployer-us",
+				"prod/eu-west4": "deployer-eu",
+			},
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(2),
+		},
+		Packages: []Package{
+			{
+				Name: packageName1,
+				Environment: Environment{
+					AnyOf: []string{"prod/*"},
+				},
+			},
+		},
+	}
+	levelProject := Policy{
+		Principal: Principal{
+			URI: "protection_name",
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(2),
+			Environments: map[string]int{
+				"prod": 3,
+			},
+		},
+		Packages: []Package{
+			{
+				Name: packageName1,
+				Environment: Environment{
+					AnyOf: []string{"dev", "prod"},
+				},
+			},
+			{
+				Name: packageName2,
+				Environment: Environment{
+					AnyOf: []string{"dev"},
+				},
+				BuildRequirements: &PackageBuildRequirements{
+					RequireSlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+	}
+	buildLevel := 3
+	vopts := dummyVerifierOpts{
+		digests:     digests,
+		publishrID:  publishrID2,
+		packageName: packageName1,
+		buildLevel:  buildLevel,
+		env:         "prod",
+	}
+	tests := []struct {
+		name         string
+		policy       Policy
+		org          organization.Policy
+		noVerifier   bool
+		packageName  string
+		digests      intoto.DigestSet
+		verifierOpts dummyVerifierOpts
+		principal    *Principal
+		level        int
+		expected     error
+	}{
+		{
+			name:         "passing",
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org:          org,
+			policy:       project,
+		},
+		{
+			name:         "empty digests",
+			expected:     errs.ErrorInvalidField,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			org:          org,
+			policy:       project,
+		},
+		{
+			name:         "empty digest value",
+			expected:     errs.ErrorInvalidField,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests: intoto.DigestSet{
+				"sha256": "val256",
+				"":       "val512",
+			},
+			org:    org,
+			policy: project,
+		},
+		{
+			name:         "digest mismatch",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests: intoto.DigestSet{
+				"sha256": "val256_different",
+				"sha512": "val512",
+			},
+			org:    org,
+			policy: project,
+		},
+		{
+			name:         "digest mismatch single match",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests: intoto.DigestSet{
+				"sha512": "val512",
+			},
+			org:    org,
+			policy: project,
+		},
+		{
+			name:         "empty digest key",
+			expected:     errs.ErrorInvalidField,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests: intoto.DigestSet{
+				"sha256": "val256",
+				"sha512": "",
+			},
+			org:    org,
+			policy: project,
+		},
+		{
+			name:         "no verifier",
+			expected:     errs.ErrorInvalidInput,
+			noVerifier:   true,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org:          org,
+			policy:       project,
+		},
+		{
+			name:         "package name not present",
+			expected:     errs.ErrorNotFound,
+			verifierOpts: vopts,
+			packageName:  packageName1 + "_not",
+			digests:      digests,
+			org:          org,
+			policy:       project,
+		},
+		{
+			name:         "root levels too low",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org: organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: publishrID1,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(1),
+							},
+						},
+						{
+							ID: publishrID2,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(1),
+							},
+						},
+					},
+				},
+			},
+			policy: project,
+		},
+		{
+			name:     "env mismatch",
+			expected: errs.ErrorVerification,
+			verifierOpts: dummyVerifierOpts{
+				publishrID:  publishrID2,
+				packageName: "package_name",
+				env:         "staging",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      project,
+		},
+		{
+			name:         "root allowed principal",
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org: organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: publishrID1,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+						{
+							ID: publishrID2,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(2),
+							},
+							PrincipalRestrictions: &organization.PrincipalRestrictions{
+								Allow: []string{"other_", "protection_"},
+							},
+						},
+					},
+				},
+			},
+			policy: project,
+		},
+		{
+			name:         "root not allowed principal",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org: organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: publishrID1,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+						{
+							ID: publishrID2,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(2),
+							},
+							PrincipalRestrictions: &organization.PrincipalRestrictions{
+								Allow: []string{"other_"},
+							},
+						},
+					},
+				},
+			},
+			policy: project,
+		},
+		{
+			name:         "root denied principal",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org: organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: publishrID1,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+						},
+						{
+							ID: publishrID2,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(2),
+							},
+							PrincipalRestrictions: &organization.PrincipalRestrictions{
+								Deny: []string{"protection_"},
+							},
+						},
+					},
+				},
+			},
+			policy: project,
+		},
+		{
+			name:         "other root denied principal",
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org: organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: publishrID1,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(3),
+							},
+							PrincipalRestrictions: &organization.PrincipalRestrictions{
+								Deny: []string{"protection_"},
+							},
+						},
+						{
+							ID: publishrID2,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(2),
+							},
+						},
+					},
+				},
+			},
+			policy: project,
+		},
+		{
+			name:         "environment principal",
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org:          org,
+			policy:       envProject,
+			principal: &Principal{
+				URI: "deployer-prod",
+			},
+		},
+		{
+			name: "default principal",
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID2,
+				packageName: packageName1,
+				buildLevel:  buildLevel,
+				env:         "staging",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      envProject,
+			principal: &Principal{
+				URI: "deployer-default",
+			},
+		},
+		{
+			name:         "pinned publish root",
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org:          org,
+			policy: Policy{
+				Principal:         project.Principal,
+				BuildRequirements: project.BuildRequirements,
+				Packages: []Package{
+					{
+						Name: packageName1,
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+						PublishRoots: []string{publishrID2},
+					},
+				},
+			},
+		},
+		{
+			name:         "pinned other publish root",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org:          org,
+			policy: Policy{
+				Principal:         project.Principal,
+				BuildRequirements: project.BuildRequirements,
+				Packages: []Package{
+					{
+						Name: packageName1,
+						Environment: Environment{
+							AnyOf: []string{"dev", "prod"},
+						},
+						PublishRoots: []string{publishrID1},
+					},
+				},
+			},
+		},
+		{
+			name:         "root not allowed environment principal",
+			expected:     errs.ErrorVerification,
+			verifierOpts: vopts,
+			packageName:  packageName1,
+			digests:      digests,
+			org: organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: publishrID2,
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(2),
+							},
+							PrincipalRestrictions: &organization.PrincipalRestrictions{
+								Allow: []string{"deployer-dev"},
+							},
+						},
+					},
+				},
+			},
+			policy: envProject,
+		},
+		{
+			name: "wildcard environment principal",
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID2,
+				packageName: packageName1,
+				buildLevel:  buildLevel,
+				env:         "prod/eu-west4",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      wildcardProject,
+			principal: &Principal{
+				URI: "deployer-eu",
+			},
+		},
+		{
+			name:     "wildcard environment mismatch",
+			expected: errs.ErrorVerification,
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID2,
+				packageName: packageName1,
+				buildLevel:  buildLevel,
+				env:         "staging/us-east1",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      wildcardProject,
+		},
+		{
+			name: "environment level",
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID2,
+				packageName: packageName1,
+				buildLevel:  2,
+				env:         "dev",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      levelProject,
+			level:       2,
+		},
+		{
+			name: "stricter environment level",
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID1,
+				packageName: packageName1,
+				buildLevel:  3,
+				env:         "prod",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      levelProject,
+			level:       3,
+		},
+		{
+			name:     "stricter environment level not verified",
+			expected: errs.ErrorVerification,
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID1,
+				packageName: packageName1,
+				buildLevel:  2,
+				env:         "prod",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      levelProject,
+		},
+		{
+			name:     "stricter environment level above root max level",
+			expected: errs.ErrorVerification,
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID2,
+				packageName: packageName1,
+				buildLevel:  3,
+				env:         "prod",
+			},
+			packageName: packageName1,
+			digests:     digests,
+			org:         org,
+			policy:      levelProject,
+		},
+		{
+			name: "package level",
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID1,
+				packageName: packageName2,
+				buildLevel:  3,
+				env:         "dev",
+			},
+			packageName: packageName2,
+			digests:     digests,
+			org:         org,
+			policy:      levelProject,
+			level:       3,
+		},
+		{
+			name:     "package level not verified",
+			expected: errs.ErrorVerification,
+			verifierOpts: dummyVerifierOpts{
+				digests:     digests,
+				publishrID:  publishrID1,
+				packageName: packageName2,
+				buildLevel:  2,
+				env:         "dev",
+			},
+			packageName: packageName2,
+			digests:     digests,
+			org:         org,
+			policy:      levelProject,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// Create the verifier that succeeds for the right parameters.
+			var verifier options.AttestationVerifier
+			if !tt.noVerifier {
+				verifier = common.NewAttestationVerifier(tt.verifierOpts.digests, tt.packageName,
+					tt.verifierOpts.env, tt.verifierOpts.publishrID, tt.verifierOpts.buildLevel)
+			}
+			opts := options.PublishVerification{
+				Verifier: verifier,
+			}
+			result, err := tt.policy.Evaluate(tt.digests, tt.packageName, tt.org, opts, time.Now())
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			principal := project.Principal
+			if tt.principal != nil {
+				principal = *tt.principal
+			}
+			if diff := cmp.Diff(principal, result.Principal); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.verifierOpts.publishrID, result.PublishRootID); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.level != 0 {
+				if diff := cmp.Diff(tt.level, result.BuildLevel); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_levelGroups(t *testing.T) {
+	t.Parallel()
+	policy := Policy{
+		Principal: Principal{
+			URI: "deployer-default",
+			Environments: map[string]string{
+				"prod/us-east1": "deployer-us",
+				"prod/eu-west4": "deployer-eu",
+			},
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(2),
+			Environments: map[string]int{
+				"prod/us-east1": 3,
+				"staging":       4,
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		pkg      Package
+		expected []levelGroup
+	}{
+		{
+			name: "no environment",
+			expected: []levelGroup{
+				{level: 2},
+			},
+		},
+		{
+			name: "no environment package level",
+			pkg: Package{
+				BuildRequirements: &PackageBuildRequirements{
+					RequireSlsaLevel: common.AsPointer(3),
+				},
+			},
+			expected: []levelGroup{
+				{level: 3},
+			},
+		},
+		{
+			name: "exact environments",
+			pkg: Package{
+				Environment: Environment{
+					AnyOf: []string{"dev", "staging", "prod/us-east1"},
+				},
+			},
+			expected: []levelGroup{
+				{level: 4, envs: []string{"staging"}},
+				{level: 3, envs: []string{"prod/us-east1"}},
+				{level: 2, envs: []string{"dev"}},
+			},
+		},
+		{
+			name: "wildcard environment",
+			pkg: Package{
+				Environment: Environment{
+					AnyOf: []string{"prod/*"},
+				},
+			},
+			expected: []levelGroup{
+				{level: 3, envs: []string{"prod/us-east1"}},
+				{level: 2, envs: []string{"prod/eu-west4", "prod/*"}},
+			},
+		},
+		{
+			name: "package level higher than environment level",
+			pkg: Package{
+				Environment: Environment{
+					AnyOf: []string{"dev", "prod/us-east1"},
+				},
+				BuildRequirements: &PackageBuildRequirements{
+					RequireSlsaLevel: common.AsPointer(3),
+				},
+			},
+			expected: []levelGroup{
+				{level: 3, envs: []string{"dev", "prod/us-east1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			groups := policy.levelGroups(&tt.pkg, policy.Principal.candidates(tt.pkg.Environment.AnyOf))
+			if diff := cmp.Diff(tt.expected, groups, cmp.AllowUnexported(levelGroup{})); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromReaders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		policies      []Policy
+		maxBuildLevel int
+		buggyIterator bool
+		expected      error
+	}{
+		{
+			name:          "two valid policies",
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name2",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name:          "passing assertions",
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.require_slsa_level", Operator: "gte", Value: 3},
+						{Path: "packages.0.environment.any_of", Operator: "contains", Value: "prod"},
+					},
+				},
+			},
+		},
+		{
+			name:          "failing assertion",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.require_slsa_level", Operator: "lte", Value: 2},
+					},
+				},
+			},
+		},
+		{
+			name:          "assertion with unknown operator",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.require_slsa_level", Operator: "gt", Value: 2},
+					},
+				},
+			},
+		},
+		{
+			name:          "assertion with unresolvable path",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+					Assertions: []assertions.Assertion{
+						{Path: "packages.1.name", Operator: "eq", Value: "package_name"},
+					},
+				},
+			},
+		},
+		{
+			name:          "known publish root",
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name:         "package_name",
+							PublishRoots: []string{"root_id2"},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name:          "unknown publish root",
 			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: -1,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name:         "package_name",
+							PublishRoots: []string{"root_id3"},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name:          "same principal URI",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name:          "same environment principal URI",
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+						Environments: map[string]string{
+							"prod": "protection_name_prod",
+						},
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name2",
+						Environments: map[string]string{
+							"dev": "protection_name_prod",
+						},
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name:          "same iterator id",
+			buggyIterator: true,
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name2",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+		{
+			name:          "one policy same package name",
+			buggyIterator: true,
+			expected:      errs.ErrorInvalidField,
+			maxBuildLevel: 3,
+			policies: []Policy{
+				{
+					Format: 1,
+					Principal: Principal{
+						URI: "protection_name",
+					},
+					Packages: []Package{
+						{
+							Name: "package_name",
+							Environment: Environment{
+								AnyOf: []string{"dev", "prod"},
+							},
+						},
+						{
+							Name: "package_name",
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Create the org policy (only the maxBuildLevel and IDs are needed).
+			orgPolicy := organization.Policy{
+				Roots: organization.Roots{
+					Publish: []organization.Root{
+						{
+							ID: "root_id1",
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(tt.maxBuildLevel - 1),
+							},
+						},
+						{
+							ID: "root_id2",
+							Build: organization.Build{
+								MaxSlsaLevel: common.AsPointer(tt.maxBuildLevel),
+							},
+						},
+					},
+				},
+			}
+
+			// Marshal the project policies into bytes.
+			policies := make([][]byte, len(tt.policies), len(tt.policies))
+			for i := range tt.policies {
+				content, err := json.Marshal(tt.policies[i])
+				if err != nil {
+					t.Fatalf("failed to marshal: %v", err)
+				}
+				policies[i] = content
+			}
+			// Create the project iterator.
+			iter := common.NewNamedBytesIterator(policies, !tt.buggyIterator)
+
+			// Call the constructor.
+			_, err := FromReaders(iter, orgPolicy, nil)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Same policy with a passing validator.
+			iter = common.NewNamedBytesIterator(policies, !tt.buggyIterator)
+			_, err = FromReaders(iter, orgPolicy, common.NewPolicyValidator(true))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			// Same policy with a failing validator.
+			iter = common.NewNamedBytesIterator(policies, !tt.buggyIterator)
+			_, err = FromReaders(iter, orgPolicy, common.NewPolicyValidator(false))
+			if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromReadersAllErrors(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "root_id1",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	valid := Policy{
+		Format: 1,
+		Principal: Principal{
+			URI: "principal_uri",
+		},
+		Packages: []Package{
+			{
+				Name: "package_name",
+			},
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(3),
+		},
+	}
+	projects := []Policy{
+		// Invalid format and empty package name.
+		{
+			Principal: Principal{
+				URI: "principal_uri1",
+			},
+			Packages: []Package{
+				{},
+			},
+			BuildRequirements: BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(3),
+			},
+		},
+		valid,
+		// Principal re-use.
+		valid,
+	}
+	policies := make([][]byte, len(projects))
+	for i := range projects {
+		content, err := json.Marshal(projects[i])
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	_, err := FromReaders(common.NewNamedBytesIterator(policies, true), orgPolicy, nil)
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	for _, want := range []string{"policy_id0: ", `"/format"`, `"/packages/0/name"`, "policy_id2: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error (%q) does not contain (%q)", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "policy_id1: ") {
+		t.Fatalf("error (%q) reports a valid policy", err)
+	}
+	// Each violation is reported separately.
+	if diff := cmp.Diff(3, len(errs.Violations(err))); diff != "" {
+		t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+	}
+}
+
+func Test_FromReadersFieldPointer(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "root_id1",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		policy  Policy
+		pointer string
+	}{
+		{
+			name: "nested environment",
+			policy: Policy{
+				Format: 1,
+				Principal: Principal{
+					URI: "principal_uri",
+				},
+				Packages: []Package{
+					{
+						Name: "package_name1",
+						Environment: Environment{
+							AnyOf: []string{"dev"},
+						},
+					},
+					{
+						Name: "package_name2",
+						Environment: Environment{
+							AnyOf: []string{"prod//eu"},
+						},
+					},
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(3),
+				},
+			},
+			pointer: `"/packages/1/environment/any_of/0"`,
+		},
+		{
+			name: "escaped principal environment",
+			policy: Policy{
+				Format: 1,
+				Principal: Principal{
+					Environments: map[string]string{
+						"prod//eu": "principal_uri",
+					},
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: []string{"prod//eu"},
+						},
+					},
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(3),
+				},
+			},
+			pointer: `"/principal/environments/prod~1~1eu"`,
+		},
+		{
+			name: "build level",
 			policy: Policy{
+				Format: 1,
+				Principal: Principal{
+					URI: "principal_uri",
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+					},
+				},
 				BuildRequirements: BuildRequirements{
 					RequireSlsaLevel: common.AsPointer(4),
 				},
 			},
+			pointer: `"/build/require_slsa_level"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content, err := json.Marshal(tt.policy)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			_, err = FromReaders(common.NewNamedBytesIterator([][]byte{content}, true), orgPolicy, nil)
+			if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if !strings.Contains(err.Error(), tt.pointer) {
+				t.Fatalf("error (%q) does not contain (%q)", err, tt.pointer)
+			}
+		})
+	}
+}
+
+// newPolicies returns n valid policy files. If invalid is set, every
+// tenth policy has an invalid format and every tenth policy re-uses the
+// principal of the previous one.
+func newPolicies(t testing.TB, n int, invalid bool) [][]byte {
+	policies := make([][]byte, n)
+	for i := range policies {
+		project := Policy{
+			Format: 1,
+			Principal: Principal{
+				URI: fmt.Sprintf("principal_uri%d", i),
+			},
+			Packages: []Package{
+				{
+					Name: fmt.Sprintf("package_name%d", i),
+					Environment: Environment{
+						AnyOf: []string{"dev", "prod"},
+					},
+				},
+			},
+			BuildRequirements: BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(3),
+			},
+		}
+		if invalid && i%10 == 3 {
+			project.Format = 0
+		}
+		if invalid && i%10 == 7 {
+			project.Principal.URI = fmt.Sprintf("principal_uri%d", i-1)
+		}
+		content, err := json.Marshal(project)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	return policies
+}
+
+func Test_FromReadersConcurrent(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "root_id1",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	valid := newPolicies(t, 100, false)
+	invalid := newPolicies(t, 100, true)
+	want, err := FromReaders(common.NewNamedBytesIterator(valid, true), orgPolicy, nil, options.WithWorkers(1))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	_, wantErr := FromReaders(common.NewNamedBytesIterator(invalid, true), orgPolicy, nil, options.WithWorkers(1))
+	if diff := cmp.Diff(errs.ErrorInvalidField, wantErr, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	tests := []struct {
+		name    string
+		workers int
+	}{
+		{
+			name:    "two workers",
+			workers: 2,
+		},
+		{
+			name:    "more workers than policies",
+			workers: 200,
+		},
+		{
+			name: "default workers",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// NOTE: the results must not depend on scheduling.
+			for i := 0; i < 10; i++ {
+				got, err := FromReaders(common.NewNamedBytesIterator(valid, true), orgPolicy,
+					common.NewPolicyValidator(true), options.WithWorkers(tt.workers))
+				if err != nil {
+					t.Fatalf("failed to parse: %v", err)
+				}
+				if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(Policy{})); diff != "" {
+					t.Fatalf("unexpected policies (-want +got): \n%s", diff)
+				}
+				_, err = FromReaders(common.NewNamedBytesIterator(invalid, true), orgPolicy, nil,
+					options.WithWorkers(tt.workers))
+				if diff := cmp.Diff(wantErr.Error(), err.Error()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFromReaders(b *testing.B) {
+	orgPolicy := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "root_id1",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	policies := newPolicies(b, 2000, false)
+	benchmarks := []struct {
+		name    string
+		workers int
+	}{
+		{
+			name:    "sequential",
+			workers: 1,
+		},
+		{
+			name:    "concurrent",
+			workers: runtime.GOMAXPROCS(0),
+		},
+	}
+	for _, bb := range benchmarks {
+		bb := bb // Re-initializing variable so it is not changed while executing the closure below
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := FromReaders(common.NewNamedBytesIterator(policies, true), orgPolicy, nil,
+					options.WithWorkers(bb.workers)); err != nil {
+					b.Fatalf("failed to parse: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_acceptedDigests(t *testing.T) {
+	t.Parallel()
+	sha256Only := intoto.DigestSet{
+		"sha256": "val256",
+	}
+	gitCommitOnly := intoto.DigestSet{
+		"gitCommit": "valCommit",
+	}
+	mixed := intoto.DigestSet{
+		"sha256":    "val256",
+		"sha512":    "val512",
+		"gitCommit": "valCommit",
+	}
+	tests := []struct {
+		name       string
+		accepted   []string
+		digests    intoto.DigestSet
+		algorithms []string
+		expected   error
+	}{
+		{
+			name:    "any algorithm",
+			digests: gitCommitOnly,
+		},
+		{
+			name:       "sha256 only accepted",
+			accepted:   []string{"sha256"},
+			digests:    sha256Only,
+			algorithms: []string{"sha256"},
+		},
+		{
+			name:     "git commit only rejected",
+			accepted: []string{"sha256", "sha512"},
+			digests:  gitCommitOnly,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:       "git commit only accepted",
+			accepted:   []string{"gitCommit"},
+			digests:    gitCommitOnly,
+			algorithms: []string{"gitCommit"},
+		},
+		{
+			name:       "mixed accepted",
+			accepted:   []string{"sha512", "sha256"},
+			digests:    mixed,
+			algorithms: []string{"sha256", "sha512"},
+		},
+		{
+			name:     "mixed rejected",
+			accepted: []string{"sha3_256"},
+			digests:  mixed,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "sha256 only rejected",
+			accepted: []string{"gitCommit"},
+			digests:  sha256Only,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pkg := Package{
+				Name:                     "package_name",
+				AcceptedDigestAlgorithms: tt.accepted,
+			}
+			algorithms, err := pkg.acceptedDigests(tt.digests)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.algorithms, algorithms); diff != "" {
+				t.Fatalf("unexpected algorithms (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_validateExceptions(t *testing.T) {
+	t.Parallel()
+	digest := "sha256:" + strings.Repeat("a", 64)
+	other := "sha256:" + strings.Repeat("b", 64)
+	expires := "2024-01-01T00:00:00Z"
+	tests := []struct {
+		name       string
+		principal  Principal
+		exceptions []Exception
+		expected   error
+	}{
+		{
+			name:      "no exceptions",
+			principal: Principal{URI: "principal"},
+		},
+		{
+			name:      "valid exceptions",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionAllow, Reason: "hotfix", Expires: expires},
+				{Digest: other, Decision: ExceptionDeny, Reason: "CVE", Expires: expires},
+			},
+		},
+		{
+			name: "deny without default principal",
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionDeny, Reason: "CVE", Expires: expires},
+			},
+		},
+		{
+			name: "allow without default principal",
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionAllow, Reason: "hotfix", Expires: expires},
+			},
+		},
+		{
+			name:      "sha512 digest",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: "sha512:" + strings.Repeat("a", 128), Decision: ExceptionDeny, Reason: "CVE", Expires: expires},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:      "short digest",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: "sha256:aaaa", Decision: ExceptionDeny, Reason: "CVE", Expires: expires},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:      "duplicate digest",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionDeny, Reason: "CVE", Expires: expires},
+				{Digest: digest, Decision: ExceptionAllow, Reason: "hotfix", Expires: expires},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:      "invalid decision",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: digest, Decision: "maybe", Reason: "CVE", Expires: expires},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:      "empty reason",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionDeny, Expires: expires},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:      "empty expiry",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionDeny, Reason: "CVE"},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:      "invalid expiry",
+			principal: Principal{URI: "principal"},
+			exceptions: []Exception{
+				{Digest: digest, Decision: ExceptionDeny, Reason: "CVE", Expires: "2024-01-01"},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			policy := Policy{
+				Principal:  tt.principal,
+				Exceptions: tt.exceptions,
+			}
+			err := policy.validateExceptions()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_EvaluateExceptions(t *testing.T) {
+	t.Parallel()
+	value := strings.Repeat("a", 64)
+	digests := intoto.DigestSet{
+		"sha256": value,
+	}
+	packageName := "package_name"
+	org := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "publishr_id",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
 		},
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	exception := func(decision, expires string) Exception {
+		return Exception{
+			Digest:   "sha256:" + value,
+			Decision: decision,
+			Reason:   "incident",
+			Expires:  expires,
+		}
+	}
+	tests := []struct {
+		name       string
+		exceptions []Exception
+		principal  *Principal
+		verifies   bool
+		allowed    bool
+		uri        string
+		expected   error
+	}{
 		{
-			name:          "large level",
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 5,
-			policy: Policy{
-				BuildRequirements: BuildRequirements{
-					RequireSlsaLevel: common.AsPointer(4),
-				},
-			},
+			name:     "no exception",
+			verifies: true,
 		},
 		{
-			name:          "negative policy level",
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 3,
-			policy: Policy{
-				BuildRequirements: BuildRequirements{
-					RequireSlsaLevel: common.AsPointer(-1),
+			name:       "deny exception",
+			exceptions: []Exception{exception(ExceptionDeny, "2024-01-02T00:00:00Z")},
+			verifies:   true,
+			expected:   errs.ErrorVerification,
+		},
+		{
+			name:       "expired deny exception",
+			exceptions: []Exception{exception(ExceptionDeny, "2024-01-01T00:00:00Z")},
+			verifies:   true,
+		},
+		{
+			name:       "allow exception",
+			exceptions: []Exception{exception(ExceptionAllow, "2024-01-02T00:00:00Z")},
+			allowed:    true,
+			uri:        "principal",
+		},
+		{
+			name:       "allow exception environment principal",
+			exceptions: []Exception{exception(ExceptionAllow, "2024-01-02T00:00:00Z")},
+			principal: &Principal{
+				Environments: map[string]string{
+					"prod": "prod_principal",
 				},
 			},
+			allowed: true,
+			uri:     "prod_principal",
 		},
 		{
-			name:          "large policy level",
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 5,
-			policy: Policy{
+			name:       "expired allow exception",
+			exceptions: []Exception{exception(ExceptionAllow, "2023-12-31T00:00:00Z")},
+			expected:   errs.ErrorVerification,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			principal := Principal{
+				URI: "principal",
+			}
+			if tt.principal != nil {
+				principal = *tt.principal
+			}
+			policy := Policy{
+				Format:    1,
+				Principal: principal,
 				BuildRequirements: BuildRequirements{
-					RequireSlsaLevel: common.AsPointer(5),
+					RequireSlsaLevel: common.AsPointer(2),
 				},
-			},
+				Packages: []Package{
+					{
+						Name: packageName,
+						Environment: Environment{
+							AnyOf: []string{"prod"},
+						},
+					},
+				},
+				Exceptions: tt.exceptions,
+			}
+			if err := policy.validateExceptions(); err != nil {
+				t.Fatalf("failed to validate exceptions: %v", err)
+			}
+			verifierDigests := intoto.DigestSet{"sha256": "other"}
+			if tt.verifies {
+				verifierDigests = digests
+			}
+			opts := options.PublishVerification{
+				Verifier: common.NewAttestationVerifier(verifierDigests, packageName, "prod", "publishr_id", 2),
+			}
+			result, err := policy.Evaluate(digests, packageName, org, opts, now)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if allowed := result.Exception != nil; allowed != tt.allowed {
+				t.Fatalf("unexpected exception: %v", result.Exception)
+			}
+			if tt.allowed && result.Principal.URI != tt.uri {
+				t.Fatalf("unexpected principal: %q", result.Principal.URI)
+			}
+		})
+	}
+}
+
+func Test_EvaluateIdentity(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": strings.Repeat("a", 64),
+	}
+	packageName := "package_name"
+	issuer := "https://token.actions.githubusercontent.com"
+	org, err := organization.FromReader(io.NopCloser(strings.NewReader(`{"format": 1, "roots": {"publish": [
+		{"name": "publishr", "identity": {"issuer": "https://token.actions.githubusercontent.com",
+			"subject_regex": "^https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main$"},
+		"build": {"max_slsa_level": 3}}]}}`)))
+	if err != nil {
+		t.Fatalf("failed to read organization policy: %v", err)
+	}
+	tests := []struct {
+		name     string
+		issuer   string
+		subject  string
+		expected error
+	}{
+		{
+			name:    "matching identity",
+			issuer:  issuer,
+			subject: "https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main",
+		},
+		{
+			name:     "mismatch subject",
+			issuer:   issuer,
+			subject:  "https://github.com/org/other/.github/workflows/publish.yml@refs/heads/main",
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "mismatch issuer",
+			issuer:   "https://accounts.google.com",
+			subject:  "https://github.com/org/publishr/.github/workflows/publish.yml@refs/heads/main",
+			expected: errs.ErrorVerification,
 		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			err := tt.policy.validateBuildRequirements(tt.maxBuildLevel)
+			policy := Policy{
+				Format: 1,
+				Principal: Principal{
+					URI: "principal",
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Packages: []Package{
+					{
+						Name: packageName,
+						Environment: Environment{
+							AnyOf: []string{"prod"},
+						},
+					},
+				},
+			}
+			opts := options.PublishVerification{
+				Verifier: common.NewAttestationVerifierWithIdentity(digests, packageName, "prod", tt.issuer, tt.subject, 3),
+			}
+			result, err := policy.Evaluate(digests, packageName, *org, opts, time.Now())
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff("publishr", result.PublishRootID); diff != "" {
+				t.Fatalf("unexpected publish root (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
 
-func Test_Evaluate(t *testing.T) {
-	t.Parallel()
-	type dummyVerifierOpts struct {
-		digests     intoto.DigestSet
-		buildLevel  int
-		packageName string
-		publishrID  string
-		env         string
+func FuzzFromReaders(f *testing.F) {
+	orgPolicy, rootIDs := randomOrgPolicy()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		content, err := json.Marshal(randomPolicy(r, i, rootIDs, *orgPolicy.DefaultSlsaLevel(), orgPolicy.MaxBuildSlsaLevel()))
+		if err != nil {
+			f.Fatalf("failed to marshal: %v", err)
+		}
+		f.Add(content)
 	}
-	publishrID1 := "publishr_id1"
-	publishrID2 := "publishr_id2"
-	packageName1 := "package_name1"
-	packageName2 := "package_name2"
+	f.Fuzz(func(t *testing.T, content []byte) {
+		policies, err := FromReaders(common.NewNamedBytesIterator([][]byte{content}, true), orgPolicy,
+			common.NewPolicyValidator(true))
+		if err != nil {
+			return
+		}
+		for _, policy := range policies {
+			for i := range policy.Packages {
+				_, _ = policy.PackageEnvironments(policy.Packages[i].Name)
+			}
+		}
+	})
+}
+
+// environmentVerifier returns env for the root it is created for,
+// regardless of the environments requested, and records them.
+type environmentVerifier struct {
+	publishrID string
+	env        *string
+	mu         sync.Mutex
+	requested  [][]string
+}
+
+func (v *environmentVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string, env []string, publishrID string,
+	identity *options.Identity, minBuildLevel int, minAuthorVersion string) (*string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.requested = append(v.requested, env)
+	if publishrID != v.publishrID {
+		return nil, fmt.Errorf("%w: cannot verify publishr ID (%q)", errs.ErrorVerification, publishrID)
+	}
+	return v.env, nil
+}
+
+func Test_EvaluateRootEnvironments(t *testing.T) {
+	t.Parallel()
 	digests := intoto.DigestSet{
 		"sha256": "val256",
-		"sha512": "val512",
 	}
 	org := organization.Policy{
 		Roots: organization.Roots{
 			Publish: []organization.Root{
 				{
-					ID: publishrID1,
+					ID: "dev_root",
 					Build: organization.Build{
 						MaxSlsaLevel: common.AsPointer(3),
 					},
+					Environments: []string{"dev", "staging/us"},
 				},
 				{
-					ID: publishrID2,
+					ID: "prod_root",
 					Build: organization.Build{
-						MaxSlsaLevel: common.AsPointer(2),
+						MaxSlsaLevel: common.AsPointer(3),
 					},
+					Environments: []string{"prod"},
 				},
 			},
 		},
 	}
-	project := Policy{
-		Protection: Protection{
-			GoogleServiceAccount: "protection_name",
-		},
-		BuildRequirements: BuildRequirements{
-			RequireSlsaLevel: common.AsPointer(2),
-		},
-		Packages: []Package{
-			{
-				Name: packageName1,
-				Environment: Environment{
-					AnyOf: []string{"dev", "prod"},
-				},
-			},
-			{
-				Name: packageName2,
-				Environment: Environment{
-					AnyOf: []string{"dev", "prod"},
-				},
-			},
-		},
-	}
-	buildLevel := 3
-	vopts := dummyVerifierOpts{
-		digests:     digests,
-		publishrID:  publishrID2,
-		packageName: packageName1,
-		buildLevel:  buildLevel,
-		env:         "prod",
-	}
 	tests := []struct {
-		name         string
-		policy       Policy
-		org          organization.Policy
-		noVerifier   bool
-		packageName  string
-		digests      intoto.DigestSet
-		verifierOpts dummyVerifierOpts
-		expected     error
+		name       string
+		anyOf      []string
+		publishrID string
+		env        *string
+		requested  [][]string
+		expected   error
 	}{
 		{
-			name:         "passing",
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests:      digests,
-			org:          org,
-			policy:       project,
-		},
-		{
-			name:         "empty digests",
-			expected:     errs.ErrorInvalidField,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			org:          org,
-			policy:       project,
-		},
-		{
-			name:         "empty digest value",
-			expected:     errs.ErrorInvalidField,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests: intoto.DigestSet{
-				"sha256": "val256",
-				"":       "val512",
-			},
-			org:    org,
-			policy: project,
-		},
-		{
-			name:         "digest mismatch",
-			expected:     errs.ErrorVerification,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests: intoto.DigestSet{
-				"sha256": "val256_different",
-				"sha512": "val512",
-			},
-			org:    org,
-			policy: project,
-		},
-		{
-			name:         "digest mismatch single match",
-			expected:     errs.ErrorVerification,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests: intoto.DigestSet{
-				"sha512": "val512",
-			},
-			org:    org,
-			policy: project,
+			name:       "dev root",
+			anyOf:      []string{"dev", "prod"},
+			publishrID: "dev_root",
+			env:        common.AsPointer("dev"),
+			requested:  [][]string{{"dev"}},
 		},
 		{
-			name:         "empty digest key",
-			expected:     errs.ErrorInvalidField,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests: intoto.DigestSet{
-				"sha256": "val256",
-				"sha512": "",
-			},
-			org:    org,
-			policy: project,
+			name:       "prod root",
+			anyOf:      []string{"dev", "prod"},
+			publishrID: "prod_root",
+			env:        common.AsPointer("prod"),
+			requested:  [][]string{{"dev"}, {"prod"}},
 		},
 		{
-			name:         "no verifier",
-			expected:     errs.ErrorInvalidInput,
-			noVerifier:   true,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests:      digests,
-			org:          org,
-			policy:       project,
+			name:       "wildcard expanded to root environments",
+			anyOf:      []string{"staging/*"},
+			publishrID: "dev_root",
+			env:        common.AsPointer("staging/us"),
+			requested:  [][]string{{"staging/us"}},
 		},
 		{
-			name:         "package name not present",
-			expected:     errs.ErrorNotFound,
-			verifierOpts: vopts,
-			packageName:  packageName1 + "_not",
-			digests:      digests,
-			org:          org,
-			policy:       project,
+			name:       "no root trusted for environments",
+			anyOf:      []string{"test"},
+			publishrID: "dev_root",
+			env:        common.AsPointer("test"),
+			expected:   errs.ErrorVerification,
 		},
 		{
-			name:         "root levels too low",
-			expected:     errs.ErrorVerification,
-			verifierOpts: vopts,
-			packageName:  packageName1,
-			digests:      digests,
-			org: organization.Policy{
-				Roots: organization.Roots{
-					Publish: []organization.Root{
-						{
-							ID: publishrID1,
-							Build: organization.Build{
-								MaxSlsaLevel: common.AsPointer(1),
-							},
-						},
-						{
-							ID: publishrID2,
-							Build: organization.Build{
-								MaxSlsaLevel: common.AsPointer(1),
-							},
-						},
-					},
-				},
-			},
-			policy: project,
+			name:       "verified environment not trusted by root",
+			anyOf:      []string{"dev", "prod"},
+			publishrID: "dev_root",
+			env:        common.AsPointer("prod"),
+			requested:  [][]string{{"dev"}, {"prod"}},
+			expected:   errs.ErrorVerification,
 		},
 		{
-			name:     "env mismatch",
-			expected: errs.ErrorVerification,
-			verifierOpts: dummyVerifierOpts{
-				publishrID:  publishrID2,
-				packageName: "package_name",
-				env:         "staging",
-			},
-			packageName: packageName1,
-			digests:     digests,
-			org:         org,
-			policy:      project,
+			name:       "no environment",
+			publishrID: "dev_root",
+			expected:   errs.ErrorVerification,
 		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			// Create the verifier that succeeds for the right parameters.
-			var verifier options.AttestationVerifier
-			if !tt.noVerifier {
-				verifier = common.NewAttestationVerifier(tt.verifierOpts.digests, tt.packageName,
-					tt.verifierOpts.env, tt.verifierOpts.publishrID, tt.verifierOpts.buildLevel)
+			policy := Policy{
+				Principal: Principal{
+					URI: "principal_uri",
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: tt.anyOf,
+						},
+					},
+				},
 			}
+			verifier := &environmentVerifier{publishrID: tt.publishrID, env: tt.env}
 			opts := options.PublishVerification{
 				Verifier: verifier,
 			}
-			protection, err := tt.policy.Evaluate(tt.digests, tt.packageName, tt.org, opts)
+			result, err := policy.Evaluate(digests, "package_name", org, opts, time.Now())
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if diff := cmp.Diff(tt.requested, verifier.requested); diff != "" {
+				t.Fatalf("unexpected requested environments (-want +got): \n%s", diff)
+			}
 			if err != nil {
 				return
 			}
-			if diff := cmp.Diff(*protection, project.Protection); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			if diff := cmp.Diff(tt.publishrID, result.PublishRootID); diff != "" {
+				t.Fatalf("unexpected root (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.env, result.Environment); diff != "" {
+				t.Fatalf("unexpected environment (-want +got): \n%s", diff)
 			}
 		})
 	}
 }
 
-func Test_FromReaders(t *testing.T) {
+func Test_validateRootEnvironments(t *testing.T) {
 	t.Parallel()
-
+	roots := []organization.Root{
+		{
+			ID:           "dev_root",
+			Environments: []string{"dev", "staging/us"},
+		},
+		{
+			ID:           "prod_root",
+			Environments: []string{"prod"},
+		},
+	}
+	unrestricted := append(roots, organization.Root{ID: "any_root"})
 	tests := []struct {
-		name          string
-		policies      []Policy
-		maxBuildLevel int
-		buggyIterator bool
-		expected      error
+		name         string
+		roots        []organization.Root
+		anyOf        []string
+		publishRoots []string
+		expected     error
 	}{
 		{
-			name:          "two valid policies",
-			maxBuildLevel: 3,
-			policies: []Policy{
-				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
+			name:  "all environments covered",
+			roots: roots,
+			anyOf: []string{"dev", "prod"},
+		},
+		{
+			name:  "wildcard covered",
+			roots: roots,
+			anyOf: []string{"staging/*"},
+		},
+		{
+			name:     "environment not covered",
+			roots:    roots,
+			anyOf:    []string{"dev", "test"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:  "environment covered by unrestricted root",
+			roots: unrestricted,
+			anyOf: []string{"dev", "test"},
+		},
+		{
+			name:         "environment covered by root not allowed by package",
+			roots:        roots,
+			anyOf:        []string{"prod"},
+			publishRoots: []string{"dev_root"},
+			expected:     errs.ErrorInvalidField,
+		},
+		{
+			name:     "no environment",
+			roots:    roots,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:  "no environment unrestricted root",
+			roots: unrestricted,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			policy := Policy{
+				Packages: []Package{
+					{
+						Name: "package_name",
+						Environment: Environment{
+							AnyOf: tt.anyOf,
 						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
+						PublishRoots: tt.publishRoots,
 					},
 				},
+			}
+			err := policy.validateRootEnvironments(tt.roots)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromReadersExtends(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{
+		Roots: organization.Roots{
+			Publish: []organization.Root{
 				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name2",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
-						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
+					ID: "root_id1",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
 					},
 				},
 			},
 		},
+	}
+	base := `base: true
+format: 1
+build:
+  require_slsa_level: 2
+`
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		levels      map[string]int
+		errContains []string
+		err         error
+	}{
 		{
-			name:          "same protection name",
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 3,
-			policies: []Policy{
-				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
-						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
-					},
-				},
-				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
-						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
-					},
-				},
+			name: "shared base",
+			files: fstest.MapFS{
+				"projects/base.yml": {Data: []byte(base)},
+				"projects/app1.json": {Data: []byte(`{"extends": "base.yml", "principal": {"uri": "principal_uri1"},
+					"packages": [{"name": "package_name1"}]}`)},
+				"projects/app2.json": {Data: []byte(`{"extends": "base.yml", "principal": {"uri": "principal_uri2"},
+					"packages": [{"name": "package_name2"}], "build": {"require_slsa_level": 3}}`)},
+			},
+			levels: map[string]int{
+				"projects/app1.json": 2,
+				"projects/app2.json": 3,
 			},
 		},
 		{
-			name:          "same iterator id",
-			buggyIterator: true,
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 3,
-			policies: []Policy{
-				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
-						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
-					},
-				},
-				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name2",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
-						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
-					},
-				},
+			name: "packages replaced",
+			files: fstest.MapFS{
+				"projects/base.json": {Data: []byte(`{"base": true, "format": 1, "principal": {"uri": "principal_uri1"},
+					"packages": [{"name": "package_name1"}], "build": {"require_slsa_level": 2}}`)},
+				"projects/app.json": {Data: []byte(`{"extends": "base.json", "principal": {"uri": "principal_uri2"},
+					"packages": [{"name": "package_name2"}]}`)},
+			},
+			levels: map[string]int{
+				"projects/app.json": 2,
 			},
 		},
 		{
-			name:          "one policy same package name",
-			buggyIterator: true,
-			expected:      errs.ErrorInvalidField,
-			maxBuildLevel: 3,
-			policies: []Policy{
-				{
-					Format: 1,
-					Protection: Protection{
-						GoogleServiceAccount: "protection_name",
-					},
-					Packages: []Package{
-						{
-							Name: "package_name",
-							Environment: Environment{
-								AnyOf: []string{"dev", "prod"},
-							},
-						},
-						{
-							Name: "package_name",
-						},
-					},
-					BuildRequirements: BuildRequirements{
-						RequireSlsaLevel: common.AsPointer(3),
-					},
-				},
+			name: "invalid merged policy",
+			files: fstest.MapFS{
+				"projects/base.yml": {Data: []byte(base)},
+				"projects/app.json": {Data: []byte(`{"extends": "base.yml", "principal": {"uri": "principal_uri"},
+					"packages": [{"name": "package_name"}], "build": {"require_slsa_level": 4}}`)},
+			},
+			errContains: []string{`projects/app.json (extends "projects/base.yml"): `},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "cycle",
+			files: fstest.MapFS{
+				"projects/app.json": {Data: []byte(`{"extends": "app.json", "principal": {"uri": "principal_uri"}}`)},
 			},
+			errContains: []string{"projects/app.json -> projects/app.json"},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid extends",
+			files: fstest.MapFS{
+				"projects/app.json": {Data: []byte(`{"extends": ["base.yml"], "principal": {"uri": "principal_uri"}}`)},
+			},
+			errContains: []string{"projects/app.json: "},
+			err:         errs.ErrorInvalidField,
 		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-
-			// Create the org policy (only the maxBuildLevel is needed).
-			orgPolicy := organization.Policy{
-				Roots: organization.Roots{
-					Publish: []organization.Root{
-						{
-							Build: organization.Build{
-								MaxSlsaLevel: common.AsPointer(tt.maxBuildLevel - 1),
-							},
-						},
-						{
-							Build: organization.Build{
-								MaxSlsaLevel: common.AsPointer(tt.maxBuildLevel),
-							},
-						},
-					},
-				},
-			}
-
-			// Marshal the project policies into bytes.
-			policies := make([][]byte, len(tt.policies), len(tt.policies))
-			for i := range tt.policies {
-				content, err := json.Marshal(tt.policies[i])
-				if err != nil {
-					t.Fatalf("failed to marshal: %v", err)
-				}
-				policies[i] = content
+			iter, err := files.NewFSPolicyIterator(tt.files, "projects/*")
+			if err != nil {
+				t.Fatalf("failed to create iterator: %v", err)
 			}
-			// Create the project iterator.
-			iter := common.NewNamedBytesIterator(policies, !tt.buggyIterator)
-
-			// Call the constructor.
-			_, err := FromReaders(iter, orgPolicy, nil)
-			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+			policies, err := FromReaders(iter, orgPolicy, nil)
+			if diff := cmp.Diff(tt.err, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
-			// Same policy with a passing validator.
-			iter = common.NewNamedBytesIterator(policies, !tt.buggyIterator)
-			_, err = FromReaders(iter, orgPolicy, common.NewPolicyValidator(true))
-			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			for _, want := range tt.errContains {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("error (%q) does not contain (%q)", err, want)
+				}
 			}
 			if err != nil {
 				return
 			}
-			// Same policy with a failing validator.
-			iter = common.NewNamedBytesIterator(policies, !tt.buggyIterator)
-			_, err = FromReaders(iter, orgPolicy, common.NewPolicyValidator(false))
-			if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
-				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			levels := make(map[string]int)
+			for id, policy := range policies {
+				levels[id] = *policy.BuildRequirements.RequireSlsaLevel
+			}
+			if diff := cmp.Diff(tt.levels, levels); diff != "" {
+				t.Fatalf("unexpected levels (-want +got): \n%s", diff)
 			}
 		})
 	}
@@ -0,0 +1,156 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/assertions"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+)
+
+// subset returns a random non-empty subset of values.
+func subset(r *rand.Rand, values []string) []string {
+	var result []string
+	for _, i := range r.Perm(len(values))[:1+r.Intn(len(values))] {
+		result = append(result, values[i])
+	}
+	return result
+}
+
+// randomPolicy returns a random valid policy number i, given
+// the publish roots and the levels of the org policy.
+func randomPolicy(r *rand.Rand, i int, rootIDs []string, defaultLevel, maxLevel int) Policy {
+	policy := Policy{
+		Format: 1,
+	}
+	envs := []string{"dev", "prod", "staging/*"}
+	switch r.Intn(3) {
+	case 0:
+		policy.Principal.URI = fmt.Sprintf("principal_uri%d", i)
+	case 1:
+		policy.Principal.URI = fmt.Sprintf("principal_uri%d", i)
+		policy.Principal.Environments = PrincipalEnvironments{
+			"prod": fmt.Sprintf("principal_uri%d_prod", i),
+		}
+	default:
+		// NOTE: every environment must resolve to a principal.
+		envs = []string{"dev", "prod"}
+		policy.Principal.Environments = PrincipalEnvironments{
+			"dev":  fmt.Sprintf("principal_uri%d_dev", i),
+			"prod": fmt.Sprintf("principal_uri%d_prod", i),
+		}
+	}
+	if r.Intn(2) == 0 {
+		policy.Principal.Scopes = map[string]string{
+			"example.com/team": "infra",
+		}
+	}
+	// NOTE: the default level of the org policy applies if none is set.
+	level := defaultLevel
+	if r.Intn(3) > 0 {
+		level = r.Intn(maxLevel + 1)
+		policy.BuildRequirements.RequireSlsaLevel = common.AsPointer(level)
+	}
+	for j := 0; j < 1+r.Intn(3); j++ {
+		pkg := Package{
+			Name: fmt.Sprintf("package_name%d_%d", i, j),
+		}
+		if policy.Principal.URI == "" || r.Intn(2) == 0 {
+			pkg.Environment.AnyOf = subset(r, envs)
+		}
+		if r.Intn(2) == 0 {
+			pkg.PublishRoots = subset(r, rootIDs)
+		}
+		if r.Intn(2) == 0 {
+			pkg.AcceptedDigestAlgorithms = subset(r, []string{"sha256", "sha512"})
+		}
+		if r.Intn(2) == 0 {
+			pkg.BuildRequirements = &PackageBuildRequirements{
+				RequireSlsaLevel: common.AsPointer(level + r.Intn(maxLevel-level+1)),
+			}
+		}
+		policy.Packages = append(policy.Packages, pkg)
+	}
+	if r.Intn(2) == 0 {
+		policy.BuildRequirements.Environments = map[string]int{
+			"dev": level + r.Intn(maxLevel-level+1),
+		}
+		policy.Packages[0].Environment.AnyOf = append(policy.Packages[0].Environment.AnyOf, "dev")
+		if policy.Principal.URI == "" {
+			policy.Packages[0].Environment.AnyOf = []string{"dev"}
+		}
+	}
+	if r.Intn(2) == 0 {
+		policy.Exceptions = []Exception{
+			{
+				Digest:   fmt.Sprintf("sha256:%064x", r.Int63()),
+				Decision: ExceptionDeny,
+				Reason:   "incident",
+				Expires:  "2030-01-02T15:04:05Z",
+			},
+		}
+	}
+	switch r.Intn(3) {
+	case 0:
+		policy.CustomRules = &CustomRules{Module: "package rules"}
+	case 1:
+		policy.CustomRules = &CustomRules{Path: "rules.rego"}
+	}
+	if r.Intn(2) == 0 {
+		policy.Assertions = []assertions.Assertion{
+			{Path: "packages.0.name", Operator: assertions.OperatorEq, Value: policy.Packages[0].Name},
+		}
+	}
+	return policy
+}
+
+// randomOrgPolicy returns the org policy the random
+// policies are created for, and its publish root IDs.
+func randomOrgPolicy() (organization.Policy, []string) {
+	orgPolicy := organization.Policy{
+		Format: 1,
+		Defaults: &organization.Defaults{
+			Build: organization.DefaultBuild{
+				RequireSlsaLevel: common.AsPointer(2),
+			},
+		},
+	}
+	var rootIDs []string
+	for i := 0; i < 3; i++ {
+		rootIDs = append(rootIDs, fmt.Sprintf("root_id%d", i))
+		orgPolicy.Roots.Publish = append(orgPolicy.Roots.Publish, organization.Root{
+			ID: rootIDs[i],
+			Build: organization.Build{
+				MaxSlsaLevel: common.AsPointer(i + 1),
+			},
+		})
+	}
+	return orgPolicy, rootIDs
+}
+
+func Test_SchemaAgreement(t *testing.T) {
+	t.Parallel()
+	if _, err := schema.Compile(Schema()); err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	orgPolicy, rootIDs := randomOrgPolicy()
+	r := rand.New(rand.NewSource(1))
+	policies := make([][]byte, 200)
+	for i := range policies {
+		content, err := json.Marshal(randomPolicy(r, i, rootIDs, *orgPolicy.DefaultSlsaLevel(), orgPolicy.MaxBuildSlsaLevel()))
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if err := policySchema.Validate(content, false); err != nil {
+			t.Fatalf("schema rejects policy (%s): %v", content, err)
+		}
+		policies[i] = content
+	}
+	if _, err := FromReaders(common.NewNamedBytesIterator(policies, true), orgPolicy, nil); err != nil {
+		t.Fatalf("failed to create policies: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"slices"
+)
+
+// Stats contains statistics about the policy.
+type Stats struct {
+	Projects                   int
+	PackagesPerProject         map[int]int
+	Environments               []string
+	PackagesWithoutEnvironment int
+	Levels                     map[int]int
+	ReferencedRoots            []string
+	UnreferencedRoots          []string
+}
+
+// Stats computes statistics about the policy.
+func (p *Policy) Stats() Stats {
+	stats := Stats{
+		Projects:           len(p.projectPolicies),
+		PackagesPerProject: make(map[int]int),
+		Levels:             make(map[int]int),
+		Environments:       []string{},
+		ReferencedRoots:    []string{},
+		UnreferencedRoots:  []string{},
+	}
+	referenced := make(map[string]bool)
+	for _, projectPolicy := range p.projectPolicies {
+		stats.PackagesPerProject[len(projectPolicy.Packages)]++
+		for i := range projectPolicy.Packages {
+			pkg := &projectPolicy.Packages[i]
+			env := pkg.Environment.AnyOf
+			if len(env) == 0 {
+				stats.PackagesWithoutEnvironment++
+			}
+			for _, e := range env {
+				if !slices.Contains(stats.Environments, e) {
+					stats.Environments = append(stats.Environments, e)
+				}
+			}
+			stats.Levels[*projectPolicy.BuildRequirements.RequireSlsaLevel]++
+		}
+		// A root is referenced if it may authorize at least one
+		// of the project's packages, see project.Evaluate().
+		for i := range p.orgPolicy.Roots.Publish {
+			root := &p.orgPolicy.Roots.Publish[i]
+			if projectPolicy.CanUseRoot(root) {
+				referenced[root.Key()] = true
+			}
+		}
+	}
+	for i := range p.orgPolicy.Roots.Publish {
+		root := &p.orgPolicy.Roots.Publish[i]
+		if referenced[root.Key()] {
+			stats.ReferencedRoots = append(stats.ReferencedRoots, root.Key())
+			continue
+		}
+		stats.UnreferencedRoots = append(stats.UnreferencedRoots, root.Key())
+	}
+	slices.Sort(stats.Environments)
+	slices.Sort(stats.ReferencedRoots)
+	slices.Sort(stats.UnreferencedRoots)
+	return stats
+}
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+)
+
+func Test_Stats(t *testing.T) {
+	t.Parallel()
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: "root_level3",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					ID: "root_level2",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(2),
+					},
+				},
+				{
+					ID: "root_deny_prod",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(4),
+					},
+					PrincipalRestrictions: &organization.PrincipalRestrictions{
+						Deny: []string{"sa-prod"},
+					},
+				},
+				{
+					ID: "root_allow_other",
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(4),
+					},
+					PrincipalRestrictions: &organization.PrincipalRestrictions{
+						Allow: []string{"other"},
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		projects []project.Policy
+		expected Stats
+	}{
+		{
+			name: "default principal usable by restricted root",
+			projects: []project.Policy{
+				{
+					Format: 1,
+					Principal: project.Principal{
+						URI: "sa-default",
+						Environments: map[string]string{
+							"prod": "sa-prod",
+						},
+					},
+					Packages: []project.Package{
+						{
+							Name: "package_name1",
+							Environment: project.Environment{
+								AnyOf: []string{"prod"},
+							},
+						},
+						{
+							Name: "package_name2",
+						},
+					},
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+				{
+					Format: 1,
+					Principal: project.Principal{
+						URI: "sa2",
+					},
+					Packages: []project.Package{
+						{
+							Name: "package_name3",
+							Environment: project.Environment{
+								AnyOf: []string{"prod", "dev"},
+							},
+						},
+					},
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+			expected: Stats{
+				Projects: 2,
+				PackagesPerProject: map[int]int{
+					1: 1,
+					2: 1,
+				},
+				Environments:               []string{"dev", "prod"},
+				PackagesWithoutEnvironment: 1,
+				Levels: map[int]int{
+					3: 3,
+				},
+				ReferencedRoots:   []string{"root_deny_prod", "root_level3"},
+				UnreferencedRoots: []string{"root_allow_other", "root_level2"},
+			},
+		},
+		{
+			name: "all principals denied by restricted root",
+			projects: []project.Policy{
+				{
+					Format: 1,
+					Principal: project.Principal{
+						Environments: map[string]string{
+							"prod": "sa-prod",
+						},
+					},
+					Packages: []project.Package{
+						{
+							Name: "package_name1",
+							Environment: project.Environment{
+								AnyOf: []string{"prod"},
+							},
+						},
+					},
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(2),
+					},
+				},
+				{
+					Format: 1,
+					Principal: project.Principal{
+						URI: "sa-prod2",
+					},
+					Packages: []project.Package{
+						{
+							Name: "package_name2",
+						},
+					},
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+			expected: Stats{
+				Projects: 2,
+				PackagesPerProject: map[int]int{
+					1: 2,
+				},
+				Environments:               []string{"prod"},
+				PackagesWithoutEnvironment: 1,
+				Levels: map[int]int{
+					2: 1,
+					3: 1,
+				},
+				ReferencedRoots:   []string{"root_level2", "root_level3"},
+				UnreferencedRoots: []string{"root_allow_other", "root_deny_prod"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			orgContent, err := json.Marshal(org)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			projects := make([][]byte, len(tt.projects))
+			for i := range tt.projects {
+				content, err := json.Marshal(tt.projects[i])
+				if err != nil {
+					t.Fatalf("failed to marshal: %v", err)
+				}
+				projects[i] = content
+			}
+			orgReader := io.NopCloser(bytes.NewReader(orgContent))
+			projectsReader := common.NewNamedBytesIterator(projects, true)
+			policy, err := PolicyNew(orgReader, projectsReader, nil)
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, policy.Stats()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
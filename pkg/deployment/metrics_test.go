@@ -0,0 +1,222 @@
+package deployment
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// observation is a set of labels recorded by metricsRecorder.
+type observation struct {
+	kind      string
+	component string
+	decision  string
+	code      string
+}
+
+// metricsRecorder counts the observations by labels.
+type metricsRecorder struct {
+	mu     sync.Mutex
+	counts map[observation]int
+}
+
+func (r *metricsRecorder) ObserveEvaluation(component, decision, code string, duration time.Duration) {
+	r.observe(observation{"evaluation", component, decision, code})
+}
+
+func (r *metricsRecorder) ObserveVerification(component, decision, code string, duration time.Duration) {
+	r.observe(observation{"verification", component, decision, code})
+}
+
+func (r *metricsRecorder) observe(o observation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[observation]int)
+	}
+	r.counts[o]++
+}
+
+func Test_SetMetrics(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	orgContent, err := json.Marshal(organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	projectContent, err := json.Marshal(project.Policy{
+		Format: 1,
+		BuildRequirements: project.BuildRequirements{
+			RequireSlsaLevel: common.AsPointer(2),
+		},
+		Principal: project.Principal{
+			URI: "principal_uri",
+		},
+		Packages: []project.Package{
+			{
+				Name: packageName,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	recorder := &metricsRecorder{}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+		common.NewNamedBytesIterator([][]byte{projectContent}, true), SetMetrics(recorder))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	requests := []struct {
+		digests intoto.DigestSet
+		level   int
+	}{
+		{digests: digests, level: 2},
+		{digests: digests, level: 3},
+		{digests: digests, level: 1},
+		{digests: intoto.DigestSet{}, level: 2},
+	}
+	for _, req := range requests {
+		verifier := NewE2eAttestationVerifier(digests, packageName, "", publishrID, req.level)
+		pol.Evaluate(req.digests, packageName, "policy_id0", AttestationVerificationOption{
+			Verifier: verifier,
+		})
+	}
+	expected := map[observation]int{
+		{"evaluation", "deployment", "allow", ""}:             2,
+		{"evaluation", "deployment", "deny", "verification"}:  1,
+		{"evaluation", "deployment", "deny", "invalid_field"}: 1,
+	}
+	if diff := cmp.Diff(expected, recorder.counts, cmp.AllowUnexported(observation{})); diff != "" {
+		t.Fatalf("unexpected counts (-want +got): \n%s", diff)
+	}
+
+	// Nil recorder.
+	_, err = PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+		common.NewNamedBytesIterator([][]byte{projectContent}, true), SetMetrics(nil))
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_WithMetrics(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{
+		ScopeKubernetesServiceAccount(): "principal_uri",
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes)
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	recorder := &metricsRecorder{}
+	tests := []struct {
+		name     string
+		digests  intoto.DigestSet
+		scopes   map[string]string
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:    "allow",
+			digests: digests,
+			scopes:  scopes,
+			options: []VerificationOption{WithMetrics(recorder)},
+		},
+		{
+			name: "digest mismatch",
+			digests: intoto.DigestSet{
+				"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c",
+			},
+			scopes:   scopes,
+			options:  []VerificationOption{WithMetrics(recorder)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "scope mismatch",
+			digests: digests,
+			scopes: map[string]string{
+				ScopeKubernetesServiceAccount(): "other_uri",
+			},
+			options:  []VerificationOption{WithMetrics(recorder)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "invalid digests",
+			digests:  intoto.DigestSet{},
+			scopes:   scopes,
+			options:  []VerificationOption{WithMetrics(recorder)},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:    "no metrics",
+			digests: digests,
+			scopes:  scopes,
+		},
+		{
+			name:     "nil metrics",
+			digests:  digests,
+			scopes:   scopes,
+			options:  []VerificationOption{WithMetrics(nil)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "metrics set twice",
+			digests:  digests,
+			scopes:   scopes,
+			options:  []VerificationOption{WithMetrics(recorder), WithMetrics(recorder)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		err := verification.Verify(tt.digests, tt.scopes, tt.options...)
+		if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("%s: unexpected err (-want +got): \n%s", tt.name, diff)
+		}
+	}
+	expected := map[observation]int{
+		{"verification", "deployment", "allow", ""}:             1,
+		{"verification", "deployment", "deny", "mismatch"}:      2,
+		{"verification", "deployment", "deny", "invalid_field"}: 1,
+	}
+	if diff := cmp.Diff(expected, recorder.counts, cmp.AllowUnexported(observation{})); diff != "" {
+		t.Fatalf("unexpected counts (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,122 @@
+package deployment
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/checks"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Properties under the reserved prefix "slsa.dev/" are written by
+// this library only. The recognized keys are:
+//   - slsa.dev/publish/root: the ID of the publish root that
+//     authorized the deployment.
+//   - slsa.dev/build/level: the minimum SLSA build level verified
+//     by the publish root.
+//   - slsa.dev/deployment/digestAlgorithms: the digest algorithms that
+//     satisfied the package's accepted digest algorithms.
+//   - slsa.dev/deployment/exception: the policy exception that allowed
+//     the deployment without a publish attestation.
+//   - slsa.dev/telemetry/evaluationDurationMs: the duration of the
+//     policy evaluation, in milliseconds.
+//   - slsa.dev/telemetry/verifierCalls: the number of verifier calls
+//     made during the policy evaluation.
+//   - slsa.dev/deployment/expiresAt: the time after which the
+//     attestation must no longer be trusted, see WithExpiry().
+//   - slsa.dev/deployment/nonce: a value chosen by the caller to
+//     bind the attestation to a request, see WithNonce().
+//
+// Callers record their own metadata with WithMetadata(),
+// outside of the reserved prefix.
+//
+// Any other key under the reserved prefix is unknown to this version
+// of the library and must not be trusted.
+const reservedPropertyPrefix = "slsa.dev/"
+
+var reservedProperties = []string{
+	publishRootProperty,
+	buildLevelProperty,
+	digestAlgorithmsProperty,
+	exceptionProperty,
+	evaluationDurationProperty,
+	verifierCallsProperty,
+	expiresAtProperty,
+	nonceProperty,
+}
+
+// isReservedProperty returns true if the key is under the reserved prefix.
+// The comparison ignores case and surrounding spaces so that look-alike
+// keys are reported too.
+func isReservedProperty(name string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(name)), reservedPropertyPrefix)
+}
+
+// unknownReservedProperties returns the sorted keys under the reserved
+// prefix that this library does not recognize.
+func unknownReservedProperties(props properties) []string {
+	var names []string
+	for name := range props {
+		if isReservedProperty(name) && !slices.Contains(reservedProperties, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Warnings returns the findings about the attestation that do not
+// fail verification by default, such as unknown reserved properties.
+func (v *Verification) Warnings() []string {
+	var warnings []string
+	for _, name := range unknownReservedProperties(v.attestation.Predicate.Properties) {
+		warnings = append(warnings, fmt.Sprintf("property (%q) uses the reserved prefix (%q) but is unknown",
+			name, reservedPropertyPrefix))
+	}
+	return warnings
+}
+
+// RejectUnknownReservedProperties rejects attestations that contain
+// properties under the reserved prefix that this library does not recognize.
+func RejectUnknownReservedProperties() VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind: "RejectUnknownReservedProperties",
+			Rank: checks.RankProperties,
+			Run:  v.rejectUnknownReservedProperties,
+		})
+	}
+}
+
+func (v *Verification) rejectUnknownReservedProperties() error {
+	if names := unknownReservedProperties(v.attestation.Predicate.Properties); len(names) > 0 {
+		return errs.VerificationErrorNew(errs.CheckProperty, "", "",
+			fmt.Errorf("%w: unknown reserved properties (%q)", errs.ErrorInvalidField, names))
+	}
+	return nil
+}
+
+func (a *Creation) validateProperties() error {
+	if names := unknownReservedProperties(a.attestation.Predicate.Properties); len(names) > 0 {
+		return fmt.Errorf("%w: cannot write unknown reserved properties (%q)", errs.ErrorInvalidInput, names)
+	}
+	// NOTE: the options may be passed in any order, so the
+	// expiry is compared to the creation time once all are set.
+	if value, exists := a.attestation.Predicate.Properties[expiresAtProperty]; exists {
+		expiresAt, err := intoto.ParseTime(value.(string))
+		if err != nil {
+			return err
+		}
+		created, err := intoto.ParseTime(a.attestation.Predicate.CreationTime)
+		if err != nil {
+			return err
+		}
+		if !expiresAt.After(created) {
+			return fmt.Errorf("%w: expiry (%s) is not after the creation time (%s)", errs.ErrorInvalidInput,
+				intoto.FormatTime(expiresAt), intoto.FormatTime(created))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// record is the serialized form of an allow decision.
+type record struct {
+	Digests      intoto.DigestSet `json:"digests"`
+	PrincipalURI string           `json:"principal_uri"`
+	// Scopes contains the scopes of the principal other than its URI.
+	Scopes        map[string]string `json:"scopes,omitempty"`
+	PublishRootID string            `json:"publish_root_id,omitempty"`
+	// BuildLevel is the minimum SLSA build level verified by the publish root.
+	BuildLevel    *int             `json:"build_level,omitempty"`
+	PolicyDigests intoto.DigestSet `json:"policy_digests"`
+	EvaluatedAt   time.Time        `json:"evaluated_at"`
+	// DigestAlgorithms is set if the package restricts the digest algorithms.
+	DigestAlgorithms []string `json:"digest_algorithms,omitempty"`
+}
+
+// ImportOption defines an option to import a decision record.
+type ImportOption func(*importer) error
+
+type importer struct {
+	maxAge time.Duration
+}
+
+// Export writes the allow decision to the writer, so that the
+// attestation can be created by a different job than the one
+// that evaluated the policy. Only successful evaluations can be exported.
+func (r PolicyEvaluationResult) Export(writer io.Writer) error {
+	if r.Error() != nil {
+		return fmt.Errorf("%w: evaluation failed. Cannot export result", errs.ErrorInternal)
+	}
+	if err := r.isValid(); err != nil {
+		return err
+	}
+	if len(r.policyDigests) == 0 {
+		return fmt.Errorf("%w: empty policy digests", errs.ErrorInternal)
+	}
+	content, err := json.Marshal(record{
+		Digests:          r.digests,
+		PrincipalURI:     r.principal.URI,
+		Scopes:           r.principal.Scopes,
+		PublishRootID:    r.publishRootID,
+		BuildLevel:       r.buildLevel,
+		PolicyDigests:    r.policyDigests,
+		EvaluatedAt:      r.evaluatedAt.UTC(),
+		DigestAlgorithms: r.digestAlgorithms,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal: %w", errs.ErrorInternal, err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		return fmt.Errorf("%w: failed to write: %w", errs.ErrorInternal, err)
+	}
+	return nil
+}
+
+// ImportResult reads a decision record written by Export.
+// The record must have been created from the same policy files
+// as the ones the policy was created from.
+func (p *Policy) ImportResult(reader io.Reader, opts ...ImportOption) (*PolicyEvaluationResult, error) {
+	imp := importer{}
+	for _, option := range opts {
+		if err := option(&imp); err != nil {
+			return nil, err
+		}
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read: %v", errs.ErrorInvalidInput, err)
+	}
+	var rec record
+	if err := json.Unmarshal(content, &rec); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal: %v", errs.ErrorInvalidInput, err)
+	}
+	if err := rec.Digests.Validate(); err != nil {
+		return nil, err
+	}
+	if rec.PrincipalURI == "" {
+		return nil, fmt.Errorf("%w: empty principal URI", errs.ErrorInvalidField)
+	}
+	if err := validatePrincipalScopes(rec.Scopes); err != nil {
+		return nil, err
+	}
+	if rec.EvaluatedAt.IsZero() {
+		return nil, fmt.Errorf("%w: empty evaluation time", errs.ErrorInvalidField)
+	}
+	if err := verifyDigests(rec.PolicyDigests, p.digests); err != nil {
+		return nil, fmt.Errorf("%w: policy digests (%v) differ from the local policy (%v)",
+			errs.ErrorMismatch, rec.PolicyDigests, p.digests)
+	}
+	if imp.maxAge > 0 {
+		if age := p.now().Sub(rec.EvaluatedAt); age > imp.maxAge {
+			return nil, fmt.Errorf("%w: result is %v old, max age is %v",
+				errs.ErrorVerification, age, imp.maxAge)
+		}
+	}
+	return &PolicyEvaluationResult{
+		digests:          rec.Digests,
+		principal:        &project.Principal{URI: rec.PrincipalURI, Scopes: rec.Scopes},
+		publishRootID:    rec.PublishRootID,
+		buildLevel:       rec.BuildLevel,
+		policyDigests:    rec.PolicyDigests,
+		evaluatedAt:      rec.EvaluatedAt,
+		digestAlgorithms: rec.DigestAlgorithms,
+	}, nil
+}
+
+// WithMaxAge rejects records evaluated more than maxAge ago.
+func WithMaxAge(maxAge time.Duration) ImportOption {
+	return func(imp *importer) error {
+		return imp.setMaxAge(maxAge)
+	}
+}
+
+func (imp *importer) setMaxAge(maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return fmt.Errorf("%w: max age (%v) must be positive", errs.ErrorInvalidInput, maxAge)
+	}
+	imp.maxAge = maxAge
+	return nil
+}
@@ -0,0 +1,216 @@
+package deployment
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_ImportResult(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "728349abb3d6fe69386e0755b956978e62e81c56759c4e21801554a5fdc7e7f5",
+	}
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	principalURI := "principal_uri"
+	policyID := "policy_id0"
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(3),
+			},
+			Principal: project.Principal{
+				URI: principalURI,
+			},
+			Packages: []project.Package{
+				{
+					Name: packageName,
+				},
+			},
+		},
+	}
+	otherProjects := []project.Policy{
+		{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(2),
+			},
+			Principal: project.Principal{
+				URI: principalURI,
+			},
+			Packages: []project.Package{
+				{
+					Name: packageName,
+				},
+			},
+		},
+	}
+	// The fake clock starts at the Unix epoch.
+	evaluatedAt := time.Unix(0, 0).Add(10 * time.Millisecond)
+	tests := []struct {
+		name     string
+		projects []project.Policy
+		now      time.Time
+		options  []ImportOption
+		expected error
+	}{
+		{
+			name:     "fresh result",
+			projects: projects,
+			now:      evaluatedAt.Add(time.Minute),
+			options:  []ImportOption{WithMaxAge(time.Hour)},
+		},
+		{
+			name:     "no max age",
+			projects: projects,
+			now:      evaluatedAt.Add(48 * time.Hour),
+		},
+		{
+			name:     "stale result",
+			projects: projects,
+			now:      evaluatedAt.Add(2 * time.Hour),
+			options:  []ImportOption{WithMaxAge(time.Hour)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "policy digest mismatch",
+			projects: otherProjects,
+			now:      evaluatedAt.Add(time.Minute),
+			options:  []ImportOption{WithMaxAge(time.Hour)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "invalid max age",
+			projects: projects,
+			now:      evaluatedAt.Add(time.Minute),
+			options:  []ImportOption{WithMaxAge(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// Evaluate and export the result.
+			pol := newTestPolicy(t, org, projects, newFakeClock(10*time.Millisecond))
+			verifier := NewE2eAttestationVerifier(digests, packageName, "", publishrID, 3)
+			result := pol.Evaluate(digests, packageName, policyID, AttestationVerificationOption{
+				Verifier: verifier,
+			})
+			if result.Error() != nil {
+				t.Fatalf("failed to evaluate: %v", result.Error())
+			}
+			var buf bytes.Buffer
+			if err := result.Export(&buf); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			// Import the result with the local policy.
+			local := newTestPolicy(t, org, tt.projects, func() time.Time { return tt.now })
+			imported, err := local.ImportResult(&buf, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(result.publishRootID, imported.PublishRoot()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			att, err := imported.AttestationNew()
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			if diff := cmp.Diff([]intoto.Subject{{Digests: digests}}, att.attestation.Header.Subjects); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			c := map[string]string{
+				ScopeKeyKubernetesServiceAccount: principalURI,
+			}
+			if diff := cmp.Diff(c, att.attestation.Predicate.Scopes); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Export(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		result   PolicyEvaluationResult
+		expected error
+	}{
+		{
+			name: "error result",
+			result: PolicyEvaluationResult{
+				err: errs.ErrorMismatch,
+			},
+			expected: errs.ErrorInternal,
+		},
+		{
+			name: "no policy digests",
+			result: PolicyEvaluationResult{
+				digests:   intoto.DigestSet{"sha256": "728349abb3d6fe69386e0755b956978e62e81c56759c4e21801554a5fdc7e7f5"},
+				principal: &project.Principal{URI: "principal_uri"},
+			},
+			expected: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.result.Export(io.Discard)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func newTestPolicy(t *testing.T, org organization.Policy, projects []project.Policy, now func() time.Time) *Policy {
+	orgContent, err := json.Marshal(org)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	policies := make([][]byte, len(projects))
+	for i := range projects {
+		content, err := json.Marshal(projects[i])
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+		common.NewNamedBytesIterator(policies, true), SetClock(now))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	return pol
+}
@@ -1,18 +1,40 @@
 package deployment
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 // PolicyEvaluationResult defines the result of policy evaluation.
 type PolicyEvaluationResult struct {
-	err        error
-	digests    intoto.DigestSet
-	protection *project.Protection
+	err           error
+	digests       intoto.DigestSet
+	packageName   string
+	principal     *project.Principal
+	publishRootID string
+	// buildLevel is the minimum SLSA build level
+	// verified by the publish root, if known.
+	buildLevel    *int
+	policyDigests intoto.DigestSet
+	evaluatedAt   time.Time
+	telemetry     *telemetry
+	// digestAlgorithms contains the algorithms that satisfied
+	// the package's accepted digest algorithms, if any.
+	digestAlgorithms []string
+	// exception is the exception that allowed the package, if any.
+	exception *PolicyException
+	// allowedEnvironments contains the environments the
+	// policy allows for the package, if any.
+	allowedEnvironments []string
+	// trace records the candidates considered, if requested.
+	trace *trace.Trace
 }
 
 // AttestationNew creates a deployment attestation.
@@ -28,14 +50,31 @@ func (r PolicyEvaluationResult) AttestationNew(options ...AttestationCreationOpt
 	}
 	// Create the options.
 	opts := []AttestationCreationOption{}
+	// Set the publish root, if known.
+	if r.publishRootID != "" {
+		opts = append(opts, SetPublishRoot(r.publishRootID))
+	}
+	// Set the build level, if known.
+	if r.buildLevel != nil {
+		opts = append(opts, setBuildLevel(*r.buildLevel))
+	}
+	// Set the accepted digest algorithms, if known.
+	if len(r.digestAlgorithms) > 0 {
+		opts = append(opts, setDigestAlgorithms(r.digestAlgorithms))
+	}
+	// Record the exception, if any.
+	if r.exception != nil {
+		opts = append(opts, setException(*r.exception))
+	}
+	// Set the telemetry, if known.
+	if r.telemetry != nil {
+		opts = append(opts, setTelemetry(*r.telemetry))
+	}
 	// Enter safe mode.
 	opts = append(opts, EnterSafeMode())
 	// Add caller options.
 	opts = append(opts, options...)
-	scopes := map[string]string{
-		scopeGoogleServiceAccount: r.protection.GoogleServiceAccount,
-	}
-	att, err := CreationNew(subject, scopes, opts...)
+	att, err := CreationNew(subject, r.principal.AllScopes(), opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +85,184 @@ func (r PolicyEvaluationResult) Error() error {
 	return r.err
 }
 
+// Trace returns the project policy, package and roots considered by
+// the evaluation, in order, and why each was rejected, including if the
+// evaluation failed. It is nil unless WithTrace() was passed to Evaluate().
+// The returned value is a copy and may be modified by the caller.
+func (r PolicyEvaluationResult) Trace() *trace.Trace {
+	return r.trace.Clone()
+}
+
+// PublishRoot returns the ID of the publish root that
+// authorized the decision.
+func (r PolicyEvaluationResult) PublishRoot() string {
+	return r.publishRootID
+}
+
+// AllowedEnvironments returns the environments the project policy
+// allows for the package, e.g. to show where a package may be deployed.
+// Entries may be wildcards, e.g. "prod/*". It is nil if the evaluation
+// failed or the package configures no environment. The returned value
+// is a copy and may be modified by the caller.
+func (r PolicyEvaluationResult) AllowedEnvironments() []string {
+	if r.Error() != nil {
+		return nil
+	}
+	return slices.Clone(r.allowedEnvironments)
+}
+
+// PrincipalURI returns the URI of the principal the
+// package is allowed to run under. It is empty if the
+// evaluation failed.
+func (r PolicyEvaluationResult) PrincipalURI() string {
+	if r.principal == nil {
+		return ""
+	}
+	return r.principal.URI
+}
+
+// Scopes returns the scopes the package is allowed to run
+// with, including the principal URI. It is nil if the
+// evaluation failed.
+func (r PolicyEvaluationResult) Scopes() map[string]string {
+	if r.principal == nil || r.Error() != nil {
+		return nil
+	}
+	return r.principal.AllScopes()
+}
+
+// VerifyScopes verifies that the scopes the package runs with are
+// the scopes allowed by the evaluation, e.g. in an admission webhook.
+// It returns the error of the evaluation if it failed, and an
+// errs.VerificationError wrapping a *ScopeMismatchError if any
+// scope differs.
+func (r PolicyEvaluationResult) VerifyScopes(scopes map[string]string) error {
+	if err := r.Error(); err != nil {
+		return err
+	}
+	if err := validateScopes(scopes); err != nil {
+		return err
+	}
+	return compareScopes(scopes, r.Scopes(), func(string) bool { return false })
+}
+
 func (r PolicyEvaluationResult) isValid() error {
-	if r.protection == nil {
-		return fmt.Errorf("%w: nil protection", errs.ErrorInternal)
+	if r.principal == nil {
+		return fmt.Errorf("%w: nil principal", errs.ErrorInternal)
 	}
-	if r.protection.GoogleServiceAccount == "" {
-		return fmt.Errorf("%w: empty protection service account", errs.ErrorInternal)
+	if r.principal.URI == "" {
+		return fmt.Errorf("%w: empty principal URI", errs.ErrorInternal)
 	}
 	return nil
 }
+
+// resultJSON is the serialized form of a PolicyEvaluationResult.
+type resultJSON struct {
+	Allow        bool   `json:"allow"`
+	PackageName  string `json:"package_name,omitempty"`
+	PrincipalURI string `json:"principal_uri,omitempty"`
+	// Scopes contains the scopes of the principal other than its URI.
+	Scopes              map[string]string `json:"scopes,omitempty"`
+	Digests             intoto.DigestSet  `json:"digests,omitempty"`
+	PublishRootID       string            `json:"publish_root_id,omitempty"`
+	BuildLevel          *int              `json:"build_level,omitempty"`
+	Exception           *PolicyException  `json:"exception,omitempty"`
+	AllowedEnvironments []string          `json:"allowed_environments,omitempty"`
+	Error               *resultErrorJSON  `json:"error,omitempty"`
+}
+
+type resultErrorJSON struct {
+	// Category is the name of the errs sentinel, see errs.Category.
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+}
+
+// ToJSON returns a machine-readable summary of the decision.
+// Deny results carry the error category and reason.
+func (r PolicyEvaluationResult) ToJSON() ([]byte, error) {
+	res := resultJSON{
+		Allow:         r.Error() == nil,
+		PackageName:   r.packageName,
+		Digests:       r.digests,
+		PublishRootID: r.publishRootID,
+		BuildLevel:    r.buildLevel,
+		Exception:     r.exception,
+	}
+	if r.Error() != nil {
+		res.Error = &resultErrorJSON{
+			Category: errs.Category(r.err),
+			Reason:   r.err.Error(),
+		}
+	} else {
+		if err := r.isValid(); err != nil {
+			return nil, err
+		}
+		res.PrincipalURI = r.principal.URI
+		res.Scopes = r.principal.Scopes
+		res.AllowedEnvironments = r.allowedEnvironments
+	}
+	content, err := json.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to marshal: %w", errs.ErrorInternal, err)
+	}
+	return content, nil
+}
+
+// ResultFromJSON parses a result serialized by ToJSON. The error of a
+// deny result wraps the sentinel of its category, if known.
+func ResultFromJSON(content []byte) (*PolicyEvaluationResult, error) {
+	var res resultJSON
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal: %v", errs.ErrorInvalidInput, err)
+	}
+	r := PolicyEvaluationResult{
+		packageName:   res.PackageName,
+		digests:       res.Digests,
+		publishRootID: res.PublishRootID,
+		buildLevel:    res.BuildLevel,
+		exception:     res.Exception,
+	}
+	if !res.Allow {
+		if res.Error == nil {
+			return nil, fmt.Errorf("%w: deny result without error", errs.ErrorInvalidField)
+		}
+		r.err = &decodedError{
+			sentinel: errs.FromCategory(res.Error.Category),
+			reason:   res.Error.Reason,
+		}
+		return &r, nil
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("%w: allow result with error", errs.ErrorInvalidField)
+	}
+	if err := res.Digests.Validate(); err != nil {
+		return nil, err
+	}
+	if alg, conflicts := res.Digests.ConflictingAlgorithm(); conflicts {
+		return nil, fmt.Errorf("%w: digest (%q) has conflicting values", errs.ErrorInvalidInput, alg)
+	}
+	if err := validatePrincipalScopes(res.Scopes); err != nil {
+		return nil, err
+	}
+	r.principal = &project.Principal{URI: res.PrincipalURI, Scopes: res.Scopes}
+	r.allowedEnvironments = res.AllowedEnvironments
+	if err := r.isValid(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrorInvalidField, err)
+	}
+	return &r, nil
+}
+
+// decodedError is an error parsed by ResultFromJSON. It keeps
+// the original message and wraps the sentinel of its category.
+type decodedError struct {
+	sentinel error
+	reason   string
+}
+
+func (e *decodedError) Error() string {
+	return e.reason
+}
+
+func (e *decodedError) Unwrap() error {
+	return e.sentinel
+}
@@ -0,0 +1,245 @@
+package deployment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// RevocationList is a list of revoked subject and attestation digests,
+// e.g. of the images of a compromised build. It is never modified once
+// created, so it is safe to share across concurrent evaluations.
+type RevocationList struct {
+	revocations []revocation
+}
+
+// revocationList is the format of a revocation list file.
+type revocationList struct {
+	Format      int          `json:"format"`
+	Revocations []revocation `json:"revocations"`
+}
+
+// revocation is an entry of a revocation list. At least one of
+// the subject and attestation digests is set.
+type revocation struct {
+	// Subject is the digest of a revoked image.
+	Subject intoto.DigestSet `json:"subject,omitempty"`
+	// Attestation is the digest of a revoked attestation,
+	// i.e. of the statement or envelope as stored.
+	Attestation intoto.DigestSet `json:"attestation,omitempty"`
+	Reason      string           `json:"reason"`
+	RevokedAt   string           `json:"revoked_at"`
+	// ExpiresAt, if set, is the time the entry stops applying.
+	ExpiresAt string `json:"expires_at,omitempty"`
+	expiresAt time.Time
+}
+
+// RevocationListNew parses and validates a revocation list, e.g.
+//
+//	{
+//	  "format": 1,
+//	  "revocations": [
+//	    {
+//	      "subject": {"sha256": "..."},
+//	      "reason": "compromised builder",
+//	      "revoked_at": "2024-05-01T00:00:00Z"
+//	    }
+//	  ]
+//	}
+func RevocationListNew(reader io.ReadCloser) (*RevocationList, error) {
+	if reader == nil {
+		return nil, fmt.Errorf("%w: revocation list reader is nil", errs.ErrorInvalidInput)
+	}
+	defer reader.Close()
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	var list revocationList
+	if err := decoder.Decode(&list); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode revocation list: %w", errs.ErrorInvalidInput, err)
+	}
+	if list.Format != 1 {
+		return nil, fmt.Errorf("%w: revocation list format (%d) is not supported", errs.ErrorInvalidField,
+			list.Format)
+	}
+	for i := range list.Revocations {
+		if err := list.Revocations[i].validate(); err != nil {
+			return nil, fmt.Errorf("revocation %d: %w", i, err)
+		}
+	}
+	return &RevocationList{
+		revocations: list.Revocations,
+	}, nil
+}
+
+// validate validates the entry and normalizes its digests.
+func (r *revocation) validate() error {
+	if r.Subject == nil && r.Attestation == nil {
+		return fmt.Errorf("%w: no subject nor attestation digests", errs.ErrorInvalidField)
+	}
+	if r.Subject != nil {
+		digests, err := r.Subject.Normalize()
+		if err != nil {
+			return err
+		}
+		r.Subject = digests
+	}
+	if r.Attestation != nil {
+		digests, err := r.Attestation.Normalize()
+		if err != nil {
+			return err
+		}
+		r.Attestation = digests
+	}
+	if r.Reason == "" {
+		return fmt.Errorf("%w: reason is empty", errs.ErrorInvalidField)
+	}
+	revokedAt, err := intoto.ParseTime(r.RevokedAt)
+	if err != nil {
+		return err
+	}
+	if r.ExpiresAt == "" {
+		return nil
+	}
+	expiresAt, err := intoto.ParseTime(r.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if !expiresAt.After(revokedAt) {
+		return fmt.Errorf("%w: expiration time (%q) is not after revocation time (%q)", errs.ErrorInvalidField,
+			r.ExpiresAt, r.RevokedAt)
+	}
+	r.expiresAt = expiresAt
+	return nil
+}
+
+// verifySubject returns errs.ErrorRevoked if the subject digests
+// are revoked at time now. Expired entries are ignored.
+func (l *RevocationList) verifySubject(digests intoto.DigestSet, now time.Time) error {
+	for i := range l.revocations {
+		r := &l.revocations[i]
+		if r.Subject == nil || r.expired(now) || !revokedDigests(r.Subject, digests) {
+			continue
+		}
+		return fmt.Errorf("%w: subject (%v) revoked at %s: %s", errs.ErrorRevoked,
+			digests, r.RevokedAt, r.Reason)
+	}
+	return nil
+}
+
+// verifyAttestation returns errs.ErrorRevoked if the digest of
+// the attestation content is revoked at time now.
+func (l *RevocationList) verifyAttestation(content []byte, now time.Time) error {
+	sum := sha256.Sum256(content)
+	digests := intoto.DigestSet{
+		"sha256": hex.EncodeToString(sum[:]),
+	}
+	for i := range l.revocations {
+		r := &l.revocations[i]
+		if r.Attestation == nil || r.expired(now) || !revokedDigests(r.Attestation, digests) {
+			continue
+		}
+		return fmt.Errorf("%w: attestation (%v) revoked at %s: %s", errs.ErrorRevoked,
+			digests, r.RevokedAt, r.Reason)
+	}
+	return nil
+}
+
+func (r *revocation) expired(now time.Time) bool {
+	return !r.expiresAt.IsZero() && !now.Before(r.expiresAt)
+}
+
+// revokedDigests returns true if the digests share
+// an algorithm value with the revoked digests.
+func revokedDigests(revoked, digests intoto.DigestSet) bool {
+	for name, value := range revoked {
+		if val, exists := digests.DigestValue(name); exists && val == value {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRevocationList fails the verification with errs.ErrorRevoked if
+// the evaluated digests, a subject of the attestation or the attestation
+// itself is revoked by the list read from reader. The list is checked
+// before any other verification. Entries that expired are ignored.
+// NOTE: The list is read once, when the option is created.
+func WithRevocationList(reader io.ReadCloser) VerificationOption {
+	list, err := RevocationListNew(reader)
+	return func(v *Verification) error {
+		if err != nil {
+			return err
+		}
+		return v.setRevocations(list)
+	}
+}
+
+// WithRevocations is like WithRevocationList, for a
+// list already parsed by RevocationListNew().
+func WithRevocations(list *RevocationList) VerificationOption {
+	return func(v *Verification) error {
+		if list == nil {
+			return fmt.Errorf("%w: revocation list is nil", errs.ErrorInvalidInput)
+		}
+		return v.setRevocations(list)
+	}
+}
+
+func (v *Verification) setRevocations(list *RevocationList) error {
+	if v.revocations != nil {
+		return fmt.Errorf("%w: revocation list is set more than once", errs.ErrorInvalidInput)
+	}
+	v.revocations = list
+	return nil
+}
+
+func (v *Verification) verifyRevocations(digests intoto.DigestSet) error {
+	if v.revocations == nil {
+		return nil
+	}
+	now := time.Now()
+	if err := v.revocations.verifySubject(digests, now); err != nil {
+		return errs.VerificationErrorNew(errs.CheckRevocation, "", "", err)
+	}
+	for i := range v.attestation.Header.Subjects {
+		if err := v.revocations.verifySubject(v.attestation.Header.Subjects[i].Digests, now); err != nil {
+			return errs.VerificationErrorNew(errs.CheckRevocation, "", "", err)
+		}
+	}
+	if err := v.revocations.verifyAttestation(v.envelope, now); err != nil {
+		return errs.VerificationErrorNew(errs.CheckRevocation, "", "", err)
+	}
+	return nil
+}
+
+// SetRevocationList denies the evaluation of digests revoked by the
+// list read from reader, with errs.ErrorRevoked, before any publish
+// attestation is verified. Entries that expired, according to the
+// clock set by SetClock(), are ignored.
+func SetRevocationList(reader io.ReadCloser) PolicyOption {
+	return func(p *Policy) error {
+		return p.setRevocationList(reader)
+	}
+}
+
+func (p *Policy) setRevocationList(reader io.ReadCloser) error {
+	list, err := RevocationListNew(reader)
+	if err != nil {
+		return err
+	}
+	p.revocations = list
+	return nil
+}
+
+func (p *Policy) verifyRevocations(digests intoto.DigestSet, now time.Time) error {
+	if p.revocations == nil {
+		return nil
+	}
+	return p.revocations.verifySubject(digests, now)
+}
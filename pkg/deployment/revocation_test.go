@@ -0,0 +1,299 @@
+package deployment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_RevocationListNew(t *testing.T) {
+	t.Parallel()
+	digest := "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"
+	tests := []struct {
+		name     string
+		content  string
+		expected error
+	}{
+		{
+			name: "subject revocation",
+			content: `{"format": 1, "revocations": [{"subject": {"sha256": "` + digest + `"},
+				"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z"}]}`,
+		},
+		{
+			name: "attestation revocation with expiry",
+			content: `{"format": 1, "revocations": [{"attestation": {"SHA256": "` + strings.ToUpper(digest) + `"},
+				"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z",
+				"expires_at": "2024-06-01T00:00:00Z"}]}`,
+		},
+		{
+			name:    "no revocations",
+			content: `{"format": 1}`,
+		},
+		{
+			name:     "invalid format",
+			content:  `{"format": 2, "revocations": []}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "unknown field",
+			content:  `{"format": 1, "revocations": [], "other": "value"}`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "malformed content",
+			content:  `{"format": 1, "revocations": [`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "no digests",
+			content: `{"format": 1, "revocations": [{"reason": "compromised builder",
+				"revoked_at": "2024-05-01T00:00:00Z"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid digest",
+			content: `{"format": 1, "revocations": [{"subject": {"sha256": "some_value"},
+				"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z"}]}`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "empty reason",
+			content: `{"format": 1, "revocations": [{"subject": {"sha256": "` + digest + `"},
+				"revoked_at": "2024-05-01T00:00:00Z"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty revocation time",
+			content: `{"format": 1, "revocations": [{"subject": {"sha256": "` + digest + `"},
+				"reason": "compromised builder"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid expiration time",
+			content: `{"format": 1, "revocations": [{"subject": {"sha256": "` + digest + `"},
+				"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z",
+				"expires_at": "tomorrow"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "expiration before revocation",
+			content: `{"format": 1, "revocations": [{"subject": {"sha256": "` + digest + `"},
+				"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z",
+				"expires_at": "2024-04-01T00:00:00Z"}]}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := RevocationListNew(io.NopCloser(strings.NewReader(tt.content)))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_WithRevocationList(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	otherDigests := intoto.DigestSet{
+		"sha256": "0b8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes, SetPublishRoot("publishr_id"))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	attDigests := intoto.DigestSet{"sha256": hex.EncodeToString(sum[:])}
+	list := func(kind string, digests intoto.DigestSet, expiresAt string) string {
+		expiry := ""
+		if expiresAt != "" {
+			expiry = fmt.Sprintf(`, "expires_at": %q`, expiresAt)
+		}
+		return fmt.Sprintf(`{"format": 1, "revocations": [{%q: {"sha256": %q},
+			"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z"%s}]}`,
+			kind, digests["sha256"], expiry)
+	}
+	tests := []struct {
+		name     string
+		list     string
+		scopes   map[string]string
+		expected error
+	}{
+		{
+			name:     "revoked subject",
+			list:     list("subject", digests, ""),
+			scopes:   scopes,
+			expected: errs.ErrorRevoked,
+		},
+		{
+			name:     "revoked attestation",
+			list:     list("attestation", attDigests, ""),
+			scopes:   scopes,
+			expected: errs.ErrorRevoked,
+		},
+		{
+			name:     "revoked before other verifications",
+			list:     list("subject", digests, ""),
+			scopes:   map[string]string{"environment": "dev"},
+			expected: errs.ErrorRevoked,
+		},
+		{
+			name:   "other subject",
+			list:   list("subject", otherDigests, ""),
+			scopes: scopes,
+		},
+		{
+			name:   "other attestation",
+			list:   list("attestation", otherDigests, ""),
+			scopes: scopes,
+		},
+		{
+			name:   "expired revocation",
+			list:   list("subject", digests, "2024-06-01T00:00:00Z"),
+			scopes: scopes,
+		},
+		{
+			name:     "not expired revocation",
+			list:     list("subject", digests, time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+			scopes:   scopes,
+			expected: errs.ErrorRevoked,
+		},
+		{
+			name:     "invalid list",
+			list:     `{"format": 1, "revocations": [{}]}`,
+			scopes:   scopes,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, tt.scopes, AllowUnknownScopes(),
+				WithRevocationList(io.NopCloser(strings.NewReader(tt.list))))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Same with a parsed list.
+			list, err := RevocationListNew(io.NopCloser(strings.NewReader(tt.list)))
+			if err != nil {
+				return
+			}
+			err = verification.Verify(digests, tt.scopes, AllowUnknownScopes(), WithRevocations(list))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_SetRevocationList(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	otherDigests := intoto.DigestSet{
+		"sha256": "0b8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaLevel: common.AsPointer(2),
+			},
+			Principal: project.Principal{
+				URI: "principal_uri",
+			},
+			Packages: []project.Package{
+				{
+					Name: packageName,
+				},
+			},
+		},
+	}
+	list := `{"format": 1, "revocations": [{"subject": {"sha256": "` + digests["sha256"] + `"},
+		"reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z",
+		"expires_at": "2024-06-01T00:00:00Z"}]}`
+	tests := []struct {
+		name     string
+		now      time.Time
+		digests  intoto.DigestSet
+		expected error
+	}{
+		{
+			name:     "revoked digests",
+			now:      time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+			digests:  digests,
+			expected: errs.ErrorRevoked,
+		},
+		{
+			name:    "expired revocation",
+			now:     time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC),
+			digests: digests,
+		},
+		{
+			name:    "other digests",
+			now:     time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+			digests: otherDigests,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol := newTestPolicy(t, org, projects, func() time.Time { return tt.now })
+			if err := SetRevocationList(io.NopCloser(strings.NewReader(list)))(pol); err != nil {
+				t.Fatalf("failed to set revocation list: %v", err)
+			}
+			verifier := NewE2eAttestationVerifier(tt.digests, packageName, "", publishrID, 2)
+			result := pol.Evaluate(tt.digests, packageName, "policy_id0", AttestationVerificationOption{
+				Verifier: verifier,
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// RootUsageMode defines how org publish roots that
+// no project can use are reported.
+type RootUsageMode int
+
+const (
+	// RootUsageIgnore does not check the roots.
+	RootUsageIgnore RootUsageMode = iota
+	// RootUsageWarn reports the unreferenced roots in Warnings().
+	RootUsageWarn
+	// RootUsageError fails the policy creation if a root is unreferenced.
+	RootUsageError
+)
+
+// WithRootUsageCheck checks that every org publish root can be used
+// by at least one project policy.
+func WithRootUsageCheck(mode RootUsageMode) PolicyOption {
+	return func(p *Policy) error {
+		return p.setRootUsageCheck(mode)
+	}
+}
+
+func (p *Policy) setRootUsageCheck(mode RootUsageMode) error {
+	if mode < RootUsageIgnore || mode > RootUsageError {
+		return fmt.Errorf("%w: invalid root usage mode (%d)", errs.ErrorInvalidInput, mode)
+	}
+	p.rootUsage = mode
+	return nil
+}
+
+func (p *Policy) checkRootUsage() error {
+	if p.rootUsage == RootUsageIgnore {
+		return nil
+	}
+	unreferenced := p.policy.Stats().UnreferencedRoots
+	if len(unreferenced) == 0 {
+		return nil
+	}
+	if p.rootUsage == RootUsageError {
+		return fmt.Errorf("%w: publish roots (%q) are not referenced by any project", errs.ErrorInvalidField, unreferenced)
+	}
+	for _, id := range unreferenced {
+		p.warnings = append(p.warnings, fmt.Sprintf("publish root (%q) is not referenced by any project", id))
+	}
+	return nil
+}
+
+// Warnings returns the findings of the checks
+// that were configured to warn instead of failing.
+func (p *Policy) Warnings() []string {
+	return append([]string{}, p.warnings...)
+}
@@ -0,0 +1,135 @@
+package deployment
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// CustomRules references the custom conditions of a project policy,
+// set in its custom_rules field: a module embedded in the policy,
+// or the path of a module file. Exactly one of them is set.
+type CustomRules struct {
+	Module string
+	Path   string
+}
+
+// CustomRulesInput is the input document the custom rules are
+// evaluated against.
+// NOTE: The fields are part of the rules' interface, do not rename them.
+type CustomRulesInput struct {
+	Request     CustomRulesRequest     `json:"request"`
+	Attestation CustomRulesAttestation `json:"attestation"`
+}
+
+// CustomRulesRequest describes the evaluated request.
+// NOTE: "package" is a keyword in Rego, hence "package_name".
+type CustomRulesRequest struct {
+	Digests  intoto.DigestSet `json:"digests"`
+	Package  string           `json:"package_name"`
+	PolicyID string           `json:"policy_id"`
+	// Environment is the environment of the package, if any.
+	Environment *string `json:"environment,omitempty"`
+	// Scopes are the scopes the deployment is allowed to run under.
+	Scopes map[string]string `json:"scopes"`
+}
+
+// CustomRulesAttestation summarizes the verified publish attestation.
+type CustomRulesAttestation struct {
+	PublishRootID string `json:"publish_root"`
+	BuildLevel    int    `json:"build_level"`
+}
+
+// CustomRulesDecision is the decision of the custom rules.
+type CustomRulesDecision struct {
+	// Deny is true if the rules deny the request.
+	Deny bool
+	// Message explains the deny decision.
+	Message string
+}
+
+// CustomRulesEngine compiles the custom rules of project policies,
+// e.g. Rego modules.
+type CustomRulesEngine interface {
+	// Compile compiles the custom rules of the project policy.
+	Compile(policyID string, rules CustomRules) (CustomRulesEvaluator, error)
+}
+
+// CustomRulesEvaluator evaluates compiled custom rules.
+// It must be safe for concurrent use.
+type CustomRulesEvaluator interface {
+	Evaluate(input CustomRulesInput) (CustomRulesDecision, error)
+}
+
+// SetCustomRulesEngine sets the engine that compiles the custom rules
+// of the project policies, when the policy is created, and evaluates
+// them once the publish attestation is verified. Policies with custom
+// rules are rejected if no engine is set.
+// NOTE: The rules are not evaluated for packages allowed by an exception.
+func SetCustomRulesEngine(engine CustomRulesEngine) PolicyOption {
+	return func(p *Policy) error {
+		return p.setCustomRulesEngine(engine)
+	}
+}
+
+func (p *Policy) setCustomRulesEngine(engine CustomRulesEngine) error {
+	if engine == nil {
+		return fmt.Errorf("%w: custom rules engine is nil", errs.ErrorInvalidInput)
+	}
+	p.rulesEngine = engine
+	return nil
+}
+
+// compileCustomRules compiles the custom rules of the project policies.
+func (p *Policy) compileCustomRules() error {
+	rules := p.policy.CustomRules()
+	if len(rules) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	if p.rulesEngine == nil {
+		return fmt.Errorf("%w: policies (%q) define custom rules but no engine is set, see SetCustomRulesEngine()",
+			errs.ErrorInvalidInput, ids)
+	}
+	p.rules = make(map[string]CustomRulesEvaluator, len(rules))
+	for _, id := range ids {
+		evaluator, err := p.rulesEngine.Compile(id, CustomRules{
+			Module: rules[id].Module,
+			Path:   rules[id].Path,
+		})
+		if err != nil {
+			return fmt.Errorf("[project] %w: failed to compile custom rules of policy (%q): %w",
+				errs.ErrorInvalidField, id, err)
+		}
+		p.rules[id] = evaluator
+	}
+	return nil
+}
+
+// evaluateCustomRules evaluates the custom rules of the project
+// policy, if any. A deny decision wraps errs.ErrorVerification.
+func (p *Policy) evaluateCustomRules(policyID string, input CustomRulesInput) error {
+	evaluator, exists := p.rules[policyID]
+	if !exists {
+		return nil
+	}
+	decision, err := evaluator.Evaluate(input)
+	if err != nil {
+		return fmt.Errorf("[project] %w: failed to evaluate custom rules: %w", errs.ErrorInternal, err)
+	}
+	if decision.Deny {
+		msg := strings.TrimSpace(decision.Message)
+		if msg == "" {
+			msg = "no message"
+		}
+		return fmt.Errorf("[project] %w: denied by custom rules: %s", errs.ErrorVerification, msg)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package deployment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// rulesEngine compiles modules "allow", "deny" and "error".
+type rulesEngine struct{}
+
+func (e *rulesEngine) Compile(policyID string, rules CustomRules) (CustomRulesEvaluator, error) {
+	switch rules.Module {
+	case "allow", "deny", "error":
+		return &rulesEvaluator{module: rules.Module}, nil
+	}
+	return nil, errors.New("invalid module")
+}
+
+type rulesEvaluator struct {
+	module string
+	inputs []CustomRulesInput
+}
+
+func (e *rulesEvaluator) Evaluate(input CustomRulesInput) (CustomRulesDecision, error) {
+	e.inputs = append(e.inputs, input)
+	switch e.module {
+	case "deny":
+		return CustomRulesDecision{Deny: true, Message: "package not allowed"}, nil
+	case "error":
+		return CustomRulesDecision{}, errors.New("undefined rule")
+	}
+	return CustomRulesDecision{}, nil
+}
+
+func Test_CustomRules(t *testing.T) {
+	t.Parallel()
+	publishrID := "publishr_id"
+	packageName := "package_uri"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Publish: []organization.Root{
+				{
+					ID: publishrID,
+					Build: organization.Build{
+						MaxSlsaLevel: common.AsPointer(3),
+					},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name        string
+		rules       *project.CustomRules
+		engine      CustomRulesEngine
+		creationErr error
+		expected    error
+	}{
+		{
+			name: "no rules",
+		},
+		{
+			name:   "no rules with engine",
+			engine: &rulesEngine{},
+		},
+		{
+			name:   "allow",
+			rules:  &project.CustomRules{Module: "allow"},
+			engine: &rulesEngine{},
+		},
+		{
+			name:     "deny",
+			rules:    &project.CustomRules{Module: "deny"},
+			engine:   &rulesEngine{},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "evaluation error",
+			rules:    &project.CustomRules{Module: "error"},
+			engine:   &rulesEngine{},
+			expected: errs.ErrorInternal,
+		},
+		{
+			name:        "compilation error",
+			rules:       &project.CustomRules{Module: "invalid"},
+			engine:      &rulesEngine{},
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name:        "no engine",
+			rules:       &project.CustomRules{Module: "allow"},
+			creationErr: errs.ErrorInvalidInput,
+		},
+		{
+			name:        "module and path",
+			rules:       &project.CustomRules{Module: "allow", Path: "rules.rego"},
+			engine:      &rulesEngine{},
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name:        "empty rules",
+			rules:       &project.CustomRules{},
+			engine:      &rulesEngine{},
+			creationErr: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			orgContent, err := json.Marshal(org)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			projectContent, err := json.Marshal(project.Policy{
+				Format: 1,
+				BuildRequirements: project.BuildRequirements{
+					RequireSlsaLevel: common.AsPointer(2),
+				},
+				Principal: project.Principal{
+					URI: "principal_uri",
+				},
+				Packages: []project.Package{
+					{
+						Name: packageName,
+					},
+				},
+				CustomRules: tt.rules,
+			})
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			var opts []PolicyOption
+			if tt.engine != nil {
+				opts = append(opts, SetCustomRulesEngine(tt.engine))
+			}
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+				common.NewNamedBytesIterator([][]byte{projectContent}, true), opts...)
+			if diff := cmp.Diff(tt.creationErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			verifier := NewE2eAttestationVerifier(digests, packageName, "", publishrID, 2)
+			result := pol.Evaluate(digests, packageName, "policy_id0", AttestationVerificationOption{
+				Verifier: verifier,
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.rules == nil {
+				return
+			}
+			evaluator := pol.rules["policy_id0"].(*rulesEvaluator)
+			expected := []CustomRulesInput{
+				{
+					Request: CustomRulesRequest{
+						Digests:  digests,
+						Package:  packageName,
+						PolicyID: "policy_id0",
+						Scopes: map[string]string{
+							ScopeKubernetesServiceAccount(): "principal_uri",
+						},
+					},
+					Attestation: CustomRulesAttestation{
+						PublishRootID: publishrID,
+						BuildLevel:    2,
+					},
+				},
+			}
+			if diff := cmp.Diff(expected, evaluator.inputs); diff != "" {
+				t.Fatalf("unexpected inputs (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package deployment
+
+import (
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+)
+
+// OrganizationSchema returns the JSON Schema the organization
+// policy is validated against, e.g. for editors and linters.
+func OrganizationSchema() []byte {
+	return organization.Schema()
+}
+
+// ProjectSchema returns the JSON Schema the project
+// policies are validated against.
+func ProjectSchema() []byte {
+	return project.Schema()
+}
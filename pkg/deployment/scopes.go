@@ -0,0 +1,195 @@
+package deployment
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Well-known scope keys.
+const (
+	// ScopeKeyKubernetesServiceAccount is the scope key
+	// of the Kubernetes service account.
+	ScopeKeyKubernetesServiceAccount = project.ScopeKubernetesServiceAccount
+)
+
+// KnownScopeKeys returns the well-known scope keys. Verify() rejects
+// other keys unless AllowUnknownScopes() is passed.
+func KnownScopeKeys() []string {
+	return []string{ScopeKeyKubernetesServiceAccount}
+}
+
+// ScopeMismatch describes a scope that failed verification.
+type ScopeMismatch struct {
+	Key string
+	// Expected is the value in the request. It is empty if
+	// the scope is present in the attestation only.
+	Expected string
+	// Got is the value in the attestation. It is empty if
+	// the scope is present in the request only.
+	Got string
+}
+
+// ScopeMismatchError is returned by Verify() when scopes do not match,
+// wrapped in an errs.VerificationError for errs.CheckScope.
+// It lists every failing scope, sorted by key, and wraps errs.ErrorMismatch.
+type ScopeMismatchError struct {
+	Mismatches []ScopeMismatch
+}
+
+// maxScopeValueLength is the length above which scope
+// values are truncated in error messages.
+const maxScopeValueLength = 64
+
+func (e *ScopeMismatchError) Error() string {
+	details := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		details = append(details, fmt.Sprintf("scope (%q): expected %s, got %s", m.Key,
+			scopeValue(m.Expected), scopeValue(m.Got)))
+	}
+	return fmt.Sprintf("%v: %s", errs.ErrorMismatch, strings.Join(details, "; "))
+}
+
+func (e *ScopeMismatchError) Unwrap() error {
+	return errs.ErrorMismatch
+}
+
+func scopeValue(value string) string {
+	if value == "" {
+		return "none"
+	}
+	if len(value) > maxScopeValueLength {
+		value = value[:maxScopeValueLength-3] + "..."
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// WithOptionalScope allows the scope key of the request
+// to be absent from the attestation. If present, its
+// value must match.
+func WithOptionalScope(key string) VerificationOption {
+	return func(v *Verification) error {
+		if key == "" {
+			return fmt.Errorf("%w: empty scope key", errs.ErrorInvalidInput)
+		}
+		if !slices.Contains(v.optionalScopes, key) {
+			v.optionalScopes = append(slices.Clip(v.optionalScopes), key)
+		}
+		return nil
+	}
+}
+
+// AllowExtraScopes allows any scope of the request to be
+// absent from the attestation. Scopes of the attestation must
+// still be present in the request.
+func AllowExtraScopes() VerificationOption {
+	return func(v *Verification) error {
+		v.extraScopes = true
+		return nil
+	}
+}
+
+// AllowUnknownScopes allows scope keys of the request, including
+// the keys passed to WithOptionalScope(), that are not in
+// KnownScopeKeys(), e.g. the scopes of a principal.
+func AllowUnknownScopes() VerificationOption {
+	return func(v *Verification) error {
+		v.unknownScopes = true
+		return nil
+	}
+}
+
+// validateRequestScopes returns ErrorInvalidInput if a scope of the
+// request has an empty key or value, or if a scope key of the request
+// or an optional scope key is unknown and unknown scopes are not allowed.
+func (v *Verification) validateRequestScopes(scopes map[string]string) error {
+	for key, value := range scopes {
+		if key == "" {
+			return fmt.Errorf("%w: empty scope key", errs.ErrorInvalidInput)
+		}
+		if value == "" {
+			return fmt.Errorf("%w: scope (%q) has an empty value", errs.ErrorInvalidInput, key)
+		}
+	}
+	if v.unknownScopes {
+		return nil
+	}
+	known := KnownScopeKeys()
+	for key := range scopes {
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("%w: unknown scope key (%q). Must be one of %q", errs.ErrorInvalidInput, key, known)
+		}
+	}
+	for _, key := range v.optionalScopes {
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("%w: unknown optional scope key (%q). Must be one of %q", errs.ErrorInvalidInput, key, known)
+		}
+	}
+	return nil
+}
+
+// validateScopes returns ErrorInvalidField if a scope
+// has an empty key or value.
+func validateScopes(scopes map[string]string) error {
+	for key, value := range scopes {
+		if key == "" {
+			return fmt.Errorf("%w: empty scope key", errs.ErrorInvalidField)
+		}
+		if value == "" {
+			return fmt.Errorf("%w: scope (%q) has an empty value", errs.ErrorInvalidField, key)
+		}
+	}
+	return nil
+}
+
+// validatePrincipalScopes validates the scopes of a principal
+// other than its URI.
+func validatePrincipalScopes(scopes map[string]string) error {
+	if err := validateScopes(scopes); err != nil {
+		return err
+	}
+	if _, exists := scopes[ScopeKeyKubernetesServiceAccount]; exists {
+		return fmt.Errorf("%w: scope (%q) must be set by the principal URI", errs.ErrorInvalidField,
+			ScopeKeyKubernetesServiceAccount)
+	}
+	return nil
+}
+
+// verifyScopes compares every scope of the request and the
+// attestation individually. All the scopes must match.
+func (v *Verification) verifyScopes(scopes map[string]string) error {
+	return compareScopes(scopes, v.attestation.Predicate.Scopes, func(key string) bool {
+		return v.extraScopes || slices.Contains(v.optionalScopes, key)
+	})
+}
+
+// compareScopes compares every scope of the request and of actual
+// individually. Scopes of the request for which optional returns
+// true may be absent from actual.
+func compareScopes(scopes, actual map[string]string, optional func(key string) bool) error {
+	var mismatches []ScopeMismatch
+	for key, expected := range scopes {
+		got, exists := actual[key]
+		if !exists && optional(key) {
+			continue
+		}
+		if !exists || got != expected {
+			mismatches = append(mismatches, ScopeMismatch{Key: key, Expected: expected, Got: got})
+		}
+	}
+	for key, got := range actual {
+		if _, exists := scopes[key]; !exists {
+			mismatches = append(mismatches, ScopeMismatch{Key: key, Got: got})
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	slices.SortFunc(mismatches, func(a, b ScopeMismatch) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return errs.VerificationErrorNew(errs.CheckScope, "", "", &ScopeMismatchError{Mismatches: mismatches})
+}
@@ -0,0 +1,36 @@
+package deployment
+
+// PolicyStats contains statistics about a policy.
+// Lists are sorted.
+type PolicyStats struct {
+	Projects int `json:"projects"`
+	// PackagesPerProject maps a number of packages to the
+	// number of projects defining that many packages.
+	PackagesPerProject map[int]int `json:"packages_per_project"`
+	// Environments lists the environments used by packages.
+	Environments               []string `json:"environments"`
+	PackagesWithoutEnvironment int      `json:"packages_without_environment"`
+	// Levels maps a required SLSA build level to the number of packages
+	// requiring it.
+	Levels map[int]int `json:"levels"`
+	// ReferencedRoots lists the IDs of the publish roots
+	// that at least one project can use.
+	ReferencedRoots []string `json:"referenced_roots"`
+	// UnreferencedRoots lists the IDs of the publish roots
+	// that no project can ever use.
+	UnreferencedRoots []string `json:"unreferenced_roots"`
+}
+
+// Stats returns statistics about the policy.
+func (p *Policy) Stats() PolicyStats {
+	stats := p.policy.Stats()
+	return PolicyStats{
+		Projects:                   stats.Projects,
+		PackagesPerProject:         stats.PackagesPerProject,
+		Environments:               stats.Environments,
+		PackagesWithoutEnvironment: stats.PackagesWithoutEnvironment,
+		Levels:                     stats.Levels,
+		ReferencedRoots:            stats.ReferencedRoots,
+		UnreferencedRoots:          stats.UnreferencedRoots,
+	}
+}
@@ -0,0 +1,188 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Validate checks the internal consistency of the attestation, without
+// any input, e.g. so that registries can lint attestations at upload:
+//   - the statement and predicate types,
+//   - the digests of every subject, not only of the verified one,
+//   - the creation time,
+//   - the scopes,
+//   - the types and values of the properties this library writes,
+//     and of the decision details.
+//
+// Unlike publish attestations, deployment attestations have no package
+// descriptor to compare the subjects to. Validate does not verify the
+// signatures of a DSSE envelope. All the violations are returned, joined,
+// and wrap errs.ErrorInvalidField.
+func (v *Verification) Validate() error {
+	var allErrs []error
+	add := func(err error) {
+		if err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	att := &v.attestation
+	// Types.
+	if att.Header.Type != statementType {
+		add(fmt.Errorf("%w: statement type (%q) != intoto type (%q)", errs.ErrorInvalidField,
+			att.Header.Type, statementType))
+	}
+	if att.Header.PredicateType != predicateType {
+		add(fmt.Errorf("%w: predicate type (%q) != deployment type (%q)", errs.ErrorInvalidField,
+			att.Header.PredicateType, predicateType))
+	}
+	// Subjects.
+	if len(att.Header.Subjects) == 0 {
+		add(fmt.Errorf("%w: no subjects in attestation", errs.ErrorInvalidField))
+	}
+	for i := range att.Header.Subjects {
+		add(validateSubject(i, att.Header.Subjects[i]))
+	}
+	// Creation time.
+	if _, err := intoto.ParseTime(att.Predicate.CreationTime); err != nil {
+		add(fmt.Errorf("creation time: %w", err))
+	}
+	// Scopes.
+	for key, value := range att.Predicate.Scopes {
+		if key == "" || value == "" {
+			add(fmt.Errorf("%w: scope (%q:%q) has an empty key or value", errs.ErrorInvalidField, key, value))
+		}
+	}
+	// Decision details.
+	if details := att.Predicate.DecisionDetails; details != nil {
+		for i := range details.Policy {
+			add(validateDescriptor("policy", i, details.Policy[i]))
+		}
+		for i := range details.Evidence {
+			add(validateDescriptor("evidence", i, details.Evidence[i]))
+		}
+	}
+	// Properties.
+	allErrs = append(allErrs, v.validateProperties()...)
+	return errors.Join(allErrs...)
+}
+
+// validateSubject validates the digests of the subject at index i.
+func validateSubject(i int, subject intoto.Subject) error {
+	if err := intoto.ValidateSubject(subject); err != nil {
+		return fmt.Errorf("subject #%d (%q): %w", i, subject.Name, err)
+	}
+	if alg, conflicts := subject.Digests.ConflictingAlgorithm(); conflicts {
+		return fmt.Errorf("%w: subject #%d (%q): digest (%q) has conflicting values", errs.ErrorInvalidField,
+			i, subject.Name, alg)
+	}
+	return nil
+}
+
+// validateDescriptor validates the resource descriptor at index i of the decision details.
+func validateDescriptor(kind string, i int, desc intoto.ResourceDescriptor) error {
+	if desc.Name == "" && desc.URI == "" {
+		return fmt.Errorf("%w: %s #%d has no name and no URI", errs.ErrorInvalidField, kind, i)
+	}
+	if desc.Digest != nil {
+		if err := desc.Digest.Validate(); err != nil {
+			return fmt.Errorf("%s #%d (%q): %w", kind, i, desc.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateProperties validates the properties this library writes, if present.
+func (v *Verification) validateProperties() []error {
+	props := v.attestation.Predicate.Properties
+	var allErrs []error
+	if value, exists := props[publishRootProperty]; exists {
+		if s, ok := value.(string); !ok || s == "" {
+			allErrs = append(allErrs, fmt.Errorf("%w: property (%q) value (%T:%v) is not a non-empty string",
+				errs.ErrorInvalidField, publishRootProperty, value, value))
+		}
+	}
+	if _, exists := props[buildLevelProperty]; exists {
+		level, err := v.intProperty(buildLevelProperty)
+		if err == nil {
+			if err := validateLevel(level); err != nil {
+				allErrs = append(allErrs, fmt.Errorf("%w: property (%q): %v", errs.ErrorInvalidField,
+					buildLevelProperty, err))
+			}
+		} else {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if value, exists := props[digestAlgorithmsProperty]; exists {
+		if err := validateStrings(digestAlgorithmsProperty, value); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if value, exists := props[exceptionProperty]; exists {
+		if err := validateException(value); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if value, exists := props[expiresAtProperty]; exists {
+		if s, ok := value.(string); !ok {
+			allErrs = append(allErrs, fmt.Errorf("%w: property (%q) value (%T:%v) is not a string",
+				errs.ErrorInvalidField, expiresAtProperty, value, value))
+		} else if _, err := intoto.ParseTime(s); err != nil {
+			allErrs = append(allErrs, fmt.Errorf("property (%q): %w", expiresAtProperty, err))
+		}
+	}
+	if value, exists := props[nonceProperty]; exists {
+		if s, ok := value.(string); !ok || s == "" {
+			allErrs = append(allErrs, fmt.Errorf("%w: property (%q) value (%T:%v) is not a non-empty string",
+				errs.ErrorInvalidField, nonceProperty, value, value))
+		}
+	}
+	for _, name := range []string{evaluationDurationProperty, verifierCallsProperty} {
+		if _, exists := props[name]; !exists {
+			continue
+		}
+		if _, err := v.intProperty(name); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+// validateStrings validates that the value of the property
+// is a non-empty list of non-empty strings.
+func validateStrings(name string, value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return fmt.Errorf("%w: property (%q) value (%T:%v) is not a non-empty list", errs.ErrorInvalidField,
+			name, value, value)
+	}
+	for _, item := range list {
+		if s, ok := item.(string); !ok || s == "" {
+			return fmt.Errorf("%w: property (%q) item (%T:%v) is not a non-empty string", errs.ErrorInvalidField,
+				name, item, item)
+		}
+	}
+	return nil
+}
+
+// validateException validates the value of the exception property.
+func validateException(value interface{}) error {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: property (%q) value (%T:%v) is not an object", errs.ErrorInvalidField,
+			exceptionProperty, value, value)
+	}
+	for _, key := range []string{"digest", "reason", "expires"} {
+		if s, ok := fields[key].(string); !ok || s == "" {
+			return fmt.Errorf("%w: property (%q) field (%q) value (%T:%v) is not a non-empty string",
+				errs.ErrorInvalidField, exceptionProperty, key, fields[key], fields[key])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, fields["expires"].(string)); err != nil {
+		return fmt.Errorf("%w: property (%q) expiry: %v", errs.ErrorInvalidField, exceptionProperty, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package publishattestation
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// MemoryFetcher is a Fetcher that keeps the attestations in memory,
+// e.g. for tests. It is safe for concurrent use.
+type MemoryFetcher struct {
+	mu      sync.RWMutex
+	entries []memoryEntry
+}
+
+type memoryEntry struct {
+	digests     intoto.DigestSet
+	packageName string
+	att         Attestation
+}
+
+var _ Fetcher = (*MemoryFetcher)(nil)
+
+// Add adds an attestation of the package with digests.
+func (f *MemoryFetcher) Add(digests intoto.DigestSet, packageName string, att Attestation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	att.Content = slices.Clone(att.Content)
+	f.entries = append(f.entries, memoryEntry{
+		digests:     maps.Clone(digests),
+		packageName: packageName,
+		att:         att,
+	})
+}
+
+// PublishAttestations implements Fetcher. It returns the attestations
+// added for the package with the same digests, in the order they were added.
+func (f *MemoryFetcher) PublishAttestations(digests intoto.DigestSet, packageName string) ([]Attestation, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var atts []Attestation
+	for i := range f.entries {
+		entry := &f.entries[i]
+		if entry.packageName != packageName || !maps.Equal(entry.digests, digests) {
+			continue
+		}
+		att := entry.att
+		att.Content = slices.Clone(att.Content)
+		atts = append(atts, att)
+	}
+	return atts, nil
+}
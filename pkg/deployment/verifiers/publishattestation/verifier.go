@@ -0,0 +1,270 @@
+// Package publishattestation verifies the publish attestations that
+// deployment policies require, see the publish package. Its Verifier
+// implements deployment.AttestationVerifier.
+package publishattestation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Attestation is a publish attestation fetched for a package.
+type Attestation struct {
+	// Content is the DSSE envelope of the attestation. The publish
+	// root that signed it is the root whose key verifies it, see Roots.
+	Content []byte
+}
+
+// Roots maps the ID of each trusted publish root to
+// the verifier of the signatures of the root.
+type Roots map[string]intoto.SignatureVerifier
+
+// Fetcher fetches the publish attestations of packages,
+// e.g. from the registry the package is stored in.
+type Fetcher interface {
+	// PublishAttestations returns the publish attestations
+	// of the package with digests.
+	PublishAttestations(digests intoto.DigestSet, packageName string) ([]Attestation, error)
+}
+
+// Verifier verifies the publish attestations of packages.
+// It implements deployment.AttestationVerifier.
+type Verifier struct {
+	fetcher       Fetcher
+	packageHelper publish.PackageHelper
+	roots         Roots
+	registry      string
+}
+
+var _ deployment.AttestationVerifier = (*Verifier)(nil)
+
+// VerifierOption defines a verifier option.
+type VerifierOption func(*Verifier) error
+
+// VerifierNew creates a verifier of the publish attestations fetched
+// by fetcher. The package names of the deployment policy are mapped
+// to the package descriptors of the attestations by packageHelper.
+// An attestation is attributed to a publish root only if it is signed
+// by the root, as verified by its entry in roots.
+func VerifierNew(fetcher Fetcher, packageHelper publish.PackageHelper, roots Roots,
+	opts ...VerifierOption) (*Verifier, error) {
+	if fetcher == nil {
+		return nil, fmt.Errorf("%w: fetcher is nil", errs.ErrorInvalidInput)
+	}
+	if packageHelper == nil {
+		return nil, fmt.Errorf("%w: package helper is nil", errs.ErrorInvalidInput)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("%w: no publish roots", errs.ErrorInvalidInput)
+	}
+	for id, signatures := range roots {
+		if signatures == nil {
+			return nil, fmt.Errorf("%w: signature verifier of publish root (%q) is nil", errs.ErrorInvalidInput, id)
+		}
+	}
+	v := Verifier{
+		fetcher:       fetcher,
+		packageHelper: packageHelper,
+		roots:         maps.Clone(roots),
+	}
+	for _, option := range opts {
+		if err := option(&v); err != nil {
+			return nil, err
+		}
+	}
+	return &v, nil
+}
+
+// WithRegistry verifies that the package was published to registry,
+// see publish.IsPackageRegistry().
+func WithRegistry(registry string) VerifierOption {
+	return func(v *Verifier) error {
+		return v.setRegistry(registry)
+	}
+}
+
+func (v *Verifier) setRegistry(registry string) error {
+	if registry == "" {
+		return fmt.Errorf("%w: registry is empty", errs.ErrorInvalidInput)
+	}
+	v.registry = registry
+	return nil
+}
+
+// VerifyPublishAttestation implements deployment.AttestationVerifier. It
+// returns the environment of the first attestation that is verified. The
+// environment is nil if none is requested, in which case the attestation
+// must not have one. It returns errs.ErrorVerification if no attestation
+// is verified. Publish roots defined by a certificate identity are not
+// supported, since the signatures are verified against keys.
+func (v *Verifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	if opts.Identity != nil {
+		return nil, fmt.Errorf("%w: publish root identity (%q, %q) is not supported", errs.ErrorInvalidInput,
+			opts.Identity.Issuer, opts.Identity.SubjectRegex)
+	}
+	rootIDs, err := v.rootIDs(opts)
+	if err != nil {
+		return nil, err
+	}
+	atts, err := v.fetcher.PublishAttestations(digests, packageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch publish attestations of (%q): %w", packageName, err)
+	}
+	if len(atts) == 0 {
+		return nil, fmt.Errorf("%w: no publish attestation for (%q)", errs.ErrorVerification, packageName)
+	}
+	var errList []error
+	for i := range atts {
+		env, err := v.verify(&atts[i], digests, packageName, environment, rootIDs, opts)
+		if errors.Is(err, errs.ErrorInvalidInput) {
+			return nil, err
+		}
+		if err != nil {
+			errList = append(errList, fmt.Errorf("attestation %d: %w", i, err))
+			continue
+		}
+		return env, nil
+	}
+	return nil, fmt.Errorf("%w: no publish attestation of (%q) verified: %w", errs.ErrorVerification,
+		packageName, errors.Join(errList...))
+}
+
+// rootIDs returns the sorted IDs of the trusted roots that are the publish root of opts.
+func (v *Verifier) rootIDs(opts deployment.AttestationVerifierPublishOptions) ([]string, error) {
+	var ids []string
+	for id := range v.roots {
+		if err := MatchRoot(id, opts); err != nil {
+			if errors.Is(err, errs.ErrorInvalidInput) {
+				return nil, err
+			}
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		root := opts.PublishrID
+		if root == "" {
+			root = opts.PublishrIDRegex
+		}
+		return nil, fmt.Errorf("%w: publish root (%q) is not trusted", errs.ErrorVerification, root)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// verify verifies the attestation with the signature verifier of each root.
+func (v *Verifier) verify(att *Attestation, digests intoto.DigestSet, packageName string,
+	environment []string, rootIDs []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	var checks []publish.VerificationOption
+	if v.registry != "" {
+		checks = append(checks, publish.IsPackageRegistry(v.registry))
+	}
+	var errList []error
+	for _, id := range rootIDs {
+		env, err := VerifyAttestation(att.Content, v.packageHelper, digests, packageName, environment, opts,
+			append(slices.Clip(checks), publish.WithDSSEVerifier(v.roots[id]))...)
+		if errors.Is(err, errs.ErrorInvalidInput) {
+			return nil, err
+		}
+		if err != nil {
+			errList = append(errList, fmt.Errorf("publish root (%q): %w", id, err))
+			continue
+		}
+		return env, nil
+	}
+	return nil, errors.Join(errList...)
+}
+
+// MatchRoot verifies that rootID is the publish root of opts, i.e.
+// opts.PublishrID or, if it is not set, a string that
+// opts.PublishrIDRegex matches entirely. It returns
+// errs.ErrorMismatch if it is not.
+func MatchRoot(rootID string, opts deployment.AttestationVerifierPublishOptions) error {
+	if opts.PublishrID != "" {
+		if rootID != opts.PublishrID {
+			return fmt.Errorf("%w: publish root ID (%q) != (%q)", errs.ErrorMismatch, rootID, opts.PublishrID)
+		}
+		return nil
+	}
+	if opts.PublishrIDRegex == "" {
+		return fmt.Errorf("%w: publish root ID and regex are empty", errs.ErrorInvalidInput)
+	}
+	re, err := regexp.Compile("^(?:" + opts.PublishrIDRegex + ")$")
+	if err != nil {
+		return fmt.Errorf("%w: invalid publish root ID regex (%q): %v", errs.ErrorInvalidInput,
+			opts.PublishrIDRegex, err)
+	}
+	if !re.MatchString(rootID) {
+		return fmt.Errorf("%w: publish root ID (%q) does not match (%q)", errs.ErrorMismatch,
+			rootID, opts.PublishrIDRegex)
+	}
+	return nil
+}
+
+// VerifyAttestation verifies the publish attestation in content for
+// the package as VerifyPublishAttestation does, with the additional
+// checks, e.g. publish.WithDSSEVerifier(). It does not verify the
+// publish root, see MatchRoot.
+func VerifyAttestation(content []byte, packageHelper publish.PackageHelper, digests intoto.DigestSet,
+	packageName string, environment []string, opts deployment.AttestationVerifierPublishOptions,
+	checks ...publish.VerificationOption) (*string, error) {
+	verification, err := publish.VerificationNew(io.NopCloser(bytes.NewReader(content)), packageHelper)
+	if err != nil {
+		return nil, err
+	}
+	checks = append([]publish.VerificationOption{
+		publish.IsSlsaBuildLevelOrAbove(opts.MinBuildLevel),
+	}, checks...)
+	if opts.MinAuthorVersion != "" {
+		checks = append(checks, publish.IsAuthorVersionAtLeast(opts.MinAuthorVersion))
+	}
+	// No environment requested: the attestation must not have one.
+	if len(environment) == 0 {
+		checks = append(checks, publish.IsPackageEnvironment(""))
+		return nil, verification.Verify(digests, packageName, checks...)
+	}
+	verified, err := verification.VerifyAttestation(context.Background(), digests, packageName, checks...)
+	if err != nil {
+		return nil, err
+	}
+	return matchEnvironment(verified.Package.Environment, environment)
+}
+
+// matchEnvironment returns the environment of the attestation if it
+// matches one of the requested environments, which may be wildcards
+// such as "prod/*", see path.Match.
+func matchEnvironment(attEnv string, environment []string) (*string, error) {
+	if attEnv == "" {
+		return nil, fmt.Errorf("%w: no environment, expected one of (%q)", errs.ErrorMismatch, environment)
+	}
+	for _, env := range environment {
+		if env == attEnv {
+			return &attEnv, nil
+		}
+		if !strings.Contains(env, "*") {
+			continue
+		}
+		matched, err := path.Match(env, attEnv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid environment (%q): %v", errs.ErrorInvalidInput, env, err)
+		}
+		if matched {
+			return &attEnv, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: environment (%q) not in (%q)", errs.ErrorMismatch, attEnv, environment)
+}
@@ -0,0 +1,348 @@
+package publishattestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/deploymenttest"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+type packageHelper struct{}
+
+func (p *packageHelper) PolicyPackageName(desc intoto.PackageDescriptor) (string, error) {
+	return desc.Name, nil
+}
+
+func (p *packageHelper) PackageDescriptor(name string) (intoto.PackageDescriptor, error) {
+	return intoto.PackageDescriptor{
+		Name:     name,
+		Registry: "registry",
+	}, nil
+}
+
+type failingFetcher struct {
+	err error
+}
+
+func (f *failingFetcher) PublishAttestations(intoto.DigestSet, string) ([]Attestation, error) {
+	return nil, f.err
+}
+
+// publishRoots contains the keys of the publish roots.
+type publishRoots map[string]*ecdsa.PrivateKey
+
+func newPublishRoots(t *testing.T, ids ...string) publishRoots {
+	t.Helper()
+	keys := publishRoots{}
+	for _, id := range ids {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		keys[id] = key
+	}
+	return keys
+}
+
+// roots returns the signature verifiers of the publish roots.
+func (r publishRoots) roots(t *testing.T) Roots {
+	t.Helper()
+	roots := Roots{}
+	for id, key := range r {
+		signatures, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+		if err != nil {
+			t.Fatalf("failed to create verifier: %v", err)
+		}
+		roots[id] = signatures
+	}
+	return roots
+}
+
+// sign returns the envelope of the attestation, signed by its publisher.
+func (r publishRoots) sign(t *testing.T, att deploymenttest.PublishAttestation) []byte {
+	t.Helper()
+	statement, err := att.Statement()
+	if err != nil {
+		t.Fatalf("failed to create statement: %v", err)
+	}
+	hash := sha256.Sum256(intoto.PAE(intoto.PayloadType, statement))
+	sig, err := ecdsa.SignASN1(rand.Reader, r[att.PublisherID], hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	envelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	return envelope
+}
+
+func newFetcher(t *testing.T, keys publishRoots, atts ...deploymenttest.PublishAttestation) *MemoryFetcher {
+	t.Helper()
+	var fetcher MemoryFetcher
+	for _, att := range atts {
+		fetcher.Add(att.Digests, att.PackageName, Attestation{
+			Content: keys.sign(t, att),
+		})
+	}
+	return &fetcher
+}
+
+func Test_RunVerifierConformance(t *testing.T) {
+	t.Parallel()
+	keys := newPublishRoots(t, "publishr_id", "other_publishr_id")
+	deploymenttest.RunVerifierConformance(t, func(t *testing.T, att deploymenttest.PublishAttestation) deployment.AttestationVerifier {
+		verifier, err := VerifierNew(newFetcher(t, keys, att), &packageHelper{}, keys.roots(t))
+		if err != nil {
+			t.Fatalf("failed to create verifier: %v", err)
+		}
+		return verifier
+	})
+}
+
+func Test_VerifyPublishAttestation(t *testing.T) {
+	t.Parallel()
+	golden := deploymenttest.GoldenAttestation()
+	other := golden
+	other.Environment = "dev"
+	other.PublisherID = "other_publishr_id"
+	untrusted := golden
+	untrusted.PublisherID = "untrusted_publishr_id"
+	keys := newPublishRoots(t, golden.PublisherID, other.PublisherID, untrusted.PublisherID)
+	roots := keys.roots(t)
+	delete(roots, untrusted.PublisherID)
+	statement, err := golden.Statement()
+	if err != nil {
+		t.Fatalf("failed to create statement: %v", err)
+	}
+	var unsigned MemoryFetcher
+	unsigned.Add(golden.Digests, golden.PackageName, Attestation{Content: statement})
+	transient := errs.TransientErrorNew(errors.New("503 service unavailable"), 0)
+	opts := deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 3}
+	tests := []struct {
+		name        string
+		fetcher     Fetcher
+		options     []VerifierOption
+		environment []string
+		opts        deployment.AttestationVerifierPublishOptions
+		expected    error
+		env         *string
+	}{
+		{
+			name:        "second attestation verified",
+			fetcher:     newFetcher(t, keys, other, golden),
+			environment: []string{"dev", "prod"},
+			opts:        opts,
+			env:         &golden.Environment,
+		},
+		{
+			name:        "root regex",
+			fetcher:     newFetcher(t, keys, other),
+			environment: []string{"dev"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrIDRegex: ".*publishr_id", MinBuildLevel: 3},
+			env:         &other.Environment,
+		},
+		{
+			name:        "root regex prefix",
+			fetcher:     newFetcher(t, keys, golden),
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrIDRegex: "publishr", MinBuildLevel: 3},
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "signed by other root",
+			fetcher:     newFetcher(t, keys, other),
+			environment: []string{"dev"},
+			opts:        opts,
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "signed by untrusted root",
+			fetcher:     newFetcher(t, keys, untrusted),
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrIDRegex: ".*", MinBuildLevel: 3},
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "untrusted root",
+			fetcher:     newFetcher(t, keys, untrusted),
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: untrusted.PublisherID, MinBuildLevel: 3},
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "unsigned statement",
+			fetcher:     &unsigned,
+			environment: []string{"prod"},
+			opts:        opts,
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "identity",
+			fetcher:     newFetcher(t, keys, golden),
+			environment: []string{"prod"},
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "^https://github.com/org/.*$",
+				MinBuildLevel:   3,
+				Identity: &deployment.RootIdentity{
+					Issuer:       "https://token.actions.githubusercontent.com",
+					SubjectRegex: regexp.MustCompile("^https://github.com/org/.*$"),
+				},
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:        "registry",
+			fetcher:     newFetcher(t, keys, golden),
+			options:     []VerifierOption{WithRegistry("https://registry/")},
+			environment: []string{"prod"},
+			opts:        opts,
+			env:         &golden.Environment,
+		},
+		{
+			name:        "registry mismatch",
+			fetcher:     newFetcher(t, keys, golden),
+			options:     []VerifierOption{WithRegistry("other_registry")},
+			environment: []string{"prod"},
+			opts:        opts,
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "no attestation",
+			fetcher:     newFetcher(t, keys),
+			environment: []string{"prod"},
+			opts:        opts,
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "transient fetch error",
+			fetcher:     &failingFetcher{err: transient},
+			environment: []string{"prod"},
+			opts:        opts,
+			expected:    errs.ErrorTransient,
+		},
+		{
+			name:        "no publish root",
+			fetcher:     newFetcher(t, keys, golden),
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{MinBuildLevel: 3},
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name:        "invalid build level",
+			fetcher:     newFetcher(t, keys, golden),
+			environment: []string{"prod"},
+			opts:        deployment.AttestationVerifierPublishOptions{PublishrID: "publishr_id", MinBuildLevel: 5},
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name:        "invalid environment pattern",
+			fetcher:     newFetcher(t, keys, golden),
+			environment: []string{"[*"},
+			opts:        opts,
+			expected:    errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifier, err := VerifierNew(tt.fetcher, &packageHelper{}, roots, tt.options...)
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			env, err := verifier.VerifyPublishAttestation(golden.Digests, golden.PackageName, tt.environment, tt.opts)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.env, env); diff != "" {
+				t.Fatalf("unexpected env (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_VerifierNew(t *testing.T) {
+	t.Parallel()
+	roots := newPublishRoots(t, "publishr_id").roots(t)
+	tests := []struct {
+		name          string
+		fetcher       Fetcher
+		packageHelper *packageHelper
+		roots         Roots
+		options       []VerifierOption
+		expected      error
+	}{
+		{
+			name:          "valid",
+			fetcher:       &MemoryFetcher{},
+			packageHelper: &packageHelper{},
+			roots:         roots,
+			options:       []VerifierOption{WithRegistry("registry")},
+		},
+		{
+			name:          "nil fetcher",
+			packageHelper: &packageHelper{},
+			roots:         roots,
+			expected:      errs.ErrorInvalidInput,
+		},
+		{
+			name:     "nil package helper",
+			fetcher:  &MemoryFetcher{},
+			roots:    roots,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:          "no roots",
+			fetcher:       &MemoryFetcher{},
+			packageHelper: &packageHelper{},
+			expected:      errs.ErrorInvalidInput,
+		},
+		{
+			name:          "nil signature verifier",
+			fetcher:       &MemoryFetcher{},
+			packageHelper: &packageHelper{},
+			roots:         Roots{"publishr_id": nil},
+			expected:      errs.ErrorInvalidInput,
+		},
+		{
+			name:          "empty registry",
+			fetcher:       &MemoryFetcher{},
+			packageHelper: &packageHelper{},
+			roots:         roots,
+			options:       []VerifierOption{WithRegistry("")},
+			expected:      errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var helper publish.PackageHelper
+			if tt.packageHelper != nil {
+				helper = tt.packageHelper
+			}
+			_, err := VerifierNew(tt.fetcher, helper, tt.roots, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -1,97 +1,623 @@
 package deployment
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/checks"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/regex"
 )
 
 type Verification struct {
 	attestation
+	checks *checks.List
+	// optionalScopes contains the requested scopes
+	// that may be absent from the attestation.
+	optionalScopes []string
+	// extraScopes allows all the requested scopes
+	// to be absent from the attestation.
+	extraScopes bool
+	// unknownScopes allows scope keys not in KnownScopeKeys().
+	unknownScopes bool
+	// envelope is the content the verification was created from,
+	// verified by the transparency log.
+	envelope []byte
+	tlog     TransparencyLog
+	// dsse is the envelope the attestation was decoded from,
+	// if any.
+	dsse              *intoto.Envelope
+	dsseVerifier      intoto.SignatureVerifier
+	allowUnsignedDSSE bool
+	revocations       *RevocationList
+	metrics           metrics.Metrics
 }
 
 type VerificationOption func(*Verification) error
 
-func VerificationNew(reader io.ReadCloser) (*Verification, error) {
-	content, err := io.ReadAll(reader)
+// ParseOption defines an option to parse attestations.
+type ParseOption func(*parser) error
+
+// parser defines how attestations are parsed.
+type parser struct {
+	maxSize int64
+}
+
+// WithMaxAttestationSize sets the maximum size of an attestation, or of
+// a bundle of attestations, in bytes. Larger content is rejected with
+// errs.ErrorInvalidInput. It defaults to 4 MiB.
+func WithMaxAttestationSize(size int64) ParseOption {
+	return func(p *parser) error {
+		return p.setMaxSize(size)
+	}
+}
+
+func (p *parser) setMaxSize(size int64) error {
+	if size < 1 {
+		return fmt.Errorf("%w: max attestation size (%d) must be positive", errs.ErrorInvalidInput, size)
+	}
+	p.maxSize = size
+	return nil
+}
+
+func parserNew(opts []ParseOption) (*parser, error) {
+	p := parser{maxSize: limit.DefaultMaxSize}
+	for _, option := range opts {
+		if err := option(&p); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// ParsedAttestation is a structurally valid deployment attestation.
+// It is never modified by verification, so it is safe to cache
+// and share across concurrent Verify calls.
+type ParsedAttestation struct {
+	attestation attestation
+	envelope    []byte
+	dsse        *intoto.Envelope
+}
+
+// ParseAndValidate parses an attestation and validates its structure:
+// statement type, predicate type, subjects and digests. It does not
+// verify conformance to any policy, nor the signatures of a DSSE envelope.
+func ParseAndValidate(reader io.ReadCloser, opts ...ParseOption) (*ParsedAttestation, error) {
+	att, content, dsse, err := parse(reader, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read: %w", err)
+		return nil, err
 	}
-	defer reader.Close()
-	var att attestation
-	if err := json.Unmarshal(content, &att); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	if err := att.validate(); err != nil {
+		return nil, err
+	}
+	return &ParsedAttestation{
+		attestation: *att,
+		envelope:    content,
+		dsse:        dsse,
+	}, nil
+}
+
+// VerificationNew creates a verification for an attestation,
+// either a bare statement or a DSSE envelope. The signatures of an
+// envelope are verified by Verify(), see WithDSSEVerifier().
+func VerificationNew(reader io.ReadCloser, opts ...ParseOption) (*Verification, error) {
+	att, content, dsse, err := parse(reader, opts)
+	if err != nil {
+		return nil, err
 	}
+	v := verificationNew(*att, content)
+	v.dsse = dsse
+	return v, nil
+}
+
+// VerificationFromParsed creates a verification for
+// an attestation returned by ParseAndValidate.
+func VerificationFromParsed(parsed *ParsedAttestation) (*Verification, error) {
+	if parsed == nil {
+		return nil, fmt.Errorf("%w: parsed attestation is nil", errs.ErrorInvalidInput)
+	}
+	v := verificationNew(parsed.attestation, parsed.envelope)
+	v.dsse = parsed.dsse
+	return v, nil
+}
+
+func verificationNew(att attestation, envelope []byte) *Verification {
 	return &Verification{
 		attestation: att,
-	}, nil
+		envelope:    envelope,
+	}
+}
+
+func parse(reader io.ReadCloser, opts []ParseOption) (*attestation, []byte, *intoto.Envelope, error) {
+	p, err := parserNew(opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	content, err := limit.ReadAll(reader, p.maxSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer reader.Close()
+	dsse, err := intoto.ParseEnvelope(content)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	statement := content
+	if dsse != nil {
+		if statement, err = dsse.Statement(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	var att attestation
+	if err := json.Unmarshal(statement, &att); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: failed to unmarshal: %w", errs.ErrorInvalidInput, err)
+	}
+	att.translate()
+	return &att, content, dsse, nil
 }
 
 func (v *Verification) Verify(digests intoto.DigestSet, scopes map[string]string, options ...VerificationOption) error {
+	return v.VerifyContext(context.Background(), digests, scopes, options...)
+}
+
+// VerifyContext is Verify with a context, used by the verifications
+// that require network calls, see RequireTransparencyLog().
+func (v *Verification) VerifyContext(ctx context.Context, digests intoto.DigestSet, scopes map[string]string, options ...VerificationOption) error {
+	return v.verifyContext(ctx, digests, scopes, options...)
+}
+
+// VerifyAttestation is VerifyContext that also returns the content of
+// the attestation once verified. Unlike a flag on the verification, the
+// view belongs to the call, so it is safe for concurrent calls.
+func (v *Verification) VerifyAttestation(ctx context.Context, digests intoto.DigestSet, scopes map[string]string, options ...VerificationOption) (*VerifiedAttestation, error) {
+	if err := v.verifyContext(ctx, digests, scopes, options...); err != nil {
+		return nil, err
+	}
+	return v.verifiedAttestation()
+}
+
+func (v *Verification) verifyContext(ctx context.Context, digests intoto.DigestSet, scopes map[string]string, options ...VerificationOption) error {
+	// Options. Collect them first on a copy,
+	// so that concurrent calls do not share state.
+	vv := *v
+	vv.checks = &checks.List{}
+	for _, option := range options {
+		err := option(&vv)
+		if err != nil {
+			return err
+		}
+	}
+	if vv.metrics == nil {
+		return v.verify(ctx, &vv, digests, scopes)
+	}
+	start := time.Now()
+	err := v.verify(ctx, &vv, digests, scopes)
+	decision, code := metrics.Decision(err)
+	vv.metrics.ObserveVerification(metrics.ComponentDeployment, decision, code, time.Since(start))
+	return err
+}
+
+// verify verifies the attestation with the options collected in vv.
+func (v *Verification) verify(ctx context.Context, vv *Verification, digests intoto.DigestSet, scopes map[string]string) error {
+	// Inputs.
+	digests, err := digests.Normalize()
+	if err != nil {
+		return err
+	}
+	if err := vv.validateRequestScopes(scopes); err != nil {
+		return err
+	}
+	// Revocations.
+	// NOTE: revoked attestations are rejected
+	// before any other verification.
+	if err := vv.verifyRevocations(digests); err != nil {
+		return err
+	}
+	// Structure.
+	if err := v.attestation.validate(); err != nil {
+		return err
+	}
+	// Digests.
+	if err := verifyDigests(v.attestation.Header.Subjects[0].Digests, digests); err != nil {
+		return err
+	}
+
+	// NOTE: the creation time is verified by the
+	// CreatedAfter() and CreatedBefore() options.
+
+	// Signatures.
+	if err := vv.verifyEnvelope(); err != nil {
+		return err
+	}
+	// Scopes.
+	// NOTE: scopes are verified after the options are collected,
+	// since WithOptionalScope() affects their verification.
+	if err := vv.verifyScopes(scopes); err != nil {
+		return err
+	}
+	if err := vv.checks.Run(); err != nil {
+		return err
+	}
+	// NOTE: the transparency log is queried last,
+	// since it may require a network call.
+	if err := vv.verifyInclusion(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate checks the structure of the attestation,
+// independently of any policy.
+func (a *attestation) validate() error {
 	// Statement type.
-	if v.attestation.Header.Type != statementType {
-		return fmt.Errorf("%w: attestation type (%q) != intoto type (%q)", errs.ErrorMismatch,
-			v.attestation.Header.Type, statementType)
+	if a.Header.Type != statementType {
+		return errs.VerificationErrorNew(errs.CheckStatementType, statementType, a.Header.Type,
+			fmt.Errorf("%w: attestation type (%q) != intoto type (%q)", errs.ErrorMismatch,
+				a.Header.Type, statementType))
 	}
 	// Predicate type.
-	if v.attestation.Header.PredicateType != predicateType {
-		return fmt.Errorf("%w: attestation predicate type (%q) != deployment type (%q)", errs.ErrorMismatch,
-			v.attestation.Header.PredicateType, predicateType)
+	if a.Header.PredicateType != predicateType {
+		return errs.VerificationErrorNew(errs.CheckPredicateType, predicateType, a.Header.PredicateType,
+			fmt.Errorf("%w: attestation predicate type (%q) != deployment type (%q)", errs.ErrorMismatch,
+				a.Header.PredicateType, predicateType))
 	}
 	// Subjects and digests.
-	if len(v.attestation.Header.Subjects) == 0 {
+	if len(a.Header.Subjects) == 0 {
 		return fmt.Errorf("%w: no subjects in attestation", errs.ErrorInvalidField)
 	}
-	if err := verifyDigests(v.attestation.Header.Subjects[0].Digests, digests); err != nil {
+	return intoto.ValidateSubject(a.Header.Subjects[0])
+}
+
+func verifyDigests(ds intoto.DigestSet, digests intoto.DigestSet) error {
+	if err := ds.Validate(); err != nil {
 		return err
 	}
-	// Scopes.
-	if err := v.verifyScopes(scopes); err != nil {
+	// NOTE: an attestation may record an algorithm under names that only
+	// differ in case. Conflicting values fail even if the evaluated
+	// digests do not contain the algorithm.
+	if alg, conflicts := ds.ConflictingAlgorithm(); conflicts {
+		return errs.VerificationErrorNew(errs.CheckDigest, "", "",
+			fmt.Errorf("%w: attestation digest (%q) has conflicting values", errs.ErrorMismatch, alg))
+	}
+	// NOTE: algorithm names and hex values are compared case-insensitively.
+	// Every algorithm of the evaluated digests must be present
+	// in the attestation with the same value.
+	digests, err := digests.Normalize()
+	if err != nil {
 		return err
 	}
+	for name, value := range digests {
+		val, exists := ds.DigestValue(name)
+		if !exists {
+			return errs.VerificationErrorNew(errs.CheckDigest, name+":"+value, "",
+				fmt.Errorf("%w: subject with digest (%q:%q) is not present in attestation", errs.ErrorMismatch,
+					name, value))
+		}
+		if val != value {
+			return errs.VerificationErrorNew(errs.CheckDigest, name+":"+value, name+":"+val,
+				fmt.Errorf("%w: subject with digest (%q:%q) != attestation (%q:%q)", errs.ErrorMismatch,
+					name, value, name, val))
+		}
+	}
+	return nil
+}
 
-	// TODO: verify time. Use default margin, but allow passing
-	// a custom one.
+// RejectForeignSubjects verifies that every subject in the attestation
+// matches the evaluated digests.
+// NOTE: This option is off by default because multi-arch images
+// legitimately carry one subject per architecture. Attestations
+// created with multiple subjects pass this check only if every
+// subject contains the evaluated digests.
+func RejectForeignSubjects(digests intoto.DigestSet) VerificationOption {
+	return func(v *Verification) error {
+		digests, err := digests.Normalize()
+		if err != nil {
+			return err
+		}
+		digestsKey := checks.DigestsKey(digests)
+		return v.addCheck(checks.Check{
+			Kind:  "RejectForeignSubjects",
+			Key:   digestsKey,
+			Value: digestsKey,
+			Rank:  checks.RankSubjects,
+			Run:   func() error { return v.rejectForeignSubjects(digests) },
+		})
+	}
+}
 
-	// Other options.
-	for _, option := range options {
-		err := option(v)
+func (v *Verification) rejectForeignSubjects(digests intoto.DigestSet) error {
+	var foreign []intoto.DigestSet
+	for i := range v.attestation.Header.Subjects {
+		subject := &v.attestation.Header.Subjects[i]
+		if err := verifyDigests(subject.Digests, digests); err != nil {
+			foreign = append(foreign, subject.Digests)
+		}
+	}
+	if len(foreign) > 0 {
+		return errs.VerificationErrorNew(errs.CheckDigest, fmt.Sprintf("%v", digests), fmt.Sprintf("%v", foreign),
+			fmt.Errorf("%w: subjects (%v) do not match digests (%v)", errs.ErrorInvalidField,
+				foreign, digests))
+	}
+	return nil
+}
+
+// ScopeValueMatches verifies that the attestation's value
+// for the scope key matches the regular expression.
+// NOTE: The pattern is not anchored implicitly: callers who need
+// to match the entire value must use "^" and "$".
+func ScopeValueMatches(key, pattern string) VerificationOption {
+	re, err := regex.Compile(pattern)
+	return func(v *Verification) error {
 		if err != nil {
 			return err
 		}
+		return v.addCheck(checks.Check{
+			Kind:  "ScopeValueMatches",
+			Key:   struct{ key, pattern string }{key, pattern},
+			Value: fmt.Sprintf("%q:%q", key, pattern),
+			Rank:  checks.RankPolicy,
+			Run:   func() error { return v.scopeValueMatches(key, re) },
+		})
+	}
+}
+
+func (v *Verification) scopeValueMatches(key string, re *regexp.Regexp) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty scope key", errs.ErrorInvalidInput)
+	}
+	value, exists := v.attestation.Predicate.Scopes[key]
+	if !exists {
+		return errs.VerificationErrorNew(errs.CheckScope, re.String(), "",
+			fmt.Errorf("%w: scope (%q) not present in attestation", errs.ErrorMismatch, key))
+	}
+	if !re.MatchString(value) {
+		return errs.VerificationErrorNew(errs.CheckScope, re.String(), value,
+			fmt.Errorf("%w: attestation scope (%q:%q) does not match (%q)", errs.ErrorMismatch,
+				key, value, re.String()))
 	}
 	return nil
 }
 
-func (v *Verification) verifyScopes(scopes map[string]string) error {
-	if !reflect.DeepEqual(v.attestation.Predicate.Scopes, scopes) {
-		return fmt.Errorf("%w: scopes (%q) != attestation scopes (%q)", errs.ErrorMismatch,
-			scopes, v.attestation.Predicate.Scopes)
+// HasPolicy verifies that the attestation records the policy
+// with the name, URI and digests, see WithPolicy().
+func HasPolicy(name, uri string, digests intoto.DigestSet) VerificationOption {
+	return func(v *Verification) error {
+		if name == "" {
+			return fmt.Errorf("%w: policy name is empty", errs.ErrorInvalidInput)
+		}
+		if err := digests.Validate(); err != nil {
+			return err
+		}
+		digestsKey := checks.DigestsKey(digests)
+		return v.addCheck(checks.Check{
+			Kind:  "HasPolicy",
+			Key:   struct{ name, uri, digests string }{name, uri, digestsKey},
+			Value: fmt.Sprintf("%q:%q:%s", name, uri, digestsKey),
+			Rank:  checks.RankPolicy,
+			Run:   func() error { return v.hasPolicy(name, uri, digests) },
+		})
+	}
+}
+
+func (v *Verification) hasPolicy(name, uri string, digests intoto.DigestSet) error {
+	var policies []intoto.ResourceDescriptor
+	if v.attestation.Predicate.DecisionDetails != nil {
+		policies = v.attestation.Predicate.DecisionDetails.Policy
+	}
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Name != name {
+			continue
+		}
+		if policy.URI != uri {
+			return errs.VerificationErrorNew(errs.CheckPolicy, uri, policy.URI,
+				fmt.Errorf("%w: policy (%q) URI (%q) != attestation URI (%q)", errs.ErrorMismatch,
+					name, uri, policy.URI))
+		}
+		if !reflect.DeepEqual(policy.Digest, digests) {
+			return errs.VerificationErrorNew(errs.CheckPolicy, fmt.Sprintf("%v", digests), fmt.Sprintf("%v", policy.Digest),
+				fmt.Errorf("%w: policy (%q) digests (%v) != attestation digests (%v)", errs.ErrorMismatch,
+					name, digests, policy.Digest))
+		}
+		return nil
+	}
+	return errs.VerificationErrorNew(errs.CheckPolicy, name, "",
+		fmt.Errorf("%w: policy (%q) not present in attestation", errs.ErrorMismatch, name))
+}
+
+// IsSlsaBuildLevelOrAbove verifies that the publish root verified
+// a SLSA build level of at least level before authorizing the deployment.
+// Attestations that do not record the level do not match.
+func IsSlsaBuildLevelOrAbove(level int) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "IsSlsaBuildLevelOrAbove",
+			Key:   level,
+			Value: fmt.Sprintf("%d", level),
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.isSlsaBuildLevelOrAbove(level) },
+		})
+	}
+}
+
+func (v *Verification) isSlsaBuildLevelOrAbove(level int) error {
+	if err := validateLevel(level); err != nil {
+		return err
+	}
+	attLevel, err := v.intProperty(buildLevelProperty)
+	if errors.Is(err, errs.ErrorNotFound) {
+		return errs.VerificationErrorNew(errs.CheckBuildLevel, strconv.Itoa(level), "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch,
+				buildLevelProperty))
+	}
+	if err != nil {
+		return err
+	}
+	if attLevel < level {
+		return errs.VerificationErrorNew(errs.CheckBuildLevel, strconv.Itoa(level), strconv.Itoa(attLevel),
+			fmt.Errorf("%w: level (%v) > attestation (%v)", errs.ErrorMismatch,
+				level, attLevel))
 	}
 	return nil
 }
 
-func verifyDigests(ds intoto.DigestSet, digests intoto.DigestSet) error {
-	if err := ds.Validate(); err != nil {
+// HasBuildLevel verifies that the publish root verified exactly the
+// SLSA build level before authorizing the deployment, e.g. for audits.
+// Attestations that do not record the level do not match.
+func HasBuildLevel(level int) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "HasBuildLevel",
+			Key:   level,
+			Value: fmt.Sprintf("%d", level),
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.hasBuildLevel(level) },
+		})
+	}
+}
+
+func (v *Verification) hasBuildLevel(level int) error {
+	if err := validateLevel(level); err != nil {
 		return err
 	}
-	if err := digests.Validate(); err != nil {
+	attLevel, err := v.intProperty(buildLevelProperty)
+	if errors.Is(err, errs.ErrorNotFound) {
+		return errs.VerificationErrorNew(errs.CheckBuildLevel, strconv.Itoa(level), "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch,
+				buildLevelProperty))
+	}
+	if err != nil {
 		return err
 	}
-	for name, value := range digests {
-		val, exists := ds[name]
-		if !exists {
-			return fmt.Errorf("%w: subject with digest (%q:%q) is not present in attestation", errs.ErrorMismatch,
-				name, value)
+	if attLevel != level {
+		return errs.VerificationErrorNew(errs.CheckBuildLevel, strconv.Itoa(level), strconv.Itoa(attLevel),
+			fmt.Errorf("%w: level (%v) != attestation (%v)", errs.ErrorMismatch,
+				level, attLevel))
+	}
+	return nil
+}
+
+// HasPublishRoot verifies that the deployment was authorized by
+// the publish root with the ID, see SetPublishRoot().
+// Attestations that do not record the publish root do not match.
+func HasPublishRoot(id string) VerificationOption {
+	return func(v *Verification) error {
+		if id == "" {
+			return fmt.Errorf("%w: publish root is empty", errs.ErrorInvalidInput)
 		}
-		if val != value {
-			return fmt.Errorf("%w: subject with digest (%q:%q) != attestation (%q:%q)", errs.ErrorMismatch,
-				name, value, name, val)
+		return v.addCheck(checks.Check{
+			Kind:  "HasPublishRoot",
+			Key:   id,
+			Value: fmt.Sprintf("%q", id),
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.hasPublishRoot(id) },
+		})
+	}
+}
+
+func (v *Verification) hasPublishRoot(id string) error {
+	value, exists := v.attestation.Predicate.Properties[publishRootProperty]
+	if !exists {
+		return errs.VerificationErrorNew(errs.CheckPublishRoot, id, "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch,
+				publishRootProperty))
+	}
+	attID, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%w: property (%q) value (%T:%v) is not a string", errs.ErrorInvalidField,
+			publishRootProperty, value, value)
+	}
+	if attID != id {
+		return errs.VerificationErrorNew(errs.CheckPublishRoot, id, attID,
+			fmt.Errorf("%w: publish root (%q) != attestation (%q)", errs.ErrorMismatch,
+				id, attID))
+	}
+	return nil
+}
+
+// HasNonce verifies that the attestation records the nonce,
+// see WithNonce(). Attestations that do not record a nonce do not match.
+func HasNonce(nonce string) VerificationOption {
+	return func(v *Verification) error {
+		if nonce == "" {
+			return fmt.Errorf("%w: nonce is empty", errs.ErrorInvalidInput)
 		}
+		return v.addCheck(checks.Check{
+			Kind:      "HasNonce",
+			Key:       nonce,
+			Value:     fmt.Sprintf("%q", nonce),
+			Exclusive: true,
+			Rank:      checks.RankProperties,
+			Run:       func() error { return v.hasNonce(nonce) },
+		})
+	}
+}
+
+func (v *Verification) hasNonce(nonce string) error {
+	value, exists := v.attestation.Predicate.Properties[nonceProperty]
+	if !exists {
+		return errs.VerificationErrorNew(errs.CheckNonce, nonce, "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch,
+				nonceProperty))
+	}
+	attNonce, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%w: property (%q) value (%T:%v) is not a string", errs.ErrorInvalidField,
+			nonceProperty, value, value)
+	}
+	if attNonce != nonce {
+		return errs.VerificationErrorNew(errs.CheckNonce, nonce, attNonce,
+			fmt.Errorf("%w: nonce (%q) != attestation (%q)", errs.ErrorMismatch,
+				nonce, attNonce))
+	}
+	return nil
+}
+
+func validateLevel(level int) error {
+	if level < 0 {
+		return fmt.Errorf("%w: level (%v) is negative", errs.ErrorInvalidInput, level)
+	}
+	if level > 4 {
+		return fmt.Errorf("%w: level (%v) is too large", errs.ErrorInvalidInput, level)
 	}
 	return nil
 }
+
+// EvaluationDuration returns the duration of the policy evaluation
+// recorded in the attestation, if present.
+func (v *Verification) EvaluationDuration() (time.Duration, error) {
+	ms, err := v.intProperty(evaluationDurationProperty)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// VerifierCalls returns the number of verifier calls
+// recorded in the attestation, if present.
+func (v *Verification) VerifierCalls() (int, error) {
+	return v.intProperty(verifierCallsProperty)
+}
+
+func (v *Verification) intProperty(name string) (int, error) {
+	value, exists := v.attestation.Predicate.Properties[name]
+	if !exists {
+		return 0, fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorNotFound, name)
+	}
+	vv, ok := value.(float64)
+	if !ok || vv < 0 || vv != float64(int(vv)) {
+		return 0, fmt.Errorf("%w: property (%q) value (%T:%v) is not a non-negative int", errs.ErrorInvalidField,
+			name, value, value)
+	}
+	return int(vv), nil
+}
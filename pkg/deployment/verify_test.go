@@ -2,9 +2,21 @@ package deployment
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -12,8 +24,15 @@ import (
 	//"github.com/slsa-framework/slsa-policy/pkg/deployment/internal/common"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/regex"
 )
 
+// withUnknownScopes returns the options with AllowUnknownScopes(),
+// for tests that verify arbitrary scope keys.
+func withUnknownScopes(options ...VerificationOption) []VerificationOption {
+	return append([]VerificationOption{AllowUnknownScopes()}, options...)
+}
+
 func Test_verifyDigests(t *testing.T) {
 	t.Parallel()
 
@@ -26,36 +45,36 @@ func Test_verifyDigests(t *testing.T) {
 		{
 			name: "same digests",
 			attDigests: intoto.DigestSet{
-				"sha256":    "another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 		},
 		{
 			name: "subset in attestations",
 			attDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 		},
 		{
 			name: "empty input digests",
 			attDigests: intoto.DigestSet{
-				"sha256":    "another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			expected: errs.ErrorInvalidField,
 		},
 		{
 			name: "empty att digests",
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 			expected: errs.ErrorInvalidField,
 		},
@@ -66,23 +85,132 @@ func Test_verifyDigests(t *testing.T) {
 				"a-gitCommit": "mismatch_another_com",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 			expected: errs.ErrorMismatch,
 		},
 		{
 			name: "mismatch sha256 digest",
 			attDigests: intoto.DigestSet{
-				"sha256":    "not_another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "mixed case input digests",
+			attDigests: intoto.DigestSet{
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"GITCOMMIT": "685D6428520757122269412CE6F9C5B7D1911F61",
+				"SHA256":    "AE448AC86C4E8E4DEC645729708EF41873AE79C6DFF84EFF73360989487F08E5",
+			},
+		},
+		{
+			name: "mixed case att digests",
+			attDigests: intoto.DigestSet{
+				"Sha256":    "AE448AC86C4E8E4DEC645729708EF41873AE79C6DFF84EFF73360989487F08E5",
+				"gitcommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+		},
+		{
+			name: "short sha256 digest",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "non hex gitCommit digest",
+			attDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f6z",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "input algorithm absent from attestation",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"sha512": "ccc",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "conflicting common digest",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"sha512": "ccc",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+				"sha512": "ccc",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "conflicting duplicate att digests",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"SHA256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "conflicting duplicate att digests not evaluated",
+			attDigests: intoto.DigestSet{
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"GITCOMMIT": "0000000000000000000000000000000000000000",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 			expected: errs.ErrorMismatch,
 		},
+		{
+			name: "same duplicate att digests",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"SHA256": "AE448AC86C4E8E4DEC645729708EF41873AE79C6DFF84EFF73360989487F08E5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+		},
+		{
+			name: "duplicate input digests",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"SHA256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -111,6 +239,9 @@ func Test_verifyScopes(t *testing.T) {
 		name        string
 		attestation attestation
 		scopes      map[string]string
+		optional    []string
+		extra       bool
+		mismatches  []ScopeMismatch
 		expected    error
 	}{
 		{
@@ -141,6 +272,9 @@ func Test_verifyScopes(t *testing.T) {
 				"key1": "val1_mismatch",
 				"key2": "val2",
 			},
+			mismatches: []ScopeMismatch{
+				{Key: "key1", Expected: "val1_mismatch", Got: "val1"},
+			},
 		},
 		{
 			name:        "mismatch scopes key2",
@@ -150,6 +284,12 @@ func Test_verifyScopes(t *testing.T) {
 				"key1_":         "val1",
 				"key2_mismatch": "val2",
 			},
+			mismatches: []ScopeMismatch{
+				{Key: "key1", Got: "val1"},
+				{Key: "key1_", Expected: "val1"},
+				{Key: "key2", Got: "val2"},
+				{Key: "key2_mismatch", Expected: "val2"},
+			},
 		},
 		{
 			name:        "mismatch scopes val2",
@@ -173,27 +313,128 @@ func Test_verifyScopes(t *testing.T) {
 			},
 			scopes: scopes,
 		},
+		{
+			name:        "optional scope absent",
+			attestation: att,
+			scopes: map[string]string{
+				"key1": "val1",
+				"key2": "val2",
+				"key3": "val3",
+			},
+			optional: []string{"key3"},
+		},
+		{
+			name:        "optional scope mismatch",
+			expected:    errs.ErrorMismatch,
+			attestation: att,
+			scopes: map[string]string{
+				"key1": "val1",
+				"key2": "val2_mismatch",
+			},
+			optional: []string{"key2"},
+			mismatches: []ScopeMismatch{
+				{Key: "key2", Expected: "val2_mismatch", Got: "val2"},
+			},
+		},
+		{
+			name:        "required scope absent",
+			expected:    errs.ErrorMismatch,
+			attestation: att,
+			scopes: map[string]string{
+				"key1": "val1",
+				"key2": "val2",
+				"key3": "val3",
+			},
+			optional: []string{"key4"},
+			mismatches: []ScopeMismatch{
+				{Key: "key3", Expected: "val3"},
+			},
+		},
+		{
+			name:        "extra scopes absent",
+			attestation: att,
+			scopes: map[string]string{
+				"key1": "val1",
+				"key2": "val2",
+				"key3": "val3",
+			},
+			extra: true,
+		},
+		{
+			name:        "extra scopes attestation only",
+			expected:    errs.ErrorMismatch,
+			attestation: att,
+			scopes: map[string]string{
+				"key1": "val1",
+			},
+			extra: true,
+			mismatches: []ScopeMismatch{
+				{Key: "key2", Got: "val2"},
+			},
+		},
+		{
+			name:        "extra scopes mismatch",
+			expected:    errs.ErrorMismatch,
+			attestation: att,
+			scopes: map[string]string{
+				"key1": "val1",
+				"key2": "val2_mismatch",
+			},
+			extra: true,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			verification := Verification{
-				attestation: tt.attestation,
+				attestation:    tt.attestation,
+				optionalScopes: tt.optional,
+				extraScopes:    tt.extra,
 			}
 			err := verification.verifyScopes(tt.scopes)
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if tt.mismatches == nil {
+				return
+			}
+			var mismatchErr *ScopeMismatchError
+			if !errors.As(err, &mismatchErr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if diff := cmp.Diff(tt.mismatches, mismatchErr.Mismatches); diff != "" {
+				t.Fatalf("unexpected mismatches (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
 
+func Test_ScopeMismatchError(t *testing.T) {
+	t.Parallel()
+	err := &ScopeMismatchError{
+		Mismatches: []ScopeMismatch{
+			{Key: "key1", Expected: "val1", Got: "val2"},
+			{Key: "key2", Expected: strings.Repeat("a", 100)},
+			{Key: "key3", Got: "val3"},
+		},
+	}
+	expected := `mismatch error: scope ("key1"): expected "val1", got "val2"; ` +
+		`scope ("key2"): expected "` + strings.Repeat("a", maxScopeValueLength-3) + `...", got none; ` +
+		`scope ("key3"): expected none, got "val3"`
+	if diff := cmp.Diff(expected, err.Error()); diff != "" {
+		t.Fatalf("unexpected message (-want +got): \n%s", diff)
+	}
+	if !errors.Is(err, errs.ErrorMismatch) {
+		t.Fatalf("error does not wrap (%v)", errs.ErrorMismatch)
+	}
+}
+
 func Test_Verify(t *testing.T) {
 	t.Parallel()
 	digests := intoto.DigestSet{
-		"sha256":    "another",
-		"gitCommit": "another_com",
+		"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+		"gitCommit": "d83f6526d87d2d52ef4d789b2933c6cd7d9b8981",
 	}
 	subjects := []intoto.Subject{
 		intoto.Subject{
@@ -287,7 +528,7 @@ func Test_Verify(t *testing.T) {
 					Subjects: []intoto.Subject{
 						{
 							Digests: intoto.DigestSet{
-								"sha256": "another",
+								"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 								"":       "mismatch_another_com",
 							},
 						},
@@ -304,7 +545,7 @@ func Test_Verify(t *testing.T) {
 			att:      att,
 			scopes:   scopes,
 			digests: intoto.DigestSet{
-				"sha256": "another",
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 				"":       "mismatch_another_com",
 			},
 		},
@@ -318,7 +559,7 @@ func Test_Verify(t *testing.T) {
 					Subjects: []intoto.Subject{
 						{
 							Digests: intoto.DigestSet{
-								"sha256":    "another",
+								"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 								"gitCommit": "",
 							},
 						},
@@ -339,8 +580,8 @@ func Test_Verify(t *testing.T) {
 					Subjects: []intoto.Subject{
 						{
 							Digests: intoto.DigestSet{
-								"sha256":    "another",
-								"gitCommit": "mismatch_another_com",
+								"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+								"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 							},
 						},
 					},
@@ -349,7 +590,7 @@ func Test_Verify(t *testing.T) {
 			},
 			scopes: scopes,
 			digests: intoto.DigestSet{
-				"sha256":    "another",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 				"gitCommit": "",
 			},
 		},
@@ -359,8 +600,8 @@ func Test_Verify(t *testing.T) {
 			att:      att,
 			scopes:   scopes,
 			digests: intoto.DigestSet{
-				"sha256":    "not_another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 		},
 		{
@@ -369,8 +610,8 @@ func Test_Verify(t *testing.T) {
 			att:      att,
 			scopes:   scopes,
 			digests: intoto.DigestSet{
-				"sha256":    "another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 		},
 		{
@@ -388,7 +629,7 @@ func Test_Verify(t *testing.T) {
 			att:    att,
 			scopes: scopes,
 			digests: intoto.DigestSet{
-				"gitCommit": "another_com",
+				"gitCommit": "d83f6526d87d2d52ef4d789b2933c6cd7d9b8981",
 			},
 		},
 		{
@@ -495,13 +736,2088 @@ func Test_Verify(t *testing.T) {
 			}
 
 			// Create verification options.
-			var options []VerificationOption
+			// NOTE: the scopes use arbitrary keys.
+			options := []VerificationOption{AllowUnknownScopes()}
 
 			// Verify.
 			err = verification.Verify(tt.digests, tt.scopes, options...)
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+
+			// Verify using the parsed attestation. Structural errors
+			// must be reported by the parsing step.
+			parsed, err := ParseAndValidate(io.NopCloser(bytes.NewReader(content)))
+			if err == nil {
+				pverification, perr := VerificationFromParsed(parsed)
+				if perr != nil {
+					t.Fatalf("failed to creation verification: %v", perr)
+				}
+				err = pverification.Verify(tt.digests, tt.scopes, options...)
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_ScopeValueMatches(t *testing.T) {
+	t.Parallel()
+	att := attestation{
+		Predicate: predicate{
+			Scopes: map[string]string{
+				"key1": "deployer-prod@acme.iam.gserviceaccount.com",
+				"key2": "déployeur-画像@acme.iam.gserviceaccount.com",
+				"key3": strings.Repeat("a", 10000) + "!",
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		key      string
+		pattern  string
+		expected error
+	}{
+		{
+			name:    "anchored match",
+			key:     "key1",
+			pattern: `^deployer-.+@acme\.iam\.gserviceaccount\.com$`,
+		},
+		{
+			name:     "anchored mismatch",
+			key:      "key1",
+			pattern:  `^prod@`,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "unanchored match",
+			key:     "key1",
+			pattern: `prod@`,
+		},
+		{
+			name:    "unicode match",
+			key:     "key2",
+			pattern: `^\p{L}+-\p{Han}+@`,
+		},
+		{
+			name:     "unicode mismatch",
+			key:      "key2",
+			pattern:  `^[a-z]+-`,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "backtracking-prone pattern",
+			key:      "key3",
+			pattern:  `^(a+)+$`,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "scope not present",
+			key:      "key4",
+			pattern:  `prod@`,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "empty key",
+			pattern:  `prod@`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "pattern too long",
+			key:      "key1",
+			pattern:  strings.Repeat("a", regex.MaxLength+1),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid pattern",
+			key:      "key1",
+			pattern:  `^deployer-(`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty pattern",
+			key:      "key1",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification := Verification{
+				attestation: att,
+			}
+			err := ScopeValueMatches(tt.key, tt.pattern)(&verification)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_RejectForeignSubjects(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	smuggledDigests := intoto.DigestSet{
+		"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c",
+	}
+	scopes := map[string]string{
+		"key": "value",
+	}
+	tests := []struct {
+		name     string
+		subjects []intoto.Subject
+		digests  intoto.DigestSet
+		expected error
+	}{
+		{
+			name: "single subject",
+			subjects: []intoto.Subject{
+				{Digests: digests},
+			},
+			digests: digests,
+		},
+		{
+			name: "multiple matching subjects",
+			subjects: []intoto.Subject{
+				{Digests: digests},
+				{Name: "other_name", Digests: digests},
+			},
+			digests: digests,
+		},
+		{
+			name: "smuggled subject",
+			subjects: []intoto.Subject{
+				{Digests: digests},
+				{Digests: smuggledDigests},
+			},
+			digests:  digests,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att := attestation{
+				Header: intoto.Header{
+					Type:          statementType,
+					PredicateType: predicateType,
+					Subjects:      tt.subjects,
+				},
+				Predicate: predicate{
+					CreationTime: intoto.Now(),
+					Scopes:       scopes,
+				},
+			}
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			// The option is off by default.
+			if err := verification.Verify(tt.digests, scopes, AllowUnknownScopes()); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			err = verification.Verify(tt.digests, scopes, AllowUnknownScopes(), RejectForeignSubjects(tt.digests))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_UnknownReservedProperties(t *testing.T) {
+	t.Parallel()
+	scopes := map[string]string{
+		"key": "value",
+	}
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		properties properties
+		warnings   int
+		expected   error
+	}{
+		{
+			name: "known properties",
+			properties: properties{
+				publishRootProperty:   "root_id",
+				verifierCallsProperty: 2,
+			},
+		},
+		{
+			name: "non-reserved properties",
+			properties: properties{
+				"example.com/publish/root": "root_id",
+			},
+		},
+		{
+			name: "unknown reserved property",
+			properties: properties{
+				publishRootProperty: "root_id",
+				"slsa.dev/level":    4,
+			},
+			warnings: 1,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "look-alike reserved properties",
+			properties: properties{
+				"SLSA.dev/publish/root":  "root_id",
+				" slsa.dev/publish/root": "root_id",
+				"slsa.dev/publish/root ": "root_id",
+			},
+			warnings: 3,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att := attestation{
+				Header: intoto.Header{
+					Type:          statementType,
+					PredicateType: predicateType,
+					Subjects:      []intoto.Subject{{Digests: digests}},
+				},
+				Predicate: predicate{
+					CreationTime: intoto.Now(),
+					Scopes:       scopes,
+					Properties:   tt.properties,
+				},
+			}
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			if diff := cmp.Diff(tt.warnings, len(verification.Warnings())); diff != "" {
+				t.Fatalf("unexpected warnings (-want +got): \n%s", diff)
+			}
+			// The option is off by default.
+			if err := verification.Verify(digests, scopes, AllowUnknownScopes()); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			err = verification.Verify(digests, scopes, AllowUnknownScopes(), RejectUnknownReservedProperties())
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Creation refuses to write unknown reserved properties.
+			creation := Creation{attestation: att}
+			err = creation.validateProperties()
+			if tt.expected != nil {
+				if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to validate properties: %v", err)
+			}
+		})
+	}
+}
+
+func Test_HasPolicy(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	commit := intoto.DigestSet{
+		"gitCommit": "d58ce22cd8e763354b58513d4de925e045c332d9",
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, nil,
+		WithPolicy("org", "git+https://github.com/org/policies", commit))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	attBytes, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	tests := []struct {
+		name     string
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:    "match",
+			options: []VerificationOption{HasPolicy("org", "git+https://github.com/org/policies", commit)},
+		},
+		{
+			name:     "mismatch uri",
+			options:  []VerificationOption{HasPolicy("org", "git+https://github.com/other/policies", commit)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "mismatch digests",
+			options: []VerificationOption{HasPolicy("org", "git+https://github.com/org/policies",
+				intoto.DigestSet{"gitCommit": "9c9773f37b535b66a71124236ec77498c2d0bcc1"})},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "extra digests",
+			options: []VerificationOption{HasPolicy("org", "git+https://github.com/org/policies",
+				intoto.DigestSet{"gitCommit": "d58ce22cd8e763354b58513d4de925e045c332d9", "sha1": "commit_value"})},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "policy not present",
+			options:  []VerificationOption{HasPolicy("projects", "git+https://github.com/org/policies", commit)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "empty name",
+			options:  []VerificationOption{HasPolicy("", "git+https://github.com/org/policies", commit)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty digests",
+			options:  []VerificationOption{HasPolicy("org", "git+https://github.com/org/policies", intoto.DigestSet{})},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(attBytes)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, nil, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CreationTime(t *testing.T) {
+	t.Parallel()
+	created := "2024-03-01T10:20:30.5+02:00"
+	createdUTC := time.Date(2024, 3, 1, 8, 20, 30, 500000000, time.UTC)
+	tests := []struct {
+		name         string
+		creationTime string
+		options      []VerificationOption
+		expected     error
+	}{
+		{
+			name:         "within window",
+			creationTime: created,
+			options: []VerificationOption{
+				CreatedAfter(createdUTC.Add(-time.Hour)),
+				CreatedBefore(createdUTC.Add(time.Hour)),
+			},
+		},
+		{
+			name:         "window boundaries",
+			creationTime: created,
+			options: []VerificationOption{
+				CreatedAfter(createdUTC),
+				CreatedBefore(createdUTC),
+			},
+		},
+		{
+			name:         "boundaries in another timezone",
+			creationTime: created,
+			options: []VerificationOption{
+				CreatedAfter(createdUTC.In(time.FixedZone("EST", -5*3600))),
+				CreatedBefore(createdUTC.In(time.FixedZone("JST", 9*3600))),
+			},
+		},
+		{
+			name:         "created too early",
+			creationTime: created,
+			options:      []VerificationOption{CreatedAfter(createdUTC.Add(time.Millisecond))},
+			expected:     errs.ErrorMismatch,
+		},
+		{
+			name:         "created too late",
+			creationTime: created,
+			options:      []VerificationOption{CreatedBefore(createdUTC.Add(-time.Millisecond))},
+			expected:     errs.ErrorMismatch,
+		},
+		{
+			name:         "utc without fraction",
+			creationTime: "2024-03-01T08:20:30Z",
+			options:      []VerificationOption{CreatedAfter(createdUTC)},
+			expected:     errs.ErrorMismatch,
+		},
+		{
+			name:     "missing creation time",
+			options:  []VerificationOption{CreatedAfter(createdUTC)},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:         "unparsable creation time",
+			creationTime: "2024-03-01 08:20:30",
+			options:      []VerificationOption{CreatedBefore(createdUTC)},
+			expected:     errs.ErrorInvalidField,
+		},
+		{
+			name:         "zero time",
+			creationTime: created,
+			options:      []VerificationOption{CreatedAfter(time.Time{})},
+			expected:     errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification := Verification{
+				attestation: attestation{
+					Predicate: predicate{
+						CreationTime: tt.creationTime,
+					},
+				},
+			}
+			var err error
+			for _, option := range tt.options {
+				if err = option(&verification); err != nil {
+					break
+				}
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_VerificationNewBundle(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	statement := func(scopes map[string]string) string {
+		att, err := CreationNew(intoto.Subject{Digests: digests}, scopes)
+		if err != nil {
+			t.Fatalf("failed to create attestation: %v", err)
+		}
+		content, err := att.ToBytes()
+		if err != nil {
+			t.Fatalf("failed to get attestation bytes: %v", err)
+		}
+		return string(content)
+	}
+	envelope := func(statement string) string {
+		return `{"payloadType":"` + intoto.PayloadType + `","payload":"` +
+			base64.StdEncoding.EncodeToString([]byte(statement)) + `","signatures":[]}`
+	}
+	dev := statement(map[string]string{"environment": "dev"})
+	prod := statement(map[string]string{"environment": "prod"})
+	other := `{"_type":"https://in-toto.io/Statement/v1","predicateType":"https://slsa.dev/publish/v0.1"}`
+	tests := []struct {
+		name      string
+		entries   []string
+		scopes    map[string]string
+		skipped   int
+		expected  error
+		verifyErr error
+	}{
+		{
+			name:    "first attestation matches",
+			entries: []string{dev, prod},
+			scopes:  map[string]string{"environment": "dev"},
+		},
+		{
+			name:    "last attestation matches",
+			entries: []string{"not json", other, envelope(dev), prod},
+			scopes:  map[string]string{"environment": "prod"},
+			skipped: 1,
+		},
+		{
+			name:    "envelope matches",
+			entries: []string{prod, envelope(dev)},
+			scopes:  map[string]string{"environment": "dev"},
+		},
+		{
+			name:      "no attestation matches",
+			entries:   []string{dev, envelope(prod)},
+			scopes:    map[string]string{"environment": "staging"},
+			verifyErr: errs.ErrorMismatch,
+		},
+		{
+			name:     "no deployment attestation",
+			entries:  []string{"not json", other},
+			expected: errs.ErrorNotFound,
+		},
+		{
+			name:     "empty bundle",
+			expected: errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reader := io.NopCloser(strings.NewReader(strings.Join(tt.entries, "\n")))
+			bundle, err := VerificationNewBundle(reader)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if len(bundle.Skipped()) != tt.skipped {
+				t.Fatalf("unexpected skipped entries: %v", bundle.Skipped())
+			}
+			// NOTE: the envelopes are not signed.
+			err = bundle.Verify(digests, tt.scopes, AllowUnknownScopes(), AllowUnsignedDSSE())
+			if diff := cmp.Diff(tt.verifyErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil && len(errs.Violations(err)) != len(tt.entries) {
+				t.Fatalf("unexpected number of errors: %v", err)
+			}
+		})
+	}
+}
+
+func Test_IsSlsaBuildLevelOrAbove(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		properties properties
+		level      int
+		expected   error
+	}{
+		{
+			name:       "level 0",
+			properties: properties{buildLevelProperty: 0},
+			level:      0,
+		},
+		{
+			name:       "level 0 below level 1",
+			properties: properties{buildLevelProperty: 0},
+			level:      1,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "level 4",
+			properties: properties{buildLevelProperty: 4},
+			level:      4,
+		},
+		{
+			name:       "level 4 above level 2",
+			properties: properties{buildLevelProperty: 4},
+			level:      2,
+		},
+		{
+			name:       "level 3 below level 4",
+			properties: properties{buildLevelProperty: 3},
+			level:      4,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:     "undefined level",
+			level:    0,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:       "undefined level with properties",
+			properties: properties{publishRootProperty: "publishr_id"},
+			level:      0,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "level is not an int",
+			properties: properties{buildLevelProperty: "3"},
+			level:      2,
+			expected:   errs.ErrorInvalidField,
+		},
+		{
+			name:       "negative level",
+			properties: properties{buildLevelProperty: 3},
+			level:      -1,
+			expected:   errs.ErrorInvalidInput,
+		},
+		{
+			name:       "level too large",
+			properties: properties{buildLevelProperty: 3},
+			level:      5,
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			att.attestation.Predicate.Properties = tt.properties
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, nil, IsSlsaBuildLevelOrAbove(tt.level))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_HasBuildLevel(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		properties properties
+		level      int
+		expected   error
+	}{
+		{
+			name:       "same level",
+			properties: properties{buildLevelProperty: 3},
+			level:      3,
+		},
+		{
+			name:       "level 0",
+			properties: properties{buildLevelProperty: 0},
+			level:      0,
+		},
+		{
+			name:       "level above",
+			properties: properties{buildLevelProperty: 4},
+			level:      3,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "level below",
+			properties: properties{buildLevelProperty: 2},
+			level:      3,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "undefined level",
+			properties: properties{publishRootProperty: "publishr_id"},
+			level:      3,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "level is not an int",
+			properties: properties{buildLevelProperty: "3"},
+			level:      3,
+			expected:   errs.ErrorInvalidField,
+		},
+		{
+			name:       "level too large",
+			properties: properties{buildLevelProperty: 3},
+			level:      5,
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			att.attestation.Predicate.Properties = tt.properties
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, nil, HasBuildLevel(tt.level))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_HasPublishRoot(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		properties properties
+		id         string
+		expected   error
+	}{
+		{
+			name:       "same root",
+			properties: properties{publishRootProperty: "publishr_id"},
+			id:         "publishr_id",
+		},
+		{
+			name:       "different root",
+			properties: properties{publishRootProperty: "publishr_id"},
+			id:         "other_id",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "undefined root",
+			properties: properties{buildLevelProperty: 3},
+			id:         "publishr_id",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "root is not a string",
+			properties: properties{publishRootProperty: 3},
+			id:         "publishr_id",
+			expected:   errs.ErrorInvalidField,
+		},
+		{
+			name:       "empty root",
+			properties: properties{publishRootProperty: "publishr_id"},
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			att.attestation.Predicate.Properties = tt.properties
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, nil, HasPublishRoot(tt.id))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_HasNonce(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		properties properties
+		nonce      string
+		expected   error
+	}{
+		{
+			name:       "same nonce",
+			properties: properties{nonceProperty: "nonce"},
+			nonce:      "nonce",
+		},
+		{
+			name:       "different nonce",
+			properties: properties{nonceProperty: "nonce"},
+			nonce:      "other",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "undefined nonce",
+			properties: properties{buildLevelProperty: 3},
+			nonce:      "nonce",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "nonce is not a string",
+			properties: properties{nonceProperty: 3},
+			nonce:      "nonce",
+			expected:   errs.ErrorInvalidField,
+		},
+		{
+			name:       "empty nonce",
+			properties: properties{nonceProperty: "nonce"},
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			att.attestation.Predicate.Properties = tt.properties
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, nil, HasNonce(tt.nonce))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_NotExpired(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	creationTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	expiry := creationTime.Add(time.Hour)
+	tests := []struct {
+		name     string
+		options  []AttestationCreationOption
+		at       time.Time
+		expected error
+	}{
+		{
+			name:    "before expiry",
+			options: []AttestationCreationOption{WithExpiry(expiry)},
+			at:      expiry.Add(-time.Second),
+		},
+		{
+			name:     "at expiry",
+			options:  []AttestationCreationOption{WithExpiry(expiry)},
+			at:       expiry,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "after expiry",
+			options:  []AttestationCreationOption{WithExpiry(expiry)},
+			at:       expiry.Add(time.Hour),
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "no expiry",
+			at:   expiry.Add(time.Hour),
+		},
+		{
+			name:     "zero time",
+			options:  []AttestationCreationOption{WithExpiry(expiry)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, nil,
+				append([]AttestationCreationOption{WithCreationTime(creationTime)}, tt.options...)...)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, nil, NotExpired(tt.at))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_VerifyAttestation(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	tests := []struct {
+		name     string
+		scopes   map[string]string
+		expected error
+	}{
+		{
+			name:   "verified",
+			scopes: scopes,
+		},
+		{
+			name:     "not verified",
+			scopes:   map[string]string{"environment": "dev"},
+			expected: errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, scopes, SetPublishRoot("publishr_id"))
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			verified, err := verification.VerifyAttestation(context.Background(), digests, tt.scopes, AllowUnknownScopes())
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				if verified != nil {
+					t.Fatalf("unexpected view of an attestation not verified: %v", verified)
+				}
+				return
+			}
+			if diff := cmp.Diff([]intoto.Subject{{Digests: digests}}, verified.Subjects); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(scopes, verified.Scopes); diff != "" {
+				t.Fatalf("unexpected scopes (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff("publishr_id", verified.Properties[publishRootProperty]); diff != "" {
+				t.Fatalf("unexpected publish root (-want +got): \n%s", diff)
+			}
+			if verified.CreationTime.IsZero() {
+				t.Fatalf("creation time is zero")
+			}
+			// The view does not alias the attestation.
+			verified.Subjects[0].Digests["sha256"] = "modified"
+			verified.Scopes["environment"] = "modified"
+			if err := verification.Verify(digests, scopes, AllowUnknownScopes()); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+		})
+	}
+}
+
+func Test_VerifyAttestationConcurrent(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes, SetPublishRoot("publishr_id"))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	// NOTE: calls that succeed and fail interleave, so each
+	// call must only observe the result of its own verification.
+	var wg sync.WaitGroup
+	errCh := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(succeed bool) {
+			defer wg.Done()
+			env := "dev"
+			if succeed {
+				env = "prod"
+			}
+			verified, err := verification.VerifyAttestation(context.Background(), digests,
+				map[string]string{"environment": env}, AllowUnknownScopes())
+			switch {
+			case succeed && (err != nil || verified == nil):
+				errCh <- fmt.Errorf("verification (%q) failed: %v", env, err)
+			case !succeed && (!errors.Is(err, errs.ErrorMismatch) || verified != nil):
+				errCh <- fmt.Errorf("verification (%q) succeeded: %v", env, err)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+}
+
+type fakeTransparencyLog struct {
+	entries map[string]string
+	err     error
+}
+
+func (l *fakeTransparencyLog) Upload(ctx context.Context, envelope []byte) (string, error) {
+	if l.err != nil {
+		return "", l.err
+	}
+	id := fmt.Sprintf("entry%d", len(l.entries))
+	l.entries[string(envelope)] = id
+	return id, nil
+}
+
+func (l *fakeTransparencyLog) VerifyInclusion(ctx context.Context, envelope []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.err != nil {
+		return l.err
+	}
+	if _, exists := l.entries[string(envelope)]; !exists {
+		return fmt.Errorf("entry not found")
+	}
+	return nil
+}
+
+func Test_TransparencyLog(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	logErr := errors.New("log error")
+	tests := []struct {
+		name      string
+		uploadLog *fakeTransparencyLog
+		verifyLog func(uploaded *fakeTransparencyLog) []VerificationOption
+		scopes    map[string]string
+		canceled  bool
+		uploadErr error
+		expected  error
+	}{
+		{
+			name:      "no transparency log",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return nil
+			},
+		},
+		{
+			name:      "entry recorded",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(uploaded *fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(uploaded)}
+			},
+		},
+		{
+			name:      "entry not recorded",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(
+					&fakeTransparencyLog{entries: map[string]string{}})}
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:      "log unavailable",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(
+					&fakeTransparencyLog{err: logErr})}
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:      "mismatch before log",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(
+					&fakeTransparencyLog{err: logErr})}
+			},
+			scopes:   map[string]string{"environment": "dev"},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:      "canceled context",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(uploaded *fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(uploaded)}
+			},
+			canceled: true,
+			expected: context.Canceled,
+		},
+		{
+			name:      "nil log",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(nil)}
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:      "log set twice",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(uploaded *fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{
+					RequireTransparencyLog(uploaded),
+					RequireTransparencyLog(uploaded),
+				}
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:      "upload failure",
+			uploadLog: &fakeTransparencyLog{err: logErr},
+			uploadErr: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, scopes, WithTransparencyLog(tt.uploadLog))
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			entryID, err := att.Upload(context.Background(), content)
+			if diff := cmp.Diff(tt.uploadErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if entryID == "" {
+				t.Fatalf("empty entry ID")
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			verifyScopes := scopes
+			if tt.scopes != nil {
+				verifyScopes = tt.scopes
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			err = verification.VerifyContext(ctx, digests, verifyScopes, withUnknownScopes(tt.verifyLog(tt.uploadLog)...)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Upload(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		log      TransparencyLog
+		envelope []byte
+		expected error
+	}{
+		{
+			name:     "uploaded",
+			log:      &fakeTransparencyLog{entries: map[string]string{}},
+			envelope: []byte("envelope"),
+		},
+		{
+			name:     "no log",
+			envelope: []byte("envelope"),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty envelope",
+			log:      &fakeTransparencyLog{entries: map[string]string{}},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty entry ID",
+			log:      emptyIDTransparencyLog{},
+			envelope: []byte("envelope"),
+			expected: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var att Creation
+			if tt.log != nil {
+				if err := WithTransparencyLog(tt.log)(&att); err != nil {
+					t.Fatalf("failed to set log: %v", err)
+				}
+			}
+			_, err := att.Upload(context.Background(), tt.envelope)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+type emptyIDTransparencyLog struct{}
+
+func (emptyIDTransparencyLog) Upload(context.Context, []byte) (string, error) {
+	return "", nil
+}
+
+func (emptyIDTransparencyLog) VerifyInclusion(context.Context, []byte) error {
+	return nil
+}
+
+func Test_DSSE(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	verifier, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	otherVerifier, err := intoto.PublicKeysVerifierNew(&otherKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes)
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	hash := sha256.Sum256(intoto.PAE(intoto.PayloadType, statement))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	envelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	badSig := bytes.Clone(sig)
+	badSig[len(badSig)-1] ^= 0xff
+	badEnvelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString(badSig)}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	tests := []struct {
+		name     string
+		content  []byte
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:    "statement",
+			content: statement,
+		},
+		{
+			name:    "statement allow unsigned",
+			content: statement,
+			options: []VerificationOption{AllowUnsignedDSSE()},
+		},
+		{
+			name:     "statement with verifier",
+			content:  statement,
+			options:  []VerificationOption{WithDSSEVerifier(verifier)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:    "envelope with verifier",
+			content: envelope,
+			options: []VerificationOption{WithDSSEVerifier(verifier)},
+		},
+		{
+			name:    "envelope allow unsigned",
+			content: envelope,
+			options: []VerificationOption{AllowUnsignedDSSE()},
+		},
+		{
+			name:     "envelope without verifier",
+			content:  envelope,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "envelope with wrong key",
+			content:  envelope,
+			options:  []VerificationOption{WithDSSEVerifier(otherVerifier)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "envelope with bad signature",
+			content:  badEnvelope,
+			options:  []VerificationOption{WithDSSEVerifier(verifier)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "nil verifier",
+			content:  envelope,
+			options:  []VerificationOption{WithDSSEVerifier(nil)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "verifier and allow unsigned",
+			content:  envelope,
+			options:  []VerificationOption{WithDSSEVerifier(verifier), AllowUnsignedDSSE()},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(tt.content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, scopes, withUnknownScopes(tt.options...)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Parsed attestations behave the same.
+			parsed, err := ParseAndValidate(io.NopCloser(bytes.NewReader(tt.content)))
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			pverification, err := VerificationFromParsed(parsed)
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = pverification.Verify(digests, scopes, withUnknownScopes(tt.options...)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// So do bundles.
+			// NOTE: bundles are newline-delimited.
+			var entry bytes.Buffer
+			if err := json.Compact(&entry, tt.content); err != nil {
+				t.Fatalf("failed to compact attestation: %v", err)
+			}
+			bundle, err := VerificationNewBundle(io.NopCloser(&entry))
+			if err != nil {
+				t.Fatalf("failed to create bundle: %v", err)
+			}
+			err = bundle.Verify(digests, scopes, withUnknownScopes(tt.options...)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PredicateVersion(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4",
+	}
+	scopes := map[string]string{
+		ScopeKeyKubernetesServiceAccount: "https://cloud.google.com/kubernetes-engine/slsa-project-echo-server",
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes, setBuildLevel(3))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	current, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	v01, err := os.ReadFile("testdata/attestation_v0.1.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	unknown := bytes.ReplaceAll(v01, []byte(predicateTypeV01), []byte("https://slsa.dev/deployment/v0.3"))
+	tests := []struct {
+		name     string
+		content  []byte
+		version  string
+		expected error
+	}{
+		{
+			name:    "current version",
+			content: current,
+			version: "v0.2",
+		},
+		{
+			name:    "previous version",
+			content: v01,
+			version: "v0.1",
+		},
+		{
+			name:     "unknown version",
+			content:  unknown,
+			expected: errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(tt.content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			if diff := cmp.Diff(tt.version, verification.PredicateVersion()); diff != "" {
+				t.Fatalf("unexpected version (-want +got): \n%s", diff)
+			}
+			err = verification.Verify(digests, scopes, IsSlsaBuildLevelOrAbove(3))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Parsed attestations and bundles.
+			parsed, err := ParseAndValidate(io.NopCloser(bytes.NewReader(tt.content)))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.version, parsed.PredicateVersion()); diff != "" {
+				t.Fatalf("unexpected version (-want +got): \n%s", diff)
+			}
+			// NOTE: bundles are newline-delimited.
+			var entry bytes.Buffer
+			if err := json.Compact(&entry, tt.content); err != nil {
+				t.Fatalf("failed to compact attestation: %v", err)
+			}
+			bundle, err := VerificationNewBundle(io.NopCloser(&entry))
+			if err != nil {
+				t.Fatalf("failed to create bundle: %v", err)
+			}
+			if err := bundle.Verify(digests, scopes, IsSlsaBuildLevelOrAbove(3)); err != nil {
+				t.Fatalf("failed to verify bundle: %v", err)
+			}
+		})
+	}
+}
+
+func Test_MaxAttestationSize(t *testing.T) {
+	t.Parallel()
+	content, err := os.ReadFile("testdata/attestation_v0.1.json")
+	if err != nil {
+		t.Fatalf("failed to read attestation: %v", err)
+	}
+	tests := []struct {
+		name     string
+		options  []ParseOption
+		expected error
+	}{
+		{
+			name: "default size",
+		},
+		{
+			name:    "attestation within size",
+			options: []ParseOption{WithMaxAttestationSize(int64(len(content)))},
+		},
+		{
+			name:     "attestation larger than size",
+			options:  []ParseOption{WithMaxAttestationSize(int64(len(content) - 1))},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid size",
+			options:  []ParseOption{WithMaxAttestationSize(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			_, err = ParseAndValidate(io.NopCloser(bytes.NewReader(content)), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func FuzzVerificationNew(f *testing.F) {
+	seed, err := os.ReadFile("testdata/attestation_v0.1.json")
+	if err != nil {
+		f.Fatalf("failed to read attestation: %v", err)
+	}
+	f.Add(seed)
+	f.Add([]byte(`{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/deployment/v0.2",
+		"subject": [{"digest": {"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4"}}],
+		"predicate": {"creationTime": "2024-05-01T10:00:00Z", "scopes": {"key": "value"},
+			"decisionDetails": {"policy": [{"name": "policy", "uri": "https://example.com", "digest": {"gitCommit": "abc"}}]},
+			"properties": {"slsa.dev/build/level": 3, "slsa.dev/telemetry/verifierCalls": 1.5}}}`))
+	f.Add([]byte(`{"payloadType": "application/vnd.in-toto+json", "payload": "e30=", "signatures": [{"sig": "c2ln"}]}`))
+	f.Add([]byte(`{"predicate": {"properties": null, "scopes": null}}`))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+		if err != nil {
+			return
+		}
+		digests := intoto.DigestSet{"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4"}
+		if len(verification.attestation.Header.Subjects) > 0 && len(verification.attestation.Header.Subjects[0].Digests) > 0 {
+			digests = verification.attestation.Header.Subjects[0].Digests
+		}
+		// NOTE: the result does not matter, only that
+		// no attestation makes the verification panic.
+		_ = verification.Verify(digests, map[string]string{"key": "value"},
+			WithOptionalScope("other"),
+			ScopeValueMatches("key", "^val.*$"),
+			HasPolicy("policy", "https://example.com", intoto.DigestSet{"gitCommit": "abc"}),
+			IsSlsaBuildLevelOrAbove(1),
+			HasBuildLevel(3),
+			HasPublishRoot("publishr_id"),
+			RejectForeignSubjects(digests),
+			RejectUnknownReservedProperties(),
+			CreatedAfter(time.Unix(0, 0)),
+		)
+		_ = verification.Verify(digests, nil, AllowExtraScopes())
+		_ = verification.Warnings()
+		_ = verification.PredicateVersion()
+		_, _ = verification.EvaluationDuration()
+		_, _ = verification.VerifierCalls()
+	})
+}
+
+func Test_VerificationErrorCheck(t *testing.T) {
+	t.Parallel()
+	digest := "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"
+	otherDigest := "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5"
+	digests := intoto.DigestSet{"sha256": digest}
+	scopes := map[string]string{"environment": "prod"}
+	policyDigests := intoto.DigestSet{"sha256": "abc"}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	verifier, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	revocations, err := RevocationListNew(io.NopCloser(strings.NewReader(`{"format": 1, "revocations": [
+		{"subject": {"sha256": "` + digest + `"}, "reason": "compromised builder", "revoked_at": "2024-05-01T00:00:00Z"}]}`)))
+	if err != nil {
+		t.Fatalf("failed to create revocation list: %v", err)
+	}
+	tests := []struct {
+		name       string
+		mutate     func(*attestation)
+		digests    intoto.DigestSet
+		scopes     map[string]string
+		verifyOpts []VerificationOption
+		check      errs.Check
+		want       string
+		got        string
+		expected   error
+	}{
+		{
+			name:     "statement type",
+			mutate:   func(a *attestation) { a.Header.Type = "other_type" },
+			check:    errs.CheckStatementType,
+			want:     statementType,
+			got:      "other_type",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "predicate type",
+			mutate:   func(a *attestation) { a.Header.PredicateType = "other_type" },
+			check:    errs.CheckPredicateType,
+			want:     predicateType,
+			got:      "other_type",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "digest",
+			digests:  intoto.DigestSet{"sha256": otherDigest},
+			check:    errs.CheckDigest,
+			want:     "sha256:" + otherDigest,
+			got:      "sha256:" + digest,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:       "foreign subjects",
+			verifyOpts: []VerificationOption{RejectForeignSubjects(intoto.DigestSet{"sha256": otherDigest})},
+			check:      errs.CheckDigest,
+			want:       "map[sha256:" + otherDigest + "]",
+			got:        "[map[sha256:" + digest + "]]",
+			expected:   errs.ErrorInvalidField,
+		},
+		{
+			name:     "scopes",
+			scopes:   map[string]string{"environment": "dev"},
+			check:    errs.CheckScope,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:       "scope value",
+			verifyOpts: []VerificationOption{ScopeValueMatches("environment", "^dev$")},
+			check:      errs.CheckScope,
+			want:       "^dev$",
+			got:        "prod",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "policy",
+			verifyOpts: []VerificationOption{HasPolicy("org", "https://example.com/other.json", policyDigests)},
+			check:      errs.CheckPolicy,
+			want:       "https://example.com/other.json",
+			got:        "https://example.com/org.json",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "build level",
+			verifyOpts: []VerificationOption{HasBuildLevel(2)},
+			check:      errs.CheckBuildLevel,
+			want:       "2",
+			got:        "3",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "minimum build level",
+			verifyOpts: []VerificationOption{IsSlsaBuildLevelOrAbove(4)},
+			check:      errs.CheckBuildLevel,
+			want:       "4",
+			got:        "3",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "publish root",
+			verifyOpts: []VerificationOption{HasPublishRoot("other_id")},
+			check:      errs.CheckPublishRoot,
+			want:       "other_id",
+			got:        "publishr_id",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "creation time",
+			verifyOpts: []VerificationOption{CreatedAfter(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))},
+			check:      errs.CheckCreationTime,
+			want:       "2024-06-01T00:00:00Z",
+			got:        "2024-05-01T10:00:00Z",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "signature",
+			verifyOpts: []VerificationOption{WithDSSEVerifier(verifier)},
+			check:      errs.CheckSignature,
+			expected:   errs.ErrorVerification,
+		},
+		{
+			name:       "revocation",
+			verifyOpts: []VerificationOption{WithRevocations(revocations)},
+			check:      errs.CheckRevocation,
+			expected:   errs.ErrorRevoked,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, scopes,
+				SetPublishRoot("publishr_id"), setBuildLevel(3),
+				WithPolicy("org", "https://example.com/org.json", policyDigests),
+				WithCreationTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			if tt.mutate != nil {
+				tt.mutate(&att.attestation)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			ds := digests
+			if tt.digests != nil {
+				ds = tt.digests
+			}
+			sc := scopes
+			if tt.scopes != nil {
+				sc = tt.scopes
+			}
+			err = verification.Verify(ds, sc, withUnknownScopes(tt.verifyOpts...)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			var verificationErr *errs.VerificationError
+			if !errors.As(err, &verificationErr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if diff := cmp.Diff(tt.check, verificationErr.Check); diff != "" {
+				t.Fatalf("unexpected check (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.want, verificationErr.Expected); diff != "" {
+				t.Fatalf("unexpected expected value (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.got, verificationErr.Got); diff != "" {
+				t.Fatalf("unexpected value (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_ScopeKeys(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name      string
+		attScopes map[string]string
+		scopes    map[string]string
+		options   []VerificationOption
+		expected  error
+	}{
+		{
+			name:      "known key",
+			attScopes: map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			scopes:    map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+		},
+		{
+			name:      "unknown key",
+			attScopes: map[string]string{"kubernetes-sa": "principal_uri"},
+			scopes:    map[string]string{"kubernetes-sa": "principal_uri"},
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "allowed unknown key",
+			attScopes: map[string]string{"kubernetes-sa": "principal_uri"},
+			scopes:    map[string]string{"kubernetes-sa": "principal_uri"},
+			options:   []VerificationOption{AllowUnknownScopes()},
+		},
+		{
+			name:      "empty value",
+			attScopes: map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			scopes:    map[string]string{ScopeKeyKubernetesServiceAccount: ""},
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "allowed unknown key empty value",
+			attScopes: map[string]string{"kubernetes-sa": "principal_uri"},
+			scopes:    map[string]string{"kubernetes-sa": ""},
+			options:   []VerificationOption{AllowUnknownScopes()},
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "empty key",
+			attScopes: map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			scopes:    map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri", "": "value"},
+			options:   []VerificationOption{AllowUnknownScopes()},
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "unknown optional key",
+			attScopes: map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			scopes:    map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			options:   []VerificationOption{WithOptionalScope("kubernetes-sa")},
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "allowed unknown optional key",
+			attScopes: map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			scopes:    map[string]string{ScopeKeyKubernetesServiceAccount: "principal_uri"},
+			options:   []VerificationOption{WithOptionalScope("kubernetes-sa"), AllowUnknownScopes()},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, tt.attScopes)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, tt.scopes, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_KnownScopeKeys(t *testing.T) {
+	t.Parallel()
+	keys := KnownScopeKeys()
+	if diff := cmp.Diff([]string{"kubernetes.io/pod/service_account/v1"}, keys); diff != "" {
+		t.Fatalf("unexpected keys (-want +got): \n%s", diff)
+	}
+	// The result is a copy.
+	keys[0] = "modified"
+	if diff := cmp.Diff(ScopeKeyKubernetesServiceAccount, KnownScopeKeys()[0]); diff != "" {
+		t.Fatalf("unexpected keys (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{
+		"environment": "prod",
+	}
+	commit := intoto.DigestSet{
+		"gitCommit": "d58ce22cd8e763354b58513d4de925e045c332d9",
+	}
+	creation, err := CreationNew(intoto.Subject{Digests: digests}, scopes,
+		SetPublishRoot("root_id"), setBuildLevel(3), setDigestAlgorithms([]string{"sha256"}),
+		setException(PolicyException{Digest: "sha256:bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			Reason: "reason", Expires: time.Now().Add(time.Hour)}),
+		WithPolicy("org", "git+https://github.com/org/policies", commit))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	attBytes, err := creation.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	tests := []struct {
+		name       string
+		modify     func(att *attestation)
+		violations int
+	}{
+		{
+			name:   "valid",
+			modify: func(*attestation) {},
+		},
+		{
+			name: "no properties",
+			modify: func(att *attestation) {
+				att.Predicate.Properties = nil
+				att.Predicate.DecisionDetails = nil
+			},
+		},
+		{
+			name:       "invalid types",
+			modify:     func(att *attestation) { att.Header.Type, att.Header.PredicateType = "other", "other" },
+			violations: 2,
+		},
+		{
+			name:       "no subjects",
+			modify:     func(att *attestation) { att.Header.Subjects = nil },
+			violations: 1,
+		},
+		{
+			name: "invalid additional subjects",
+			modify: func(att *attestation) {
+				att.Header.Subjects = append(att.Header.Subjects,
+					intoto.Subject{Name: "empty"},
+					intoto.Subject{Name: "conflicting", Digests: intoto.DigestSet{"sha256": "a", "SHA256": "b"}})
+			},
+			violations: 2,
+		},
+		{
+			name:       "invalid creation time",
+			modify:     func(att *attestation) { att.Predicate.CreationTime = "yesterday" },
+			violations: 1,
+		},
+		{
+			name:       "empty scope value",
+			modify:     func(att *attestation) { att.Predicate.Scopes["environment"] = "" },
+			violations: 1,
+		},
+		{
+			name: "invalid policy descriptor",
+			modify: func(att *attestation) {
+				att.Predicate.DecisionDetails.Policy[0].Digest = intoto.DigestSet{"gitCommit": ""}
+			},
+			violations: 1,
+		},
+		{
+			name: "invalid properties",
+			modify: func(att *attestation) {
+				att.Predicate.Properties[publishRootProperty] = 1
+				att.Predicate.Properties[buildLevelProperty] = 5
+				att.Predicate.Properties[digestAlgorithmsProperty] = []interface{}{""}
+				att.Predicate.Properties[verifierCallsProperty] = -1
+			},
+			violations: 4,
+		},
+		{
+			name: "invalid expiry and nonce",
+			modify: func(att *attestation) {
+				att.Predicate.Properties[expiresAtProperty] = "tomorrow"
+				att.Predicate.Properties[nonceProperty] = ""
+			},
+			violations: 2,
+		},
+		{
+			name: "invalid exception expiry",
+			modify: func(att *attestation) {
+				att.Predicate.Properties[exceptionProperty].(map[string]interface{})["expires"] = "tomorrow"
+			},
+			violations: 1,
+		},
+		{
+			name: "exception without reason",
+			modify: func(att *attestation) {
+				delete(att.Predicate.Properties[exceptionProperty].(map[string]interface{}), "reason")
+			},
+			violations: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var att attestation
+			if err := json.Unmarshal(attBytes, &att); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			tt.modify(&att)
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Validate()
+			if diff := cmp.Diff(tt.violations, len(errs.Violations(err))); diff != "" {
+				t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+			}
+			for _, violation := range errs.Violations(err) {
+				if !errors.Is(violation, errs.ErrorInvalidField) {
+					t.Fatalf("unexpected violation: %v", violation)
+				}
+			}
+		})
+	}
+}
+
+func Test_Content(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	scopes := map[string]string{"environment": "prod"}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, scopes, SetPublishRoot("publishr_id"),
+		WithPolicy("org.json", "policy_uri", digests))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	envelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: "c2ln"}, {KeyID: "key", Sig: "c2ln"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	tests := []struct {
+		name       string
+		content    []byte
+		dsse       bool
+		signatures int
+	}{
+		{
+			name:    "statement",
+			content: statement,
+		},
+		{
+			name:       "envelope",
+			content:    envelope,
+			dsse:       true,
+			signatures: 2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(tt.content)))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			// Available before verification.
+			content := verification.Content()
+			if diff := cmp.Diff(predicateType, content.PredicateType); diff != "" {
+				t.Fatalf("unexpected predicate type (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(predicateVersion, content.PredicateVersion); diff != "" {
+				t.Fatalf("unexpected predicate version (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff([]intoto.Subject{{Digests: digests}}, content.Subjects); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(scopes, content.Scopes); diff != "" {
+				t.Fatalf("unexpected scopes (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(1, len(content.Policy)); diff != "" {
+				t.Fatalf("unexpected policy (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff("publishr_id", content.Properties[publishRootProperty]); diff != "" {
+				t.Fatalf("unexpected publish root (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.dsse, content.DSSE); diff != "" {
+				t.Fatalf("unexpected dsse (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.signatures, content.Signatures); diff != "" {
+				t.Fatalf("unexpected signatures (-want +got): \n%s", diff)
+			}
+			// The view does not alias the attestation.
+			content.Subjects[0].Digests["sha256"] = "modified"
+			content.Scopes["environment"] = "modified"
+			if err := verification.Verify(digests, scopes, AllowUnknownScopes(), AllowUnsignedDSSE()); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
 		})
 	}
 }
@@ -9,4 +9,96 @@ var (
 	ErrorInternal     = errors.New("internal error")
 	ErrorVerification = errors.New("verification error")
 	ErrorMismatch     = errors.New("mismatch error")
+	ErrorRevoked      = errors.New("revoked")
+	// ErrorTransient marks a failure that may succeed if retried,
+	// e.g. a rate-limited registry. See TransientError.
+	ErrorTransient = errors.New("transient error")
 )
+
+// NOTE: the names and codes are stable and must not be changed, since
+// they are serialized, e.g. in JSON results and metric labels.
+var categories = []struct {
+	name string
+	code string
+	err  error
+}{
+	{"invalid_field", "invalid_field", ErrorInvalidField},
+	{"invalid_input", "invalid_input", ErrorInvalidInput},
+	{"not_found", "not_found", ErrorNotFound},
+	{"internal", "internal", ErrorInternal},
+	{"verification", "verification_failed", ErrorVerification},
+	{"mismatch", "mismatch", ErrorMismatch},
+	{"revoked", "revoked", ErrorRevoked},
+	{"transient", "transient", ErrorTransient},
+}
+
+// Category returns a stable name for the sentinel error wrapped by err,
+// e.g. "verification", so that the sentinel survives serialization, see
+// FromCategory(). It returns "unknown" if err does not wrap any of the
+// sentinels, and an empty string if err is nil.
+func Category(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, c := range categories {
+		if errors.Is(err, c.err) {
+			return c.name
+		}
+	}
+	return "unknown"
+}
+
+// FromCategory returns the sentinel error for a name returned by Category,
+// or nil if the name is unknown.
+func FromCategory(name string) error {
+	for _, c := range categories {
+		if c.name == name {
+			return c.err
+		}
+	}
+	return nil
+}
+
+// Code returns a stable short code for the sentinel error wrapped by err,
+// e.g. "verification_failed", see FromCode(). It returns "unknown" if err
+// does not wrap any of the sentinels, and an empty string if err is nil.
+func Code(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, c := range categories {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return "unknown"
+}
+
+// FromCode returns the sentinel error for a code returned by Code,
+// or nil if the code is unknown.
+func FromCode(code string) error {
+	for _, c := range categories {
+		if c.code == code {
+			return c.err
+		}
+	}
+	return nil
+}
+
+// Violations returns the individual errors accumulated in err,
+// e.g. by errors.Join, in order. It returns nil if err is nil
+// and a single-element list if err is not a multi-error.
+func Violations(err error) []error {
+	if err == nil {
+		return nil
+	}
+	multi, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var list []error
+	for _, e := range multi.Unwrap() {
+		list = append(list, Violations(e)...)
+	}
+	return list
+}
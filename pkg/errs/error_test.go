@@ -0,0 +1,150 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_Violations(t *testing.T) {
+	t.Parallel()
+	err1 := fmt.Errorf("%w: first", ErrorInvalidField)
+	err2 := fmt.Errorf("%w: second", ErrorInvalidField)
+	err3 := fmt.Errorf("%w: third", ErrorNotFound)
+	tests := []struct {
+		name     string
+		err      error
+		expected []error
+	}{
+		{
+			name: "nil error",
+		},
+		{
+			name:     "single error",
+			err:      err1,
+			expected: []error{err1},
+		},
+		{
+			name:     "joined errors",
+			err:      errors.Join(err1, err2),
+			expected: []error{err1, err2},
+		},
+		{
+			name:     "nested joined errors",
+			err:      errors.Join(err1, errors.Join(err2, err3)),
+			expected: []error{err1, err2, err3},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			violations := Violations(tt.err)
+			if diff := cmp.Diff(tt.expected, violations, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+// sentinels returns the names of the exported error variables of the package.
+func sentinels(t *testing.T) []string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "error.go", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if name.IsExported() {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func Test_CategoryAndCode(t *testing.T) {
+	t.Parallel()
+	values := map[string]error{
+		"ErrorInvalidField": ErrorInvalidField,
+		"ErrorInvalidInput": ErrorInvalidInput,
+		"ErrorNotFound":     ErrorNotFound,
+		"ErrorInternal":     ErrorInternal,
+		"ErrorVerification": ErrorVerification,
+		"ErrorMismatch":     ErrorMismatch,
+		"ErrorRevoked":      ErrorRevoked,
+		"ErrorTransient":    ErrorTransient,
+	}
+	names := sentinels(t)
+	if diff := cmp.Diff(len(values), len(names)); diff != "" {
+		t.Fatalf("unexpected sentinels %q (-want +got): \n%s", names, diff)
+	}
+	categories := make(map[string]bool)
+	codes := make(map[string]bool)
+	for _, name := range names {
+		sentinel, exists := values[name]
+		if !exists {
+			t.Fatalf("sentinel (%q) is not tested", name)
+		}
+		category := Category(fmt.Errorf("%w: some error", sentinel))
+		if category == "unknown" {
+			t.Fatalf("sentinel (%q) has no category", name)
+		}
+		if categories[category] {
+			t.Fatalf("sentinel (%q) has a duplicate category (%q)", name, category)
+		}
+		categories[category] = true
+		if diff := cmp.Diff(sentinel, FromCategory(category), cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("unexpected sentinel (-want +got): \n%s", diff)
+		}
+		code := Code(fmt.Errorf("%w: some error", sentinel))
+		if code == "unknown" {
+			t.Fatalf("sentinel (%q) has no code", name)
+		}
+		if codes[code] {
+			t.Fatalf("sentinel (%q) has a duplicate code (%q)", name, code)
+		}
+		codes[code] = true
+		if diff := cmp.Diff(sentinel, FromCode(code), cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("unexpected sentinel (-want +got): \n%s", diff)
+		}
+	}
+	if diff := cmp.Diff("verification", Category(ErrorVerification)); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("unknown", Category(errors.New("some error"))); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("", Category(nil)); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+	if err := FromCategory("unknown"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff("verification_failed", Code(ErrorVerification)); diff != "" {
+		t.Fatalf("unexpected code (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("unknown", Code(errors.New("some error"))); diff != "" {
+		t.Fatalf("unexpected code (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("", Code(nil)); diff != "" {
+		t.Fatalf("unexpected code (-want +got): \n%s", diff)
+	}
+	if err := FromCode("unknown"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package errs
+
+import (
+	"errors"
+	"time"
+)
+
+// TransientError is returned by a verifier when a verification could not
+// complete but may succeed if retried, e.g. the registry responded with
+// 429 or 5xx. errors.Is() matches it with ErrorTransient.
+type TransientError struct {
+	// RetryAfter, if non-zero, is the minimum time
+	// to wait before retrying, e.g. from a Retry-After header.
+	RetryAfter time.Duration
+	// Err is the underlying error.
+	Err error
+}
+
+// TransientErrorNew returns a TransientError wrapping err.
+func TransientErrorNew(err error, retryAfter time.Duration) *TransientError {
+	return &TransientError{
+		RetryAfter: retryAfter,
+		Err:        err,
+	}
+}
+
+func (e *TransientError) Error() string {
+	if e.Err == nil {
+		return ErrorTransient.Error()
+	}
+	return ErrorTransient.Error() + ": " + e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrorTransient.
+func (e *TransientError) Is(target error) bool {
+	return target == ErrorTransient
+}
+
+// IsTransient returns true if err may succeed if retried: it wraps
+// ErrorTransient, and none of the errors of a policy decision,
+// e.g. ErrorVerification, which must never be retried.
+func IsTransient(err error) bool {
+	if !errors.Is(err, ErrorTransient) {
+		return false
+	}
+	for _, denial := range []error{ErrorVerification, ErrorMismatch, ErrorRevoked,
+		ErrorInvalidField, ErrorInvalidInput, ErrorNotFound} {
+		if errors.Is(err, denial) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_TransientError(t *testing.T) {
+	t.Parallel()
+	cause := errors.New("429 too many requests")
+	err := fmt.Errorf("wrapped: %w", TransientErrorNew(cause, time.Second))
+	if !errors.Is(err, ErrorTransient) {
+		t.Fatalf("error (%v) does not wrap (%v)", err, ErrorTransient)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("error (%v) does not wrap (%v)", err, cause)
+	}
+	var transientErr *TransientError
+	if !errors.As(err, &transientErr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if diff := cmp.Diff(time.Second, transientErr.RetryAfter); diff != "" {
+		t.Fatalf("unexpected retry after (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("wrapped: transient error: 429 too many requests", err.Error()); diff != "" {
+		t.Fatalf("unexpected message (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("transient", Category(err)); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("transient", Code(err)); diff != "" {
+		t.Fatalf("unexpected code (-want +got): \n%s", diff)
+	}
+}
+
+func Test_IsTransient(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name: "nil error",
+		},
+		{
+			name: "other error",
+			err:  errors.New("some error"),
+		},
+		{
+			name:     "transient sentinel",
+			err:      fmt.Errorf("%w: 503 service unavailable", ErrorTransient),
+			expected: true,
+		},
+		{
+			name:     "transient error",
+			err:      TransientErrorNew(errors.New("429 too many requests"), 0),
+			expected: true,
+		},
+		{
+			name: "verification error",
+			err:  TransientErrorNew(fmt.Errorf("%w: no attestation", ErrorVerification), 0),
+		},
+		{
+			name: "mismatch error",
+			err:  fmt.Errorf("%w: %w: digest", ErrorTransient, ErrorMismatch),
+		},
+		{
+			name: "revoked error",
+			err:  errors.Join(ErrorTransient, ErrorRevoked),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, IsTransient(tt.err)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
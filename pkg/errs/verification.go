@@ -0,0 +1,85 @@
+package errs
+
+// Check identifies the verification check that failed.
+// NOTE: the values are stable and must not be changed,
+// since callers may serialize or match them.
+type Check string
+
+const (
+	// CheckDigest verifies the digests of the attestation subjects.
+	CheckDigest Check = "digest"
+	// CheckSubjectURI verifies the package the attestation is for,
+	// e.g. its name and registry.
+	CheckSubjectURI Check = "subject-uri"
+	// CheckPredicateType verifies the predicate type of the attestation.
+	CheckPredicateType Check = "predicate-type"
+	// CheckStatementType verifies the in-toto statement type.
+	CheckStatementType Check = "statement-type"
+	// CheckEnvironment verifies the package environment.
+	CheckEnvironment Check = "environment"
+	// CheckVersion verifies the package version.
+	CheckVersion Check = "version"
+	// CheckBuildLevel verifies the SLSA build level.
+	CheckBuildLevel Check = "build-level"
+	// CheckPolicy verifies the policy recorded in the attestation.
+	CheckPolicy Check = "policy"
+	// CheckScope verifies the deployment scopes.
+	CheckScope Check = "scope"
+	// CheckPackage verifies other package fields, e.g. the architecture.
+	CheckPackage Check = "package"
+	// CheckAuthor verifies the tool that created the attestation.
+	CheckAuthor Check = "author"
+	// CheckProperty verifies the attestation properties.
+	CheckProperty Check = "property"
+	// CheckBaseImage verifies the base images of the package.
+	CheckBaseImage Check = "base-image"
+	// CheckSBOM verifies the SBOM referenced by the attestation.
+	CheckSBOM Check = "sbom"
+	// CheckPublishRoot verifies the publish root of a deployment.
+	CheckPublishRoot Check = "publish-root"
+	// CheckCreationTime verifies the creation time of the attestation.
+	CheckCreationTime Check = "creation-time"
+	// CheckSignature verifies the signatures of a DSSE envelope.
+	CheckSignature Check = "signature"
+	// CheckTransparencyLog verifies the inclusion in a transparency log.
+	CheckTransparencyLog Check = "transparency-log"
+	// CheckRevocation verifies that the attestation is not revoked.
+	CheckRevocation Check = "revocation"
+	// CheckExpiry verifies that the attestation has not expired.
+	CheckExpiry Check = "expiry"
+	// CheckNonce verifies the nonce recorded in the attestation.
+	CheckNonce Check = "nonce"
+)
+
+// VerificationError is returned when an attestation fails verification.
+// It identifies the failing check, and wraps an error that wraps one of
+// the sentinels, usually ErrorMismatch, so that errors.Is() matches it.
+type VerificationError struct {
+	Check Check
+	// Expected is the value the check requires, e.g. the build level.
+	// It is empty if the check has no single expected value.
+	Expected string
+	// Got is the value in the attestation. It is empty if the
+	// attestation does not contain it.
+	Got string
+	// Err is the underlying error.
+	Err error
+}
+
+// VerificationErrorNew returns a VerificationError for the check wrapping err.
+func VerificationErrorNew(check Check, expected, got string, err error) *VerificationError {
+	return &VerificationError{
+		Check:    check,
+		Expected: expected,
+		Got:      got,
+		Err:      err,
+	}
+}
+
+func (e *VerificationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,76 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Check(t *testing.T) {
+	t.Parallel()
+	// NOTE: the values are stable, see Check.
+	checks := map[Check]string{
+		CheckDigest:          "digest",
+		CheckSubjectURI:      "subject-uri",
+		CheckPredicateType:   "predicate-type",
+		CheckStatementType:   "statement-type",
+		CheckEnvironment:     "environment",
+		CheckVersion:         "version",
+		CheckBuildLevel:      "build-level",
+		CheckPolicy:          "policy",
+		CheckScope:           "scope",
+		CheckPackage:         "package",
+		CheckAuthor:          "author",
+		CheckProperty:        "property",
+		CheckBaseImage:       "base-image",
+		CheckSBOM:            "sbom",
+		CheckPublishRoot:     "publish-root",
+		CheckCreationTime:    "creation-time",
+		CheckSignature:       "signature",
+		CheckTransparencyLog: "transparency-log",
+		CheckRevocation:      "revocation",
+		CheckExpiry:          "expiry",
+		CheckNonce:           "nonce",
+	}
+	for check, value := range checks {
+		if diff := cmp.Diff(value, string(check)); diff != "" {
+			t.Fatalf("unexpected check (-want +got): \n%s", diff)
+		}
+	}
+}
+
+func Test_VerificationError(t *testing.T) {
+	t.Parallel()
+	cause := fmt.Errorf("%w: level (3) != attestation (2)", ErrorMismatch)
+	err := fmt.Errorf("wrapped: %w", VerificationErrorNew(CheckBuildLevel, "3", "2", cause))
+	if !errors.Is(err, ErrorMismatch) {
+		t.Fatalf("error (%v) does not wrap (%v)", err, ErrorMismatch)
+	}
+	if errors.Is(err, ErrorVerification) {
+		t.Fatalf("error (%v) wraps (%v)", err, ErrorVerification)
+	}
+	var verificationErr *VerificationError
+	if !errors.As(err, &verificationErr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if diff := cmp.Diff([]string{"build-level", "3", "2"}, []string{string(verificationErr.Check),
+		verificationErr.Expected, verificationErr.Got}); diff != "" {
+		t.Fatalf("unexpected error (-want +got): \n%s", diff)
+	}
+	if verificationErr.Err != cause {
+		t.Fatalf("unexpected cause: %v", verificationErr.Err)
+	}
+	if diff := cmp.Diff("wrapped: mismatch error: level (3) != attestation (2)", err.Error()); diff != "" {
+		t.Fatalf("unexpected message (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff("mismatch", Category(err)); diff != "" {
+		t.Fatalf("unexpected category (-want +got): \n%s", diff)
+	}
+	// NOTE: a verification error is a single violation.
+	if diff := cmp.Diff(1, len(Violations(VerificationErrorNew(CheckDigest, "", "",
+		fmt.Errorf("%w: %w", ErrorMismatch, errors.Join(cause, cause)))))); diff != "" {
+		t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+	}
+}
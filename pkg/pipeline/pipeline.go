@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Stage identifies a stage of the pipeline.
+type Stage int
+
+const (
+	// StagePublish is the evaluation of the publish policy.
+	StagePublish Stage = iota + 1
+	// StageDeployment is the evaluation of the deployment policy.
+	StageDeployment
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StagePublish:
+		return "publish"
+	case StageDeployment:
+		return "deployment"
+	default:
+		return fmt.Sprintf("unknown stage (%d)", int(s))
+	}
+}
+
+// StageError is returned when a stage of the pipeline fails.
+// It wraps the error of the stage.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("[%s] %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// PublishInputs defines the inputs of the publish stage.
+type PublishInputs struct {
+	Policy *publish.Policy
+	// Verifier verifies the build provenance.
+	Verifier publish.AttestationVerifier
+	// PackageHelper is the helper the publish policy was created with.
+	PackageHelper publish.PackageHelper
+	// PublisherID is the identity of the publish root the publish
+	// attestation is attributed to. The deployment policy must
+	// trust it as a publish root.
+	PublisherID string
+	// PublisherIssuer, if set, is the OIDC issuer of the certificate
+	// identity of the publisher. It is required to match publish
+	// roots defined by a certificate identity, in which case
+	// PublisherID is the subject of the certificate.
+	PublisherIssuer string
+	// Environment, if set, is the environment the package is published to.
+	Environment *string
+	// Options are passed to the creation of the publish attestation.
+	Options []publish.AttestationCreationOption
+}
+
+// DeploymentInputs defines the inputs of the deployment stage.
+type DeploymentInputs struct {
+	Policy *deployment.Policy
+	// PolicyID is the ID of the project policy to evaluate.
+	PolicyID string
+	// Options are passed to the creation of the deployment attestation.
+	Options []deployment.AttestationCreationOption
+}
+
+// Inputs defines the inputs of EvaluateAndAttest.
+type Inputs struct {
+	Digests intoto.DigestSet
+	// PackageName is the name of the package in both
+	// the publish and the deployment policies.
+	PackageName string
+	Publish     PublishInputs
+	Deployment  DeploymentInputs
+}
+
+// Attestations contains the attestations created by EvaluateAndAttest.
+type Attestations struct {
+	Publish    []byte
+	Deployment []byte
+}
+
+func (i *Inputs) validate() error {
+	if err := i.Digests.Validate(); err != nil {
+		return err
+	}
+	if i.PackageName == "" {
+		return fmt.Errorf("%w: package name is empty", errs.ErrorInvalidInput)
+	}
+	if i.Publish.Policy == nil {
+		return fmt.Errorf("%w: publish policy is nil", errs.ErrorInvalidInput)
+	}
+	if i.Publish.Verifier == nil {
+		return fmt.Errorf("%w: publish verifier is nil", errs.ErrorInvalidInput)
+	}
+	if i.Publish.PackageHelper == nil {
+		return fmt.Errorf("%w: package helper is nil", errs.ErrorInvalidInput)
+	}
+	if i.Publish.PublisherID == "" {
+		return fmt.Errorf("%w: publisher ID is empty", errs.ErrorInvalidInput)
+	}
+	if i.Deployment.Policy == nil {
+		return fmt.Errorf("%w: deployment policy is nil", errs.ErrorInvalidInput)
+	}
+	if i.Deployment.PolicyID == "" {
+		return fmt.Errorf("%w: deployment policy ID is empty", errs.ErrorInvalidInput)
+	}
+	return nil
+}
+
+// EvaluateAndAttest evaluates the publish policy, creates the publish
+// attestation, then evaluates the deployment policy against it and
+// creates the deployment attestation. It stops at the first stage that
+// fails and returns a *StageError indicating the stage. Invalid inputs
+// are reported before any evaluation.
+// NOTE: The attestations are not signed; the publish attestation is
+// verified in memory, so its signature is not checked.
+func EvaluateAndAttest(ctx context.Context, inputs Inputs) (*Attestations, error) {
+	if err := inputs.validate(); err != nil {
+		return nil, err
+	}
+
+	// Publish.
+	if err := ctx.Err(); err != nil {
+		return nil, &StageError{Stage: StagePublish, Err: err}
+	}
+	publishAtt, err := evaluatePublish(ctx, inputs)
+	if err != nil {
+		return nil, &StageError{Stage: StagePublish, Err: err}
+	}
+
+	// Deployment.
+	if err := ctx.Err(); err != nil {
+		return nil, &StageError{Stage: StageDeployment, Err: err}
+	}
+	deploymentAtt, err := evaluateDeployment(ctx, inputs, publishAtt)
+	if err != nil {
+		return nil, &StageError{Stage: StageDeployment, Err: err}
+	}
+	return &Attestations{
+		Publish:    publishAtt,
+		Deployment: deploymentAtt,
+	}, nil
+}
+
+func evaluatePublish(ctx context.Context, inputs Inputs) ([]byte, error) {
+	result := inputs.Publish.Policy.Evaluate(inputs.Digests, inputs.PackageName,
+		publish.RequestOption{
+			Environment: inputs.Publish.Environment,
+		},
+		publish.AttestationVerificationOption{
+			Verifier: inputs.Publish.Verifier,
+			Context:  ctx,
+		})
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+	att, err := result.AttestationNew(inputs.Publish.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return att.ToBytes()
+}
+
+func evaluateDeployment(ctx context.Context, inputs Inputs, publishAtt []byte) ([]byte, error) {
+	result := inputs.Deployment.Policy.Evaluate(inputs.Digests, inputs.PackageName, inputs.Deployment.PolicyID,
+		deployment.AttestationVerificationOption{
+			Verifier: &publishVerifier{
+				attestation:   publishAtt,
+				packageHelper: inputs.Publish.PackageHelper,
+				publisherID:   inputs.Publish.PublisherID,
+				issuer:        inputs.Publish.PublisherIssuer,
+			},
+			Context: ctx,
+		})
+	if err := result.Error(); err != nil {
+		return nil, err
+	}
+	att, err := result.AttestationNew(inputs.Deployment.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return att.ToBytes()
+}
@@ -0,0 +1,372 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/deploymenttest"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files_reader"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/named_files_reader"
+)
+
+type packageHelper struct {
+	registry string
+}
+
+func (p *packageHelper) PolicyPackageName(desc intoto.PackageDescriptor) (string, error) {
+	return desc.Name, nil
+}
+
+func (p *packageHelper) PackageDescriptor(name string) (intoto.PackageDescriptor, error) {
+	return intoto.PackageDescriptor{
+		Name:     name,
+		Registry: p.registry,
+	}, nil
+}
+
+type buildVerifier struct {
+	builderID string
+}
+
+func (v *buildVerifier) VerifyBuildAttestation(digests intoto.DigestSet, packageName, builderID, sourceURI string,
+	opts publish.AttestationVerifierBuildOptions) error {
+	if builderID != v.builderID {
+		return fmt.Errorf("%w: builder (%q) != (%q)", errs.ErrorMismatch, builderID, v.builderID)
+	}
+	return nil
+}
+
+func (v *buildVerifier) BaseImages(digests intoto.DigestSet, packageName string) ([]string, error) {
+	return nil, nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return path
+}
+
+func newPublishPolicy(t *testing.T, helper publish.PackageHelper) *publish.Policy {
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3},
+		{"id": "https://github.com/actions/runner/self-hosted", "name": "github_actions_level_2", "slsa_level": 2}]}}`
+	project := `{"format": 1, "package": {"name": "package_name", "environment": {"any_of": ["dev", "prod", "staging"]}},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`
+	dir := t.TempDir()
+	pol, err := publish.PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+		files_reader.FromPaths([]string{writeFile(t, dir, "project.json", project)}), helper)
+	if err != nil {
+		t.Fatalf("failed to create publish policy: %v", err)
+	}
+	return pol
+}
+
+func newDeploymentPolicy(t *testing.T) *deployment.Policy {
+	org := `{"format": 1, "roots": {"publish": [
+		{"id": "publishr_id", "build": {"max_slsa_level": 3}}]}}`
+	project := `{"format": 1, "principal": {"uri": "principal_uri"}, "build": {"require_slsa_level": 3},
+		"packages": [{"name": "package_name", "environment": {"any_of": ["dev", "prod"]}}]}`
+	dir := t.TempDir()
+	pol, err := deployment.PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+		named_files_reader.FromPaths(dir, []string{writeFile(t, dir, "policy_id", project)}))
+	if err != nil {
+		t.Fatalf("failed to create deployment policy: %v", err)
+	}
+	return pol
+}
+
+func Test_EvaluateAndAttest(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	helper := &packageHelper{registry: "registry"}
+	publishPolicy := newPublishPolicy(t, helper)
+	deploymentPolicy := newDeploymentPolicy(t)
+	newInputs := func() Inputs {
+		return Inputs{
+			Digests:     digests,
+			PackageName: "package_name",
+			Publish: PublishInputs{
+				Policy:        publishPolicy,
+				Verifier:      &buildVerifier{builderID: "https://github.com/actions/runner/github-hosted"},
+				PackageHelper: helper,
+				PublisherID:   "publishr_id",
+				Environment:   asPointer("prod"),
+			},
+			Deployment: DeploymentInputs{
+				Policy:   deploymentPolicy,
+				PolicyID: "policy_id",
+			},
+		}
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		inputs   func(*Inputs)
+		stage    Stage
+		expected error
+	}{
+		{
+			name: "allowed",
+		},
+		{
+			name: "publish denied",
+			inputs: func(i *Inputs) {
+				i.Publish.Verifier = &buildVerifier{builderID: "https://github.com/actions/runner/self-hosted"}
+			},
+			stage:    StagePublish,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "deployment denied environment",
+			inputs: func(i *Inputs) {
+				i.Publish.Environment = asPointer("staging")
+			},
+			stage:    StageDeployment,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "deployment denied publisher",
+			inputs: func(i *Inputs) {
+				i.Publish.PublisherID = "other_publishr_id"
+			},
+			stage:    StageDeployment,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "unknown deployment policy",
+			inputs: func(i *Inputs) {
+				i.Deployment.PolicyID = "other_policy_id"
+			},
+			stage:    StageDeployment,
+			expected: errs.ErrorNotFound,
+		},
+		{
+			name:     "cancelled context",
+			ctx:      cancelled,
+			stage:    StagePublish,
+			expected: context.Canceled,
+		},
+		{
+			name: "empty package name",
+			inputs: func(i *Inputs) {
+				i.PackageName = ""
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "nil deployment policy",
+			inputs: func(i *Inputs) {
+				i.Deployment.Policy = nil
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			inputs := newInputs()
+			if tt.inputs != nil {
+				tt.inputs(&inputs)
+			}
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			atts, err := EvaluateAndAttest(ctx, inputs)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			var stageErr *StageError
+			if errors.As(err, &stageErr) {
+				if diff := cmp.Diff(tt.stage, stageErr.Stage); diff != "" {
+					t.Fatalf("unexpected stage (-want +got): \n%s", diff)
+				}
+			} else if tt.stage != 0 {
+				t.Fatalf("expected a stage error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			// Verify both attestations.
+			pverification, err := publish.VerificationNew(io.NopCloser(bytes.NewReader(atts.Publish)), helper)
+			if err != nil {
+				t.Fatalf("failed to create publish verification: %v", err)
+			}
+			if err := pverification.Verify(digests, "package_name", publish.IsSlsaBuildLevel(3),
+				publish.IsPackageEnvironment("prod")); err != nil {
+				t.Fatalf("failed to verify publish attestation: %v", err)
+			}
+			dverification, err := deployment.VerificationNew(io.NopCloser(bytes.NewReader(atts.Deployment)))
+			if err != nil {
+				t.Fatalf("failed to create deployment verification: %v", err)
+			}
+			scopes := map[string]string{
+				deployment.ScopeKeyKubernetesServiceAccount: "principal_uri",
+			}
+			if err := dverification.Verify(digests, scopes, deployment.IsSlsaBuildLevelOrAbove(3)); err != nil {
+				t.Fatalf("failed to verify deployment attestation: %v", err)
+			}
+		})
+	}
+}
+
+func Test_publishVerifierConformance(t *testing.T) {
+	t.Parallel()
+	deploymenttest.RunVerifierConformance(t, func(t *testing.T, att deploymenttest.PublishAttestation) deployment.AttestationVerifier {
+		content, err := att.Statement()
+		if err != nil {
+			t.Fatalf("failed to create statement: %v", err)
+		}
+		return &publishVerifier{
+			attestation:   content,
+			packageHelper: &packageHelper{registry: att.Registry},
+			publisherID:   att.PublisherID,
+			issuer:        att.Issuer,
+		}
+	})
+}
+
+func Test_verifyIdentity(t *testing.T) {
+	t.Parallel()
+	issuer := "https://token.actions.githubusercontent.com"
+	subject := "https://github.com/org/repo/.github/workflows/publish.yml@refs/heads/main"
+	tests := []struct {
+		name     string
+		issuer   string
+		opts     deployment.AttestationVerifierPublishOptions
+		expected error
+	}{
+		{
+			name: "publisher ID",
+			opts: deployment.AttestationVerifierPublishOptions{PublishrID: subject},
+		},
+		{
+			name: "publisher regex",
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "https://github.com/org/repo/.*",
+			},
+		},
+		{
+			name: "publisher regex prefix",
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "https://github.com/org/repo/",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "publisher regex alternation",
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "other|https://github.com/org/repo/.*",
+			},
+		},
+		{
+			name:   "identity",
+			issuer: issuer,
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "https://github.com/org/.*",
+				Identity: &deployment.RootIdentity{
+					Issuer:       issuer,
+					SubjectRegex: regexp.MustCompile("https://github.com/org/.*"),
+				},
+			},
+		},
+		{
+			name:   "identity issuer mismatch",
+			issuer: "https://accounts.google.com",
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "https://github.com/org/.*",
+				Identity: &deployment.RootIdentity{
+					Issuer:       issuer,
+					SubjectRegex: regexp.MustCompile("https://github.com/org/.*"),
+				},
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "identity without issuer",
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "https://github.com/org/.*",
+				Identity: &deployment.RootIdentity{
+					Issuer:       issuer,
+					SubjectRegex: regexp.MustCompile("https://github.com/org/.*"),
+				},
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:   "identity subject mismatch",
+			issuer: issuer,
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "https://github.com/other/.*",
+				Identity: &deployment.RootIdentity{
+					Issuer:       issuer,
+					SubjectRegex: regexp.MustCompile("https://github.com/other/.*"),
+				},
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "invalid regex",
+			opts: deployment.AttestationVerifierPublishOptions{
+				PublishrIDRegex: "(",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			v := publishVerifier{publisherID: subject, issuer: tt.issuer}
+			err := v.verifyIdentity(tt.opts)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Stage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		stage    Stage
+		expected string
+	}{
+		{stage: StagePublish, expected: "publish"},
+		{stage: StageDeployment, expected: "deployment"},
+		{stage: Stage(0), expected: "unknown stage (0)"},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.expected, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, tt.stage.String()); diff != "" {
+				t.Fatalf("unexpected stage (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func asPointer[K interface{}](o K) *K {
+	return &o
+}
@@ -0,0 +1,43 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/deployment"
+	"github.com/slsa-framework/slsa-policy/pkg/deployment/verifiers/publishattestation"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// publishVerifier verifies the publish attestation created by the
+// publish stage, for the deployment stage.
+type publishVerifier struct {
+	attestation   []byte
+	packageHelper publish.PackageHelper
+	publisherID   string
+	issuer        string
+}
+
+// VerifyPublishAttestation implements deployment.AttestationVerifier.
+// The attestation is attributed to the publisher of the inputs, see
+// PublishInputs.PublisherID.
+func (v *publishVerifier) VerifyPublishAttestation(digests intoto.DigestSet, packageName string,
+	environment []string, opts deployment.AttestationVerifierPublishOptions) (*string, error) {
+	if err := v.verifyIdentity(opts); err != nil {
+		return nil, err
+	}
+	return publishattestation.VerifyAttestation(v.attestation, v.packageHelper, digests, packageName,
+		environment, opts)
+}
+
+// verifyIdentity verifies the publisher against the publish root, see
+// publishattestation.MatchRoot. The issuer must also match if the root
+// is defined by a certificate identity.
+func (v *publishVerifier) verifyIdentity(opts deployment.AttestationVerifierPublishOptions) error {
+	if opts.Identity != nil && opts.Identity.Issuer != v.issuer {
+		return fmt.Errorf("%w: publisher issuer (%q) != publish root issuer (%q)", errs.ErrorMismatch,
+			v.issuer, opts.Identity.Issuer)
+	}
+	return publishattestation.MatchRoot(v.publisherID, opts)
+}
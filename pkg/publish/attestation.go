@@ -15,18 +15,43 @@ type predicate struct {
 	// NOTE: We may replace the descriptor by a PURL.
 	Package         intoto.PackageDescriptor `json:"package"`
 	Properties      properties               `json:"properties,omitempty"`
+	// Author is the tool that created the attestation, if recorded.
+	Author          *author                  `json:"author,omitempty"`
 	// TODO: properties for dependencies.
 }
 
 type attestation struct {
 	intoto.Header
 	Predicate predicate `json:"predicate"`
+	// version is the predicate version the attestation
+	// was created with, set by translate().
+	version string
 }
 
 type properties map[string]interface{}
 
+// author is the tool that created the attestation,
+// e.g. the policy evaluator.
+type author struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// builder is the value of the builder property.
+type builder struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SourceURI string `json:"sourceUri"`
+}
+
 const (
-	statementType      = "https://in-toto.io/Statement/v1"
-	predicateType      = "https://slsa.dev/publish/v0.1"
-	buildLevelProperty = "slsa.dev/build/level"
+	statementType              = intoto.StatementType
+	predicateType              = "https://slsa.dev/publish/v0.2"
+	predicateTypeV01           = "https://slsa.dev/publish/v0.1"
+	buildLevelProperty         = "slsa.dev/build/level"
+	baseImagesProperty         = "slsa.dev/build/baseImages"
+	builderProperty            = "slsa.dev/build/builder"
+	sbomProperty               = "slsa.dev/publish/sbom"
+	evaluationDurationProperty = "slsa.dev/telemetry/evaluationDurationMs"
+	verifierCallsProperty      = "slsa.dev/telemetry/verifierCalls"
 )
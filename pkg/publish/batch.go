@@ -0,0 +1,59 @@
+package publish
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/parallel"
+)
+
+// EvaluationRequest defines a request to evaluate,
+// e.g. one of the packages built by a pipeline.
+type EvaluationRequest struct {
+	Digests           intoto.DigestSet
+	PolicyPackageName string
+	Environment       *string
+}
+
+// BatchOptions defines options for EvaluateBatch().
+type BatchOptions struct {
+	// Verifier verifies the build attestations
+	// of every request.
+	Verifier AttestationVerifier
+	// Workers is the maximum number of requests evaluated
+	// concurrently. It defaults to 1. The verifier must be
+	// safe for concurrent use if Workers is greater than 1.
+	Workers int
+}
+
+// EvaluateBatch evaluates each request against the policy, with the same
+// verifier, and returns the results in the order of the requests. A request
+// that cannot be evaluated, e.g. with invalid digests, is reported in its
+// result and does not fail the batch.
+// It returns an error only if the options are invalid.
+func (p *Policy) EvaluateBatch(requests []EvaluationRequest, opts BatchOptions) ([]PolicyEvaluationResult, error) {
+	if opts.Verifier == nil {
+		return nil, fmt.Errorf("%w: verifier is nil", errs.ErrorInvalidInput)
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = 1
+	}
+	if workers < 1 {
+		return nil, fmt.Errorf("%w: workers (%d) must be positive", errs.ErrorInvalidInput, workers)
+	}
+	verification := AttestationVerificationOption{
+		Verifier: opts.Verifier,
+	}
+	// NOTE: each request writes its own entry, so the
+	// results do not depend on scheduling.
+	results := make([]PolicyEvaluationResult, len(requests))
+	parallel.ForEach(len(requests), workers, func(index int) {
+		req := &requests[index]
+		results[index] = p.Evaluate(req.Digests, req.PolicyPackageName, RequestOption{
+			Environment: req.Environment,
+		}, verification)
+	})
+	return results, nil
+}
@@ -0,0 +1,133 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_OptionsOrder(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	att := attestation{
+		Header: intoto.Header{
+			Type:          statementType,
+			PredicateType: predicateType,
+			Subjects: []intoto.Subject{
+				{Digests: digests},
+			},
+		},
+		Predicate: predicate{
+			CreationTime: intoto.Now(),
+			Package: intoto.PackageDescriptor{
+				Name:        packageName,
+				Registry:    registry,
+				Version:     "1.2.3",
+				Environment: "prod",
+			},
+			Properties: map[string]interface{}{
+				buildLevelProperty: 3,
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name: "all options pass",
+			options: []VerificationOption{
+				IsPackageVersion("1.2.3"),
+				IsPackageEnvironment("prod"),
+				IsSlsaBuildLevel(3),
+				IsSlsaBuildLevelOrAbove(2),
+				IsSlsaBuildLevelOrAbove(3),
+				PackageNameMatches(`^registry/package_`),
+			},
+		},
+		{
+			name: "identical duplicates collapse",
+			options: []VerificationOption{
+				IsPackageVersion("1.2.3"),
+				IsPackageVersion("1.2.3"),
+				IsSlsaBuildLevel(3),
+				IsSlsaBuildLevel(3),
+			},
+		},
+		{
+			name: "conflicting duplicates",
+			options: []VerificationOption{
+				IsPackageVersion("1.2.3"),
+				IsPackageVersion("1.2.4"),
+				IsSlsaBuildLevel(3),
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "multiple failing options",
+			options: []VerificationOption{
+				IsSlsaBuildLevel(2),
+				PackageNameMatches(`^other/`),
+				IsPackageVersion("1.2.4"),
+				IsPackageEnvironment("dev"),
+			},
+			expected: errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			// Verify with the options in different orders.
+			var errStr string
+			for i, options := range permutations(tt.options) {
+				err := verification.Verify(digests, packageName, options...)
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if err == nil {
+					continue
+				}
+				if i == 0 {
+					errStr = err.Error()
+				}
+				if diff := cmp.Diff(errStr, err.Error()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+// permutations returns the rotations and reversed rotations of the options.
+func permutations(options []VerificationOption) [][]VerificationOption {
+	var res [][]VerificationOption
+	for i := range options {
+		rotated := append(append([]VerificationOption{}, options[i:]...), options[:i]...)
+		reversed := make([]VerificationOption, len(rotated))
+		for j := range rotated {
+			reversed[len(rotated)-1-j] = rotated[j]
+		}
+		res = append(res, rotated, reversed)
+	}
+	return res
+}
@@ -1,17 +1,33 @@
 package publish
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/semver"
 )
 
 type Creation struct {
 	attestation
-	safeMode bool
+	safeMode         bool
+	telemetry        *telemetry
+	telemetryEnabled bool
+	tlog             TransparencyLog
+}
+
+// telemetry contains data recorded during the policy evaluation.
+type telemetry struct {
+	duration      time.Duration
+	verifierCalls int
 }
 
 type AttestationCreationOption func(*Creation) error
@@ -19,19 +35,21 @@ type AttestationCreationOption func(*Creation) error
 // NOTE: See https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis.
 func CreationNew(subject intoto.Subject, packageDesc intoto.PackageDescriptor,
 	options ...AttestationCreationOption) (*Creation, error) {
-	if err := subject.Validate(); err != nil {
+	header, err := intoto.NewStatement(statementType, predicateType, []intoto.Subject{subject})
+	if err != nil {
 		return nil, err
 	}
 	if err := packageDesc.Validate(); err != nil {
 		return nil, err
 	}
+	// NOTE: a subject recording an algorithm twice with
+	// conflicting values could never be verified.
+	if alg, conflicts := subject.Digests.ConflictingAlgorithm(); conflicts {
+		return nil, fmt.Errorf("%w: digest (%q) has conflicting values", errs.ErrorInvalidInput, alg)
+	}
 	att := Creation{
 		attestation: attestation{
-			Header: intoto.Header{
-				Type:          statementType,
-				PredicateType: predicateType,
-				Subjects:      []intoto.Subject{subject},
-			},
+			Header: header,
 			Predicate: predicate{
 				CreationTime: intoto.Now(),
 				Package:      packageDesc,
@@ -44,17 +62,52 @@ func CreationNew(subject intoto.Subject, packageDesc intoto.PackageDescriptor,
 			return nil, err
 		}
 	}
+	if att.telemetryEnabled {
+		if err := att.writeTelemetry(); err != nil {
+			return nil, err
+		}
+	}
+	if err := att.validateProperties(); err != nil {
+		return nil, err
+	}
 	return &att, nil
 }
 
+// ToBytes returns the JSON encoding of the attestation. The encoding
+// is deterministic: the same attestation is always encoded to the
+// same bytes.
 func (a *Creation) ToBytes() ([]byte, error) {
+	// NOTE: encoding/json sorts map keys, e.g. of the properties,
+	// and serializes struct fields in declaration order.
 	content, err := json.Marshal(a.attestation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal: %v", err)
+		return nil, fmt.Errorf("%w: failed to marshal: %v", errs.ErrorInternal, err)
 	}
 	return content, nil
 }
 
+// Digest returns the sha256 of the bytes returned by ToBytes(),
+// e.g. to name the attestation when it is stored.
+func (a *Creation) Digest() (intoto.DigestSet, error) {
+	content, err := a.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return intoto.DigestSet{
+		"sha256": hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Sign returns a DSSE envelope of the attestation signed by signer.
+func (a *Creation) Sign(ctx context.Context, signer intoto.AttestationSigner) ([]byte, error) {
+	content, err := a.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return intoto.SignStatement(ctx, signer, content)
+}
+
 func EnterSafeMode() AttestationCreationOption {
 	return func(a *Creation) error {
 		return a.enterSafeMode()
@@ -81,6 +134,86 @@ func (a *Creation) setPackageVersion(version string) error {
 	return nil
 }
 
+// WithPackageArch records the architecture of the package, e.g. amd64.
+func WithPackageArch(arch string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setPackageField("arch", &a.attestation.Predicate.Package.Arch, arch)
+	}
+}
+
+// WithPackageOS records the operating system of the package, e.g. linux.
+func WithPackageOS(os string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setPackageField("os", &a.attestation.Predicate.Package.OS, os)
+	}
+}
+
+// WithPackageMediaType records the media type of the package,
+// e.g. application/vnd.oci.image.manifest.v1+json.
+func WithPackageMediaType(mediaType string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setPackageField("media type", &a.attestation.Predicate.Package.MediaType, mediaType)
+	}
+}
+
+// setPackageField sets a field of the package descriptor. A field
+// set by the descriptor or by another option cannot be changed.
+func (a *Creation) setPackageField(name string, field *string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%w: package %s is empty", errs.ErrorInvalidInput, name)
+	}
+	if *field != "" && *field != value {
+		return fmt.Errorf("%w: package %s (%q) is already set to (%q)", errs.ErrorInvalidInput,
+			name, value, *field)
+	}
+	*field = value
+	return nil
+}
+
+// WithAuthor records the tool that created the attestation, e.g.
+// the policy evaluator, and its semantic version. Deployment policies
+// may require a minimum version, see IsAuthorVersionAtLeast().
+func WithAuthor(id, version string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withAuthor(id, version)
+	}
+}
+
+func (a *Creation) withAuthor(id, version string) error {
+	if id == "" {
+		return fmt.Errorf("%w: author ID is empty", errs.ErrorInvalidInput)
+	}
+	if _, err := semver.Parse(version); err != nil {
+		return fmt.Errorf("%w: author %w", errs.ErrorInvalidInput, err)
+	}
+	if a.attestation.Predicate.Author != nil {
+		return fmt.Errorf("%w: author is set more than once", errs.ErrorInvalidInput)
+	}
+	a.attestation.Predicate.Author = &author{
+		ID:      id,
+		Version: version,
+	}
+	return nil
+}
+
+// WithCreationTime sets the creation time of the attestation, which
+// defaults to the current time. It is recorded in UTC with a precision
+// of one second, so that attestations created with the same inputs
+// and creation time have the same content, see ToBytes().
+func WithCreationTime(t time.Time) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withCreationTime(t)
+	}
+}
+
+func (a *Creation) withCreationTime(t time.Time) error {
+	if t.IsZero() {
+		return fmt.Errorf("%w: creation time is zero", errs.ErrorInvalidInput)
+	}
+	a.attestation.Predicate.CreationTime = intoto.FormatTime(t)
+	return nil
+}
+
 func SetSlsaBuildLevel(level int) AttestationCreationOption {
 	return func(a *Creation) error {
 		return a.setSlsaBuildLevel(level)
@@ -104,6 +237,152 @@ func (a *Creation) setSlsaBuildLevel(level int) error {
 	return nil
 }
 
+func SetBaseImages(images ...string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setBaseImages(images)
+	}
+}
+
+func (a *Creation) setBaseImages(images []string) error {
+	if a.isSafeMode() {
+		return fmt.Errorf("%w: safe mode enabled, cannot edit base images", errs.ErrorInternal)
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("%w: base images are empty", errs.ErrorInvalidInput)
+	}
+	for _, image := range images {
+		if image == "" {
+			return fmt.Errorf("%w: base image is empty", errs.ErrorInvalidInput)
+		}
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[baseImagesProperty] = append([]string{}, images...)
+	return nil
+}
+
+// SetBuilder records the trusted builder and the source URI
+// the package's provenance was verified against.
+func SetBuilder(id, name, sourceURI string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.setBuilder(id, name, sourceURI)
+	}
+}
+
+func (a *Creation) setBuilder(id, name, sourceURI string) error {
+	if a.isSafeMode() {
+		return fmt.Errorf("%w: safe mode enabled, cannot edit builder", errs.ErrorInternal)
+	}
+	if id == "" {
+		return fmt.Errorf("%w: builder ID is empty", errs.ErrorInvalidInput)
+	}
+	if name == "" {
+		return fmt.Errorf("%w: builder name is empty", errs.ErrorInvalidInput)
+	}
+	if sourceURI == "" {
+		return fmt.Errorf("%w: source URI is empty", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[builderProperty] = builder{
+		ID:        id,
+		Name:      name,
+		SourceURI: sourceURI,
+	}
+	return nil
+}
+
+// WithSBOM records a reference to the SBOM of the package.
+// The media type is optional, e.g. "application/spdx+json".
+func WithSBOM(uri string, digests intoto.DigestSet, mediaType string) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withSBOM(uri, digests, mediaType)
+	}
+}
+
+func (a *Creation) withSBOM(uri string, digests intoto.DigestSet, mediaType string) error {
+	if uri == "" {
+		return fmt.Errorf("%w: SBOM URI is empty", errs.ErrorInvalidField)
+	}
+	if err := digests.Validate(); err != nil {
+		return fmt.Errorf("SBOM: %w", err)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[sbomProperty] = intoto.ResourceDescriptor{
+		URI:       uri,
+		Digest:    maps.Clone(digests),
+		MediaType: mediaType,
+	}
+	return nil
+}
+
+// WithAdditionalSubjects adds subjects to the attestation, e.g. the
+// platform manifests of a multi-arch image whose index digest is the
+// evaluated digest. Subjects identical to an existing one are ignored.
+func WithAdditionalSubjects(subjects []intoto.Subject) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withAdditionalSubjects(subjects)
+	}
+}
+
+func (a *Creation) withAdditionalSubjects(subjects []intoto.Subject) error {
+	if len(subjects) == 0 {
+		return fmt.Errorf("%w: additional subjects are empty", errs.ErrorInvalidInput)
+	}
+	for i := range subjects {
+		subject := &subjects[i]
+		if err := intoto.ValidateSubject(*subject); err != nil {
+			return fmt.Errorf("subject (%q): %w", subject.Name, err)
+		}
+		if slices.ContainsFunc(a.attestation.Header.Subjects, func(s intoto.Subject) bool {
+			return s.Name == subject.Name && maps.Equal(s.Digests, subject.Digests)
+		}) {
+			continue
+		}
+		a.attestation.Header.Subjects = append(a.attestation.Header.Subjects, intoto.Subject{
+			Name:    subject.Name,
+			Digests: maps.Clone(subject.Digests),
+		})
+	}
+	return nil
+}
+
+// WithTelemetryProperties records the policy evaluation's duration
+// and number of verifier calls in the attestation properties.
+func WithTelemetryProperties() AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withTelemetryProperties()
+	}
+}
+
+func (a *Creation) withTelemetryProperties() error {
+	a.telemetryEnabled = true
+	return nil
+}
+
+func setTelemetry(t telemetry) AttestationCreationOption {
+	return func(a *Creation) error {
+		a.telemetry = &t
+		return nil
+	}
+}
+
+func (a *Creation) writeTelemetry() error {
+	if a.telemetry == nil {
+		return fmt.Errorf("%w: no telemetry available", errs.ErrorInvalidInput)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[evaluationDurationProperty] = a.telemetry.duration.Milliseconds()
+	a.attestation.Predicate.Properties[verifierCallsProperty] = a.telemetry.verifierCalls
+	return nil
+}
+
 // Utility functions needed by cosign APIs.
 func (a *Creation) PredicateType() string {
 	return predicateType
@@ -1,7 +1,16 @@
 package publish
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -15,8 +24,8 @@ func Test_CreationNew(t *testing.T) {
 	t.Parallel()
 	subject := intoto.Subject{
 		Digests: intoto.DigestSet{
-			"sha256":    "some_value",
-			"gitCommit": "another_value",
+			"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
 		},
 	}
 	packageName := "package_name"
@@ -52,7 +61,7 @@ func Test_CreationNew(t *testing.T) {
 			name: "result with empty digest value",
 			subject: intoto.Subject{
 				Digests: intoto.DigestSet{
-					"sha256":    "some_value",
+					"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
 					"gitCommit": "",
 				},
 			},
@@ -63,7 +72,7 @@ func Test_CreationNew(t *testing.T) {
 			name: "result with empty digest key",
 			subject: intoto.Subject{
 				Digests: intoto.DigestSet{
-					"sha256": "some_value",
+					"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
 					"":       "another_value",
 				},
 			},
@@ -155,8 +164,8 @@ func Test_EnterSafeMode(t *testing.T) {
 	t.Parallel()
 	subject := intoto.Subject{
 		Digests: intoto.DigestSet{
-			"sha256":    "some_value",
-			"gitCommit": "another_value",
+			"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
 		},
 	}
 	packageName := "package_name"
@@ -232,3 +241,164 @@ func Test_EnterSafeMode(t *testing.T) {
 		})
 	}
 }
+
+// recordingSigner is a fake signer that records
+// the statement it is asked to sign.
+type recordingSigner struct {
+	statement []byte
+}
+
+func (s *recordingSigner) Sign(ctx context.Context, statement []byte) ([]byte, error) {
+	s.statement = statement
+	return json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString([]byte("signature"))}},
+	})
+}
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	registry := "registry"
+	packageName := "package_name"
+	att, err := CreationNew(intoto.Subject{Digests: digests},
+		intoto.PackageDescriptor{Name: packageName, Registry: registry})
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	var signer recordingSigner
+	envelope, err := att.Sign(context.Background(), &signer)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if diff := cmp.Diff(string(statement), string(signer.statement)); diff != "" {
+		t.Fatalf("unexpected signed statement (-want +got): \n%s", diff)
+	}
+	// The envelope is accepted by verification.
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(envelope)), newPackageHelper(registry))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	if err := verification.Verify(digests, packageName, AllowUnsignedDSSE()); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	_, err = att.Sign(context.Background(), nil)
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Digest(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
+	}
+	options := []AttestationCreationOption{
+		SetSlsaBuildLevel(3),
+		SetBaseImages("docker.io/library/alpine", "docker.io/library/debian"),
+		WithSBOM("sbom.json", intoto.DigestSet{"sha256": "abc", "sha1": "def"}, "application/spdx+json"),
+	}
+	for i := 0; i < 20; i++ {
+		options = append(options, WithProperty(fmt.Sprintf("example.com/key%d", i),
+			map[string]interface{}{"a": i, "b": "value", "c": []int{i, i + 1}}))
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests},
+		intoto.PackageDescriptor{Name: "package_name", Registry: "registry"}, options...)
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	sum := sha256.Sum256(statement)
+	expected := intoto.DigestSet{"sha256": hex.EncodeToString(sum[:])}
+	// NOTE: map iteration order is randomized, so repeated
+	// encodings would differ if the encoding depended on it.
+	for i := 0; i < 100; i++ {
+		content, err := att.ToBytes()
+		if err != nil {
+			t.Fatalf("failed to get attestation bytes: %v", err)
+		}
+		if !bytes.Equal(statement, content) {
+			t.Fatalf("unexpected content (-want +got): \n%s", cmp.Diff(string(statement), string(content)))
+		}
+		digest, err := att.Digest()
+		if err != nil {
+			t.Fatalf("failed to get attestation digest: %v", err)
+		}
+		if diff := cmp.Diff(expected, digest); diff != "" {
+			t.Fatalf("unexpected digest (-want +got): \n%s", diff)
+		}
+	}
+}
+
+func Test_WithCreationTime(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name         string
+		creationTime time.Time
+		value        string
+		expected     error
+	}{
+		{
+			name:         "utc time",
+			creationTime: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			value:        "2024-05-01T10:00:00Z",
+		},
+		{
+			name:         "time zone offset",
+			creationTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			value:        "2024-05-01T10:00:00Z",
+		},
+		{
+			name:         "fractional seconds",
+			creationTime: time.Date(2024, 5, 1, 10, 0, 0, 999999999, time.UTC),
+			value:        "2024-05-01T10:00:00Z",
+		},
+		{
+			name:     "zero time",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var contents [][]byte
+			// NOTE: attestations created with the same inputs must have the same content.
+			for i := 0; i < 2; i++ {
+				att, err := CreationNew(intoto.Subject{Digests: digests}, intoto.PackageDescriptor{Name: "package_name", Registry: "registry"},
+					WithCreationTime(tt.creationTime))
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if err != nil {
+					return
+				}
+				if diff := cmp.Diff(tt.value, att.attestation.Predicate.CreationTime); diff != "" {
+					t.Fatalf("unexpected creation time (-want +got): \n%s", diff)
+				}
+				content, err := att.ToBytes()
+				if err != nil {
+					t.Fatalf("failed to get attestation bytes: %v", err)
+				}
+				contents = append(contents, content)
+			}
+			if !bytes.Equal(contents[0], contents[1]) {
+				t.Fatalf("unexpected content (-want +got): \n%s", cmp.Diff(string(contents[0]), string(contents[1])))
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package publish
+
+// PackageRequirements describes the effective requirements of a
+// package, after combining the organization and project policies.
+type PackageRequirements struct {
+	Name         string   `json:"name"`
+	Environments []string `json:"environments,omitempty"`
+	// Builders contains the names of the accepted builders.
+	Builders []string `json:"builders"`
+	// RequiredLevel is the lowest SLSA build level of the accepted builders.
+	RequiredLevel int `json:"required_slsa_level"`
+	// Repositories contains the accepted source URIs.
+	Repositories []string `json:"repositories"`
+	// BaseImages, if set, contains the reference prefixes
+	// of the approved base images.
+	BaseImages []string `json:"base_images,omitempty"`
+	// MaxAgeDays, if set, is the maximum age in days of the provenance.
+	MaxAgeDays *int `json:"max_age_days,omitempty"`
+}
+
+// PackageNames returns the sorted names of the packages
+// the policy covers.
+func (p *Policy) PackageNames() []string {
+	return p.policy.PackageNames()
+}
+
+// PackageRequirements returns the requirements of a package. It returns
+// errs.ErrorNotFound if the package is not covered by the policy.
+// The returned value is a copy and may be modified by the caller.
+func (p *Policy) PackageRequirements(name string) (PackageRequirements, error) {
+	pkg, err := p.policy.Package(name)
+	if err != nil {
+		return PackageRequirements{}, err
+	}
+	return PackageRequirements{
+		Name:          pkg.Name,
+		Environments:  pkg.Environments,
+		Builders:      pkg.Builders,
+		RequiredLevel: pkg.RequiredLevel,
+		Repositories:  pkg.Repositories,
+		BaseImages:    pkg.BaseImages,
+		MaxAgeDays:    pkg.MaxAgeDays,
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/options"
@@ -52,14 +53,53 @@ func NewAttestationVerifier(digests intoto.DigestSet, packageName, builderID, so
 		digests: digests}
 }
 
+// Attestation verifier reporting base images.
+func NewAttestationVerifierWithBaseImages(digests intoto.DigestSet, packageName, builderID, sourceName string,
+	baseImages []string) options.AttestationVerifier {
+	return &attestationVerifier{packageName: packageName,
+		builderID: builderID, sourceName: sourceName,
+		digests: digests, baseImages: baseImages}
+}
+
+// Attestation verifier for a provenance of the given age.
+func NewAttestationVerifierWithAge(digests intoto.DigestSet, packageName, builderID, sourceName string,
+	age time.Duration) options.AttestationVerifier {
+	return &attestationVerifier{packageName: packageName,
+		builderID: builderID, sourceName: sourceName,
+		digests: digests, age: age}
+}
+
+// Attestation verifier for a provenance signed by the given
+// certificate identity.
+func NewAttestationVerifierWithIdentity(digests intoto.DigestSet, packageName, issuer, subject, sourceName string) options.AttestationVerifier {
+	return &attestationVerifier{packageName: packageName,
+		issuer: issuer, subject: subject, sourceName: sourceName,
+		digests: digests}
+}
+
 type attestationVerifier struct {
 	packageName string
 	builderID   string
+	issuer      string
+	subject     string
 	sourceName  string
 	digests     intoto.DigestSet
+	baseImages  []string
+	age         time.Duration
 }
 
-func (v *attestationVerifier) VerifyBuildAttestation(digests intoto.DigestSet, packageName, builderID, sourceName string) error {
+func (v *attestationVerifier) VerifyBuildAttestation(digests intoto.DigestSet, packageName, builderID string,
+	identity *options.Identity, sourceName string, maxAge time.Duration) error {
+	if maxAge > 0 && v.age > maxAge {
+		return fmt.Errorf("%w: provenance age (%v) exceeds (%v)", errs.ErrorVerification, v.age, maxAge)
+	}
+	if identity != nil {
+		if builderID != "" || identity.Issuer != v.issuer || !identity.SubjectRegex.MatchString(v.subject) {
+			return fmt.Errorf("%w: cannot verify package Name (%q) identity (%q, %q) source Name (%q) digests (%q)",
+				errs.ErrorVerification, packageName, identity.Issuer, identity.SubjectRegex, sourceName, digests)
+		}
+		builderID = v.builderID
+	}
 	if packageName == v.packageName && builderID == v.builderID && sourceName == v.sourceName && mapEq(digests, v.digests) {
 		return nil
 	}
@@ -67,6 +107,14 @@ func (v *attestationVerifier) VerifyBuildAttestation(digests intoto.DigestSet, p
 		errs.ErrorVerification, packageName, builderID, sourceName, digests)
 }
 
+func (v *attestationVerifier) BaseImages(digests intoto.DigestSet, packageName string) ([]string, error) {
+	if packageName == v.packageName && mapEq(digests, v.digests) {
+		return v.baseImages, nil
+	}
+	return nil, fmt.Errorf("%w: cannot get base images for package Name (%q) digests (%q)",
+		errs.ErrorVerification, packageName, digests)
+}
+
 func mapEq(m1, m2 map[string]string) bool {
 	if len(m1) != len(m2) {
 		return false
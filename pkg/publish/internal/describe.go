@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// PackageDescription describes the requirements of a package, after
+// combining the organization and project policies.
+type PackageDescription struct {
+	Name          string
+	Environments  []string
+	Builders      []string
+	RequiredLevel int
+	Repositories  []string
+	BaseImages    []string
+	MaxAgeDays    *int
+}
+
+// PackageNames returns the sorted names of the packages
+// of the project policies.
+func (p *Policy) PackageNames() []string {
+	names := make([]string, 0, len(p.projectPolicies))
+	for name := range p.projectPolicies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Package returns the description of a package. The required level
+// is the lowest SLSA level of the builders the package accepts.
+func (p *Policy) Package(name string) (*PackageDescription, error) {
+	projectPolicy, exists := p.projectPolicies[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: package's name (%q) not present in project policies", errs.ErrorNotFound, name)
+	}
+	builders := projectPolicy.BuildRequirements.BuilderNames()
+	level := -1
+	for _, builder := range builders {
+		if builderLevel := p.orgPolicy.BuilderSlsaLevel(builder); level == -1 || builderLevel < level {
+			level = builderLevel
+		}
+	}
+	desc := PackageDescription{
+		Name:          projectPolicy.Package.Name,
+		Environments:  slices.Clone(projectPolicy.Package.Environment.AnyOf),
+		Builders:      builders,
+		RequiredLevel: level,
+		Repositories:  projectPolicy.BuildRequirements.Repository.URIs(),
+	}
+	if projectPolicy.BuildRequirements.BaseImages != nil {
+		desc.BaseImages = slices.Clone(projectPolicy.BuildRequirements.BaseImages.AnyOf)
+	}
+	if projectPolicy.BuildRequirements.MaxAgeDays != nil {
+		days := *projectPolicy.BuildRequirements.MaxAgeDays
+		desc.MaxAgeDays = &days
+	}
+	return &desc, nil
+}
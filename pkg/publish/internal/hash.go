@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/project"
+)
+
+// effectivePolicy is the canonical serialization of a policy.
+// NOTE: encoding/json sorts map keys and serializes struct fields
+// in declaration order, so the output does not depend on the
+// formatting of the files or the order they are read in.
+type effectivePolicy struct {
+	Version  int                       `json:"version"`
+	Org      organization.Policy       `json:"org"`
+	Projects map[string]project.Policy `json:"projects"`
+}
+
+// EffectiveHash returns the hex-encoded sha256 of the
+// canonical serialization of the parsed policy.
+func (p *Policy) EffectiveHash() (string, error) {
+	content, err := json.Marshal(effectivePolicy{
+		Version:  1,
+		Org:      p.orgPolicy,
+		Projects: p.projectPolicies,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to marshal: %v", errs.ErrorInternal, err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
+)
+
+func Test_EffectiveHash(t *testing.T) {
+	t.Parallel()
+	org := `{"format":1,"roots":{"build":[{"id":"builder_id","name":"builder_name","slsa_level":3}]}}`
+	project1 := `{"format":1,"package":{"name":"package_name1"},"build":{"require_slsa_builder":"builder_name","repository":{"uri":"repo_uri1"}}}`
+	project2 := `{"format":1,"package":{"name":"package_name2"},"build":{"require_slsa_builder":"builder_name","repository":{"uri":"repo_uri2"}}}`
+	tests := []struct {
+		name     string
+		org      string
+		projects []string
+		same     bool
+	}{
+		{
+			name:     "identical policy",
+			org:      org,
+			projects: []string{project1, project2},
+			same:     true,
+		},
+		{
+			name: "whitespace and key order",
+			org: `{
+				"roots": {"build": [{"slsa_level": 3, "name": "builder_name", "id": "builder_id"}]},
+				"format": 1
+			}`,
+			projects: []string{
+				`{"build":{"repository":{"uri":"repo_uri1"},"require_slsa_builder":"builder_name"},"package":{"name":"package_name1"},"format":1}`,
+				project2,
+			},
+			same: true,
+		},
+		{
+			name:     "file order",
+			org:      org,
+			projects: []string{project2, project1},
+			same:     true,
+		},
+		{
+			name:     "level change",
+			org:      `{"format":1,"roots":{"build":[{"id":"builder_id","name":"builder_name","slsa_level":2}]}}`,
+			projects: []string{project1, project2},
+		},
+		{
+			name: "repository change",
+			org:  org,
+			projects: []string{
+				project1,
+				`{"format":1,"package":{"name":"package_name2"},"build":{"require_slsa_builder":"builder_name","repository":{"uri":"repo_uri3"}}}`,
+			},
+		},
+		{
+			name:     "project removed",
+			org:      org,
+			projects: []string{project1},
+		},
+	}
+	expected := effectiveHash(t, org, []string{project1, project2})
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hash := effectiveHash(t, tt.org, tt.projects)
+			if diff := cmp.Diff(tt.same, hash == expected); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func effectiveHash(t *testing.T, org string, projects []string) string {
+	contents := make([][]byte, len(projects))
+	for i := range projects {
+		contents[i] = []byte(projects[i])
+	}
+	orgReader := io.NopCloser(bytes.NewReader([]byte(org)))
+	policy, err := PolicyNew(orgReader, common.NewBytesIterator(contents), nil)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	hash, err := policy.EffectiveHash()
+	if err != nil {
+		t.Fatalf("failed to compute hash: %v", err)
+	}
+	return hash
+}
@@ -1,19 +1,63 @@
 package options
 
 import (
+	"context"
+	"log/slog"
+	"regexp"
+	"time"
+
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/logging"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/retry"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
+// Identity is the certificate identity of a trusted root.
+type Identity struct {
+	Issuer       string
+	SubjectRegex *regexp.Regexp
+}
+
 // AttestationVerifier defines an interface to verify attestations.
 type AttestationVerifier interface {
-	// Build attestations.
-	VerifyBuildAttestation(digests intoto.DigestSet, publishName, builderID, sourceName string) error
+	// Build attestations. A non-zero maxAge is the maximum
+	// age of the provenance. The builder is identified either by
+	// builderID or, if builderID is empty, by identity.
+	VerifyBuildAttestation(digests intoto.DigestSet, publishName, builderID string, identity *Identity,
+		sourceName string, maxAge time.Duration) error
+	// Base images the package was built from, extracted from
+	// the provenance or an SBOM attestation.
+	BaseImages(digests intoto.DigestSet, publishName string) ([]string, error)
 }
 
 // BuildVerification defines the configuration to verify
 // build attestations.
 type BuildVerification struct {
 	Verifier AttestationVerifier
+	// Logger, if set, receives the records of the evaluation.
+	Logger *slog.Logger
+	// Trace, if set, records the candidates considered
+	// by the evaluation.
+	Trace *trace.Trace
+	// Retry, if set, retries the verifier calls that
+	// fail with a transient error.
+	Retry *retry.Policy
+	// Context, if set, interrupts the waits between retries.
+	Context context.Context
+}
+
+// Log returns the logger of the evaluation.
+func (v BuildVerification) Log() *slog.Logger {
+	return logging.OrDiscard(v.Logger)
+}
+
+// Ctx returns the context of the evaluation.
+func (v BuildVerification) Ctx() context.Context {
+	if v.Context == nil {
+		return context.Background()
+	}
+	return v.Context
 }
 
 // Request is metadata about the caller request.
@@ -39,3 +83,94 @@ type ValidationEnvironment struct {
 type PolicyValidator interface {
 	ValidatePackage(pkg ValidationPackage) error
 }
+
+// ParseOption defines an option to parse policy files.
+type ParseOption func(*Parse)
+
+// Parse defines how policy files are parsed.
+type Parse struct {
+	// AllowUnknownFields accepts fields that are not
+	// defined by the policy format.
+	AllowUnknownFields bool
+	// Logger, if set, receives the records of the parsing.
+	Logger *slog.Logger
+	// Workers, if set, is the maximum number of
+	// policy files parsed concurrently.
+	Workers int
+	// MaxSize, if set, is the maximum size of
+	// a policy file, see limit.DefaultMaxSize.
+	MaxSize int64
+	// MaxPolicies, if set, is the maximum number of
+	// policy files, see limit.DefaultMaxPolicies.
+	MaxPolicies int
+}
+
+// Log returns the logger of the parsing.
+func (p Parse) Log() *slog.Logger {
+	return logging.OrDiscard(p.Logger)
+}
+
+// Concurrency returns the maximum number of policy files parsed
+// concurrently. It defaults to 1, so that the validator is not
+// called concurrently unless the caller opts in, see WithWorkers().
+func (p Parse) Concurrency() int {
+	if p.Workers > 0 {
+		return p.Workers
+	}
+	return 1
+}
+
+// PolicyLimit returns the maximum number of policy
+// files. It defaults to limit.DefaultMaxPolicies.
+func (p Parse) PolicyLimit() int {
+	if p.MaxPolicies > 0 {
+		return p.MaxPolicies
+	}
+	return limit.DefaultMaxPolicies
+}
+
+// AllowUnknownFields accepts fields that are not
+// defined by the policy format.
+func AllowUnknownFields() ParseOption {
+	return func(p *Parse) {
+		p.AllowUnknownFields = true
+	}
+}
+
+// WithLogger sets the logger of the parsing.
+func WithLogger(logger *slog.Logger) ParseOption {
+	return func(p *Parse) {
+		p.Logger = logger
+	}
+}
+
+// WithWorkers sets the maximum number of
+// policy files parsed concurrently.
+func WithWorkers(workers int) ParseOption {
+	return func(p *Parse) {
+		p.Workers = workers
+	}
+}
+
+// WithMaxSize sets the maximum size of a policy file.
+func WithMaxSize(size int64) ParseOption {
+	return func(p *Parse) {
+		p.MaxSize = size
+	}
+}
+
+// WithMaxPolicies sets the maximum number of policy files.
+func WithMaxPolicies(count int) ParseOption {
+	return func(p *Parse) {
+		p.MaxPolicies = count
+	}
+}
+
+// ParseNew creates the parse configuration from the options.
+func ParseNew(opts ...ParseOption) Parse {
+	var p Parse
+	for _, option := range opts {
+		option(&p)
+	}
+	return p
+}
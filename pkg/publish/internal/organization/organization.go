@@ -1,24 +1,53 @@
 package organization
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"path"
+	"regexp"
+	"slices"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/yaml"
 )
 
-// Root defines a trusted root.
+// Root defines a trusted root. It is identified either by
+// its ID or by its certificate identity, but not both.
 type Root struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	SlsaLevel *int   `json:"slsa_level"`
-	// TODO: list of repositories the builder is allowed to attest to:
-	// example: GitHub can attest to github.com/* only, GCB can attest to github.com/*
-	// gitlab.com/*, etc.
+	ID        string    `json:"id"`
+	Identity  *Identity `json:"identity,omitempty"`
+	Name      string    `json:"name"`
+	SlsaLevel *int      `json:"slsa_level"`
+	// SourceURIs, if set, contains the source repositories the builder
+	// may attest to, e.g. github.com/org/* so that project policies cannot
+	// trust the provenance of a fork. Patterns use the syntax of
+	// path.Match(), so * does not match /. Project policies can only
+	// narrow the repositories further: a repository of a project policy
+	// is verified with the builder only if it matches one of the patterns.
+	SourceURIs []string `json:"source_uris,omitempty"`
+}
+
+// Identity defines the certificate identity of a root
+// for keyless signing, e.g. a reusable workflow.
+type Identity struct {
+	// Issuer is the OIDC issuer of the certificate.
+	Issuer string `json:"issuer"`
+	// SubjectRegex matches the subject alternative name of the certificate.
+	SubjectRegex string `json:"subject_regex"`
+	// subject is SubjectRegex compiled by validate(),
+	// anchored to match the whole subject.
+	subject *regexp.Regexp
+}
+
+// Subject returns the compiled subject regex. It is anchored,
+// so the verifiers may match it against the subject as is.
+func (i *Identity) Subject() *regexp.Regexp {
+	return i.subject
 }
 
 // Roots defines a set of truted roots.
@@ -26,37 +55,81 @@ type Roots struct {
 	Build []Root `json:"build"`
 }
 
+// Defaults defines the default requirements of the project
+// policies. The value of a project policy, if set, takes precedence.
+type Defaults struct {
+	Build DefaultBuild `json:"build"`
+}
+
+// DefaultBuild defines the default build requirements.
+type DefaultBuild struct {
+	// RequireSlsaBuilder is the name of the builder required
+	// by project policies that do not define any builder.
+	RequireSlsaBuilder string `json:"require_slsa_builder"`
+}
+
 // Policy defines the policy.
 type Policy struct {
-	Format int   `json:"format"`
-	Roots  Roots `json:"roots"`
+	Format   int       `json:"format"`
+	Roots    Roots     `json:"roots"`
+	Defaults *Defaults `json:"defaults,omitempty"`
 }
 
 // FromReader creates a new instance of a Policy from an IO reader.
-func FromReader(reader io.ReadCloser) (*Policy, error) {
+func FromReader(reader io.ReadCloser, parseOpts ...options.ParseOption) (*Policy, error) {
+	parse := options.ParseNew(parseOpts...)
 	// NOTE: see https://yourbasic.org/golang/io-reader-interface-explained.
-	content, err := ioutil.ReadAll(reader)
+	content, err := limit.ReadAll(reader, parse.MaxSize)
 	if err != nil {
-		return nil, fmt.Errorf("[organization] failed to read: %w", err)
+		return nil, fmt.Errorf("[organization] %w", err)
 	}
 	defer reader.Close()
 	var org Policy
-	if err := json.Unmarshal(content, &org); err != nil {
-		return nil, fmt.Errorf("[organization] failed to unmarshal: %w", err)
+	if err := policySchema.Validate(content, parse.AllowUnknownFields); err != nil {
+		return nil, schema.WrapViolations(fmt.Sprintf("[organization] failed to validate (%s)", readerName(reader)), err)
+	}
+	if err := yaml.Unmarshal(content, &org, parse.AllowUnknownFields); err != nil {
+		return nil, fmt.Errorf("[organization] failed to unmarshal (%s): %w", readerName(reader), err)
 	}
 	if err := org.validate(); err != nil {
 		return nil, err
 	}
+	parse.Log().Debug("policy file parsed", "policy_id", readerName(reader), "roots", len(org.Roots.Build))
 	return &org, nil
 }
 
+// readerName returns the name of the file the policy is read from, if available.
+func readerName(reader io.ReadCloser) string {
+	if named, ok := reader.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return "organization policy"
+}
+
 // validate validates the format of the policy.
 func (p *Policy) validate() error {
-	if err := p.validateFormat(); err != nil {
+	if err := errors.Join(p.validateFormat(), p.validateBuildRoots()); err != nil {
 		return err
 	}
-	if err := p.validateBuildRoots(); err != nil {
-		return err
+	// NOTE: defaults are validated against valid roots only.
+	return p.validateDefaults()
+}
+
+func (p *Policy) validateDefaults() error {
+	if p.Defaults == nil {
+		return nil
+	}
+	// The default builder must be set and
+	// must be one of the builders.
+	pointer := schema.Pointer("defaults", "build", "require_slsa_builder")
+	name := p.Defaults.Build.RequireSlsaBuilder
+	if name == "" {
+		return fmt.Errorf("[organization] %w: %q: defaults' require_slsa_builder is not defined", errs.ErrorInvalidField,
+			pointer)
+	}
+	if names := p.RootBuilderNames(); !slices.Contains(names, name) {
+		return fmt.Errorf("[organization] %w: %q: defaults' require_slsa_builder has unexpected value (%q). Must be one of %q",
+			errs.ErrorInvalidField, pointer, name, names)
 	}
 	return nil
 }
@@ -64,7 +137,8 @@ func (p *Policy) validate() error {
 func (p *Policy) validateFormat() error {
 	// Format must be 1.
 	if p.Format != 1 {
-		return fmt.Errorf("[organization] %w: invalid format (%q). Must be 1", errs.ErrorInvalidField, p.Format)
+		return fmt.Errorf("[organization] %w: %q: invalid format (%q). Must be 1", errs.ErrorInvalidField,
+			schema.Pointer("format"), p.Format)
 	}
 	return nil
 }
@@ -72,44 +146,142 @@ func (p *Policy) validateFormat() error {
 func (p *Policy) validateBuildRoots() error {
 	// There must be at least one build root.
 	if len(p.Roots.Build) == 0 {
-		return fmt.Errorf("[organization] %w: build's roots are not defined", errs.ErrorInvalidField)
+		return fmt.Errorf("[organization] %w: %q: build's roots are not defined", errs.ErrorInvalidField,
+			schema.Pointer("roots", "build"))
 	}
 	// Each root must have all its fields defined.
 	// Also validate that
 	//  1) the names given to builders are unique
 	//  2) the ids do not repeat
+	// NOTE: errors are accumulated so that all invalid roots are reported.
+	//  3) the identities do not repeat
 	names := make(map[string]bool)
 	ids := make(map[string]bool)
+	identities := make(map[Identity]bool)
+	var allErrs []error
 	for i := range p.Roots.Build {
-		build := &p.Roots.Build[i]
+		if err := p.Roots.Build[i].validate(schema.Pointer("roots", "build", i), names, ids, identities); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return errors.Join(allErrs...)
+}
+
+// validate validates the root at pointer, see schema.Pointer().
+func (r *Root) validate(pointer string, names, ids map[string]bool, identities map[Identity]bool) error {
+	// Exactly one of ID and identity must be defined.
+	if r.ID != "" && r.Identity != nil {
+		return fmt.Errorf("[organization] %w: %q: build's id (%q) and identity are mutually exclusive",
+			errs.ErrorInvalidField, pointer, r.ID)
+	}
+	if r.Identity != nil {
+		if err := r.Identity.validate(pointer+"/identity", identities); err != nil {
+			return err
+		}
+	} else {
 		// ID must be defined and non-empty.
-		if build.ID == "" {
-			return fmt.Errorf("[organization] %w: build's id is empty", errs.ErrorInvalidField)
+		if r.ID == "" {
+			return fmt.Errorf("[organization] %w: %q: build's id is empty", errs.ErrorInvalidField, pointer+"/id")
 		}
 		// ID must be unique.
-		if _, exists := ids[build.ID]; exists {
-			return fmt.Errorf("[organization] %w: build's name (%q) is defined more than once", errs.ErrorInvalidField, build.ID)
+		if _, exists := ids[r.ID]; exists {
+			return fmt.Errorf("[organization] %w: %q: build's name (%q) is defined more than once", errs.ErrorInvalidField,
+				pointer+"/id", r.ID)
 		}
-		ids[build.ID] = true
-		// Name must be defined and non-empty.
-		if build.Name == "" {
-			return fmt.Errorf("[organization] %w: build's name is empty", errs.ErrorInvalidField)
+		ids[r.ID] = true
+	}
+	// Name must be defined and non-empty.
+	if r.Name == "" {
+		return fmt.Errorf("[organization] %w: %q: build's name is empty", errs.ErrorInvalidField, pointer+"/name")
+	}
+	// Name must be unique.
+	if _, exists := names[r.Name]; exists {
+		return fmt.Errorf("[organization] %w: %q: build's name (%q) is defined more than once", errs.ErrorInvalidField,
+			pointer+"/name", r.Name)
+	}
+	names[r.Name] = true
+	// Level must be defined.
+	if r.SlsaLevel == nil {
+		return fmt.Errorf("[organization] %w: %q: build's slsa_level is not defined", errs.ErrorInvalidField,
+			pointer+"/slsa_level")
+	}
+	// Level must be in the corre range.
+	if *r.SlsaLevel < 0 || *r.SlsaLevel > 4 {
+		return fmt.Errorf("[organization] %w: %q: build's slsa_level is invalid (%d). Must satisfy 0 <= slsa_level <= 4",
+			errs.ErrorInvalidField, pointer+"/slsa_level", *r.SlsaLevel)
+	}
+	return r.validateSourceURIs(pointer + "/source_uris")
+}
+
+func (r *Root) validateSourceURIs(pointer string) error {
+	// Source URIs, if set, must be valid unique patterns.
+	if r.SourceURIs == nil {
+		return nil
+	}
+	if len(r.SourceURIs) == 0 {
+		return fmt.Errorf("[organization] %w: %q: build's source_uris is empty for root (%q)", errs.ErrorInvalidField,
+			pointer, r.Name)
+	}
+	seen := make(map[string]bool)
+	for i, pattern := range r.SourceURIs {
+		patternPointer := fmt.Sprintf("%s/%d", pointer, i)
+		if pattern == "" {
+			return fmt.Errorf("[organization] %w: %q: build's source_uris has an empty field for root (%q)",
+				errs.ErrorInvalidField, patternPointer, r.Name)
 		}
-		// Name must be unique.
-		if _, exists := names[build.Name]; exists {
-			return fmt.Errorf("[organization] %w: build's name (%q) is defined more than once", errs.ErrorInvalidField, build.Name)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("[organization] %w: %q: build's source_uris (%q) is invalid for root (%q): %v",
+				errs.ErrorInvalidField, patternPointer, pattern, r.Name, err)
 		}
-		names[build.Name] = true
-		// Level must be defined.
-		if build.SlsaLevel == nil {
-			return fmt.Errorf("[organization] %w: build's slsa_level is not defined", errs.ErrorInvalidField)
+		if seen[pattern] {
+			return fmt.Errorf("[organization] %w: %q: build's source_uris contains (%q) more than once for root (%q)",
+				errs.ErrorInvalidField, patternPointer, pattern, r.Name)
 		}
-		// Level must be in the corre range.
-		if *build.SlsaLevel < 0 || *build.SlsaLevel > 4 {
-			return fmt.Errorf("[organization] %w: build's slsa_level is invalid (%d). Must satisfy 0 <= slsa_level <= 4",
-				errs.ErrorInvalidField, *build.SlsaLevel)
+		seen[pattern] = true
+	}
+	return nil
+}
+
+// allowsSource returns true if the root may attest to sourceURI.
+func (r *Root) allowsSource(sourceURI string) bool {
+	if r.SourceURIs == nil {
+		return true
+	}
+	for _, pattern := range r.SourceURIs {
+		// NOTE: patterns are validated by validateSourceURIs().
+		if matched, _ := path.Match(pattern, sourceURI); matched {
+			return true
 		}
 	}
+	return false
+}
+
+// validate validates the identity at pointer, see schema.Pointer().
+func (i *Identity) validate(pointer string, identities map[Identity]bool) error {
+	// Issuer and subject must be defined and non-empty.
+	if i.Issuer == "" {
+		return fmt.Errorf("[organization] %w: %q: build's identity issuer is empty", errs.ErrorInvalidField,
+			pointer+"/issuer")
+	}
+	if i.SubjectRegex == "" {
+		return fmt.Errorf("[organization] %w: %q: build's identity subject_regex is empty", errs.ErrorInvalidField,
+			pointer+"/subject_regex")
+	}
+	// Identity must be unique.
+	key := Identity{Issuer: i.Issuer, SubjectRegex: i.SubjectRegex}
+	if _, exists := identities[key]; exists {
+		return fmt.Errorf("[organization] %w: %q: build's identity (%q, %q) is defined more than once",
+			errs.ErrorInvalidField, pointer, i.Issuer, i.SubjectRegex)
+	}
+	identities[key] = true
+	// Subject must be a valid regex. It must match the whole subject,
+	// even if the policy omits the anchors.
+	subject, err := regexp.Compile("^(?:" + i.SubjectRegex + ")$")
+	if err != nil {
+		return fmt.Errorf("[organization] %w: %q: build's identity subject_regex (%q) is invalid: %v",
+			errs.ErrorInvalidField, pointer+"/subject_regex", i.SubjectRegex, err)
+	}
+	i.subject = subject
 	return nil
 }
 
@@ -123,6 +295,15 @@ func (p *Policy) RootBuilderNames() []string {
 	return names
 }
 
+// DefaultBuilder returns the name of the builder required by project
+// policies that do not define any builder, or an empty string.
+func (p *Policy) DefaultBuilder() string {
+	if p.Defaults == nil {
+		return ""
+	}
+	return p.Defaults.Build.RequireSlsaBuilder
+}
+
 func (p *Policy) BuilderID(builderName string) (string, error) {
 	for i := range p.Roots.Build {
 		builder := &p.Roots.Build[i]
@@ -133,6 +314,42 @@ func (p *Policy) BuilderID(builderName string) (string, error) {
 	return "", fmt.Errorf("[organization] %w: builder ID (%q) is not defined", errs.ErrorMismatch, builderName)
 }
 
+// BuilderIdentity returns the certificate identity of the builder,
+// or nil if the builder is identified by its ID.
+func (p *Policy) BuilderIdentity(builderName string) *Identity {
+	for i := range p.Roots.Build {
+		builder := &p.Roots.Build[i]
+		if builderName == builder.Name {
+			return builder.Identity
+		}
+	}
+	return nil
+}
+
+// BuilderSourceURIs returns the source repository patterns the
+// builder may attest to, or nil if the builder is not restricted.
+func (p *Policy) BuilderSourceURIs(builderName string) []string {
+	for i := range p.Roots.Build {
+		builder := &p.Roots.Build[i]
+		if builderName == builder.Name {
+			return builder.SourceURIs
+		}
+	}
+	return nil
+}
+
+// BuilderAllowsSource returns true if the builder may attest to
+// sourceURI, i.e. if sourceURI matches one of its source_uris.
+func (p *Policy) BuilderAllowsSource(builderName, sourceURI string) bool {
+	for i := range p.Roots.Build {
+		builder := &p.Roots.Build[i]
+		if builderName == builder.Name {
+			return builder.allowsSource(sourceURI)
+		}
+	}
+	return false
+}
+
 func (p *Policy) BuilderSlsaLevel(builderName string) int {
 	for i := range p.Roots.Build {
 		builder := &p.Roots.Build[i]
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"math/rand"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -217,9 +218,10 @@ func Test_validateBuildRoots(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name     string
-		policy   *Policy
-		expected error
+		name       string
+		policy     *Policy
+		violations int
+		expected   error
 	}{
 		{
 			name:     "empty roots",
@@ -371,6 +373,226 @@ func Test_validateBuildRoots(t *testing.T) {
 			},
 			expected: errs.ErrorInvalidField,
 		},
+		{
+			name: "one root with valid identity",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v.*$",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "one root with id and identity",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							ID:   "builder id",
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v.*$",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with identity empty issuer",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "",
+								SubjectRegex: "^https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v.*$",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with identity empty subject regex",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with identity invalid subject regex",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^(invalid$",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "two roots with same identity",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							Name: "the name",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v.*$",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+						{
+							Name: "the name2",
+							Identity: &Identity{
+								Issuer:       "https://token.actions.githubusercontent.com",
+								SubjectRegex: "^https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v.*$",
+							},
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with source uris",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							ID:         "builder id",
+							Name:       "the name",
+							SlsaLevel:  common.AsPointer(3),
+							SourceURIs: []string{"github.com/org/*", "gitlab.com/org/repo"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "one root with empty source uris",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							ID:         "builder id",
+							Name:       "the name",
+							SlsaLevel:  common.AsPointer(3),
+							SourceURIs: []string{},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with empty source uri",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							ID:         "builder id",
+							Name:       "the name",
+							SlsaLevel:  common.AsPointer(3),
+							SourceURIs: []string{"github.com/org/*", ""},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with invalid source uri",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							ID:         "builder id",
+							Name:       "the name",
+							SlsaLevel:  common.AsPointer(3),
+							SourceURIs: []string{"github.com/org/[a-"},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "one root with same source uris",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							ID:         "builder id",
+							Name:       "the name",
+							SlsaLevel:  common.AsPointer(3),
+							SourceURIs: []string{"github.com/org/*", "github.com/org/*"},
+						},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "multiple invalid roots",
+			policy: &Policy{
+				Roots: Roots{
+					Build: []Root{
+						{
+							Name:      "the name",
+							SlsaLevel: common.AsPointer(3),
+						},
+						{
+							ID:        "builder id",
+							Name:      "the name2",
+							SlsaLevel: common.AsPointer(5),
+						},
+						{
+							ID:        "builder id2",
+							Name:      "the name3",
+							SlsaLevel: common.AsPointer(3),
+						},
+					},
+				},
+			},
+			violations: 2,
+			expected:   errs.ErrorInvalidField,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -381,6 +603,150 @@ func Test_validateBuildRoots(t *testing.T) {
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if tt.violations == 0 {
+				return
+			}
+			if diff := cmp.Diff(tt.violations, len(errs.Violations(err))); diff != "" {
+				t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_BuilderAllowsSource(t *testing.T) {
+	t.Parallel()
+	policy := Policy{
+		Roots: Roots{
+			Build: []Root{
+				{
+					ID:        "builder_id1",
+					Name:      "builder_name1",
+					SlsaLevel: common.AsPointer(3),
+				},
+				{
+					ID:         "builder_id2",
+					Name:       "builder_name2",
+					SlsaLevel:  common.AsPointer(3),
+					SourceURIs: []string{"github.com/org/*", "gitlab.com/org/repo"},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name      string
+		builder   string
+		sourceURI string
+		allowed   bool
+	}{
+		{
+			name:      "unrestricted builder",
+			builder:   "builder_name1",
+			sourceURI: "github.com/fork/repo",
+			allowed:   true,
+		},
+		{
+			name:      "glob match",
+			builder:   "builder_name2",
+			sourceURI: "github.com/org/repo",
+			allowed:   true,
+		},
+		{
+			name:      "exact match",
+			builder:   "builder_name2",
+			sourceURI: "gitlab.com/org/repo",
+			allowed:   true,
+		},
+		{
+			name:      "fork",
+			builder:   "builder_name2",
+			sourceURI: "github.com/fork/repo",
+		},
+		{
+			name:      "glob does not match separator",
+			builder:   "builder_name2",
+			sourceURI: "github.com/org/repo/sub",
+		},
+		{
+			name:      "unknown builder",
+			builder:   "builder_name3",
+			sourceURI: "github.com/org/repo",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.allowed, policy.BuilderAllowsSource(tt.builder, tt.sourceURI)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_validateDefaults(t *testing.T) {
+	t.Parallel()
+
+	roots := Roots{
+		Build: []Root{
+			{
+				ID:        "builder id",
+				Name:      "the name",
+				SlsaLevel: common.AsPointer(3),
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		policy   *Policy
+		expected error
+	}{
+		{
+			name: "no defaults",
+			policy: &Policy{
+				Roots: roots,
+			},
+		},
+		{
+			name: "default builder",
+			policy: &Policy{
+				Roots: roots,
+				Defaults: &Defaults{
+					Build: DefaultBuild{
+						RequireSlsaBuilder: "the name",
+					},
+				},
+			},
+		},
+		{
+			name: "empty default builder",
+			policy: &Policy{
+				Roots:    roots,
+				Defaults: &Defaults{},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "unknown default builder",
+			policy: &Policy{
+				Roots: roots,
+				Defaults: &Defaults{
+					Build: DefaultBuild{
+						RequireSlsaBuilder: "other name",
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.policy.validateDefaults()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
@@ -628,3 +994,80 @@ func Test_Evaluate(t *testing.T) {
 		})
 	}
 }
+
+func FuzzFromReader(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		content, err := json.Marshal(randomPolicy(r))
+		if err != nil {
+			f.Fatalf("failed to marshal: %v", err)
+		}
+		f.Add(content)
+	}
+	f.Add([]byte("format: 1\nroots:\n  build:\n    - id: builder_id\n      name: builder_name\n      slsa_level: 3\n"))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		policy, err := FromReader(io.NopCloser(bytes.NewReader(content)))
+		if err != nil {
+			return
+		}
+		for _, name := range policy.RootBuilderNames() {
+			_, _ = policy.BuilderID(name)
+			_ = policy.BuilderIdentity(name)
+			_ = policy.BuilderSlsaLevel(name)
+		}
+		_ = policy.DefaultBuilder()
+	})
+}
+
+func Test_IdentitySubject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		regex    string
+		subject  string
+		expected bool
+	}{
+		{
+			name:     "match",
+			regex:    "https://github.com/org/repo/.*@refs/heads/main",
+			subject:  "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+			expected: true,
+		},
+		{
+			name:     "anchored match",
+			regex:    "^https://github.com/org/repo/.*@refs/heads/main$",
+			subject:  "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+			expected: true,
+		},
+		{
+			name:    "prefix",
+			regex:   "https://github.com/org/repo/.github/workflows/release.yml",
+			subject: "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+		},
+		{
+			name:    "suffix",
+			regex:   "https://github.com/org/repo/.*",
+			subject: "https://evil.com/https://github.com/org/repo/release.yml",
+		},
+		{
+			name:    "alternation",
+			regex:   "https://github.com/org/repo/.*|https://github.com/org/other/.*",
+			subject: "https://evil.com/https://github.com/org/other/release.yml",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			identity := Identity{Issuer: "https://token.actions.githubusercontent.com", SubjectRegex: tt.regex}
+			if err := identity.validate("/identity", make(map[Identity]bool)); err != nil {
+				t.Fatalf("failed to validate: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, identity.Subject().MatchString(tt.subject)); diff != "" {
+				t.Fatalf("unexpected match (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package organization
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+)
+
+// randomPolicy returns a random valid policy.
+func randomPolicy(r *rand.Rand) Policy {
+	policy := Policy{Format: 1}
+	n := 1 + r.Intn(4)
+	for i := 0; i < n; i++ {
+		root := Root{
+			Name:      fmt.Sprintf("builder_name%d", i),
+			SlsaLevel: common.AsPointer(r.Intn(5)),
+		}
+		if r.Intn(2) == 0 {
+			root.ID = fmt.Sprintf("builder_id%d", i)
+		} else {
+			root.Identity = &Identity{
+				Issuer:       "https://token.actions.githubusercontent.com",
+				SubjectRegex: fmt.Sprintf("^https://github.com/org/repo%d/", i),
+			}
+		}
+		if r.Intn(3) == 0 {
+			root.SourceURIs = []string{"github.com/org/*", fmt.Sprintf("gitlab.com/org/repo%d", i)}
+		}
+		policy.Roots.Build = append(policy.Roots.Build, root)
+	}
+	if r.Intn(2) == 0 {
+		policy.Defaults = &Defaults{
+			Build: DefaultBuild{
+				RequireSlsaBuilder: policy.Roots.Build[r.Intn(n)].Name,
+			},
+		}
+	}
+	return policy
+}
+
+func Test_SchemaAgreement(t *testing.T) {
+	t.Parallel()
+	if _, err := schema.Compile(Schema()); err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		content, err := json.Marshal(randomPolicy(r))
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if err := policySchema.Validate(content, false); err != nil {
+			t.Fatalf("schema rejects policy (%s): %v", content, err)
+		}
+		if _, err := FromReader(io.NopCloser(bytes.NewReader(content))); err != nil {
+			t.Fatalf("failed to create policy (%s): %v", content, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/project"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 type Policy struct {
@@ -17,12 +18,12 @@ type Policy struct {
 	projectPolicies map[string]project.Policy
 }
 
-func PolicyNew(org io.ReadCloser, projects iterator.ReadCloserIterator, validator options.PolicyValidator) (*Policy, error) {
-	orgPolicy, err := organization.FromReader(org)
+func PolicyNew(org io.ReadCloser, projects iterator.ReadCloserIterator, validator options.PolicyValidator, parseOpts ...options.ParseOption) (*Policy, error) {
+	orgPolicy, err := organization.FromReader(org, parseOpts...)
 	if err != nil {
 		return nil, err
 	}
-	projectPolicies, err := project.FromReaders(projects, *orgPolicy, validator)
+	projectPolicies, err := project.FromReaders(projects, *orgPolicy, validator, parseOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -32,30 +33,37 @@ func PolicyNew(org io.ReadCloser, projects iterator.ReadCloserIterator, validato
 	}, nil
 }
 
-func (p *Policy) Evaluate(digests intoto.DigestSet, packageName string, reqOpts options.Request, buildOpts options.BuildVerification) (int, error) {
+func (p *Policy) Evaluate(digests intoto.DigestSet, packageName string, reqOpts options.Request, buildOpts options.BuildVerification) (*project.Result, error) {
 	if packageName == "" {
-		return -1, fmt.Errorf("%w: package name is empty", errs.ErrorInvalidInput)
+		return nil, fmt.Errorf("%w: package name is empty", errs.ErrorInvalidInput)
+	}
+	// NOTE: validate the digests before they are passed to the verifier.
+	if err := digests.Validate(); err != nil {
+		return nil, err
 	}
 	return p.evaluateBuildPolicy(digests, packageName, reqOpts, buildOpts)
 }
 
-func (p *Policy) evaluateBuildPolicy(digests intoto.DigestSet, packageName string, reqOpts options.Request, buildOpts options.BuildVerification) (int, error) {
+func (p *Policy) evaluateBuildPolicy(digests intoto.DigestSet, packageName string, reqOpts options.Request, buildOpts options.BuildVerification) (*project.Result, error) {
 	// Get the project policy for the artifact.
 	projectPolicy, exists := p.projectPolicies[packageName]
 	if !exists {
-		return -1, fmt.Errorf("%w: package's name (%q) not present in project policies", errs.ErrorNotFound, packageName)
+		err := fmt.Errorf("%w: package's name (%q) not present in project policies", errs.ErrorNotFound, packageName)
+		buildOpts.Trace.Record(trace.Step{Package: packageName, Outcome: trace.OutcomeRejected,
+			Reason: trace.ReasonPackageNotFound}, err)
+		return nil, err
 	}
 
 	// Evaluate the org policy.
 	err := p.orgPolicy.Evaluate(digests, packageName, reqOpts, buildOpts)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
 	// Evaluate the project policy.
-	level, err := projectPolicy.Evaluate(digests, packageName, p.orgPolicy, reqOpts, buildOpts)
+	result, err := projectPolicy.Evaluate(digests, packageName, p.orgPolicy, reqOpts, buildOpts)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
-	return level, nil
+	return result, nil
 }
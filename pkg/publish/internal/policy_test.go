@@ -624,14 +624,14 @@ func Test_Evaluate(t *testing.T) {
 			req := options.Request{
 				Environment: tt.verifierOpts.environment,
 			}
-			level, err := policy.Evaluate(tt.verifierOpts.digests, tt.packageName, req, opts)
+			result, err := policy.Evaluate(tt.verifierOpts.digests, tt.packageName, req, opts)
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 			if err != nil {
 				return
 			}
-			if diff := cmp.Diff(tt.level, level); diff != "" {
+			if diff := cmp.Diff(tt.level, result.Level); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 		})
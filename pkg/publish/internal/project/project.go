@@ -1,32 +1,109 @@
 package project
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/assertions"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/extends"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/parallel"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/yaml"
 )
 
-// Repository defines the repository.
+// Repository defines the source repository.
+// Exactly one of URI and AnyOf must be set.
 type Repository struct {
 	URI string `json:"uri"`
+	// AnyOf contains the accepted source URIs, e.g.
+	// the old and new URIs of a renamed repository.
+	AnyOf []string `json:"any_of,omitempty"`
+}
+
+// URIs returns the accepted source URIs.
+func (r *Repository) URIs() []string {
+	if r.AnyOf != nil {
+		return append([]string{}, r.AnyOf...) // NOTE: Make a copy of the array.
+	}
+	if r.URI == "" {
+		return nil
+	}
+	return []string{r.URI}
+}
+
+// BaseImages defines the approved base images.
+type BaseImages struct {
+	// AnyOf contains the reference prefixes of the approved base images.
+	AnyOf []string `json:"any_of,omitempty"`
+}
+
+// SlsaBuilders defines the accepted builders.
+type SlsaBuilders struct {
+	// AnyOf contains the names of the accepted builders,
+	// as defined in the organization-level policy.
+	AnyOf []string `json:"any_of,omitempty"`
 }
 
 // BuildRequirements defines the build requirements.
+// Exactly one of RequireSlsaBuilder and RequireSlsaBuilders must be set.
 type BuildRequirements struct {
-	RequireSlsaBuilder string     `json:"require_slsa_builder"`
-	Repository         Repository `json:"repository"`
+	RequireSlsaBuilder  string        `json:"require_slsa_builder"`
+	RequireSlsaBuilders *SlsaBuilders `json:"require_slsa_builders,omitempty"`
+	Repository          Repository    `json:"repository"`
+	BaseImages          *BaseImages   `json:"base_images,omitempty"`
+	// MaxAgeDays, if set, is the maximum age in days of the provenance.
+	MaxAgeDays *int `json:"max_age_days,omitempty"`
+}
+
+// BuilderNames returns the names of the accepted builders.
+func (b *BuildRequirements) BuilderNames() []string {
+	if b.RequireSlsaBuilders != nil {
+		return append([]string{}, b.RequireSlsaBuilders.AnyOf...) // NOTE: Make a copy of the array.
+	}
+	if b.RequireSlsaBuilder == "" {
+		return nil
+	}
+	return []string{b.RequireSlsaBuilder}
+}
+
+// MaxAge returns the maximum age of the provenance.
+// It is zero if the policy does not require one.
+func (b *BuildRequirements) MaxAge() time.Duration {
+	if b.MaxAgeDays == nil {
+		return 0
+	}
+	return time.Duration(*b.MaxAgeDays) * 24 * time.Hour
+}
+
+// Result defines the result of a successful evaluation.
+type Result struct {
+	Level int
+	// BuilderID and BuilderName identify the trusted builder
+	// whose build attestation was verified.
+	BuilderID   string
+	BuilderName string
+	// SourceURI is the source repository URI that was verified.
+	SourceURI string
+	// BaseImages contains the approved base images
+	// the package was built from, if the policy requires them.
+	BaseImages []string
 }
 
 // Environment defines the target environment.
 type Environment struct {
+	// AnyOf contains the accepted environments, which
+	// may be wildcards such as "prod/*".
 	AnyOf []string `json:"any_of,omitempty"`
 }
 
@@ -42,37 +119,62 @@ type Policy struct {
 	Format            int                     `json:"format"`
 	Package           Package                 `json:"package"`
 	BuildRequirements BuildRequirements       `json:"build"`
+	Assertions        []assertions.Assertion  `json:"assertions,omitempty"`
 	validator         options.PolicyValidator `json:"-"`
 }
 
-func fromReader(reader io.ReadCloser, builderNames []string, validator options.PolicyValidator) (*Policy, error) {
+// readPolicy reads the content of a policy file.
+func readPolicy(reader io.ReadCloser, parse options.Parse) ([]byte, error) {
 	// NOTE: see https://yourbasic.org/golang/io-reader-interface-explained.
-	content, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("[projects] failed to read: %w", err)
-	}
 	defer reader.Close()
+	return limit.ReadAll(reader, parse.MaxSize)
+}
+
+// fromContent creates a policy from the content of a policy
+// file, with the files it extends merged in, see extends.Resolve.
+func fromContent(content []byte, builderNames []string, defaultBuilder string, validator options.PolicyValidator,
+	parse options.Parse) (*Policy, error) {
 	var project Policy
-	if err := json.Unmarshal(content, &project); err != nil {
+	if err := policySchema.Validate(content, parse.AllowUnknownFields); err != nil {
+		return nil, schema.WrapViolations("[projects] failed to validate", err)
+	}
+	if err := yaml.Unmarshal(content, &project, parse.AllowUnknownFields); err != nil {
 		return nil, fmt.Errorf("[projects] failed to unmarshal: %w", err)
 	}
 	project.validator = validator
+	project.applyDefaults(defaultBuilder)
 	if err := project.validate(builderNames); err != nil {
 		return nil, err
 	}
 	return &project, nil
 }
 
+// applyDefaults sets the builder to the default builder of the
+// organization policy, if the policy does not define any builder.
+func (p *Policy) applyDefaults(defaultBuilder string) {
+	b := &p.BuildRequirements
+	if b.RequireSlsaBuilder == "" && b.RequireSlsaBuilders == nil {
+		b.RequireSlsaBuilder = defaultBuilder
+	}
+}
+
 // validate validates the format of the policy.
+// All independent checks are run so that every violation is reported.
 func (p *Policy) validate(builderNames []string) error {
-	if err := p.validateFormat(); err != nil {
+	if err := errors.Join(p.validateFormat(), p.validatePackage(),
+		p.validateBuildRequirements(builderNames)); err != nil {
 		return err
 	}
-	if err := p.validatePackage(); err != nil {
+	// NOTE: assertions must be evaluated last, on the validated policy.
+	if err := p.validateAssertions(); err != nil {
 		return err
 	}
-	if err := p.validateBuildRequirements(builderNames); err != nil {
-		return err
+	return nil
+}
+
+func (p *Policy) validateAssertions() error {
+	if err := assertions.Evaluate(p.Assertions, p); err != nil {
+		return fmt.Errorf("[projects] %w", err)
 	}
 	return nil
 }
@@ -80,7 +182,8 @@ func (p *Policy) validate(builderNames []string) error {
 func (p *Policy) validateFormat() error {
 	// Format must be 1.
 	if p.Format != 1 {
-		return fmt.Errorf("[projects] %w: invalid format (%q). Must be 1", errs.ErrorInvalidField, p.Format)
+		return fmt.Errorf("[projects] %w: %q: invalid format (%q). Must be 1", errs.ErrorInvalidField,
+			schema.Pointer("format"), p.Format)
 	}
 	return nil
 }
@@ -88,13 +191,13 @@ func (p *Policy) validateFormat() error {
 func (p *Policy) validatePackage() error {
 	// Package must have a non-empty Name.
 	if p.Package.Name == "" {
-		return fmt.Errorf("[projects] %w: package's name is empty", errs.ErrorInvalidField)
+		return fmt.Errorf("[projects] %w: %q: package's name is empty", errs.ErrorInvalidField,
+			schema.Pointer("package", "name"))
 	}
-	// Environment field, if set, must contain non-empty values.
-	for i := range p.Package.Environment.AnyOf {
-		val := &p.Package.Environment.AnyOf[i]
-		if *val == "" {
-			return fmt.Errorf("[projects] %w: package's any_of value has an empty field", errs.ErrorInvalidField)
+	// Environment field, if set, must contain valid values.
+	for i, env := range p.Package.Environment.AnyOf {
+		if err := validateEnvironment(schema.Pointer("package", "environment", "any_of", i), env); err != nil {
+			return err
 		}
 	}
 	// Validate the package using the custom validator.
@@ -106,7 +209,8 @@ func (p *Policy) validatePackage() error {
 			},
 		}
 		if err := p.validator.ValidatePackage(pkg); err != nil {
-			return fmt.Errorf("%w: failed to validate package: %w", errs.ErrorInvalidField, err)
+			return fmt.Errorf("%w: %q: failed to validate package: %w", errs.ErrorInvalidField,
+				schema.Pointer("package"), err)
 		}
 	}
 	return nil
@@ -114,95 +218,422 @@ func (p *Policy) validatePackage() error {
 
 func (p *Policy) validateBuildRequirements(builderNames []string) error {
 	// SLSA builder
-	//	1) must be set
-	//	2) must contain one the builders configured by the organization-level policy
-	//	3) must contain a repository URI.
+	//	1) must be set, either as a single builder or as a list of builders
+	//	2) must contain builders configured by the organization-level policy
+	//	3) must contain a repository URI, either as a single URI or as a list of URIs.
 	if len(builderNames) == 0 {
 		return fmt.Errorf("[projects] %w: builder names are empty", errs.ErrorInvalidInput)
 	}
-	if p.BuildRequirements.RequireSlsaBuilder == "" {
-		return fmt.Errorf("[projects] %w: build's require_slsa_builder is not defined", errs.ErrorInvalidField)
+	if p.BuildRequirements.RequireSlsaBuilder != "" && p.BuildRequirements.RequireSlsaBuilders != nil {
+		return fmt.Errorf("[projects] %w: %q: build's require_slsa_builder and require_slsa_builders are both defined",
+			errs.ErrorInvalidField, schema.Pointer("build"))
+	}
+	if p.BuildRequirements.RequireSlsaBuilders != nil {
+		if len(p.BuildRequirements.RequireSlsaBuilders.AnyOf) == 0 {
+			return fmt.Errorf("[projects] %w: %q: build's require_slsa_builders is empty", errs.ErrorInvalidField,
+				schema.Pointer("build", "require_slsa_builders", "any_of"))
+		}
+		seen := make(map[string]bool)
+		for i, name := range p.BuildRequirements.RequireSlsaBuilders.AnyOf {
+			pointer := schema.Pointer("build", "require_slsa_builders", "any_of", i)
+			if !slices.Contains(builderNames, name) {
+				return fmt.Errorf("[projects] %w: %q: build's require_slsa_builders has unexpected value (%q). Must be one of %q",
+					errs.ErrorInvalidField, pointer, name, builderNames)
+			}
+			if seen[name] {
+				return fmt.Errorf("[projects] %w: %q: build's require_slsa_builders contains (%q) more than once",
+					errs.ErrorInvalidField, pointer, name)
+			}
+			seen[name] = true
+		}
+	} else {
+		pointer := schema.Pointer("build", "require_slsa_builder")
+		if p.BuildRequirements.RequireSlsaBuilder == "" {
+			return fmt.Errorf("[projects] %w: %q: build's require_slsa_builder is not defined", errs.ErrorInvalidField,
+				pointer)
+		}
+		if !slices.Contains(builderNames, p.BuildRequirements.RequireSlsaBuilder) {
+			return fmt.Errorf("[projects] %w: %q: build's require_slsa_builder has unexpected value (%q). Must be one of %q",
+				errs.ErrorInvalidField, pointer, p.BuildRequirements.RequireSlsaBuilder, builderNames)
+		}
+	}
+	if err := p.BuildRequirements.Repository.validate(schema.Pointer("build", "repository")); err != nil {
+		return err
 	}
-	if !slices.Contains(builderNames, p.BuildRequirements.RequireSlsaBuilder) {
-		return fmt.Errorf("[projects] %w: build's require_slsa_builder has unexpected value (%q). Must be one of %q",
-			errs.ErrorInvalidField, p.BuildRequirements.RequireSlsaBuilder, builderNames)
+	// Base images, if set, must contain non-empty values.
+	if p.BuildRequirements.BaseImages != nil {
+		if len(p.BuildRequirements.BaseImages.AnyOf) == 0 {
+			return fmt.Errorf("[projects] %w: %q: build's base_images is empty", errs.ErrorInvalidField,
+				schema.Pointer("build", "base_images", "any_of"))
+		}
+		for i, prefix := range p.BuildRequirements.BaseImages.AnyOf {
+			if prefix == "" {
+				return fmt.Errorf("[projects] %w: %q: build's base_images has an empty field", errs.ErrorInvalidField,
+					schema.Pointer("build", "base_images", "any_of", i))
+			}
+		}
 	}
-	if p.BuildRequirements.Repository.URI == "" {
-		return fmt.Errorf("[projects] %w: build's repository URI is not defined", errs.ErrorInvalidField)
+	// Max age, if set, must be positive.
+	if p.BuildRequirements.MaxAgeDays != nil && *p.BuildRequirements.MaxAgeDays <= 0 {
+		return fmt.Errorf("[projects] %w: %q: build's max_age_days (%d) must be positive",
+			errs.ErrorInvalidField, schema.Pointer("build", "max_age_days"), *p.BuildRequirements.MaxAgeDays)
 	}
 	return nil
 }
 
+// validate validates the repository at pointer, see schema.Pointer().
+func (r *Repository) validate(pointer string) error {
+	if r.URI != "" && r.AnyOf != nil {
+		return fmt.Errorf("[projects] %w: %q: build's repository URI and any_of are both defined", errs.ErrorInvalidField,
+			pointer)
+	}
+	if r.AnyOf == nil {
+		if r.URI == "" {
+			return fmt.Errorf("[projects] %w: %q: build's repository URI is not defined", errs.ErrorInvalidField,
+				pointer+"/uri")
+		}
+		return nil
+	}
+	if len(r.AnyOf) == 0 {
+		return fmt.Errorf("[projects] %w: %q: build's repository any_of is empty", errs.ErrorInvalidField,
+			pointer+"/any_of")
+	}
+	seen := make(map[string]bool)
+	for i, uri := range r.AnyOf {
+		if uri == "" {
+			return fmt.Errorf("[projects] %w: %q: build's repository any_of has an empty field", errs.ErrorInvalidField,
+				fmt.Sprintf("%s/any_of/%d", pointer, i))
+		}
+		if seen[uri] {
+			return fmt.Errorf("[projects] %w: %q: build's repository any_of contains (%q) more than once",
+				errs.ErrorInvalidField, fmt.Sprintf("%s/any_of/%d", pointer, i), uri)
+		}
+		seen[uri] = true
+	}
+	return nil
+}
+
+// validateSourceURIs validates that each builder may attest to at least
+// one of the repositories, see organization.Root.SourceURIs.
+func (p *Policy) validateSourceURIs(orgPolicy organization.Policy) error {
+	sourceURIs := p.BuildRequirements.Repository.URIs()
+	for _, builderName := range p.BuildRequirements.BuilderNames() {
+		if !slices.ContainsFunc(sourceURIs, func(sourceURI string) bool {
+			return orgPolicy.BuilderAllowsSource(builderName, sourceURI)
+		}) {
+			return fmt.Errorf("[projects] %w: %q: build's repository (%q) is not allowed for builder (%q). Must match one of %q",
+				errs.ErrorInvalidField, schema.Pointer("build", "repository"), sourceURIs, builderName,
+				orgPolicy.BuilderSourceURIs(builderName))
+		}
+	}
+	return nil
+}
+
+// parsedPolicy is the result of parsing a policy file.
+type parsedPolicy struct {
+	id string
+	// label identifies the policy file and the files
+	// it extends, used to annotate errors.
+	label  string
+	policy *Policy
+	err    error
+}
+
+// readAll reads the policy files of readers. The iterator is consumed
+// by the calling goroutine. It stops reading once there are more policy
+// files than the limit, see options.WithMaxPolicies().
+func readAll(readers iterator.ReadCloserIterator, parse options.Parse) ([]extends.Document, error) {
+	var docs []extends.Document
+	for i := 0; readers.HasNext(); i++ {
+		if i == parse.PolicyLimit() {
+			return nil, fmt.Errorf("[projects] %w: more policy files than the maximum number of policy files (%d)",
+				errs.ErrorInvalidInput, parse.PolicyLimit())
+		}
+		reader := readers.Next()
+		// NOTE: the iterator returns a nil reader on error,
+		// which is reported by readers.Error().
+		if reader == nil {
+			break
+		}
+		doc := extends.Document{ID: readerID(reader, i)}
+		doc.Content, doc.Err = readPolicy(reader, parse)
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// parseAll parses the resolved policy files, with up to workers files
+// parsed concurrently. The results are in the order of the files, so
+// that they do not depend on scheduling.
+func parseAll(files []extends.Resolved, workers int,
+	parse func(content []byte) (*Policy, error)) []parsedPolicy {
+	results := make([]parsedPolicy, len(files))
+	// NOTE: each call writes distinct results.
+	parallel.ForEach(len(files), workers, func(i int) {
+		results[i].id, results[i].label = files[i].ID, files[i].Label()
+		if files[i].Err != nil {
+			results[i].err = fmt.Errorf("[projects] %w", files[i].Err)
+			return
+		}
+		results[i].policy, results[i].err = parse(files[i].Content)
+	})
+	return results
+}
+
 // FromReaders creates a set of policies keyed by their package Name (and if present, the environment).
-func FromReaders(readers iterator.ReadCloserIterator, orgPolicy organization.Policy, validator options.PolicyValidator) (map[string]Policy, error) {
+// A policy file may extend a base file, see extends.Resolve. Base files are not policies
+// by themselves. The policy files may be parsed concurrently, see options.WithWorkers(),
+// in which case the validator must be safe for concurrent use.
+func FromReaders(readers iterator.ReadCloserIterator, orgPolicy organization.Policy, validator options.PolicyValidator, parseOpts ...options.ParseOption) (map[string]Policy, error) {
+	parse := options.ParseNew(parseOpts...)
 	policies := make(map[string]Policy)
-	for readers.HasNext() {
-		reader := readers.Next()
-		// NOTE: fromReader() calls validates that the builder used are consistent
+	builderNames := orgPolicy.RootBuilderNames()
+	defaultBuilder := orgPolicy.DefaultBuilder()
+	docs, err := readAll(readers, parse)
+	if err != nil {
+		return nil, err
+	}
+	files := extends.Resolve(docs)
+	results := parseAll(files, parse.Concurrency(), func(content []byte) (*Policy, error) {
+		// NOTE: fromContent() calls validates that the builder used are consistent
 		// with the org policy.
-		policy, err := fromReader(reader, orgPolicy.RootBuilderNames(), validator)
+		policy, err := fromContent(content, builderNames, defaultBuilder, validator, parse)
 		if err != nil {
 			return nil, err
 		}
+		if err := policy.validateSourceURIs(orgPolicy); err != nil {
+			return nil, err
+		}
+		return policy, nil
+	})
+	// NOTE: errors are accumulated so that all invalid policies are reported.
+	var allErrs []error
+	for _, result := range results {
+		id, policy := result.id, result.policy
+		if result.err != nil {
+			allErrs = append(allErrs, annotate(result.label, result.err)...)
+			continue
+		}
 		// TODO: Re-visit what we consider unique. It maye require some tweaks to support
 		// different environments in different files.
 		// If we want to support multiple files, they should all have the environment defined or none
 		// should.
 		name := policy.Package.Name
 		if _, exists := policies[name]; exists {
-			return nil, fmt.Errorf("[projects] %w: package's name (%q) is defined more than once", errs.ErrorInvalidField, name)
+			allErrs = append(allErrs, fmt.Errorf("%s: [projects] %w: package's name (%q) is defined more than once",
+				id, errs.ErrorInvalidField, name))
+			continue
 		}
 		policies[name] = *policy
-
+		parse.Log().Debug("policy file parsed", "policy_id", id, "package", name)
 	}
 	//TODO: add test for this.
 	if readers.Error() != nil {
-		return nil, fmt.Errorf("[projects] failed to read policy: %w", readers.Error())
+		return nil, fmt.Errorf("[projects] %w: failed to read policy: %w", errs.ErrorInvalidInput, readers.Error())
+	}
+	if len(allErrs) > 0 {
+		return nil, errors.Join(allErrs...)
 	}
 	return policies, nil
 }
 
+// annotate prefixes each violation in err with the policy identifier.
+func annotate(id string, err error) []error {
+	var list []error
+	for _, e := range errs.Violations(err) {
+		list = append(list, fmt.Errorf("%s: %w", id, e))
+	}
+	return list
+}
+
+// readerID returns an identifier for the policy read by reader, used to
+// annotate errors. Readers that are files are identified by their name.
+func readerID(reader io.ReadCloser, index int) string {
+	if named, ok := reader.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("policy #%d", index)
+}
+
 // Evaluate evaluates the policy.
 func (p *Policy) Evaluate(digests intoto.DigestSet, packageName string,
-	orgPolicy organization.Policy, reqOpts options.Request, buildOpts options.BuildVerification) (int, error) {
+	orgPolicy organization.Policy, reqOpts options.Request, buildOpts options.BuildVerification) (*Result, error) {
 	if buildOpts.Verifier == nil {
-		return -1, fmt.Errorf("[projects] %w: verifier is empty", errs.ErrorInvalidInput)
+		return nil, fmt.Errorf("[projects] %w: verifier is empty", errs.ErrorInvalidInput)
+	}
+	if err := p.verifyEnvironment(packageName, reqOpts, buildOpts); err != nil {
+		buildOpts.Trace.Record(trace.Step{Package: packageName, Environments: p.Package.Environment.AnyOf,
+			Outcome: trace.OutcomeRejected, Reason: trace.ReasonEnvironmentMismatch}, err)
+		return nil, err
+	}
+	// Validate digests.
+	if err := digests.Validate(); err != nil {
+		return nil, err
+	}
+	buildOpts.Log().Debug("package matched", "package", packageName, "environments", p.Package.Environment.AnyOf)
+	// Verify build attestations.
+	builder, err := p.verifyBuilder(digests, packageName, orgPolicy, buildOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the base images.
+	baseImages, err := p.verifyBaseImages(digests, packageName, buildOpts)
+	if err != nil {
+		buildOpts.Trace.Record(trace.Step{Package: packageName, RootID: builder.name,
+			Outcome: trace.OutcomeRejected, Reason: trace.ReasonBaseImageNotApproved}, err)
+		return nil, err
 	}
+	buildOpts.Trace.Record(trace.Step{Package: packageName, RootID: builder.name,
+		Outcome: trace.OutcomeSelected, Reason: trace.ReasonVerified}, nil)
+	return &Result{
+		Level:       orgPolicy.BuilderSlsaLevel(builder.name),
+		BuilderID:   builder.id,
+		BuilderName: builder.name,
+		SourceURI:   builder.sourceURI,
+		BaseImages:  baseImages,
+	}, nil
+}
+
+// verifyEnvironment verifies that the environment of the
+// request is one of the environments of the policy.
+func (p *Policy) verifyEnvironment(packageName string, reqOpts options.Request, buildOpts options.BuildVerification) error {
 	// If the policy has environment defined, the request must contain an environment.
 	if len(p.Package.Environment.AnyOf) > 0 && (reqOpts.Environment == nil || *reqOpts.Environment == "") {
-		return -1, fmt.Errorf("[projects] %w: build config's environment is empty but the policy has it defined (%q)",
+		return fmt.Errorf("[projects] %w: build config's environment is empty but the policy has it defined (%q)",
 			errs.ErrorInvalidInput, p.Package.Environment.AnyOf)
 	}
 	// If the policy has no environment defined, the request must not contain an environment.
 	if len(p.Package.Environment.AnyOf) == 0 && reqOpts.Environment != nil {
-		return -1, fmt.Errorf("[projects] %w: build config's environment is set (%q) but the policy has none defined",
+		return fmt.Errorf("[projects] %w: build config's environment is set (%q) but the policy has none defined",
 			errs.ErrorInvalidInput, *reqOpts.Environment)
 	}
 	// Verify the environment and request match.
 	if reqOpts.Environment != nil {
 		if *reqOpts.Environment == "" {
-			return -1, fmt.Errorf("[projects] %w: build config's environment is empty", errs.ErrorInvalidInput)
+			return fmt.Errorf("[projects] %w: build config's environment is empty", errs.ErrorInvalidInput)
 		}
-		if !slices.Contains(p.Package.Environment.AnyOf, *reqOpts.Environment) {
-			return -1, fmt.Errorf("[projects] %w: failed to verify artifact (%q) for environment (%q): not defined in policy",
+		entry, ok := matchEnvironments(p.Package.Environment.AnyOf, *reqOpts.Environment)
+		if !ok {
+			return fmt.Errorf("[projects] %w: failed to verify artifact (%q) for environment (%q): not defined in policy",
 				errs.ErrorNotFound, packageName, *reqOpts.Environment)
 		}
+		buildOpts.Log().Debug("environment matched", "environment", *reqOpts.Environment, "entry", entry)
 	}
-	// Validate digests.
-	if err := digests.Validate(); err != nil {
-		return -1, err
+	return nil
+}
+
+// matchedBuilder is the builder and source URI a build attestation
+// was verified against.
+type matchedBuilder struct {
+	id        string
+	name      string
+	sourceURI string
+}
+
+// verifyBuilder verifies the build attestation against each accepted
+// builder and source URI, in the order they are listed in the policy.
+// It returns the first builder and source URI that verify.
+func (p *Policy) verifyBuilder(digests intoto.DigestSet, packageName string,
+	orgPolicy organization.Policy, buildOpts options.BuildVerification) (*matchedBuilder, error) {
+	var errList []error
+	logger := buildOpts.Log()
+	sourceURIs := p.BuildRequirements.Repository.URIs()
+	maxAge := p.BuildRequirements.MaxAge()
+	for _, builderName := range p.BuildRequirements.BuilderNames() {
+		builderID, err := orgPolicy.BuilderID(builderName)
+		if err != nil {
+			return nil, err
+		}
+		identity := builderIdentity(orgPolicy, builderName)
+		logger.Debug("root considered", "root", builderName, "builder_id", builderID,
+			"level", orgPolicy.BuilderSlsaLevel(builderName))
+		for _, sourceURI := range sourceURIs {
+			// NOTE: the organization policy takes precedence: the
+			// builder is never trusted for other repositories.
+			if !orgPolicy.BuilderAllowsSource(builderName, sourceURI) {
+				logger.Debug("source URI not allowed", "root", builderName, "source_uri", sourceURI)
+				err := fmt.Errorf("builder (%q -> %q) source URI (%q): not allowed (%q)",
+					builderName, builderID, sourceURI, orgPolicy.BuilderSourceURIs(builderName))
+				buildOpts.Trace.Record(trace.Step{Package: packageName, RootID: builderName,
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonRootNotAllowed}, err)
+				errList = append(errList, err)
+				continue
+			}
+			logger.Debug("verifier invoked", "package", packageName, "environments", p.Package.Environment.AnyOf,
+				"builder_id", builderID, "source_uri", sourceURI, "max_age", maxAge)
+			err = buildOpts.Retry.Do(buildOpts.Ctx(), func() error {
+				return buildOpts.Verifier.VerifyBuildAttestation(digests, packageName, builderID, identity, sourceURI, maxAge)
+			})
+			if err == nil {
+				logger.Debug("verifier result", "builder_id", builderID, "source_uri", sourceURI)
+				return &matchedBuilder{
+					id:        builderID,
+					name:      builderName,
+					sourceURI: sourceURI,
+				}, nil
+			}
+			logger.Debug("verifier result", "builder_id", builderID, "source_uri", sourceURI, "error", err)
+			err = fmt.Errorf("builder (%q -> %q) source URI (%q): %w", builderName, builderID, sourceURI, err)
+			buildOpts.Trace.Record(trace.Step{Package: packageName, RootID: builderName,
+				Outcome: trace.OutcomeRejected, Reason: trace.ReasonVerifierFailure}, err)
+			errList = append(errList, err)
+		}
 	}
-	// Verify build attestations.
-	builderID, err := orgPolicy.BuilderID(p.BuildRequirements.RequireSlsaBuilder)
-	if err != nil {
-		return -1, err
+	return nil, fmt.Errorf("[projects] %w: failed to verify artifact (%q) with builders (%q) source URIs (%q) digests (%q): %w",
+		errs.ErrorVerification, packageName, p.BuildRequirements.BuilderNames(),
+		sourceURIs, digests, errors.Join(errList...))
+}
+
+// builderIdentity returns the certificate identity of the builder,
+// or nil if the builder is identified by its ID.
+func builderIdentity(orgPolicy organization.Policy, builderName string) *options.Identity {
+	identity := orgPolicy.BuilderIdentity(builderName)
+	if identity == nil {
+		return nil
+	}
+	return &options.Identity{
+		Issuer:       identity.Issuer,
+		SubjectRegex: identity.Subject(),
+	}
+}
+
+func (p *Policy) verifyBaseImages(digests intoto.DigestSet, packageName string, buildOpts options.BuildVerification) ([]string, error) {
+	if p.BuildRequirements.BaseImages == nil {
+		return nil, nil
 	}
-	err = buildOpts.Verifier.VerifyBuildAttestation(digests, packageName, builderID, p.BuildRequirements.Repository.URI)
+	logger := buildOpts.Log()
+	logger.Debug("verifier invoked", "package", packageName, "approved_base_images", p.BuildRequirements.BaseImages.AnyOf)
+	var baseImages []string
+	err := buildOpts.Retry.Do(buildOpts.Ctx(), func() error {
+		var err error
+		baseImages, err = buildOpts.Verifier.BaseImages(digests, packageName)
+		return err
+	})
 	if err != nil {
-		return -1, fmt.Errorf("[projects] %w: failed to verify artifact (%q) with builder (%q -> %q) source URI (%q) digests (%q): %w",
-			errs.ErrorVerification, packageName, p.BuildRequirements.RequireSlsaBuilder, builderID,
-			p.BuildRequirements.Repository.URI, digests, err)
+		logger.Debug("verifier result", "error", err)
+		return nil, fmt.Errorf("[projects] %w: failed to get base images for artifact (%q): %w",
+			errs.ErrorVerification, packageName, err)
+	}
+	logger.Debug("verifier result", "base_images", baseImages)
+	if len(baseImages) == 0 {
+		return nil, fmt.Errorf("[projects] %w: unknown base image for artifact (%q)", errs.ErrorVerification, packageName)
+	}
+	// Every base image must be approved.
+	for _, baseImage := range baseImages {
+		if !hasPrefix(baseImage, p.BuildRequirements.BaseImages.AnyOf) {
+			return nil, fmt.Errorf("[projects] %w: base image (%q) of artifact (%q) is not approved (%q)",
+				errs.ErrorVerification, baseImage, packageName, p.BuildRequirements.BaseImages.AnyOf)
+		}
 	}
+	return baseImages, nil
+}
 
-	return orgPolicy.BuilderSlsaLevel(p.BuildRequirements.RequireSlsaBuilder), nil
+func hasPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
 }
@@ -2,7 +2,14 @@ package project
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -11,7 +18,10 @@ import (
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/assertions"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files"
 )
 
 func Test_validateFormat(t *testing.T) {
@@ -86,6 +96,41 @@ func Test_validatePackage(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "set name and wildcard environment",
+			policy: Policy{
+				Package: Package{
+					Name: "non_empty_name",
+					Environment: Environment{
+						AnyOf: []string{"dev", "prod/*", "staging/us-*"},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid wildcard environment",
+			policy: Policy{
+				Package: Package{
+					Name: "non_empty_name",
+					Environment: Environment{
+						AnyOf: []string{"prod/us*east1"},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty environment segment",
+			policy: Policy{
+				Package: Package{
+					Name: "non_empty_name",
+					Environment: Environment{
+						AnyOf: []string{"prod/"},
+					},
+				},
+			},
+			expected: errs.ErrorInvalidField,
+		},
 		{
 			name: "empty environment field",
 			policy: Policy{
@@ -148,6 +193,92 @@ func Test_validateBuildRequirements(t *testing.T) {
 			},
 			builders: []string{"builder_name"},
 		},
+		{
+			name: "valid base images",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI: "non_empty",
+					},
+					BaseImages: &BaseImages{
+						AnyOf: []string{"gcr.io/distroless/"},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+		},
+		{
+			name: "empty base images",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI: "non_empty",
+					},
+					BaseImages: &BaseImages{},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty base image",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI: "non_empty",
+					},
+					BaseImages: &BaseImages{
+						AnyOf: []string{"gcr.io/distroless/", ""},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "valid max age",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI: "non_empty",
+					},
+					MaxAgeDays: common.AsPointer(30),
+				},
+			},
+			builders: []string{"builder_name"},
+		},
+		{
+			name: "zero max age",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI: "non_empty",
+					},
+					MaxAgeDays: common.AsPointer(0),
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "negative max age",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI: "non_empty",
+					},
+					MaxAgeDays: common.AsPointer(-1),
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
 		{
 			name: "builders not set",
 			policy: Policy{
@@ -182,6 +313,71 @@ func Test_validateBuildRequirements(t *testing.T) {
 			builders: []string{"builder_name"},
 			expected: errs.ErrorInvalidField,
 		},
+		{
+			name: "valid any of repositories",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						AnyOf: []string{"github.com/oldorg/repo", "github.com/neworg/repo"},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+		},
+		{
+			name: "both repository fields set",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						URI:   "github.com/oldorg/repo",
+						AnyOf: []string{"github.com/neworg/repo"},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty any of repositories",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						AnyOf: []string{},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty any of repository",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						AnyOf: []string{"github.com/oldorg/repo", ""},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "duplicate any of repository",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					Repository: Repository{
+						AnyOf: []string{"github.com/oldorg/repo", "github.com/oldorg/repo"},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
 		{
 			name: "mismatch builder names",
 			policy: Policy{
@@ -195,6 +391,79 @@ func Test_validateBuildRequirements(t *testing.T) {
 			builders: []string{"other_builder_name"},
 			expected: errs.ErrorInvalidField,
 		},
+		{
+			name: "valid any of builders",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilders: &SlsaBuilders{
+						AnyOf: []string{"builder_name", "other_builder_name"},
+					},
+					Repository: Repository{
+						URI: "non_empty",
+					},
+				},
+			},
+			builders: []string{"builder_name", "other_builder_name"},
+		},
+		{
+			name: "both builder fields set",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder_name",
+					RequireSlsaBuilders: &SlsaBuilders{
+						AnyOf: []string{"other_builder_name"},
+					},
+					Repository: Repository{
+						URI: "non_empty",
+					},
+				},
+			},
+			builders: []string{"builder_name", "other_builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty any of builders",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilders: &SlsaBuilders{},
+					Repository: Repository{
+						URI: "non_empty",
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "any of builders with unknown builder",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilders: &SlsaBuilders{
+						AnyOf: []string{"builder_name", "unknown_builder_name"},
+					},
+					Repository: Repository{
+						URI: "non_empty",
+					},
+				},
+			},
+			builders: []string{"builder_name", "other_builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "any of builders with duplicate builder",
+			policy: Policy{
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilders: &SlsaBuilders{
+						AnyOf: []string{"builder_name", "builder_name"},
+					},
+					Repository: Repository{
+						URI: "non_empty",
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -237,7 +506,7 @@ func Test_FromReaders(t *testing.T) {
 			builders: []string{"builder_name"},
 		},
 		{
-			name: "builder name not present in org policy",
+			name: "passing assertions",
 			policies: []Policy{
 				Policy{
 					Format: 1,
@@ -245,18 +514,21 @@ func Test_FromReaders(t *testing.T) {
 						Name: "name_set",
 					},
 					BuildRequirements: BuildRequirements{
-						RequireSlsaBuilder: "other_builder_name",
+						RequireSlsaBuilder: "builder_name",
 						Repository: Repository{
 							URI: "non_empty",
 						},
 					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.require_slsa_builder", Operator: "eq", Value: "builder_name"},
+						{Path: "package.name", Operator: "contains", Value: "name"},
+					},
 				},
 			},
 			builders: []string{"builder_name"},
-			expected: errs.ErrorInvalidField,
 		},
 		{
-			name: "package name re-use",
+			name: "failing assertion",
 			policies: []Policy{
 				Policy{
 					Format: 1,
@@ -269,7 +541,17 @@ func Test_FromReaders(t *testing.T) {
 							URI: "non_empty",
 						},
 					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.require_slsa_builder", Operator: "eq", Value: "other_builder_name"},
+					},
 				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "assertion with unknown operator",
+			policies: []Policy{
 				Policy{
 					Format: 1,
 					Package: Package{
@@ -281,21 +563,21 @@ func Test_FromReaders(t *testing.T) {
 							URI: "non_empty",
 						},
 					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.require_slsa_builder", Operator: "neq", Value: "builder_name"},
+					},
 				},
 			},
 			builders: []string{"builder_name"},
 			expected: errs.ErrorInvalidField,
 		},
 		{
-			name: "package name re-use same env",
+			name: "assertion with unresolvable path",
 			policies: []Policy{
 				Policy{
 					Format: 1,
 					Package: Package{
 						Name: "name_set",
-						Environment: Environment{
-							AnyOf: []string{"dev"},
-						},
 					},
 					BuildRequirements: BuildRequirements{
 						RequireSlsaBuilder: "builder_name",
@@ -303,17 +585,24 @@ func Test_FromReaders(t *testing.T) {
 							URI: "non_empty",
 						},
 					},
+					Assertions: []assertions.Assertion{
+						{Path: "build.builder", Operator: "eq", Value: "builder_name"},
+					},
 				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "builder name not present in org policy",
+			policies: []Policy{
 				Policy{
 					Format: 1,
 					Package: Package{
 						Name: "name_set",
-						Environment: Environment{
-							AnyOf: []string{"dev"},
-						},
 					},
 					BuildRequirements: BuildRequirements{
-						RequireSlsaBuilder: "builder_name",
+						RequireSlsaBuilder: "other_builder_name",
 						Repository: Repository{
 							URI: "non_empty",
 						},
@@ -324,15 +613,12 @@ func Test_FromReaders(t *testing.T) {
 			expected: errs.ErrorInvalidField,
 		},
 		{
-			name: "package name re-use different env",
+			name: "package name re-use",
 			policies: []Policy{
 				Policy{
 					Format: 1,
 					Package: Package{
 						Name: "name_set",
-						Environment: Environment{
-							AnyOf: []string{"prod"},
-						},
 					},
 					BuildRequirements: BuildRequirements{
 						RequireSlsaBuilder: "builder_name",
@@ -345,9 +631,6 @@ func Test_FromReaders(t *testing.T) {
 					Format: 1,
 					Package: Package{
 						Name: "name_set",
-						Environment: Environment{
-							AnyOf: []string{"dev"},
-						},
 					},
 					BuildRequirements: BuildRequirements{
 						RequireSlsaBuilder: "builder_name",
@@ -361,14 +644,14 @@ func Test_FromReaders(t *testing.T) {
 			expected: errs.ErrorInvalidField,
 		},
 		{
-			name: "package name re-use env set and not",
+			name: "package name re-use same env",
 			policies: []Policy{
 				Policy{
 					Format: 1,
 					Package: Package{
 						Name: "name_set",
 						Environment: Environment{
-							AnyOf: []string{"prod"},
+							AnyOf: []string{"dev"},
 						},
 					},
 					BuildRequirements: BuildRequirements{
@@ -382,8 +665,82 @@ func Test_FromReaders(t *testing.T) {
 					Format: 1,
 					Package: Package{
 						Name: "name_set",
-					},
-					BuildRequirements: BuildRequirements{
+						Environment: Environment{
+							AnyOf: []string{"dev"},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "builder_name",
+						Repository: Repository{
+							URI: "non_empty",
+						},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "package name re-use different env",
+			policies: []Policy{
+				Policy{
+					Format: 1,
+					Package: Package{
+						Name: "name_set",
+						Environment: Environment{
+							AnyOf: []string{"prod"},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "builder_name",
+						Repository: Repository{
+							URI: "non_empty",
+						},
+					},
+				},
+				Policy{
+					Format: 1,
+					Package: Package{
+						Name: "name_set",
+						Environment: Environment{
+							AnyOf: []string{"dev"},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "builder_name",
+						Repository: Repository{
+							URI: "non_empty",
+						},
+					},
+				},
+			},
+			builders: []string{"builder_name"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "package name re-use env set and not",
+			policies: []Policy{
+				Policy{
+					Format: 1,
+					Package: Package{
+						Name: "name_set",
+						Environment: Environment{
+							AnyOf: []string{"prod"},
+						},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "builder_name",
+						Repository: Repository{
+							URI: "non_empty",
+						},
+					},
+				},
+				Policy{
+					Format: 1,
+					Package: Package{
+						Name: "name_set",
+					},
+					BuildRequirements: BuildRequirements{
 						RequireSlsaBuilder: "builder_name",
 						Repository: Repository{
 							URI: "non_empty",
@@ -494,12 +851,200 @@ func Test_FromReaders(t *testing.T) {
 	}
 }
 
+// newPolicies returns n valid policy files. If invalid is set, every
+// tenth policy has an invalid format and every tenth policy re-uses the
+// package name of the previous one.
+func newPolicies(t testing.TB, n int, invalid bool) [][]byte {
+	policies := make([][]byte, n)
+	for i := range policies {
+		project := Policy{
+			Format: 1,
+			Package: Package{
+				Name: fmt.Sprintf("package_name%d", i),
+			},
+			BuildRequirements: BuildRequirements{
+				RequireSlsaBuilder: "builder_name",
+				Repository: Repository{
+					URI: fmt.Sprintf("repo_uri%d", i),
+				},
+			},
+		}
+		if invalid && i%10 == 3 {
+			project.Format = 0
+		}
+		if invalid && i%10 == 7 {
+			project.Package.Name = fmt.Sprintf("package_name%d", i-1)
+		}
+		content, err := json.Marshal(project)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	return policies
+}
+
+func Test_FromReadersConcurrent(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{}
+	orgPolicy.Roots.Build = append(orgPolicy.Roots.Build, organization.Root{Name: "builder_name"})
+	valid := newPolicies(t, 100, false)
+	invalid := newPolicies(t, 100, true)
+	want, err := FromReaders(common.NewBytesIterator(valid), orgPolicy, nil, options.WithWorkers(1))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	_, wantErr := FromReaders(common.NewBytesIterator(invalid), orgPolicy, nil, options.WithWorkers(1))
+	if diff := cmp.Diff(errs.ErrorInvalidField, wantErr, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	tests := []struct {
+		name    string
+		workers int
+	}{
+		{
+			name:    "two workers",
+			workers: 2,
+		},
+		{
+			name:    "more workers than policies",
+			workers: 200,
+		},
+		{
+			name: "default workers",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// NOTE: the results must not depend on scheduling.
+			for i := 0; i < 10; i++ {
+				got, err := FromReaders(common.NewBytesIterator(valid), orgPolicy,
+					common.NewPolicyValidator(true), options.WithWorkers(tt.workers))
+				if err != nil {
+					t.Fatalf("failed to parse: %v", err)
+				}
+				if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(Policy{})); diff != "" {
+					t.Fatalf("unexpected policies (-want +got): \n%s", diff)
+				}
+				_, err = FromReaders(common.NewBytesIterator(invalid), orgPolicy, nil,
+					options.WithWorkers(tt.workers))
+				if diff := cmp.Diff(wantErr.Error(), err.Error()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFromReaders(b *testing.B) {
+	orgPolicy := organization.Policy{}
+	orgPolicy.Roots.Build = append(orgPolicy.Roots.Build, organization.Root{Name: "builder_name"})
+	policies := newPolicies(b, 2000, false)
+	benchmarks := []struct {
+		name    string
+		workers int
+	}{
+		{
+			name:    "sequential",
+			workers: 1,
+		},
+		{
+			name:    "concurrent",
+			workers: runtime.GOMAXPROCS(0),
+		},
+	}
+	for _, bb := range benchmarks {
+		bb := bb // Re-initializing variable so it is not changed while executing the closure below
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := FromReaders(common.NewBytesIterator(policies), orgPolicy, nil,
+					options.WithWorkers(bb.workers)); err != nil {
+					b.Fatalf("failed to parse: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_FromReadersAllErrors(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{}
+	orgPolicy.Roots.Build = append(orgPolicy.Roots.Build, organization.Root{Name: "builder_name"})
+	projects := []Policy{
+		// Invalid format and empty repository.
+		{
+			Package: Package{
+				Name: "package_name1",
+			},
+			BuildRequirements: BuildRequirements{
+				RequireSlsaBuilder: "builder_name",
+			},
+		},
+		// Valid.
+		{
+			Format: 1,
+			Package: Package{
+				Name: "package_name2",
+			},
+			BuildRequirements: BuildRequirements{
+				RequireSlsaBuilder: "builder_name",
+				Repository: Repository{
+					URI: "repo_uri",
+				},
+			},
+		},
+		// Package name re-use.
+		{
+			Format: 1,
+			Package: Package{
+				Name: "package_name2",
+			},
+			BuildRequirements: BuildRequirements{
+				RequireSlsaBuilder: "builder_name",
+				Repository: Repository{
+					URI: "repo_uri",
+				},
+			},
+		},
+	}
+	policies := make([][]byte, len(projects))
+	for i := range projects {
+		content, err := json.Marshal(projects[i])
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	_, err := FromReaders(common.NewBytesIterator(policies), orgPolicy, nil)
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	for _, want := range []string{"policy #0: ", `"/format"`, "policy #2: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error (%q) does not contain (%q)", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "policy #1: ") {
+		t.Fatalf("error (%q) reports a valid policy", err)
+	}
+	// Each violation is reported separately. The schema violations
+	// are reported before the empty repository is validated.
+	if diff := cmp.Diff(2, len(errs.Violations(err))); diff != "" {
+		t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+	}
+}
+
 func Test_Evaluate(t *testing.T) {
 	t.Parallel()
 	type dummyVerifierOpts struct {
 		builderID, sourceURI string
 		environment          *string
 		digests              intoto.DigestSet
+		baseImages           []string
 	}
 	digests := intoto.DigestSet{
 		"sha256": "val256",
@@ -531,6 +1076,64 @@ func Test_Evaluate(t *testing.T) {
 			},
 		},
 	}
+	projectAnyOfBuilders := Policy{
+		Format: 1,
+		Package: Package{
+			Name: packageName,
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaBuilders: &SlsaBuilders{
+				AnyOf: []string{"builder1", "builder2"},
+			},
+			Repository: Repository{
+				URI: sourceURI,
+			},
+		},
+	}
+	projectAnyOfRepositories := Policy{
+		Format: 1,
+		Package: Package{
+			Name: packageName,
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaBuilders: &SlsaBuilders{
+				AnyOf: []string{"builder1", "builder2"},
+			},
+			Repository: Repository{
+				AnyOf: []string{"other_source_name", sourceURI},
+			},
+		},
+	}
+	projectWildcardEnvironment := Policy{
+		Format: 1,
+		Package: Package{
+			Name: packageName,
+			Environment: Environment{
+				AnyOf: []string{"staging", "prod/*"},
+			},
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaBuilder: "builder1",
+			Repository: Repository{
+				URI: sourceURI,
+			},
+		},
+	}
+	projectBaseImages := Policy{
+		Format: 1,
+		Package: Package{
+			Name: packageName,
+		},
+		BuildRequirements: BuildRequirements{
+			RequireSlsaBuilder: "builder1",
+			Repository: Repository{
+				URI: sourceURI,
+			},
+			BaseImages: &BaseImages{
+				AnyOf: []string{"gcr.io/distroless/", "docker.io/acme/golden"},
+			},
+		},
+	}
 	org := organization.Policy{
 		Roots: organization.Roots{
 			Build: []organization.Root{
@@ -561,8 +1164,115 @@ func Test_Evaluate(t *testing.T) {
 		digests      intoto.DigestSet
 		verifierOpts dummyVerifierOpts
 		level        int
+		baseImages   []string
 		expected     error
 	}{
+		{
+			name:         "any of repositories first builder",
+			packageName:  packageName,
+			digests:      digests,
+			org:          org,
+			policy:       projectAnyOfRepositories,
+			verifierOpts: vopts,
+			level:        1,
+		},
+		{
+			name:        "any of repositories second builder",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectAnyOfRepositories,
+			verifierOpts: dummyVerifierOpts{
+				builderID: "builder2_id",
+				sourceURI: "other_source_name",
+				digests:   digests,
+			},
+			level: 2,
+		},
+		{
+			name:        "any of repositories mismatch",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectAnyOfRepositories,
+			verifierOpts: dummyVerifierOpts{
+				builderID: "builder1_id",
+				sourceURI: "unknown_source_name",
+				digests:   digests,
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:        "approved base image",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectBaseImages,
+			verifierOpts: dummyVerifierOpts{
+				builderID:  "builder1_id",
+				sourceURI:  sourceURI,
+				digests:    digests,
+				baseImages: []string{"gcr.io/distroless/static@sha256:abcd"},
+			},
+			level:      1,
+			baseImages: []string{"gcr.io/distroless/static@sha256:abcd"},
+		},
+		{
+			name:        "approved base images",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectBaseImages,
+			verifierOpts: dummyVerifierOpts{
+				builderID:  "builder1_id",
+				sourceURI:  sourceURI,
+				digests:    digests,
+				baseImages: []string{"gcr.io/distroless/static", "docker.io/acme/golden:v1"},
+			},
+			level:      1,
+			baseImages: []string{"gcr.io/distroless/static", "docker.io/acme/golden:v1"},
+		},
+		{
+			name:        "unapproved base image",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectBaseImages,
+			verifierOpts: dummyVerifierOpts{
+				builderID:  "builder1_id",
+				sourceURI:  sourceURI,
+				digests:    digests,
+				baseImages: []string{"gcr.io/distroless/static", "docker.io/library/ubuntu"},
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:        "unknown base image",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectBaseImages,
+			verifierOpts: dummyVerifierOpts{
+				builderID: "builder1_id",
+				sourceURI: sourceURI,
+				digests:   digests,
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:        "base images ignored",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectBuilder1,
+			verifierOpts: dummyVerifierOpts{
+				builderID:  "builder1_id",
+				sourceURI:  sourceURI,
+				digests:    digests,
+				baseImages: []string{"docker.io/library/ubuntu"},
+			},
+			level: 1,
+		},
 		{
 			name:        "no verifier defined",
 			packageName: packageName,
@@ -668,7 +1378,42 @@ func Test_Evaluate(t *testing.T) {
 			expected: errs.ErrorVerification,
 		},
 		{
-			name:        "builder 2 different source",
+			name:         "any of builders first success",
+			packageName:  packageName,
+			digests:      digests,
+			org:          org,
+			policy:       projectAnyOfBuilders,
+			level:        1,
+			verifierOpts: vopts,
+		},
+		{
+			name:        "any of builders second success",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectAnyOfBuilders,
+			verifierOpts: dummyVerifierOpts{
+				builderID: "builder2_id",
+				sourceURI: sourceURI,
+				digests:   digests,
+			},
+			level: 2,
+		},
+		{
+			name:        "any of builders none supported",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectAnyOfBuilders,
+			verifierOpts: dummyVerifierOpts{
+				builderID: "builder3_id",
+				sourceURI: sourceURI,
+				digests:   digests,
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:        "builder 2 different source",
 			packageName: packageName,
 			digests:     digests,
 			org:         org,
@@ -719,6 +1464,48 @@ func Test_Evaluate(t *testing.T) {
 			verifierOpts: vopts,
 			expected:     errs.ErrorInvalidInput,
 		},
+		{
+			name:        "request env matches wildcard",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectWildcardEnvironment,
+			level:       1,
+			verifierOpts: dummyVerifierOpts{
+				builderID:   "builder1_id",
+				sourceURI:   sourceURI,
+				digests:     digests,
+				environment: common.AsPointer("prod/us-east1"),
+			},
+		},
+		{
+			name:        "request env more segments than wildcard",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectWildcardEnvironment,
+			verifierOpts: dummyVerifierOpts{
+				builderID:   "builder1_id",
+				sourceURI:   sourceURI,
+				digests:     digests,
+				environment: common.AsPointer("prod/us-east1/zone-a"),
+			},
+			expected: errs.ErrorNotFound,
+		},
+		{
+			name:        "request env is wildcard",
+			packageName: packageName,
+			digests:     digests,
+			org:         org,
+			policy:      projectWildcardEnvironment,
+			verifierOpts: dummyVerifierOpts{
+				builderID:   "builder1_id",
+				sourceURI:   sourceURI,
+				digests:     digests,
+				environment: common.AsPointer("prod/*"),
+			},
+			expected: errs.ErrorNotFound,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -727,8 +1514,8 @@ func Test_Evaluate(t *testing.T) {
 			// Create the verifier that succeeds for the right parameters.
 			var verifier options.AttestationVerifier
 			if !tt.noVerifier {
-				verifier = common.NewAttestationVerifier(tt.verifierOpts.digests, tt.packageName,
-					tt.verifierOpts.builderID, tt.verifierOpts.sourceURI)
+				verifier = common.NewAttestationVerifierWithBaseImages(tt.verifierOpts.digests, tt.packageName,
+					tt.verifierOpts.builderID, tt.verifierOpts.sourceURI, tt.verifierOpts.baseImages)
 			}
 			opts := options.BuildVerification{
 				Verifier: verifier,
@@ -736,16 +1523,468 @@ func Test_Evaluate(t *testing.T) {
 			req := options.Request{
 				Environment: tt.verifierOpts.environment,
 			}
-			level, err := tt.policy.Evaluate(tt.digests, tt.packageName, tt.org, req, opts)
+			result, err := tt.policy.Evaluate(tt.digests, tt.packageName, tt.org, req, opts)
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 			if err != nil {
 				return
 			}
-			if diff := cmp.Diff(tt.level, level); diff != "" {
+			if diff := cmp.Diff(tt.level, result.Level); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.verifierOpts.builderID, result.BuilderID); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(strings.TrimSuffix(tt.verifierOpts.builderID, "_id"), result.BuilderName); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.verifierOpts.sourceURI, result.SourceURI); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.baseImages, result.BaseImages); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_EvaluateMaxAge(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "val256",
+	}
+	org := organization.Policy{
+		Roots: organization.Roots{
+			Build: []organization.Root{
+				{
+					ID:        "builder1_id",
+					Name:      "builder1",
+					SlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name       string
+		maxAgeDays *int
+		age        time.Duration
+		expected   error
+	}{
+		{
+			name:       "recent provenance",
+			maxAgeDays: common.AsPointer(30),
+			age:        29 * 24 * time.Hour,
+		},
+		{
+			name:       "old provenance",
+			maxAgeDays: common.AsPointer(30),
+			age:        31 * 24 * time.Hour,
+			expected:   errs.ErrorVerification,
+		},
+		{
+			name: "no max age",
+			age:  365 * 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			policy := Policy{
+				Format: 1,
+				Package: Package{
+					Name: "package_name",
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder1",
+					Repository: Repository{
+						URI: "source_name",
+					},
+					MaxAgeDays: tt.maxAgeDays,
+				},
+			}
+			opts := options.BuildVerification{
+				Verifier: common.NewAttestationVerifierWithAge(digests, "package_name", "builder1_id", "source_name", tt.age),
+			}
+			_, err := policy.Evaluate(digests, "package_name", org, options.Request{}, opts)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
 		})
 	}
 }
+
+func Test_EvaluateIdentity(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "val256",
+	}
+	issuer := "https://token.actions.githubusercontent.com"
+	org, err := organization.FromReader(io.NopCloser(strings.NewReader(`{"format": 1, "roots": {"build": [
+		{"identity": {"issuer": "https://token.actions.githubusercontent.com",
+			"subject_regex": "^https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v.*$"},
+		"name": "builder1", "slsa_level": 3}]}}`)))
+	if err != nil {
+		t.Fatalf("failed to read organization policy: %v", err)
+	}
+	tests := []struct {
+		name     string
+		issuer   string
+		subject  string
+		expected error
+	}{
+		{
+			name:    "matching identity",
+			issuer:  issuer,
+			subject: "https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v1.0.0",
+		},
+		{
+			name:     "mismatch subject",
+			issuer:   issuer,
+			subject:  "https://github.com/org/builder/.github/workflows/build.yml@refs/heads/main",
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "mismatch issuer",
+			issuer:   "https://accounts.google.com",
+			subject:  "https://github.com/org/builder/.github/workflows/build.yml@refs/tags/v1.0.0",
+			expected: errs.ErrorVerification,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			policy := Policy{
+				Format: 1,
+				Package: Package{
+					Name: "package_name",
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilder: "builder1",
+					Repository: Repository{
+						URI: "source_name",
+					},
+				},
+			}
+			opts := options.BuildVerification{
+				Verifier: common.NewAttestationVerifierWithIdentity(digests, "package_name", tt.issuer, tt.subject, "source_name"),
+			}
+			result, err := policy.Evaluate(digests, "package_name", *org, options.Request{}, opts)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff("builder1", result.BuilderName); diff != "" {
+				t.Fatalf("unexpected builder name (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff("", result.BuilderID); diff != "" {
+				t.Fatalf("unexpected builder ID (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_SourceURIs(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "val256",
+	}
+	org, err := organization.FromReader(io.NopCloser(strings.NewReader(`{"format": 1, "roots": {"build": [
+		{"id": "builder1_id", "name": "builder1", "slsa_level": 3, "source_uris": ["github.com/org/*"]},
+		{"id": "builder2_id", "name": "builder2", "slsa_level": 2}]}}`)))
+	if err != nil {
+		t.Fatalf("failed to read organization policy: %v", err)
+	}
+	tests := []struct {
+		name        string
+		builders    []string
+		sourceURIs  []string
+		verifierID  string
+		verifierURI string
+		result      *Result
+		parseErr    error
+		expected    error
+	}{
+		{
+			name:        "allowed source",
+			builders:    []string{"builder1"},
+			sourceURIs:  []string{"github.com/org/repo"},
+			verifierID:  "builder1_id",
+			verifierURI: "github.com/org/repo",
+			result: &Result{
+				Level:       3,
+				BuilderID:   "builder1_id",
+				BuilderName: "builder1",
+				SourceURI:   "github.com/org/repo",
+			},
+		},
+		{
+			name:        "unrestricted builder",
+			builders:    []string{"builder2"},
+			sourceURIs:  []string{"github.com/fork/repo"},
+			verifierID:  "builder2_id",
+			verifierURI: "github.com/fork/repo",
+			result: &Result{
+				Level:       2,
+				BuilderID:   "builder2_id",
+				BuilderName: "builder2",
+				SourceURI:   "github.com/fork/repo",
+			},
+		},
+		{
+			name:        "fork",
+			builders:    []string{"builder1"},
+			sourceURIs:  []string{"github.com/fork/repo"},
+			verifierID:  "builder1_id",
+			verifierURI: "github.com/fork/repo",
+			parseErr:    errs.ErrorInvalidField,
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "fork with unrestricted builder",
+			builders:    []string{"builder1", "builder2"},
+			sourceURIs:  []string{"github.com/fork/repo"},
+			verifierID:  "builder2_id",
+			verifierURI: "github.com/fork/repo",
+			parseErr:    errs.ErrorInvalidField,
+			result: &Result{
+				Level:       2,
+				BuilderID:   "builder2_id",
+				BuilderName: "builder2",
+				SourceURI:   "github.com/fork/repo",
+			},
+		},
+		{
+			name:        "fork among allowed sources",
+			builders:    []string{"builder1"},
+			sourceURIs:  []string{"github.com/fork/repo", "github.com/org/repo"},
+			verifierID:  "builder1_id",
+			verifierURI: "github.com/fork/repo",
+			expected:    errs.ErrorVerification,
+		},
+		{
+			name:        "allowed among sources",
+			builders:    []string{"builder1"},
+			sourceURIs:  []string{"github.com/fork/repo", "github.com/org/repo"},
+			verifierID:  "builder1_id",
+			verifierURI: "github.com/org/repo",
+			result: &Result{
+				Level:       3,
+				BuilderID:   "builder1_id",
+				BuilderName: "builder1",
+				SourceURI:   "github.com/org/repo",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			policy := Policy{
+				Format: 1,
+				Package: Package{
+					Name: "package_name",
+				},
+				BuildRequirements: BuildRequirements{
+					RequireSlsaBuilders: &SlsaBuilders{AnyOf: tt.builders},
+					Repository: Repository{
+						AnyOf: tt.sourceURIs,
+					},
+				},
+			}
+			content, err := json.Marshal(policy)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			_, err = FromReaders(common.NewBytesIterator([][]byte{content}), *org, nil)
+			if diff := cmp.Diff(tt.parseErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// NOTE: the policy is evaluated even if it is rejected
+			// by FromReaders(), as if the check was not static.
+			opts := options.BuildVerification{
+				Verifier: common.NewAttestationVerifier(digests, "package_name", tt.verifierID, tt.verifierURI),
+			}
+			result, err := policy.Evaluate(digests, "package_name", *org, options.Request{}, opts)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.result, result); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func FuzzFromReaders(f *testing.F) {
+	orgPolicy, builderNames := randomOrgPolicy()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		content, err := json.Marshal(randomPolicy(r, fmt.Sprintf("package_name%d", i), builderNames))
+		if err != nil {
+			f.Fatalf("failed to marshal: %v", err)
+		}
+		f.Add(content)
+	}
+	f.Fuzz(func(t *testing.T, content []byte) {
+		_, _ = FromReaders(common.NewBytesIterator([][]byte{content}), orgPolicy, common.NewPolicyValidator(true))
+	})
+}
+
+func Test_FromReadersExtends(t *testing.T) {
+	t.Parallel()
+
+	orgPolicy := organization.Policy{}
+	orgPolicy.Roots.Build = append(orgPolicy.Roots.Build,
+		organization.Root{Name: "builder_name"}, organization.Root{Name: "other_builder_name"})
+	base := `{"base": true, "format": 1,
+		"package": {"environment": {"any_of": ["dev", "prod"]}},
+		"build": {"require_slsa_builder": "builder_name", "repository": {"uri": "repo_uri"}}}`
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expected    map[string]Policy
+		errContains []string
+		err         error
+	}{
+		{
+			name: "shared base",
+			files: fstest.MapFS{
+				"projects/base.json": {Data: []byte(base)},
+				"projects/app1.yml":  {Data: []byte("extends: base.json\npackage:\n  name: package_name1\n")},
+				"projects/app2.json": {Data: []byte(`{"extends": "base.json", "package": {"name": "package_name2",
+					"environment": {"any_of": ["prod"]}}, "build": {"require_slsa_builder": "other_builder_name"}}`)},
+			},
+			expected: map[string]Policy{
+				"package_name1": {
+					Format: 1,
+					Package: Package{
+						Name:        "package_name1",
+						Environment: Environment{AnyOf: []string{"dev", "prod"}},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "builder_name",
+						Repository:         Repository{URI: "repo_uri"},
+					},
+				},
+				"package_name2": {
+					Format: 1,
+					Package: Package{
+						Name:        "package_name2",
+						Environment: Environment{AnyOf: []string{"prod"}},
+					},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "other_builder_name",
+						Repository:         Repository{URI: "repo_uri"},
+					},
+				},
+			},
+		},
+		{
+			name: "complete base",
+			files: fstest.MapFS{
+				"projects/base.json": {Data: []byte(`{"base": true, "format": 1, "package": {"name": "package_name1"},
+					"build": {"require_slsa_builder": "builder_name", "repository": {"uri": "repo_uri"}}}`)},
+				"projects/app2.json": {Data: []byte(`{"extends": "base.json", "package": {"name": "package_name2"}}`)},
+			},
+			expected: map[string]Policy{
+				"package_name2": {
+					Format:  1,
+					Package: Package{Name: "package_name2"},
+					BuildRequirements: BuildRequirements{
+						RequireSlsaBuilder: "builder_name",
+						Repository:         Repository{URI: "repo_uri"},
+					},
+				},
+			},
+		},
+		{
+			name: "invalid merged policy",
+			files: fstest.MapFS{
+				"projects/base.json": {Data: []byte(base)},
+				"projects/app.json": {Data: []byte(`{"extends": "base.json", "package": {"name": "package_name"},
+					"build": {"require_slsa_builder": "unknown_builder_name"}}`)},
+			},
+			errContains: []string{`projects/app.json (extends "projects/base.json"): `, "unknown_builder_name"},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "unknown field in base",
+			files: fstest.MapFS{
+				"projects/base.json": {Data: []byte(`{"base": true, "format": 1, "unknown": true}`)},
+				"projects/app.json":  {Data: []byte(`{"extends": "base.json", "package": {"name": "package_name"}}`)},
+			},
+			errContains: []string{`projects/app.json (extends "projects/base.json"): `, "unknown"},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "base not found",
+			files: fstest.MapFS{
+				"projects/app.json": {Data: []byte(`{"extends": "../base.json", "package": {"name": "package_name"}}`)},
+			},
+			errContains: []string{`projects/app.json (extends "base.json"): `},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "cycle",
+			files: fstest.MapFS{
+				"projects/app1.json": {Data: []byte(`{"base": true, "extends": "app2.json", "package": {"name": "package_name1"}}`)},
+				"projects/app2.json": {Data: []byte(`{"base": true, "extends": "app1.json", "package": {"name": "package_name2"}}`)},
+			},
+			errContains: []string{"projects/app1.json -> projects/app2.json -> projects/app1.json"},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "max depth",
+			files: fstest.MapFS{
+				"projects/app.json":   {Data: []byte(`{"extends": "base1.json", "package": {"name": "package_name"}}`)},
+				"projects/base1.json": {Data: []byte(`{"base": true, "extends": "base2.json"}`)},
+				"projects/base2.json": {Data: []byte(`{"base": true, "extends": "base3.json"}`)},
+				"projects/base3.json": {Data: []byte(`{"base": true, "extends": "base4.json"}`)},
+				"projects/base4.json": {Data: []byte(`{"base": true, "extends": "base5.json"}`)},
+				"projects/base5.json": {Data: []byte(base)},
+			},
+			errContains: []string{"extends more than (4) files"},
+			err:         errs.ErrorInvalidField,
+		},
+		{
+			name: "policy of another team",
+			files: fstest.MapFS{
+				"projects/app1.json": {Data: []byte(`{"format": 1, "package": {"name": "package_name1"},
+					"build": {"require_slsa_builder": "builder_name", "repository": {"uri": "repo_uri"}}}`)},
+				"projects/app2.json": {Data: []byte(`{"extends": "app1.json", "package": {"name": "package_name2"}}`)},
+			},
+			errContains: []string{`projects/app2.json (extends "projects/app1.json"): `, "not a base file"},
+			err:         errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			iter, err := files.NewFSPolicyIterator(tt.files, "projects/*")
+			if err != nil {
+				t.Fatalf("failed to create iterator: %v", err)
+			}
+			policies, err := FromReaders(iterator.Unnamed(iter), orgPolicy, nil)
+			if diff := cmp.Diff(tt.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			for _, want := range tt.errContains {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("error (%q) does not contain (%q)", err, want)
+				}
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, policies, cmpopts.IgnoreUnexported(Policy{})); diff != "" {
+				t.Fatalf("unexpected policies (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package project
+
+import (
+	_ "embed"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+)
+
+//go:embed schema.json
+var schemaContent []byte
+
+// policySchema validates the policy files before they are unmarshaled.
+var policySchema = schema.MustCompile(schemaContent)
+
+// Schema returns the JSON Schema of the publish project policy.
+func Schema() []byte {
+	return policySchema.Bytes()
+}
@@ -0,0 +1,111 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/assertions"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/schema"
+)
+
+// subset returns a random non-empty subset of values.
+func subset(r *rand.Rand, values []string) []string {
+	var result []string
+	for _, i := range r.Perm(len(values))[:1+r.Intn(len(values))] {
+		result = append(result, values[i])
+	}
+	return result
+}
+
+// randomPolicy returns a random valid policy for
+// package name, given the builders of the org policy.
+func randomPolicy(r *rand.Rand, name string, builderNames []string) Policy {
+	policy := Policy{
+		Format: 1,
+		Package: Package{
+			Name: name,
+		},
+	}
+	if r.Intn(2) == 0 {
+		policy.Package.Environment.AnyOf = subset(r, []string{"dev", "prod", "staging/*", "prod/eu-*"})
+	}
+	build := &policy.BuildRequirements
+	// NOTE: the default builder of the org policy applies if none is set.
+	switch r.Intn(3) {
+	case 0:
+		build.RequireSlsaBuilder = builderNames[r.Intn(len(builderNames))]
+	case 1:
+		build.RequireSlsaBuilders = &SlsaBuilders{
+			AnyOf: subset(r, builderNames),
+		}
+	}
+	if r.Intn(2) == 0 {
+		build.Repository.URI = "https://github.com/org/repo"
+	} else {
+		build.Repository.AnyOf = subset(r, []string{"https://github.com/org/repo", "https://github.com/org/old-repo"})
+	}
+	if r.Intn(2) == 0 {
+		build.BaseImages = &BaseImages{
+			AnyOf: subset(r, []string{"docker.io/library/", "gcr.io/distroless/"}),
+		}
+	}
+	if r.Intn(2) == 0 {
+		build.MaxAgeDays = common.AsPointer(1 + r.Intn(90))
+	}
+	if r.Intn(2) == 0 {
+		policy.Assertions = []assertions.Assertion{
+			{Path: "package.name", Operator: assertions.OperatorEq, Value: name},
+		}
+	}
+	return policy
+}
+
+// randomOrgPolicy returns the org policy the random
+// policies are created for, and its builder names.
+func randomOrgPolicy() (organization.Policy, []string) {
+	orgPolicy := organization.Policy{
+		Format: 1,
+		Defaults: &organization.Defaults{
+			Build: organization.DefaultBuild{
+				RequireSlsaBuilder: "builder_name0",
+			},
+		},
+	}
+	var builderNames []string
+	for i := 0; i < 3; i++ {
+		builderNames = append(builderNames, fmt.Sprintf("builder_name%d", i))
+		orgPolicy.Roots.Build = append(orgPolicy.Roots.Build, organization.Root{
+			ID:        fmt.Sprintf("builder_id%d", i),
+			Name:      builderNames[i],
+			SlsaLevel: common.AsPointer(3),
+		})
+	}
+	return orgPolicy, builderNames
+}
+
+func Test_SchemaAgreement(t *testing.T) {
+	t.Parallel()
+	if _, err := schema.Compile(Schema()); err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	orgPolicy, builderNames := randomOrgPolicy()
+	r := rand.New(rand.NewSource(1))
+	policies := make([][]byte, 200)
+	for i := range policies {
+		content, err := json.Marshal(randomPolicy(r, fmt.Sprintf("package_name%d", i), builderNames))
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if err := policySchema.Validate(content, false); err != nil {
+			t.Fatalf("schema rejects policy (%s): %v", content, err)
+		}
+		policies[i] = content
+	}
+	if _, err := FromReaders(common.NewBytesIterator(policies), orgPolicy, nil); err != nil {
+		t.Fatalf("failed to create policies: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"slices"
+)
+
+// Stats contains statistics about the policy.
+type Stats struct {
+	Projects                   int
+	PackagesPerProject         map[int]int
+	Environments               []string
+	PackagesWithoutEnvironment int
+	Levels                     map[int]int
+	ReferencedRoots            []string
+	UnreferencedRoots          []string
+}
+
+// Stats computes statistics about the policy.
+func (p *Policy) Stats() Stats {
+	stats := Stats{
+		Projects:           len(p.projectPolicies),
+		PackagesPerProject: make(map[int]int),
+		Levels:             make(map[int]int),
+		Environments:       []string{},
+		ReferencedRoots:    []string{},
+		UnreferencedRoots:  []string{},
+	}
+	referenced := make(map[string]bool)
+	for _, projectPolicy := range p.projectPolicies {
+		// Each project policy defines a single package.
+		stats.PackagesPerProject[1]++
+		env := projectPolicy.Package.Environment.AnyOf
+		if len(env) == 0 {
+			stats.PackagesWithoutEnvironment++
+		}
+		for _, e := range env {
+			if !slices.Contains(stats.Environments, e) {
+				stats.Environments = append(stats.Environments, e)
+			}
+		}
+		// NOTE: a package that accepts several builders is counted
+		// at the lowest level among them.
+		level := -1
+		for _, builderName := range projectPolicy.BuildRequirements.BuilderNames() {
+			builderLevel := p.orgPolicy.BuilderSlsaLevel(builderName)
+			if level == -1 || builderLevel < level {
+				level = builderLevel
+			}
+			referenced[builderName] = true
+		}
+		stats.Levels[level]++
+	}
+	// Roots are referenced by name in project policies.
+	for i := range p.orgPolicy.Roots.Build {
+		root := &p.orgPolicy.Roots.Build[i]
+		if referenced[root.Name] {
+			stats.ReferencedRoots = append(stats.ReferencedRoots, root.ID)
+			continue
+		}
+		stats.UnreferencedRoots = append(stats.UnreferencedRoots, root.ID)
+	}
+	slices.Sort(stats.Environments)
+	slices.Sort(stats.ReferencedRoots)
+	slices.Sort(stats.UnreferencedRoots)
+	return stats
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/project"
+)
+
+func Test_Stats(t *testing.T) {
+	t.Parallel()
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Build: []organization.Root{
+				{
+					ID:        "builder_id3",
+					Name:      "builder_name3",
+					SlsaLevel: common.AsPointer(3),
+				},
+				{
+					ID:        "builder_id2",
+					Name:      "builder_name2",
+					SlsaLevel: common.AsPointer(2),
+				},
+				{
+					ID:        "builder_id1",
+					Name:      "builder_name1",
+					SlsaLevel: common.AsPointer(1),
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: "package_name1",
+				Environment: project.Environment{
+					AnyOf: []string{"prod", "dev"},
+				},
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilder: "builder_name3",
+				Repository: project.Repository{
+					URI: "repo_uri1",
+				},
+			},
+		},
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: "package_name2",
+				Environment: project.Environment{
+					AnyOf: []string{"staging", "prod"},
+				},
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilder: "builder_name3",
+				Repository: project.Repository{
+					URI: "repo_uri2",
+				},
+			},
+		},
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: "package_name3",
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilder: "builder_name1",
+				Repository: project.Repository{
+					URI: "repo_uri3",
+				},
+			},
+		},
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: "package_name4",
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilders: &project.SlsaBuilders{
+					AnyOf: []string{"builder_name3", "builder_name2"},
+				},
+				Repository: project.Repository{
+					URI: "repo_uri4",
+				},
+			},
+		},
+	}
+	expected := Stats{
+		Projects: 4,
+		PackagesPerProject: map[int]int{
+			1: 4,
+		},
+		Environments:               []string{"dev", "prod", "staging"},
+		PackagesWithoutEnvironment: 2,
+		Levels: map[int]int{
+			1: 1,
+			2: 1,
+			3: 2,
+		},
+		ReferencedRoots:   []string{"builder_id1", "builder_id2", "builder_id3"},
+		UnreferencedRoots: []string{},
+	}
+
+	orgContent, err := json.Marshal(org)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	contents := make([][]byte, len(projects))
+	for i := range projects {
+		content, err := json.Marshal(projects[i])
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		contents[i] = content
+	}
+	orgReader := io.NopCloser(bytes.NewReader(orgContent))
+	projectsReader := common.NewBytesIterator(contents)
+	policy, err := PolicyNew(orgReader, projectsReader, nil)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	if diff := cmp.Diff(expected, policy.Stats()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,45 @@
+package publish
+
+import (
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+)
+
+// SetMetrics sets the recorder of the outcome and duration of
+// each evaluation, labeled with the "publish" component.
+// Nothing is recorded by default.
+func SetMetrics(m metrics.Metrics) PolicyOption {
+	return func(p *Policy) error {
+		return p.setMetrics(m)
+	}
+}
+
+func (p *Policy) setMetrics(m metrics.Metrics) error {
+	if m == nil {
+		return fmt.Errorf("%w: metrics is nil", errs.ErrorInvalidInput)
+	}
+	p.metrics = m
+	return nil
+}
+
+// WithMetrics records the outcome and duration of the verification,
+// labeled with the "publish" component. Errors of the options
+// themselves are not recorded.
+func WithMetrics(m metrics.Metrics) VerificationOption {
+	return func(v *Verification) error {
+		return v.setMetrics(m)
+	}
+}
+
+func (v *Verification) setMetrics(m metrics.Metrics) error {
+	if m == nil {
+		return fmt.Errorf("%w: metrics is nil", errs.ErrorInvalidInput)
+	}
+	if v.metrics != nil {
+		return fmt.Errorf("%w: metrics is set more than once", errs.ErrorInvalidInput)
+	}
+	v.metrics = m
+	return nil
+}
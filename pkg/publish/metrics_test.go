@@ -0,0 +1,221 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/project"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// observation is a set of labels recorded by metricsRecorder.
+type observation struct {
+	kind      string
+	component string
+	decision  string
+	code      string
+}
+
+// metricsRecorder counts the observations by labels.
+type metricsRecorder struct {
+	mu     sync.Mutex
+	counts map[observation]int
+}
+
+func (r *metricsRecorder) ObserveEvaluation(component, decision, code string, duration time.Duration) {
+	r.observe(observation{"evaluation", component, decision, code})
+}
+
+func (r *metricsRecorder) ObserveVerification(component, decision, code string, duration time.Duration) {
+	r.observe(observation{"verification", component, decision, code})
+}
+
+func (r *metricsRecorder) observe(o observation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[observation]int)
+	}
+	r.counts[o]++
+}
+
+func Test_SetMetrics(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	registry := "registry"
+	packageName := "package_name"
+	builderID := "https://github.com/actions/runner/github-hosted"
+	sourceURI := "source_uri"
+	orgContent, err := json.Marshal(organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Build: []organization.Root{
+				{
+					ID:        builderID,
+					Name:      "github_actions_level_3",
+					SlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	projectContent, err := json.Marshal(project.Policy{
+		Format: 1,
+		Package: project.Package{
+			Name: packageName,
+		},
+		BuildRequirements: project.BuildRequirements{
+			RequireSlsaBuilder: "github_actions_level_3",
+			Repository: project.Repository{
+				URI: sourceURI,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	recorder := &metricsRecorder{}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+		common.NewBytesIterator([][]byte{projectContent}), newPackageHelper(registry), SetMetrics(recorder))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	requests := []struct {
+		digests   intoto.DigestSet
+		sourceURI string
+	}{
+		{digests: digests, sourceURI: sourceURI},
+		{digests: digests, sourceURI: sourceURI},
+		{digests: digests, sourceURI: "other_uri"},
+		{digests: intoto.DigestSet{}, sourceURI: sourceURI},
+	}
+	for _, req := range requests {
+		verifier := newAttestationVerifier(common.NewAttestationVerifier(digests, packageName, builderID, req.sourceURI))
+		pol.Evaluate(req.digests, packageName, RequestOption{}, AttestationVerificationOption{
+			Verifier: verifier,
+		})
+	}
+	expected := map[observation]int{
+		{"evaluation", "publish", "allow", ""}:             2,
+		{"evaluation", "publish", "deny", "verification"}:  1,
+		{"evaluation", "publish", "deny", "invalid_field"}: 1,
+	}
+	if diff := cmp.Diff(expected, recorder.counts, cmp.AllowUnexported(observation{})); diff != "" {
+		t.Fatalf("unexpected counts (-want +got): \n%s", diff)
+	}
+
+	// Nil recorder.
+	_, err = PolicyNew(io.NopCloser(bytes.NewReader(orgContent)),
+		common.NewBytesIterator([][]byte{projectContent}), newPackageHelper(registry), SetMetrics(nil))
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_WithMetrics(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	content, err := json.Marshal(attestation{
+		Header: intoto.Header{
+			Type:          statementType,
+			PredicateType: predicateType,
+			Subjects: []intoto.Subject{
+				{Digests: digests},
+			},
+		},
+		Predicate: predicate{
+			CreationTime: intoto.Now(),
+			Package: intoto.PackageDescriptor{
+				Name:     packageName,
+				Registry: registry,
+			},
+			Properties: map[string]interface{}{
+				buildLevelProperty: 3,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	recorder := &metricsRecorder{}
+	tests := []struct {
+		name     string
+		digests  intoto.DigestSet
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:    "allow",
+			digests: digests,
+			options: []VerificationOption{WithMetrics(recorder)},
+		},
+		{
+			name:    "allow with options",
+			digests: digests,
+			options: []VerificationOption{IsSlsaBuildLevel(3), WithMetrics(recorder)},
+		},
+		{
+			name:     "level mismatch",
+			digests:  digests,
+			options:  []VerificationOption{WithMetrics(recorder), IsSlsaBuildLevel(2)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "invalid digests",
+			digests:  intoto.DigestSet{},
+			options:  []VerificationOption{WithMetrics(recorder)},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:    "no metrics",
+			digests: digests,
+		},
+		{
+			name:     "nil metrics",
+			digests:  digests,
+			options:  []VerificationOption{WithMetrics(nil)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "metrics set twice",
+			digests:  digests,
+			options:  []VerificationOption{WithMetrics(recorder), WithMetrics(recorder)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		err := verification.Verify(tt.digests, packageName, tt.options...)
+		if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("%s: unexpected err (-want +got): \n%s", tt.name, diff)
+		}
+	}
+	expected := map[observation]int{
+		{"verification", "publish", "allow", ""}:             2,
+		{"verification", "publish", "deny", "mismatch"}:      1,
+		{"verification", "publish", "deny", "invalid_field"}: 1,
+	}
+	if diff := cmp.Diff(expected, recorder.counts, cmp.AllowUnexported(observation{})); diff != "" {
+		t.Fatalf("unexpected counts (-want +got): \n%s", diff)
+	}
+}
@@ -1,6 +1,10 @@
 package publish
 
-import "github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+import (
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
 
 // PackageHelper defines an interface to let callers
 // customize the parsing of the packages defined
@@ -13,3 +17,17 @@ type PackageHelper interface {
 	// from a policy's package name.
 	PackageDescriptor(string) (intoto.PackageDescriptor, error)
 }
+
+// normalizeRegistry returns the registry without its
+// scheme and trailing slashes, lowercased, e.g.
+// "docker.io" for "https://Docker.io/".
+func normalizeRegistry(registry string) string {
+	registry = strings.ToLower(registry)
+	for _, scheme := range []string{"https://", "http://"} {
+		if strings.HasPrefix(registry, scheme) {
+			registry = registry[len(scheme):]
+			break
+		}
+	}
+	return strings.TrimRight(registry, "/")
+}
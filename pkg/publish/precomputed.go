@@ -0,0 +1,139 @@
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// precomputed contains the data Verify derives from the attestation.
+// It is computed once when the verification is created and never
+// modified afterwards, so concurrent Verify calls share it without locking.
+type precomputed struct {
+	// structureErr is the result of attestation.validate().
+	structureErr error
+	// subjects maps a digest algorithm and value to the
+	// indices of the subjects with this digest.
+	subjects map[string]map[string][]int
+	level    int
+	levelErr error
+	sbom     *intoto.ResourceDescriptor
+	sbomErr  error
+	// unknownReserved are the sorted properties under the
+	// reserved prefix that this library does not recognize.
+	unknownReserved []string
+}
+
+func precomputedNew(att *attestation) *precomputed {
+	p := &precomputed{
+		structureErr:    att.validate(),
+		unknownReserved: unknownReservedProperties(att.Predicate.Properties),
+	}
+	p.level, p.levelErr = att.level()
+	p.sbom, p.sbomErr = att.sbom()
+	if p.structureErr != nil {
+		return p
+	}
+	// NOTE: the digests of the subjects are valid.
+	p.subjects = make(map[string]map[string][]int)
+	for i := range att.Header.Subjects {
+		ds := att.Header.Subjects[i].Digests
+		for name := range ds {
+			// NOTE: the index is looked up with normalized digests.
+			alg := intoto.CanonicalDigestAlgorithm(name)
+			value, _ := ds.DigestValue(alg)
+			values, exists := p.subjects[alg]
+			if !exists {
+				values = make(map[string][]int)
+				p.subjects[alg] = values
+			}
+			values[value] = append(values[value], i)
+		}
+	}
+	return p
+}
+
+// precomputed returns the precomputed data. It computes it if the
+// verification was not created by verificationNew, e.g. in tests.
+func (v *Verification) precomputed() *precomputed {
+	if v.computed != nil {
+		return v.computed
+	}
+	return precomputedNew(&v.attestation)
+}
+
+// verifySubjects is equivalent to verifySubjects() but looks the
+// candidate subjects up by digest, and does not allocate on success.
+// The digests must be normalized.
+func (p *precomputed) verifySubjects(subjects []intoto.Subject, digests intoto.DigestSet) error {
+	for name, value := range digests {
+		// NOTE: a matching subject has every digest,
+		// so the candidates of any digest are enough.
+		for _, i := range p.subjects[name][value] {
+			if containsDigests(subjects[i].Digests, digests) {
+				return nil
+			}
+		}
+		break
+	}
+	// Report the same errors as without the index.
+	return verifySubjects(subjects, digests)
+}
+
+func containsDigests(ds intoto.DigestSet, digests intoto.DigestSet) bool {
+	if _, conflicts := ds.ConflictingAlgorithm(); conflicts {
+		return false
+	}
+	for name, value := range digests {
+		if val, exists := ds.DigestValue(name); !exists || val != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *attestation) level() (int, error) {
+	if a.Predicate.Properties == nil {
+		return 0, errs.VerificationErrorNew(errs.CheckBuildLevel, "", "",
+			fmt.Errorf("%w: publish properties are empty", errs.ErrorMismatch))
+	}
+	value, exists := a.Predicate.Properties[buildLevelProperty]
+	if !exists {
+		return 0, errs.VerificationErrorNew(errs.CheckBuildLevel, "", "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch,
+				buildLevelProperty))
+	}
+	vv, ok := value.(float64)
+	if !ok {
+		return 0, errs.VerificationErrorNew(errs.CheckBuildLevel, "", fmt.Sprintf("%v", value),
+			fmt.Errorf("%w: attestation level (%T:%v) is not an int", errs.ErrorMismatch, value, value))
+	}
+	return int(vv), nil
+}
+
+func (a *attestation) sbom() (*intoto.ResourceDescriptor, error) {
+	value, exists := a.Predicate.Properties[sbomProperty]
+	if !exists {
+		return nil, errs.VerificationErrorNew(errs.CheckSBOM, "", "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch, sbomProperty))
+	}
+	// NOTE: the value is a generic JSON object, so we convert it.
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to marshal SBOM: %v", errs.ErrorInvalidField, err)
+	}
+	var sbom intoto.ResourceDescriptor
+	if err := json.Unmarshal(content, &sbom); err != nil {
+		return nil, fmt.Errorf("%w: SBOM (%T:%v) is not a resource descriptor: %v", errs.ErrorInvalidField,
+			value, value, err)
+	}
+	if sbom.URI == "" {
+		return nil, fmt.Errorf("%w: SBOM URI is empty", errs.ErrorInvalidField)
+	}
+	if err := sbom.Digest.Validate(); err != nil {
+		return nil, fmt.Errorf("SBOM: %w", err)
+	}
+	return &sbom, nil
+}
@@ -0,0 +1,202 @@
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/checks"
+)
+
+// Properties under the reserved prefix "slsa.dev/" are written by
+// this library only. The recognized keys are:
+//   - slsa.dev/build/level: the SLSA build level of the package.
+//   - slsa.dev/build/baseImages: the approved base images the package
+//     was built from.
+//   - slsa.dev/build/builder: the ID and name of the trusted builder
+//     and the source URI the package's provenance was verified against.
+//   - slsa.dev/publish/sbom: the resource descriptor of the package's SBOM.
+//   - slsa.dev/telemetry/evaluationDurationMs: the duration of the
+//     policy evaluation, in milliseconds.
+//   - slsa.dev/telemetry/verifierCalls: the number of verifier calls
+//     made during the policy evaluation.
+//
+// Any other key under the reserved prefix is unknown to this version
+// of the library and must not be trusted.
+const reservedPropertyPrefix = "slsa.dev/"
+
+var reservedProperties = []string{
+	buildLevelProperty,
+	baseImagesProperty,
+	builderProperty,
+	sbomProperty,
+	evaluationDurationProperty,
+	verifierCallsProperty,
+}
+
+// isReservedProperty returns true if the key is under the reserved prefix.
+// The comparison ignores case and surrounding spaces so that look-alike
+// keys are reported too.
+func isReservedProperty(name string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(name)), reservedPropertyPrefix)
+}
+
+// unknownReservedProperties returns the sorted keys under the reserved
+// prefix that this library does not recognize.
+func unknownReservedProperties(props properties) []string {
+	var names []string
+	for name := range props {
+		if isReservedProperty(name) && !slices.Contains(reservedProperties, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Warnings returns the findings about the attestation that do not
+// fail verification by default, such as unknown reserved properties.
+func (v *Verification) Warnings() []string {
+	var warnings []string
+	for _, name := range v.precomputed().unknownReserved {
+		warnings = append(warnings, fmt.Sprintf("property (%q) uses the reserved prefix (%q) but is unknown",
+			name, reservedPropertyPrefix))
+	}
+	return warnings
+}
+
+// RejectUnknownReservedProperties rejects attestations that contain
+// properties under the reserved prefix that this library does not recognize.
+func RejectUnknownReservedProperties() VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind: "RejectUnknownReservedProperties",
+			Rank: checks.RankProperties,
+			Run:  v.rejectUnknownReservedProperties,
+		})
+	}
+}
+
+func (v *Verification) rejectUnknownReservedProperties() error {
+	if names := v.precomputed().unknownReserved; len(names) > 0 {
+		return errs.VerificationErrorNew(errs.CheckProperty, "", "",
+			fmt.Errorf("%w: unknown reserved properties (%q)", errs.ErrorInvalidField, names))
+	}
+	return nil
+}
+
+func (a *Creation) validateProperties() error {
+	if names := unknownReservedProperties(a.attestation.Predicate.Properties); len(names) > 0 {
+		return fmt.Errorf("%w: cannot write unknown reserved properties (%q)", errs.ErrorInvalidInput, names)
+	}
+	return nil
+}
+
+// WithProperty records a custom property in the attestation,
+// e.g. the name of the team that owns the package. The value
+// must be serializable to JSON. Keys under the reserved prefix
+// are written by this library only and are rejected.
+func WithProperty(key string, value interface{}) AttestationCreationOption {
+	return func(a *Creation) error {
+		return a.withProperty(key, value)
+	}
+}
+
+func (a *Creation) withProperty(key string, value interface{}) error {
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("%w: property key is empty", errs.ErrorInvalidInput)
+	}
+	if isReservedProperty(key) {
+		return fmt.Errorf("%w: property (%q) uses the reserved prefix (%q)", errs.ErrorInvalidInput,
+			key, reservedPropertyPrefix)
+	}
+	if _, err := jsonValue(value); err != nil {
+		return fmt.Errorf("property (%q): %w", key, err)
+	}
+	if a.attestation.Predicate.Properties == nil {
+		a.attestation.Predicate.Properties = make(map[string]interface{})
+	}
+	a.attestation.Predicate.Properties[key] = value
+	return nil
+}
+
+// HasProperty verifies that the attestation contains the property.
+func HasProperty(key string) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "HasProperty",
+			Key:   key,
+			Value: fmt.Sprintf("%q", key),
+			Rank:  checks.RankProperties,
+			Run: func() error {
+				_, err := v.property(key)
+				return err
+			},
+		})
+	}
+}
+
+// PropertyEquals verifies that the attestation contains the property
+// with the value. Values are compared after conversion to JSON, so that
+// numbers compare equal regardless of their Go type, and structs and
+// nested maps compare equal to the JSON objects they serialize to.
+func PropertyEquals(key string, value interface{}) VerificationOption {
+	expected, err := jsonValue(value)
+	return func(v *Verification) error {
+		if err != nil {
+			return fmt.Errorf("property (%q): %w", key, err)
+		}
+		content, _ := json.Marshal(expected)
+		return v.addCheck(checks.Check{
+			Kind:  "PropertyEquals",
+			Key:   struct{ key, content string }{key, string(content)},
+			Value: fmt.Sprintf("%q:%s", key, content),
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.propertyEquals(key, expected) },
+		})
+	}
+}
+
+func (v *Verification) propertyEquals(key string, expected interface{}) error {
+	value, err := v.property(key)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(expected, value) {
+		return errs.VerificationErrorNew(errs.CheckProperty, fmt.Sprintf("%v", expected), fmt.Sprintf("%v", value),
+			fmt.Errorf("%w: property (%q) value (%v) != attestation value (%v)", errs.ErrorMismatch,
+				key, expected, value))
+	}
+	return nil
+}
+
+func (v *Verification) property(key string) (interface{}, error) {
+	if key == "" {
+		return nil, fmt.Errorf("%w: property key is empty", errs.ErrorInvalidInput)
+	}
+	value, exists := v.attestation.Predicate.Properties[key]
+	if !exists {
+		return nil, errs.VerificationErrorNew(errs.CheckProperty, "", "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch, key))
+	}
+	return value, nil
+}
+
+// jsonValue converts the value to the form it is decoded to from
+// JSON, e.g. integers to float64 and structs to maps, so that it
+// can be compared to the properties of a parsed attestation.
+func jsonValue(value interface{}) (interface{}, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: value (%T) is not serializable to JSON: %v", errs.ErrorInvalidInput,
+			value, err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal value (%T): %v", errs.ErrorInternal, value, err)
+	}
+	return decoded, nil
+}
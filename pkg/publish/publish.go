@@ -1,27 +1,69 @@
 package publish
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/logging"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/retry"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 // AttestationVerifier defines an interface to verify attestations.
 type AttestationVerifier interface {
 	// Build attestation verification.
-	VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID, sourceURI string) error
+	VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID, sourceURI string, opts AttestationVerifierBuildOptions) error
+	// Base images the package was built from, extracted from the provenance
+	// or an SBOM attestation. Only called if the policy requires base images.
+	BaseImages(digests intoto.DigestSet, policyPackageName string) ([]string, error)
+}
+
+// AttestationVerifierBuildOptions defines options for
+// verifying a build attestation.
+type AttestationVerifierBuildOptions struct {
+	// MaxAge, if non-zero, is the maximum age of the provenance.
+	// The verifier must return an error if the provenance was
+	// built earlier.
+	MaxAge time.Duration
+	// Identity, if set, is the certificate identity of the builder
+	// defined by the organization policy. The builder ID is empty
+	// and the verifier must match the signer of the provenance
+	// against the identity instead.
+	Identity *RootIdentity
+}
+
+// RootIdentity defines the certificate identity of a trusted root.
+type RootIdentity struct {
+	// Issuer is the OIDC issuer of the certificate.
+	Issuer string
+	// SubjectRegex matches the subject alternative name of the certificate.
+	// It is anchored, so it matches the whole name.
+	SubjectRegex *regexp.Regexp
 }
 
 // AttestationVerificationOption defines the configuration to verify
 // build attestations.
 type AttestationVerificationOption struct {
 	Verifier AttestationVerifier
-	// We can add attestation-specific options here.
+	// Retry, if set, retries the calls to the verifier that fail
+	// with a transient error, see errs.TransientError. Failed
+	// verifications are never retried.
+	Retry *retry.Policy
+	// Context, if set, interrupts the waits between retries
+	// once it is done. It defaults to context.Background().
+	Context context.Context
 }
 
 // RequestOption contains options from the caller.
@@ -34,22 +76,64 @@ type Policy struct {
 	policy        *internal.Policy
 	validator     options.PolicyValidator
 	packageHelper PackageHelper
+	now           func() time.Time
+	rootUsage     RootUsageMode
+	warnings      []string
+	parseOpts     []options.ParseOption
+	logger        *slog.Logger
+	metrics       metrics.Metrics
 }
 
 // PolicyOption defines a policy option.
 type PolicyOption func(*Policy) error
 
+// EvaluationOption defines an option of a single evaluation.
+type EvaluationOption func(*evaluation) error
+
+type evaluation struct {
+	trace *trace.Trace
+}
+
+// WithTrace records the project policy and builders considered by the
+// evaluation, and why each was rejected, see PolicyEvaluationResult.Trace().
+func WithTrace() EvaluationOption {
+	return func(e *evaluation) error {
+		e.trace = trace.New()
+		return nil
+	}
+}
+
 // This is a helpder class to forward calls between the internal
 // classes and the caller.
 type internal_verifier struct {
-	opts AttestationVerificationOption
+	opts  AttestationVerificationOption
+	calls int
 }
 
-func (i *internal_verifier) VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID, sourceURI string) error {
+func (i *internal_verifier) VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID string,
+	identity *options.Identity, sourceURI string, maxAge time.Duration) error {
 	if i.opts.Verifier == nil {
 		return fmt.Errorf("%w: verifier is nil", errs.ErrorInvalidInput)
 	}
-	return i.opts.Verifier.VerifyBuildAttestation(digests, policyPackageName, builderID, sourceURI)
+	i.calls++
+	opts := AttestationVerifierBuildOptions{
+		MaxAge: maxAge,
+	}
+	if identity != nil {
+		opts.Identity = &RootIdentity{
+			Issuer:       identity.Issuer,
+			SubjectRegex: identity.SubjectRegex,
+		}
+	}
+	return i.opts.Verifier.VerifyBuildAttestation(digests, policyPackageName, builderID, sourceURI, opts)
+}
+
+func (i *internal_verifier) BaseImages(digests intoto.DigestSet, policyPackageName string) ([]string, error) {
+	if i.opts.Verifier == nil {
+		return nil, fmt.Errorf("%w: verifier is nil", errs.ErrorInvalidInput)
+	}
+	i.calls++
+	return i.opts.Verifier.BaseImages(digests, policyPackageName)
 }
 
 // This is a class to forward calls between internal
@@ -71,17 +155,41 @@ func (i *internal_validator) ValidatePackage(pkg options.ValidationPackage) erro
 	})
 }
 
+// PolicyNewFromFS creates a publish policy from the files of fsys, e.g.
+// an embed.FS. orgPath is the path of the org policy, and projectGlob
+// matches the paths of the project policies, see fs.Glob. Errors
+// identify a project policy by its path in fsys.
+func PolicyNewFromFS(fsys fs.FS, orgPath, projectGlob string, packageHelper PackageHelper, opts ...PolicyOption) (*Policy, error) {
+	projects, err := files.NewFSPolicyIterator(fsys, projectGlob, orgPath)
+	if err != nil {
+		return nil, err
+	}
+	org, err := files.OpenFS(fsys, orgPath)
+	if err != nil {
+		return nil, err
+	}
+	return PolicyNewFromIterator(org, projects, packageHelper, opts...)
+}
+
+// PolicyNewFromIterator creates a publish policy from a named iterator
+// over the project policies, e.g. a files.PolicyIterator. Errors identify
+// a project policy by its ID in the iterator.
+func PolicyNewFromIterator(org io.ReadCloser, projects iterator.NamedReadCloserIterator, packageHelper PackageHelper, opts ...PolicyOption) (*Policy, error) {
+	return PolicyNew(org, iterator.Unnamed(projects), packageHelper, opts...)
+}
+
 // New creates a publish policy.
 func PolicyNew(org io.ReadCloser, projects iterator.ReadCloserIterator, packageHelper PackageHelper, opts ...PolicyOption) (*Policy, error) {
 	// Initialize a policy with caller options.
 	p := new(Policy)
+	p.now = time.Now
 	for _, option := range opts {
 		err := option(p)
 		if err != nil {
 			return nil, err
 		}
 	}
-	policy, err := internal.PolicyNew(org, projects, p.validator)
+	policy, err := internal.PolicyNew(org, projects, p.validator, p.parseOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -90,10 +198,15 @@ func PolicyNew(org io.ReadCloser, projects iterator.ReadCloserIterator, packageH
 		return nil, fmt.Errorf("%w: package hepler is nil", errs.ErrorInvalidInput)
 	}
 	p.packageHelper = packageHelper
+	if err := p.checkRootUsage(); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
-// SetValidator sets a custom validator.
+// SetValidator sets a custom validator. The validator must be safe
+// for concurrent use if more than one parse worker is set, see
+// SetParseWorkers().
 func SetValidator(validator PolicyValidator) PolicyOption {
 	return func(p *Policy) error {
 		return p.setValidator(validator)
@@ -109,41 +222,208 @@ func (p *Policy) setValidator(validator PolicyValidator) error {
 	return nil
 }
 
+// AllowUnknownFields accepts policy files with fields that are not defined
+// by the policy format, e.g. annotations. By default, such files are rejected
+// so that a misspelled field does not silently weaken the policy.
+func AllowUnknownFields() PolicyOption {
+	return func(p *Policy) error {
+		return p.allowUnknownFields()
+	}
+}
+
+func (p *Policy) allowUnknownFields() error {
+	p.parseOpts = append(p.parseOpts, options.AllowUnknownFields())
+	return nil
+}
+
+// SetParseWorkers sets the maximum number of project policy files
+// parsed concurrently. It defaults to 1. The validator set
+// by SetValidator() may be called concurrently unless workers is 1.
+func SetParseWorkers(workers int) PolicyOption {
+	return func(p *Policy) error {
+		return p.setParseWorkers(workers)
+	}
+}
+
+func (p *Policy) setParseWorkers(workers int) error {
+	if workers < 1 {
+		return fmt.Errorf("%w: parse workers (%d) must be positive", errs.ErrorInvalidInput, workers)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithWorkers(workers))
+	return nil
+}
+
+// SetMaxPolicySize sets the maximum size of a policy file, in bytes.
+// Larger files are rejected with errs.ErrorInvalidInput. It defaults
+// to 4 MiB.
+func SetMaxPolicySize(size int64) PolicyOption {
+	return func(p *Policy) error {
+		return p.setMaxPolicySize(size)
+	}
+}
+
+func (p *Policy) setMaxPolicySize(size int64) error {
+	if size < 1 {
+		return fmt.Errorf("%w: max policy size (%d) must be positive", errs.ErrorInvalidInput, size)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithMaxSize(size))
+	return nil
+}
+
+// SetMaxPolicies sets the maximum number of project policy files,
+// including the files they extend. Once more files are read, the
+// project policies are rejected with errs.ErrorInvalidInput, without
+// reading the remaining files. Since a project policy file defines
+// a single package, it also bounds the number of packages. It
+// defaults to 10000.
+func SetMaxPolicies(count int) PolicyOption {
+	return func(p *Policy) error {
+		return p.setMaxPolicies(count)
+	}
+}
+
+func (p *Policy) setMaxPolicies(count int) error {
+	if count < 1 {
+		return fmt.Errorf("%w: max policies (%d) must be positive", errs.ErrorInvalidInput, count)
+	}
+	p.parseOpts = append(p.parseOpts, options.WithMaxPolicies(count))
+	return nil
+}
+
+// SetClock sets the clock used to measure the evaluation.
+func SetClock(now func() time.Time) PolicyOption {
+	return func(p *Policy) error {
+		return p.setClock(now)
+	}
+}
+
+func (p *Policy) setClock(now func() time.Time) error {
+	if now == nil {
+		return fmt.Errorf("%w: clock is nil", errs.ErrorInvalidInput)
+	}
+	p.now = now
+	return nil
+}
+
+// SetLogger sets the logger that receives the records of the policy
+// parsing and evaluation, e.g. the builders considered and the verifier
+// results. Nothing is logged by default.
+func SetLogger(logger *slog.Logger) PolicyOption {
+	return func(p *Policy) error {
+		return p.setLogger(logger)
+	}
+}
+
+func (p *Policy) setLogger(logger *slog.Logger) error {
+	if logger == nil {
+		return fmt.Errorf("%w: logger is nil", errs.ErrorInvalidInput)
+	}
+	p.logger = logger
+	p.parseOpts = append(p.parseOpts, options.WithLogger(logger))
+	return nil
+}
+
+// EffectiveHash returns a hash of the parsed policy. It does not
+// depend on formatting, key order or the order of the project files,
+// and changes whenever a field used during evaluation changes.
+func (p *Policy) EffectiveHash() (string, error) {
+	return p.policy.EffectiveHash()
+}
+
 // Evaluate evalues the publish policy.
 func (p *Policy) Evaluate(digests intoto.DigestSet, policyPackageName string, reqOpts RequestOption,
-	opts AttestationVerificationOption) PolicyEvaluationResult {
-	level, err := p.policy.Evaluate(digests, policyPackageName,
+	opts AttestationVerificationOption, evalOpts ...EvaluationOption) PolicyEvaluationResult {
+	var eval evaluation
+	for _, option := range evalOpts {
+		if err := option(&eval); err != nil {
+			return PolicyEvaluationResult{
+				err:       err,
+				evaluated: true,
+			}
+		}
+	}
+	if p.metrics == nil {
+		return p.evaluate(digests, policyPackageName, reqOpts, opts, eval)
+	}
+	start := time.Now()
+	result := p.evaluate(digests, policyPackageName, reqOpts, opts, eval)
+	decision, code := metrics.Decision(result.err)
+	p.metrics.ObserveEvaluation(metrics.ComponentPublish, decision, code, time.Since(start))
+	return result
+}
+
+func (p *Policy) evaluate(digests intoto.DigestSet, policyPackageName string, reqOpts RequestOption,
+	opts AttestationVerificationOption, eval evaluation) PolicyEvaluationResult {
+	start := p.now()
+	logger := logging.OrDiscard(p.logger)
+	verifier := &internal_verifier{
+		opts: opts,
+	}
+	digests, err := digests.Normalize()
+	if err == nil {
+		err = opts.Retry.Validate()
+	}
+	if err != nil {
+		logger.Info("policy decision", "package", policyPackageName, "allow", false, "error", err)
+		eval.trace.Record(trace.Step{Package: policyPackageName, Outcome: trace.OutcomeRejected,
+			Reason: trace.ReasonInvalidInput}, err)
+		return PolicyEvaluationResult{
+			err:       err,
+			evaluated: true,
+			trace:     eval.trace,
+		}
+	}
+	result, err := p.policy.Evaluate(digests, policyPackageName,
 		options.Request{
 			Environment: reqOpts.Environment,
 		},
 		options.BuildVerification{
-			Verifier: &internal_verifier{
-				opts: opts,
-			},
+			Verifier: verifier,
+			Logger:   p.logger,
+			Trace:    eval.trace,
+			Retry:    opts.Retry,
+			Context:  opts.Context,
 		},
 	)
 	if err != nil {
+		logger.Info("policy decision", "package", policyPackageName, "allow", false, "error", err)
 		return PolicyEvaluationResult{
 			err:       err,
 			evaluated: true,
+			trace:     eval.trace,
 		}
 	}
 
 	// Translate the policy package names to a package descriptor.
 	packageDesc, err := p.packageHelper.PackageDescriptor(policyPackageName)
 	if err != nil {
+		logger.Info("policy decision", "package", policyPackageName, "allow", false, "error", err)
+		eval.trace.Record(trace.Step{Package: policyPackageName, Outcome: trace.OutcomeRejected,
+			Reason: trace.ReasonInvalidInput}, err)
 		return PolicyEvaluationResult{
 			err:       err,
 			evaluated: true,
+			trace:     eval.trace,
 		}
 	}
+	logger.Info("policy decision", "package", policyPackageName, "allow", true, "level", result.Level,
+		"builder_id", result.BuilderID, "source_uri", result.SourceURI)
 	return PolicyEvaluationResult{
-		level:       level,
+		level:       result.Level,
+		builderID:   result.BuilderID,
+		builderName: result.BuilderName,
+		sourceURI:   result.SourceURI,
+		baseImages:  result.BaseImages,
 		err:         err,
 		packageDesc: packageDesc,
 		digests:     digests,
 		environment: reqOpts.Environment,
 		evaluated:   true,
+		telemetry: &telemetry{
+			duration:      p.now().Sub(start),
+			verifierCalls: verifier.calls,
+		},
+		trace: eval.trace,
 	}
 }
 
@@ -151,3 +431,10 @@ func (p *Policy) Evaluate(digests intoto.DigestSet, policyPackageName string, re
 func PredicateType() string {
 	return predicateType
 }
+
+// PredicateTypes returns the predicate types accepted
+// by verification, the current one first.
+// Utility function for cosign integration.
+func PredicateTypes() []string {
+	return []string{predicateType, predicateTypeV01}
+}
@@ -2,9 +2,21 @@ package publish
 
 import (
 	"bytes"
+	"context"
+	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -13,13 +25,17 @@ import (
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/project"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator/files"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/retry"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 func Test_AttestationNew(t *testing.T) {
 	t.Parallel()
 	digests := intoto.DigestSet{
-		"sha256":    "some_value",
-		"gitCommit": "another_value",
+		"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
 	}
 	subject := intoto.Subject{
 		Digests: digests,
@@ -53,6 +69,50 @@ func Test_AttestationNew(t *testing.T) {
 			subject:    subject,
 			buildLevel: level,
 		},
+		{
+			name: "with base images",
+			result: PolicyEvaluationResult{
+				evaluated:   true,
+				level:       level,
+				packageDesc: packageDesc,
+				digests:     digests,
+				environment: environment,
+				baseImages:  []string{"gcr.io/distroless/static"},
+			},
+			options:    []AttestationCreationOption{},
+			subject:    subject,
+			buildLevel: level,
+		},
+		{
+			name: "with builder",
+			result: PolicyEvaluationResult{
+				evaluated:   true,
+				level:       level,
+				builderID:   "builder_id",
+				builderName: "builder_name",
+				sourceURI:   "source_uri",
+				packageDesc: packageDesc,
+				digests:     digests,
+				environment: environment,
+			},
+			options:    []AttestationCreationOption{},
+			subject:    subject,
+			buildLevel: level,
+		},
+		{
+			name: "with builder in safe mode",
+			result: PolicyEvaluationResult{
+				evaluated:   true,
+				level:       level,
+				builderID:   "builder_id",
+				builderName: "builder_name",
+				sourceURI:   "source_uri",
+				packageDesc: packageDesc,
+				digests:     digests,
+			},
+			options:  []AttestationCreationOption{SetBuilder("other_id", "other_name", "other_uri")},
+			expected: errs.ErrorInternal,
+		},
 		{
 			name: "no env",
 			result: PolicyEvaluationResult{
@@ -119,6 +179,23 @@ func Test_AttestationNew(t *testing.T) {
 			if diff := cmp.Diff(tt.buildLevel, v); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if len(tt.result.baseImages) > 0 {
+				if diff := cmp.Diff(tt.result.baseImages, properties[baseImagesProperty]); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+			if tt.result.builderID != "" {
+				expectedBuilder := builder{
+					ID:        tt.result.builderID,
+					Name:      tt.result.builderName,
+					SourceURI: tt.result.sourceURI,
+				}
+				if diff := cmp.Diff(expectedBuilder, properties[builderProperty]); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			} else if _, exists := properties[builderProperty]; exists {
+				t.Fatalf("%q property exists: \n", builderProperty)
+			}
 			var expectedEnv string
 			if tt.result.environment != nil {
 				expectedEnv = *tt.result.environment
@@ -134,8 +211,8 @@ func Test_AttestationNew(t *testing.T) {
 func Test_e2e(t *testing.T) {
 	t.Parallel()
 	digests := intoto.DigestSet{
-		"sha256":    "some_value",
-		"gitCommit": "another_value",
+		"sha256":    "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+		"gitCommit": "a3623e630f9d01bdda426723ca7ec17a8146f25c",
 	}
 	packageRegistry := "registry"
 	packageName := "package_name"
@@ -295,6 +372,39 @@ func Test_e2e(t *testing.T) {
 			errorEvaluate:    errs.ErrorNotFound,
 			errorAttestation: errs.ErrorInternal,
 		},
+		{
+			name: "wildcard env",
+			// Policies to evaluate.
+			org: orgPolicy,
+			projects: []project.Policy{
+				{
+					Format: 1,
+					Package: project.Package{
+						Name: packageName,
+						Environment: project.Environment{
+							AnyOf: []string{"dev", "prod/*"},
+						},
+					},
+					BuildRequirements: project.BuildRequirements{
+						RequireSlsaBuilder: "github_actions_level_3",
+						Repository: project.Repository{
+							URI: sourceURI,
+						},
+					},
+				},
+			},
+			// NOTE: the attestation records the concrete environment.
+			packageEnvironment: common.AsPointer("prod/us-east1"),
+			// Options to create the attestation.
+			options:     []AttestationCreationOption{},
+			packageName: packageName,
+			// Fields to validate the created attestation.
+			digests:    digests,
+			buildLevel: githubLevel,
+			// Builder that the verifier will use.
+			builderID: githubHostedRunner,
+			sourceURI: sourceURI,
+		},
 		{
 			name: "no env",
 			// Policies to evaluate.
@@ -378,11 +488,11 @@ func Test_e2e(t *testing.T) {
 			// No validator.
 			orgReader = io.NopCloser(bytes.NewReader(orgContent))
 			projectsReader = common.NewBytesIterator(policies)
-			pol, err := PolicyNew(orgReader, projectsReader, packageHelper)
+			pol, err := PolicyNew(orgReader, projectsReader, packageHelper, SetClock(newFakeClock(10*time.Millisecond)))
 			if err != nil {
 				t.Fatalf("failed to create policy: %v", err)
 			}
-			verifier := common.NewAttestationVerifier(tt.digests, tt.packageName, tt.builderID, tt.sourceURI)
+			verifier := newAttestationVerifier(common.NewAttestationVerifier(tt.digests, tt.packageName, tt.builderID, tt.sourceURI))
 			opts := AttestationVerificationOption{
 				Verifier: verifier,
 			}
@@ -393,6 +503,24 @@ func Test_e2e(t *testing.T) {
 			if diff := cmp.Diff(tt.errorEvaluate, result.Error(), cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			if result.Error() == nil {
+				// The fake clock advances by 10ms on each call.
+				if diff := cmp.Diff(10*time.Millisecond, result.telemetry.duration); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if result.telemetry.verifierCalls < 1 {
+					t.Fatalf("unexpected verifier calls: %d\n", result.telemetry.verifierCalls)
+				}
+				if diff := cmp.Diff(tt.builderID, result.BuilderID()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if diff := cmp.Diff(tt.sourceURI, result.SourceURI()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if diff := cmp.Diff(tt.digests, result.Digests()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
 			if err != nil {
 				return
 			}
@@ -433,3 +561,1543 @@ func Test_e2e(t *testing.T) {
 		})
 	}
 }
+
+func Test_Telemetry(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	packageDesc := intoto.PackageDescriptor{
+		Name:     "package_name",
+		Registry: "package_registry",
+	}
+	tests := []struct {
+		name             string
+		telemetry        *telemetry
+		options          []AttestationCreationOption
+		expected         error
+		expectedErr      error
+		expectedDuration time.Duration
+		expectedCalls    int
+	}{
+		{
+			name: "telemetry enabled",
+			telemetry: &telemetry{
+				duration:      1500 * time.Millisecond,
+				verifierCalls: 3,
+			},
+			options:          []AttestationCreationOption{WithTelemetryProperties()},
+			expectedDuration: 1500 * time.Millisecond,
+			expectedCalls:    3,
+		},
+		{
+			name: "telemetry not enabled",
+			telemetry: &telemetry{
+				duration:      1500 * time.Millisecond,
+				verifierCalls: 3,
+			},
+			expectedErr: errs.ErrorNotFound,
+		},
+		{
+			name:     "telemetry enabled without data",
+			options:  []AttestationCreationOption{WithTelemetryProperties()},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := PolicyEvaluationResult{
+				evaluated:   true,
+				level:       2,
+				packageDesc: packageDesc,
+				digests:     digests,
+				telemetry:   tt.telemetry,
+			}
+			att, err := result.AttestationNew(tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(packageDesc.Registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v\n", err)
+			}
+			duration, err := verification.EvaluationDuration()
+			if diff := cmp.Diff(tt.expectedErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedDuration, duration); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			calls, err := verification.VerifierCalls()
+			if diff := cmp.Diff(tt.expectedErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedCalls, calls); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func newFakeClock(step time.Duration) func() time.Time {
+	now := time.Unix(0, 0)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// Test_DigestSetContract checks that nil and empty digest sets
+// are rejected with ErrorInvalidField by every entry point,
+// before the attestation verifier is called.
+func Test_DigestSetContract(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	packageDesc := intoto.PackageDescriptor{
+		Name:     "package_name",
+		Registry: "registry",
+	}
+	builderID := "builder_id"
+	sourceURI := "source_uri"
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Build: []organization.Root{
+				{
+					ID:        builderID,
+					Name:      "builder_name",
+					SlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: packageDesc.Name,
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilder: "builder_name",
+				Repository: project.Repository{
+					URI: sourceURI,
+				},
+			},
+		},
+	}
+	orgContent, err := json.Marshal(org)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	policies := make([][]byte, len(projects))
+	for i := range projects {
+		content, err := json.Marshal(projects[i])
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	packageHelper := newPackageHelper(packageDesc.Registry)
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)), common.NewBytesIterator(policies), packageHelper)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, packageDesc)
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	attBytes, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	entryPoints := []struct {
+		name string
+		call func(digests intoto.DigestSet) error
+	}{
+		{
+			name: "Evaluate",
+			call: func(ds intoto.DigestSet) error {
+				// NOTE: the verifier fails with ErrorVerification on any other digests.
+				verifier := newAttestationVerifier(common.NewAttestationVerifier(digests, packageDesc.Name, builderID, sourceURI))
+				result := pol.Evaluate(ds, packageDesc.Name, RequestOption{}, AttestationVerificationOption{
+					Verifier: verifier,
+				})
+				return result.Error()
+			},
+		},
+		{
+			name: "CreationNew",
+			call: func(ds intoto.DigestSet) error {
+				_, err := CreationNew(intoto.Subject{Digests: ds}, packageDesc)
+				return err
+			},
+		},
+		{
+			name: "Verify",
+			call: func(ds intoto.DigestSet) error {
+				verification, err := VerificationNew(io.NopCloser(bytes.NewReader(attBytes)), packageHelper)
+				if err != nil {
+					return err
+				}
+				return verification.Verify(ds, packageDesc.Name)
+			},
+		},
+	}
+	inputs := []struct {
+		name     string
+		digests  intoto.DigestSet
+		expected error
+	}{
+		{
+			name:     "nil digests",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty digests",
+			digests:  intoto.DigestSet{},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:    "single digest",
+			digests: digests,
+		},
+		{
+			name: "mixed case digest",
+			digests: intoto.DigestSet{
+				"SHA256": strings.ToUpper(digests["sha256"]),
+			},
+		},
+		{
+			name: "conflicting duplicate digest",
+			digests: intoto.DigestSet{
+				"sha256": digests["sha256"],
+				"SHA256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, ep := range entryPoints {
+		for _, in := range inputs {
+			ep, in := ep, in // Re-initializing variables so they are not changed while executing the closure below
+			t.Run(ep.name+" "+in.name, func(t *testing.T) {
+				t.Parallel()
+				err := ep.call(in.digests)
+				if diff := cmp.Diff(in.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func Test_RootUsageCheck(t *testing.T) {
+	t.Parallel()
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Build: []organization.Root{
+				{
+					ID:        "builder_id1",
+					Name:      "builder_name1",
+					SlsaLevel: common.AsPointer(3),
+				},
+				{
+					ID:        "builder_id2",
+					Name:      "builder_name2",
+					SlsaLevel: common.AsPointer(2),
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: "package_name",
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilder: "builder_name1",
+				Repository: project.Repository{
+					URI: "source_uri",
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		warnings []string
+		expected error
+	}{
+		{
+			name:     "no check",
+			warnings: []string{},
+		},
+		{
+			name:     "ignore mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageIgnore)},
+			warnings: []string{},
+		},
+		{
+			name:     "warn mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageWarn)},
+			warnings: []string{`build root ("builder_id2") is not referenced by any project`},
+		},
+		{
+			name:     "error mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageError)},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid mode",
+			options:  []PolicyOption{WithRootUsageCheck(RootUsageMode(3))},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			orgContent, err := json.Marshal(org)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			policies := make([][]byte, len(projects))
+			for i := range projects {
+				content, err := json.Marshal(projects[i])
+				if err != nil {
+					t.Fatalf("failed to marshal: %v", err)
+				}
+				policies[i] = content
+			}
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)), common.NewBytesIterator(policies),
+				newPackageHelper("registry"), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.warnings, pol.Warnings()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_YAMLPolicy(t *testing.T) {
+	t.Parallel()
+	orgJSON := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3},
+		{"id": "https://github.com/actions/runner/self-hosted", "name": "github_actions_level_2", "slsa_level": 2}]}}`
+	orgYAML := `# Organization policy.
+format: 1
+roots:
+  build:
+    - id: https://github.com/actions/runner/github-hosted
+      name: github_actions_level_3
+      slsa_level: 3
+    # Self-hosted runners.
+    - id: https://github.com/actions/runner/self-hosted
+      name: github_actions_level_2
+      slsa_level: 2
+`
+	projectJSON := `{"format": 1, "package": {"name": "package_name", "environment": {"any_of": ["dev", "prod"]}},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`
+	projectYAML := `format: 1
+package:
+  name: package_name
+  environment:
+    any_of: [dev, prod] # Allowed environments.
+build:
+  require_slsa_builder: github_actions_level_3
+  repository:
+    uri: source_uri
+`
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name        string
+		builderID   string
+		environment *string
+		expected    error
+	}{
+		{
+			name:        "passing",
+			builderID:   "https://github.com/actions/runner/github-hosted",
+			environment: common.AsPointer("prod"),
+		},
+		{
+			name:        "mismatch environment",
+			builderID:   "https://github.com/actions/runner/github-hosted",
+			environment: common.AsPointer("staging"),
+			expected:    errs.ErrorNotFound,
+		},
+		{
+			name:        "mismatch builder",
+			builderID:   "https://github.com/actions/runner/self-hosted",
+			environment: common.AsPointer("prod"),
+			expected:    errs.ErrorVerification,
+		},
+	}
+	newPolicy := func(org, project string) *Policy {
+		pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+			common.NewBytesIterator([][]byte{[]byte(project)}), newPackageHelper("registry"))
+		if err != nil {
+			t.Fatalf("failed to create policy: %v", err)
+		}
+		return pol
+	}
+	jsonPolicy := newPolicy(orgJSON, projectJSON)
+	yamlPolicy := newPolicy(orgYAML, projectYAML)
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := AttestationVerificationOption{
+				Verifier: newAttestationVerifier(common.NewAttestationVerifier(digests, "package_name", tt.builderID, "source_uri")),
+			}
+			req := RequestOption{
+				Environment: tt.environment,
+			}
+			want := jsonPolicy.Evaluate(digests, "package_name", req, opts)
+			got := yamlPolicy.Evaluate(digests, "package_name", req, opts)
+			if diff := cmp.Diff(tt.expected, want.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, got.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(want.Level(), got.Level()); diff != "" {
+				t.Fatalf("unexpected level (-want +got): \n%s", diff)
+			}
+		})
+	}
+
+	// YAML documents with unknown fields are rejected.
+	_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(orgYAML+"unknown: value\n"))),
+		common.NewBytesIterator([][]byte{[]byte(projectYAML)}), newPackageHelper("registry"))
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_UnknownFields(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3}]}}`
+	project := `{"format": 1, "package": {"name": "package_name"},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`
+	tests := []struct {
+		name     string
+		org      string
+		project  string
+		options  []PolicyOption
+		contains []string
+		expected error
+	}{
+		{
+			name:    "no unknown fields",
+			org:     org,
+			project: project,
+		},
+		{
+			name:     "unknown org field",
+			org:      `{"format": 1, "annotations": {}, "roots": {"build": []}}`,
+			project:  project,
+			contains: []string{`"annotations"`, "organization policy"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "unknown project field",
+			org:  org,
+			project: `{"format": 1, "package": {"name": "package_name"}, "build": {"require_slsa_builder": "github_actions_level_3",
+				"repository": {"uri": "source_uri", "branch": "main"}}}`,
+			contains: []string{`"branch"`, "policy #0"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:    "allowed unknown fields",
+			org:     `{"format": 1, "annotations": {}, "roots": {"build": [{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3}]}}`,
+			project: `{"format": 1, "owner": "team", "package": {"name": "package_name"}, "build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`,
+			options: []PolicyOption{AllowUnknownFields()},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(tt.org))),
+				common.NewBytesIterator([][]byte{[]byte(tt.project)}), newPackageHelper("registry"), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(err.Error(), s) {
+					t.Fatalf("error (%v) does not contain (%q)", err, s)
+				}
+			}
+		})
+	}
+}
+
+//go:embed testdata/policies
+var embeddedPolicies embed.FS
+
+func Test_PolicyNewFromFS(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"build": [{"id": "https://github.com/actions/runner/github-hosted",
+		"name": "github_actions_level_3", "slsa_level": 3}]}}`)
+	project := []byte(`{"format": 1, "package": {"name": "package_name"},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`)
+	tests := []struct {
+		name        string
+		fsys        fs.FS
+		orgPath     string
+		projectGlob string
+		errPath     string
+		expected    error
+	}{
+		{
+			name:        "embed fs",
+			fsys:        embeddedPolicies,
+			orgPath:     "testdata/policies/org.json",
+			projectGlob: "testdata/policies/*.json",
+		},
+		{
+			name:        "os dir fs",
+			fsys:        os.DirFS("testdata/policies"),
+			orgPath:     "org.json",
+			projectGlob: "*.json",
+		},
+		{
+			name: "map fs",
+			fsys: fstest.MapFS{
+				"org.json":                      {Data: org},
+				"projects/echo-server.json":     {Data: project},
+				"projects/notes/echo-test.json": {Data: project},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/*.json",
+		},
+		{
+			name: "invalid project policy",
+			fsys: fstest.MapFS{
+				"org.json":                  {Data: org},
+				"projects/echo-server.json": {Data: project},
+				"projects/echo-client.json": {Data: []byte(`{"format": 1, "package": {"name": "package_name1"}}`)},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/*.json",
+			errPath:     "projects/echo-client.json",
+			expected:    errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid org policy",
+			fsys: fstest.MapFS{
+				"policies/org.json":         {Data: []byte(`{"format": 1, "roots": {"build": [{"unknown": "value"}]}}`)},
+				"projects/echo-server.json": {Data: project},
+			},
+			orgPath:     "policies/org.json",
+			projectGlob: "projects/*.json",
+			errPath:     "policies/org.json",
+			expected:    errs.ErrorInvalidField,
+		},
+		{
+			name: "org policy not found",
+			fsys: fstest.MapFS{
+				"projects/echo-server.json": {Data: project},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/*.json",
+			errPath:     "org.json",
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name: "invalid glob",
+			fsys: fstest.MapFS{
+				"org.json":                  {Data: org},
+				"projects/echo-server.json": {Data: project},
+			},
+			orgPath:     "org.json",
+			projectGlob: "projects/[.json",
+			expected:    errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNewFromFS(tt.fsys, tt.orgPath, tt.projectGlob, newPackageHelper("registry"))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.errPath) {
+					t.Fatalf("error (%q) does not contain (%q)", err, tt.errPath)
+				}
+				return
+			}
+			verifier := newAttestationVerifier(common.NewAttestationVerifier(digests, "package_name",
+				"https://github.com/actions/runner/github-hosted", "source_uri"))
+			result := pol.Evaluate(digests, "package_name", RequestOption{}, AttestationVerificationOption{Verifier: verifier})
+			if diff := cmp.Diff(nil, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PolicyNewFromIterator(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"build": [{"id": "https://github.com/actions/runner/github-hosted",
+		"name": "github_actions_level_3", "slsa_level": 3}]}}`)
+	project := []byte(`{"format": 1, "package": {"name": "package_name"},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`)
+	tests := []struct {
+		name     string
+		files    map[string][]byte
+		errPath  string
+		expected error
+	}{
+		{
+			name: "nested project policies",
+			files: map[string][]byte{
+				"org.json":                      org,
+				"projects/echo-server.json":     project,
+				"projects/notes/echo-test.json": bytes.Replace(project, []byte(`"package_name"`), []byte(`"package_name1"`), 1),
+			},
+		},
+		{
+			name: "invalid project policy",
+			files: map[string][]byte{
+				"org.json":                  org,
+				"projects/echo-server.json": project,
+				"projects/echo-client.json": []byte(`{"format": 1, "package": {"name": "package_name1"}}`),
+			},
+			// NOTE: the error identifies the policy by its ID in the iterator.
+			errPath:  "projects/echo-client.json: ",
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				p := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(p, content, 0o600); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			projects, err := files.NewPolicyIterator(dir, files.WithExclude("org.json"))
+			if err != nil {
+				t.Fatalf("failed to create iterator: %v", err)
+			}
+			org, err := os.Open(filepath.Join(dir, "org.json"))
+			if err != nil {
+				t.Fatalf("failed to open org policy: %v", err)
+			}
+			pol, err := PolicyNewFromIterator(org, projects, newPackageHelper("registry"))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.errPath) {
+					t.Fatalf("error (%q) does not contain (%q)", err, tt.errPath)
+				}
+				return
+			}
+			verifier := newAttestationVerifier(common.NewAttestationVerifier(digests, "package_name",
+				"https://github.com/actions/runner/github-hosted", "source_uri"))
+			result := pol.Evaluate(digests, "package_name", RequestOption{}, AttestationVerificationOption{Verifier: verifier})
+			if diff := cmp.Diff(nil, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_MaxPolicySize(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3}]}}`
+	project := `{"format": 1, "package": {"name": "package_name"},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"},
+			"max_age_days": 30}}`
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		contains string
+		expected error
+	}{
+		{
+			name: "default size",
+		},
+		{
+			name:    "files within size",
+			options: []PolicyOption{SetMaxPolicySize(int64(len(project)))},
+		},
+		{
+			name:     "org larger than size",
+			options:  []PolicyOption{SetMaxPolicySize(int64(len(org) - 1))},
+			contains: "[organization]",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "project larger than size",
+			options:  []PolicyOption{SetMaxPolicySize(int64(len(org)))},
+			contains: "[projects]",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid size",
+			options:  []PolicyOption{SetMaxPolicySize(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))),
+				common.NewBytesIterator([][]byte{[]byte(project)}), newPackageHelper("registry"), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.contains != "" && !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("error (%v) does not contain (%q)", err, tt.contains)
+			}
+		})
+	}
+}
+
+// countingIterator counts the policy files read from an iterator.
+type countingIterator struct {
+	iterator.ReadCloserIterator
+	count int
+}
+
+func (iter *countingIterator) Next() io.ReadCloser {
+	iter.count++
+	return iter.ReadCloserIterator.Next()
+}
+
+func Test_MaxPolicies(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3}]}}`
+	project := func(name string) []byte {
+		return []byte(`{"format": 1, "package": {"name": "` + name + `"},
+			"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"}}}`)
+	}
+	projects := [][]byte{project("package_name1"), project("package_name2"), project("package_name3")}
+	tests := []struct {
+		name     string
+		options  []PolicyOption
+		read     int
+		expected error
+	}{
+		{
+			name: "default count",
+			read: 3,
+		},
+		{
+			name:    "files within count",
+			options: []PolicyOption{SetMaxPolicies(3)},
+			read:    3,
+		},
+		{
+			name:     "more files than count",
+			options:  []PolicyOption{SetMaxPolicies(1)},
+			read:     1,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid count",
+			options:  []PolicyOption{SetMaxPolicies(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			iter := &countingIterator{ReadCloserIterator: common.NewBytesIterator(projects)}
+			_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), iter, newPackageHelper("registry"), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil && tt.read != 0 && !strings.Contains(err.Error(), "maximum number of policy files") {
+				t.Fatalf("error (%v) does not name the limit", err)
+			}
+			// The files beyond the limit are not read.
+			if diff := cmp.Diff(tt.read, iter.count); diff != "" {
+				t.Fatalf("unexpected files read (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PackageRequirements(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3},
+		{"id": "https://cloudbuild.googleapis.com/GoogleHostedWorker", "name": "google_cloud_build_level_2", "slsa_level": 2}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "package": {"name": "package_name2", "environment": {"any_of": ["dev", "prod"]}},
+			"build": {"require_slsa_builders": {"any_of": ["github_actions_level_3", "google_cloud_build_level_2"]},
+				"repository": {"uri": "source_uri2"}, "base_images": {"any_of": ["docker.io/library/alpine"]}, "max_age_days": 30}}`),
+		[]byte(`{"format": 1, "package": {"name": "package_name1"},
+			"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri1"}}}`),
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewBytesIterator(projects), newPackageHelper("registry"))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	if diff := cmp.Diff([]string{"package_name1", "package_name2"}, pol.PackageNames()); diff != "" {
+		t.Fatalf("unexpected names (-want +got): \n%s", diff)
+	}
+	tests := []struct {
+		name         string
+		packageName  string
+		requirements PackageRequirements
+		expected     error
+	}{
+		{
+			name:        "single builder",
+			packageName: "package_name1",
+			requirements: PackageRequirements{
+				Name:          "package_name1",
+				Builders:      []string{"github_actions_level_3"},
+				RequiredLevel: 3,
+				Repositories:  []string{"source_uri1"},
+			},
+		},
+		{
+			name:        "multiple builders",
+			packageName: "package_name2",
+			requirements: PackageRequirements{
+				Name:          "package_name2",
+				Environments:  []string{"dev", "prod"},
+				Builders:      []string{"github_actions_level_3", "google_cloud_build_level_2"},
+				RequiredLevel: 2,
+				Repositories:  []string{"source_uri2"},
+				BaseImages:    []string{"docker.io/library/alpine"},
+				MaxAgeDays:    common.AsPointer(30),
+			},
+		},
+		{
+			name:        "unknown package",
+			packageName: "package_name3",
+			expected:    errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			requirements, err := pol.PackageRequirements(tt.packageName)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.requirements, requirements); diff != "" {
+				t.Fatalf("unexpected requirements (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			// Modifying the requirements must not change the policy.
+			requirements.Builders[0] = "modified"
+			if len(requirements.Environments) > 0 {
+				requirements.Environments[0] = "modified"
+			}
+			if len(requirements.BaseImages) > 0 {
+				requirements.BaseImages[0] = "modified"
+			}
+			if requirements.MaxAgeDays != nil {
+				*requirements.MaxAgeDays = 0
+			}
+			requirements, _ = pol.PackageRequirements(tt.packageName)
+			if diff := cmp.Diff(tt.requirements, requirements); diff != "" {
+				t.Fatalf("unexpected requirements (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_DefaultBuildRequirements(t *testing.T) {
+	t.Parallel()
+	roots := `"roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3},
+		{"id": "https://cloudbuild.googleapis.com/GoogleHostedWorker", "name": "google_cloud_build_level_2", "slsa_level": 2}]}`
+	tests := []struct {
+		name         string
+		org          string
+		project      string
+		requirements PackageRequirements
+		expected     error
+	}{
+		{
+			name:    "default builder",
+			org:     `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_builder": "github_actions_level_3"}}}`,
+			project: `{"format": 1, "package": {"name": "package_name"}, "build": {"repository": {"uri": "source_uri"}}}`,
+			requirements: PackageRequirements{
+				Name:          "package_name",
+				Builders:      []string{"github_actions_level_3"},
+				RequiredLevel: 3,
+				Repositories:  []string{"source_uri"},
+			},
+		},
+		{
+			name: "project builder",
+			org:  `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_builder": "github_actions_level_3"}}}`,
+			project: `{"format": 1, "package": {"name": "package_name"},
+				"build": {"require_slsa_builder": "google_cloud_build_level_2", "repository": {"uri": "source_uri"}}}`,
+			requirements: PackageRequirements{
+				Name:          "package_name",
+				Builders:      []string{"google_cloud_build_level_2"},
+				RequiredLevel: 2,
+				Repositories:  []string{"source_uri"},
+			},
+		},
+		{
+			name: "project builders",
+			org:  `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_builder": "github_actions_level_3"}}}`,
+			project: `{"format": 1, "package": {"name": "package_name"},
+				"build": {"require_slsa_builders": {"any_of": ["google_cloud_build_level_2"]}, "repository": {"uri": "source_uri"}}}`,
+			requirements: PackageRequirements{
+				Name:          "package_name",
+				Builders:      []string{"google_cloud_build_level_2"},
+				RequiredLevel: 2,
+				Repositories:  []string{"source_uri"},
+			},
+		},
+		{
+			name:     "no defaults",
+			org:      `{"format": 1, ` + roots + `}`,
+			project:  `{"format": 1, "package": {"name": "package_name"}, "build": {"repository": {"uri": "source_uri"}}}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "unknown default builder",
+			org:      `{"format": 1, ` + roots + `, "defaults": {"build": {"require_slsa_builder": "unknown_builder"}}}`,
+			project:  `{"format": 1, "package": {"name": "package_name"}, "build": {"repository": {"uri": "source_uri"}}}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(tt.org))),
+				common.NewBytesIterator([][]byte{[]byte(tt.project)}), newPackageHelper("registry"))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			requirements, err := pol.PackageRequirements("package_name")
+			if err != nil {
+				t.Fatalf("failed to get requirements: %v", err)
+			}
+			if diff := cmp.Diff(tt.requirements, requirements); diff != "" {
+				t.Fatalf("unexpected requirements (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Trace(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3},
+		{"id": "https://cloudbuild.googleapis.com/GoogleHostedWorker", "name": "google_cloud_build_level_3", "slsa_level": 3}]}}`)
+	project := []byte(`{"format": 1, "package": {"name": "package_name", "environment": {"any_of": ["dev", "prod"]}},
+		"build": {"require_slsa_builders": {"any_of": ["github_actions_level_3", "google_cloud_build_level_3"]},
+		"repository": {"uri": "source_uri"}}}`)
+	tests := []struct {
+		name        string
+		packageName string
+		environment *string
+		builderID   string
+		options     []EvaluationOption
+		expected    error
+		steps       []trace.Step
+	}{
+		{
+			name:        "selected builder",
+			packageName: "package_name",
+			environment: common.AsPointer("prod"),
+			builderID:   "https://cloudbuild.googleapis.com/GoogleHostedWorker",
+			options:     []EvaluationOption{WithTrace()},
+			steps: []trace.Step{
+				{
+					Package: "package_name", RootID: "github_actions_level_3",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonVerifierFailure,
+				},
+				{
+					Package: "package_name", RootID: "google_cloud_build_level_3",
+					Outcome: trace.OutcomeSelected, Reason: trace.ReasonVerified,
+				},
+			},
+		},
+		{
+			name:        "verifier failure",
+			packageName: "package_name",
+			environment: common.AsPointer("prod"),
+			builderID:   "https://github.com/actions/runner/self-hosted",
+			options:     []EvaluationOption{WithTrace()},
+			expected:    errs.ErrorVerification,
+			steps: []trace.Step{
+				{
+					Package: "package_name", RootID: "github_actions_level_3",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonVerifierFailure,
+				},
+				{
+					Package: "package_name", RootID: "google_cloud_build_level_3",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonVerifierFailure,
+				},
+			},
+		},
+		{
+			name:        "environment mismatch",
+			packageName: "package_name",
+			environment: common.AsPointer("staging"),
+			builderID:   "https://github.com/actions/runner/github-hosted",
+			options:     []EvaluationOption{WithTrace()},
+			expected:    errs.ErrorNotFound,
+			steps: []trace.Step{
+				{
+					Package: "package_name", Environments: []string{"dev", "prod"},
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonEnvironmentMismatch,
+				},
+			},
+		},
+		{
+			name:        "package not found",
+			packageName: "other_name",
+			environment: common.AsPointer("prod"),
+			builderID:   "https://github.com/actions/runner/github-hosted",
+			options:     []EvaluationOption{WithTrace()},
+			expected:    errs.ErrorNotFound,
+			steps: []trace.Step{
+				{
+					Package: "other_name",
+					Outcome: trace.OutcomeRejected, Reason: trace.ReasonPackageNotFound,
+				},
+			},
+		},
+		{
+			name:        "no trace",
+			packageName: "package_name",
+			environment: common.AsPointer("prod"),
+			builderID:   "https://github.com/actions/runner/github-hosted",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(org)),
+				common.NewBytesIterator([][]byte{project}), newPackageHelper("registry"))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			verifier := newAttestationVerifier(common.NewAttestationVerifier(digests, tt.packageName, tt.builderID, "source_uri"))
+			result := pol.Evaluate(digests, tt.packageName, RequestOption{Environment: tt.environment},
+				AttestationVerificationOption{Verifier: verifier}, tt.options...)
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			tr := result.Trace()
+			if tt.steps == nil {
+				if tr != nil {
+					t.Fatalf("unexpected trace: %v", tr)
+				}
+				return
+			}
+			if tr == nil {
+				t.Fatalf("trace is nil")
+			}
+			// NOTE: the error details are not part of the contract.
+			if diff := cmp.Diff(tt.steps, tr.Steps, cmpopts.IgnoreFields(trace.Step{}, "Error")); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			for i := range tr.Steps {
+				if tr.Steps[i].Outcome == trace.OutcomeRejected && tr.Steps[i].Error == "" {
+					t.Fatalf("step %d has no error", i)
+				}
+			}
+		})
+	}
+}
+
+// flakyVerifier fails the first calls of each method
+// with err, then forwards the calls to verifier.
+type flakyVerifier struct {
+	verifier        AttestationVerifier
+	failures        int
+	err             error
+	buildCalls      int
+	baseImagesCalls int
+}
+
+func (v *flakyVerifier) VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID, sourceURI string,
+	opts AttestationVerifierBuildOptions) error {
+	v.buildCalls++
+	if v.buildCalls <= v.failures {
+		return v.err
+	}
+	return v.verifier.VerifyBuildAttestation(digests, policyPackageName, builderID, sourceURI, opts)
+}
+
+func (v *flakyVerifier) BaseImages(digests intoto.DigestSet, policyPackageName string) ([]string, error) {
+	v.baseImagesCalls++
+	if v.baseImagesCalls <= v.failures {
+		return nil, v.err
+	}
+	return v.verifier.BaseImages(digests, policyPackageName)
+}
+
+func Test_Retry(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	org := []byte(`{"format": 1, "roots": {"build": [{"id": "https://github.com/actions/runner/github-hosted",
+		"name": "github_actions_level_3", "slsa_level": 3}]}}`)
+	project := []byte(`{"format": 1, "package": {"name": "package_name"},
+		"build": {"require_slsa_builder": "github_actions_level_3", "repository": {"uri": "source_uri"},
+		"base_images": {"any_of": ["docker.io/library/alpine"]}}}`)
+	transient := errs.TransientErrorNew(errors.New("503 service unavailable"), 0)
+	tests := []struct {
+		name            string
+		retry           *retry.Policy
+		failures        int
+		err             error
+		buildCalls      int
+		baseImagesCalls int
+		expected        error
+	}{
+		{
+			name:            "transient errors retried",
+			retry:           &retry.Policy{MaxAttempts: 3},
+			failures:        2,
+			err:             transient,
+			buildCalls:      3,
+			baseImagesCalls: 3,
+		},
+		{
+			name:       "transient error without retry",
+			failures:   1,
+			err:        transient,
+			buildCalls: 1,
+			expected:   errs.ErrorVerification,
+		},
+		{
+			name:       "attempts exhausted",
+			retry:      &retry.Policy{MaxAttempts: 2},
+			failures:   2,
+			err:        transient,
+			buildCalls: 2,
+			expected:   errs.ErrorVerification,
+		},
+		{
+			name:       "denial not retried",
+			retry:      &retry.Policy{MaxAttempts: 3},
+			failures:   1,
+			err:        fmt.Errorf("%w: no provenance", errs.ErrorVerification),
+			buildCalls: 1,
+			expected:   errs.ErrorVerification,
+		},
+		{
+			name:     "invalid retry policy",
+			retry:    &retry.Policy{MaxAttempts: 2, InitialBackoff: -time.Second},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader(org)),
+				common.NewBytesIterator([][]byte{project}), newPackageHelper("registry"))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			verifier := &flakyVerifier{
+				verifier: newAttestationVerifier(common.NewAttestationVerifierWithBaseImages(digests, "package_name",
+					"https://github.com/actions/runner/github-hosted", "source_uri", []string{"docker.io/library/alpine"})),
+				failures: tt.failures,
+				err:      tt.err,
+			}
+			result := pol.Evaluate(digests, "package_name", RequestOption{},
+				AttestationVerificationOption{Verifier: verifier, Retry: tt.retry})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.buildCalls, verifier.buildCalls); diff != "" {
+				t.Fatalf("unexpected build calls (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.baseImagesCalls, verifier.baseImagesCalls); diff != "" {
+				t.Fatalf("unexpected base images calls (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+// recordingHandler records the messages of the log records.
+type recordingHandler struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, r.Message)
+	return nil
+}
+
+func Test_SetLogger(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3},
+		{"id": "https://cloudbuild.googleapis.com/GoogleHostedWorker", "name": "google_cloud_build_level_2", "slsa_level": 2}]}}`
+	projects := [][]byte{
+		[]byte(`{"format": 1, "package": {"name": "package_name"},
+			"build": {"require_slsa_builders": {"any_of": ["github_actions_level_3", "google_cloud_build_level_2"]},
+				"repository": {"uri": "source_uri"}}}`),
+	}
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name      string
+		builderID string
+		messages  []string
+		expected  error
+	}{
+		{
+			name:      "allow",
+			builderID: "https://cloudbuild.googleapis.com/GoogleHostedWorker",
+			messages: []string{
+				"policy file parsed",
+				"policy file parsed",
+				"package matched",
+				"root considered",
+				"verifier invoked",
+				"verifier result",
+				"root considered",
+				"verifier invoked",
+				"verifier result",
+				"policy decision",
+			},
+		},
+		{
+			name:      "deny",
+			builderID: "other_builder_id",
+			messages: []string{
+				"policy file parsed",
+				"policy file parsed",
+				"package matched",
+				"root considered",
+				"verifier invoked",
+				"verifier result",
+				"root considered",
+				"verifier invoked",
+				"verifier result",
+				"policy decision",
+			},
+			expected: errs.ErrorVerification,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			handler := &recordingHandler{}
+			pol, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewBytesIterator(projects),
+				newPackageHelper("registry"), SetLogger(slog.New(handler)))
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := pol.Evaluate(digests, "package_name", RequestOption{}, AttestationVerificationOption{
+				Verifier: newAttestationVerifier(common.NewAttestationVerifier(digests, "package_name", tt.builderID, "source_uri")),
+			})
+			if diff := cmp.Diff(tt.expected, result.Error(), cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.messages, handler.messages); diff != "" {
+				t.Fatalf("unexpected messages (-want +got): \n%s", diff)
+			}
+		})
+	}
+	// A nil logger is rejected.
+	_, err := PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewBytesIterator(projects),
+		newPackageHelper("registry"), SetLogger(nil))
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// Parsing without workers is rejected.
+	_, err = PolicyNew(io.NopCloser(bytes.NewReader([]byte(org))), common.NewBytesIterator(projects),
+		newPackageHelper("registry"), SetParseWorkers(0))
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
+
+func Test_PolicyCache(t *testing.T) {
+	t.Parallel()
+	org := `{"format": 1, "roots": {"build": [
+		{"id": "https://github.com/actions/runner/github-hosted", "name": "github_actions_level_3", "slsa_level": 3}]}}`
+	project1 := []byte(`{"format": 1, "package": {"name": "package_name1"},
+		"build": {"require_slsa_builders": {"any_of": ["github_actions_level_3"]}, "repository": {"uri": "source_uri"}}}`)
+	project2 := []byte(`{"format": 1, "package": {"name": "package_name2"},
+		"build": {"require_slsa_builders": {"any_of": ["github_actions_level_3"]}, "repository": {"uri": "source_uri"}}}`)
+	digests1 := intoto.DigestSet{"sha256": "3c9683017f9e4bf33d0fbedd26bf143fd72de9b9dd145441b75f0604047ea28e"}
+	digests2 := intoto.DigestSet{"sha256": "0537d481f73a757334328052da3af9626ced97028e20b849f6115c22cd765197"}
+	cache := PolicyCacheNew(newPackageHelper("registry"))
+	cache.now = newFakeClock(time.Second)
+	steps := []struct {
+		name     string
+		digests  intoto.DigestSet
+		project  []byte
+		packages []string
+		reused   bool
+		hits     uint64
+		misses   uint64
+		expected error
+	}{
+		{
+			name:     "first build",
+			digests:  digests1,
+			project:  project1,
+			packages: []string{"package_name1"},
+			misses:   1,
+		},
+		{
+			name:     "same digests",
+			reused:   true,
+			digests:  digests1,
+			project:  project2,
+			packages: []string{"package_name1"},
+			hits:     1,
+			misses:   1,
+		},
+		{
+			name:     "different digests",
+			digests:  digests2,
+			project:  project2,
+			packages: []string{"package_name2"},
+			hits:     1,
+			misses:   2,
+		},
+		{
+			name:     "invalid policy",
+			digests:  intoto.DigestSet{"sha256": "89dc6ae7f06a9f46b565af03eab0ece0bf6024d3659b7e3a1d03573cfeb0b59d"},
+			project:  []byte(`{"format": 1`),
+			hits:     1,
+			misses:   3,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "previous policy kept",
+			reused:   true,
+			digests:  digests2,
+			project:  project1,
+			packages: []string{"package_name2"},
+			hits:     2,
+			misses:   3,
+		},
+		{
+			name:     "empty digests",
+			project:  project1,
+			hits:     2,
+			misses:   3,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	var previous *Policy
+	for _, step := range steps {
+		pol, err := cache.Get(step.digests, io.NopCloser(bytes.NewReader([]byte(org))),
+			common.NewBytesIterator([][]byte{step.project}))
+		if diff := cmp.Diff(step.expected, err, cmpopts.EquateErrors()); diff != "" {
+			t.Fatalf("%s: unexpected err (-want +got): \n%s", step.name, diff)
+		}
+		stats := cache.Stats()
+		if diff := cmp.Diff(step.hits, stats.Hits); diff != "" {
+			t.Fatalf("%s: unexpected hits (-want +got): \n%s", step.name, diff)
+		}
+		if diff := cmp.Diff(step.misses, stats.Misses); diff != "" {
+			t.Fatalf("%s: unexpected misses (-want +got): \n%s", step.name, diff)
+		}
+		if err != nil {
+			continue
+		}
+		if diff := cmp.Diff(step.packages, pol.PackageNames()); diff != "" {
+			t.Fatalf("%s: unexpected packages (-want +got): \n%s", step.name, diff)
+		}
+		if diff := cmp.Diff(step.reused, pol == previous); diff != "" {
+			t.Fatalf("%s: unexpected reuse (-want +got): \n%s", step.name, diff)
+		}
+		previous = pol
+	}
+	if diff := cmp.Diff(time.Unix(2, 0), cache.Stats().LastBuild); diff != "" {
+		t.Fatalf("unexpected last build (-want +got): \n%s", diff)
+	}
+}
+
+func Test_EvaluateBatch(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	packageDesc := intoto.PackageDescriptor{
+		Name:     "package_name",
+		Registry: "registry",
+	}
+	builderID := "builder_id"
+	sourceURI := "source_uri"
+	org := organization.Policy{
+		Format: 1,
+		Roots: organization.Roots{
+			Build: []organization.Root{
+				{
+					ID:        builderID,
+					Name:      "builder_name",
+					SlsaLevel: common.AsPointer(3),
+				},
+			},
+		},
+	}
+	projects := []project.Policy{
+		{
+			Format: 1,
+			Package: project.Package{
+				Name: packageDesc.Name,
+			},
+			BuildRequirements: project.BuildRequirements{
+				RequireSlsaBuilder: "builder_name",
+				Repository: project.Repository{
+					URI: sourceURI,
+				},
+			},
+		},
+	}
+	orgContent, err := json.Marshal(org)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	policies := make([][]byte, len(projects))
+	for i := range projects {
+		content, err := json.Marshal(projects[i])
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		policies[i] = content
+	}
+	pol, err := PolicyNew(io.NopCloser(bytes.NewReader(orgContent)), common.NewBytesIterator(policies),
+		newPackageHelper(packageDesc.Registry))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	// NOTE: the verifier fails with ErrorVerification on any other digests.
+	verifier := newAttestationVerifier(common.NewAttestationVerifier(digests, packageDesc.Name, builderID, sourceURI))
+	requests := []EvaluationRequest{
+		{Digests: digests, PolicyPackageName: packageDesc.Name},
+		{Digests: intoto.DigestSet{"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c"},
+			PolicyPackageName: packageDesc.Name},
+		{Digests: digests, PolicyPackageName: "other_package_name"},
+		{Digests: intoto.DigestSet{}, PolicyPackageName: packageDesc.Name},
+		{Digests: intoto.DigestSet{"SHA256": strings.ToUpper(digests["sha256"])}, PolicyPackageName: packageDesc.Name},
+	}
+	// categories are the categories of the errors of the requests.
+	categories := []string{"", "verification", "not_found", "invalid_field", ""}
+	tests := []struct {
+		name     string
+		verifier AttestationVerifier
+		workers  int
+		expected error
+	}{
+		{
+			name:     "default workers",
+			verifier: verifier,
+		},
+		{
+			name:     "concurrent workers",
+			verifier: verifier,
+			workers:  3,
+		},
+		{
+			name:     "nil verifier",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "negative workers",
+			verifier: verifier,
+			workers:  -1,
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			results, err := pol.EvaluateBatch(requests, BatchOptions{
+				Verifier: tt.verifier,
+				Workers:  tt.workers,
+			})
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			got := make([]string, len(results))
+			for i := range results {
+				if err := results[i].Error(); err != nil {
+					got[i] = errs.Category(err)
+					continue
+				}
+				if diff := cmp.Diff(3, results[i].Level()); diff != "" {
+					t.Fatalf("unexpected level (-want +got): \n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(categories, got); diff != "" {
+				t.Fatalf("unexpected categories (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
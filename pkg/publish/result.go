@@ -2,19 +2,28 @@ package publish
 
 import (
 	"fmt"
+	"maps"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/trace"
 )
 
 // PolicyEvaluationResult defines the result of policy evaluation.
 type PolicyEvaluationResult struct {
 	level       int
+	builderID   string
+	builderName string
+	sourceURI   string
 	err         error
 	packageDesc intoto.PackageDescriptor
 	digests     intoto.DigestSet
 	environment *string
 	evaluated   bool
+	baseImages  []string
+	telemetry   *telemetry
+	// trace records the candidates considered, if requested.
+	trace *trace.Trace
 }
 
 // Attestation creates a publish attestation.
@@ -37,6 +46,18 @@ func (r PolicyEvaluationResult) AttestationNew(options ...AttestationCreationOpt
 		// Set SLSA build level.
 		SetSlsaBuildLevel(r.level),
 	}
+	// Set the builder, if known.
+	if r.builderID != "" {
+		opts = append(opts, SetBuilder(r.builderID, r.builderName, r.sourceURI))
+	}
+	// Set the base images, if known.
+	if len(r.baseImages) > 0 {
+		opts = append(opts, SetBaseImages(r.baseImages...))
+	}
+	// Record the telemetry, in case the caller asks for it.
+	if r.telemetry != nil {
+		opts = append(opts, setTelemetry(*r.telemetry))
+	}
 	// Enter safe mode.
 	opts = append(opts, EnterSafeMode())
 	// Add caller options.
@@ -52,6 +73,51 @@ func (r PolicyEvaluationResult) Error() error {
 	return r.err
 }
 
+// Trace returns the project policy and builders considered by the
+// evaluation, in order, and why each was rejected, including if the
+// evaluation failed. It is nil unless WithTrace() was passed to Evaluate().
+// The returned value is a copy and may be modified by the caller.
+func (r PolicyEvaluationResult) Trace() *trace.Trace {
+	return r.trace.Clone()
+}
+
+// Level returns the SLSA build level the package was verified at.
+func (r PolicyEvaluationResult) Level() int {
+	return r.level
+}
+
+// BuilderID returns the ID of the trusted builder
+// the package's provenance was verified against.
+// It is empty if the builder is identified by its
+// certificate identity, in which case the attestation
+// does not record the builder.
+func (r PolicyEvaluationResult) BuilderID() string {
+	return r.builderID
+}
+
+// BuilderName returns the name of the trusted builder
+// the package's provenance was verified against.
+func (r PolicyEvaluationResult) BuilderName() string {
+	return r.builderName
+}
+
+// SourceURI returns the source URI the
+// package's provenance was verified against.
+func (r PolicyEvaluationResult) SourceURI() string {
+	return r.sourceURI
+}
+
+// Digests returns the digests of the evaluated package.
+func (r PolicyEvaluationResult) Digests() intoto.DigestSet {
+	return maps.Clone(r.digests)
+}
+
+// BaseImages returns the approved base images the package
+// was built from, if the policy requires them.
+func (r PolicyEvaluationResult) BaseImages() []string {
+	return append([]string{}, r.baseImages...)
+}
+
 func (r PolicyEvaluationResult) isValid() error {
 	if !r.evaluated {
 		return fmt.Errorf("%w: evaluation result not ready", errs.ErrorInternal)
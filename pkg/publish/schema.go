@@ -0,0 +1,18 @@
+package publish
+
+import (
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/organization"
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/project"
+)
+
+// OrganizationSchema returns the JSON Schema the organization
+// policy is validated against, e.g. for editors and linters.
+func OrganizationSchema() []byte {
+	return organization.Schema()
+}
+
+// ProjectSchema returns the JSON Schema the project
+// policies are validated against.
+func ProjectSchema() []byte {
+	return project.Schema()
+}
@@ -0,0 +1,191 @@
+package publish
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/semver"
+)
+
+// Validate checks the internal consistency of the attestation, without
+// any input, e.g. so that registries can lint attestations at upload:
+//   - the statement and predicate types,
+//   - the digests of every subject, not only of the verified one,
+//   - the creation time,
+//   - the package descriptor, and that every subject is the package,
+//     see RejectForeignSubjects(),
+//   - the types and values of the properties this library writes,
+//     and of the author and decision details.
+//
+// It does not verify the signatures of a DSSE envelope. All the
+// violations are returned, joined, and wrap errs.ErrorInvalidField.
+// Unknown reserved properties are reported by Warnings().
+func (v *Verification) Validate() error {
+	var allErrs []error
+	add := func(err error) {
+		if err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	att := &v.attestation
+	// Types.
+	if att.Header.Type != statementType {
+		add(fmt.Errorf("%w: statement type (%q) != intoto type (%q)", errs.ErrorInvalidField,
+			att.Header.Type, statementType))
+	}
+	if att.Header.PredicateType != predicateType {
+		add(fmt.Errorf("%w: predicate type (%q) != publish type (%q)", errs.ErrorInvalidField,
+			att.Header.PredicateType, predicateType))
+	}
+	// Subjects.
+	if len(att.Header.Subjects) == 0 {
+		add(fmt.Errorf("%w: no subjects in attestation", errs.ErrorInvalidField))
+	}
+	for i := range att.Header.Subjects {
+		add(validateSubject(i, att.Header.Subjects[i]))
+	}
+	// Creation time.
+	if _, err := intoto.ParseTime(att.Predicate.CreationTime); err != nil {
+		add(fmt.Errorf("creation time: %w", err))
+	}
+	// Package.
+	packageErr := att.Predicate.Package.Validate()
+	add(packageErr)
+	add(validateAnnotation("package environment", att.Predicate.Package.Environment))
+	add(validateAnnotation("package version", att.Predicate.Package.Version))
+	// NOTE: the subjects are compared to a valid package only.
+	if packageErr == nil && len(att.Header.Subjects) > 0 {
+		add(v.rejectForeignSubjects(v.packageHelper))
+	}
+	// Author.
+	if author := att.Predicate.Author; author != nil {
+		if author.ID == "" {
+			add(fmt.Errorf("%w: author ID is empty", errs.ErrorInvalidField))
+		}
+		if _, err := semver.Parse(author.Version); err != nil {
+			add(fmt.Errorf("%w: author %v", errs.ErrorInvalidField, err))
+		}
+	}
+	// Decision details.
+	if details := att.Predicate.DecisionDetails; details != nil {
+		for i := range details.Policy {
+			add(validateDescriptor("policy", i, details.Policy[i]))
+		}
+		for i := range details.Evidence {
+			add(validateDescriptor("evidence", i, details.Evidence[i]))
+		}
+	}
+	// Properties.
+	allErrs = append(allErrs, v.validateProperties()...)
+	return errors.Join(allErrs...)
+}
+
+// validateSubject validates the digests of the subject at index i.
+func validateSubject(i int, subject intoto.Subject) error {
+	if err := intoto.ValidateSubject(subject); err != nil {
+		return fmt.Errorf("subject #%d (%q): %w", i, subject.Name, err)
+	}
+	if alg, conflicts := subject.Digests.ConflictingAlgorithm(); conflicts {
+		return fmt.Errorf("%w: subject #%d (%q): digest (%q) has conflicting values", errs.ErrorInvalidField,
+			i, subject.Name, alg)
+	}
+	return nil
+}
+
+// validateAnnotation validates an optional string of the package descriptor.
+func validateAnnotation(name, value string) error {
+	if value != "" && strings.TrimSpace(value) != value {
+		return fmt.Errorf("%w: %s (%q) has surrounding spaces", errs.ErrorInvalidField, name, value)
+	}
+	return nil
+}
+
+// validateDescriptor validates the resource descriptor at index i of the decision details.
+func validateDescriptor(kind string, i int, desc intoto.ResourceDescriptor) error {
+	if desc.Name == "" && desc.URI == "" {
+		return fmt.Errorf("%w: %s #%d has no name and no URI", errs.ErrorInvalidField, kind, i)
+	}
+	if desc.Digest != nil {
+		if err := desc.Digest.Validate(); err != nil {
+			return fmt.Errorf("%s #%d (%q): %w", kind, i, desc.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateProperties validates the properties this library writes, if present.
+func (v *Verification) validateProperties() []error {
+	props := v.attestation.Predicate.Properties
+	var allErrs []error
+	if _, exists := props[buildLevelProperty]; exists {
+		level, err := v.intProperty(buildLevelProperty)
+		if err == nil {
+			if err := validateLevel(level); err != nil {
+				allErrs = append(allErrs, fmt.Errorf("%w: property (%q): %v", errs.ErrorInvalidField,
+					buildLevelProperty, err))
+			}
+		} else {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if value, exists := props[baseImagesProperty]; exists {
+		if err := validateStrings(baseImagesProperty, value); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if value, exists := props[builderProperty]; exists {
+		if err := validateBuilder(value); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if _, exists := props[sbomProperty]; exists {
+		if _, err := v.attestation.sbom(); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	for _, name := range []string{evaluationDurationProperty, verifierCallsProperty} {
+		if _, exists := props[name]; !exists {
+			continue
+		}
+		if _, err := v.intProperty(name); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+// validateStrings validates that the value of the property
+// is a non-empty list of non-empty strings.
+func validateStrings(name string, value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return fmt.Errorf("%w: property (%q) value (%T:%v) is not a non-empty list", errs.ErrorInvalidField,
+			name, value, value)
+	}
+	for _, item := range list {
+		if s, ok := item.(string); !ok || s == "" {
+			return fmt.Errorf("%w: property (%q) item (%T:%v) is not a non-empty string", errs.ErrorInvalidField,
+				name, item, item)
+		}
+	}
+	return nil
+}
+
+// validateBuilder validates the value of the builder property.
+func validateBuilder(value interface{}) error {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: property (%q) value (%T:%v) is not an object", errs.ErrorInvalidField,
+			builderProperty, value, value)
+	}
+	for _, key := range []string{"id", "name", "sourceUri"} {
+		if s, ok := fields[key].(string); !ok || s == "" {
+			return fmt.Errorf("%w: property (%q) field (%q) value (%T:%v) is not a non-empty string",
+				errs.ErrorInvalidField, builderProperty, key, fields[key], fields[key])
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package publish
 import (
 	"fmt"
 
+	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/options"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
 )
 
@@ -15,13 +16,13 @@ type packageHelper struct {
 }
 
 func (p *packageHelper) PolicyPackageName(desc intoto.PackageDescriptor) (string, error) {
-	return desc.Registry + "/" + desc.Name, nil
+	return normalizeRegistry(desc.Registry) + "/" + desc.Name, nil
 }
 
 func (p *packageHelper) PackageDescriptor(name string) (intoto.PackageDescriptor, error) {
 	return intoto.PackageDescriptor{
 		Name:     name,
-		Registry: p.registry,
+		Registry: normalizeRegistry(p.registry),
 	}, nil
 }
 
@@ -39,3 +40,29 @@ func (v *policyValidator) ValidatePackage(pkg ValidationPackage) error {
 	}
 	return fmt.Errorf("failed to validate package: pass (%v)", v.pass)
 }
+
+// newAttestationVerifier adapts a verifier of the internal
+// packages to the AttestationVerifier interface.
+func newAttestationVerifier(verifier options.AttestationVerifier) AttestationVerifier {
+	return &attestationVerifier{verifier: verifier}
+}
+
+type attestationVerifier struct {
+	verifier options.AttestationVerifier
+}
+
+func (v *attestationVerifier) VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID, sourceURI string,
+	opts AttestationVerifierBuildOptions) error {
+	var identity *options.Identity
+	if opts.Identity != nil {
+		identity = &options.Identity{
+			Issuer:       opts.Identity.Issuer,
+			SubjectRegex: opts.Identity.SubjectRegex,
+		}
+	}
+	return v.verifier.VerifyBuildAttestation(digests, policyPackageName, builderID, identity, sourceURI, opts.MaxAge)
+}
+
+func (v *attestationVerifier) BaseImages(digests intoto.DigestSet, policyPackageName string) ([]string, error) {
+	return v.verifier.BaseImages(digests, policyPackageName)
+}
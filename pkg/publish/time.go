@@ -0,0 +1,72 @@
+package publish
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/checks"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// CreatedAfter verifies that the attestation was created at or after t.
+func CreatedAfter(t time.Time) VerificationOption {
+	return func(v *Verification) error {
+		if t.IsZero() {
+			return fmt.Errorf("%w: time is zero", errs.ErrorInvalidInput)
+		}
+		return v.addCheck(checks.Check{
+			Kind:      "CreatedAfter",
+			Key:       t.UnixNano(),
+			Value:     t.UTC().Format(time.RFC3339Nano),
+			Exclusive: true,
+			Rank:      checks.RankTime,
+			Run:       func() error { return v.createdAfter(t) },
+		})
+	}
+}
+
+func (v *Verification) createdAfter(t time.Time) error {
+	created, err := intoto.ParseTime(v.attestation.Predicate.CreationTime)
+	if err != nil {
+		return err
+	}
+	if created.Before(t) {
+		return errs.VerificationErrorNew(errs.CheckCreationTime, t.UTC().Format(time.RFC3339Nano),
+			created.UTC().Format(time.RFC3339Nano),
+			fmt.Errorf("%w: attestation creation time (%s) is before (%s)", errs.ErrorMismatch,
+				created.UTC().Format(time.RFC3339Nano), t.UTC().Format(time.RFC3339Nano)))
+	}
+	return nil
+}
+
+// CreatedBefore verifies that the attestation was created at or before t.
+func CreatedBefore(t time.Time) VerificationOption {
+	return func(v *Verification) error {
+		if t.IsZero() {
+			return fmt.Errorf("%w: time is zero", errs.ErrorInvalidInput)
+		}
+		return v.addCheck(checks.Check{
+			Kind:      "CreatedBefore",
+			Key:       t.UnixNano(),
+			Value:     t.UTC().Format(time.RFC3339Nano),
+			Exclusive: true,
+			Rank:      checks.RankTime,
+			Run:       func() error { return v.createdBefore(t) },
+		})
+	}
+}
+
+func (v *Verification) createdBefore(t time.Time) error {
+	created, err := intoto.ParseTime(v.attestation.Predicate.CreationTime)
+	if err != nil {
+		return err
+	}
+	if created.After(t) {
+		return errs.VerificationErrorNew(errs.CheckCreationTime, t.UTC().Format(time.RFC3339Nano),
+			created.UTC().Format(time.RFC3339Nano),
+			fmt.Errorf("%w: attestation creation time (%s) is after (%s)", errs.ErrorMismatch,
+				created.UTC().Format(time.RFC3339Nano), t.UTC().Format(time.RFC3339Nano)))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// TransparencyLog defines an interface to record attestations
+// in a transparency log, e.g. Rekor.
+type TransparencyLog interface {
+	// Upload records the envelope of a signed attestation
+	// and returns the ID of the log entry.
+	Upload(ctx context.Context, envelope []byte) (string, error)
+	// VerifyInclusion verifies that the envelope is recorded in the log.
+	// The envelope is the content the verification was created from.
+	VerifyInclusion(ctx context.Context, envelope []byte) error
+}
+
+// WithTransparencyLog sets the log the signed
+// attestation is uploaded to by Upload().
+func WithTransparencyLog(log TransparencyLog) AttestationCreationOption {
+	return func(a *Creation) error {
+		if log == nil {
+			return fmt.Errorf("%w: transparency log is nil", errs.ErrorInvalidInput)
+		}
+		a.tlog = log
+		return nil
+	}
+}
+
+// Upload records the envelope of the signed attestation in the
+// transparency log set by WithTransparencyLog() and returns
+// the ID of the log entry.
+func (a *Creation) Upload(ctx context.Context, envelope []byte) (string, error) {
+	if a.tlog == nil {
+		return "", fmt.Errorf("%w: no transparency log", errs.ErrorInvalidInput)
+	}
+	if len(envelope) == 0 {
+		return "", fmt.Errorf("%w: envelope is empty", errs.ErrorInvalidInput)
+	}
+	entryID, err := a.tlog.Upload(ctx, envelope)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to upload to transparency log: %w", errs.ErrorInternal, err)
+	}
+	if entryID == "" {
+		return "", fmt.Errorf("%w: transparency log returned an empty entry ID", errs.ErrorInternal)
+	}
+	return entryID, nil
+}
+
+// RequireTransparencyLog requires the attestation to be recorded in
+// the transparency log. The log is queried after all the other
+// verifications succeed, with the context passed to VerifyContext().
+func RequireTransparencyLog(log TransparencyLog) VerificationOption {
+	return func(v *Verification) error {
+		if log == nil {
+			return fmt.Errorf("%w: transparency log is nil", errs.ErrorInvalidInput)
+		}
+		if v.tlog != nil {
+			return fmt.Errorf("%w: transparency log is set more than once", errs.ErrorInvalidInput)
+		}
+		v.tlog = log
+		return nil
+	}
+}
+
+func (v *Verification) verifyInclusion(ctx context.Context) error {
+	if v.tlog == nil {
+		return nil
+	}
+	if len(v.envelope) == 0 {
+		return errs.VerificationErrorNew(errs.CheckTransparencyLog, "", "",
+			fmt.Errorf("%w: no envelope to verify in transparency log", errs.ErrorVerification))
+	}
+	if err := v.tlog.VerifyInclusion(ctx, v.envelope); err != nil {
+		return errs.VerificationErrorNew(errs.CheckTransparencyLog, "", "",
+			fmt.Errorf("%w: failed to verify inclusion in transparency log: %w", errs.ErrorVerification, err))
+	}
+	return nil
+}
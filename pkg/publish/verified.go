@@ -0,0 +1,46 @@
+package publish
+
+import (
+	"maps"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// VerifiedAttestation is a read-only view of an attestation
+// that passed verification.
+type VerifiedAttestation struct {
+	Subjects     []intoto.Subject
+	Package      intoto.PackageDescriptor
+	CreationTime time.Time
+	// Properties contains the properties of the attestation.
+	// NOTE: nested values are shared with the verification
+	// and must not be modified.
+	Properties map[string]interface{}
+}
+
+// verifiedAttestation returns the content of the attestation.
+// It must only be called once the attestation is verified.
+func (v *Verification) verifiedAttestation() (*VerifiedAttestation, error) {
+	creationTime, err := intoto.ParseTime(v.attestation.Predicate.CreationTime)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedAttestation{
+		Subjects:     copySubjects(v.attestation.Header.Subjects),
+		Package:      v.attestation.Predicate.Package,
+		CreationTime: creationTime,
+		Properties:   maps.Clone(v.attestation.Predicate.Properties),
+	}, nil
+}
+
+func copySubjects(subjects []intoto.Subject) []intoto.Subject {
+	res := make([]intoto.Subject, len(subjects))
+	for i := range subjects {
+		res[i] = intoto.Subject{
+			Name:    subjects[i].Name,
+			Digests: maps.Clone(subjects[i].Digests),
+		}
+	}
+	return res
+}
@@ -0,0 +1,347 @@
+// Package slsaprovenance verifies SLSA provenance attestations in the
+// v1 and v0.2 formats, see https://slsa.dev/spec/v1.0/provenance and
+// https://slsa.dev/provenance/v0.2. Its Verifier implements
+// publish.AttestationVerifier.
+package slsaprovenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Predicate types of the supported provenance formats.
+const (
+	PredicateTypeV1  = "https://slsa.dev/provenance/v1"
+	PredicateTypeV02 = "https://slsa.dev/provenance/v0.2"
+)
+
+// statementTypes are the in-toto statement types
+// a provenance may be wrapped in.
+var statementTypes = []string{
+	intoto.StatementType,
+	"https://in-toto.io/Statement/v0.1",
+}
+
+// Provenance contains the fields of a provenance
+// that are verified against the publish policy.
+type Provenance struct {
+	PredicateType string
+	Subjects      []intoto.Subject
+	// BuilderID is the ID of the builder, e.g.
+	// https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml@refs/tags/v1.9.0.
+	BuilderID string
+	// SourceURI is the URI of the source repository as recorded
+	// in the provenance, e.g. git+https://github.com/org/repo@refs/heads/main.
+	SourceURI string
+	// BuildTime is the start of the build, or its end if the
+	// start is not recorded. It is zero if neither is recorded.
+	BuildTime time.Time
+	// BaseImages contains the container images among the materials
+	// of the build, pinned by digest if recorded, e.g.
+	// docker.io/library/golang@sha256:... Neither provenance format
+	// tells base images from other images used by the build, so
+	// all the images are included.
+	BaseImages []string
+}
+
+type statement struct {
+	Type          string           `json:"_type"`
+	PredicateType string           `json:"predicateType"`
+	Subjects      []intoto.Subject `json:"subject"`
+	Predicate     json.RawMessage  `json:"predicate"`
+}
+
+// predicateV1 contains the fields of the v1 predicate.
+type predicateV1 struct {
+	BuildDefinition struct {
+		ExternalParameters   map[string]interface{}      `json:"externalParameters"`
+		ResolvedDependencies []intoto.ResourceDescriptor `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  *time.Time `json:"startedOn"`
+			FinishedOn *time.Time `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// predicateV02 contains the fields of the v0.2 predicate.
+type predicateV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Invocation struct {
+		ConfigSource struct {
+			URI string `json:"uri"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildStartedOn  *time.Time `json:"buildStartedOn"`
+		BuildFinishedOn *time.Time `json:"buildFinishedOn"`
+	} `json:"metadata"`
+	Materials []intoto.ResourceDescriptor `json:"materials"`
+}
+
+// ParseEnvelope verifies the signatures of a DSSE envelope
+// with verifier and parses the provenance it contains.
+func ParseEnvelope(content []byte, verifier intoto.SignatureVerifier) (*Provenance, error) {
+	envelope, err := intoto.ParseEnvelope(content)
+	if err != nil {
+		return nil, err
+	}
+	if envelope == nil {
+		return nil, fmt.Errorf("%w: provenance is not a DSSE envelope", errs.ErrorInvalidInput)
+	}
+	if err := envelope.Verify(verifier); err != nil {
+		return nil, err
+	}
+	payload, err := envelope.Statement()
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatement(payload)
+}
+
+// ParseStatement parses an in-toto statement containing a v1
+// or v0.2 provenance. The signatures are not verified.
+func ParseStatement(content []byte) (*Provenance, error) {
+	var s statement
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal statement: %w", errs.ErrorInvalidInput, err)
+	}
+	if !slices.Contains(statementTypes, s.Type) {
+		return nil, fmt.Errorf("%w: statement type (%q) is not supported", errs.ErrorInvalidField, s.Type)
+	}
+	if len(s.Subjects) == 0 {
+		return nil, fmt.Errorf("%w: statement has no subjects", errs.ErrorInvalidField)
+	}
+	prov := Provenance{
+		PredicateType: s.PredicateType,
+		Subjects:      s.Subjects,
+	}
+	switch s.PredicateType {
+	case PredicateTypeV1:
+		var pred predicateV1
+		if err := json.Unmarshal(s.Predicate, &pred); err != nil {
+			return nil, fmt.Errorf("%w: failed to unmarshal predicate: %w", errs.ErrorInvalidField, err)
+		}
+		prov.BuilderID = pred.RunDetails.Builder.ID
+		prov.SourceURI = externalSource(pred.BuildDefinition.ExternalParameters)
+		prov.BuildTime = firstTime(pred.RunDetails.Metadata.StartedOn, pred.RunDetails.Metadata.FinishedOn)
+		prov.BaseImages = images(pred.BuildDefinition.ResolvedDependencies)
+	case PredicateTypeV02:
+		var pred predicateV02
+		if err := json.Unmarshal(s.Predicate, &pred); err != nil {
+			return nil, fmt.Errorf("%w: failed to unmarshal predicate: %w", errs.ErrorInvalidField, err)
+		}
+		prov.BuilderID = pred.Builder.ID
+		prov.SourceURI = pred.Invocation.ConfigSource.URI
+		prov.BuildTime = firstTime(pred.Metadata.BuildStartedOn, pred.Metadata.BuildFinishedOn)
+		prov.BaseImages = images(pred.Materials)
+	default:
+		return nil, fmt.Errorf("%w: predicate type (%q) is not supported", errs.ErrorInvalidField, s.PredicateType)
+	}
+	if prov.BuilderID == "" {
+		return nil, fmt.Errorf("%w: builder ID is empty", errs.ErrorInvalidField)
+	}
+	return &prov, nil
+}
+
+// externalSource returns the source repository recorded in the
+// external parameters of a v1 provenance. GitHub Actions builders
+// record it as workflow.repository and the SLSA GitHub generator
+// as source.uri.
+func externalSource(params map[string]interface{}) string {
+	for _, path := range [][2]string{{"workflow", "repository"}, {"source", "uri"}} {
+		parent, ok := params[path[0]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if uri, ok := parent[path[1]].(string); ok && uri != "" {
+			return uri
+		}
+	}
+	// NOTE: some builders record the source URI as a string.
+	if uri, ok := params["source"].(string); ok {
+		return uri
+	}
+	return ""
+}
+
+// images returns the references of the container images among
+// materials, in order and without duplicates.
+func images(materials []intoto.ResourceDescriptor) []string {
+	var refs []string
+	for _, material := range materials {
+		ref, ok := imageReference(material.URI, material.Digest)
+		if ok && !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// imageReference returns the reference of the image identified by uri,
+// a docker package URL, e.g. pkg:docker/golang@1.22?platform=linux%2Famd64,
+// or an oci:// or docker:// reference. The reference is pinned by the
+// sha256 digest of the material if recorded, e.g.
+// docker.io/library/golang@sha256:...
+func imageReference(uri string, digests intoto.DigestSet) (string, bool) {
+	var repository, version string
+	switch {
+	case strings.HasPrefix(uri, "pkg:docker/"):
+		purl, qualifiers, _ := strings.Cut(strings.TrimPrefix(uri, "pkg:docker/"), "?")
+		purl, _, _ = strings.Cut(purl, "#")
+		name, v, _ := strings.Cut(purl, "@")
+		name, err := url.PathUnescape(name)
+		if err != nil || name == "" {
+			return "", false
+		}
+		if version, err = url.PathUnescape(v); err != nil {
+			return "", false
+		}
+		values, err := url.ParseQuery(qualifiers)
+		if err != nil {
+			return "", false
+		}
+		registry := strings.TrimSuffix(values.Get("repository_url"), "/")
+		if registry == "" {
+			registry = "docker.io"
+			if !strings.Contains(name, "/") {
+				name = "library/" + name
+			}
+		}
+		repository = registry + "/" + name
+	case strings.HasPrefix(uri, "oci://"), strings.HasPrefix(uri, "docker://"):
+		_, ref, _ := strings.Cut(uri, "://")
+		if ref == "" {
+			return "", false
+		}
+		repository, version = splitReference(ref)
+	default:
+		return "", false
+	}
+	if digest, exists := digests.DigestValue("sha256"); exists {
+		return repository + "@sha256:" + digest, true
+	}
+	switch {
+	case version == "":
+		return repository, true
+	case strings.Contains(version, ":"):
+		// NOTE: a digest, e.g. sha256:...
+		return repository + "@" + version, true
+	default:
+		return repository + ":" + version, true
+	}
+}
+
+// splitReference splits an image reference into its
+// repository and its tag or digest.
+func splitReference(ref string) (string, string) {
+	if repository, digest, found := strings.Cut(ref, "@"); found {
+		return repository, digest
+	}
+	// NOTE: the registry may have a port, e.g. localhost:5000/image.
+	slash := strings.LastIndex(ref, "/")
+	if colon := strings.LastIndex(ref, ":"); colon > slash {
+		return ref[:colon], ref[colon+1:]
+	}
+	return ref, ""
+}
+
+func firstTime(times ...*time.Time) time.Time {
+	for _, t := range times {
+		if t != nil {
+			return *t
+		}
+	}
+	return time.Time{}
+}
+
+// HasSubject returns true if a subject of the provenance shares at
+// least one digest algorithm with digests and the values of all the
+// algorithms they share are equal. Algorithms of digests the subject
+// does not record are ignored, since builders record a single one.
+func (p *Provenance) HasSubject(digests intoto.DigestSet) bool {
+	for _, subject := range p.Subjects {
+		if commonDigestsMatch(subject.Digests, digests) {
+			return true
+		}
+	}
+	return false
+}
+
+// commonDigestsMatch returns true if ds and digests share at least
+// one algorithm, no shared algorithm has conflicting values and
+// neither records an algorithm twice with conflicting values.
+func commonDigestsMatch(ds, digests intoto.DigestSet) bool {
+	if _, conflicts := ds.ConflictingAlgorithm(); conflicts {
+		return false
+	}
+	if _, conflicts := digests.ConflictingAlgorithm(); conflicts {
+		return false
+	}
+	common := 0
+	for alg := range digests {
+		expected, _ := digests.DigestValue(alg)
+		actual, exists := ds.DigestValue(alg)
+		if !exists {
+			continue
+		}
+		if actual != expected {
+			return false
+		}
+		common++
+	}
+	return common > 0
+}
+
+// BuilderIDMatches returns true if the builder of the provenance
+// is builderID. GitHub workflow builder IDs are compared without
+// their ref if builderID has none, e.g.
+// https://github.com/org/repo/.github/workflows/build.yml matches
+// https://github.com/org/repo/.github/workflows/build.yml@refs/tags/v1.0.0.
+func (p *Provenance) BuilderIDMatches(builderID string) bool {
+	if builderID == p.BuilderID {
+		return true
+	}
+	if !isGitHubWorkflow(p.BuilderID) {
+		return false
+	}
+	expectedPath, expectedRef, hasRef := strings.Cut(builderID, "@")
+	actualPath, actualRef, _ := strings.Cut(p.BuilderID, "@")
+	if expectedPath != actualPath {
+		return false
+	}
+	return !hasRef || expectedRef == actualRef
+}
+
+// SourceURIMatches returns true if the source of the provenance is
+// sourceURI. The scheme, the ref and the .git suffix are ignored, e.g.
+// github.com/org/repo matches git+https://github.com/org/repo.git@refs/heads/main.
+func (p *Provenance) SourceURIMatches(sourceURI string) bool {
+	return p.SourceURI != "" && normalizeSource(p.SourceURI) == normalizeSource(sourceURI)
+}
+
+func isGitHubWorkflow(builderID string) bool {
+	return strings.HasPrefix(builderID, "https://github.com/") &&
+		strings.Contains(builderID, "/.github/workflows/")
+}
+
+func normalizeSource(uri string) string {
+	uri = strings.TrimPrefix(uri, "git+")
+	uri = strings.TrimPrefix(uri, "https://")
+	uri, _, _ = strings.Cut(uri, "@")
+	uri = strings.TrimSuffix(uri, "/")
+	return strings.TrimSuffix(uri, ".git")
+}
@@ -0,0 +1,433 @@
+package slsaprovenance
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_ParseStatement(t *testing.T) {
+	t.Parallel()
+	subjects := []intoto.Subject{
+		{
+			Name: "docker.io/org/echo-server",
+			Digests: intoto.DigestSet{
+				"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4",
+			},
+		},
+	}
+	tests := []struct {
+		name       string
+		path       string
+		content    string
+		provenance *Provenance
+		expected   error
+	}{
+		{
+			name: "v1 provenance",
+			path: "testdata/provenance_v1.json",
+			provenance: &Provenance{
+				PredicateType: PredicateTypeV1,
+				Subjects:      subjects,
+				BuilderID:     "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml@refs/tags/v2.0.0",
+				SourceURI:     "https://github.com/org/echo-server",
+				BuildTime:     time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				BaseImages: []string{
+					"docker.io/library/golang@sha256:d0902bacefdde1cf45528c098d14e55d78c107def8a22d148eabd71582d7a99f",
+				},
+			},
+		},
+		{
+			name: "v0.2 provenance",
+			path: "testdata/provenance_v0.2.json",
+			provenance: &Provenance{
+				PredicateType: PredicateTypeV02,
+				Subjects:      subjects,
+				BuilderID:     "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml@refs/tags/v1.9.0",
+				SourceURI:     "git+https://github.com/org/echo-server@refs/heads/main",
+				BuildTime:     time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC),
+				BaseImages: []string{
+					"gcr.io/distroless/static@sha256:6ec5aa99dc335666e79dc64e4a6c8b89c33a543a1967f20d360922a80dd21f02",
+				},
+			},
+		},
+		{
+			name: "v1 source uri",
+			content: `{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/provenance/v1",
+				"subject": [{"digest": {"sha256": "abc"}}],
+				"predicate": {"buildDefinition": {"externalParameters": {"source": {"uri": "git+https://github.com/org/repo@refs/tags/v1"}}},
+					"runDetails": {"builder": {"id": "builder_id"}}}}`,
+			provenance: &Provenance{
+				PredicateType: PredicateTypeV1,
+				Subjects:      []intoto.Subject{{Digests: intoto.DigestSet{"sha256": "abc"}}},
+				BuilderID:     "builder_id",
+				SourceURI:     "git+https://github.com/org/repo@refs/tags/v1",
+			},
+		},
+		{
+			name: "v1 source string",
+			content: `{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/provenance/v1",
+				"subject": [{"digest": {"sha256": "abc"}}],
+				"predicate": {"buildDefinition": {"externalParameters": {"source": "git+https://github.com/org/repo"}},
+					"runDetails": {"builder": {"id": "builder_id"}}}}`,
+			provenance: &Provenance{
+				PredicateType: PredicateTypeV1,
+				Subjects:      []intoto.Subject{{Digests: intoto.DigestSet{"sha256": "abc"}}},
+				BuilderID:     "builder_id",
+				SourceURI:     "git+https://github.com/org/repo",
+			},
+		},
+		{
+			name:     "invalid json",
+			content:  `{"_type": `,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "unsupported statement type",
+			content: `{"_type": "https://in-toto.io/Statement/v2", "predicateType": "https://slsa.dev/provenance/v1",
+				"subject": [{"digest": {"sha256": "abc"}}], "predicate": {"runDetails": {"builder": {"id": "builder_id"}}}}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "unsupported predicate type",
+			content: `{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/publish/v0.1",
+				"subject": [{"digest": {"sha256": "abc"}}], "predicate": {}}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "no subjects",
+			content: `{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/provenance/v1",
+				"predicate": {"runDetails": {"builder": {"id": "builder_id"}}}}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty builder ID",
+			content: `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2",
+				"subject": [{"digest": {"sha256": "abc"}}], "predicate": {"builder": {}}}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid predicate",
+			content: `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2",
+				"subject": [{"digest": {"sha256": "abc"}}], "predicate": {"builder": "builder_id"}}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content := []byte(tt.content)
+			if tt.path != "" {
+				var err error
+				content, err = os.ReadFile(tt.path)
+				if err != nil {
+					t.Fatalf("failed to read provenance: %v", err)
+				}
+			}
+			provenance, err := ParseStatement(content)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.provenance, provenance); diff != "" {
+				t.Fatalf("unexpected provenance (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_imageReference(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{"sha256": "abc"}
+	tests := []struct {
+		name      string
+		uri       string
+		digests   intoto.DigestSet
+		reference string
+		isImage   bool
+	}{
+		{
+			name:      "docker purl",
+			uri:       "pkg:docker/golang@1.22?platform=linux%2Famd64",
+			digests:   digests,
+			reference: "docker.io/library/golang@sha256:abc",
+			isImage:   true,
+		},
+		{
+			name:      "docker purl with namespace",
+			uri:       "pkg:docker/org/image",
+			digests:   digests,
+			reference: "docker.io/org/image@sha256:abc",
+			isImage:   true,
+		},
+		{
+			name:      "docker purl with repository url",
+			uri:       "pkg:docker/distroless/static@nonroot?repository_url=gcr.io",
+			digests:   digests,
+			reference: "gcr.io/distroless/static@sha256:abc",
+			isImage:   true,
+		},
+		{
+			name:      "docker purl without digests",
+			uri:       "pkg:docker/golang@1.22",
+			reference: "docker.io/library/golang:1.22",
+			isImage:   true,
+		},
+		{
+			name:      "docker purl with digest version",
+			uri:       "pkg:docker/golang@sha256%3Adef",
+			reference: "docker.io/library/golang@sha256:def",
+			isImage:   true,
+		},
+		{
+			name:      "oci reference",
+			uri:       "oci://ghcr.io/org/image:v1",
+			digests:   digests,
+			reference: "ghcr.io/org/image@sha256:abc",
+			isImage:   true,
+		},
+		{
+			name:      "docker reference with port",
+			uri:       "docker://localhost:5000/image",
+			reference: "localhost:5000/image",
+			isImage:   true,
+		},
+		{
+			name:      "docker reference with digest",
+			uri:       "docker://gcr.io/distroless/static@sha256:def",
+			reference: "gcr.io/distroless/static@sha256:def",
+			isImage:   true,
+		},
+		{
+			name:    "git material",
+			uri:     "git+https://github.com/org/repo@refs/heads/main",
+			digests: intoto.DigestSet{"sha1": "abc"},
+		},
+		{
+			name: "npm purl",
+			uri:  "pkg:npm/left-pad@1.3.0",
+		},
+		{
+			name: "empty purl name",
+			uri:  "pkg:docker/@1.22",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reference, isImage := imageReference(tt.uri, tt.digests)
+			if diff := cmp.Diff(tt.isImage, isImage); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.reference, reference); diff != "" {
+				t.Fatalf("unexpected reference (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_HasSubject(t *testing.T) {
+	t.Parallel()
+	digest := "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4"
+	other := "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe"
+	tests := []struct {
+		name     string
+		subjects []intoto.Subject
+		digests  intoto.DigestSet
+		matches  bool
+	}{
+		{
+			name:     "same digest",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest}}},
+			digests:  intoto.DigestSet{"sha256": digest},
+			matches:  true,
+		},
+		{
+			name:     "mixed case digest",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest}}},
+			digests:  intoto.DigestSet{"SHA256": strings.ToUpper(digest)},
+			matches:  true,
+		},
+		{
+			name:     "algorithm absent from provenance",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest}}},
+			digests:  intoto.DigestSet{"sha256": digest, "sha512": "ccc"},
+			matches:  true,
+		},
+		{
+			name:     "no common algorithm",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest}}},
+			digests:  intoto.DigestSet{"sha512": "ccc"},
+		},
+		{
+			name:     "conflicting common algorithm",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest, "sha512": "ccc"}}},
+			digests:  intoto.DigestSet{"sha256": other, "sha512": "ccc"},
+		},
+		{
+			name:     "one common algorithm matches",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest, "sha512": "ccc"}}},
+			digests:  intoto.DigestSet{"sha256": digest, "sha512": "ddd"},
+		},
+		{
+			name:     "conflicting duplicate subject digests",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest, "SHA256": other}}},
+			digests:  intoto.DigestSet{"sha256": digest},
+		},
+		{
+			name:     "conflicting duplicate digests",
+			subjects: []intoto.Subject{{Digests: intoto.DigestSet{"sha256": digest}}},
+			digests:  intoto.DigestSet{"sha256": digest, "SHA256": other},
+		},
+		{
+			name: "second subject",
+			subjects: []intoto.Subject{
+				{Digests: intoto.DigestSet{"sha256": other}},
+				{Digests: intoto.DigestSet{"sha256": digest}},
+			},
+			digests: intoto.DigestSet{"sha256": digest},
+			matches: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := Provenance{Subjects: tt.subjects}
+			if diff := cmp.Diff(tt.matches, p.HasSubject(tt.digests)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_BuilderIDMatches(t *testing.T) {
+	t.Parallel()
+	workflow := "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml"
+	tests := []struct {
+		name      string
+		builderID string
+		expected  string
+		matches   bool
+	}{
+		{
+			name:      "same builder",
+			builderID: "https://cloudbuild.googleapis.com/GoogleHostedWorker",
+			expected:  "https://cloudbuild.googleapis.com/GoogleHostedWorker",
+			matches:   true,
+		},
+		{
+			name:      "different builder",
+			builderID: "https://cloudbuild.googleapis.com/GoogleHostedWorker",
+			expected:  "https://cloudbuild.googleapis.com/Other",
+		},
+		{
+			name:      "non workflow with ref",
+			builderID: "https://example.com/builder@v1",
+			expected:  "https://example.com/builder",
+		},
+		{
+			name:      "workflow without ref",
+			builderID: workflow + "@refs/tags/v1.9.0",
+			expected:  workflow,
+			matches:   true,
+		},
+		{
+			name:      "workflow with same ref",
+			builderID: workflow + "@refs/tags/v1.9.0",
+			expected:  workflow + "@refs/tags/v1.9.0",
+			matches:   true,
+		},
+		{
+			name:      "workflow with different ref",
+			builderID: workflow + "@refs/tags/v1.9.0",
+			expected:  workflow + "@refs/tags/v2.0.0",
+		},
+		{
+			name:      "provenance workflow without ref",
+			builderID: workflow,
+			expected:  workflow + "@refs/tags/v1.9.0",
+		},
+		{
+			name:      "different workflow",
+			builderID: workflow + "@refs/tags/v1.9.0",
+			expected:  "https://github.com/org/repo/.github/workflows/build.yml",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := Provenance{BuilderID: tt.builderID}
+			if diff := cmp.Diff(tt.matches, p.BuilderIDMatches(tt.expected)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_SourceURIMatches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		sourceURI string
+		expected  string
+		matches   bool
+	}{
+		{
+			name:      "same source",
+			sourceURI: "github.com/org/repo",
+			expected:  "github.com/org/repo",
+			matches:   true,
+		},
+		{
+			name:      "git scheme and ref",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/main",
+			expected:  "github.com/org/repo",
+			matches:   true,
+		},
+		{
+			name:      "https and git suffix",
+			sourceURI: "https://github.com/org/repo.git",
+			expected:  "github.com/org/repo",
+			matches:   true,
+		},
+		{
+			name:      "expected with scheme",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/main",
+			expected:  "https://github.com/org/repo",
+			matches:   true,
+		},
+		{
+			name:      "different repository",
+			sourceURI: "git+https://github.com/org/other@refs/heads/main",
+			expected:  "github.com/org/repo",
+		},
+		{
+			name:      "repository prefix",
+			sourceURI: "git+https://github.com/org/repo-fork@refs/heads/main",
+			expected:  "github.com/org/repo",
+		},
+		{
+			name:     "empty source",
+			expected: "github.com/org/repo",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := Provenance{SourceURI: tt.sourceURI}
+			if diff := cmp.Diff(tt.matches, p.SourceURIMatches(tt.expected)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package slsaprovenance
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Fetcher fetches the provenance attestations of packages,
+// e.g. from the registry the package is stored in.
+type Fetcher interface {
+	// Provenances returns the DSSE envelopes of the provenance
+	// attestations of the package with digests.
+	Provenances(digests intoto.DigestSet, packageName string) ([][]byte, error)
+}
+
+// Verifier verifies the provenance of packages. It
+// implements publish.AttestationVerifier.
+type Verifier struct {
+	fetcher    Fetcher
+	signatures intoto.SignatureVerifier
+	now        func() time.Time
+}
+
+var _ publish.AttestationVerifier = (*Verifier)(nil)
+
+// VerifierOption defines a verifier option.
+type VerifierOption func(*Verifier) error
+
+// VerifierNew creates a verifier of the provenance fetched
+// by fetcher and signed by a key accepted by signatures.
+func VerifierNew(fetcher Fetcher, signatures intoto.SignatureVerifier, opts ...VerifierOption) (*Verifier, error) {
+	if fetcher == nil {
+		return nil, fmt.Errorf("%w: fetcher is nil", errs.ErrorInvalidInput)
+	}
+	if signatures == nil {
+		return nil, fmt.Errorf("%w: signature verifier is nil", errs.ErrorInvalidInput)
+	}
+	v := Verifier{
+		fetcher:    fetcher,
+		signatures: signatures,
+		now:        time.Now,
+	}
+	for _, option := range opts {
+		if err := option(&v); err != nil {
+			return nil, err
+		}
+	}
+	return &v, nil
+}
+
+// SetClock sets the clock used to verify the age of the provenance.
+func SetClock(now func() time.Time) VerifierOption {
+	return func(v *Verifier) error {
+		return v.setClock(now)
+	}
+}
+
+func (v *Verifier) setClock(now func() time.Time) error {
+	if now == nil {
+		return fmt.Errorf("%w: clock is nil", errs.ErrorInvalidInput)
+	}
+	v.now = now
+	return nil
+}
+
+// Verify returns the first provenance of the package that is built
+// from sourceURI by builderID and, if maxAge is non-zero, no earlier
+// than maxAge ago. It returns errs.ErrorNotFound if the package has
+// no provenance and errs.ErrorVerification if none is verified.
+func (v *Verifier) Verify(digests intoto.DigestSet, packageName, builderID, sourceURI string,
+	maxAge time.Duration) (*Provenance, error) {
+	if builderID == "" {
+		return nil, fmt.Errorf("%w: builder ID is empty", errs.ErrorInvalidInput)
+	}
+	if sourceURI == "" {
+		return nil, fmt.Errorf("%w: source URI is empty", errs.ErrorInvalidInput)
+	}
+	envelopes, err := v.fetcher.Provenances(digests, packageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provenance of (%q): %w", packageName, err)
+	}
+	if len(envelopes) == 0 {
+		return nil, fmt.Errorf("%w: no provenance for (%q)", errs.ErrorNotFound, packageName)
+	}
+	var errList []error
+	for i, envelope := range envelopes {
+		prov, err := ParseEnvelope(envelope, v.signatures)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("provenance %d: %w", i, err))
+			continue
+		}
+		if err := v.match(prov, digests, builderID, sourceURI, maxAge); err != nil {
+			errList = append(errList, fmt.Errorf("provenance %d: %w", i, err))
+			continue
+		}
+		return prov, nil
+	}
+	return nil, fmt.Errorf("%w: no provenance of (%q) verified: %w", errs.ErrorVerification,
+		packageName, errors.Join(errList...))
+}
+
+func (v *Verifier) match(prov *Provenance, digests intoto.DigestSet, builderID, sourceURI string,
+	maxAge time.Duration) error {
+	if !prov.HasSubject(digests) {
+		return fmt.Errorf("%w: no subject with digests (%v)", errs.ErrorMismatch, digests)
+	}
+	if !prov.BuilderIDMatches(builderID) {
+		return fmt.Errorf("%w: builder ID (%q) != (%q)", errs.ErrorMismatch, prov.BuilderID, builderID)
+	}
+	if !prov.SourceURIMatches(sourceURI) {
+		return fmt.Errorf("%w: source URI (%q) != (%q)", errs.ErrorMismatch, prov.SourceURI, sourceURI)
+	}
+	if maxAge == 0 {
+		return nil
+	}
+	if prov.BuildTime.IsZero() {
+		return fmt.Errorf("%w: provenance has no build time", errs.ErrorMismatch)
+	}
+	if age := v.now().Sub(prov.BuildTime); age > maxAge {
+		return fmt.Errorf("%w: provenance built at (%v) is older than (%v)", errs.ErrorMismatch,
+			prov.BuildTime.UTC(), maxAge)
+	}
+	return nil
+}
+
+// VerifyBuildAttestation implements publish.AttestationVerifier.
+// Certificate identities are not supported, since the signatures
+// are verified against keys.
+func (v *Verifier) VerifyBuildAttestation(digests intoto.DigestSet, policyPackageName, builderID, sourceURI string,
+	opts publish.AttestationVerifierBuildOptions) error {
+	if opts.Identity != nil {
+		return fmt.Errorf("%w: builder identity (%q, %q) is not supported", errs.ErrorInvalidInput,
+			opts.Identity.Issuer, opts.Identity.SubjectRegex)
+	}
+	_, err := v.Verify(digests, policyPackageName, builderID, sourceURI, opts.MaxAge)
+	return err
+}
+
+// BaseImages implements publish.AttestationVerifier. It returns the
+// images among the materials of the verified provenances of the
+// package, see Provenance.BaseImages. It returns errs.ErrorNotFound
+// if the package has no provenance and errs.ErrorVerification if
+// none is verified.
+func (v *Verifier) BaseImages(digests intoto.DigestSet, policyPackageName string) ([]string, error) {
+	envelopes, err := v.fetcher.Provenances(digests, policyPackageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provenance of (%q): %w", policyPackageName, err)
+	}
+	if len(envelopes) == 0 {
+		return nil, fmt.Errorf("%w: no provenance for (%q)", errs.ErrorNotFound, policyPackageName)
+	}
+	var errList []error
+	var images []string
+	verified := false
+	for i, envelope := range envelopes {
+		prov, err := ParseEnvelope(envelope, v.signatures)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("provenance %d: %w", i, err))
+			continue
+		}
+		if !prov.HasSubject(digests) {
+			errList = append(errList, fmt.Errorf("provenance %d: %w: no subject with digests (%v)", i,
+				errs.ErrorMismatch, digests))
+			continue
+		}
+		verified = true
+		for _, image := range prov.BaseImages {
+			if !slices.Contains(images, image) {
+				images = append(images, image)
+			}
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: no provenance of (%q) verified: %w", errs.ErrorVerification,
+			policyPackageName, errors.Join(errList...))
+	}
+	return images, nil
+}
@@ -0,0 +1,362 @@
+package slsaprovenance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/publish"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// fakeFetcher returns the same envelopes for all packages.
+type fakeFetcher struct {
+	envelopes [][]byte
+	err       error
+}
+
+func (f *fakeFetcher) Provenances(digests intoto.DigestSet, packageName string) ([][]byte, error) {
+	return f.envelopes, f.err
+}
+
+func signEnvelope(t *testing.T, key *ecdsa.PrivateKey, path string) []byte {
+	statement, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read provenance: %v", err)
+	}
+	hash := sha256.Sum256(intoto.PAE(intoto.PayloadType, statement))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	envelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	return envelope
+}
+
+func Test_VerifyBuildAttestation(t *testing.T) {
+	t.Parallel()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signatures, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	v1 := signEnvelope(t, key, "testdata/provenance_v1.json")
+	v02 := signEnvelope(t, key, "testdata/provenance_v0.2.json")
+	otherV1 := signEnvelope(t, otherKey, "testdata/provenance_v1.json")
+	digests := intoto.DigestSet{
+		"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4",
+	}
+	workflow := "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml"
+	sourceURI := "github.com/org/echo-server"
+	now := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		envelopes [][]byte
+		fetchErr  error
+		digests   intoto.DigestSet
+		builderID string
+		sourceURI string
+		opts      publish.AttestationVerifierBuildOptions
+		expected  error
+	}{
+		{
+			name:      "v1 provenance",
+			envelopes: [][]byte{v1},
+			builderID: workflow,
+		},
+		{
+			name:      "v0.2 provenance",
+			envelopes: [][]byte{v02},
+			builderID: workflow,
+		},
+		{
+			name:      "v1 provenance with ref",
+			envelopes: [][]byte{v02, v1},
+			builderID: workflow + "@refs/tags/v2.0.0",
+		},
+		{
+			name:      "v0.2 provenance with ref",
+			envelopes: [][]byte{v1, v02},
+			builderID: workflow + "@refs/tags/v1.9.0",
+		},
+		{
+			name:      "uppercase digest",
+			envelopes: [][]byte{v1},
+			digests: intoto.DigestSet{
+				"SHA256": "4378B3D11E11EDE0F64946E588C590E460E44F90C8A7921AD2CB7B04AAF298D4",
+			},
+			builderID: workflow,
+		},
+		{
+			name:      "within max age",
+			envelopes: [][]byte{v1},
+			builderID: workflow,
+			opts:      publish.AttestationVerifierBuildOptions{MaxAge: time.Hour},
+		},
+		{
+			name:      "older than max age",
+			envelopes: [][]byte{v1},
+			builderID: workflow,
+			opts:      publish.AttestationVerifierBuildOptions{MaxAge: 30 * time.Minute},
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "different ref",
+			envelopes: [][]byte{v1, v02},
+			builderID: workflow + "@refs/tags/v3.0.0",
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "different builder",
+			envelopes: [][]byte{v1},
+			builderID: "https://github.com/org/repo/.github/workflows/build.yml",
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "different source",
+			envelopes: [][]byte{v1},
+			builderID: workflow,
+			sourceURI: "github.com/org/other",
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "different digest",
+			envelopes: [][]byte{v1},
+			digests: intoto.DigestSet{
+				"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			},
+			builderID: workflow,
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "untrusted signature",
+			envelopes: [][]byte{otherV1},
+			builderID: workflow,
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "bare statement",
+			envelopes: [][]byte{[]byte(`{"_type": "https://in-toto.io/Statement/v1"}`)},
+			builderID: workflow,
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "no provenance",
+			builderID: workflow,
+			expected:  errs.ErrorNotFound,
+		},
+		{
+			name:      "fetch error",
+			fetchErr:  fmt.Errorf("%w: registry", errs.ErrorInternal),
+			builderID: workflow,
+			expected:  errs.ErrorInternal,
+		},
+		{
+			name:      "empty builder ID",
+			envelopes: [][]byte{v1},
+			expected:  errs.ErrorInvalidInput,
+		},
+		{
+			name:      "identity",
+			envelopes: [][]byte{v1},
+			opts: publish.AttestationVerifierBuildOptions{
+				Identity: &publish.RootIdentity{
+					Issuer:       "https://token.actions.githubusercontent.com",
+					SubjectRegex: regexp.MustCompile("^https://github.com/org/.*$"),
+				},
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifier, err := VerifierNew(&fakeFetcher{envelopes: tt.envelopes, err: tt.fetchErr}, signatures,
+				SetClock(func() time.Time { return now }))
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			digests := digests
+			if tt.digests != nil {
+				digests = tt.digests
+			}
+			sourceURI := sourceURI
+			if tt.sourceURI != "" {
+				sourceURI = tt.sourceURI
+			}
+			err = verifier.VerifyBuildAttestation(digests, "docker.io/org/echo-server", tt.builderID, sourceURI, tt.opts)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_BaseImages(t *testing.T) {
+	t.Parallel()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signatures, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	v1 := signEnvelope(t, key, "testdata/provenance_v1.json")
+	v02 := signEnvelope(t, key, "testdata/provenance_v0.2.json")
+	otherV02 := signEnvelope(t, otherKey, "testdata/provenance_v0.2.json")
+	digests := intoto.DigestSet{
+		"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4",
+	}
+	golang := "docker.io/library/golang@sha256:d0902bacefdde1cf45528c098d14e55d78c107def8a22d148eabd71582d7a99f"
+	distroless := "gcr.io/distroless/static@sha256:6ec5aa99dc335666e79dc64e4a6c8b89c33a543a1967f20d360922a80dd21f02"
+	tests := []struct {
+		name      string
+		envelopes [][]byte
+		fetchErr  error
+		digests   intoto.DigestSet
+		images    []string
+		expected  error
+	}{
+		{
+			name:      "single provenance",
+			envelopes: [][]byte{v1},
+			images:    []string{golang},
+		},
+		{
+			name:      "all verified provenances",
+			envelopes: [][]byte{v1, v02, v1},
+			images:    []string{golang, distroless},
+		},
+		{
+			name:      "untrusted provenance ignored",
+			envelopes: [][]byte{v1, otherV02},
+			images:    []string{golang},
+		},
+		{
+			name:      "untrusted signature",
+			envelopes: [][]byte{otherV02},
+			expected:  errs.ErrorVerification,
+		},
+		{
+			name:      "different digest",
+			envelopes: [][]byte{v1},
+			digests: intoto.DigestSet{
+				"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "no provenance",
+			expected: errs.ErrorNotFound,
+		},
+		{
+			name:     "fetch error",
+			fetchErr: fmt.Errorf("%w: registry", errs.ErrorInternal),
+			expected: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifier, err := VerifierNew(&fakeFetcher{envelopes: tt.envelopes, err: tt.fetchErr}, signatures)
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			digests := digests
+			if tt.digests != nil {
+				digests = tt.digests
+			}
+			images, err := verifier.BaseImages(digests, "docker.io/org/echo-server")
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.images, images); diff != "" {
+				t.Fatalf("unexpected images (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_VerifierNew(t *testing.T) {
+	t.Parallel()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signatures, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	tests := []struct {
+		name       string
+		fetcher    Fetcher
+		signatures intoto.SignatureVerifier
+		opts       []VerifierOption
+		expected   error
+	}{
+		{
+			name:       "valid verifier",
+			fetcher:    &fakeFetcher{},
+			signatures: signatures,
+		},
+		{
+			name:       "nil fetcher",
+			signatures: signatures,
+			expected:   errs.ErrorInvalidInput,
+		},
+		{
+			name:     "nil signature verifier",
+			fetcher:  &fakeFetcher{},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:       "nil clock",
+			fetcher:    &fakeFetcher{},
+			signatures: signatures,
+			opts:       []VerifierOption{SetClock(nil)},
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := VerifierNew(tt.fetcher, tt.signatures, tt.opts...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
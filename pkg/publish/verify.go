@@ -1,57 +1,243 @@
 package publish
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/checks"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/limit"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/metrics"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/regex"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/semver"
 )
 
+// Verification verifies an attestation. It is not modified by Verify,
+// so a single verification is safe for concurrent Verify calls,
+// with different options.
 type Verification struct {
 	attestation
 	packageHelper PackageHelper
+	// computed is computed from the attestation by verificationNew.
+	computed *precomputed
+	checks   *checks.List
+	// envelope is the content the verification was created from,
+	// verified by the transparency log.
+	envelope []byte
+	tlog     TransparencyLog
+	// dsse is the envelope the attestation was decoded from,
+	// if any.
+	dsse              *intoto.Envelope
+	dsseVerifier      intoto.SignatureVerifier
+	allowUnsignedDSSE bool
+	metrics           metrics.Metrics
 }
 
 type VerificationOption func(*Verification) error
 
-func VerificationNew(reader io.ReadCloser, packageHelper PackageHelper) (*Verification, error) {
-	content, err := ioutil.ReadAll(reader)
+// ParseOption defines an option to parse attestations.
+type ParseOption func(*parser) error
+
+// parser defines how attestations are parsed.
+type parser struct {
+	maxSize int64
+}
+
+// WithMaxAttestationSize sets the maximum size of an attestation, or of
+// a bundle of attestations, in bytes. Larger content is rejected with
+// errs.ErrorInvalidInput. It defaults to 4 MiB.
+func WithMaxAttestationSize(size int64) ParseOption {
+	return func(p *parser) error {
+		return p.setMaxSize(size)
+	}
+}
+
+func (p *parser) setMaxSize(size int64) error {
+	if size < 1 {
+		return fmt.Errorf("%w: max attestation size (%d) must be positive", errs.ErrorInvalidInput, size)
+	}
+	p.maxSize = size
+	return nil
+}
+
+func parserNew(opts []ParseOption) (*parser, error) {
+	p := parser{maxSize: limit.DefaultMaxSize}
+	for _, option := range opts {
+		if err := option(&p); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// ParsedAttestation is a structurally valid publish attestation.
+// It is never modified by verification, so it is safe to cache
+// and share across concurrent Verify calls.
+type ParsedAttestation struct {
+	attestation attestation
+	envelope    []byte
+	dsse        *intoto.Envelope
+}
+
+// ParseAndValidate parses an attestation and validates its structure:
+// statement type, predicate type, subjects and digests. It does not
+// verify conformance to any policy, nor the signatures of a DSSE envelope.
+func ParseAndValidate(reader io.ReadCloser, opts ...ParseOption) (*ParsedAttestation, error) {
+	att, content, dsse, err := parse(reader, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read: %w", err)
+		return nil, err
 	}
-	defer reader.Close()
-	var att attestation
-	if err := json.Unmarshal(content, &att); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	if err := att.validate(); err != nil {
+		return nil, err
+	}
+	return &ParsedAttestation{
+		attestation: *att,
+		envelope:    content,
+		dsse:        dsse,
+	}, nil
+}
+
+// VerificationNew creates a verification for an attestation,
+// either a bare statement or a DSSE envelope. The signatures of an
+// envelope are verified by Verify(), see WithDSSEVerifier().
+func VerificationNew(reader io.ReadCloser, packageHelper PackageHelper, opts ...ParseOption) (*Verification, error) {
+	att, content, dsse, err := parse(reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	v, err := verificationNew(*att, content, packageHelper)
+	if err != nil {
+		return nil, err
+	}
+	v.dsse = dsse
+	return v, nil
+}
+
+// VerificationFromParsed creates a verification for
+// an attestation returned by ParseAndValidate.
+func VerificationFromParsed(parsed *ParsedAttestation, packageHelper PackageHelper) (*Verification, error) {
+	if parsed == nil {
+		return nil, fmt.Errorf("%w: parsed attestation is nil", errs.ErrorInvalidInput)
 	}
+	v, err := verificationNew(parsed.attestation, parsed.envelope, packageHelper)
+	if err != nil {
+		return nil, err
+	}
+	v.dsse = parsed.dsse
+	return v, nil
+}
+
+func verificationNew(att attestation, envelope []byte, packageHelper PackageHelper) (*Verification, error) {
 	if packageHelper == nil {
 		return nil, fmt.Errorf("%w: package hepler is nil", errs.ErrorInvalidInput)
 	}
 	return &Verification{
 		attestation:   att,
 		packageHelper: packageHelper,
+		computed:      precomputedNew(&att),
+		envelope:      envelope,
 	}, nil
 }
 
+func parse(reader io.ReadCloser, opts []ParseOption) (*attestation, []byte, *intoto.Envelope, error) {
+	p, err := parserNew(opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	content, err := limit.ReadAll(reader, p.maxSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer reader.Close()
+	dsse, err := intoto.ParseEnvelope(content)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	statement := content
+	if dsse != nil {
+		if statement, err = dsse.Statement(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	var att attestation
+	if err := json.Unmarshal(statement, &att); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: failed to unmarshal: %w", errs.ErrorInvalidInput, err)
+	}
+	att.translate()
+	return &att, content, dsse, nil
+}
+
 func (v *Verification) Verify(digests intoto.DigestSet, policyPackageName string, options ...VerificationOption) error {
-	// Statement type.
-	if v.attestation.Header.Type != statementType {
-		return fmt.Errorf("%w: attestation type (%q) != intoto type (%q)", errs.ErrorMismatch,
-			v.attestation.Header.Type, statementType)
+	return v.VerifyContext(context.Background(), digests, policyPackageName, options...)
+}
+
+// VerifyContext is Verify with a context, used by the verifications
+// that require network calls, see RequireTransparencyLog().
+func (v *Verification) VerifyContext(ctx context.Context, digests intoto.DigestSet, policyPackageName string, options ...VerificationOption) error {
+	return v.verifyContext(ctx, digests, policyPackageName, options...)
+}
+
+// VerifyAttestation is VerifyContext that also returns the content of
+// the attestation once verified. Unlike a flag on the verification, the
+// view belongs to the call, so it is safe for concurrent calls.
+func (v *Verification) VerifyAttestation(ctx context.Context, digests intoto.DigestSet, policyPackageName string, options ...VerificationOption) (*VerifiedAttestation, error) {
+	if err := v.verifyContext(ctx, digests, policyPackageName, options...); err != nil {
+		return nil, err
+	}
+	return v.verifiedAttestation()
+}
+
+func (v *Verification) verifyContext(ctx context.Context, digests intoto.DigestSet, policyPackageName string, options ...VerificationOption) error {
+	// Without options, there is no state to collect,
+	// so the verification is used as is.
+	if len(options) == 0 {
+		return v.verify(ctx, v, digests, policyPackageName)
 	}
-	// Predicate type.
-	if v.attestation.Header.PredicateType != predicateType {
-		return fmt.Errorf("%w: attestation predicate type (%q) != publish type (%q)", errs.ErrorMismatch,
-			v.attestation.Header.PredicateType, predicateType)
+
+	// Options. Collect them first on a copy,
+	// so that concurrent calls do not share state.
+	vv := *v
+	vv.computed = v.precomputed()
+	vv.checks = &checks.List{}
+	for _, option := range options {
+		err := option(&vv)
+		if err != nil {
+			return err
+		}
 	}
-	// Subjects and digests.
-	if len(v.attestation.Header.Subjects) == 0 {
-		return fmt.Errorf("%w: no subjects in attestation", errs.ErrorInvalidField)
+	if vv.metrics == nil {
+		return v.verify(ctx, &vv, digests, policyPackageName)
+	}
+	start := time.Now()
+	err := v.verify(ctx, &vv, digests, policyPackageName)
+	decision, code := metrics.Decision(err)
+	vv.metrics.ObserveVerification(metrics.ComponentPublish, decision, code, time.Since(start))
+	return err
+}
+
+// verify verifies the attestation with the options collected in vv,
+// which is v itself if there are none.
+func (v *Verification) verify(ctx context.Context, vv *Verification, digests intoto.DigestSet, policyPackageName string) error {
+	// Inputs.
+	digests, err := digests.Normalize()
+	if err != nil {
+		return err
 	}
-	if err := verifyDigests(v.attestation.Header.Subjects[0].Digests, digests); err != nil {
+	computed := vv.precomputed()
+	// Structure.
+	if err := computed.structureErr; err != nil {
+		return err
+	}
+	// Digests.
+	if err := computed.verifySubjects(v.attestation.Header.Subjects, digests); err != nil {
 		return err
 	}
 
@@ -59,16 +245,23 @@ func (v *Verification) Verify(digests intoto.DigestSet, policyPackageName string
 	if err := v.verifyPackage(policyPackageName); err != nil {
 		return err
 	}
-	// TODO: verify time. Use default margin, but allow passing
-	// a custom one.
+	// NOTE: the creation time is verified by the
+	// CreatedAfter() and CreatedBefore() options.
 
-	// Other options.
-	for _, option := range options {
-		err := option(v)
-		if err != nil {
+	// Signatures.
+	if err := vv.verifyEnvelope(); err != nil {
+		return err
+	}
+	if vv.checks != nil {
+		if err := vv.checks.Run(); err != nil {
 			return err
 		}
 	}
+	// NOTE: the transparency log is queried last,
+	// since it may require a network call.
+	if err := vv.verifyInclusion(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -85,29 +278,91 @@ func (v *Verification) verifyPackage(policyPackageName string) error {
 		return fmt.Errorf("%w: failed to create package descriptor: %v", errs.ErrorInternal, err.Error())
 	}
 
-	if packageDesc.Name != v.attestation.Predicate.Package.Name || packageDesc.Registry != v.attestation.Predicate.Package.Registry {
-		return fmt.Errorf("%w: package (%q) != attestation package (%q)", errs.ErrorMismatch,
-			policyPackageName, v.attestation.Predicate.Package.Name+"/"+v.attestation.Predicate.Package.Registry)
+	if packageDesc.Name != v.attestation.Predicate.Package.Name ||
+		normalizeRegistry(packageDesc.Registry) != normalizeRegistry(v.attestation.Predicate.Package.Registry) {
+		return errs.VerificationErrorNew(errs.CheckSubjectURI, policyPackageName,
+			v.attestation.Predicate.Package.Name+"/"+v.attestation.Predicate.Package.Registry,
+			fmt.Errorf("%w: package (%q) != attestation package (%q)", errs.ErrorMismatch,
+				policyPackageName, v.attestation.Predicate.Package.Name+"/"+v.attestation.Predicate.Package.Registry))
+	}
+	return nil
+}
+
+// validate checks the structure of the attestation,
+// independently of any policy.
+func (a *attestation) validate() error {
+	// Statement type.
+	if a.Header.Type != statementType {
+		return errs.VerificationErrorNew(errs.CheckStatementType, statementType, a.Header.Type,
+			fmt.Errorf("%w: attestation type (%q) != intoto type (%q)", errs.ErrorMismatch,
+				a.Header.Type, statementType))
+	}
+	// Predicate type.
+	if a.Header.PredicateType != predicateType {
+		return errs.VerificationErrorNew(errs.CheckPredicateType, predicateType, a.Header.PredicateType,
+			fmt.Errorf("%w: attestation predicate type (%q) != publish type (%q)", errs.ErrorMismatch,
+				a.Header.PredicateType, predicateType))
+	}
+	// Subjects and digests.
+	if len(a.Header.Subjects) == 0 {
+		return fmt.Errorf("%w: no subjects in attestation", errs.ErrorInvalidField)
+	}
+	for i := range a.Header.Subjects {
+		if err := intoto.ValidateSubject(a.Header.Subjects[i]); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// verifySubjects verifies that the digests match at least one
+// of the subjects, e.g. the index or a platform manifest
+// of a multi-arch image.
+func verifySubjects(subjects []intoto.Subject, digests intoto.DigestSet) error {
+	var errList []error
+	for i := range subjects {
+		err := verifyDigests(subjects[i].Digests, digests)
+		if err == nil {
+			return nil
+		}
+		errList = append(errList, err)
+	}
+	if len(errList) == 1 {
+		return errList[0]
+	}
+	return errs.VerificationErrorNew(errs.CheckDigest, fmt.Sprintf("%v", digests), "",
+		fmt.Errorf("%w: no subject matches digests (%q): %w", errs.ErrorMismatch, digests, errors.Join(errList...)))
+}
+
 func verifyDigests(ds intoto.DigestSet, digests intoto.DigestSet) error {
 	if err := ds.Validate(); err != nil {
 		return err
 	}
-	if err := digests.Validate(); err != nil {
+	// NOTE: an attestation may record an algorithm under names that only
+	// differ in case. Conflicting values fail even if the evaluated
+	// digests do not contain the algorithm.
+	if alg, conflicts := ds.ConflictingAlgorithm(); conflicts {
+		return errs.VerificationErrorNew(errs.CheckDigest, "", "",
+			fmt.Errorf("%w: attestation digest (%q) has conflicting values", errs.ErrorMismatch, alg))
+	}
+	// NOTE: algorithm names and hex values are compared case-insensitively.
+	// Every algorithm of the evaluated digests must be present
+	// in the attestation with the same value.
+	digests, err := digests.Normalize()
+	if err != nil {
 		return err
 	}
 	for name, value := range digests {
-		val, exists := ds[name]
+		val, exists := ds.DigestValue(name)
 		if !exists {
-			return fmt.Errorf("%w: subject with digest (%q:%q) is not present in attestation", errs.ErrorMismatch,
-				name, value)
+			return errs.VerificationErrorNew(errs.CheckDigest, name+":"+value, "",
+				fmt.Errorf("%w: subject with digest (%q:%q) is not present in attestation", errs.ErrorMismatch,
+					name, value))
 		}
 		if val != value {
-			return fmt.Errorf("%w: subject with digest (%q:%q) != attestation (%q:%q)", errs.ErrorMismatch,
-				name, value, name, val)
+			return errs.VerificationErrorNew(errs.CheckDigest, name+":"+value, name+":"+val,
+				fmt.Errorf("%w: subject with digest (%q:%q) != attestation (%q:%q)", errs.ErrorMismatch,
+					name, value, name, val))
 		}
 	}
 	return nil
@@ -115,35 +370,192 @@ func verifyDigests(ds intoto.DigestSet, digests intoto.DigestSet) error {
 
 func IsPackageEnvironment(env string) VerificationOption {
 	return func(v *Verification) error {
-		return v.isPackageEnvironment(env)
+		return v.addCheck(checks.Check{
+			Kind:      "IsPackageEnvironment",
+			Key:       env,
+			Value:     fmt.Sprintf("%q", env),
+			Exclusive: true,
+			Rank:      checks.RankPackage,
+			Run:       func() error { return v.isPackageEnvironment(env) },
+		})
 	}
 }
 
 func (v *Verification) isPackageEnvironment(env string) error {
 	if v.attestation.Predicate.Package.Environment != env {
-		return fmt.Errorf("%w: environment (%q) != attestation environment (%q)", errs.ErrorMismatch,
-			env, v.attestation.Predicate.Package.Environment)
+		return errs.VerificationErrorNew(errs.CheckEnvironment, env, v.attestation.Predicate.Package.Environment,
+			fmt.Errorf("%w: environment (%q) != attestation environment (%q)", errs.ErrorMismatch,
+				env, v.attestation.Predicate.Package.Environment))
+	}
+	return nil
+}
+
+// IsPackageArch verifies the architecture of the package, e.g. amd64.
+func IsPackageArch(arch string) VerificationOption {
+	return isPackageField("IsPackageArch", "arch", arch, func(p *intoto.PackageDescriptor) string { return p.Arch })
+}
+
+// IsPackageOS verifies the operating system of the package, e.g. linux.
+func IsPackageOS(os string) VerificationOption {
+	return isPackageField("IsPackageOS", "os", os, func(p *intoto.PackageDescriptor) string { return p.OS })
+}
+
+// IsPackageMediaType verifies the media type of the package.
+func IsPackageMediaType(mediaType string) VerificationOption {
+	return isPackageField("IsPackageMediaType", "media type", mediaType,
+		func(p *intoto.PackageDescriptor) string { return p.MediaType })
+}
+
+func isPackageField(kind, name, value string, field func(*intoto.PackageDescriptor) string) VerificationOption {
+	return func(v *Verification) error {
+		if value == "" {
+			return fmt.Errorf("%w: package %s is empty", errs.ErrorInvalidInput, name)
+		}
+		return v.addCheck(checks.Check{
+			Kind:      kind,
+			Key:       value,
+			Value:     fmt.Sprintf("%q", value),
+			Exclusive: true,
+			Rank:      checks.RankPackage,
+			Run: func() error {
+				actual := field(&v.attestation.Predicate.Package)
+				if actual != value {
+					return errs.VerificationErrorNew(errs.CheckPackage, value, actual,
+						fmt.Errorf("%w: %s (%q) != attestation %s (%q)", errs.ErrorMismatch,
+							name, value, name, actual))
+				}
+				return nil
+			},
+		})
+	}
+}
+
+// IsPackageRegistry verifies the registry of the package, e.g. the registry
+// the image was pulled from, so that an attestation is not replayed onto an
+// identically-named package of another registry. Registries are compared
+// without their scheme and trailing slashes.
+func IsPackageRegistry(registry string) VerificationOption {
+	return func(v *Verification) error {
+		normalized := normalizeRegistry(registry)
+		if normalized == "" {
+			return fmt.Errorf("%w: package registry is empty", errs.ErrorInvalidInput)
+		}
+		return v.addCheck(checks.Check{
+			Kind:      "IsPackageRegistry",
+			Key:       normalized,
+			Value:     fmt.Sprintf("%q", normalized),
+			Exclusive: true,
+			Rank:      checks.RankPackage,
+			Run:       func() error { return v.isPackageRegistry(registry) },
+		})
+	}
+}
+
+func (v *Verification) isPackageRegistry(registry string) error {
+	actual := v.attestation.Predicate.Package.Registry
+	if normalizeRegistry(actual) == "" {
+		return fmt.Errorf("%w: attestation registry is empty", errs.ErrorInvalidField)
+	}
+	if normalizeRegistry(actual) != normalizeRegistry(registry) {
+		return errs.VerificationErrorNew(errs.CheckSubjectURI, registry, actual,
+			fmt.Errorf("%w: registry (%q) != attestation registry (%q)", errs.ErrorMismatch,
+				registry, actual))
 	}
 	return nil
 }
 
 func IsPackageVersion(version string) VerificationOption {
 	return func(v *Verification) error {
-		return v.isPackageVersion(version)
+		return v.addCheck(checks.Check{
+			Kind:      "IsPackageVersion",
+			Key:       version,
+			Value:     fmt.Sprintf("%q", version),
+			Exclusive: true,
+			Rank:      checks.RankPackage,
+			Run:       func() error { return v.isPackageVersion(version) },
+		})
 	}
 }
 
 func (v *Verification) isPackageVersion(version string) error {
 	if v.attestation.Predicate.Package.Version != version {
-		return fmt.Errorf("%w: version (%q) != attestation version (%q)", errs.ErrorMismatch,
-			version, v.attestation.Predicate.Package.Version)
+		return errs.VerificationErrorNew(errs.CheckVersion, version, v.attestation.Predicate.Package.Version,
+			fmt.Errorf("%w: version (%q) != attestation version (%q)", errs.ErrorMismatch,
+				version, v.attestation.Predicate.Package.Version))
+	}
+	return nil
+}
+
+// IsPackageVersionAtLeast verifies that the attestation's package
+// version is greater than or equal to version, using semantic
+// versioning precedence. Missing minor and patch components are 0.
+func IsPackageVersionAtLeast(version string) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "IsPackageVersionAtLeast",
+			Key:   version,
+			Value: fmt.Sprintf("%q", version),
+			Rank:  checks.RankPackage,
+			Run:   func() error { return v.isPackageVersionAtLeast(version) },
+		})
+	}
+}
+
+func (v *Verification) isPackageVersionAtLeast(version string) error {
+	minVersion, err := semver.Parse(version)
+	if err != nil {
+		return err
+	}
+	actual, err := semver.Parse(v.attestation.Predicate.Package.Version)
+	if err != nil {
+		return err
+	}
+	if actual.Compare(minVersion) < 0 {
+		return errs.VerificationErrorNew(errs.CheckVersion, version, v.attestation.Predicate.Package.Version,
+			fmt.Errorf("%w: attestation version (%q) < version (%q)", errs.ErrorMismatch,
+				v.attestation.Predicate.Package.Version, version))
+	}
+	return nil
+}
+
+// IsAuthorVersionAtLeast verifies that the attestation records its
+// author, see WithAuthor(), with a version greater than or equal to
+// version, using semantic versioning precedence.
+func IsAuthorVersionAtLeast(version string) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "IsAuthorVersionAtLeast",
+			Key:   version,
+			Value: fmt.Sprintf("%q", version),
+			Rank:  checks.RankPolicy,
+			Run:   func() error { return v.isAuthorVersionAtLeast(version) },
+		})
+	}
+}
+
+func (v *Verification) isAuthorVersionAtLeast(version string) error {
+	author := v.attestation.Predicate.Author
+	if author == nil {
+		return errs.VerificationErrorNew(errs.CheckAuthor, version, "",
+			fmt.Errorf("%w: author not present in attestation", errs.ErrorMismatch))
+	}
+	if err := semver.AtLeast(author.Version, version); err != nil {
+		return errs.VerificationErrorNew(errs.CheckAuthor, version, author.Version,
+			fmt.Errorf("author (%q): %w", author.ID, err))
 	}
 	return nil
 }
 
 func IsSlsaBuildLevel(level int) VerificationOption {
 	return func(v *Verification) error {
-		return v.isSlsaBuildLevel(level)
+		return v.addCheck(checks.Check{
+			Kind:      "IsSlsaBuildLevel",
+			Key:       level,
+			Value:     fmt.Sprintf("%d", level),
+			Exclusive: true,
+			Rank:      checks.RankProperties,
+			Run:       func() error { return v.isSlsaBuildLevel(level) },
+		})
 	}
 }
 
@@ -156,15 +568,22 @@ func (v *Verification) isSlsaBuildLevel(level int) error {
 		return err
 	}
 	if attLevel != level {
-		return fmt.Errorf("%w: level (%v) != attestation (%v)", errs.ErrorMismatch,
-			level, attLevel)
+		return errs.VerificationErrorNew(errs.CheckBuildLevel, strconv.Itoa(level), strconv.Itoa(attLevel),
+			fmt.Errorf("%w: level (%v) != attestation (%v)", errs.ErrorMismatch,
+				level, attLevel))
 	}
 	return nil
 }
 
 func IsSlsaBuildLevelOrAbove(level int) VerificationOption {
 	return func(v *Verification) error {
-		return v.isSlsaBuildLevelOrAbove(level)
+		return v.addCheck(checks.Check{
+			Kind:  "IsSlsaBuildLevelOrAbove",
+			Key:   level,
+			Value: fmt.Sprintf("%d", level),
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.isSlsaBuildLevelOrAbove(level) },
+		})
 	}
 }
 
@@ -177,8 +596,204 @@ func (v *Verification) isSlsaBuildLevelOrAbove(level int) error {
 		return err
 	}
 	if attLevel < level {
-		return fmt.Errorf("%w: level (%v) > attestation (%v)", errs.ErrorMismatch,
-			level, attLevel)
+		return errs.VerificationErrorNew(errs.CheckBuildLevel, strconv.Itoa(level), strconv.Itoa(attLevel),
+			fmt.Errorf("%w: level (%v) > attestation (%v)", errs.ErrorMismatch,
+				level, attLevel))
+	}
+	return nil
+}
+
+// HasBaseImage verifies that one of the base images
+// recorded in the attestation starts with the prefix.
+func HasBaseImage(prefix string) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "HasBaseImage",
+			Key:   prefix,
+			Value: fmt.Sprintf("%q", prefix),
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.hasBaseImage(prefix) },
+		})
+	}
+}
+
+func (v *Verification) hasBaseImage(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("%w: base image prefix is empty", errs.ErrorInvalidInput)
+	}
+	value, exists := v.attestation.Predicate.Properties[baseImagesProperty]
+	if !exists {
+		return errs.VerificationErrorNew(errs.CheckBaseImage, prefix, "",
+			fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch, baseImagesProperty))
+	}
+	images, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: base images (%T:%v) is not a list", errs.ErrorInvalidField, value, value)
+	}
+	for _, image := range images {
+		vv, ok := image.(string)
+		if !ok {
+			return fmt.Errorf("%w: base image (%T:%v) is not a string", errs.ErrorInvalidField, image, image)
+		}
+		if strings.HasPrefix(vv, prefix) {
+			return nil
+		}
+	}
+	return errs.VerificationErrorNew(errs.CheckBaseImage, prefix, fmt.Sprintf("%q", images),
+		fmt.Errorf("%w: prefix (%q) does not match attestation base images (%q)", errs.ErrorMismatch,
+			prefix, images))
+}
+
+// HasSBOM verifies that the attestation references an SBOM.
+func HasSBOM() VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind: "HasSBOM",
+			Rank: checks.RankProperties,
+			Run: func() error {
+				_, err := v.sbom()
+				return err
+			},
+		})
+	}
+}
+
+// HasSBOMDigest verifies that the attestation references an SBOM
+// with the digests.
+func HasSBOMDigest(digests intoto.DigestSet) VerificationOption {
+	return func(v *Verification) error {
+		if err := digests.Validate(); err != nil {
+			return err
+		}
+		digestsKey := checks.DigestsKey(digests)
+		return v.addCheck(checks.Check{
+			Kind:  "HasSBOMDigest",
+			Key:   digestsKey,
+			Value: digestsKey,
+			Rank:  checks.RankProperties,
+			Run:   func() error { return v.hasSBOMDigest(digests) },
+		})
+	}
+}
+
+func (v *Verification) hasSBOMDigest(digests intoto.DigestSet) error {
+	sbom, err := v.sbom()
+	if err != nil {
+		return err
+	}
+	for name, value := range digests {
+		val, exists := sbom.Digest[name]
+		if !exists {
+			return errs.VerificationErrorNew(errs.CheckSBOM, name+":"+value, "",
+				fmt.Errorf("%w: SBOM digest (%q:%q) is not present in attestation", errs.ErrorMismatch,
+					name, value))
+		}
+		if val != value {
+			return errs.VerificationErrorNew(errs.CheckSBOM, name+":"+value, name+":"+val,
+				fmt.Errorf("%w: SBOM digest (%q:%q) != attestation (%q:%q)", errs.ErrorMismatch,
+					name, value, name, val))
+		}
+	}
+	return nil
+}
+
+func (v *Verification) sbom() (*intoto.ResourceDescriptor, error) {
+	computed := v.precomputed()
+	return computed.sbom, computed.sbomErr
+}
+
+// RejectForeignSubjects verifies that every subject in the attestation
+// is the attested package: each subject's name must resolve, via the
+// package helper, to the attestation's package name. An unnamed subject
+// is accepted only if it is the sole subject, since Verify() already
+// matched it against the digests.
+// NOTE: This option is off by default because multi-arch images
+// legitimately carry one subject per architecture. Attestations
+// created with multiple subjects must name each of them
+// with the package name to pass this check.
+func RejectForeignSubjects(packageHelper PackageHelper) VerificationOption {
+	return func(v *Verification) error {
+		return v.addCheck(checks.Check{
+			Kind:  "RejectForeignSubjects",
+			Key:   packageHelper,
+			Value: fmt.Sprintf("%T", packageHelper),
+			Rank:  checks.RankSubjects,
+			Run:   func() error { return v.rejectForeignSubjects(packageHelper) },
+		})
+	}
+}
+
+func (v *Verification) rejectForeignSubjects(packageHelper PackageHelper) error {
+	if packageHelper == nil {
+		return fmt.Errorf("%w: package hepler is nil", errs.ErrorInvalidInput)
+	}
+	if err := v.attestation.Predicate.Package.Validate(); err != nil {
+		return err
+	}
+	packageName, err := packageHelper.PolicyPackageName(v.attestation.Predicate.Package)
+	if err != nil {
+		return fmt.Errorf("%w: failed to create package name: %v", errs.ErrorInternal, err.Error())
+	}
+	subjects := v.attestation.Header.Subjects
+	var foreign []string
+	for i := range subjects {
+		subject := &subjects[i]
+		if subject.Name == "" {
+			if len(subjects) != 1 {
+				foreign = append(foreign, subject.Name)
+			}
+			continue
+		}
+		desc, err := packageHelper.PackageDescriptor(subject.Name)
+		if err != nil {
+			foreign = append(foreign, subject.Name)
+			continue
+		}
+		name, err := packageHelper.PolicyPackageName(desc)
+		if err != nil || name != packageName {
+			foreign = append(foreign, subject.Name)
+		}
+	}
+	if len(foreign) > 0 {
+		return errs.VerificationErrorNew(errs.CheckSubjectURI, packageName, fmt.Sprintf("%q", foreign),
+			fmt.Errorf("%w: subjects (%q) are not package (%q)", errs.ErrorInvalidField,
+				foreign, packageName))
+	}
+	return nil
+}
+
+// PackageNameMatches verifies that the attestation's policy package name,
+// as constructed by the PackageHelper, matches the regular expression.
+// NOTE: The pattern is not anchored implicitly: callers who need
+// to match the entire name must use "^" and "$".
+func PackageNameMatches(pattern string) VerificationOption {
+	re, err := regex.Compile(pattern)
+	return func(v *Verification) error {
+		if err != nil {
+			return err
+		}
+		return v.addCheck(checks.Check{
+			Kind:  "PackageNameMatches",
+			Key:   pattern,
+			Value: fmt.Sprintf("%q", pattern),
+			Rank:  checks.RankPolicy,
+			Run:   func() error { return v.packageNameMatches(re) },
+		})
+	}
+}
+
+func (v *Verification) packageNameMatches(re *regexp.Regexp) error {
+	if err := v.attestation.Predicate.Package.Validate(); err != nil {
+		return err
+	}
+	name, err := v.packageHelper.PolicyPackageName(v.attestation.Predicate.Package)
+	if err != nil {
+		return fmt.Errorf("%w: failed to create package name: %v", errs.ErrorInternal, err.Error())
+	}
+	if !re.MatchString(name) {
+		return errs.VerificationErrorNew(errs.CheckSubjectURI, re.String(), name,
+			fmt.Errorf("%w: attestation package (%q) does not match (%q)", errs.ErrorMismatch,
+				name, re.String()))
 	}
 	return nil
 }
@@ -194,17 +809,35 @@ func validateLevel(level int) error {
 }
 
 func (v *Verification) attestationLevel() (int, error) {
-	if v.attestation.Predicate.Properties == nil {
-		return 0, fmt.Errorf("%w: publish properties are empty", errs.ErrorMismatch)
+	computed := v.precomputed()
+	return computed.level, computed.levelErr
+}
+
+// EvaluationDuration returns the duration of the policy evaluation
+// recorded in the attestation, if present.
+func (v *Verification) EvaluationDuration() (time.Duration, error) {
+	ms, err := v.intProperty(evaluationDurationProperty)
+	if err != nil {
+		return 0, err
 	}
-	value, exists := v.attestation.Predicate.Properties[buildLevelProperty]
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// VerifierCalls returns the number of verifier calls
+// recorded in the attestation, if present.
+func (v *Verification) VerifierCalls() (int, error) {
+	return v.intProperty(verifierCallsProperty)
+}
+
+func (v *Verification) intProperty(name string) (int, error) {
+	value, exists := v.attestation.Predicate.Properties[name]
 	if !exists {
-		return 0, fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorMismatch,
-			buildLevelProperty)
+		return 0, fmt.Errorf("%w: (%q) field not present in properties", errs.ErrorNotFound, name)
 	}
 	vv, ok := value.(float64)
-	if !ok {
-		return 0, fmt.Errorf("%w: attestation level (%T:%v) is not an int", errs.ErrorMismatch, value, value)
+	if !ok || vv < 0 || vv != float64(int(vv)) {
+		return 0, fmt.Errorf("%w: property (%q) value (%T:%v) is not a non-negative int", errs.ErrorInvalidField,
+			name, value, value)
 	}
 	return int(vv), nil
 }
@@ -2,15 +2,28 @@ package publish
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 	"github.com/slsa-framework/slsa-policy/pkg/publish/internal/common"
 	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/regex"
 )
 
 func Test_verifyDigests(t *testing.T) {
@@ -25,36 +38,36 @@ func Test_verifyDigests(t *testing.T) {
 		{
 			name: "same digests",
 			attDigests: intoto.DigestSet{
-				"sha256":    "another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 		},
 		{
 			name: "subset in attestations",
 			attDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 		},
 		{
 			name: "empty input digests",
 			attDigests: intoto.DigestSet{
-				"sha256":    "another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			expected: errs.ErrorInvalidField,
 		},
 		{
 			name: "empty att digests",
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 			expected: errs.ErrorInvalidField,
 		},
@@ -65,23 +78,132 @@ func Test_verifyDigests(t *testing.T) {
 				"a-gitCommit": "mismatch_another_com",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 			expected: errs.ErrorMismatch,
 		},
 		{
 			name: "mismatch sha256 digest",
 			attDigests: intoto.DigestSet{
-				"sha256":    "not_another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			inputDigests: intoto.DigestSet{
-				"gitCommit": "mismatch_another_com",
-				"sha256":    "another",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 			},
 			expected: errs.ErrorMismatch,
 		},
+		{
+			name: "mixed case input digests",
+			attDigests: intoto.DigestSet{
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"GITCOMMIT": "685D6428520757122269412CE6F9C5B7D1911F61",
+				"SHA256":    "AE448AC86C4E8E4DEC645729708EF41873AE79C6DFF84EFF73360989487F08E5",
+			},
+		},
+		{
+			name: "mixed case att digests",
+			attDigests: intoto.DigestSet{
+				"Sha256":    "AE448AC86C4E8E4DEC645729708EF41873AE79C6DFF84EFF73360989487F08E5",
+				"gitcommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+		},
+		{
+			name: "short sha256 digest",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "non hex gitCommit digest",
+			attDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+			},
+			inputDigests: intoto.DigestSet{
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f6z",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name: "input algorithm absent from attestation",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"sha512": "ccc",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "conflicting common digest",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"sha512": "ccc",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+				"sha512": "ccc",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "conflicting duplicate att digests",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"SHA256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "conflicting duplicate att digests not evaluated",
+			attDigests: intoto.DigestSet{
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
+				"GITCOMMIT": "0000000000000000000000000000000000000000",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "same duplicate att digests",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"SHA256": "AE448AC86C4E8E4DEC645729708EF41873AE79C6DFF84EFF73360989487F08E5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+		},
+		{
+			name: "duplicate input digests",
+			attDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			inputDigests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"SHA256": "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+			},
+			expected: errs.ErrorInvalidInput,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -100,8 +222,8 @@ func Test_verifyDigests(t *testing.T) {
 func Test_Verify(t *testing.T) {
 	t.Parallel()
 	digests := intoto.DigestSet{
-		"sha256":    "another",
-		"gitCommit": "another_com",
+		"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+		"gitCommit": "d83f6526d87d2d52ef4d789b2933c6cd7d9b8981",
 	}
 	subjects := []intoto.Subject{
 		intoto.Subject{
@@ -304,7 +426,7 @@ func Test_Verify(t *testing.T) {
 					Subjects: []intoto.Subject{
 						{
 							Digests: intoto.DigestSet{
-								"sha256": "another",
+								"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 								"":       "mismatch_another_com",
 							},
 						},
@@ -327,7 +449,7 @@ func Test_Verify(t *testing.T) {
 			packageName:        packageName,
 			packageVersion:     packageVersion,
 			digests: intoto.DigestSet{
-				"sha256": "another",
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 				"":       "mismatch_another_com",
 			},
 			expected: errs.ErrorInvalidField,
@@ -341,7 +463,7 @@ func Test_Verify(t *testing.T) {
 					Subjects: []intoto.Subject{
 						{
 							Digests: intoto.DigestSet{
-								"sha256":    "another",
+								"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 								"gitCommit": "",
 							},
 						},
@@ -365,8 +487,8 @@ func Test_Verify(t *testing.T) {
 					Subjects: []intoto.Subject{
 						{
 							Digests: intoto.DigestSet{
-								"sha256":    "another",
-								"gitCommit": "mismatch_another_com",
+								"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+								"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 							},
 						},
 					},
@@ -378,7 +500,7 @@ func Test_Verify(t *testing.T) {
 			packageName:        packageName,
 			packageVersion:     packageVersion,
 			digests: intoto.DigestSet{
-				"sha256":    "another",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
 				"gitCommit": "",
 			},
 			expected: errs.ErrorInvalidField,
@@ -391,8 +513,8 @@ func Test_Verify(t *testing.T) {
 			packageVersion:     packageVersion,
 			buildLevel:         buildLevel,
 			digests: intoto.DigestSet{
-				"sha256":    "not_another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			expected: errs.ErrorMismatch,
 		},
@@ -404,8 +526,8 @@ func Test_Verify(t *testing.T) {
 			packageName:        packageName,
 			packageVersion:     packageVersion,
 			digests: intoto.DigestSet{
-				"sha256":    "another",
-				"gitCommit": "mismatch_another_com",
+				"sha256":    "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+				"gitCommit": "685d6428520757122269412ce6f9c5b7d1911f61",
 			},
 			expected: errs.ErrorMismatch,
 		},
@@ -430,7 +552,7 @@ func Test_Verify(t *testing.T) {
 			packageName:        packageName,
 			packageVersion:     packageVersion,
 			digests: intoto.DigestSet{
-				"gitCommit": "another_com",
+				"gitCommit": "d83f6526d87d2d52ef4d789b2933c6cd7d9b8981",
 			},
 		},
 		{
@@ -607,6 +729,20 @@ func Test_Verify(t *testing.T) {
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+
+			// Verify using the parsed attestation. Structural errors
+			// must be reported by the parsing step.
+			parsed, perr := ParseAndValidate(io.NopCloser(bytes.NewReader(content)))
+			if perr == nil {
+				pverification, err := VerificationFromParsed(parsed, newPackageHelper(tt.att.Predicate.Package.Registry))
+				if err != nil {
+					t.Fatalf("failed to creation verification: %v", err)
+				}
+				perr = pverification.Verify(tt.digests, tt.packageName, options...)
+			}
+			if diff := cmp.Diff(tt.expected, perr, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
 			if err != nil {
 				return
 			}
@@ -625,3 +761,2636 @@ func Test_Verify(t *testing.T) {
 		})
 	}
 }
+
+func Test_PackageNameMatches(t *testing.T) {
+	t.Parallel()
+	registry := "us-docker.pkg.dev"
+	tests := []struct {
+		name        string
+		packageName string
+		pattern     string
+		expected    error
+	}{
+		{
+			name:        "anchored match",
+			packageName: "acme-team/image",
+			pattern:     `^us-docker\.pkg\.dev/acme-.+$`,
+		},
+		{
+			name:        "anchored mismatch",
+			packageName: "acme-team/image",
+			pattern:     `^acme-.+$`,
+			expected:    errs.ErrorMismatch,
+		},
+		{
+			name:        "unanchored match",
+			packageName: "acme-team/image",
+			pattern:     `acme-`,
+		},
+		{
+			name:        "unanchored mismatch",
+			packageName: "other-team/image",
+			pattern:     `acme-`,
+			expected:    errs.ErrorMismatch,
+		},
+		{
+			name:        "unicode match",
+			packageName: "acme-équipe/画像",
+			pattern:     `^us-docker\.pkg\.dev/acme-\p{L}+/\p{Han}+$`,
+		},
+		{
+			name:        "unicode mismatch",
+			packageName: "acme-équipe/画像",
+			pattern:     `^us-docker\.pkg\.dev/acme-[a-z]+/.+$`,
+			expected:    errs.ErrorMismatch,
+		},
+		{
+			name:        "backtracking-prone pattern",
+			packageName: strings.Repeat("a", 10000) + "!",
+			pattern:     `^us-docker\.pkg\.dev/(a+)+$`,
+			expected:    errs.ErrorMismatch,
+		},
+		{
+			name:        "pattern too long",
+			packageName: "acme-team/image",
+			pattern:     strings.Repeat("a", regex.MaxLength+1),
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name:        "invalid pattern",
+			packageName: "acme-team/image",
+			pattern:     `^acme-(`,
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name:        "empty pattern",
+			packageName: "acme-team/image",
+			expected:    errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty attestation package",
+			pattern:  `acme-`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification := Verification{
+				attestation: attestation{
+					Predicate: predicate{
+						Package: intoto.PackageDescriptor{
+							Name:     tt.packageName,
+							Registry: registry,
+						},
+					},
+				},
+				packageHelper: newPackageHelper(registry),
+			}
+			err := PackageNameMatches(tt.pattern)(&verification)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_RejectForeignSubjects(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	smuggledDigests := intoto.DigestSet{
+		"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c",
+	}
+	tests := []struct {
+		name          string
+		subjects      []intoto.Subject
+		packageHelper PackageHelper
+		expected      error
+	}{
+		{
+			name: "single unnamed subject",
+			subjects: []intoto.Subject{
+				{Digests: digests},
+			},
+			packageHelper: newPackageHelper(registry),
+		},
+		{
+			name: "named subjects",
+			subjects: []intoto.Subject{
+				{Name: packageName, Digests: digests},
+				{Name: packageName, Digests: smuggledDigests},
+			},
+			packageHelper: newPackageHelper(registry),
+		},
+		{
+			name: "smuggled subject",
+			subjects: []intoto.Subject{
+				{Name: packageName, Digests: digests},
+				{Name: "other_package", Digests: smuggledDigests},
+			},
+			packageHelper: newPackageHelper(registry),
+			expected:      errs.ErrorInvalidField,
+		},
+		{
+			name: "smuggled unnamed subject",
+			subjects: []intoto.Subject{
+				{Name: packageName, Digests: digests},
+				{Digests: smuggledDigests},
+			},
+			packageHelper: newPackageHelper(registry),
+			expected:      errs.ErrorInvalidField,
+		},
+		{
+			name: "smuggled subject other registry",
+			subjects: []intoto.Subject{
+				{Name: packageName, Digests: digests},
+				{Name: packageName, Digests: smuggledDigests},
+			},
+			packageHelper: newPackageHelper("other_registry"),
+			expected:      errs.ErrorInvalidField,
+		},
+		{
+			name: "nil package helper",
+			subjects: []intoto.Subject{
+				{Digests: digests},
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att := attestation{
+				Header: intoto.Header{
+					Type:          statementType,
+					PredicateType: predicateType,
+					Subjects:      tt.subjects,
+				},
+				Predicate: predicate{
+					CreationTime: intoto.Now(),
+					Package: intoto.PackageDescriptor{
+						Name:     packageName,
+						Registry: registry,
+					},
+				},
+			}
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			// The option is off by default.
+			if err := verification.Verify(digests, packageName); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			err = verification.Verify(digests, packageName, RejectForeignSubjects(tt.packageHelper))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_HasBaseImage(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		baseImages []string
+		prefix     string
+		expected   error
+	}{
+		{
+			name:       "matching prefix",
+			baseImages: []string{"docker.io/library/ubuntu", "gcr.io/distroless/static"},
+			prefix:     "gcr.io/distroless/",
+		},
+		{
+			name:       "full reference",
+			baseImages: []string{"gcr.io/distroless/static@sha256:abcd"},
+			prefix:     "gcr.io/distroless/static@sha256:abcd",
+		},
+		{
+			name:       "mismatch prefix",
+			baseImages: []string{"docker.io/library/ubuntu"},
+			prefix:     "gcr.io/distroless/",
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:     "no base images",
+			prefix:   "gcr.io/distroless/",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:       "empty prefix",
+			baseImages: []string{"gcr.io/distroless/static"},
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var opts []AttestationCreationOption
+			if len(tt.baseImages) > 0 {
+				opts = append(opts, SetBaseImages(tt.baseImages...))
+			}
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry}, opts...)
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, HasBaseImage(tt.prefix))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_SBOM(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	sbomURI := "https://example.com/sbom.spdx.json"
+	sbomDigests := intoto.DigestSet{
+		"sha256": "c6c4880e37de7a133846dec8385d63091786a1225f23c195d8da90ec2f037330",
+		"sha512": "sbom_value512",
+	}
+	tests := []struct {
+		name        string
+		uri         string
+		digests     intoto.DigestSet
+		noSBOM      bool
+		option      VerificationOption
+		creationErr error
+		expected    error
+	}{
+		{
+			name:    "has SBOM",
+			uri:     sbomURI,
+			digests: sbomDigests,
+			option:  HasSBOM(),
+		},
+		{
+			name:     "no SBOM",
+			noSBOM:   true,
+			option:   HasSBOM(),
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "matching digest",
+			uri:     sbomURI,
+			digests: sbomDigests,
+			option:  HasSBOMDigest(intoto.DigestSet{"sha256": "c6c4880e37de7a133846dec8385d63091786a1225f23c195d8da90ec2f037330"}),
+		},
+		{
+			name:    "matching digests",
+			uri:     sbomURI,
+			digests: sbomDigests,
+			option:  HasSBOMDigest(sbomDigests),
+		},
+		{
+			name:     "mismatch digest",
+			uri:      sbomURI,
+			digests:  sbomDigests,
+			option:   HasSBOMDigest(intoto.DigestSet{"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c"}),
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "missing digest",
+			uri:      sbomURI,
+			digests:  sbomDigests,
+			option:   HasSBOMDigest(intoto.DigestSet{"sha384": "sbom_value"}),
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "digest without SBOM",
+			noSBOM:   true,
+			option:   HasSBOMDigest(sbomDigests),
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "empty input digests",
+			uri:      sbomURI,
+			digests:  sbomDigests,
+			option:   HasSBOMDigest(intoto.DigestSet{}),
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:        "empty URI",
+			digests:     sbomDigests,
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name:        "empty digests",
+			uri:         sbomURI,
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name:        "empty digest value",
+			uri:         sbomURI,
+			digests:     intoto.DigestSet{"sha256": ""},
+			creationErr: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var opts []AttestationCreationOption
+			if !tt.noSBOM {
+				opts = append(opts, WithSBOM(tt.uri, tt.digests, "application/spdx+json"))
+			}
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry}, opts...)
+			if diff := cmp.Diff(tt.creationErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, tt.option)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if len(verification.Warnings()) != 0 {
+				t.Fatalf("unexpected warnings: %v", verification.Warnings())
+			}
+		})
+	}
+}
+
+func Test_AdditionalSubjects(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	index := intoto.Subject{
+		Digests: intoto.DigestSet{
+			"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8",
+		},
+	}
+	amd64 := intoto.Subject{
+		Name: "registry/package_name",
+		Digests: intoto.DigestSet{
+			"sha512": "amd64_value512",
+		},
+	}
+	arm64 := intoto.Subject{
+		Digests: intoto.DigestSet{
+			"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c",
+			"sha384": "arm64_value384",
+		},
+	}
+	tests := []struct {
+		name        string
+		subjects    []intoto.Subject
+		digests     intoto.DigestSet
+		count       int
+		creationErr error
+		expected    error
+	}{
+		{
+			name:     "index digest",
+			subjects: []intoto.Subject{amd64, arm64},
+			digests:  index.Digests,
+			count:    3,
+		},
+		{
+			name:     "platform digest",
+			subjects: []intoto.Subject{amd64, arm64},
+			digests:  intoto.DigestSet{"sha512": "amd64_value512"},
+			count:    3,
+		},
+		{
+			name:     "platform digests",
+			subjects: []intoto.Subject{amd64, arm64},
+			digests:  arm64.Digests,
+			count:    3,
+		},
+		{
+			name:     "platform partial digests",
+			subjects: []intoto.Subject{amd64, arm64},
+			digests:  intoto.DigestSet{"sha384": "arm64_value384"},
+			count:    3,
+		},
+		{
+			name:     "digests across subjects",
+			subjects: []intoto.Subject{amd64, arm64},
+			digests:  intoto.DigestSet{"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c", "sha512": "amd64_value512"},
+			count:    3,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "mismatch digest",
+			subjects: []intoto.Subject{amd64, arm64},
+			digests:  intoto.DigestSet{"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c"},
+			count:    3,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "duplicate subjects",
+			subjects: []intoto.Subject{index, amd64, arm64, amd64},
+			digests:  arm64.Digests,
+			count:    3,
+		},
+		{
+			name: "same digests different name",
+			subjects: []intoto.Subject{
+				{Name: "registry/package_name", Digests: index.Digests},
+			},
+			digests: index.Digests,
+			count:   2,
+		},
+		{
+			name:        "no subjects",
+			subjects:    []intoto.Subject{},
+			creationErr: errs.ErrorInvalidInput,
+		},
+		{
+			name:        "empty digests",
+			subjects:    []intoto.Subject{amd64, {Name: "registry/package_name"}},
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty digest value",
+			subjects: []intoto.Subject{
+				{Digests: intoto.DigestSet{"sha256": ""}},
+			},
+			creationErr: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty digest key",
+			subjects: []intoto.Subject{
+				{Digests: intoto.DigestSet{"": "some_value"}},
+			},
+			creationErr: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// NOTE: the option is available in safe mode.
+			att, err := CreationNew(index, intoto.PackageDescriptor{Name: packageName, Registry: registry},
+				EnterSafeMode(), WithAdditionalSubjects(tt.subjects))
+			if diff := cmp.Diff(tt.creationErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.count, len(att.attestation.Header.Subjects)); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			err = verification.Verify(tt.digests, packageName)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_IsPackageVersionAtLeast(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name     string
+		actual   string
+		version  string
+		expected error
+	}{
+		{
+			name:    "equal versions",
+			actual:  "2.4.0",
+			version: "2.4.0",
+		},
+		{
+			name:    "greater version",
+			actual:  "2.10.1",
+			version: "2.4.0",
+		},
+		{
+			name:     "lower version",
+			actual:   "2.3.9",
+			version:  "2.4.0",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "missing patch",
+			actual:  "2.4",
+			version: "2.4.0",
+		},
+		{
+			name:    "missing minor and patch",
+			actual:  "v3",
+			version: "2.4.0",
+		},
+		{
+			name:    "v prefix",
+			actual:  "v2.4.0",
+			version: "2.4",
+		},
+		{
+			name:    "build metadata ignored",
+			actual:  "2.4.0+build.5",
+			version: "2.4.0",
+		},
+		{
+			name:     "pre-release lower than release",
+			actual:   "2.4.0-rc.1",
+			version:  "2.4.0",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "release greater than pre-release",
+			actual:  "2.4.0",
+			version: "2.4.0-rc.1",
+		},
+		{
+			name:    "greater numeric pre-release",
+			actual:  "2.4.0-rc.10",
+			version: "2.4.0-rc.2",
+		},
+		{
+			name:     "numeric pre-release lower than alphanumeric",
+			actual:   "2.4.0-1",
+			version:  "2.4.0-alpha",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "shorter pre-release lower",
+			actual:   "2.4.0-alpha",
+			version:  "2.4.0-alpha.1",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "equal pre-release",
+			actual:  "2.4.0-beta.2",
+			version: "2.4.0-beta.2",
+		},
+		{
+			name:     "invalid version",
+			actual:   "2.4.0",
+			version:  "two",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid attestation version",
+			actual:   "2.4.0.1",
+			version:  "2.4.0",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty attestation version",
+			version:  "2.4.0",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty pre-release identifier",
+			actual:   "2.4.0-rc..1",
+			version:  "2.4.0",
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry, Version: tt.actual})
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, IsPackageVersionAtLeast(tt.version))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PackagePlatform(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	mediaType := "application/vnd.oci.image.manifest.v1+json"
+	tests := []struct {
+		name          string
+		packageDesc   intoto.PackageDescriptor
+		creationOpts  []AttestationCreationOption
+		verifyOpts    []VerificationOption
+		creationErr   error
+		expected      error
+		expectedArch  string
+		expectedOS    string
+		expectedMedia string
+	}{
+		{
+			name:          "options",
+			creationOpts:  []AttestationCreationOption{WithPackageArch("amd64"), WithPackageOS("linux"), WithPackageMediaType(mediaType)},
+			verifyOpts:    []VerificationOption{IsPackageArch("amd64"), IsPackageOS("linux"), IsPackageMediaType(mediaType)},
+			expectedArch:  "amd64",
+			expectedOS:    "linux",
+			expectedMedia: mediaType,
+		},
+		{
+			name:         "descriptor",
+			packageDesc:  intoto.PackageDescriptor{Arch: "amd64", OS: "linux"},
+			verifyOpts:   []VerificationOption{IsPackageArch("amd64"), IsPackageOS("linux")},
+			expectedArch: "amd64",
+			expectedOS:   "linux",
+		},
+		{
+			name:         "same value in descriptor and option",
+			packageDesc:  intoto.PackageDescriptor{Arch: "amd64"},
+			creationOpts: []AttestationCreationOption{WithPackageArch("amd64")},
+			expectedArch: "amd64",
+		},
+		{
+			name:         "same value twice",
+			creationOpts: []AttestationCreationOption{WithPackageOS("linux"), WithPackageOS("linux")},
+			expectedOS:   "linux",
+		},
+		{
+			name:         "different value in descriptor and option",
+			packageDesc:  intoto.PackageDescriptor{Arch: "amd64"},
+			creationOpts: []AttestationCreationOption{WithPackageArch("arm64")},
+			creationErr:  errs.ErrorInvalidInput,
+		},
+		{
+			name:         "different values",
+			creationOpts: []AttestationCreationOption{WithPackageMediaType(mediaType), WithPackageMediaType("other")},
+			creationErr:  errs.ErrorInvalidInput,
+		},
+		{
+			name:         "empty value",
+			creationOpts: []AttestationCreationOption{WithPackageOS("")},
+			creationErr:  errs.ErrorInvalidInput,
+		},
+		{
+			name:         "mismatch arch",
+			creationOpts: []AttestationCreationOption{WithPackageArch("amd64")},
+			verifyOpts:   []VerificationOption{IsPackageArch("arm64")},
+			expected:     errs.ErrorMismatch,
+			expectedArch: "amd64",
+		},
+		{
+			name:       "missing os",
+			verifyOpts: []VerificationOption{IsPackageOS("linux")},
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "empty media type",
+			verifyOpts: []VerificationOption{IsPackageMediaType("")},
+			expected:   errs.ErrorInvalidInput,
+		},
+		{
+			name:         "conflicting options",
+			creationOpts: []AttestationCreationOption{WithPackageArch("amd64")},
+			verifyOpts:   []VerificationOption{IsPackageArch("amd64"), IsPackageArch("arm64")},
+			expected:     errs.ErrorInvalidInput,
+			expectedArch: "amd64",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pkg := tt.packageDesc
+			pkg.Name = packageName
+			pkg.Registry = registry
+			att, err := CreationNew(intoto.Subject{Digests: digests}, pkg, tt.creationOpts...)
+			if diff := cmp.Diff(tt.creationErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			got := verification.attestation.Predicate.Package
+			if diff := cmp.Diff([]string{tt.expectedArch, tt.expectedOS, tt.expectedMedia},
+				[]string{got.Arch, got.OS, got.MediaType}); diff != "" {
+				t.Fatalf("unexpected package (-want +got): \n%s", diff)
+			}
+			err = verification.Verify(digests, packageName, tt.verifyOpts...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_IsPackageRegistry(t *testing.T) {
+	t.Parallel()
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		registry   string
+		verifyOpts []VerificationOption
+		expected   error
+	}{
+		{
+			name:       "same registry",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("registry")},
+		},
+		{
+			name:       "scheme and trailing slash",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("https://Registry/")},
+		},
+		{
+			name:       "attestation scheme and trailing slash",
+			registry:   "http://registry//",
+			verifyOpts: []VerificationOption{IsPackageRegistry("registry")},
+		},
+		{
+			name:       "same registry twice",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("registry"), IsPackageRegistry("https://registry")},
+		},
+		{
+			name:       "mismatch registry",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("other_registry")},
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "empty registry",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("")},
+			expected:   errs.ErrorInvalidInput,
+		},
+		{
+			name:       "scheme only registry",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("https://")},
+			expected:   errs.ErrorInvalidInput,
+		},
+		{
+			name:       "empty attestation registry",
+			registry:   "https://",
+			verifyOpts: []VerificationOption{IsPackageRegistry("registry")},
+			expected:   errs.ErrorInvalidField,
+		},
+		{
+			name:       "conflicting options",
+			registry:   "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("registry"), IsPackageRegistry("other_registry")},
+			expected:   errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: tt.registry})
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(tt.registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, tt.verifyOpts...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_packageHelper(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		registry string
+		expected string
+	}{
+		{
+			name:     "registry",
+			registry: "registry",
+			expected: "registry",
+		},
+		{
+			name:     "trailing slashes",
+			registry: "registry//",
+			expected: "registry",
+		},
+		{
+			name:     "https scheme",
+			registry: "https://registry",
+			expected: "registry",
+		},
+		{
+			name:     "http scheme and uppercase",
+			registry: "HTTP://Registry/",
+			expected: "registry",
+		},
+		{
+			name:     "path",
+			registry: "https://registry/path/",
+			expected: "registry/path",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			helper := newPackageHelper(tt.registry)
+			desc, err := helper.PackageDescriptor("package_name")
+			if err != nil {
+				t.Fatalf("failed to create package descriptor: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, desc.Registry); diff != "" {
+				t.Fatalf("unexpected registry (-want +got): \n%s", diff)
+			}
+			name, err := helper.PolicyPackageName(intoto.PackageDescriptor{Name: "package_name", Registry: tt.registry})
+			if err != nil {
+				t.Fatalf("failed to create policy package name: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected+"/package_name", name); diff != "" {
+				t.Fatalf("unexpected package name (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_AuthorVersion(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name        string
+		options     []AttestationCreationOption
+		version     string
+		creationErr error
+		expected    error
+	}{
+		{
+			name:    "equal version",
+			options: []AttestationCreationOption{WithAuthor("author_id", "v1.2.3")},
+			version: "1.2.3",
+		},
+		{
+			name:    "greater version",
+			options: []AttestationCreationOption{WithAuthor("author_id", "1.10.0")},
+			version: "1.9",
+		},
+		{
+			name:     "lower version",
+			options:  []AttestationCreationOption{WithAuthor("author_id", "1.2.3")},
+			version:  "1.3.0",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "no author",
+			version:  "1.3.0",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "invalid version",
+			options:  []AttestationCreationOption{WithAuthor("author_id", "1.2.3")},
+			version:  "latest",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:        "empty author ID",
+			options:     []AttestationCreationOption{WithAuthor("", "1.2.3")},
+			creationErr: errs.ErrorInvalidInput,
+		},
+		{
+			name:        "invalid author version",
+			options:     []AttestationCreationOption{WithAuthor("author_id", "latest")},
+			creationErr: errs.ErrorInvalidInput,
+		},
+		{
+			name:        "author set twice",
+			options:     []AttestationCreationOption{WithAuthor("author_id", "1.2.3"), WithAuthor("author_id", "1.2.3")},
+			creationErr: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry}, tt.options...)
+			if diff := cmp.Diff(tt.creationErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, IsAuthorVersionAtLeast(tt.version))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_UnknownReservedProperties(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name       string
+		properties properties
+		warnings   int
+		expected   error
+	}{
+		{
+			name: "known properties",
+			properties: properties{
+				buildLevelProperty:    3,
+				verifierCallsProperty: 2,
+			},
+		},
+		{
+			name: "non-reserved properties",
+			properties: properties{
+				"example.com/build/level": 4,
+			},
+		},
+		{
+			name: "unknown reserved property",
+			properties: properties{
+				buildLevelProperty: 3,
+				"slsa.dev/level":   4,
+			},
+			warnings: 1,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "look-alike reserved properties",
+			properties: properties{
+				"SLSA.dev/build/level":  4,
+				" slsa.dev/build/level": 4,
+				"slsa.dev/build/level ": 4,
+			},
+			warnings: 3,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att := attestation{
+				Header: intoto.Header{
+					Type:          statementType,
+					PredicateType: predicateType,
+					Subjects:      []intoto.Subject{{Digests: digests}},
+				},
+				Predicate: predicate{
+					CreationTime: intoto.Now(),
+					Package:      intoto.PackageDescriptor{Name: packageName, Registry: registry},
+					Properties:   tt.properties,
+				},
+			}
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			if diff := cmp.Diff(tt.warnings, len(verification.Warnings())); diff != "" {
+				t.Fatalf("unexpected warnings (-want +got): \n%s", diff)
+			}
+			// The option is off by default.
+			if err := verification.Verify(digests, packageName); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			err = verification.Verify(digests, packageName, RejectUnknownReservedProperties())
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Creation refuses to write unknown reserved properties.
+			creation := Creation{attestation: att}
+			err = creation.validateProperties()
+			if tt.expected != nil {
+				if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to validate properties: %v", err)
+			}
+		})
+	}
+}
+
+func Test_Properties(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	type ticket struct {
+		ID       int    `json:"id"`
+		Priority string `json:"priority"`
+	}
+	properties := []AttestationCreationOption{
+		WithProperty("example.com/team", "team_name"),
+		WithProperty("example.com/reviewers", 2),
+		WithProperty("example.com/ticket", ticket{ID: 1234, Priority: "high"}),
+		SetSlsaBuildLevel(3),
+	}
+	tests := []struct {
+		name     string
+		options  []AttestationCreationOption
+		verify   []VerificationOption
+		expected error
+	}{
+		{
+			name:    "has properties",
+			options: properties,
+			verify: []VerificationOption{
+				HasProperty("example.com/team"),
+				HasProperty(buildLevelProperty),
+			},
+		},
+		{
+			name:     "missing property",
+			options:  properties,
+			verify:   []VerificationOption{HasProperty("example.com/other")},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "empty key",
+			options:  properties,
+			verify:   []VerificationOption{HasProperty("")},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:    "equal string",
+			options: properties,
+			verify:  []VerificationOption{PropertyEquals("example.com/team", "team_name")},
+		},
+		{
+			name:    "equal number",
+			options: properties,
+			verify: []VerificationOption{
+				PropertyEquals("example.com/reviewers", 2),
+				PropertyEquals("example.com/reviewers", int64(2)),
+				PropertyEquals("example.com/reviewers", 2.0),
+				PropertyEquals(buildLevelProperty, 3),
+			},
+		},
+		{
+			name:    "equal nested map",
+			options: properties,
+			verify: []VerificationOption{
+				PropertyEquals("example.com/ticket", ticket{ID: 1234, Priority: "high"}),
+				PropertyEquals("example.com/ticket", map[string]interface{}{"priority": "high", "id": 1234}),
+			},
+		},
+		{
+			name:     "different value",
+			options:  properties,
+			verify:   []VerificationOption{PropertyEquals("example.com/team", "other_team")},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "different type",
+			options:  properties,
+			verify:   []VerificationOption{PropertyEquals("example.com/reviewers", "2")},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "different nested value",
+			options: properties,
+			verify: []VerificationOption{
+				PropertyEquals("example.com/ticket", map[string]interface{}{"priority": "low", "id": 1234}),
+			},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "missing value",
+			options:  properties,
+			verify:   []VerificationOption{PropertyEquals("example.com/other", "team_name")},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "value not serializable",
+			options:  properties,
+			verify:   []VerificationOption{PropertyEquals("example.com/team", func() {})},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "create with empty key",
+			options:  []AttestationCreationOption{WithProperty(" ", "value")},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "create with reserved key",
+			options:  []AttestationCreationOption{WithProperty(buildLevelProperty, 4)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "create with value not serializable",
+			options:  []AttestationCreationOption{WithProperty("example.com/team", make(chan int))},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry}, tt.options...)
+			if err != nil {
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				return
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, tt.verify...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CreationTime(t *testing.T) {
+	t.Parallel()
+	created := "2024-03-01T10:20:30.5+02:00"
+	createdUTC := time.Date(2024, 3, 1, 8, 20, 30, 500000000, time.UTC)
+	tests := []struct {
+		name         string
+		creationTime string
+		options      []VerificationOption
+		expected     error
+	}{
+		{
+			name:         "within window",
+			creationTime: created,
+			options: []VerificationOption{
+				CreatedAfter(createdUTC.Add(-time.Hour)),
+				CreatedBefore(createdUTC.Add(time.Hour)),
+			},
+		},
+		{
+			name:         "window boundaries",
+			creationTime: created,
+			options: []VerificationOption{
+				CreatedAfter(createdUTC),
+				CreatedBefore(createdUTC),
+			},
+		},
+		{
+			name:         "boundaries in another timezone",
+			creationTime: created,
+			options: []VerificationOption{
+				CreatedAfter(createdUTC.In(time.FixedZone("EST", -5*3600))),
+				CreatedBefore(createdUTC.In(time.FixedZone("JST", 9*3600))),
+			},
+		},
+		{
+			name:         "created too early",
+			creationTime: created,
+			options:      []VerificationOption{CreatedAfter(createdUTC.Add(time.Millisecond))},
+			expected:     errs.ErrorMismatch,
+		},
+		{
+			name:         "created too late",
+			creationTime: created,
+			options:      []VerificationOption{CreatedBefore(createdUTC.Add(-time.Millisecond))},
+			expected:     errs.ErrorMismatch,
+		},
+		{
+			name:         "utc without fraction",
+			creationTime: "2024-03-01T08:20:30Z",
+			options:      []VerificationOption{CreatedAfter(createdUTC)},
+			expected:     errs.ErrorMismatch,
+		},
+		{
+			name:     "missing creation time",
+			options:  []VerificationOption{CreatedAfter(createdUTC)},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:         "unparsable creation time",
+			creationTime: "2024-03-01 08:20:30",
+			options:      []VerificationOption{CreatedBefore(createdUTC)},
+			expected:     errs.ErrorInvalidField,
+		},
+		{
+			name:         "zero time",
+			creationTime: created,
+			options:      []VerificationOption{CreatedAfter(time.Time{})},
+			expected:     errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification := Verification{
+				attestation: attestation{
+					Predicate: predicate{
+						CreationTime: tt.creationTime,
+					},
+				},
+			}
+			var err error
+			for _, option := range tt.options {
+				if err = option(&verification); err != nil {
+					break
+				}
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_VerificationNewBundle(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	statement := func(version string) string {
+		att, err := CreationNew(intoto.Subject{Digests: digests},
+			intoto.PackageDescriptor{Name: packageName, Registry: registry, Version: version})
+		if err != nil {
+			t.Fatalf("failed to create attestation: %v", err)
+		}
+		content, err := att.ToBytes()
+		if err != nil {
+			t.Fatalf("failed to get attestation bytes: %v", err)
+		}
+		return string(content)
+	}
+	envelope := func(statement string) string {
+		return `{"payloadType":"` + intoto.PayloadType + `","payload":"` +
+			base64.StdEncoding.EncodeToString([]byte(statement)) + `","signatures":[]}`
+	}
+	v1 := statement("1.0.0")
+	v2 := statement("2.0.0")
+	other := `{"_type":"https://in-toto.io/Statement/v1","predicateType":"https://slsa.dev/deployment/v0.1"}`
+	tests := []struct {
+		name      string
+		entries   []string
+		version   string
+		skipped   int
+		expected  error
+		verifyErr error
+	}{
+		{
+			name:    "first attestation matches",
+			entries: []string{v1, v2},
+			version: "1.0.0",
+		},
+		{
+			name:    "last attestation matches",
+			entries: []string{"not json", other, envelope(v1), v2},
+			version: "2.0.0",
+			skipped: 1,
+		},
+		{
+			name:    "envelope matches",
+			entries: []string{v2, envelope(v1)},
+			version: "1.0.0",
+		},
+		{
+			name:      "no attestation matches",
+			entries:   []string{v1, envelope(v2)},
+			version:   "3.0.0",
+			verifyErr: errs.ErrorMismatch,
+		},
+		{
+			name:     "no publish attestation",
+			entries:  []string{"not json", other},
+			expected: errs.ErrorNotFound,
+		},
+		{
+			name:     "empty bundle",
+			expected: errs.ErrorNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reader := io.NopCloser(strings.NewReader(strings.Join(tt.entries, "\n")))
+			bundle, err := VerificationNewBundle(reader, newPackageHelper(registry))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if len(bundle.Skipped()) != tt.skipped {
+				t.Fatalf("unexpected skipped entries: %v", bundle.Skipped())
+			}
+			// NOTE: the envelopes are not signed.
+			err = bundle.Verify(digests, packageName, IsPackageVersion(tt.version), AllowUnsignedDSSE())
+			if diff := cmp.Diff(tt.verifyErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil && len(errs.Violations(err)) != len(tt.entries) {
+				t.Fatalf("unexpected number of errors: %v", err)
+			}
+		})
+	}
+}
+
+func Test_VerifyAttestation(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	pkg := intoto.PackageDescriptor{Name: packageName, Registry: registry, Version: "1.2.3"}
+	tests := []struct {
+		name     string
+		version  string
+		expected error
+	}{
+		{
+			name:    "verified",
+			version: "1.2.3",
+		},
+		{
+			name:     "not verified",
+			version:  "1.0.0",
+			expected: errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests}, pkg, SetSlsaBuildLevel(3))
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			verified, err := verification.VerifyAttestation(context.Background(), digests, packageName,
+				IsPackageVersion(tt.version))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				if verified != nil {
+					t.Fatalf("unexpected view of an attestation not verified: %v", verified)
+				}
+				return
+			}
+			if diff := cmp.Diff([]intoto.Subject{{Digests: digests}}, verified.Subjects); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(pkg, verified.Package); diff != "" {
+				t.Fatalf("unexpected package (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(float64(3), verified.Properties[buildLevelProperty]); diff != "" {
+				t.Fatalf("unexpected level (-want +got): \n%s", diff)
+			}
+			if verified.CreationTime.IsZero() {
+				t.Fatalf("creation time is zero")
+			}
+			// The view does not alias the attestation.
+			verified.Subjects[0].Digests["sha256"] = "modified"
+			verified.Properties[buildLevelProperty] = 4
+			if err := verification.Verify(digests, packageName, IsSlsaBuildLevel(3)); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+		})
+	}
+}
+
+func Test_VerifyAttestationConcurrent(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	pkg := intoto.PackageDescriptor{Name: packageName, Registry: registry, Version: "1.2.3"}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, pkg, SetSlsaBuildLevel(3))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	// NOTE: calls that succeed and fail interleave, so each
+	// call must only observe the result of its own verification.
+	var wg sync.WaitGroup
+	errCh := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(succeed bool) {
+			defer wg.Done()
+			version := "1.0.0"
+			if succeed {
+				version = "1.2.3"
+			}
+			verified, err := verification.VerifyAttestation(context.Background(), digests, packageName,
+				IsPackageVersion(version))
+			switch {
+			case succeed && (err != nil || verified == nil):
+				errCh <- fmt.Errorf("verification (%q) failed: %v", version, err)
+			case !succeed && (!errors.Is(err, errs.ErrorMismatch) || verified != nil):
+				errCh <- fmt.Errorf("verification (%q) succeeded: %v", version, err)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+}
+
+type fakeTransparencyLog struct {
+	entries map[string]string
+	err     error
+}
+
+func (l *fakeTransparencyLog) Upload(ctx context.Context, envelope []byte) (string, error) {
+	if l.err != nil {
+		return "", l.err
+	}
+	id := fmt.Sprintf("entry%d", len(l.entries))
+	l.entries[string(envelope)] = id
+	return id, nil
+}
+
+func (l *fakeTransparencyLog) VerifyInclusion(ctx context.Context, envelope []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.err != nil {
+		return l.err
+	}
+	if _, exists := l.entries[string(envelope)]; !exists {
+		return fmt.Errorf("entry not found")
+	}
+	return nil
+}
+
+func Test_TransparencyLog(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	registry := "registry"
+	packageName := "package_name"
+	logErr := errors.New("log error")
+	tests := []struct {
+		name      string
+		uploadLog *fakeTransparencyLog
+		verifyLog func(uploaded *fakeTransparencyLog) []VerificationOption
+		digests   intoto.DigestSet
+		canceled  bool
+		uploadErr error
+		expected  error
+	}{
+		{
+			name:      "no transparency log",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return nil
+			},
+		},
+		{
+			name:      "entry recorded",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(uploaded *fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(uploaded)}
+			},
+		},
+		{
+			name:      "entry not recorded",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(
+					&fakeTransparencyLog{entries: map[string]string{}})}
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:      "log unavailable",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(
+					&fakeTransparencyLog{err: logErr})}
+			},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:      "mismatch before log",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(
+					&fakeTransparencyLog{err: logErr})}
+			},
+			digests:  intoto.DigestSet{"sha256": "77a73f170325fc6a4dc852ca8c5f0fdf45633eeb6dee51a903716a58d4d48e9c"},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:      "canceled context",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(uploaded *fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(uploaded)}
+			},
+			canceled: true,
+			expected: context.Canceled,
+		},
+		{
+			name:      "nil log",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(*fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{RequireTransparencyLog(nil)}
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:      "log set twice",
+			uploadLog: &fakeTransparencyLog{entries: map[string]string{}},
+			verifyLog: func(uploaded *fakeTransparencyLog) []VerificationOption {
+				return []VerificationOption{
+					RequireTransparencyLog(uploaded),
+					RequireTransparencyLog(uploaded),
+				}
+			},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:      "upload failure",
+			uploadLog: &fakeTransparencyLog{err: logErr},
+			uploadErr: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry}, WithTransparencyLog(tt.uploadLog))
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v", err)
+			}
+			entryID, err := att.Upload(context.Background(), content)
+			if diff := cmp.Diff(tt.uploadErr, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if entryID == "" {
+				t.Fatalf("empty entry ID")
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			verifyDigests := digests
+			if tt.digests != nil {
+				verifyDigests = tt.digests
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			err = verification.VerifyContext(ctx, verifyDigests, packageName, tt.verifyLog(tt.uploadLog)...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Upload(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		log      TransparencyLog
+		envelope []byte
+		expected error
+	}{
+		{
+			name:     "uploaded",
+			log:      &fakeTransparencyLog{entries: map[string]string{}},
+			envelope: []byte("envelope"),
+		},
+		{
+			name:     "no log",
+			envelope: []byte("envelope"),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty envelope",
+			log:      &fakeTransparencyLog{entries: map[string]string{}},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty entry ID",
+			log:      emptyIDTransparencyLog{},
+			envelope: []byte("envelope"),
+			expected: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var att Creation
+			if tt.log != nil {
+				if err := WithTransparencyLog(tt.log)(&att); err != nil {
+					t.Fatalf("failed to set log: %v", err)
+				}
+			}
+			_, err := att.Upload(context.Background(), tt.envelope)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+type emptyIDTransparencyLog struct{}
+
+func (emptyIDTransparencyLog) Upload(context.Context, []byte) (string, error) {
+	return "", nil
+}
+
+func (emptyIDTransparencyLog) VerifyInclusion(context.Context, []byte) error {
+	return nil
+}
+
+func Test_DSSE(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	registry := "registry"
+	packageName := "package_name"
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	verifier, err := intoto.PublicKeysVerifierNew(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	otherVerifier, err := intoto.PublicKeysVerifierNew(&otherKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests},
+		intoto.PackageDescriptor{Name: packageName, Registry: registry})
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	hash := sha256.Sum256(intoto.PAE(intoto.PayloadType, statement))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	envelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	badSig := bytes.Clone(sig)
+	badSig[len(badSig)-1] ^= 0xff
+	badEnvelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: base64.StdEncoding.EncodeToString(badSig)}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	tests := []struct {
+		name     string
+		content  []byte
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:    "statement",
+			content: statement,
+		},
+		{
+			name:    "statement allow unsigned",
+			content: statement,
+			options: []VerificationOption{AllowUnsignedDSSE()},
+		},
+		{
+			name:     "statement with verifier",
+			content:  statement,
+			options:  []VerificationOption{WithDSSEVerifier(verifier)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:    "envelope with verifier",
+			content: envelope,
+			options: []VerificationOption{WithDSSEVerifier(verifier)},
+		},
+		{
+			name:    "envelope allow unsigned",
+			content: envelope,
+			options: []VerificationOption{AllowUnsignedDSSE()},
+		},
+		{
+			name:     "envelope without verifier",
+			content:  envelope,
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "envelope with wrong key",
+			content:  envelope,
+			options:  []VerificationOption{WithDSSEVerifier(otherVerifier)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "envelope with bad signature",
+			content:  badEnvelope,
+			options:  []VerificationOption{WithDSSEVerifier(verifier)},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "nil verifier",
+			content:  envelope,
+			options:  []VerificationOption{WithDSSEVerifier(nil)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "verifier and allow unsigned",
+			content:  envelope,
+			options:  []VerificationOption{WithDSSEVerifier(verifier), AllowUnsignedDSSE()},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(tt.content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Verify(digests, packageName, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Parsed attestations behave the same.
+			parsed, err := ParseAndValidate(io.NopCloser(bytes.NewReader(tt.content)))
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			pverification, err := VerificationFromParsed(parsed, newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = pverification.Verify(digests, packageName, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// So do bundles.
+			// NOTE: bundles are newline-delimited.
+			var entry bytes.Buffer
+			if err := json.Compact(&entry, tt.content); err != nil {
+				t.Fatalf("failed to compact attestation: %v", err)
+			}
+			bundle, err := VerificationNewBundle(io.NopCloser(&entry), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create bundle: %v", err)
+			}
+			err = bundle.Verify(digests, packageName, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PredicateVersion(t *testing.T) {
+	t.Parallel()
+	digests := intoto.DigestSet{
+		"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4",
+	}
+	registry := "docker.io/slsa-framework"
+	packageName := "slsa-project-echo-server"
+	packageDesc := intoto.PackageDescriptor{
+		Name:     packageName,
+		Registry: registry,
+	}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, packageDesc, SetSlsaBuildLevel(3))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	current, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	v01, err := os.ReadFile("testdata/attestation_v0.1.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	unknown := bytes.ReplaceAll(v01, []byte(predicateTypeV01), []byte("https://slsa.dev/publish/v0.3"))
+	tests := []struct {
+		name     string
+		content  []byte
+		version  string
+		expected error
+	}{
+		{
+			name:    "current version",
+			content: current,
+			version: "v0.2",
+		},
+		{
+			name:    "previous version",
+			content: v01,
+			version: "v0.1",
+		},
+		{
+			name:     "unknown version",
+			content:  unknown,
+			expected: errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(tt.content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			if diff := cmp.Diff(tt.version, verification.PredicateVersion()); diff != "" {
+				t.Fatalf("unexpected version (-want +got): \n%s", diff)
+			}
+			err = verification.Verify(digests, packageName, IsSlsaBuildLevelOrAbove(3))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			// Parsed attestations and bundles.
+			parsed, err := ParseAndValidate(io.NopCloser(bytes.NewReader(tt.content)))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.version, parsed.PredicateVersion()); diff != "" {
+				t.Fatalf("unexpected version (-want +got): \n%s", diff)
+			}
+			// NOTE: bundles are newline-delimited.
+			var entry bytes.Buffer
+			if err := json.Compact(&entry, tt.content); err != nil {
+				t.Fatalf("failed to compact attestation: %v", err)
+			}
+			bundle, err := VerificationNewBundle(io.NopCloser(&entry), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create bundle: %v", err)
+			}
+			if err := bundle.Verify(digests, packageName, IsSlsaBuildLevelOrAbove(3)); err != nil {
+				t.Fatalf("failed to verify bundle: %v", err)
+			}
+		})
+	}
+}
+
+func newConcurrentVerification(t testing.TB, registry, packageName string) *Verification {
+	index := intoto.Subject{
+		Digests: intoto.DigestSet{
+			"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8",
+		},
+	}
+	arm64 := intoto.Subject{
+		Digests: intoto.DigestSet{
+			"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c",
+			"sha512": "arm64_value512",
+		},
+	}
+	att, err := CreationNew(index, intoto.PackageDescriptor{Name: packageName, Registry: registry, Environment: "prod"},
+		SetSlsaBuildLevel(3), SetPackageVersion("1.2.3"), WithAdditionalSubjects([]intoto.Subject{arm64}),
+		WithSBOM("https://example.com/sbom.spdx.json", intoto.DigestSet{"sha256": "c6c4880e37de7a133846dec8385d63091786a1225f23c195d8da90ec2f037330"}, ""))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	content, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+	if err != nil {
+		t.Fatalf("failed to create verification: %v", err)
+	}
+	return verification
+}
+
+func Test_VerifyConcurrent(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	verification := newConcurrentVerification(t, registry, packageName)
+	tests := []struct {
+		name     string
+		digests  intoto.DigestSet
+		options  []VerificationOption
+		expected error
+	}{
+		{
+			name:    "index",
+			digests: intoto.DigestSet{"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8"},
+		},
+		{
+			name:    "platform",
+			digests: intoto.DigestSet{"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c", "sha512": "arm64_value512"},
+			options: []VerificationOption{IsSlsaBuildLevelOrAbove(2), IsPackageEnvironment("prod")},
+		},
+		{
+			name:     "mismatch digest",
+			digests:  intoto.DigestSet{"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c", "sha512": "index_value"},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "mismatch environment",
+			digests:  intoto.DigestSet{"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8"},
+			options:  []VerificationOption{IsPackageEnvironment("dev")},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "version and SBOM",
+			digests: intoto.DigestSet{"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8"},
+			options: []VerificationOption{IsPackageVersionAtLeast("1.2"),
+				HasSBOMDigest(intoto.DigestSet{"sha256": "c6c4880e37de7a133846dec8385d63091786a1225f23c195d8da90ec2f037330"})},
+		},
+		{
+			name:     "mismatch level",
+			digests:  intoto.DigestSet{"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8"},
+			options:  []VerificationOption{IsSlsaBuildLevel(2)},
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "conflicting options",
+			digests:  intoto.DigestSet{"sha256": "382b5295d2277150ce6e4122615d18546991d5697a2c5d905be669982efa04d8"},
+			options:  []VerificationOption{IsSlsaBuildLevel(2), IsSlsaBuildLevel(3)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	// NOTE: each goroutine runs every test, in a different order.
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := range tests {
+				tt := &tests[(i+j)%len(tests)]
+				err := verification.Verify(tt.digests, packageName, tt.options...)
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Errorf("%s: unexpected err (-want +got): \n%s", tt.name, diff)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_VerifyAllocations(t *testing.T) {
+	// NOTE: not parallel, since allocations are counted globally.
+	packageName := "package_name"
+	verification := newConcurrentVerification(t, "registry", packageName)
+	digests := intoto.DigestSet{"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c"}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := verification.Verify(digests, packageName); err != nil {
+			t.Fatalf("failed to verify: %v", err)
+		}
+	})
+	if diff := cmp.Diff(float64(0), allocs); diff != "" {
+		t.Fatalf("unexpected allocations (-want +got): \n%s", diff)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	packageName := "package_name"
+	verification := newConcurrentVerification(b, "registry", packageName)
+	digests := intoto.DigestSet{"sha256": "52f8e338bb91cf152b45e7ba738c8f38c89f9f93d7d405188547aa4e94e1ef1c"}
+	benchmarks := []struct {
+		name    string
+		options []VerificationOption
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name:    "options",
+			options: []VerificationOption{IsSlsaBuildLevelOrAbove(3), IsPackageEnvironment("prod")},
+		},
+	}
+	for _, bb := range benchmarks {
+		bb := bb // Re-initializing variable so it is not changed while executing the closure below
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := verification.Verify(digests, packageName, bb.options...); err != nil {
+					b.Fatalf("failed to verify: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_MaxAttestationSize(t *testing.T) {
+	t.Parallel()
+	content, err := os.ReadFile("testdata/attestation_v0.1.json")
+	if err != nil {
+		t.Fatalf("failed to read attestation: %v", err)
+	}
+	tests := []struct {
+		name     string
+		options  []ParseOption
+		expected error
+	}{
+		{
+			name: "default size",
+		},
+		{
+			name:    "attestation within size",
+			options: []ParseOption{WithMaxAttestationSize(int64(len(content)))},
+		},
+		{
+			name:     "attestation larger than size",
+			options:  []ParseOption{WithMaxAttestationSize(int64(len(content) - 1))},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid size",
+			options:  []ParseOption{WithMaxAttestationSize(0)},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper("docker.io/org"), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			_, err = ParseAndValidate(io.NopCloser(bytes.NewReader(content)), tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func FuzzVerificationNew(f *testing.F) {
+	seed, err := os.ReadFile("testdata/attestation_v0.1.json")
+	if err != nil {
+		f.Fatalf("failed to read attestation: %v", err)
+	}
+	f.Add(seed)
+	f.Add([]byte(`{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://slsa.dev/publish/v0.2",
+		"subject": [{"digest": {"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4"}}],
+		"predicate": {"creationTime": "2024-05-01T10:00:00Z",
+			"package": {"name": "echo-server", "registry": "docker.io/org", "version": "1.2.3", "environment": "prod"},
+			"author": {"id": "evaluator", "version": "v1.0.0"},
+			"properties": {"slsa.dev/build/level": 3, "slsa.dev/build/baseImages": ["docker.io/library/alpine"],
+				"slsa.dev/publish/sbom": {"uri": "sbom.json", "digest": {"sha256": "abc"}}}}}`))
+	f.Add([]byte(`{"payloadType": "application/vnd.in-toto+json", "payload": "e30=", "signatures": [{"sig": "c2ln"}]}`))
+	f.Add([]byte(`{"predicate": {"properties": null, "package": null}}`))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper("docker.io/org"))
+		if err != nil {
+			return
+		}
+		digests := intoto.DigestSet{"sha256": "4378b3d11e11ede0f64946e588c590e460e44f90c8a7921ad2cb7b04aaf298d4"}
+		if len(verification.attestation.Header.Subjects) > 0 && len(verification.attestation.Header.Subjects[0].Digests) > 0 {
+			digests = verification.attestation.Header.Subjects[0].Digests
+		}
+		// NOTE: the result does not matter, only that
+		// no attestation makes the verification panic.
+		_ = verification.Verify(digests, "docker.io/org/echo-server",
+			IsPackageEnvironment("prod"),
+			IsPackageArch("amd64"),
+			IsPackageOS("linux"),
+			IsPackageMediaType("application/vnd.oci.image.manifest.v1+json"),
+			IsPackageRegistry("docker.io/org"),
+			IsPackageVersion("1.2.3"),
+			IsPackageVersionAtLeast("1.0.0"),
+			IsAuthorVersionAtLeast("v1.0.0"),
+			IsSlsaBuildLevelOrAbove(1),
+			HasBaseImage("docker.io/library/"),
+			HasSBOMDigest(intoto.DigestSet{"sha256": "abc"}),
+			HasProperty(buildLevelProperty),
+			PropertyEquals(buildLevelProperty, 3),
+			RejectForeignSubjects(newPackageHelper("docker.io/org")),
+			PackageNameMatches("^docker.io/org/.*$"),
+			RejectUnknownReservedProperties(),
+			CreatedAfter(time.Unix(0, 0)),
+		)
+		_ = verification.Verify(digests, "docker.io/org/echo-server", HasSBOM())
+		_ = verification.Warnings()
+		_ = verification.PredicateVersion()
+		_, _ = verification.EvaluationDuration()
+		_, _ = verification.VerifierCalls()
+	})
+}
+
+func Test_VerificationErrorCheck(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	tests := []struct {
+		name        string
+		mutate      func(*attestation)
+		digests     intoto.DigestSet
+		packageName string
+		verifyOpts  []VerificationOption
+		check       errs.Check
+		expected    error
+	}{
+		{
+			name:     "statement type",
+			mutate:   func(a *attestation) { a.Header.Type = "other_type" },
+			check:    errs.CheckStatementType,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "predicate type",
+			mutate:   func(a *attestation) { a.Header.PredicateType = "other_type" },
+			check:    errs.CheckPredicateType,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name: "digest",
+			digests: intoto.DigestSet{
+				"sha256": "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5",
+			},
+			check:    errs.CheckDigest,
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:        "package name",
+			packageName: "other_name",
+			check:       errs.CheckSubjectURI,
+			expected:    errs.ErrorMismatch,
+		},
+		{
+			name:       "registry",
+			verifyOpts: []VerificationOption{IsPackageRegistry("other_registry")},
+			check:      errs.CheckSubjectURI,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "environment",
+			verifyOpts: []VerificationOption{IsPackageEnvironment("dev")},
+			check:      errs.CheckEnvironment,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "version",
+			verifyOpts: []VerificationOption{IsPackageVersion("2.0.0")},
+			check:      errs.CheckVersion,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "arch",
+			verifyOpts: []VerificationOption{IsPackageArch("arm64")},
+			check:      errs.CheckPackage,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "build level",
+			verifyOpts: []VerificationOption{IsSlsaBuildLevel(2)},
+			check:      errs.CheckBuildLevel,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "author",
+			verifyOpts: []VerificationOption{IsAuthorVersionAtLeast("v2.0.0")},
+			check:      errs.CheckAuthor,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "base image",
+			verifyOpts: []VerificationOption{HasBaseImage("gcr.io/distroless/")},
+			check:      errs.CheckBaseImage,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "sbom",
+			verifyOpts: []VerificationOption{HasSBOM()},
+			check:      errs.CheckSBOM,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "property",
+			verifyOpts: []VerificationOption{HasProperty("team")},
+			check:      errs.CheckProperty,
+			expected:   errs.ErrorMismatch,
+		},
+		{
+			name:       "creation time",
+			verifyOpts: []VerificationOption{CreatedAfter(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))},
+			check:      errs.CheckCreationTime,
+			expected:   errs.ErrorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			att, err := CreationNew(intoto.Subject{Digests: digests},
+				intoto.PackageDescriptor{Name: packageName, Registry: registry, Environment: "prod"},
+				SetPackageVersion("1.0.0"), WithPackageArch("amd64"), WithAuthor("author_id", "v1.0.0"),
+				SetSlsaBuildLevel(3), WithCreationTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+			if err != nil {
+				t.Fatalf("failed to create attestation: %v", err)
+			}
+			if tt.mutate != nil {
+				tt.mutate(&att.attestation)
+			}
+			content, err := att.ToBytes()
+			if err != nil {
+				t.Fatalf("failed to get attestation bytes: %v\n", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to creation verification: %v", err)
+			}
+			ds := digests
+			if tt.digests != nil {
+				ds = tt.digests
+			}
+			name := packageName
+			if tt.packageName != "" {
+				name = tt.packageName
+			}
+			err = verification.Verify(ds, name, tt.verifyOpts...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			var verificationErr *errs.VerificationError
+			if !errors.As(err, &verificationErr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if diff := cmp.Diff(tt.check, verificationErr.Check); diff != "" {
+				t.Fatalf("unexpected check (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	creation, err := CreationNew(intoto.Subject{Name: packageName, Digests: digests},
+		intoto.PackageDescriptor{Name: packageName, Registry: registry, Environment: "prod"},
+		SetPackageVersion("1.2.3"), WithAuthor("author_id", "1.0.0"), SetSlsaBuildLevel(3),
+		SetBaseImages("registry/base"), SetBuilder("builder_id", "builder", "git+https://github.com/org/builder"),
+		WithSBOM("https://example.com/sbom.json", digests, "application/spdx+json"), WithTelemetryProperties(),
+		setTelemetry(telemetry{duration: time.Second, verifierCalls: 2}))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	attBytes, err := creation.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	tests := []struct {
+		name       string
+		modify     func(att *attestation)
+		violations int
+	}{
+		{
+			name:   "valid",
+			modify: func(*attestation) {},
+		},
+		{
+			name: "no properties",
+			modify: func(att *attestation) {
+				att.Predicate.Properties = nil
+				att.Predicate.Author = nil
+			},
+		},
+		{
+			name:       "invalid types",
+			modify:     func(att *attestation) { att.Header.Type, att.Header.PredicateType = "other", "other" },
+			violations: 2,
+		},
+		{
+			name:       "no subjects",
+			modify:     func(att *attestation) { att.Header.Subjects = nil },
+			violations: 1,
+		},
+		{
+			name: "invalid additional subjects",
+			modify: func(att *attestation) {
+				att.Header.Subjects = append(att.Header.Subjects,
+					intoto.Subject{Name: packageName},
+					intoto.Subject{Name: packageName, Digests: intoto.DigestSet{"sha256": "a", "SHA256": "b"}})
+			},
+			violations: 2,
+		},
+		{
+			name: "foreign subject",
+			modify: func(att *attestation) {
+				att.Header.Subjects = append(att.Header.Subjects,
+					intoto.Subject{Name: "other_package", Digests: digests})
+			},
+			violations: 1,
+		},
+		{
+			name:       "invalid creation time",
+			modify:     func(att *attestation) { att.Predicate.CreationTime = "yesterday" },
+			violations: 1,
+		},
+		{
+			name:       "no package registry",
+			modify:     func(att *attestation) { att.Predicate.Package.Registry = "" },
+			violations: 1,
+		},
+		{
+			name: "package annotations with spaces",
+			modify: func(att *attestation) {
+				att.Predicate.Package.Environment = " prod"
+				att.Predicate.Package.Version = "1.2.3 "
+			},
+			violations: 2,
+		},
+		{
+			name: "invalid author",
+			modify: func(att *attestation) {
+				att.Predicate.Author.ID = ""
+				att.Predicate.Author.Version = "latest"
+			},
+			violations: 2,
+		},
+		{
+			name: "invalid properties",
+			modify: func(att *attestation) {
+				att.Predicate.Properties[buildLevelProperty] = 2.5
+				att.Predicate.Properties[baseImagesProperty] = []interface{}{}
+				att.Predicate.Properties[builderProperty] = map[string]interface{}{"id": "builder_id"}
+				att.Predicate.Properties[sbomProperty] = "sbom"
+				att.Predicate.Properties[verifierCallsProperty] = "2"
+			},
+			violations: 5,
+		},
+		{
+			name:       "level too large",
+			modify:     func(att *attestation) { att.Predicate.Properties[buildLevelProperty] = 5 },
+			violations: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var att attestation
+			if err := json.Unmarshal(attBytes, &att); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			tt.modify(&att)
+			content, err := json.Marshal(att)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			err = verification.Validate()
+			if diff := cmp.Diff(tt.violations, len(errs.Violations(err))); diff != "" {
+				t.Fatalf("unexpected violations (-want +got): %v\n%s", err, diff)
+			}
+			for _, violation := range errs.Violations(err) {
+				if !errors.Is(violation, errs.ErrorInvalidField) {
+					t.Fatalf("unexpected violation: %v", violation)
+				}
+			}
+		})
+	}
+}
+
+func Test_Content(t *testing.T) {
+	t.Parallel()
+	registry := "registry"
+	packageName := "package_name"
+	digests := intoto.DigestSet{
+		"sha256": "bf8260204a85f123e8c486c01057463ae681906de652202e82c7aa25d9e06bfe",
+	}
+	pkg := intoto.PackageDescriptor{Name: packageName, Registry: registry, Version: "1.2.3"}
+	att, err := CreationNew(intoto.Subject{Digests: digests}, pkg, SetSlsaBuildLevel(3),
+		WithAuthor("evaluator", "1.0.0"))
+	if err != nil {
+		t.Fatalf("failed to create attestation: %v", err)
+	}
+	statement, err := att.ToBytes()
+	if err != nil {
+		t.Fatalf("failed to get attestation bytes: %v", err)
+	}
+	envelope, err := json.Marshal(intoto.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []intoto.Signature{{Sig: "c2ln"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	tests := []struct {
+		name       string
+		content    []byte
+		dsse       bool
+		signatures int
+	}{
+		{
+			name:    "statement",
+			content: statement,
+		},
+		{
+			name:       "envelope",
+			content:    envelope,
+			dsse:       true,
+			signatures: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verification, err := VerificationNew(io.NopCloser(bytes.NewReader(tt.content)), newPackageHelper(registry))
+			if err != nil {
+				t.Fatalf("failed to create verification: %v", err)
+			}
+			// Available before verification.
+			content := verification.Content()
+			if diff := cmp.Diff(predicateType, content.PredicateType); diff != "" {
+				t.Fatalf("unexpected predicate type (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(predicateVersion, content.PredicateVersion); diff != "" {
+				t.Fatalf("unexpected predicate version (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff([]intoto.Subject{{Digests: digests}}, content.Subjects); diff != "" {
+				t.Fatalf("unexpected subjects (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(pkg, content.Package); diff != "" {
+				t.Fatalf("unexpected package (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff([]string{"evaluator", "1.0.0"}, []string{content.AuthorID, content.AuthorVersion}); diff != "" {
+				t.Fatalf("unexpected author (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(float64(3), content.Properties[buildLevelProperty]); diff != "" {
+				t.Fatalf("unexpected level (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.dsse, content.DSSE); diff != "" {
+				t.Fatalf("unexpected dsse (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.signatures, content.Signatures); diff != "" {
+				t.Fatalf("unexpected signatures (-want +got): \n%s", diff)
+			}
+			// The view does not alias the attestation.
+			content.Subjects[0].Digests["sha256"] = "modified"
+			content.Properties[buildLevelProperty] = 4
+			if err := verification.Verify(digests, packageName, IsSlsaBuildLevel(3), AllowUnsignedDSSE()); err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+		})
+	}
+}
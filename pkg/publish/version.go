@@ -0,0 +1,47 @@
+package publish
+
+// Predicate versions. Attestations are created with the current
+// version, and verification accepts the current and previous versions.
+const (
+	predicateVersion    = "v0.2"
+	predicateVersionV01 = "v0.1"
+)
+
+// translate converts an attestation of a previous predicate version
+// into the current version, so that verification only handles the
+// current format, and records the version it was created with.
+// It returns false if the predicate type is unknown, in which case
+// the attestation is left unchanged and fails validation.
+func (a *attestation) translate() bool {
+	switch a.Header.PredicateType {
+	case predicateType:
+		a.version = predicateVersion
+	case predicateTypeV01:
+		a.translateV01()
+		a.version = predicateVersionV01
+	default:
+		return false
+	}
+	return true
+}
+
+// translateV01 converts a v0.1 attestation. The v0.2 predicate
+// has the same fields as v0.1, so only the predicate type changes.
+// NOTE: v0.1 attestations may lack the properties introduced since,
+// in which case the options that verify them fail.
+func (a *attestation) translateV01() {
+	a.Header.PredicateType = predicateType
+}
+
+// PredicateVersion returns the predicate version the attestation was
+// created with, e.g. "v0.1" for an attestation translated from a
+// previous version. It is empty if the predicate type is unknown.
+func (v *Verification) PredicateVersion() string {
+	return v.attestation.version
+}
+
+// PredicateVersion returns the predicate version
+// the attestation was created with.
+func (p *ParsedAttestation) PredicateVersion() string {
+	return p.attestation.version
+}
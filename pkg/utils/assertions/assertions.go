@@ -0,0 +1,144 @@
+package assertions
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Operators supported by assertions.
+const (
+	OperatorEq       = "eq"
+	OperatorGte      = "gte"
+	OperatorLte      = "lte"
+	OperatorContains = "contains"
+)
+
+var operators = []string{OperatorEq, OperatorGte, OperatorLte, OperatorContains}
+
+// Assertion defines a check on a field of a policy file.
+// Path is a dot-separated list of JSON field names and
+// array indices, e.g. "packages.0.name".
+type Assertion struct {
+	Path     string      `json:"path"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+func (a Assertion) String() string {
+	value, _ := json.Marshal(a.Value)
+	return fmt.Sprintf("%s %s %s", a.Path, a.Operator, value)
+}
+
+// Evaluate evaluates the assertions against the JSON
+// serialization of policy. Assertions with an unknown operator
+// or a path that cannot be resolved are rejected.
+func Evaluate(assertions []Assertion, policy interface{}) error {
+	if len(assertions) == 0 {
+		return nil
+	}
+	content, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal: %v", errs.ErrorInternal, err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal: %v", errs.ErrorInternal, err)
+	}
+	for i := range assertions {
+		if err := assertions[i].evaluate(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a Assertion) evaluate(doc interface{}) error {
+	if a.Path == "" {
+		return fmt.Errorf("%w: assertion (%s) has an empty path", errs.ErrorInvalidField, a)
+	}
+	if !slices.Contains(operators, a.Operator) {
+		return fmt.Errorf("%w: assertion (%s) has unknown operator (%q). Must be one of %q",
+			errs.ErrorInvalidField, a, a.Operator, operators)
+	}
+	actual, err := resolve(doc, a.Path)
+	if err != nil {
+		return fmt.Errorf("%w: assertion (%s): %v", errs.ErrorInvalidField, a, err)
+	}
+	var ok bool
+	switch a.Operator {
+	case OperatorEq:
+		ok = reflect.DeepEqual(actual, a.Value)
+	case OperatorGte, OperatorLte:
+		ok, err = compare(actual, a.Value, a.Operator)
+		if err != nil {
+			return fmt.Errorf("%w: assertion (%s): %v", errs.ErrorInvalidField, a, err)
+		}
+	case OperatorContains:
+		ok = contains(actual, a.Value)
+	}
+	if !ok {
+		value, _ := json.Marshal(actual)
+		return fmt.Errorf("%w: assertion (%s) failed: actual value is %s", errs.ErrorInvalidField, a, value)
+	}
+	return nil
+}
+
+func resolve(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	for _, elt := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		default:
+			return nil, fmt.Errorf("path (%q) cannot be resolved at (%q)", path, elt)
+		case map[string]interface{}:
+			next, exists := v[elt]
+			if !exists {
+				return nil, fmt.Errorf("path (%q) has no field (%q)", path, elt)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(elt)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("path (%q) has no index (%q)", path, elt)
+			}
+			current = v[index]
+		}
+	}
+	return current, nil
+}
+
+func compare(actual, expected interface{}, operator string) (bool, error) {
+	a, ok := actual.(float64)
+	if !ok {
+		return false, fmt.Errorf("actual value (%v) is not a number", actual)
+	}
+	e, ok := expected.(float64)
+	if !ok {
+		return false, fmt.Errorf("value (%v) is not a number", expected)
+	}
+	if operator == OperatorGte {
+		return a >= e, nil
+	}
+	return a <= e, nil
+}
+
+func contains(actual, expected interface{}) bool {
+	switch v := actual.(type) {
+	case []interface{}:
+		for i := range v {
+			if reflect.DeepEqual(v[i], expected) {
+				return true
+			}
+		}
+	case string:
+		if e, ok := expected.(string); ok {
+			return strings.Contains(v, e)
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package assertions
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+	type build struct {
+		Level   int      `json:"level"`
+		Builder string   `json:"builder"`
+		Envs    []string `json:"envs"`
+	}
+	type policy struct {
+		Build build `json:"build"`
+	}
+	pol := policy{
+		Build: build{
+			Level:   3,
+			Builder: "github_actions",
+			Envs:    []string{"dev", "prod"},
+		},
+	}
+	tests := []struct {
+		name       string
+		assertions []Assertion
+		expected   error
+	}{
+		{
+			name: "no assertions",
+		},
+		{
+			name: "passing assertions",
+			assertions: []Assertion{
+				{Path: "build.level", Operator: OperatorEq, Value: float64(3)},
+				{Path: "build.level", Operator: OperatorGte, Value: float64(3)},
+				{Path: "build.level", Operator: OperatorLte, Value: float64(4)},
+				{Path: "build.builder", Operator: OperatorContains, Value: "github"},
+				{Path: "build.envs", Operator: OperatorContains, Value: "prod"},
+				{Path: "build.envs.1", Operator: OperatorEq, Value: "prod"},
+			},
+		},
+		{
+			name: "failing eq",
+			assertions: []Assertion{
+				{Path: "build.builder", Operator: OperatorEq, Value: "other"},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "failing gte",
+			assertions: []Assertion{
+				{Path: "build.level", Operator: OperatorGte, Value: float64(4)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "failing lte",
+			assertions: []Assertion{
+				{Path: "build.level", Operator: OperatorLte, Value: float64(2)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "failing contains",
+			assertions: []Assertion{
+				{Path: "build.envs", Operator: OperatorContains, Value: "staging"},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "gte on a string",
+			assertions: []Assertion{
+				{Path: "build.builder", Operator: OperatorGte, Value: float64(2)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "unknown operator",
+			assertions: []Assertion{
+				{Path: "build.level", Operator: "gt", Value: float64(2)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "empty path",
+			assertions: []Assertion{
+				{Operator: OperatorEq, Value: float64(2)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "unknown field",
+			assertions: []Assertion{
+				{Path: "build.other", Operator: OperatorEq, Value: float64(2)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "index out of range",
+			assertions: []Assertion{
+				{Path: "build.envs.2", Operator: OperatorEq, Value: "prod"},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "path through a value",
+			assertions: []Assertion{
+				{Path: "build.level.value", Operator: OperatorEq, Value: float64(3)},
+			},
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := Evaluate(tt.assertions, pol)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
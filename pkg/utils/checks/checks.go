@@ -0,0 +1,112 @@
+// Package checks orders and deduplicates the checks
+// registered by the verification options of an attestation.
+package checks
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+// Verification options run in a fixed order, regardless of the order
+// the caller passes them in, so that error reporting is stable:
+// types, subjects and digests, package fields, properties, policy
+// pins and time.
+const (
+	RankTypes = iota
+	RankSubjects
+	RankPackage
+	RankProperties
+	RankPolicy
+	RankTime
+)
+
+// Check is a verification check registered by an option.
+type Check struct {
+	// Kind identifies the option.
+	Kind string
+	// Key is the option's argument, used to detect duplicates,
+	// e.g. a string, an int or a struct of such fields. Keys
+	// that are not comparable are never duplicates.
+	Key any
+	// Value is the option's argument as displayed in errors.
+	// It orders the checks of the same kind.
+	Value string
+	// Exclusive is true if the option may only be
+	// given a single value.
+	Exclusive bool
+	Rank      int
+	Run       func() error
+}
+
+// List is the list of checks of a verification.
+type List struct {
+	list []Check
+}
+
+// Add registers a check. Duplicates with identical keys
+// collapse, and exclusive duplicates with conflicting keys fail.
+func (c *List) Add(n Check) error {
+	for i := range c.list {
+		o := &c.list[i]
+		if o.Kind != n.Kind {
+			continue
+		}
+		if sameKey(o.Key, n.Key) {
+			return nil
+		}
+		if n.Exclusive {
+			values := []string{o.Value, n.Value}
+			sort.Strings(values)
+			return fmt.Errorf("%w: conflicting options %s(%s) and %s(%s)", errs.ErrorInvalidInput,
+				n.Kind, values[0], n.Kind, values[1])
+		}
+	}
+	c.list = append(c.list, n)
+	return nil
+}
+
+// sameKey returns true if the keys are equal. Unlike ==,
+// it does not panic if a key is not comparable.
+func sameKey(a, b any) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Type() == vb.Type() && va.Comparable() && vb.Comparable() && a == b
+}
+
+// Run runs the checks ordered by rank, kind and value.
+func (c *List) Run() error {
+	sort.SliceStable(c.list, func(i, j int) bool {
+		a, b := &c.list[i], &c.list[j]
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Value < b.Value
+	})
+	for i := range c.list {
+		if err := c.list[i].Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DigestsKey returns a key of the digests, sorted by algorithm,
+// so that equal digest sets have equal keys.
+func DigestsKey(digests intoto.DigestSet) string {
+	values := make([]string, 0, len(digests))
+	for alg, value := range digests {
+		values = append(values, alg+":"+value)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
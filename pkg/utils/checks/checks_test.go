@@ -0,0 +1,143 @@
+package checks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/intoto"
+)
+
+func Test_Add(t *testing.T) {
+	t.Parallel()
+	helper1, helper2 := &struct{ name string }{"helper"}, &struct{ name string }{"helper"}
+	tests := []struct {
+		name     string
+		checks   []Check
+		count    int
+		expected error
+	}{
+		{
+			name: "identical keys collapse",
+			checks: []Check{
+				{Kind: "kind", Key: "value", Value: `"value"`},
+				{Kind: "kind", Key: "value", Value: `"value"`},
+			},
+			count: 1,
+		},
+		{
+			name: "keys of different types",
+			checks: []Check{
+				{Kind: "kind", Key: "1", Value: "1"},
+				{Kind: "kind", Key: 1, Value: "1"},
+			},
+			count: 2,
+		},
+		{
+			name: "identical struct keys collapse",
+			checks: []Check{
+				{Kind: "kind", Key: struct{ key, pattern string }{"key", "pattern"}, Value: `"key":"pattern"`},
+				{Kind: "kind", Key: struct{ key, pattern string }{"key", "pattern"}, Value: `"key":"pattern"`},
+			},
+			count: 1,
+		},
+		{
+			name: "keys compared by identity",
+			checks: []Check{
+				{Kind: "kind", Key: helper1, Value: "helper"},
+				{Kind: "kind", Key: helper2, Value: "helper"},
+			},
+			count: 2,
+		},
+		{
+			name: "keys not comparable",
+			checks: []Check{
+				{Kind: "kind", Key: []string{"value"}, Value: "value"},
+				{Kind: "kind", Key: []string{"value"}, Value: "value"},
+			},
+			count: 2,
+		},
+		{
+			name: "different kinds",
+			checks: []Check{
+				{Kind: "kind1", Key: "value", Exclusive: true},
+				{Kind: "kind2", Key: "value", Exclusive: true},
+			},
+			count: 2,
+		},
+		{
+			name: "conflicting exclusive keys",
+			checks: []Check{
+				{Kind: "kind", Key: "value1", Value: `"value1"`, Exclusive: true},
+				{Kind: "kind", Key: "value2", Value: `"value2"`, Exclusive: true},
+			},
+			count:    1,
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var list List
+			var err error
+			for _, check := range tt.checks {
+				if err = list.Add(check); err != nil {
+					break
+				}
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.count, len(list.list)); diff != "" {
+				t.Fatalf("unexpected count (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Run(t *testing.T) {
+	t.Parallel()
+	var order []string
+	newCheck := func(kind, value string, rank int, err error) Check {
+		return Check{Kind: kind, Key: value, Value: value, Rank: rank, Run: func() error {
+			order = append(order, kind+"("+value+")")
+			return err
+		}}
+	}
+	failure := errors.New("failure")
+	var list List
+	for _, check := range []Check{
+		newCheck("kind2", "b", RankTime, nil),
+		newCheck("kind2", "a", RankPackage, failure),
+		newCheck("kind1", "b", RankPackage, nil),
+		newCheck("kind1", "a", RankPackage, nil),
+		newCheck("kind3", "a", RankTypes, nil),
+	} {
+		if err := list.Add(check); err != nil {
+			t.Fatalf("failed to add check: %v", err)
+		}
+	}
+	err := list.Run()
+	if diff := cmp.Diff(failure, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// NOTE: the check of rank RankTime does not run after the failure.
+	if diff := cmp.Diff([]string{"kind3(a)", "kind1(a)", "kind1(b)", "kind2(a)"}, order); diff != "" {
+		t.Fatalf("unexpected order (-want +got): \n%s", diff)
+	}
+}
+
+func Test_DigestsKey(t *testing.T) {
+	t.Parallel()
+	key1 := DigestsKey(intoto.DigestSet{"sha256": "value1", "sha512": "value2"})
+	key2 := DigestsKey(intoto.DigestSet{"sha512": "value2", "sha256": "value1"})
+	if diff := cmp.Diff("sha256:value1,sha512:value2", key1); diff != "" {
+		t.Fatalf("unexpected key (-want +got): \n%s", diff)
+	}
+	if diff := cmp.Diff(key1, key2); diff != "" {
+		t.Fatalf("unexpected key (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,262 @@
+// Package extends resolves the extends field of policy files, with
+// which a policy file inherits the fields of another policy file,
+// e.g. so that project policies that only differ in their package
+// name share a base file.
+//
+// The value of the field is the path of the base file, relative to the
+// directory of the policy file, e.g. "base.json" or "../common/base.yml".
+// A base file must be declared as such with the base field set to true:
+// it is not a policy by itself, and only base files may be extended, so
+// that a policy file is always evaluated as itself, whichever files
+// reference it. The base is merged into the policy file:
+//   - objects are merged recursively,
+//   - lists, scalars and null values of the policy file replace
+//     those of the base.
+//
+// A base may itself extend another file, up to a maximum depth.
+package extends
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/yaml"
+)
+
+const (
+	// Field is the name of the extends field.
+	Field = "extends"
+	// BaseField is the name of the field that declares a base file.
+	BaseField = "base"
+	// MaxDepth is the maximum number of files a
+	// policy file may extend, directly or not.
+	MaxDepth = 4
+)
+
+// Document is a policy file.
+type Document struct {
+	// ID is the path of the file, see iterator.NamedReadCloserIterator.
+	ID      string
+	Content []byte
+	// Err, if set, is the error reading the file.
+	Err error
+}
+
+// Resolved is a policy file with its bases merged in.
+type Resolved struct {
+	ID string
+	// Extends contains the IDs of the bases, nearest first.
+	Extends []string
+	// Content is the document, as is if it does not extend
+	// any file, or as JSON without the extends field otherwise.
+	Content []byte
+	// Err, if set, is the error reading or resolving the file.
+	Err error
+}
+
+// Label returns the ID of the file followed by its bases,
+// used to annotate errors, e.g. `app.json (extends "base.json")`.
+func (r *Resolved) Label() string {
+	if len(r.Extends) == 0 {
+		return r.ID
+	}
+	return fmt.Sprintf("%s (extends %s)", r.ID, quoteAll(r.Extends))
+}
+
+// parsed is a decoded policy file.
+type parsed struct {
+	doc    *Document
+	fields map[string]interface{}
+	// hasExtends is set if the file has the extends field,
+	// and extends is its value if valid.
+	hasExtends bool
+	extends    string
+	// hasBase is set if the file has the base field,
+	// and isBase is its value if valid.
+	hasBase bool
+	isBase  bool
+	err     error
+}
+
+// Resolve merges the bases of docs into the files that extend them.
+// It returns the files that are not bases, in order, and the bases
+// that cannot be resolved, so that their errors are reported. Files
+// that are not valid documents and have neither the extends nor the
+// base field are returned as is, so that the caller reports their errors.
+func Resolve(docs []Document) []Resolved {
+	byID := make(map[string]*parsed, len(docs))
+	all := make([]*parsed, len(docs))
+	for i := range docs {
+		p := decode(&docs[i])
+		all[i] = p
+		byID[docs[i].ID] = p
+	}
+	var results []Resolved
+	for _, p := range all {
+		result := Resolved{ID: p.doc.ID, Content: p.doc.Content}
+		switch {
+		case p.doc.Err != nil:
+			result.Err = p.doc.Err
+		case !p.hasExtends && !p.hasBase:
+			// NOTE: the caller reports an invalid document.
+		default:
+			fields, chain, err := resolve(p, byID, []string{p.doc.ID})
+			result.Extends = chain
+			if err != nil {
+				result.Err = err
+				result.Content = nil
+				break
+			}
+			if p.isBase {
+				continue
+			}
+			content, err := json.Marshal(fields)
+			if err != nil {
+				result.Err = fmt.Errorf("%w: failed to marshal: %w", errs.ErrorInternal, err)
+				break
+			}
+			result.Content = content
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// resolve returns the fields of p with its bases merged in, and the IDs
+// of the bases. visited contains the IDs of the files that extend p.
+func resolve(p *parsed, byID map[string]*parsed, visited []string) (map[string]interface{}, []string, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	if !p.hasExtends {
+		return p.fields, nil, nil
+	}
+	id := reference(p.doc.ID, p.extends)
+	chain := []string{id}
+	if slices.Contains(visited, id) {
+		return nil, chain, fmt.Errorf("%w: extends cycle (%s)", errs.ErrorInvalidField,
+			strings.Join(append(visited, id), " -> "))
+	}
+	if len(visited) > MaxDepth {
+		return nil, chain, fmt.Errorf("%w: extends more than (%d) files (%s)", errs.ErrorInvalidField,
+			MaxDepth, strings.Join(append(visited, id), " -> "))
+	}
+	base, exists := byID[id]
+	if !exists {
+		return nil, chain, fmt.Errorf("%w: extends (%q): policy file not found", errs.ErrorInvalidField, id)
+	}
+	if base.doc.Err != nil {
+		return nil, chain, fmt.Errorf("extends (%q): %w", id, base.doc.Err)
+	}
+	if base.err != nil {
+		return nil, chain, fmt.Errorf("extends (%q): %w", id, base.err)
+	}
+	if !base.isBase {
+		return nil, chain, fmt.Errorf("%w: extends (%q): not a base file, see the (%q) field",
+			errs.ErrorInvalidField, id, BaseField)
+	}
+	fields, baseChain, err := resolve(base, byID, append(slices.Clone(visited), id))
+	chain = append(chain, baseChain...)
+	if err != nil {
+		return nil, chain, err
+	}
+	return merge(fields, p.fields).(map[string]interface{}), chain, nil
+}
+
+// reference returns the ID of the file referenced by
+// ref in the file id, relative to its directory.
+func reference(id, ref string) string {
+	return path.Join(path.Dir(id), ref)
+}
+
+// decode decodes the document and its extends and base fields.
+func decode(doc *Document) *parsed {
+	p := parsed{doc: doc}
+	if doc.Err != nil {
+		return &p
+	}
+	content := doc.Content
+	if !yaml.IsJSON(content) {
+		out, err := yaml.ToJSON(content)
+		if err != nil {
+			p.err = err
+			return &p
+		}
+		content = out
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&p.fields); err != nil {
+		p.err = fmt.Errorf("%w: %w", errs.ErrorInvalidField, err)
+		return &p
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		p.err = fmt.Errorf("%w: unexpected data after the document", errs.ErrorInvalidField)
+		return &p
+	}
+	if value, exists := p.fields[BaseField]; exists {
+		p.hasBase = true
+		isBase, ok := value.(bool)
+		if !ok {
+			p.err = fmt.Errorf("%w: base (%T:%v) is not a boolean", errs.ErrorInvalidField, value, value)
+			return &p
+		}
+		p.isBase = isBase
+	}
+	if value, exists := p.fields[Field]; exists {
+		p.hasExtends = true
+		ref, ok := value.(string)
+		if !ok || ref == "" {
+			p.err = fmt.Errorf("%w: extends (%T:%v) is not a non-empty string", errs.ErrorInvalidField, value, value)
+			return &p
+		}
+		p.extends = ref
+	}
+	fields := make(map[string]interface{}, len(p.fields))
+	for k, v := range p.fields {
+		if k != Field && k != BaseField {
+			fields[k] = v
+		}
+	}
+	p.fields = fields
+	return &p
+}
+
+// merge merges override into base. Objects are merged
+// recursively, other values of override replace those of base.
+func merge(base, override interface{}) interface{} {
+	baseFields, ok := base.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	overrideFields, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	merged := make(map[string]interface{}, len(baseFields)+len(overrideFields))
+	for k, v := range baseFields {
+		merged[k] = v
+	}
+	for k, v := range overrideFields {
+		if baseValue, exists := merged[k]; exists {
+			merged[k] = merge(baseValue, v)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func quoteAll(ids []string) string {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = fmt.Sprintf("%q", id)
+	}
+	return strings.Join(quoted, " -> ")
+}
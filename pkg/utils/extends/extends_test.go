@@ -0,0 +1,293 @@
+package extends
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_Resolve(t *testing.T) {
+	t.Parallel()
+	readErr := errors.New("read error")
+	type result struct {
+		id      string
+		extends []string
+		json    string
+		content string
+		err     error
+	}
+	tests := []struct {
+		name     string
+		docs     []Document
+		expected []result
+	}{
+		{
+			name: "no extends",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"format": 1}`)},
+				{ID: "b.yml", Content: []byte("format: 1\n")},
+			},
+			expected: []result{
+				{id: "a.json", content: `{"format": 1}`},
+				{id: "b.yml", content: "format: 1\n"},
+			},
+		},
+		{
+			name: "deep merge",
+			docs: []Document{
+				{ID: "projects/base.json", Content: []byte(`{"base": true, "format": 1,
+					"package": {"environment": {"any_of": ["dev", "prod"]}},
+					"build": {"require_slsa_builder": "builder", "repository": {"uri": "repo"}, "max_age_days": 30}}`)},
+				{ID: "projects/app.yml", Content: []byte(`extends: base.json
+package:
+  name: app
+  environment:
+    any_of: [prod]
+build:
+  max_age_days: null
+`)},
+			},
+			expected: []result{
+				{
+					id: "projects/app.yml", extends: []string{"projects/base.json"},
+					json: `{"format": 1,
+						"package": {"name": "app", "environment": {"any_of": ["prod"]}},
+						"build": {"require_slsa_builder": "builder", "repository": {"uri": "repo"}, "max_age_days": null}}`,
+				},
+			},
+		},
+		{
+			name: "chain",
+			docs: []Document{
+				{ID: "app.json", Content: []byte(`{"extends": "common/team.json", "name": "app"}`)},
+				{ID: "common/team.json", Content: []byte(`{"base": true, "extends": "../org.json", "team": "team"}`)},
+				{ID: "org.json", Content: []byte(`{"base": true, "format": 1, "team": "org", "name": "org"}`)},
+			},
+			expected: []result{
+				{
+					id: "app.json", extends: []string{"common/team.json", "org.json"},
+					json: `{"format": 1, "team": "team", "name": "app"}`,
+				},
+			},
+		},
+		{
+			name: "scalar replaces object",
+			docs: []Document{
+				{ID: "base.json", Content: []byte(`{"base": true, "build": {"repository": {"uri": "repo"}}}`)},
+				{ID: "app.json", Content: []byte(`{"extends": "base.json", "build": "none"}`)},
+			},
+			expected: []result{
+				{id: "app.json", extends: []string{"base.json"}, json: `{"build": "none"}`},
+			},
+		},
+		{
+			name: "base not found",
+			docs: []Document{
+				{ID: "app.json", Content: []byte(`{"extends": "base.json"}`)},
+			},
+			expected: []result{
+				{id: "app.json", extends: []string{"base.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "cycle",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"base": true, "extends": "b.json"}`)},
+				{ID: "b.json", Content: []byte(`{"base": true, "extends": "a.json"}`)},
+			},
+			expected: []result{
+				{id: "a.json", extends: []string{"b.json", "a.json"}, err: errs.ErrorInvalidField},
+				{id: "b.json", extends: []string{"a.json", "b.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "self",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"base": true, "extends": "a.json"}`)},
+			},
+			expected: []result{
+				{id: "a.json", extends: []string{"a.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "max depth",
+			docs: []Document{
+				{ID: "0.json", Content: []byte(`{"extends": "1.json"}`)},
+				{ID: "1.json", Content: []byte(`{"base": true, "extends": "2.json"}`)},
+				{ID: "2.json", Content: []byte(`{"base": true, "extends": "3.json"}`)},
+				{ID: "3.json", Content: []byte(`{"base": true, "extends": "4.json"}`)},
+				{ID: "4.json", Content: []byte(`{"base": true, "format": 1}`)},
+			},
+			expected: []result{
+				{id: "0.json", extends: []string{"1.json", "2.json", "3.json", "4.json"}, json: `{"format": 1}`},
+			},
+		},
+		{
+			name: "max depth exceeded",
+			docs: []Document{
+				{ID: "0.json", Content: []byte(`{"extends": "1.json"}`)},
+				{ID: "1.json", Content: []byte(`{"base": true, "extends": "2.json"}`)},
+				{ID: "2.json", Content: []byte(`{"base": true, "extends": "3.json"}`)},
+				{ID: "3.json", Content: []byte(`{"base": true, "extends": "4.json"}`)},
+				{ID: "4.json", Content: []byte(`{"base": true, "extends": "5.json"}`)},
+				{ID: "5.json", Content: []byte(`{"base": true, "format": 1}`)},
+			},
+			expected: []result{
+				{id: "0.json", extends: []string{"1.json", "2.json", "3.json", "4.json", "5.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "invalid extends",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"extends": 1}`)},
+			},
+			expected: []result{
+				{id: "a.json", err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "invalid base field",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"base": "true"}`)},
+			},
+			expected: []result{
+				{id: "a.json", err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "not a base",
+			docs: []Document{
+				{ID: "team/app.json", Content: []byte(`{"format": 1, "name": "app"}`)},
+				{ID: "other/app.json", Content: []byte(`{"extends": "../team/app.json", "name": "other"}`)},
+			},
+			expected: []result{
+				{id: "team/app.json", content: `{"format": 1, "name": "app"}`},
+				{id: "other/app.json", extends: []string{"team/app.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "explicit policy",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"base": false, "format": 1}`)},
+				{ID: "b.json", Content: []byte(`{"extends": "a.json"}`)},
+			},
+			expected: []result{
+				{id: "a.json", json: `{"format": 1}`},
+				{id: "b.json", extends: []string{"a.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "unused base",
+			docs: []Document{
+				{ID: "base.json", Content: []byte(`{"base": true, "format": 1}`)},
+			},
+		},
+		{
+			name: "invalid base",
+			docs: []Document{
+				{ID: "base.json", Content: []byte(`{"format": 1`)},
+				{ID: "app.json", Content: []byte(`{"extends": "base.json"}`)},
+			},
+			expected: []result{
+				{id: "base.json", content: `{"format": 1`},
+				{id: "app.json", extends: []string{"base.json"}, err: errs.ErrorInvalidField},
+			},
+		},
+		{
+			name: "invalid document",
+			docs: []Document{
+				{ID: "a.json", Content: []byte(`{"format": 1} trailing`)},
+			},
+			expected: []result{
+				{id: "a.json", content: `{"format": 1} trailing`},
+			},
+		},
+		{
+			name: "read error",
+			docs: []Document{
+				{ID: "base.json", Err: readErr},
+				{ID: "app.json", Content: []byte(`{"extends": "base.json"}`)},
+			},
+			expected: []result{
+				{id: "base.json", err: readErr},
+				{id: "app.json", extends: []string{"base.json"}, err: readErr},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			results := Resolve(tt.docs)
+			if diff := cmp.Diff(len(tt.expected), len(results)); diff != "" {
+				t.Fatalf("unexpected results (-want +got): \n%s", diff)
+			}
+			for i, expected := range tt.expected {
+				r := results[i]
+				if diff := cmp.Diff(expected.id, r.ID); diff != "" {
+					t.Fatalf("unexpected id (-want +got): \n%s", diff)
+				}
+				if diff := cmp.Diff(expected.extends, r.Extends); diff != "" {
+					t.Fatalf("unexpected extends (-want +got): \n%s", diff)
+				}
+				if diff := cmp.Diff(expected.err, r.Err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				if r.Err != nil {
+					continue
+				}
+				if expected.json == "" {
+					if diff := cmp.Diff(expected.content, string(r.Content)); diff != "" {
+						t.Fatalf("unexpected content (-want +got): \n%s", diff)
+					}
+					continue
+				}
+				var want, got interface{}
+				if err := json.Unmarshal([]byte(expected.json), &want); err != nil {
+					t.Fatalf("failed to unmarshal: %v", err)
+				}
+				if err := json.Unmarshal(r.Content, &got); err != nil {
+					t.Fatalf("failed to unmarshal: %v", err)
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Fatalf("unexpected content (-want +got): \n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_Label(t *testing.T) {
+	t.Parallel()
+	r := Resolved{ID: "app.json"}
+	if diff := cmp.Diff("app.json", r.Label()); diff != "" {
+		t.Fatalf("unexpected label (-want +got): \n%s", diff)
+	}
+	r.Extends = []string{"team.json", "org.json"}
+	if diff := cmp.Diff(`app.json (extends "team.json" -> "org.json")`, r.Label()); diff != "" {
+		t.Fatalf("unexpected label (-want +got): \n%s", diff)
+	}
+}
+
+func Test_ErrorNamesFiles(t *testing.T) {
+	t.Parallel()
+	results := Resolve([]Document{
+		{ID: "base.json", Content: []byte(`{"base": true, "extends": "missing.json"}`)},
+		{ID: "app.json", Content: []byte(`{"extends": "base.json"}`)},
+	})
+	for _, r := range results {
+		if r.ID != "app.json" {
+			continue
+		}
+		if !strings.Contains(r.Label(), "base.json") || !strings.Contains(r.Err.Error(), "missing.json") {
+			t.Fatalf("unexpected error: %s: %v", r.Label(), r.Err)
+		}
+		return
+	}
+	t.Fatalf("app.json not returned")
+}
@@ -0,0 +1,81 @@
+package intoto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// PayloadType is the DSSE payload type of in-toto statements.
+const PayloadType = "application/vnd.in-toto+json"
+
+// BundleEntry is a statement of a bundle.
+type BundleEntry struct {
+	// Line is the line of the entry in the bundle, starting at 1.
+	Line      int
+	Statement []byte
+	// Envelope is the raw entry, i.e., the statement
+	// or the DSSE envelope containing it.
+	Envelope []byte
+}
+
+type bundleEntry struct {
+	Type        string `json:"_type"`
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+}
+
+// BundleStatements returns the in-toto statements of a bundle, i.e.,
+// a stream of newline-delimited JSON entries. Each entry is either a
+// statement or a DSSE envelope containing a statement.
+// NOTE: The signatures of the envelopes are not verified.
+// Malformed entries are skipped and their errors returned.
+func BundleStatements(reader io.Reader) ([]BundleEntry, []error, error) {
+	var entries []BundleEntry
+	var skipped []error
+	r := bufio.NewReader(reader)
+	for line := 1; ; line++ {
+		content, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, fmt.Errorf("%w: failed to read: %w", errs.ErrorInvalidInput, err)
+		}
+		if content = bytes.TrimSpace(content); len(content) > 0 {
+			statement, perr := bundleStatement(content)
+			if perr != nil {
+				skipped = append(skipped, fmt.Errorf("line %d: %w", line, perr))
+			} else {
+				entries = append(entries, BundleEntry{Line: line, Statement: statement, Envelope: content})
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return entries, skipped, nil
+}
+
+func bundleStatement(content []byte) ([]byte, error) {
+	var entry bundleEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal: %v", errs.ErrorInvalidField, err)
+	}
+	switch {
+	case entry.Type != "":
+		return content, nil
+	case entry.PayloadType == "":
+		return nil, fmt.Errorf("%w: entry is neither a statement nor an envelope", errs.ErrorInvalidField)
+	case entry.PayloadType != PayloadType:
+		return nil, fmt.Errorf("%w: envelope payload type (%q) != (%q)", errs.ErrorInvalidField,
+			entry.PayloadType, PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(entry.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid envelope payload: %v", errs.ErrorInvalidField, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,191 @@
+package intoto
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Envelope is a DSSE envelope, see
+// https://github.com/secure-systems-lab/dsse/blob/master/envelope.md.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of a DSSE envelope.
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// SignatureVerifier verifies the signatures of DSSE envelopes.
+type SignatureVerifier interface {
+	// Verify verifies the signature over message, i.e., the
+	// pre-authentication encoding of the envelope. keyID is
+	// the key ID of the signature, and may be empty.
+	Verify(keyID string, message, signature []byte) error
+}
+
+// AttestationSigner signs in-toto statements.
+type AttestationSigner interface {
+	// Sign returns a DSSE envelope containing the
+	// statement and its signatures.
+	Sign(ctx context.Context, statement []byte) ([]byte, error)
+}
+
+// SignStatement signs the statement with signer and verifies
+// that the returned envelope contains the statement.
+func SignStatement(ctx context.Context, signer AttestationSigner, statement []byte) ([]byte, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("%w: signer is nil", errs.ErrorInvalidInput)
+	}
+	envelope, err := signer.Sign(ctx, statement)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to sign: %w", errs.ErrorInternal, err)
+	}
+	dsse, err := ParseEnvelope(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid envelope: %w", errs.ErrorInternal, err)
+	}
+	if dsse == nil {
+		return nil, fmt.Errorf("%w: signer did not return an envelope", errs.ErrorInternal)
+	}
+	payload, err := dsse.Statement()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid envelope: %w", errs.ErrorInternal, err)
+	}
+	if !bytes.Equal(payload, statement) {
+		return nil, fmt.Errorf("%w: envelope payload differs from the statement", errs.ErrorInternal)
+	}
+	return envelope, nil
+}
+
+// ParseEnvelope returns the DSSE envelope in content.
+// It returns nil if content does not have the shape of an envelope,
+// e.g., if it is a bare statement.
+func ParseEnvelope(content []byte) (*Envelope, error) {
+	var entry struct {
+		bundleEntry
+		Signatures []Signature `json:"signatures"`
+	}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal: %w", errs.ErrorInvalidInput, err)
+	}
+	if entry.Type != "" || entry.PayloadType == "" {
+		return nil, nil
+	}
+	if entry.PayloadType != PayloadType {
+		return nil, fmt.Errorf("%w: envelope payload type (%q) != (%q)", errs.ErrorInvalidField,
+			entry.PayloadType, PayloadType)
+	}
+	return &Envelope{
+		PayloadType: entry.PayloadType,
+		Payload:     entry.Payload,
+		Signatures:  entry.Signatures,
+	}, nil
+}
+
+// Statement returns the decoded payload of the envelope.
+// NOTE: The signatures are not verified.
+func (e *Envelope) Statement() ([]byte, error) {
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid envelope payload: %v", errs.ErrorInvalidField, err)
+	}
+	return payload, nil
+}
+
+// Verify verifies that at least one signature of the envelope
+// is verified by verifier.
+func (e *Envelope) Verify(verifier SignatureVerifier) error {
+	if verifier == nil {
+		return fmt.Errorf("%w: signature verifier is nil", errs.ErrorInvalidInput)
+	}
+	if len(e.Signatures) == 0 {
+		return fmt.Errorf("%w: envelope has no signatures", errs.ErrorVerification)
+	}
+	payload, err := e.Statement()
+	if err != nil {
+		return err
+	}
+	message := PAE(e.PayloadType, payload)
+	var errList []error
+	for i, s := range e.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("signature %d: invalid encoding: %v", i, err))
+			continue
+		}
+		if err := verifier.Verify(s.KeyID, message, sig); err != nil {
+			errList = append(errList, fmt.Errorf("signature %d: %w", i, err))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no valid signature: %w", errs.ErrorVerification, errors.Join(errList...))
+}
+
+// PAE returns the DSSE pre-authentication encoding of a payload,
+// i.e., the message that is signed.
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// PublicKeysVerifier is a SignatureVerifier that accepts signatures
+// by any of its ECDSA, Ed25519 or RSA public keys. ECDSA and RSA
+// signatures are over the SHA-256 digest of the message.
+type PublicKeysVerifier struct {
+	keys []crypto.PublicKey
+}
+
+// PublicKeysVerifierNew creates a verifier for the public keys.
+func PublicKeysVerifierNew(keys ...crypto.PublicKey) (*PublicKeysVerifier, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no public keys", errs.ErrorInvalidInput)
+	}
+	for i, key := range keys {
+		switch key.(type) {
+		case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("%w: key %d: unsupported key type %T", errs.ErrorInvalidInput, i, key)
+		}
+	}
+	return &PublicKeysVerifier{
+		keys: keys,
+	}, nil
+}
+
+// Verify implements SignatureVerifier. The key ID is ignored.
+func (v *PublicKeysVerifier) Verify(keyID string, message, signature []byte) error {
+	digest := sha256.Sum256(message)
+	for _, key := range v.keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, digest[:], signature) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, message, signature) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil ||
+				rsa.VerifyPSS(k, crypto.SHA256, digest[:], signature, nil) == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: signature not verified by any key", errs.ErrorVerification)
+}
@@ -2,11 +2,17 @@ package intoto
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/slsa-framework/slsa-policy/pkg/errs"
 )
 
+// StatementType is the type of in-toto v1 statements.
+const StatementType = "https://in-toto.io/Statement/v1"
+
 type DigestSet map[string]string
 
 type Subject struct {
@@ -33,6 +39,10 @@ type PackageDescriptor struct {
 	Distro string `json:"distro,omitempty"`
 	// Package environment (debug, prod, etc).
 	Environment string `json:"environment,omitempty"`
+	// The package target operating system, e.g. linux.
+	OS string `json:"os,omitempty"`
+	// The package media type, e.g. application/vnd.oci.image.manifest.v1+json.
+	MediaType string `json:"mediaType,omitempty"`
 	// NOTE: Can add any additional fields.
 	// We may define this structure as simmply a map[string]string.
 }
@@ -48,7 +58,43 @@ type ResourceDescriptor struct {
 }
 
 func (s Subject) Validate() error {
-	return s.Digests.Validate()
+	return ValidateSubject(s)
+}
+
+// ValidateSubject returns ErrorInvalidField if the
+// digests of the subject are invalid.
+func ValidateSubject(s Subject) error {
+	return ValidateDigestSet(s.Digests)
+}
+
+// NewStatement returns the header of a statement. It returns ErrorInvalidInput
+// if a type is empty and ErrorInvalidField if the subjects are empty or invalid.
+// The subjects are copied.
+func NewStatement(statementType, predicateType string, subjects []Subject) (Header, error) {
+	if statementType == "" {
+		return Header{}, fmt.Errorf("%w: statement type is empty", errs.ErrorInvalidInput)
+	}
+	if predicateType == "" {
+		return Header{}, fmt.Errorf("%w: predicate type is empty", errs.ErrorInvalidInput)
+	}
+	if len(subjects) == 0 {
+		return Header{}, fmt.Errorf("%w: no subjects", errs.ErrorInvalidField)
+	}
+	header := Header{
+		Type:          statementType,
+		PredicateType: predicateType,
+		Subjects:      make([]Subject, 0, len(subjects)),
+	}
+	for _, subject := range subjects {
+		if err := ValidateSubject(subject); err != nil {
+			return Header{}, err
+		}
+		header.Subjects = append(header.Subjects, Subject{
+			Name:    subject.Name,
+			Digests: maps.Clone(subject.Digests),
+		})
+	}
+	return header, nil
 }
 
 func (r PackageDescriptor) Validate() error {
@@ -61,7 +107,16 @@ func (r PackageDescriptor) Validate() error {
 	return nil
 }
 
+// Validate returns ErrorInvalidField if the digest set is nil or empty,
+// or if it contains an empty key or value. Public APIs that accept a
+// DigestSet call it before doing any other work.
 func (ds DigestSet) Validate() error {
+	return ValidateDigestSet(ds)
+}
+
+// ValidateDigestSet returns ErrorInvalidField if the digest set is nil
+// or empty, or if it contains an empty key or value.
+func ValidateDigestSet(ds DigestSet) error {
 	if len(ds) == 0 {
 		return fmt.Errorf("%w: digests empty", errs.ErrorInvalidField)
 	}
@@ -76,6 +131,140 @@ func (ds DigestSet) Validate() error {
 	return nil
 }
 
+// knownDigestAlgorithms contains the digest algorithm names
+// defined by the in-toto specification, see
+// https://github.com/in-toto/attestation/blob/main/spec/v1/digest_set.md.
+var knownDigestAlgorithms = []string{
+	"sha256", "sha224", "sha384", "sha512", "sha512_224", "sha512_256",
+	"sha3_224", "sha3_256", "sha3_384", "sha3_512", "shake128", "shake256",
+	"blake2b", "blake2s", "ripemd160", "sm3", "gost", "sha1", "md5",
+	"dirHash", "gitCommit", "gitTree", "gitBlob", "gitTag",
+}
+
+// IsKnownDigestAlgorithm returns true if the digest algorithm
+// is defined by the in-toto specification.
+func IsKnownDigestAlgorithm(name string) bool {
+	return slices.Contains(knownDigestAlgorithms, name)
+}
+
+// CanonicalDigestAlgorithm returns the name of the digest algorithm
+// as defined by the in-toto specification, matched case-insensitively,
+// e.g. "gitCommit" for "GITCOMMIT". Unknown names are lowercased.
+func CanonicalDigestAlgorithm(name string) string {
+	for _, known := range knownDigestAlgorithms {
+		if strings.EqualFold(known, name) {
+			return known
+		}
+	}
+	return strings.ToLower(name)
+}
+
+// digestLengths contains the number of hex characters of
+// the values of the digest algorithms whose format is verified.
+var digestLengths = map[string]int{
+	"sha256":    64,
+	"gitCommit": 40,
+}
+
+// Normalize validates the digest set like Validate and returns it with
+// canonical algorithm names and lowercase hex values, so that digests
+// compare equal regardless of the case produced by the tooling.
+// It returns ErrorInvalidInput if a sha256 value is not 64 hex characters,
+// if a gitCommit value is not 40 hex characters, or if two keys are the
+// same algorithm. The digest set is returned as is if it is already normalized.
+func (ds DigestSet) Normalize() (DigestSet, error) {
+	if err := ds.Validate(); err != nil {
+		return nil, err
+	}
+	normalized := true
+	for name, value := range ds {
+		alg := CanonicalDigestAlgorithm(name)
+		if length, exists := digestLengths[alg]; exists && (len(value) != length || !isHex(value)) {
+			return nil, fmt.Errorf("%w: digest (%q:%q) must be %d hex characters", errs.ErrorInvalidInput,
+				name, value, length)
+		}
+		if alg != name || canonicalDigestValue(value) != value {
+			normalized = false
+		}
+	}
+	if normalized {
+		return ds, nil
+	}
+	res := make(DigestSet, len(ds))
+	for name, value := range ds {
+		alg := CanonicalDigestAlgorithm(name)
+		if _, exists := res[alg]; exists {
+			return nil, fmt.Errorf("%w: digests contain algorithm (%q) more than once", errs.ErrorInvalidInput, alg)
+		}
+		res[alg] = canonicalDigestValue(value)
+	}
+	return res, nil
+}
+
+// DigestValue returns the value of the digest algorithm in the digest set,
+// comparing algorithm names case-insensitively. The value is lowercased
+// if it is hex. The digest set is not validated, e.g. it may be read from
+// an attestation.
+func (ds DigestSet) DigestValue(name string) (string, bool) {
+	alg := CanonicalDigestAlgorithm(name)
+	if value, exists := ds[alg]; exists {
+		return canonicalDigestValue(value), true
+	}
+	for k, value := range ds {
+		if CanonicalDigestAlgorithm(k) == alg {
+			return canonicalDigestValue(value), true
+		}
+	}
+	return "", false
+}
+
+// ConflictingAlgorithm returns the canonical name of an algorithm that
+// the digest set contains more than once with different values, e.g.
+// "sha256" for {"sha256": "ab", "SHA256": "cd"}. DigestValue returns
+// either value for such an algorithm, so callers that compare digests
+// read from attestations must reject the digest set instead.
+func (ds DigestSet) ConflictingAlgorithm() (string, bool) {
+	if len(ds) < 2 {
+		return "", false
+	}
+	seen := make(map[string]string, len(ds))
+	for name, value := range ds {
+		alg := CanonicalDigestAlgorithm(name)
+		value = canonicalDigestValue(value)
+		if val, exists := seen[alg]; exists && val != value {
+			return alg, true
+		}
+		seen[alg] = value
+	}
+	return "", false
+}
+
+// canonicalDigestValue lowercases hex values and returns other values as is.
+func canonicalDigestValue(value string) string {
+	if !isHex(value) {
+		return value
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] >= 'A' && value[i] <= 'F' {
+			return strings.ToLower(value)
+		}
+	}
+	return value
+}
+
+func isHex(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') && !('A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
 func GetAnnotationValue(anno map[string]interface{}, name string) (string, error) {
 	if anno == nil {
 		return "", nil
@@ -93,5 +282,25 @@ func GetAnnotationValue(anno map[string]interface{}, name string) (string, error
 }
 
 func Now() string {
-	return time.Now().UTC().Format(time.RFC3339)
+	return FormatTime(time.Now())
+}
+
+// FormatTime formats t in UTC in the RFC 3339 format with a precision
+// of one second, e.g. 2024-05-01T10:00:00Z, so that the same time
+// is always formatted the same way.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ParseTime parses a time in the RFC 3339 format used by Now().
+// Fractional seconds and any timezone offset are accepted.
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%w: time is empty", errs.ErrorInvalidField)
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid time (%q): %v", errs.ErrorInvalidField, value, err)
+	}
+	return t, nil
 }
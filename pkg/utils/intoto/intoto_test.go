@@ -1,7 +1,18 @@
 package intoto
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -59,6 +70,10 @@ func Test_ValidateSubject(t *testing.T) {
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			err = ValidateSubject(tt.subject)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
@@ -78,8 +93,13 @@ func Test_ValidateDigests(t *testing.T) {
 				"gitCommit": "another_value",
 			},
 		},
+		{
+			name:     "nil digests",
+			expected: errs.ErrorInvalidField,
+		},
 		{
 			name:     "empty digests",
+			digests:  DigestSet{},
 			expected: errs.ErrorInvalidField,
 		},
 		{
@@ -107,6 +127,199 @@ func Test_ValidateDigests(t *testing.T) {
 			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
 				t.Fatalf("unexpected err (-want +got): \n%s", diff)
 			}
+			err = ValidateDigestSet(tt.digests)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Normalize(t *testing.T) {
+	t.Parallel()
+	digest := "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5"
+	commit := "685d6428520757122269412ce6f9c5b7d1911f61"
+	tests := []struct {
+		name       string
+		digests    DigestSet
+		normalized DigestSet
+		expected   error
+	}{
+		{
+			name:       "normalized",
+			digests:    DigestSet{"sha256": digest, "gitCommit": commit, "other": "Some_Value"},
+			normalized: DigestSet{"sha256": digest, "gitCommit": commit, "other": "Some_Value"},
+		},
+		{
+			name:       "mixed case",
+			digests:    DigestSet{"SHA256": strings.ToUpper(digest), "GitCommit": strings.ToUpper(commit), "SHA512": "ABC"},
+			normalized: DigestSet{"sha256": digest, "gitCommit": commit, "sha512": "abc"},
+		},
+		{
+			name:     "empty value",
+			digests:  DigestSet{"SHA256": ""},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "short sha256",
+			digests:  DigestSet{"sha256": digest[1:]},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "non hex sha256",
+			digests:  DigestSet{"sha256": "z" + digest[1:]},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "long gitCommit",
+			digests:  DigestSet{"gitCommit": commit + "0"},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "duplicate algorithm",
+			digests:  DigestSet{"sha256": digest, "SHA256": digest},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			normalized, err := tt.digests.Normalize()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.normalized, normalized); diff != "" {
+				t.Fatalf("unexpected digests (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_ConflictingAlgorithm(t *testing.T) {
+	t.Parallel()
+	digest := "ae448ac86c4e8e4dec645729708ef41873ae79c6dff84eff73360989487f08e5"
+	other := "cd84e8eb5ec577de03d8b159a56c809b9ec7ae1956f828bfab6479de57a1e88d"
+	tests := []struct {
+		name      string
+		digests   DigestSet
+		algorithm string
+		conflicts bool
+	}{
+		{
+			name:    "single algorithm",
+			digests: DigestSet{"sha256": digest},
+		},
+		{
+			name:    "different algorithms",
+			digests: DigestSet{"sha256": digest, "sha512": other},
+		},
+		{
+			name:    "same values",
+			digests: DigestSet{"sha256": digest, "SHA256": strings.ToUpper(digest)},
+		},
+		{
+			name:      "conflicting values",
+			digests:   DigestSet{"sha256": digest, "SHA256": other},
+			algorithm: "sha256",
+			conflicts: true,
+		},
+		{
+			name:      "conflicting unknown algorithm",
+			digests:   DigestSet{"Other": "a", "OTHER": "b", "sha256": digest},
+			algorithm: "other",
+			conflicts: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			algorithm, conflicts := tt.digests.ConflictingAlgorithm()
+			if diff := cmp.Diff(tt.conflicts, conflicts); diff != "" {
+				t.Fatalf("unexpected conflicts (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.algorithm, algorithm); diff != "" {
+				t.Fatalf("unexpected algorithm (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_NewStatement(t *testing.T) {
+	t.Parallel()
+	subjects := []Subject{
+		{
+			Name:    "subject1",
+			Digests: DigestSet{"sha256": "some_value"},
+		},
+		{
+			Digests: DigestSet{"sha512": "another_value"},
+		},
+	}
+	tests := []struct {
+		name          string
+		statementType string
+		predicateType string
+		subjects      []Subject
+		expected      error
+	}{
+		{
+			name:          "valid statement",
+			statementType: StatementType,
+			predicateType: "predicate_type",
+			subjects:      subjects,
+		},
+		{
+			name:          "empty statement type",
+			predicateType: "predicate_type",
+			subjects:      subjects,
+			expected:      errs.ErrorInvalidInput,
+		},
+		{
+			name:          "empty predicate type",
+			statementType: StatementType,
+			subjects:      subjects,
+			expected:      errs.ErrorInvalidInput,
+		},
+		{
+			name:          "no subjects",
+			statementType: StatementType,
+			predicateType: "predicate_type",
+			expected:      errs.ErrorInvalidField,
+		},
+		{
+			name:          "invalid subject",
+			statementType: StatementType,
+			predicateType: "predicate_type",
+			subjects:      append([]Subject{{Name: "subject2"}}, subjects...),
+			expected:      errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			header, err := NewStatement(tt.statementType, tt.predicateType, tt.subjects)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			expected := Header{
+				Type:          tt.statementType,
+				PredicateType: tt.predicateType,
+				Subjects:      tt.subjects,
+			}
+			if diff := cmp.Diff(expected, header); diff != "" {
+				t.Fatalf("unexpected header (-want +got): \n%s", diff)
+			}
+			// Modifying the header must not change the subjects.
+			header.Subjects[0].Digests["sha256"] = "modified"
+			if diff := cmp.Diff("some_value", tt.subjects[0].Digests["sha256"]); diff != "" {
+				t.Fatalf("unexpected digest (-want +got): \n%s", diff)
+			}
 		})
 	}
 }
@@ -207,3 +420,409 @@ func Test_GetAnnotationValue(t *testing.T) {
 		})
 	}
 }
+
+func Test_ParseTime(t *testing.T) {
+	t.Parallel()
+	utc := time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		time     time.Time
+		expected error
+	}{
+		{
+			name:  "utc",
+			value: "2024-03-01T10:20:30Z",
+			time:  utc,
+		},
+		{
+			name:  "positive offset",
+			value: "2024-03-01T12:20:30+02:00",
+			time:  utc,
+		},
+		{
+			name:  "negative offset",
+			value: "2024-03-01T05:20:30-05:00",
+			time:  utc,
+		},
+		{
+			name:  "milliseconds",
+			value: "2024-03-01T10:20:30.123Z",
+			time:  utc.Add(123 * time.Millisecond),
+		},
+		{
+			name:  "nanoseconds with offset",
+			value: "2024-03-01T11:20:30.000000001+01:00",
+			time:  utc.Add(time.Nanosecond),
+		},
+		{
+			name:  "now",
+			value: Now(),
+		},
+		{
+			name:     "empty",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "no timezone",
+			value:    "2024-03-01T10:20:30",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "date only",
+			value:    "2024-03-01",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "not a time",
+			value:    "yesterday",
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			parsed, err := ParseTime(tt.value)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil || tt.time.IsZero() {
+				return
+			}
+			if !parsed.Equal(tt.time) {
+				t.Fatalf("unexpected time: %v != %v", parsed, tt.time)
+			}
+		})
+	}
+}
+
+func Test_BundleStatements(t *testing.T) {
+	t.Parallel()
+	statement := `{"_type":"https://in-toto.io/Statement/v1"}`
+	payload := base64.StdEncoding.EncodeToString([]byte(statement))
+	envelope := `{"payloadType":"` + PayloadType + `","payload":"` + payload + `","signatures":[]}`
+	tests := []struct {
+		name     string
+		bundle   string
+		entries  []BundleEntry
+		skipped  int
+		expected error
+	}{
+		{
+			name:   "statements and envelopes",
+			bundle: statement + "\n\n" + envelope + "\n",
+			entries: []BundleEntry{
+				{Line: 1, Statement: []byte(statement), Envelope: []byte(statement)},
+				{Line: 3, Statement: []byte(statement), Envelope: []byte(envelope)},
+			},
+		},
+		{
+			name:    "no trailing newline",
+			bundle:  "  " + statement,
+			entries: []BundleEntry{{Line: 1, Statement: []byte(statement), Envelope: []byte(statement)}},
+		},
+		{
+			name: "malformed entries",
+			bundle: "not json\n" +
+				`{"name":"neither"}` + "\n" +
+				`{"payloadType":"application/json","payload":"` + payload + `"}` + "\n" +
+				`{"payloadType":"` + PayloadType + `","payload":"not base64!"}` + "\n" +
+				statement,
+			entries: []BundleEntry{{Line: 5, Statement: []byte(statement), Envelope: []byte(statement)}},
+			skipped: 4,
+		},
+		{
+			name: "empty bundle",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			entries, skipped, err := BundleStatements(strings.NewReader(tt.bundle))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.entries, entries); diff != "" {
+				t.Fatalf("unexpected entries (-want +got): \n%s", diff)
+			}
+			if len(skipped) != tt.skipped {
+				t.Fatalf("unexpected skipped entries: %v", skipped)
+			}
+			for _, e := range skipped {
+				if !errors.Is(e, errs.ErrorInvalidField) {
+					t.Fatalf("unexpected skipped error: %v", e)
+				}
+			}
+		})
+	}
+}
+
+func Test_PAE(t *testing.T) {
+	t.Parallel()
+	// See https://github.com/secure-systems-lab/dsse/blob/master/protocol.md.
+	expected := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if diff := cmp.Diff(expected, string(PAE("http://example.com/HelloWorld", []byte("hello world")))); diff != "" {
+		t.Fatalf("unexpected encoding (-want +got): \n%s", diff)
+	}
+}
+
+func Test_ParseEnvelope(t *testing.T) {
+	t.Parallel()
+	statement := `{"_type":"https://in-toto.io/Statement/v1"}`
+	payload := base64.StdEncoding.EncodeToString([]byte(statement))
+	tests := []struct {
+		name      string
+		content   string
+		envelope  *Envelope
+		statement string
+		expected  error
+	}{
+		{
+			name:    "envelope",
+			content: `{"payloadType":"` + PayloadType + `","payload":"` + payload + `","signatures":[{"keyid":"id","sig":"c2ln"}]}`,
+			envelope: &Envelope{
+				PayloadType: PayloadType,
+				Payload:     payload,
+				Signatures:  []Signature{{KeyID: "id", Sig: "c2ln"}},
+			},
+			statement: statement,
+		},
+		{
+			name:    "statement",
+			content: statement,
+		},
+		{
+			name:     "invalid payload type",
+			content:  `{"payloadType":"application/json","payload":"` + payload + `"}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid payload",
+			content:  `{"payloadType":"` + PayloadType + `","payload":"not base64!"}`,
+			envelope: &Envelope{PayloadType: PayloadType, Payload: "not base64!"},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "not json",
+			content:  "not json",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			envelope, err := ParseEnvelope([]byte(tt.content))
+			if err != nil {
+				if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.envelope, envelope); diff != "" {
+				t.Fatalf("unexpected envelope (-want +got): \n%s", diff)
+			}
+			if envelope == nil {
+				return
+			}
+			content, err := envelope.Statement()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.statement, string(content)); diff != "" {
+				t.Fatalf("unexpected statement (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_EnvelopeVerify(t *testing.T) {
+	t.Parallel()
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	payload := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+	message := PAE(PayloadType, payload)
+	digest := sha256.Sum256(message)
+	ecdsaSig, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	edSig := ed25519.Sign(edKey, message)
+	envelope := func(sigs ...[]byte) Envelope {
+		e := Envelope{
+			PayloadType: PayloadType,
+			Payload:     base64.StdEncoding.EncodeToString(payload),
+		}
+		for _, sig := range sigs {
+			e.Signatures = append(e.Signatures, Signature{Sig: base64.StdEncoding.EncodeToString(sig)})
+		}
+		return e
+	}
+	tests := []struct {
+		name     string
+		envelope Envelope
+		keys     []crypto.PublicKey
+		expected error
+	}{
+		{
+			name:     "ecdsa signature",
+			envelope: envelope(ecdsaSig),
+			keys:     []crypto.PublicKey{&ecdsaKey.PublicKey},
+		},
+		{
+			name:     "ed25519 signature",
+			envelope: envelope(edSig),
+			keys:     []crypto.PublicKey{&otherKey.PublicKey, edPublicKey},
+		},
+		{
+			name:     "one valid signature",
+			envelope: envelope(edSig, ecdsaSig),
+			keys:     []crypto.PublicKey{&ecdsaKey.PublicKey},
+		},
+		{
+			name:     "wrong key",
+			envelope: envelope(ecdsaSig),
+			keys:     []crypto.PublicKey{&otherKey.PublicKey},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "no signatures",
+			envelope: envelope(),
+			keys:     []crypto.PublicKey{&ecdsaKey.PublicKey},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "modified payload",
+			envelope: Envelope{
+				PayloadType: PayloadType,
+				Payload:     base64.StdEncoding.EncodeToString([]byte(`{}`)),
+				Signatures:  envelope(ecdsaSig).Signatures,
+			},
+			keys:     []crypto.PublicKey{&ecdsaKey.PublicKey},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name: "invalid signature encoding",
+			envelope: Envelope{
+				PayloadType: PayloadType,
+				Payload:     base64.StdEncoding.EncodeToString(payload),
+				Signatures:  []Signature{{Sig: "not base64!"}},
+			},
+			keys:     []crypto.PublicKey{&ecdsaKey.PublicKey},
+			expected: errs.ErrorVerification,
+		},
+		{
+			name:     "no keys",
+			envelope: envelope(ecdsaSig),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "unsupported key",
+			envelope: envelope(ecdsaSig),
+			keys:     []crypto.PublicKey{"key"},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			verifier, err := PublicKeysVerifierNew(tt.keys...)
+			if err == nil {
+				err = tt.envelope.Verify(verifier)
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+type fakeSigner struct {
+	envelope func(statement []byte) []byte
+	err      error
+}
+
+func (s *fakeSigner) Sign(ctx context.Context, statement []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.envelope(statement), nil
+}
+
+func Test_SignStatement(t *testing.T) {
+	t.Parallel()
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+	envelope := func(payload []byte) []byte {
+		return []byte(`{"payloadType":"` + PayloadType + `","payload":"` +
+			base64.StdEncoding.EncodeToString(payload) + `","signatures":[{"sig":"c2ln"}]}`)
+	}
+	tests := []struct {
+		name     string
+		signer   AttestationSigner
+		expected error
+	}{
+		{
+			name:   "valid envelope",
+			signer: &fakeSigner{envelope: envelope},
+		},
+		{
+			name:     "nil signer",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "signer error",
+			signer:   &fakeSigner{err: errors.New("sign error")},
+			expected: errs.ErrorInternal,
+		},
+		{
+			name: "not an envelope",
+			signer: &fakeSigner{envelope: func(statement []byte) []byte {
+				return statement
+			}},
+			expected: errs.ErrorInternal,
+		},
+		{
+			name: "not json",
+			signer: &fakeSigner{envelope: func([]byte) []byte {
+				return []byte("not json")
+			}},
+			expected: errs.ErrorInternal,
+		},
+		{
+			name: "different payload",
+			signer: &fakeSigner{envelope: func([]byte) []byte {
+				return envelope([]byte(`{}`))
+			}},
+			expected: errs.ErrorInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content, err := SignStatement(context.Background(), tt.signer, statement)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(string(envelope(statement)), string(content)); diff != "" {
+				t.Fatalf("unexpected envelope (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+)
+
+// PolicyIterator iterates over the JSON and YAML policy files of a directory tree,
+// in lexical order of their path. The ID of a policy is its path relative
+// to the root, with '/' as separator. It can be passed to deployment.PolicyNew,
+// and to publish.PolicyNew with iterator.Unnamed.
+type PolicyIterator struct {
+	root     string
+	include  []string
+	exclude  []string
+	maxDepth int
+	paths    []string
+	index    int
+	err      error
+}
+
+var _ iterator.NamedReadCloserIterator = (*PolicyIterator)(nil)
+
+var policyExtensions = []string{".json", ".yaml", ".yml"}
+
+// PolicyIteratorOption defines an option of the policy iterator.
+type PolicyIteratorOption func(*PolicyIterator) error
+
+// NewPolicyIterator walks the directory tree at root and returns
+// an iterator over its JSON and YAML files.
+func NewPolicyIterator(root string, opts ...PolicyIteratorOption) (*PolicyIterator, error) {
+	iter := &PolicyIterator{
+		root:     root,
+		maxDepth: -1,
+		index:    -1,
+	}
+	for _, option := range opts {
+		if err := option(iter); err != nil {
+			return nil, err
+		}
+	}
+	if err := iter.walk(); err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// WithInclude only iterates over the files whose relative
+// path matches one of the glob patterns, see path.Match.
+func WithInclude(patterns ...string) PolicyIteratorOption {
+	return func(iter *PolicyIterator) error {
+		return iter.setInclude(patterns)
+	}
+}
+
+func (iter *PolicyIterator) setInclude(patterns []string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	iter.include = append(iter.include, patterns...)
+	return nil
+}
+
+// WithExclude skips the files whose relative path
+// matches one of the glob patterns, see path.Match.
+func WithExclude(patterns ...string) PolicyIteratorOption {
+	return func(iter *PolicyIterator) error {
+		return iter.setExclude(patterns)
+	}
+}
+
+func (iter *PolicyIterator) setExclude(patterns []string) error {
+	if err := validatePatterns(patterns); err != nil {
+		return err
+	}
+	iter.exclude = append(iter.exclude, patterns...)
+	return nil
+}
+
+// WithMaxDepth limits the number of directory levels below
+// the root that are walked. A depth of 0 only iterates over
+// the files directly under the root.
+func WithMaxDepth(depth int) PolicyIteratorOption {
+	return func(iter *PolicyIterator) error {
+		return iter.setMaxDepth(depth)
+	}
+}
+
+func (iter *PolicyIterator) setMaxDepth(depth int) error {
+	if depth < 0 {
+		return fmt.Errorf("%w: max depth (%d) is negative", errs.ErrorInvalidInput, depth)
+	}
+	iter.maxDepth = depth
+	return nil
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return fmt.Errorf("%w: pattern is empty", errs.ErrorInvalidInput)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: invalid pattern (%q): %v", errs.ErrorInvalidInput, pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// NOTE: validatePatterns() ensures the pattern is valid.
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// walk collects the paths of the policy files.
+// NOTE: filepath.WalkDir walks in lexical order, so the order is deterministic.
+func (iter *PolicyIterator) walk() error {
+	return filepath.WalkDir(iter.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to walk (%q): %w", p, err)
+		}
+		rel, err := filepath.Rel(iter.root, p)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path (%q): %w", p, err)
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && iter.maxDepth >= 0 && strings.Count(rel, "/")+1 > iter.maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !slices.Contains(policyExtensions, filepath.Ext(p)) {
+			return nil
+		}
+		if len(iter.include) > 0 && !matchAny(iter.include, rel) {
+			return nil
+		}
+		if matchAny(iter.exclude, rel) {
+			return nil
+		}
+		iter.paths = append(iter.paths, rel)
+		return nil
+	})
+}
+
+func (iter *PolicyIterator) Next() (string, io.ReadCloser) {
+	if iter.err != nil {
+		return "", nil
+	}
+	iter.index++
+	rel := iter.paths[iter.index]
+	file, err := os.Open(filepath.Join(iter.root, filepath.FromSlash(rel)))
+	if err != nil {
+		iter.err = fmt.Errorf("failed to open (%q): %w", rel, err)
+		return "", nil
+	}
+	return rel, file
+}
+
+func (iter *PolicyIterator) HasNext() bool {
+	if iter.err != nil {
+		return false
+	}
+	return iter.index+1 < len(iter.paths)
+}
+
+func (iter *PolicyIterator) Error() error {
+	return iter.err
+}
@@ -0,0 +1,305 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func createTree(t *testing.T) string {
+	root := t.TempDir()
+	for _, name := range []string{
+		"b.json",
+		"a.json",
+		"notes.txt",
+		"dir1/c.json",
+		"dir1/test_c.json",
+		"dir1/dir2/d.json",
+		"dir3/e.json",
+		"dir3/f.yml",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	return root
+}
+
+func Test_NewPolicyIterator(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		options  []PolicyIteratorOption
+		ids      []string
+		expected error
+	}{
+		{
+			name: "all policy files",
+			ids:  []string{"a.json", "b.json", "dir1/c.json", "dir1/dir2/d.json", "dir1/test_c.json", "dir3/e.json", "dir3/f.yml"},
+		},
+		{
+			name:    "include",
+			options: []PolicyIteratorOption{WithInclude("dir1/*")},
+			ids:     []string{"dir1/c.json", "dir1/test_c.json"},
+		},
+		{
+			name:    "exclude",
+			options: []PolicyIteratorOption{WithExclude("*/test_*", "dir3/*")},
+			ids:     []string{"a.json", "b.json", "dir1/c.json", "dir1/dir2/d.json"},
+		},
+		{
+			name:    "include and exclude",
+			options: []PolicyIteratorOption{WithInclude("dir1/*", "dir3/*"), WithExclude("*/test_*")},
+			ids:     []string{"dir1/c.json", "dir3/e.json", "dir3/f.yml"},
+		},
+		{
+			name:    "max depth 0",
+			options: []PolicyIteratorOption{WithMaxDepth(0)},
+			ids:     []string{"a.json", "b.json"},
+		},
+		{
+			name:    "max depth 1",
+			options: []PolicyIteratorOption{WithMaxDepth(1)},
+			ids:     []string{"a.json", "b.json", "dir1/c.json", "dir1/test_c.json", "dir3/e.json", "dir3/f.yml"},
+		},
+		{
+			name:     "negative max depth",
+			options:  []PolicyIteratorOption{WithMaxDepth(-1)},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid pattern",
+			options:  []PolicyIteratorOption{WithInclude("dir1/[")},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty pattern",
+			options:  []PolicyIteratorOption{WithExclude("")},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			root := createTree(t)
+			iter, err := NewPolicyIterator(root, tt.options...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			var ids []string
+			for iter.HasNext() {
+				id, reader := iter.Next()
+				content, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("failed to read: %v", err)
+				}
+				reader.Close()
+				if diff := cmp.Diff(id, string(content)); diff != "" {
+					t.Fatalf("unexpected content (-want +got): \n%s", diff)
+				}
+				ids = append(ids, id)
+			}
+			if err := iter.Error(); err != nil {
+				t.Fatalf("failed to iterate: %v", err)
+			}
+			if diff := cmp.Diff(tt.ids, ids); diff != "" {
+				t.Fatalf("unexpected ids (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_PolicyIteratorErrors(t *testing.T) {
+	t.Parallel()
+	// Missing root.
+	if _, err := NewPolicyIterator(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatalf("expected an error for a missing root")
+	}
+	// File removed after the walk.
+	root := createTree(t)
+	iter, err := NewPolicyIterator(root)
+	if err != nil {
+		t.Fatalf("failed to create iterator: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, "a.json")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if _, reader := iter.Next(); reader != nil {
+		t.Fatalf("expected a nil reader")
+	}
+	if iter.HasNext() {
+		t.Fatalf("expected the iteration to stop")
+	}
+	if diff := cmp.Diff(os.ErrNotExist, iter.Error(), cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// The error contains the path.
+	if !strings.Contains(iter.Error().Error(), "a.json") {
+		t.Fatalf("error (%q) does not contain the path", iter.Error())
+	}
+}
+
+func Test_NewFSPolicyIterator(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"org.json":               {Data: []byte("org.json")},
+		"b.json":                 {Data: []byte("b.json")},
+		"a.json":                 {Data: []byte("a.json")},
+		"dir1/c.json":            {Data: []byte("dir1/c.json")},
+		"dir1/dir2.json/d.json":  {Data: []byte("dir1/dir2.json/d.json")},
+		"dir3/e.json":            {Data: []byte("dir3/e.json")},
+		"dir3/notes.txt":         {Data: []byte("dir3/notes.txt")},
+		"dir3/dir4/f.json":       {Data: []byte("dir3/dir4/f.json")},
+		"dir3/dir4/dir5/g.json":  {Data: []byte("dir3/dir4/dir5/g.json")},
+		"dir6/dir7/dir8/h.json":  {Data: []byte("dir6/dir7/dir8/h.json")},
+		"dir6/dir7/dir8/i.yml":   {Data: []byte("dir6/dir7/dir8/i.yml")},
+		"dir6/dir7/dir8/j.yaml":  {Data: []byte("dir6/dir7/dir8/j.yaml")},
+		"dir6/dir7/dir8/k.json5": {Data: []byte("dir6/dir7/dir8/k.json5")},
+	}
+	tests := []struct {
+		name     string
+		fsys     fs.FS
+		pattern  string
+		exclude  []string
+		ids      []string
+		expected error
+	}{
+		{
+			name:    "root files",
+			fsys:    fsys,
+			pattern: "*.json",
+			ids:     []string{"a.json", "b.json", "org.json"},
+		},
+		{
+			name:    "exclude",
+			fsys:    fsys,
+			pattern: "*.json",
+			exclude: []string{"org.json"},
+			ids:     []string{"a.json", "b.json"},
+		},
+		{
+			name:    "directories are skipped",
+			fsys:    fsys,
+			pattern: "dir1/*.json",
+			ids:     []string{"dir1/c.json"},
+		},
+		{
+			name:    "nested directories",
+			fsys:    fsys,
+			pattern: "dir*/*/*.json",
+			ids:     []string{"dir1/dir2.json/d.json", "dir3/dir4/f.json"},
+		},
+		{
+			name:    "extensions",
+			fsys:    fsys,
+			pattern: "dir6/dir7/dir8/*.y*ml",
+			ids:     []string{"dir6/dir7/dir8/i.yml", "dir6/dir7/dir8/j.yaml"},
+		},
+		{
+			name:    "no match",
+			fsys:    fsys,
+			pattern: "dir9/*.json",
+		},
+		{
+			name:     "invalid pattern",
+			fsys:     fsys,
+			pattern:  "dir1/[",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "empty pattern",
+			fsys:     fsys,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "nil fs",
+			pattern:  "*.json",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			iter, err := NewFSPolicyIterator(tt.fsys, tt.pattern, tt.exclude...)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			var ids []string
+			for iter.HasNext() {
+				id, reader := iter.Next()
+				content, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("failed to read: %v", err)
+				}
+				reader.Close()
+				if diff := cmp.Diff(id, string(content)); diff != "" {
+					t.Fatalf("unexpected content (-want +got): \n%s", diff)
+				}
+				named, ok := reader.(interface{ Name() string })
+				if !ok {
+					t.Fatalf("reader does not implement Name()")
+				}
+				if diff := cmp.Diff(id, named.Name()); diff != "" {
+					t.Fatalf("unexpected name (-want +got): \n%s", diff)
+				}
+				ids = append(ids, id)
+			}
+			if err := iter.Error(); err != nil {
+				t.Fatalf("failed to iterate: %v", err)
+			}
+			if diff := cmp.Diff(tt.ids, ids); diff != "" {
+				t.Fatalf("unexpected ids (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FSPolicyIteratorErrors(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"a.json": {Data: []byte("a.json")},
+	}
+	iter, err := NewFSPolicyIterator(fsys, "*.json")
+	if err != nil {
+		t.Fatalf("failed to create iterator: %v", err)
+	}
+	// File removed after the glob.
+	delete(fsys, "a.json")
+	if _, reader := iter.Next(); reader != nil {
+		t.Fatalf("expected a nil reader")
+	}
+	if iter.HasNext() {
+		t.Fatalf("expected the iteration to stop")
+	}
+	if diff := cmp.Diff(fs.ErrNotExist, iter.Error(), cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	// The error contains the path.
+	if !strings.Contains(iter.Error().Error(), "a.json") {
+		t.Fatalf("error (%q) does not contain the path", iter.Error())
+	}
+	if _, err := OpenFS(fsys, "missing.json"); !strings.Contains(fmt.Sprint(err), "missing.json") {
+		t.Fatalf("error (%v) does not contain the path", err)
+	}
+}
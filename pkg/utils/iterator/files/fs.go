@@ -0,0 +1,107 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/iterator"
+)
+
+// FSPolicyIterator iterates over the policy files of a file system matching
+// a glob pattern, in lexical order of their path. The ID of a policy is its
+// path in the file system. The readers implement Name(), which returns the
+// same path, so that errors identify the policy for APIs that do not use IDs.
+type FSPolicyIterator struct {
+	fsys  fs.FS
+	paths []string
+	index int
+	err   error
+}
+
+var _ iterator.NamedReadCloserIterator = (*FSPolicyIterator)(nil)
+
+// NewFSPolicyIterator returns an iterator over the regular files of fsys
+// matching pattern, see fs.Glob. The paths in exclude are skipped, e.g.
+// the org policy if pattern matches it.
+func NewFSPolicyIterator(fsys fs.FS, pattern string, exclude ...string) (*FSPolicyIterator, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("%w: file system is nil", errs.ErrorInvalidInput)
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("%w: pattern is empty", errs.ErrorInvalidInput)
+	}
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid pattern (%q): %v", errs.ErrorInvalidInput, pattern, err)
+	}
+	// NOTE: fs.Glob does not document the order of the matches.
+	slices.Sort(matches)
+	iter := &FSPolicyIterator{fsys: fsys, index: -1}
+	for _, p := range matches {
+		if slices.Contains(exclude, p) {
+			continue
+		}
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to stat (%q): %w", errs.ErrorInvalidInput, p, err)
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		iter.paths = append(iter.paths, p)
+	}
+	return iter, nil
+}
+
+// OpenFS opens the file at path in fsys. The returned
+// reader implements Name(), which returns path.
+func OpenFS(fsys fs.FS, path string) (io.ReadCloser, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("%w: file system is nil", errs.ErrorInvalidInput)
+	}
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open (%q): %w", errs.ErrorInvalidInput, path, err)
+	}
+	return &namedFile{File: file, name: path}, nil
+}
+
+// namedFile is a file that implements Name(). The files of
+// some file systems, e.g. fstest.MapFS, do not, and the name
+// of an os.File is its path on disk, not in the file system.
+type namedFile struct {
+	fs.File
+	name string
+}
+
+func (f *namedFile) Name() string {
+	return f.name
+}
+
+func (iter *FSPolicyIterator) Next() (string, io.ReadCloser) {
+	if iter.err != nil {
+		return "", nil
+	}
+	iter.index++
+	p := iter.paths[iter.index]
+	file, err := OpenFS(iter.fsys, p)
+	if err != nil {
+		iter.err = err
+		return "", nil
+	}
+	return p, file
+}
+
+func (iter *FSPolicyIterator) HasNext() bool {
+	if iter.err != nil {
+		return false
+	}
+	return iter.index+1 < len(iter.paths)
+}
+
+func (iter *FSPolicyIterator) Error() error {
+	return iter.err
+}
@@ -0,0 +1,39 @@
+package iterator
+
+import "io"
+
+// Unnamed returns an iterator over the readers of a named iterator,
+// for APIs that do not use the IDs. The readers implement
+// Name() string, which returns the ID of the reader.
+func Unnamed(named NamedReadCloserIterator) ReadCloserIterator {
+	return &unnamedIterator{named: named}
+}
+
+type unnamedIterator struct {
+	named NamedReadCloserIterator
+}
+
+func (iter *unnamedIterator) Next() io.ReadCloser {
+	id, reader := iter.named.Next()
+	if reader == nil {
+		return nil
+	}
+	return &namedReadCloser{ReadCloser: reader, name: id}
+}
+
+func (iter *unnamedIterator) HasNext() bool {
+	return iter.named.HasNext()
+}
+
+func (iter *unnamedIterator) Error() error {
+	return iter.named.Error()
+}
+
+type namedReadCloser struct {
+	io.ReadCloser
+	name string
+}
+
+func (r *namedReadCloser) Name() string {
+	return r.name
+}
@@ -0,0 +1,70 @@
+package limit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// DefaultMaxSize is the default maximum size of a
+// policy file or an attestation, in bytes.
+const DefaultMaxSize int64 = 4 << 20
+
+// DefaultMaxPolicies is the default maximum
+// number of project policy files.
+const DefaultMaxPolicies = 10000
+
+// DefaultMaxPackages is the default maximum number
+// of packages defined by a project policy file.
+const DefaultMaxPackages = 1000
+
+// Reader returns a reader that reads from r and fails with an error
+// wrapping errs.ErrorInvalidInput once more than maxSize bytes are
+// read, so that oversized documents do not grow memory unbounded.
+// A non-positive maxSize defaults to DefaultMaxSize.
+func Reader(r io.Reader, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &reader{r: r, remaining: maxSize, maxSize: maxSize}
+}
+
+type reader struct {
+	r         io.Reader
+	remaining int64
+	maxSize   int64
+}
+
+func (l *reader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err()
+	}
+	// NOTE: one more byte than the maximum is read,
+	// to tell a document of exactly maxSize bytes from
+	// a larger one.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), l.err()
+	}
+	return n, err
+}
+
+func (l *reader) err() error {
+	return fmt.Errorf("%w: content exceeds the maximum size (%d bytes)", errs.ErrorInvalidInput, l.maxSize)
+}
+
+// ReadAll reads r until EOF, see Reader(). Errors
+// wrap errs.ErrorInvalidInput.
+func ReadAll(r io.Reader, maxSize int64) ([]byte, error) {
+	content, err := io.ReadAll(Reader(r, maxSize))
+	if err != nil && !errors.Is(err, errs.ErrorInvalidInput) {
+		return nil, fmt.Errorf("%w: failed to read: %v", errs.ErrorInvalidInput, err)
+	}
+	return content, err
+}
@@ -0,0 +1,93 @@
+package limit
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_ReadAll(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		content  string
+		maxSize  int64
+		oneByte  bool
+		expected error
+	}{
+		{
+			name:    "empty",
+			maxSize: 4,
+		},
+		{
+			name:    "smaller",
+			content: "abc",
+			maxSize: 4,
+		},
+		{
+			name:    "exact size",
+			content: "abcd",
+			maxSize: 4,
+		},
+		{
+			name:    "exact size one byte at a time",
+			content: "abcd",
+			maxSize: 4,
+			oneByte: true,
+		},
+		{
+			name:     "larger",
+			content:  "abcde",
+			maxSize:  4,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "larger one byte at a time",
+			content:  "abcde",
+			maxSize:  4,
+			oneByte:  true,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:    "default size",
+			content: strings.Repeat("a", int(DefaultMaxSize)),
+		},
+		{
+			name:     "larger than default size",
+			content:  strings.Repeat("a", int(DefaultMaxSize)+1),
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := bytes.NewReader([]byte(tt.content))
+			content, err := ReadAll(r, tt.maxSize)
+			if tt.oneByte {
+				r.Reset([]byte(tt.content))
+				content, err = ReadAll(iotest.OneByteReader(r), tt.maxSize)
+			}
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.content, string(content)); diff != "" {
+				t.Fatalf("unexpected content (-want +got): \n%s", diff)
+			}
+		})
+	}
+	// Read errors are reported as invalid input.
+	_, err := ReadAll(iotest.ErrReader(errors.New("read error")), 4)
+	if diff := cmp.Diff(errs.ErrorInvalidInput, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+}
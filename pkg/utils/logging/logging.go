@@ -0,0 +1,25 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discard drops every record.
+var discard = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// OrDiscard returns the logger, or a logger
+// that drops every record if it is nil.
+func OrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discard
+	}
+	return logger
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Components of the observations.
+const (
+	ComponentPublish    = "publish"
+	ComponentDeployment = "deployment"
+)
+
+// Decisions of the observations.
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Metrics records the outcomes of policy evaluations and attestation
+// verifications, e.g. as Prometheus counters. It must be safe for
+// concurrent use.
+type Metrics interface {
+	// ObserveEvaluation records a policy evaluation. The component is
+	// one of the Component* constants, the decision one of the Decision*
+	// constants and code the errs.Category() of the deny error, empty if the
+	// evaluation allows the request.
+	ObserveEvaluation(component, decision, code string, duration time.Duration)
+	// ObserveVerification records an attestation verification,
+	// with the same labels as ObserveEvaluation.
+	ObserveVerification(component, decision, code string, duration time.Duration)
+}
+
+// Decision returns the decision and code labels of
+// an evaluation or verification that returned err.
+func Decision(err error) (decision, code string) {
+	if err == nil {
+		return DecisionAllow, ""
+	}
+	return DecisionDeny, errs.Category(err)
+}
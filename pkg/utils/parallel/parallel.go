@@ -0,0 +1,33 @@
+package parallel
+
+import "sync"
+
+// ForEach calls fn for each index in [0, n), with at most
+// workers concurrent calls, and returns once all calls return.
+// A workers value below 1 is treated as 1. Callers that collect
+// results should write them to distinct entries, indexed by the
+// argument of fn, so that the results do not depend on scheduling.
+func ForEach(n, workers int, fn func(index int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				fn(index)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,75 @@
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ForEach(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		n       int
+		workers int
+	}{
+		{
+			name:    "sequential",
+			n:       10,
+			workers: 1,
+		},
+		{
+			name:    "concurrent",
+			n:       100,
+			workers: 8,
+		},
+		{
+			name:    "more workers than calls",
+			n:       3,
+			workers: 8,
+		},
+		{
+			name:    "zero workers",
+			n:       3,
+			workers: 0,
+		},
+		{
+			name:    "no calls",
+			workers: 4,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var running, maxRunning int32
+			calls := make([]int, tt.n)
+			ForEach(tt.n, tt.workers, func(index int) {
+				current := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					prev := atomic.LoadInt32(&maxRunning)
+					if current <= prev || atomic.CompareAndSwapInt32(&maxRunning, prev, current) {
+						break
+					}
+				}
+				calls[index]++
+			})
+			expected := make([]int, tt.n)
+			for i := range expected {
+				expected[i] = 1
+			}
+			if diff := cmp.Diff(expected, calls); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): \n%s", diff)
+			}
+			limit := tt.workers
+			if limit < 1 {
+				limit = 1
+			}
+			if int(maxRunning) > limit {
+				t.Fatalf("unexpected concurrent calls: %d > %d", maxRunning, limit)
+			}
+		})
+	}
+}
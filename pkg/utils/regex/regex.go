@@ -0,0 +1,29 @@
+// Package regex compiles the patterns of verification options.
+package regex
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// MaxLength is the maximum length of a pattern.
+const MaxLength = 1024
+
+// Compile compiles a pattern. It returns errs.ErrorInvalidInput
+// if the pattern is empty, longer than MaxLength or invalid.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("%w: empty pattern", errs.ErrorInvalidInput)
+	}
+	if len(pattern) > MaxLength {
+		return nil, fmt.Errorf("%w: pattern length (%d) exceeds the limit (%d)", errs.ErrorInvalidInput,
+			len(pattern), MaxLength)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid pattern (%q): %v", errs.ErrorInvalidInput, pattern, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,58 @@
+package regex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_Compile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		pattern  string
+		expected error
+	}{
+		{
+			name:    "valid pattern",
+			pattern: "^https://github.com/org/.*$",
+		},
+		{
+			name:    "max length",
+			pattern: strings.Repeat("a", MaxLength),
+		},
+		{
+			name:     "empty pattern",
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "pattern too long",
+			pattern:  strings.Repeat("a", MaxLength+1),
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid pattern",
+			pattern:  "^(org$",
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			re, err := Compile(tt.pattern)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.pattern, re.String()); diff != "" {
+				t.Fatalf("unexpected pattern (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Default values of the policy.
+const (
+	DefaultMaxAttempts    = 3
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 10 * time.Second
+)
+
+// Policy defines how failed calls are retried. A nil
+// Policy calls the function once, without retries.
+// A Policy is safe for concurrent use once created.
+type Policy struct {
+	// MaxAttempts is the maximum number of calls,
+	// including the first one. It must be 1 or more.
+	MaxAttempts int
+	// InitialBackoff is the time to wait before the first
+	// retry. It doubles after each retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum time to wait before a retry,
+	// including the wait requested by a TransientError.
+	MaxBackoff time.Duration
+	// Retryable returns true if the error may succeed if retried.
+	// It defaults to errs.IsTransient. Errors of a policy decision,
+	// e.g. errs.ErrorVerification, are never retried.
+	Retryable func(error) bool
+	// sleep waits for the duration. It is set by tests.
+	sleep func(time.Duration)
+}
+
+// New returns a policy with the default values.
+func New() *Policy {
+	return &Policy{
+		MaxAttempts:    DefaultMaxAttempts,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+	}
+}
+
+// Validate returns an error if the policy is invalid.
+// A nil policy is valid.
+func (p *Policy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("%w: max attempts (%d) must be 1 or more", errs.ErrorInvalidInput, p.MaxAttempts)
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("%w: backoff (%v, %v) is negative", errs.ErrorInvalidInput, p.InitialBackoff, p.MaxBackoff)
+	}
+	if p.InitialBackoff > p.MaxBackoff {
+		return fmt.Errorf("%w: initial backoff (%v) exceeds max backoff (%v)", errs.ErrorInvalidInput,
+			p.InitialBackoff, p.MaxBackoff)
+	}
+	return nil
+}
+
+// Do calls fn until it succeeds, returns an error that is not
+// retryable, or the maximum number of attempts is reached.
+// It returns the error of the last call. If ctx is done while
+// waiting before a retry, it returns ctx.Err() wrapping the
+// error of the last call.
+func (p *Policy) Do(ctx context.Context, fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+	backoff := p.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+		if ctxErr := p.wait(ctx, max(backoff, retryAfter(err))); ctxErr != nil {
+			return fmt.Errorf("%w: %w", ctxErr, err)
+		}
+		backoff = min(2*backoff, p.MaxBackoff)
+	}
+}
+
+func (p *Policy) retryable(err error) bool {
+	// NOTE: a policy decision is never retried, whatever the predicate.
+	if errors.Is(err, errs.ErrorVerification) || errors.Is(err, errs.ErrorMismatch) ||
+		errors.Is(err, errs.ErrorRevoked) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return errs.IsTransient(err)
+}
+
+// wait waits for the duration, or until ctx is done.
+// It returns ctx.Err() if ctx is done first.
+func (p *Policy) wait(ctx context.Context, d time.Duration) error {
+	d = min(d, p.MaxBackoff)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if p.sleep != nil {
+		p.sleep(d)
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter returns the wait requested by a TransientError, if any.
+func retryAfter(err error) time.Duration {
+	var transient *errs.TransientError
+	if errors.As(err, &transient) {
+		return transient.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,236 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		policy   *Policy
+		expected error
+	}{
+		{
+			name: "nil policy",
+		},
+		{
+			name:   "default policy",
+			policy: New(),
+		},
+		{
+			name:   "no backoff",
+			policy: &Policy{MaxAttempts: 1},
+		},
+		{
+			name:     "no attempts",
+			policy:   &Policy{},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "negative backoff",
+			policy:   &Policy{MaxAttempts: 2, InitialBackoff: -time.Second},
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "initial backoff exceeds max backoff",
+			policy:   &Policy{MaxAttempts: 2, InitialBackoff: 2 * time.Second, MaxBackoff: time.Second},
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.policy.Validate()
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Do(t *testing.T) {
+	t.Parallel()
+	transient := fmt.Errorf("%w: 503 service unavailable", errs.ErrorTransient)
+	rateLimited := errs.TransientErrorNew(errors.New("429 too many requests"), 3*time.Second)
+	denial := errs.TransientErrorNew(fmt.Errorf("%w: no attestation", errs.ErrorVerification), 0)
+	other := errors.New("some error")
+	policy := Policy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	tests := []struct {
+		name      string
+		policy    *Policy
+		retryable func(error) bool
+		results   []error
+		calls     int
+		waits     []time.Duration
+		expected  error
+	}{
+		{
+			name:     "nil policy",
+			results:  []error{transient, nil},
+			calls:    1,
+			expected: transient,
+		},
+		{
+			name:    "success",
+			policy:  &policy,
+			results: []error{nil},
+			calls:   1,
+		},
+		{
+			name:    "transient then success",
+			policy:  &policy,
+			results: []error{transient, transient, nil},
+			calls:   3,
+			waits:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:     "max attempts",
+			policy:   &policy,
+			results:  []error{transient, transient, transient, transient, nil},
+			calls:    4,
+			waits:    []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+			expected: transient,
+		},
+		{
+			name:     "max backoff",
+			policy:   &Policy{MaxAttempts: 4, InitialBackoff: 2 * time.Second, MaxBackoff: 3 * time.Second},
+			results:  []error{transient, transient, transient, transient},
+			calls:    4,
+			waits:    []time.Duration{2 * time.Second, 3 * time.Second, 3 * time.Second},
+			expected: transient,
+		},
+		{
+			name:    "retry after",
+			policy:  &policy,
+			results: []error{rateLimited, nil},
+			calls:   2,
+			waits:   []time.Duration{3 * time.Second},
+		},
+		{
+			name:     "retry after above max backoff",
+			policy:   &Policy{MaxAttempts: 2, MaxBackoff: 2 * time.Second},
+			results:  []error{rateLimited, rateLimited},
+			calls:    2,
+			waits:    []time.Duration{2 * time.Second},
+			expected: rateLimited,
+		},
+		{
+			name:     "not retryable",
+			policy:   &policy,
+			results:  []error{other, nil},
+			calls:    1,
+			expected: other,
+		},
+		{
+			name:     "denial",
+			policy:   &policy,
+			results:  []error{denial, nil},
+			calls:    1,
+			expected: denial,
+		},
+		{
+			name:      "custom predicate",
+			policy:    &policy,
+			retryable: func(err error) bool { return err == other },
+			results:   []error{other, nil},
+			calls:     2,
+			waits:     []time.Duration{time.Second},
+		},
+		{
+			name:      "custom predicate does not retry denials",
+			policy:    &policy,
+			retryable: func(error) bool { return true },
+			results:   []error{denial, nil},
+			calls:     1,
+			expected:  denial,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var waits []time.Duration
+			p := tt.policy
+			if p != nil {
+				c := *p
+				c.Retryable = tt.retryable
+				c.sleep = func(d time.Duration) { waits = append(waits, d) }
+				p = &c
+			}
+			calls := 0
+			err := p.Do(context.Background(), func() error {
+				calls++
+				return tt.results[calls-1]
+			})
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.calls, calls); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.waits, waits); diff != "" {
+				t.Fatalf("unexpected waits (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_DoContext(t *testing.T) {
+	t.Parallel()
+	transient := fmt.Errorf("%w: 503 service unavailable", errs.ErrorTransient)
+	// NOTE: the backoff is long enough for the test to
+	// time out if the wait does not end with the context.
+	policy := Policy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	t.Cleanup(cancelExpired)
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		calls    int
+		expected []error
+	}{
+		{
+			name:     "canceled",
+			ctx:      canceled,
+			calls:    1,
+			expected: []error{context.Canceled, transient},
+		},
+		{
+			name:     "deadline exceeded",
+			ctx:      expired,
+			calls:    1,
+			expected: []error{context.DeadlineExceeded, transient},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			err := policy.Do(tt.ctx, func() error {
+				calls++
+				return transient
+			})
+			for _, expected := range tt.expected {
+				if diff := cmp.Diff(expected, err, cmpopts.EquateErrors()); diff != "" {
+					t.Fatalf("unexpected err (-want +got): \n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(tt.calls, calls); diff != "" {
+				t.Fatalf("unexpected calls (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
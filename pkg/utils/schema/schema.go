@@ -0,0 +1,394 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+	"github.com/slsa-framework/slsa-policy/pkg/utils/yaml"
+)
+
+// Schema is a compiled JSON Schema. It supports the subset of the
+// keywords used by the policy schemas: $ref to local $defs, type,
+// properties, required, additionalProperties, items, enum, const,
+// minimum, maximum, minLength, minItems and pattern. Annotations,
+// e.g. title and description, are ignored. It is never modified
+// once compiled, so it is safe for concurrent use.
+type Schema struct {
+	content []byte
+	root    *node
+}
+
+// node is a schema or sub-schema.
+type node struct {
+	Schema               string           `json:"$schema"`
+	ID                   string           `json:"$id"`
+	Title                string           `json:"title"`
+	Description          string           `json:"description"`
+	Ref                  string           `json:"$ref"`
+	Defs                 map[string]*node `json:"$defs"`
+	Type                 types            `json:"type"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *additional      `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	Enum                 []interface{}    `json:"enum"`
+	Const                *constant        `json:"const"`
+	Minimum              *json.Number     `json:"minimum"`
+	Maximum              *json.Number     `json:"maximum"`
+	MinLength            *int             `json:"minLength"`
+	MinItems             *int             `json:"minItems"`
+	Pattern              string           `json:"pattern"`
+	// pattern is Pattern compiled by Compile().
+	pattern *regexp.Regexp
+}
+
+// types is the value of the type keyword, a type or a list of types.
+type types []string
+
+func (t *types) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = types{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*t = names
+	return nil
+}
+
+// additional is the value of the additionalProperties
+// keyword, either a boolean or a schema.
+type additional struct {
+	allowed bool
+	schema  *node
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return unmarshalNode(data, &a.schema)
+}
+
+// constant is the value of the const keyword.
+type constant struct {
+	value interface{}
+}
+
+func (c *constant) UnmarshalJSON(data []byte) error {
+	return decode(data, &c.value)
+}
+
+// Compile compiles a schema. Unsupported keywords are rejected,
+// so that they are not silently ignored.
+func Compile(content []byte) (*Schema, error) {
+	var root *node
+	if err := unmarshalNode(content, &root); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode schema: %w", errs.ErrorInvalidInput, err)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("%w: schema is empty", errs.ErrorInvalidInput)
+	}
+	if err := root.compile(root); err != nil {
+		return nil, err
+	}
+	return &Schema{
+		content: content,
+		root:    root,
+	}, nil
+}
+
+// MustCompile is like Compile but panics on error.
+// It is meant for schemas embedded in the binary.
+func MustCompile(content []byte) *Schema {
+	s, err := Compile(content)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Bytes returns the content of the schema.
+func (s *Schema) Bytes() []byte {
+	return bytes.Clone(s.content)
+}
+
+func unmarshalNode(data []byte, n **node) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(n)
+}
+
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// compile compiles the patterns and resolves the references of
+// the node and its sub-schemas.
+func (n *node) compile(root *node) error {
+	if n == nil {
+		return nil
+	}
+	if n.Ref != "" {
+		if _, err := root.resolve(n.Ref); err != nil {
+			return err
+		}
+	}
+	for _, name := range n.Type {
+		if !slices.Contains([]string{"object", "array", "string", "integer", "number", "boolean", "null"}, name) {
+			return fmt.Errorf("%w: unknown type (%q)", errs.ErrorInvalidInput, name)
+		}
+	}
+	if n.Pattern != "" {
+		pattern, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: invalid pattern (%q): %w", errs.ErrorInvalidInput, n.Pattern, err)
+		}
+		n.pattern = pattern
+	}
+	children := []*node{n.Items}
+	for _, child := range n.Defs {
+		children = append(children, child)
+	}
+	for _, child := range n.Properties {
+		children = append(children, child)
+	}
+	if n.AdditionalProperties != nil {
+		children = append(children, n.AdditionalProperties.schema)
+	}
+	for _, child := range children {
+		if err := child.compile(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns the definition a reference points to.
+// Only references to the root's $defs are supported.
+func (n *node) resolve(ref string) (*node, error) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported reference (%q)", errs.ErrorInvalidInput, ref)
+	}
+	def, exists := n.Defs[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: undefined reference (%q)", errs.ErrorInvalidInput, ref)
+	}
+	return def, nil
+}
+
+// Validate validates a JSON or YAML document against the schema.
+// Every violation wraps errs.ErrorInvalidField and is prefixed by the
+// JSON pointer of the invalid value, e.g. "/roots/build/0/slsa_level".
+// The violations are accumulated, see errs.Violations(). Properties
+// denied by additionalProperties are accepted if allowUnknownFields
+// is set.
+func (s *Schema) Validate(document []byte, allowUnknownFields bool) error {
+	if !yaml.IsJSON(document) {
+		out, err := yaml.ToJSON(document)
+		if err != nil {
+			return err
+		}
+		document = out
+	}
+	var value interface{}
+	if err := decode(document, &value); err != nil {
+		return fmt.Errorf("%w: %w", errs.ErrorInvalidField, err)
+	}
+	v := validator{
+		root:               s.root,
+		allowUnknownFields: allowUnknownFields,
+	}
+	v.validate(s.root, value, "")
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	root               *node
+	allowUnknownFields bool
+	errs               []error
+}
+
+func (v *validator) errorf(pointer, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%w: %q: %s", errs.ErrorInvalidField, pointer,
+		fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) validate(n *node, value interface{}, pointer string) {
+	if n.Ref != "" {
+		// NOTE: references are resolved by Compile().
+		def, _ := v.root.resolve(n.Ref)
+		v.validate(def, value, pointer)
+	}
+	if len(n.Type) > 0 && !slices.Contains(n.Type, typeOf(value)) &&
+		!(typeOf(value) == "integer" && slices.Contains(n.Type, "number")) {
+		v.errorf(pointer, "type (%s) is invalid. Must be %s", typeOf(value), strings.Join(n.Type, " or "))
+		// NOTE: the other keywords assume a valid type.
+		return
+	}
+	if n.Const != nil && !equal(n.Const.value, value) {
+		v.errorf(pointer, "value (%s) is invalid. Must be %s", encode(value), encode(n.Const.value))
+	}
+	if len(n.Enum) > 0 && !slices.ContainsFunc(n.Enum, func(e interface{}) bool { return equal(e, value) }) {
+		v.errorf(pointer, "value (%s) is invalid. Must be one of %s", encode(value), encode(n.Enum))
+	}
+	switch val := value.(type) {
+	case json.Number:
+		v.validateNumber(n, val, pointer)
+	case string:
+		if n.MinLength != nil && len([]rune(val)) < *n.MinLength {
+			v.errorf(pointer, "length (%d) is invalid. Must be at least %d", len([]rune(val)), *n.MinLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(val) {
+			v.errorf(pointer, "value (%q) does not match pattern (%q)", val, n.Pattern)
+		}
+	case []interface{}:
+		if n.MinItems != nil && len(val) < *n.MinItems {
+			v.errorf(pointer, "length (%d) is invalid. Must contain at least %d item(s)", len(val), *n.MinItems)
+		}
+		if n.Items != nil {
+			for i := range val {
+				v.validate(n.Items, val[i], fmt.Sprintf("%s/%d", pointer, i))
+			}
+		}
+	case map[string]interface{}:
+		v.validateObject(n, val, pointer)
+	}
+}
+
+func (v *validator) validateNumber(n *node, val json.Number, pointer string) {
+	f, err := val.Float64()
+	if err != nil {
+		v.errorf(pointer, "value (%s) is not a valid number", val)
+		return
+	}
+	if n.Minimum != nil {
+		if min, _ := n.Minimum.Float64(); f < min {
+			v.errorf(pointer, "value (%s) is invalid. Must be >= %s", val, *n.Minimum)
+		}
+	}
+	if n.Maximum != nil {
+		if max, _ := n.Maximum.Float64(); f > max {
+			v.errorf(pointer, "value (%s) is invalid. Must be <= %s", val, *n.Maximum)
+		}
+	}
+}
+
+func (v *validator) validateObject(n *node, val map[string]interface{}, pointer string) {
+	for _, name := range n.Required {
+		if _, exists := val[name]; !exists {
+			v.errorf(pointer, "required field (%q) is not defined", name)
+		}
+	}
+	// NOTE: the properties are sorted so that
+	// the violations are reported in a stable order.
+	names := make([]string, 0, len(val))
+	for name := range val {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := pointer + "/" + escape(name)
+		if prop, exists := n.Properties[name]; exists {
+			v.validate(prop, val[name], child)
+			continue
+		}
+		if n.AdditionalProperties == nil {
+			continue
+		}
+		if !n.AdditionalProperties.allowed {
+			if !v.allowUnknownFields {
+				v.errorf(pointer, "unknown field (%q)", name)
+			}
+			continue
+		}
+		if n.AdditionalProperties.schema != nil {
+			v.validate(n.AdditionalProperties.schema, val[name], child)
+		}
+	}
+}
+
+// typeOf returns the JSON Schema type of a decoded value.
+func typeOf(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func equal(a, b interface{}) bool {
+	return encode(a) == encode(b)
+}
+
+func encode(value interface{}) string {
+	// NOTE: maps are encoded with sorted keys.
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(content)
+}
+
+// Pointer returns the JSON pointer of a value from its path, e.g.
+// Pointer("packages", 1, "name") returns "/packages/1/name", so that
+// validations outside of the schema report the invalid value the
+// same way as Validate(). Strings are escaped, see RFC 6901.
+func Pointer(path ...interface{}) string {
+	var pointer strings.Builder
+	for _, elem := range path {
+		pointer.WriteString("/")
+		switch e := elem.(type) {
+		case string:
+			pointer.WriteString(escape(e))
+		default:
+			pointer.WriteString(fmt.Sprint(e))
+		}
+	}
+	return pointer.String()
+}
+
+// escape escapes a property name in a JSON pointer, see RFC 6901.
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+// WrapViolations prefixes each violation of err, e.g. with the name of
+// the policy, so that they are still reported separately by
+// errs.Violations().
+func WrapViolations(prefix string, err error) error {
+	violations := errs.Violations(err)
+	wrapped := make([]error, len(violations))
+	for i := range violations {
+		wrapped[i] = fmt.Errorf("%s: %w", prefix, violations[i])
+	}
+	return errors.Join(wrapped...)
+}
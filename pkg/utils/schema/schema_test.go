@@ -0,0 +1,288 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_Compile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		schema   string
+		expected error
+	}{
+		{
+			name: "valid schema",
+			schema: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"title": "title",
+				"type": "object",
+				"properties": {"name": {"$ref": "#/$defs/name"}},
+				"$defs": {"name": {"type": "string", "pattern": "^[a-z]+$"}}
+			}`,
+		},
+		{
+			name:     "empty schema",
+			schema:   `null`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "unsupported keyword",
+			schema:   `{"type": "object", "oneOf": []}`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "unknown type",
+			schema:   `{"type": "date"}`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "invalid pattern",
+			schema:   `{"properties": {"name": {"pattern": "^[a-z"}}}`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "undefined reference",
+			schema:   `{"items": {"$ref": "#/$defs/undefined"}}`,
+			expected: errs.ErrorInvalidInput,
+		},
+		{
+			name:     "remote reference",
+			schema:   `{"$ref": "https://example.com/schema.json"}`,
+			expected: errs.ErrorInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := Compile([]byte(tt.schema))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.schema, string(s.Bytes())); diff != "" {
+				t.Fatalf("unexpected content (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	s := MustCompile([]byte(`{
+		"type": "object",
+		"required": ["format", "roots"],
+		"additionalProperties": false,
+		"properties": {
+			"format": {"const": 1},
+			"roots": {
+				"type": "array",
+				"minItems": 1,
+				"items": {"$ref": "#/$defs/root"}
+			},
+			"labels": {
+				"type": ["object", "null"],
+				"additionalProperties": {"type": "string"}
+			},
+			"ratio": {"type": "number", "maximum": 1}
+		},
+		"$defs": {
+			"root": {
+				"type": "object",
+				"required": ["name"],
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string", "minLength": 1},
+					"level": {"type": "integer", "minimum": 0, "maximum": 4},
+					"digest": {"type": "string", "pattern": "^sha256:[a-f0-9]+$"},
+					"decision": {"enum": ["allow", "deny"]}
+				}
+			}
+		}
+	}`))
+	tests := []struct {
+		name               string
+		document           string
+		allowUnknownFields bool
+		violations         []string
+		expected           error
+	}{
+		{
+			name:     "valid json",
+			document: `{"format": 1, "roots": [{"name": "root", "level": 3, "digest": "sha256:abc", "decision": "deny"}]}`,
+		},
+		{
+			name: "valid yaml",
+			document: `format: 1
+roots:
+  - name: root
+    level: 0
+labels:
+  team: infra
+ratio: 0.5
+`,
+		},
+		{
+			name:     "null labels",
+			document: `{"format": 1, "roots": [{"name": "root"}], "labels": null}`,
+		},
+		{
+			name:     "integer number",
+			document: `{"format": 1, "roots": [{"name": "root"}], "ratio": 1}`,
+		},
+		{
+			name:               "unknown field allowed",
+			document:           `{"format": 1, "roots": [{"name": "root", "other": true}], "other": 1}`,
+			allowUnknownFields: true,
+		},
+		{
+			name:     "unknown fields",
+			document: `{"format": 1, "roots": [{"name": "root", "other": true}], "other": 1}`,
+			violations: []string{
+				`invalid field: "": unknown field ("other")`,
+				`invalid field: "/roots/0": unknown field ("other")`,
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "missing required fields",
+			document: `{}`,
+			violations: []string{
+				`invalid field: "": required field ("format") is not defined`,
+				`invalid field: "": required field ("roots") is not defined`,
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name: "invalid values",
+			document: `{"format": 2, "roots": [{"name": "", "level": 5, "digest": "sha256:ABC", "decision": "skip"}],
+				"labels": {"a/b": 1}, "ratio": 1.5}`,
+			violations: []string{
+				`invalid field: "/format": value (2) is invalid. Must be 1`,
+				`invalid field: "/labels/a~1b": type (integer) is invalid. Must be string`,
+				`invalid field: "/ratio": value (1.5) is invalid. Must be <= 1`,
+				`invalid field: "/roots/0/decision": value ("skip") is invalid. Must be one of ["allow","deny"]`,
+				`invalid field: "/roots/0/digest": value ("sha256:ABC") does not match pattern ("^sha256:[a-f0-9]+$")`,
+				`invalid field: "/roots/0/level": value (5) is invalid. Must be <= 4`,
+				`invalid field: "/roots/0/name": length (0) is invalid. Must be at least 1`,
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid types",
+			document: `{"format": "1", "roots": {}, "ratio": null}`,
+			violations: []string{
+				`invalid field: "/format": value ("1") is invalid. Must be 1`,
+				`invalid field: "/ratio": type (null) is invalid. Must be number`,
+				`invalid field: "/roots": type (object) is invalid. Must be array`,
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty roots",
+			document: `{"format": 1, "roots": []}`,
+			violations: []string{
+				`invalid field: "/roots": length (0) is invalid. Must contain at least 1 item(s)`,
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "not an object",
+			document: `- format`,
+			violations: []string{
+				`invalid field: "": type (array) is invalid. Must be object`,
+			},
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid json",
+			document: `{"format": 1,`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := s.Validate([]byte(tt.document), tt.allowUnknownFields)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if tt.violations == nil {
+				return
+			}
+			var violations []string
+			for _, violation := range errs.Violations(err) {
+				violations = append(violations, violation.Error())
+			}
+			if diff := cmp.Diff(tt.violations, violations); diff != "" {
+				t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Pointer(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		path     []interface{}
+		expected string
+	}{
+		{
+			name: "root",
+		},
+		{
+			name:     "property",
+			path:     []interface{}{"format"},
+			expected: "/format",
+		},
+		{
+			name:     "nested field",
+			path:     []interface{}{"packages", 1, "environment", "any_of", 0},
+			expected: "/packages/1/environment/any_of/0",
+		},
+		{
+			name:     "escaped name",
+			path:     []interface{}{"principal", "environments", "prod/eu~1"},
+			expected: "/principal/environments/prod~1eu~01",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tt.expected, Pointer(tt.path...)); diff != "" {
+				t.Fatalf("unexpected pointer (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_WrapViolations(t *testing.T) {
+	t.Parallel()
+	err := WrapViolations("policy", errors.Join(
+		fmt.Errorf("%w: first", errs.ErrorInvalidField),
+		fmt.Errorf("%w: second", errs.ErrorInvalidField),
+	))
+	if diff := cmp.Diff(errs.ErrorInvalidField, err, cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("unexpected err (-want +got): \n%s", diff)
+	}
+	var violations []string
+	for _, violation := range errs.Violations(err) {
+		violations = append(violations, violation.Error())
+	}
+	expected := []string{"policy: invalid field: first", "policy: invalid field: second"}
+	if diff := cmp.Diff(expected, violations); diff != "" {
+		t.Fatalf("unexpected violations (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,109 @@
+// Package semver parses and compares semantic versions, see https://semver.org.
+package semver
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// Version is a parsed semantic version.
+type Version struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+// Parse parses a version of the form [v]MAJOR[.MINOR[.PATCH]][-PRERELEASE][+BUILD].
+// Missing minor and patch components default to 0. Build metadata is ignored.
+// It returns errs.ErrorInvalidField if the version is invalid.
+func Parse(version string) (*Version, error) {
+	s := strings.TrimPrefix(version, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		if s[i+1:] == "" {
+			return nil, fmt.Errorf("%w: version (%q) has empty build metadata", errs.ErrorInvalidField, version)
+		}
+		s = s[:i]
+	}
+	var v Version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range v.prerelease {
+			if id == "" {
+				return nil, fmt.Errorf("%w: version (%q) has an empty pre-release identifier", errs.ErrorInvalidField, version)
+			}
+		}
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("%w: version (%q) has too many components", errs.ErrorInvalidField, version)
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: version (%q) has invalid component (%q)", errs.ErrorInvalidField, version, part)
+		}
+		v.core[i] = n
+	}
+	return &v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is respectively lower than,
+// equal to or greater than o.
+func (v *Version) Compare(o *Version) int {
+	for i := range v.core {
+		if v.core[i] != o.core[i] {
+			return cmp.Compare(v.core[i], o.core[i])
+		}
+	}
+	// A version without pre-release has higher precedence.
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := comparePrerelease(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.prerelease), len(o.prerelease))
+}
+
+func comparePrerelease(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(an, bn)
+	// Numeric identifiers have lower precedence than alphanumeric ones.
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// AtLeast returns errs.ErrorMismatch if version is lower than
+// minimum, and errs.ErrorInvalidField if either is invalid.
+func AtLeast(version, minimum string) error {
+	minVersion, err := Parse(minimum)
+	if err != nil {
+		return err
+	}
+	actual, err := Parse(version)
+	if err != nil {
+		return err
+	}
+	if actual.Compare(minVersion) < 0 {
+		return fmt.Errorf("%w: version (%q) < minimum version (%q)", errs.ErrorMismatch, version, minimum)
+	}
+	return nil
+}
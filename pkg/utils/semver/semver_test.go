@@ -0,0 +1,74 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_AtLeast(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		version  string
+		minimum  string
+		expected error
+	}{
+		{
+			name:    "equal",
+			version: "1.2.3",
+			minimum: "1.2.3",
+		},
+		{
+			name:    "greater",
+			version: "v1.10.0",
+			minimum: "1.9",
+		},
+		{
+			name:     "lower",
+			version:  "1.2.3",
+			minimum:  "1.3",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:     "pre-release lower than release",
+			version:  "1.3.0-rc.1",
+			minimum:  "1.3.0",
+			expected: errs.ErrorMismatch,
+		},
+		{
+			name:    "build metadata ignored",
+			version: "1.3.0+build.1",
+			minimum: "1.3.0",
+		},
+		{
+			name:     "invalid version",
+			version:  "one",
+			minimum:  "1.3.0",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "invalid minimum",
+			version:  "1.3.0",
+			minimum:  "1.3.0.1",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "empty version",
+			minimum:  "1.3.0",
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := AtLeast(tt.version, tt.minimum)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
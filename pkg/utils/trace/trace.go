@@ -0,0 +1,138 @@
+package trace
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Outcome is the outcome of a step.
+type Outcome string
+
+// Outcomes of the steps.
+const (
+	// OutcomeSelected is the outcome of the candidate
+	// that allowed the request.
+	OutcomeSelected Outcome = "selected"
+	// OutcomeRejected is the outcome of a candidate
+	// that did not allow the request.
+	OutcomeRejected Outcome = "rejected"
+)
+
+// Reason is the reason of the outcome of a step.
+// NOTE: the values are stable and must not be changed,
+// since callers may serialize or match them.
+type Reason string
+
+// Reasons of the outcomes.
+const (
+	// ReasonInvalidInput is the reason of a request
+	// rejected before any candidate is considered.
+	ReasonInvalidInput Reason = "invalid_input"
+	// ReasonRevoked is the reason of a digest rejected
+	// by the revocation list.
+	ReasonRevoked Reason = "revoked"
+	// ReasonPolicyNotFound is the reason of a request
+	// for a project policy that does not exist.
+	ReasonPolicyNotFound Reason = "policy_not_found"
+	// ReasonPackageNotFound is the reason of a request for
+	// a package not defined by the project policies.
+	ReasonPackageNotFound Reason = "package_not_found"
+	// ReasonDigestNotAccepted is the reason of a request with
+	// digest algorithms the package does not accept.
+	ReasonDigestNotAccepted Reason = "digest_not_accepted"
+	// ReasonException is the reason of a request
+	// decided by an exception.
+	ReasonException Reason = "exception"
+	// ReasonEnvironmentMismatch is the reason of a candidate
+	// that does not match the requested or allowed environments.
+	ReasonEnvironmentMismatch Reason = "environment_mismatch"
+	// ReasonLevelTooLow is the reason of a root whose
+	// SLSA build level is below the required level.
+	ReasonLevelTooLow Reason = "level_too_low"
+	// ReasonRootNotAllowed is the reason of a root the
+	// policies do not allow for the request.
+	ReasonRootNotAllowed Reason = "root_not_allowed"
+	// ReasonVerifierFailure is the reason of a candidate
+	// whose attestation failed verification.
+	ReasonVerifierFailure Reason = "verifier_failure"
+	// ReasonBaseImageNotApproved is the reason of a package
+	// built from a base image the policy does not approve.
+	ReasonBaseImageNotApproved Reason = "base_image_not_approved"
+	// ReasonCustomRules is the reason of a request
+	// rejected by the custom rules of a policy.
+	ReasonCustomRules Reason = "custom_rules"
+	// ReasonVerified is the reason of the selected candidate.
+	ReasonVerified Reason = "verified"
+)
+
+// Step is a candidate considered by an evaluation,
+// e.g. a root, and the outcome of its evaluation.
+type Step struct {
+	// PolicyID is the ID of the project policy, if any.
+	PolicyID string `json:"policy_id,omitempty"`
+	// Package is the name of the package.
+	Package string `json:"package,omitempty"`
+	// RootID is the ID of the root, if the step
+	// considers a root.
+	RootID string `json:"root_id,omitempty"`
+	// Environments are the environments
+	// considered, if any.
+	Environments []string `json:"environments,omitempty"`
+	Outcome      Outcome  `json:"outcome"`
+	Reason       Reason   `json:"reason"`
+	// Error details why the candidate was rejected.
+	Error string `json:"error,omitempty"`
+}
+
+// Trace is the ordered list of the steps of an evaluation.
+// A nil Trace records nothing, so that evaluations without
+// a trace have no overhead. It is not safe for concurrent use.
+type Trace struct {
+	Steps []Step `json:"steps"`
+}
+
+// New returns an empty trace.
+func New() *Trace {
+	return &Trace{Steps: []Step{}}
+}
+
+// Enabled returns true if the trace records steps.
+func (t *Trace) Enabled() bool {
+	return t != nil
+}
+
+// Record records the step, with err as its error, if set.
+func (t *Trace) Record(step Step, err error) {
+	if t == nil {
+		return
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	step.Environments = slices.Clone(step.Environments)
+	t.Steps = append(t.Steps, step)
+}
+
+// Recordf records the step, with an error formatted
+// from format and args. The arguments are not formatted
+// if the trace is nil.
+func (t *Trace) Recordf(step Step, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	step.Error = fmt.Sprintf(format, args...)
+	t.Record(step, nil)
+}
+
+// Clone returns a copy of the trace, or nil if the trace is nil.
+func (t *Trace) Clone() *Trace {
+	if t == nil {
+		return nil
+	}
+	c := &Trace{Steps: make([]Step, len(t.Steps))}
+	for i, step := range t.Steps {
+		step.Environments = slices.Clone(step.Environments)
+		c.Steps[i] = step
+	}
+	return c
+}
@@ -0,0 +1,84 @@
+package trace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Record(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		trace    *Trace
+		expected *Trace
+	}{
+		{
+			name: "nil trace",
+		},
+		{
+			name:  "trace",
+			trace: New(),
+			expected: &Trace{
+				Steps: []Step{
+					{
+						PolicyID: "policy_id", Package: "package_name", RootID: "root_id",
+						Environments: []string{"dev"}, Outcome: OutcomeRejected,
+						Reason: ReasonVerifierFailure, Error: "verification failed",
+					},
+					{
+						Package: "package_name", RootID: "root_id", Outcome: OutcomeRejected,
+						Reason: ReasonLevelTooLow, Error: "level (1) below (2)",
+					},
+					{
+						Package: "package_name", RootID: "root_id", Outcome: OutcomeSelected,
+						Reason: ReasonVerified,
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			envs := []string{"dev"}
+			tt.trace.Record(Step{PolicyID: "policy_id", Package: "package_name", RootID: "root_id",
+				Environments: envs, Outcome: OutcomeRejected, Reason: ReasonVerifierFailure},
+				errors.New("verification failed"))
+			// The recorded environments must not alias the caller's slice.
+			envs[0] = "prod"
+			tt.trace.Recordf(Step{Package: "package_name", RootID: "root_id",
+				Outcome: OutcomeRejected, Reason: ReasonLevelTooLow}, "level (%d) below (%d)", 1, 2)
+			tt.trace.Record(Step{Package: "package_name", RootID: "root_id",
+				Outcome: OutcomeSelected, Reason: ReasonVerified}, nil)
+			if diff := cmp.Diff(tt.expected != nil, tt.trace.Enabled()); diff != "" {
+				t.Fatalf("unexpected enabled (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, tt.trace); diff != "" {
+				t.Fatalf("unexpected trace (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Clone(t *testing.T) {
+	t.Parallel()
+	var tr *Trace
+	if c := tr.Clone(); c != nil {
+		t.Fatalf("unexpected clone: %v", c)
+	}
+	tr = New()
+	tr.Record(Step{Package: "package_name", Environments: []string{"dev"},
+		Outcome: OutcomeRejected, Reason: ReasonEnvironmentMismatch}, errors.New("mismatch"))
+	c := tr.Clone()
+	if diff := cmp.Diff(tr, c); diff != "" {
+		t.Fatalf("unexpected clone (-want +got): \n%s", diff)
+	}
+	c.Steps[0].Environments[0] = "prod"
+	c.Steps[0].Package = "other_name"
+	if tr.Steps[0].Environments[0] != "dev" || tr.Steps[0].Package != "package_name" {
+		t.Fatalf("clone modified the trace: %v", tr)
+	}
+}
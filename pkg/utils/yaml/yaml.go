@@ -0,0 +1,437 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+// IsJSON returns true if content is a JSON document, i.e.,
+// its first non-space character starts a JSON object.
+func IsJSON(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte("{"))
+}
+
+// Unmarshal parses content into v. Documents that are not JSON are
+// parsed as YAML. Unless allowUnknownFields is set, documents with
+// fields that do not exist in v are rejected.
+func Unmarshal(content []byte, v interface{}, allowUnknownFields bool) error {
+	if !IsJSON(content) {
+		out, err := ToJSON(content)
+		if err != nil {
+			return err
+		}
+		content = out
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if !allowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		// NOTE: the decoder does not export the unknown field error.
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("%w: unknown field %s", errs.ErrorInvalidField, field)
+		}
+		return fmt.Errorf("%w: %w", errs.ErrorInvalidField, err)
+	}
+	// NOTE: json.Unmarshal rejects trailing data.
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("%w: unexpected data after the document", errs.ErrorInvalidField)
+	}
+	return nil
+}
+
+// ToJSON converts a YAML document into JSON. It supports the subset
+// of YAML used by policy files: block mappings and sequences, flow
+// sequences and mappings, plain and quoted scalars and comments.
+// Anchors, aliases, tags, block scalars and multiple documents are
+// rejected.
+func ToJSON(content []byte) ([]byte, error) {
+	lines, err := splitLines(string(content))
+	if err != nil {
+		return nil, err
+	}
+	p := parser{lines: lines}
+	var value interface{}
+	if len(lines) > 0 {
+		value, err = p.parseBlock(lines[0].indent)
+		if err != nil {
+			return nil, err
+		}
+		if p.index < len(p.lines) {
+			return nil, p.errorf("unexpected content")
+		}
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to marshal: %v", errs.ErrorInternal, err)
+	}
+	return out, nil
+}
+
+type line struct {
+	number int
+	indent int
+	text   string
+}
+
+type parser struct {
+	lines []line
+	index int
+}
+
+func (p *parser) errorf(format string, a ...any) error {
+	number := 0
+	if p.index < len(p.lines) {
+		number = p.lines[p.index].number
+	} else if len(p.lines) > 0 {
+		number = p.lines[len(p.lines)-1].number
+	}
+	return fmt.Errorf("%w: yaml line %d: %s", errs.ErrorInvalidField, number, fmt.Sprintf(format, a...))
+}
+
+// splitLines removes comments, blank lines and document markers,
+// and computes the indentation of each line.
+func splitLines(content string) ([]line, error) {
+	var lines []line
+	for i, raw := range strings.Split(content, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimRight(stripComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (trimmed == "---" && len(lines) == 0) {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("%w: yaml line %d: tabs are not allowed for indentation", errs.ErrorInvalidField, i+1)
+		}
+		if trimmed == "---" || trimmed == "..." {
+			return nil, fmt.Errorf("%w: yaml line %d: multiple documents are not supported", errs.ErrorInvalidField, i+1)
+		}
+		lines = append(lines, line{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	return lines, nil
+}
+
+// stripComment removes a comment that starts outside of quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *parser) parseBlock(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.index].text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitKey(p.lines[p.index].text); ok {
+		return p.parseMapping(indent)
+	}
+	value, err := parseInline(p.lines[p.index].text)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.index++
+	return value, nil
+}
+
+func (p *parser) parseSequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.index < len(p.lines) {
+		l := &p.lines[p.index]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !isSequenceItem(l.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.index++
+			if p.index >= len(p.lines) || p.lines[p.index].indent <= indent {
+				list = append(list, nil)
+				continue
+			}
+			value, err := p.parseBlock(p.lines[p.index].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+		// The item is parsed as a block starting at the item's content,
+		// e.g. "- name: value" is a mapping indented past the dash.
+		l.indent += len(l.text) - len(rest)
+		l.text = rest
+		value, err := p.parseBlock(l.indent)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+func (p *parser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.index < len(p.lines) {
+		l := p.lines[p.index]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isSequenceItem(l.text) {
+			break
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, p.errorf("expected a mapping key")
+		}
+		if _, exists := m[key]; exists {
+			return nil, p.errorf("key (%q) is defined more than once", key)
+		}
+		if rest != "" {
+			value, err := parseInline(rest)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			m[key] = value
+			p.index++
+			continue
+		}
+		p.index++
+		switch {
+		case p.index < len(p.lines) && p.lines[p.index].indent > indent:
+			value, err := p.parseBlock(p.lines[p.index].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		// A sequence may have the same indentation as its key.
+		case p.index < len(p.lines) && p.lines[p.index].indent == indent && isSequenceItem(p.lines[p.index].text):
+			value, err := p.parseSequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		default:
+			m[key] = nil
+		}
+	}
+	return m, nil
+}
+
+// splitKey splits a "key: value" entry. The key may be quoted.
+func splitKey(text string) (string, string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := quoteEnd(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		key, err := parseQuoted(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// quoteEnd returns the index of the quote closing the quoted
+// string at the start of s, or -1.
+func quoteEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			// Two single quotes are an escaped single quote.
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func parseQuoted(s string) (string, error) {
+	if s[0] == '"' {
+		var v string
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return "", fmt.Errorf("invalid double-quoted string (%s)", s)
+		}
+		return v, nil
+	}
+	return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+}
+
+func parseInline(s string) (interface{}, error) {
+	switch s[0] {
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported (%s)", s)
+	case '|', '>':
+		return nil, fmt.Errorf("block scalars are not supported (%s)", s)
+	case '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("unterminated flow sequence (%s)", s)
+		}
+		items, err := splitFlow(s[1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for _, item := range items {
+			value, err := parseInline(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case '{':
+		if s[len(s)-1] != '}' {
+			return nil, fmt.Errorf("unterminated flow mapping (%s)", s)
+		}
+		items, err := splitFlow(s[1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]interface{}{}
+		for _, item := range items {
+			key, rest, ok := splitKey(item)
+			if !ok {
+				return nil, fmt.Errorf("expected a mapping key (%s)", item)
+			}
+			if _, exists := m[key]; exists {
+				return nil, fmt.Errorf("key (%q) is defined more than once", key)
+			}
+			var value interface{}
+			if rest != "" {
+				if value, err = parseInline(rest); err != nil {
+					return nil, err
+				}
+			}
+			m[key] = value
+		}
+		return m, nil
+	case '"', '\'':
+		if quoteEnd(s) != len(s)-1 {
+			return nil, fmt.Errorf("invalid quoted string (%s)", s)
+		}
+		return parseQuoted(s)
+	}
+	return parsePlain(s)
+}
+
+// splitFlow splits the content of a flow collection at top-level commas.
+func splitFlow(s string) ([]string, error) {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := quoteEnd(s[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string (%s)", s[i:])
+			}
+			i += end
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced flow collection (%s)", s)
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	} else if len(items) > 0 {
+		return nil, fmt.Errorf("empty flow collection item (%s)", s)
+	}
+	for _, item := range items {
+		if item == "" {
+			return nil, fmt.Errorf("empty flow collection item (%s)", s)
+		}
+	}
+	return items, nil
+}
+
+// parsePlain parses a plain scalar. Integers that overflow int64
+// and non-finite floats, e.g. "inf" or "nan", are strings, since
+// they have no exact JSON representation.
+func parsePlain(s string) (interface{}, error) {
+	// NOTE: a mapping value cannot contain a mapping, e.g. "a: b: c".
+	if strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return nil, fmt.Errorf("mapping values are not allowed in plain scalars (%s)", s)
+	}
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return i, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return s, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXpP_") &&
+		!math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f, nil
+	}
+	return s, nil
+}
@@ -0,0 +1,273 @@
+package yaml
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slsa-framework/slsa-policy/pkg/errs"
+)
+
+func Test_ToJSON(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		yaml     string
+		json     string
+		expected error
+	}{
+		{
+			name: "empty document",
+			yaml: "# only a comment\n",
+			json: `null`,
+		},
+		{
+			name: "mapping with comments",
+			yaml: `---
+# The format.
+format: 1 # inline comment
+name: "value # not a comment"
+other: 'it''s'
+`,
+			json: `{"format": 1, "name": "value # not a comment", "other": "it's"}`,
+		},
+		{
+			name: "nested mappings and sequences",
+			yaml: `format: 1
+roots:
+  build:
+    - id: https://github.com/actions/runner
+      name: github_actions
+      slsa_level: 3
+    - id: builder2
+      name: other
+      slsa_level: 2
+`,
+			json: `{"format": 1, "roots": {"build": [
+				{"id": "https://github.com/actions/runner", "name": "github_actions", "slsa_level": 3},
+				{"id": "builder2", "name": "other", "slsa_level": 2}]}}`,
+		},
+		{
+			name: "sequence at key indentation",
+			yaml: `any_of:
+- dev
+- prod
+`,
+			json: `{"any_of": ["dev", "prod"]}`,
+		},
+		{
+			name: "flow collections",
+			yaml: `environment: {any_of: [dev, "prod", 'staging']}
+empty_list: []
+empty_map: {}
+`,
+			json: `{"environment": {"any_of": ["dev", "prod", "staging"]}, "empty_list": [], "empty_map": {}}`,
+		},
+		{
+			name: "scalars",
+			yaml: `int: 3
+float: 1.5
+yes: true
+no: false
+nothing: null
+tilde: ~
+empty:
+version: 1.2.3
+quoted_int: "3"
+url: https://example.com:8080/path
+`,
+			json: `{"int": 3, "float": 1.5, "yes": true, "no": false, "nothing": null, "tilde": null,
+				"empty": null, "version": "1.2.3", "quoted_int": "3", "url": "https://example.com:8080/path"}`,
+		},
+		{
+			name: "non-finite floats",
+			yaml: `nan: nan
+inf: inf
+infinity: -Infinity
+upper: Inf
+`,
+			json: `{"nan": "nan", "inf": "inf", "infinity": "-Infinity", "upper": "Inf"}`,
+		},
+		{
+			name: "integer overflow",
+			yaml: `big: 9223372036854775808
+small: -9223372036854775809
+max: 9223372036854775807
+`,
+			json: `{"big": "9223372036854775808", "small": "-9223372036854775809", "max": 9223372036854775807}`,
+		},
+		{
+			name: "nested sequences",
+			yaml: `list:
+  -
+    - a
+    - b
+  - - c
+`,
+			json: `{"list": [["a", "b"], ["c"]]}`,
+		},
+		{
+			name: "JSON document",
+			yaml: `{"format": 1, "list": ["a", "b"]}`,
+			json: `{"format": 1, "list": ["a", "b"]}`,
+		},
+		{
+			name:     "duplicate key",
+			yaml:     "key: 1\nkey: 2\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "bad indentation",
+			yaml:     "key:\n  a: 1\n    b: 2\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "tab indentation",
+			yaml:     "key:\n\ta: 1\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "anchor",
+			yaml:     "key: &anchor value\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "alias",
+			yaml:     "key: *anchor\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "block scalar",
+			yaml:     "key: |\n  text\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "multiple documents",
+			yaml:     "key: 1\n---\nkey: 2\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "unterminated flow sequence",
+			yaml:     "key: [a, b\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "mapping in plain scalar",
+			yaml:     "key: a: b\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "mapping in sequence item",
+			yaml:     "key:\n  - a: b: c\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "mapping in flow mapping",
+			yaml:     "key: {a: b: c}\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "trailing colon",
+			yaml:     "key: value:\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "mixed mapping and sequence",
+			yaml:     "key: 1\n- a\n",
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			out, err := ToJSON([]byte(tt.yaml))
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatalf("failed to unmarshal output (%s): %v", out, err)
+			}
+			if err := json.Unmarshal([]byte(tt.json), &want); err != nil {
+				t.Fatalf("failed to unmarshal expected: %v", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("unexpected json (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Unmarshal(t *testing.T) {
+	t.Parallel()
+	type policy struct {
+		Format int `json:"format"`
+	}
+	tests := []struct {
+		name               string
+		content            string
+		allowUnknownFields bool
+		policy             policy
+		expected           error
+	}{
+		{
+			name:    "json",
+			content: `{"format": 1}`,
+			policy:  policy{Format: 1},
+		},
+		{
+			name:    "yaml",
+			content: "format: 1\n",
+			policy:  policy{Format: 1},
+		},
+		{
+			name:     "json unknown field",
+			content:  `{"format": 1, "formats": 2}`,
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:     "yaml unknown field",
+			content:  "format: 1\nformats: 2\n",
+			expected: errs.ErrorInvalidField,
+		},
+		{
+			name:               "json allowed unknown field",
+			content:            `{"format": 1, "formats": 2}`,
+			allowUnknownFields: true,
+			policy:             policy{Format: 1},
+		},
+		{
+			name:               "yaml allowed unknown field",
+			content:            "format: 1\nformats: 2\n",
+			allowUnknownFields: true,
+			policy:             policy{Format: 1},
+		},
+		{
+			name:     "json trailing data",
+			content:  `{"format": 1}}`,
+			expected: errs.ErrorInvalidField,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var p policy
+			err := Unmarshal([]byte(tt.content), &p, tt.allowUnknownFields)
+			if diff := cmp.Diff(tt.expected, err, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected err (-want +got): \n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.policy, p); diff != "" {
+				t.Fatalf("unexpected policy (-want +got): \n%s", diff)
+			}
+		})
+	}
+}